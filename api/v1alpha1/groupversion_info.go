@@ -1,6 +1,7 @@
 // Package v1alpha1 contains API Schema definitions for the x402.io v1alpha1 API group.
 // +kubebuilder:object:generate=true
 // +groupName=x402.io
+// +groupGoName=X402
 package v1alpha1
 
 import (
@@ -17,4 +18,13 @@ var (
 
 	// AddToScheme adds the types in this group-version to the given scheme.
 	AddToScheme = SchemeBuilder.AddToScheme
+
+	// SchemeGroupVersion is GroupVersion under the name the generated clients
+	// in pkg/generated expect.
+	SchemeGroupVersion = GroupVersion
 )
+
+// Resource takes an unqualified resource and returns a Group qualified GroupResource.
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
@@ -0,0 +1,48 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// X402PricePlanSpec defines pricing rules shared by several X402Routes.
+type X402PricePlanSpec struct {
+	// DefaultPrice is the price of rules without a price of their own, in
+	// routes that reference the plan and set no payment.defaultPrice.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([$€£]?\s*[0-9]+(\.[0-9]+)?\s+[A-Z]{3}|[0-9]+(\.[0-9]+)?|\$\{[-._a-zA-Z0-9]+\})$`
+	DefaultPrice string `json:"defaultPrice,omitempty"`
+
+	// Routes defines per-path pricing rules, as in X402Route. Referencing
+	// routes match them after their own rules.
+	// +kubebuilder:validation:MaxItems=256
+	Routes []RouteRule `json:"routes"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName={x4pp},categories=all
+// +kubebuilder:printcolumn:name="Default Price",type="string",JSONPath=".spec.defaultPrice"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// X402PricePlan is the Schema for the x402priceplans API: a reusable set of
+// pricing rules that X402Routes reference with spec.pricePlanRef.
+type X402PricePlan struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec X402PricePlanSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// X402PricePlanList contains a list of X402PricePlan.
+type X402PricePlanList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []X402PricePlan `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&X402PricePlan{}, &X402PricePlanList{})
+}
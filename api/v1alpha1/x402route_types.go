@@ -5,6 +5,8 @@ import (
 )
 
 // X402RouteSpec defines the desired state of X402Route.
+// +kubebuilder:validation:XValidation:rule="!has(self.clusterOverrides) || self.clusterOverrides.all(o, !has(o.path) || self.routes.exists(r, r.path == o.path))",message="clusterOverrides[].path must be the path of a rule in routes"
+// +kubebuilder:validation:XValidation:rule="!has(self.waitingRoom) || (has(self.maxConcurrent) && self.maxConcurrent > 0)",message="waitingRoom requires maxConcurrent"
 type X402RouteSpec struct {
 	// IngressRef references the existing Ingress to patch with payment gating.
 	IngressRef IngressReference `json:"ingressRef"`
@@ -13,7 +15,385 @@ type X402RouteSpec struct {
 	Payment PaymentDefaults `json:"payment"`
 
 	// Routes defines per-path pricing rules.
+	// +kubebuilder:validation:MaxItems=256
 	Routes []RouteRule `json:"routes"`
+
+	// PricePlanRef adds the rules of an X402PricePlan in the route's
+	// namespace after the route's own rules. An own rule with the same path
+	// replaces the plan's, and payment.defaultPrice, when set, replaces the
+	// plan's default price.
+	// +optional
+	PricePlanRef *PricePlanReference `json:"pricePlanRef,omitempty"`
+
+	// VariablesFrom lists ConfigMaps and Secrets in the route's namespace
+	// whose keys replace ${KEY} placeholders in the spec, such as a
+	// per-environment payment.wallet, so identical manifests can be promoted
+	// across stages. Later sources win on duplicate keys, values are trimmed
+	// of surrounding whitespace, and "$${" writes a literal "${". ingressRef,
+	// pricePlanRef and variablesFrom itself are not substituted. A changed
+	// source is picked up within a minute.
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	VariablesFrom []VariableSource `json:"variablesFrom,omitempty"`
+
+	// ConfirmPatch holds Ingress changes for review. The controller publishes a
+	// diff of the pending patch in status.pendingPatch and leaves the Ingress
+	// untouched until this is set back to false.
+	// +optional
+	ConfirmPatch bool `json:"confirmPatch,omitempty"`
+
+	// UnmatchedBehavior controls requests that reach the gateway but match no
+	// rule: "404" (default) rejects them, "passthrough" forwards them unpaid to
+	// the original backend recorded for the Ingress path.
+	// +optional
+	// +kubebuilder:validation:Enum="404";passthrough
+	// +kubebuilder:default="404"
+	UnmatchedBehavior string `json:"unmatchedBehavior,omitempty"`
+
+	// BackendResolution selects how the gateway reaches backends: "service"
+	// (default) addresses the Service DNS name, "endpoints" load-balances over
+	// the ready addresses in the Service's EndpointSlices.
+	// +optional
+	// +kubebuilder:validation:Enum=service;endpoints
+	// +kubebuilder:default="service"
+	BackendResolution string `json:"backendResolution,omitempty"`
+
+	// BackendProtocols sets the protocol the gateway speaks to backend
+	// Services. Services not listed use the Ingress's
+	// nginx.ingress.kubernetes.io/backend-protocol annotation, the appProtocol
+	// of their Service port, or HTTP/1.1.
+	// +optional
+	// +kubebuilder:validation:MaxItems=64
+	BackendProtocols []BackendProtocol `json:"backendProtocols,omitempty"`
+
+	// BackendTLS configures TLS to backend Services: the CA their
+	// certificates are verified against and the server name sent. Listed
+	// Services are spoken to over TLS. Without an entry, TLS backends are not
+	// verified, as with ingress-nginx.
+	// +optional
+	// +kubebuilder:validation:MaxItems=64
+	BackendTLS []BackendTLS `json:"backendTLS,omitempty"`
+
+	// OnFacilitatorError controls paid requests when the facilitator cannot be
+	// reached or returns an error: "failClosed" (default) answers 402,
+	// "failOpen" forwards the request unpaid to the backend, and "staticOK"
+	// answers 200 without contacting the backend. Fail-open requests are
+	// counted in metrics and reported as Warning events.
+	// +optional
+	// +kubebuilder:validation:Enum=failClosed;failOpen;staticOK
+	// +kubebuilder:default="failClosed"
+	OnFacilitatorError string `json:"onFacilitatorError,omitempty"`
+
+	// BypassPercent sends this percentage of the traffic on gated paths
+	// straight to the original backends, unpaid, through an ingress-nginx
+	// canary Ingress. It is an escape hatch for gateway incidents; 0 (default)
+	// sends all traffic through the gateway. Requires ingress-nginx.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	BypassPercent int32 `json:"bypassPercent,omitempty"`
+
+	// MaxConcurrent caps the paid requests each gateway replica forwards to
+	// the backend at the same time. Requests over the cap are answered 503
+	// with Retry-After before their payment is settled. 0 (default) is
+	// unlimited.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrent int32 `json:"maxConcurrent,omitempty"`
+
+	// MaxQueueWaitSeconds lets requests over maxConcurrent wait this long for
+	// a free slot before they are rejected. 0 (default) rejects them at once.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=60
+	MaxQueueWaitSeconds int32 `json:"maxQueueWaitSeconds,omitempty"`
+
+	// WaitingRoom answers requests over maxConcurrent with 429, a signed
+	// queue token and an estimate of when to retry, instead of 503. Clients
+	// that come back with the token are admitted before new arrivals.
+	// Requires maxConcurrent.
+	// +optional
+	WaitingRoom *WaitingRoomPolicy `json:"waitingRoom,omitempty"`
+
+	// DeletionPolicy controls the Ingress when the X402Route is deleted:
+	// "restore" (default) points paid paths back at their original backends,
+	// "abandon" leaves the Ingress routed to the gateway, so a replacement
+	// X402Route can take over without a traffic flap.
+	// +optional
+	// +kubebuilder:validation:Enum=restore;abandon
+	// +kubebuilder:default="restore"
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// Approval gates Ingress changes behind a manual approval.
+	// +optional
+	Approval *ApprovalPolicy `json:"approval,omitempty"`
+
+	// Fallback is the backend paid paths are routed to while no gateway replica
+	// is available, e.g. a maintenance page or a Service that returns 403. The
+	// last gateway replica to shut down switches the Ingress to it, and the
+	// controller switches back once the gateway is serving again.
+	// +optional
+	Fallback *FallbackBackend `json:"fallback,omitempty"`
+
+	// SettlementCallbacks lets clients name a URL that the gateway notifies
+	// asynchronously with the settlement result of their paid request.
+	// +optional
+	SettlementCallbacks *SettlementCallbackPolicy `json:"settlementCallbacks,omitempty"`
+
+	// Sidecar sends gated traffic to a backend in the gateway's own pod
+	// instead of the Ingress backends, for gateways deployed as a sidecar.
+	// Requires the operator flag --allow-sidecar-backends.
+	// +optional
+	Sidecar *SidecarBackend `json:"sidecar,omitempty"`
+
+	// ClusterOverrides replaces prices in individual clusters of a fleet. The
+	// operator's --cluster-name selects the overrides that apply. Overridden
+	// prices are not compared across clusters.
+	// +optional
+	// +kubebuilder:validation:MaxItems=64
+	ClusterOverrides []ClusterOverride `json:"clusterOverrides,omitempty"`
+
+	// Mirror copies a sample of the paid requests, once settled, to a second
+	// backend such as a staging deployment. Mirror responses are discarded.
+	// +optional
+	Mirror *MirrorPolicy `json:"mirror,omitempty"`
+
+	// PaidResponseCaching sets the caching headers of responses to paid
+	// requests. By default they are replaced with "Cache-Control: private,
+	// no-store" and "Surrogate-Control: no-store", so a CDN in front of the
+	// Ingress never serves paid content to clients that did not pay.
+	// +optional
+	PaidResponseCaching *ResponseCachingPolicy `json:"paidResponseCaching,omitempty"`
+
+	// Exemptions forwards requests that browsers and crawlers send on their
+	// own without payment, on any path of the Ingress: by default OPTIONS
+	// (CORS preflights) and HEAD requests, /robots.txt, /favicon.ico and
+	// /.well-known/*.
+	// +optional
+	Exemptions *ExemptionPolicy `json:"exemptions,omitempty"`
+
+	// Reputation graylists or denies payers whose recent payments failed:
+	// invalid payments, replays and settlements that failed after
+	// verification raise a payer's score, which decays over time.
+	// +optional
+	Reputation *ReputationPolicy `json:"reputation,omitempty"`
+
+	// Sandbox serves the route on the test network of payment.network, such
+	// as base-sepolia for base, in its test USDC, so it can be exposed
+	// publicly without real money at stake. Rules without a price cost one
+	// unit, prices finer than the token's precision are rounded up, and 402
+	// responses point clients at a faucet.
+	// +optional
+	Sandbox bool `json:"sandbox,omitempty"`
+}
+
+// ApprovalPolicy configures the manual approval gate for Ingress changes.
+type ApprovalPolicy struct {
+	// Required makes the controller publish the intended Ingress changes in
+	// status.pendingPatch and wait for the x402.io/approved annotation before
+	// mutating the Ingress. The annotation accepts "true" or the value of
+	// status.pendingPatchHash, and is removed once the patch is applied.
+	// +optional
+	Required bool `json:"required,omitempty"`
+}
+
+// BackendProtocol sets the protocol of one backend Service.
+type BackendProtocol struct {
+	// Service is the name of a backend Service of the Ingress.
+	Service string `json:"service"`
+
+	// Protocol is "http1", "h2c" (cleartext HTTP/2, e.g. gRPC), "h2"
+	// (HTTP/2 over TLS) or "https" (HTTP/1.1 over TLS).
+	// +kubebuilder:validation:Enum=http1;h2c;h2;https
+	Protocol string `json:"protocol"`
+}
+
+// BackendTLS configures TLS to one backend Service.
+// +kubebuilder:validation:XValidation:rule="!(has(self.caSecretRef) && has(self.insecureSkipVerify) && self.insecureSkipVerify)",message="caSecretRef and insecureSkipVerify are mutually exclusive"
+type BackendTLS struct {
+	// Service is the name of a backend Service of the Ingress.
+	Service string `json:"service"`
+
+	// CASecretRef names a Secret in the Ingress namespace holding the PEM CA
+	// bundle the backend's certificate is verified against. Without it, the
+	// system roots are used.
+	// +optional
+	CASecretRef *CASecretRef `json:"caSecretRef,omitempty"`
+
+	// ServerName is sent as SNI and must match the backend's certificate.
+	// Defaults to <service>.<namespace>.svc.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+
+	// InsecureSkipVerify speaks TLS to the backend without verifying its
+	// certificate.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// CASecretRef names a key of a Secret holding a PEM CA bundle.
+type CASecretRef struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+
+	// Key of the CA bundle in the Secret.
+	// +optional
+	// +kubebuilder:default="ca.crt"
+	Key string `json:"key,omitempty"`
+}
+
+// ClusterOverride replaces a price in one cluster.
+type ClusterOverride struct {
+	// Cluster is the --cluster-name of the operator the override applies to.
+	Cluster string `json:"cluster"`
+
+	// Path is the path of a rule in spec.routes. Empty overrides the default
+	// price, for rules without a price of their own.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Price replaces the price in this cluster.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Price string `json:"price"`
+}
+
+// FallbackBackend identifies a Service in the Ingress namespace.
+type FallbackBackend struct {
+	// ServiceName is the name of the fallback Service.
+	ServiceName string `json:"serviceName"`
+
+	// ServicePort is the port of the fallback Service.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ServicePort int32 `json:"servicePort"`
+}
+
+// SidecarBackend is a backend reachable from the gateway's own pod. Exactly
+// one of Port and SocketPath must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.port) != has(self.socketPath)",message="exactly one of port and socketPath must be set"
+type SidecarBackend struct {
+	// Port is the port of the backend on localhost.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// SocketPath is the absolute path of the backend's Unix domain socket.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	SocketPath string `json:"socketPath,omitempty"`
+}
+
+// MirrorPolicy configures shadow traffic to a second backend.
+type MirrorPolicy struct {
+	// BackendRef is the Service in the Ingress namespace that receives the
+	// copies.
+	BackendRef FallbackBackend `json:"backendRef"`
+
+	// Percent is the percentage of paid requests that are mirrored.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Percent int32 `json:"percent"`
+}
+
+// ResponseCachingPolicy configures the caching headers of paid responses.
+// +kubebuilder:validation:XValidation:rule="!has(self.policy) || self.policy != 'custom' || has(self.cacheControl)",message="the custom policy needs cacheControl"
+type ResponseCachingPolicy struct {
+	// Policy is noStore (default) to forbid caching, backend to keep the
+	// backend's caching headers, or custom to set CacheControl and
+	// SurrogateControl.
+	// +kubebuilder:validation:Enum=noStore;backend;custom
+	// +optional
+	Policy string `json:"policy,omitempty"`
+
+	// CacheControl is the Cache-Control header of paid responses under the
+	// custom policy.
+	// +optional
+	CacheControl string `json:"cacheControl,omitempty"`
+
+	// SurrogateControl is the Surrogate-Control and CDN-Cache-Control header
+	// of paid responses under the custom policy. Empty removes them.
+	// +optional
+	SurrogateControl string `json:"surrogateControl,omitempty"`
+}
+
+// ExemptionPolicy selects the requests forwarded without payment.
+type ExemptionPolicy struct {
+	// Policy is default for the built-in exemptions, custom to exempt only
+	// Methods and Paths, or none to exempt nothing.
+	// +kubebuilder:validation:Enum=default;custom;none
+	// +optional
+	Policy string `json:"policy,omitempty"`
+
+	// Methods exempted on every path under the custom policy.
+	// +optional
+	// +kubebuilder:validation:MaxItems=2
+	// +kubebuilder:validation:items:Enum=OPTIONS;HEAD
+	Methods []string `json:"methods,omitempty"`
+
+	// Paths exempted under the custom policy: exact paths, or prefixes
+	// ending in "/*".
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:items:Pattern=`^/`
+	Paths []string `json:"paths,omitempty"`
+}
+
+// ReputationPolicy sets the payer reputation scores at which a route treats
+// payers more strictly. 0 disables a threshold.
+type ReputationPolicy struct {
+	// GraylistScore makes payers at or above this score settle before their
+	// request reaches the backend, whatever the rule's settle mode.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	GraylistScore int32 `json:"graylistScore,omitempty"`
+
+	// DenyScore answers payers at or above this score with 403 without
+	// verifying their payment.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	DenyScore int32 `json:"denyScore,omitempty"`
+}
+
+// WaitingRoomPolicy configures the queue tokens handed to requests over
+// maxConcurrent.
+type WaitingRoomPolicy struct {
+	// MaxRetryAfterSeconds caps the retry time given to queued clients.
+	// Defaults to 60.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	MaxRetryAfterSeconds int32 `json:"maxRetryAfterSeconds,omitempty"`
+
+	// TokenTTLSeconds is how long after its retry time a queue token is still
+	// honored. Defaults to 300.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	TokenTTLSeconds int32 `json:"tokenTTLSeconds,omitempty"`
+
+	// PriorityWaitSeconds is how long a request holding a queue token waits
+	// for a free slot. New requests are not admitted while one waits.
+	// Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=60
+	PriorityWaitSeconds int32 `json:"priorityWaitSeconds,omitempty"`
+}
+
+// SettlementCallbackPolicy configures settlement result callbacks.
+type SettlementCallbackPolicy struct {
+	// Enabled accepts a callback URL from the payment payload's
+	// extra.callbackUrl or the X-Payment-Callback header. Callback URLs must use
+	// https and resolve to a public address.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// AllowedHosts restricts callback URLs to these hosts; a leading "*."
+	// matches any subdomain. Empty allows any host.
+	// +optional
+	// +kubebuilder:validation:MaxItems=32
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
 }
 
 // IngressReference identifies an Ingress resource to patch.
@@ -21,39 +401,256 @@ type IngressReference struct {
 	// Name is the name of the Ingress resource.
 	Name string `json:"name"`
 
-	// Namespace of the Ingress. Defaults to the X402Route's namespace.
+	// Namespace of the Ingress. Defaults to the X402Route's namespace. Another
+	// namespace must be granted by the Ingress through the
+	// x402.io/allowed-route-namespaces annotation.
 	// +optional
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// PricePlanReference names an X402PricePlan.
+type PricePlanReference struct {
+	// Name of the X402PricePlan.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+}
+
+// VariableSource is a ConfigMap or Secret of route variables.
+// +kubebuilder:validation:XValidation:rule="has(self.configMapRef) != has(self.secretRef)",message="exactly one of configMapRef and secretRef must be set"
+type VariableSource struct {
+	// ConfigMapRef names a ConfigMap whose data keys are variables.
+	// +optional
+	ConfigMapRef *LocalObjectReference `json:"configMapRef,omitempty"`
+
+	// SecretRef names a Secret whose data keys are variables.
+	// +optional
+	SecretRef *LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// LocalObjectReference names an object in the route's namespace.
+type LocalObjectReference struct {
+	// Name of the object.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+}
+
 // PaymentDefaults defines the global payment configuration.
+// +kubebuilder:validation:XValidation:rule="has(self.wallet) != has(self.walletSecretRef)",message="exactly one of wallet and walletSecretRef must be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.facilitatorURL) || !has(self.facilitatorURLs)",message="facilitatorURL and facilitatorURLs are mutually exclusive"
 type PaymentDefaults struct {
-	// Wallet is the wallet address to receive payments.
-	Wallet string `json:"wallet"`
+	// Wallet is the wallet address to receive payments: an EVM address
+	// (0x followed by 40 hex digits) or a base58 Solana address. Exactly one
+	// of wallet and walletSecretRef is set.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(0x[0-9a-fA-F]{40}|[1-9A-HJ-NP-Za-km-z]{32,44}|\$\{[-._a-zA-Z0-9]+\})$`
+	Wallet string `json:"wallet,omitempty"`
+
+	// WalletSecretRef reads the wallet address from a secret store instead,
+	// re-read every few minutes so a changed address is picked up.
+	// +optional
+	WalletSecretRef *SecretValueRef `json:"walletSecretRef,omitempty"`
 
 	// Network is the blockchain network (e.g. "base", "base-sepolia").
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=64
 	Network string `json:"network"`
 
 	// DefaultPrice is the default price for paid routes (e.g. "0.001").
-	// Individual routes can override this.
+	// Individual routes can override this. Prices are token amounts, or fiat
+	// amounts such as "$0.01 USD".
 	// +optional
+	// +kubebuilder:validation:Pattern=`^([$€£]?\s*[0-9]+(\.[0-9]+)?\s+[A-Z]{3}|[0-9]+(\.[0-9]+)?|\$\{[-._a-zA-Z0-9]+\})$`
 	DefaultPrice string `json:"defaultPrice,omitempty"`
 
+	// MinimumCharge is the smallest amount a paid request is charged, in
+	// tokens (e.g. "0.001"). Prices below it, such as those computed from
+	// fiat rates, price modifiers or metered usage, are raised to it.
+	// Defaults to the operator's --minimum-charge.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	MinimumCharge string `json:"minimumCharge,omitempty"`
+
+	// PriceIncrement rounds charged amounts up to a multiple of it, in
+	// tokens (e.g. "0.0001"). Defaults to the operator's --price-increment.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	PriceIncrement string `json:"priceIncrement,omitempty"`
+
+	// Asset is the token contract address to accept. Defaults to USDC on the
+	// network. Metadata of assets outside the built-in registry is read from
+	// the chain through the operator's configured RPC endpoint.
+	// +optional
+	// +kubebuilder:validation:MaxLength=128
+	Asset string `json:"asset,omitempty"`
+
 	// FacilitatorURL is the URL of the x402 facilitator service.
 	// Defaults to https://x402.org/facilitator.
 	// +optional
-	// +kubebuilder:validation:Pattern=`^https?://`
+	// +kubebuilder:validation:Pattern=`^(https?://|\$\{)`
 	// +kubebuilder:validation:MaxLength=2048
 	FacilitatorURL string `json:"facilitatorURL,omitempty"`
+
+	// FacilitatorURLs lists facilitators to fail over between, in order of
+	// preference, instead of a single facilitatorURL. When one is
+	// unreachable, times out or fails, the payment is verified by the next;
+	// it is always settled by the facilitator that verified it.
+	// +optional
+	// +kubebuilder:validation:MinItems=2
+	// +kubebuilder:validation:MaxItems=4
+	// +kubebuilder:validation:items:Pattern=`^https?://`
+	// +kubebuilder:validation:items:MaxLength=2048
+	FacilitatorURLs []string `json:"facilitatorURLs,omitempty"`
+
+	// FacilitatorSelection is the order facilitatorURLs are tried in:
+	// "ordered" (default) always starts with the first, "healthy" tries
+	// facilitators that failed in the last 30 seconds only after the others.
+	// +optional
+	// +kubebuilder:validation:Enum=ordered;healthy
+	FacilitatorSelection string `json:"facilitatorSelection,omitempty"`
+
+	// FacilitatorType selects how the facilitator's answers are read:
+	// "coinbase" (CDP), "x402.org" or "custom". Defaults to the vendor the
+	// facilitator URL points at, and "custom" for any other host.
+	// +optional
+	// +kubebuilder:validation:Enum=coinbase;x402.org;custom
+	FacilitatorType string `json:"facilitatorType,omitempty"`
+
+	// FacilitatorTimeouts bounds the facilitator calls of a paid request.
+	// +optional
+	FacilitatorTimeouts *FacilitatorTimeouts `json:"facilitatorTimeouts,omitempty"`
+
+	// FacilitatorAuth sends a credential, such as a CDP API key, with each
+	// /verify and /settle call.
+	// +optional
+	FacilitatorAuth *FacilitatorAuth `json:"facilitatorAuth,omitempty"`
+
+	// Settle is when paid requests are settled, unless a rule says
+	// otherwise: "sync" (default) settles before the request is forwarded,
+	// "async" forwards it while settling in the background, and
+	// "afterResponse" settles once the backend has answered successfully.
+	// +optional
+	// +kubebuilder:validation:Enum=sync;async;afterResponse
+	Settle string `json:"settle,omitempty"`
+
+	// SettleAbandoned settles paid requests whose client disconnected after
+	// the payment was verified. By default they are not settled, so a client
+	// is not charged for a response it never received. Async settlements
+	// are unaffected.
+	// +optional
+	SettleAbandoned bool `json:"settleAbandoned,omitempty"`
+
+	// BindResource adds a hash of the resource and amount to each payment
+	// requirement (extra.resourceHash) and rejects payments whose accepted
+	// requirements do not echo the hash of the requested resource, so an
+	// offer for one path cannot be used on another.
+	// +optional
+	BindResource bool `json:"bindResource,omitempty"`
+
+	// BindClient binds each 402 offer to the TLS connection it was requested
+	// over: "certificate" to the client certificate, "session" to the TLS
+	// session. Offers carry a random extra.challenge and the extra.nonce the
+	// payment authorization must be signed with, so a payment captured in
+	// transit cannot be replayed by another client. Requires the gateway to
+	// serve TLS or, for certificate, a trusted client certificate header.
+	// +optional
+	// +kubebuilder:validation:Enum=certificate;session
+	BindClient string `json:"bindClient,omitempty"`
+}
+
+// FacilitatorAuth is a credential sent to the facilitator in a request header.
+type FacilitatorAuth struct {
+	// Header carrying the credential. Defaults to "Authorization".
+	// +optional
+	// +kubebuilder:validation:MaxLength=128
+	Header string `json:"header,omitempty"`
+
+	// Prefix is prepended to the value, e.g. "Bearer ".
+	// +optional
+	// +kubebuilder:validation:MaxLength=64
+	Prefix string `json:"prefix,omitempty"`
+
+	// ValueFrom is where the credential is read from. It is read by the
+	// gateway when it calls the facilitator and never stored in the route.
+	ValueFrom SecretValueRef `json:"valueFrom"`
+}
+
+// SecretValueRef is where a secret value is read from.
+type SecretValueRef struct {
+	// Vault reads the value from HashiCorp Vault, with the operator's Vault
+	// credentials.
+	Vault *VaultSecretRef `json:"vault"`
+}
+
+// VaultSecretRef names a value of a Vault secret.
+type VaultSecretRef struct {
+	// Path of the secret, including its mount, e.g. "secret/data/x402" for
+	// a KV version 2 engine mounted at "secret".
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=512
+	Path string `json:"path"`
+
+	// Key of the value in the secret.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	Key string `json:"key"`
+}
+
+// FacilitatorTimeouts bounds the /verify and /settle calls to the facilitator.
+// A call that runs out of time is a facilitator failure, handled as
+// onFacilitatorError says; a payment over its latency budget is not.
+type FacilitatorTimeouts struct {
+	// VerifySeconds bounds the /verify call. Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=30
+	VerifySeconds int32 `json:"verifySeconds,omitempty"`
+
+	// SettleSeconds bounds the /settle call. Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=30
+	SettleSeconds int32 `json:"settleSeconds,omitempty"`
+
+	// BudgetMilliseconds bounds the /verify call of a request. A payment over
+	// budget has its call canceled and is answered 402 with a Retry-After
+	// hint, whatever onFacilitatorError says, instead of holding the client.
+	// Settlement is not budgeted, and payments are never refunded.
+	// +optional
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=60000
+	BudgetMilliseconds int32 `json:"budgetMilliseconds,omitempty"`
 }
 
 // RouteRule defines a single route rule with pricing and optional conditions.
+// +kubebuilder:validation:XValidation:rule="!has(self.offers) || size(self.offers) == 0 || (!has(self.graphql) && !has(self.metering))",message="offers cannot be combined with graphql pricing or metering"
+// +kubebuilder:validation:XValidation:rule="!has(self.offers) || size(self.offers) == 0 || !has(self.priceModifiers) || self.priceModifiers.all(m, !has(m.price))",message="price modifiers of a rule with offers must use multiplier"
+// +kubebuilder:validation:XValidation:rule="!has(self.async) || !has(self.metering)",message="async cannot be combined with metering"
+// +kubebuilder:validation:XValidation:rule="!has(self.metering) || !has(self.settle) || self.settle == 'afterResponse'",message="metered rules settle after the response"
+// +kubebuilder:validation:XValidation:rule="!has(self.async) || !has(self.settle) || self.settle != 'afterResponse'",message="async rules cannot settle after the response"
+// +kubebuilder:validation:XValidation:rule="!has(self.experiments) || size(self.experiments) == 0 || ((!has(self.offers) || size(self.offers) == 0) && !has(self.graphql))",message="experiments cannot be combined with offers or graphql pricing"
+// +kubebuilder:validation:XValidation:rule="!has(self.experiments) || size(self.experiments) == 0 || !has(self.priceModifiers) || self.priceModifiers.all(m, !has(m.price))",message="price modifiers of a rule with experiments must use multiplier"
+// +kubebuilder:validation:XValidation:rule="!has(self.experiments) || self.experiments.map(e, e.percent).sum() <= 100",message="experiments can take at most 100 percent of the clients"
+// +kubebuilder:validation:XValidation:rule="!has(self.async) || !has(self.conditionalRequests)",message="async cannot be combined with conditionalRequests"
+// +kubebuilder:validation:XValidation:rule="!has(self.conditionalRequests) || !has(self.conditionalRequests.notModified) || self.conditionalRequests.notModified != 'reduced' || has(self.metering)",message="reduced charges of 304 responses need metering"
 type RouteRule struct {
 	// Path is the URL path pattern (supports * for single segment, ** for any depth).
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:validation:MaxLength=1024
 	Path string `json:"path"`
 
+	// Host limits the rule to requests for a virtual host of a multi-host
+	// Ingress, such as api.example.com or *.example.com. Rules with a host
+	// take precedence over rules without one, which serve every host.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(\*\.)?[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +kubebuilder:validation:MaxLength=253
+	Host string `json:"host,omitempty"`
+
 	// Price overrides the default price for this specific path.
 	// +optional
+	// +kubebuilder:validation:Pattern=`^([$€£]?\s*[0-9]+(\.[0-9]+)?\s+[A-Z]{3}|[0-9]+(\.[0-9]+)?|\$\{[-._a-zA-Z0-9]+\})$`
 	Price string `json:"price,omitempty"`
 
 	// Free marks this path as free (no payment required).
@@ -68,7 +665,315 @@ type RouteRule struct {
 
 	// Conditions defines when payment is required (only used when mode is "conditional").
 	// +optional
+	// +kubebuilder:validation:MaxItems=16
 	Conditions []PaymentCondition `json:"conditions,omitempty"`
+
+	// Disabled pauses this rule without removing it. Disabled rules are left
+	// out of the gateway and the Ingress patch as if they were not listed.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Offers lists alternative prices for this path, e.g. standard and
+	// priority processing. Each offer is advertised as its own accepts entry
+	// and replaces Price. The offer a payment matches is forwarded to the
+	// backend in the X-402-Offer header, along with the offer's Headers.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
+	Offers []PriceOffer `json:"offers,omitempty"`
+
+	// PriceModifiers adjust the price by query parameter, e.g. a higher price
+	// for ?resolution=4k. The first modifier whose parameter matches applies;
+	// the price is computed per request and enforced at verification.
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	PriceModifiers []PriceModifier `json:"priceModifiers,omitempty"`
+
+	// Experiments serve alternative prices to shares of the rule's clients.
+	// Clients are bucketed deterministically by address, so a client sees
+	// the same price on every request; clients outside every variant pay
+	// Price as the "control" variant. Settlements are tagged with the
+	// variant, and x402_experiment_* metrics report conversion and revenue
+	// per variant.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
+	Experiments []PriceExperiment `json:"experiments,omitempty"`
+
+	// GraphQL prices a GraphQL endpoint per operation. The operation name is
+	// read from a bounded prefix of the request body (or the query string for
+	// GET), and operations not listed cost Price.
+	// +optional
+	GraphQL *GraphQLPricing `json:"graphql,omitempty"`
+
+	// Metering charges by the backend response instead of a fixed price.
+	// Price becomes the maximum the client authorizes; the gateway verifies
+	// the payment up front and settles the metered amount once the backend
+	// has answered. Requires a facilitator that supports the "upto" scheme.
+	// +optional
+	Metering *MeteringPolicy `json:"metering,omitempty"`
+
+	// Async answers paid requests with 202 and a job URL and proxies them in
+	// the background, for backends that take longer than a client can hold a
+	// request open. The result is fetched with the original payment header.
+	// +optional
+	Async *AsyncPolicy `json:"async,omitempty"`
+
+	// Idempotency replays the response of a paid POST to retries carrying the
+	// same Idempotency-Key header, so a client retrying after a network
+	// failure is not charged twice.
+	// +optional
+	Idempotency *IdempotencyPolicy `json:"idempotency,omitempty"`
+
+	// Settle overrides payment.settle for this rule. Metered rules always
+	// settle after the response, and async rules cannot.
+	// +optional
+	// +kubebuilder:validation:Enum=sync;async;afterResponse
+	Settle string `json:"settle,omitempty"`
+
+	// ConditionalRequests sets the charge of conditional requests
+	// (If-None-Match or If-Modified-Since) the backend answers 304 Not
+	// Modified, so clients polling for changes do not pay full price when
+	// nothing changed. Conditional requests then settle after the response.
+	// +optional
+	ConditionalRequests *ConditionalRequestPolicy `json:"conditionalRequests,omitempty"`
+
+	// RequestValidation answers requests missing a required header, with a
+	// body of an unexpected content type or not matching a JSON Schema 400
+	// before payment is demanded, so clients do not pay for requests the
+	// backend would reject anyway.
+	// +optional
+	RequestValidation *RequestValidationPolicy `json:"requestValidation,omitempty"`
+
+	// ExternalBackend sends the rule's traffic to an upstream outside the
+	// cluster, such as a SaaS API, instead of the Ingress backends. Requires
+	// the operator flag --allow-external-backends.
+	// +optional
+	ExternalBackend *ExternalBackend `json:"externalBackend,omitempty"`
+}
+
+// ExternalBackend is an upstream outside the cluster, reached over HTTPS.
+type ExternalBackend struct {
+	// URL of the upstream, e.g. "https://api.example.com/v1". The request
+	// path is appended to its path. It must use https and name a public
+	// host; the gateway also refuses to dial private addresses it resolves to.
+	// +kubebuilder:validation:Pattern=`^https://`
+	// +kubebuilder:validation:MaxLength=2048
+	URL string `json:"url"`
+
+	// ServerName is sent as SNI and must match the upstream's certificate,
+	// which is verified against the system roots. Defaults to the URL host.
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	ServerName string `json:"serverName,omitempty"`
+
+	// Auth adds the upstream's credential, e.g. an API key, to forwarded
+	// requests, replacing any value the client sent. Like
+	// payment.facilitatorAuth, it is read by the gateway from Vault.
+	// +optional
+	Auth *FacilitatorAuth `json:"auth,omitempty"`
+}
+
+// ConditionalRequestPolicy prices conditional requests answered 304 Not
+// Modified.
+// +kubebuilder:validation:XValidation:rule="(has(self.notModified) && self.notModified == 'reduced') == has(self.reducedPrice)",message="reducedPrice is set exactly when notModified is reduced"
+type ConditionalRequestPolicy struct {
+	// NotModified is what a 304 is charged: "full" (default) the rule price,
+	// "free" nothing, and "reduced" reducedPrice. Reduced charges need a
+	// metered rule, as only "upto" payments can settle less than the price.
+	// +optional
+	// +kubebuilder:validation:Enum=full;free;reduced
+	// +kubebuilder:default="full"
+	NotModified string `json:"notModified,omitempty"`
+
+	// ReducedPrice is the charge of a 304 when notModified is "reduced", in
+	// tokens (e.g. "0.0001"), capped at the rule price.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	ReducedPrice string `json:"reducedPrice,omitempty"`
+}
+
+// RequestValidationPolicy lists what a request must look like to be paid for.
+// +kubebuilder:validation:XValidation:rule="!has(self.jsonSchema) || !has(self.jsonSchemaRef)",message="jsonSchema and jsonSchemaRef are mutually exclusive"
+type RequestValidationPolicy struct {
+	// RequiredHeaders must be present and non-empty, e.g. "X-Tenant-ID".
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:items:Pattern=`^[-!#$%&'*+.^_|~0-9A-Za-z]+$`
+	RequiredHeaders []string `json:"requiredHeaders,omitempty"`
+
+	// ContentTypes are the media types a request body may have, e.g.
+	// "application/json" or "image/*". Requests with a body of another type
+	// or without a Content-Type are rejected. Empty allows any.
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:items:Pattern=`^[-.+a-z0-9]+/([-.+a-z0-9]+|\*)$`
+	ContentTypes []string `json:"contentTypes,omitempty"`
+
+	// JSONSchema is a JSON Schema the request body must match. Requests
+	// without a body are not checked. The validation keywords are supported;
+	// $ref, conditionals and format assertions are not.
+	// +optional
+	// +kubebuilder:validation:MaxLength=65536
+	JSONSchema string `json:"jsonSchema,omitempty"`
+
+	// JSONSchemaRef reads the JSON Schema from a key of a ConfigMap in the
+	// route namespace instead. Changes to the ConfigMap are picked up within
+	// a minute.
+	// +optional
+	JSONSchemaRef *ConfigMapKeyReference `json:"jsonSchemaRef,omitempty"`
+
+	// MaxBodyBytes bounds the body read to check it against the schema;
+	// larger bodies are rejected. Defaults to 1048576 (1 MiB).
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16777216
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+}
+
+// ConfigMapKeyReference names a key of a ConfigMap in the route namespace.
+type ConfigMapKeyReference struct {
+	// Name of the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+
+	// Key in the ConfigMap's data.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	Key string `json:"key"`
+}
+
+// IdempotencyPolicy configures the replay window for Idempotency-Key retries.
+type IdempotencyPolicy struct {
+	// WindowSeconds is how long a response is replayed. Defaults to 600.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	WindowSeconds int32 `json:"windowSeconds,omitempty"`
+
+	// MaxResponseBytes bounds a stored response; larger responses are not
+	// replayed. Defaults to 1 MiB.
+	// +optional
+	// +kubebuilder:validation:Minimum=1024
+	// +kubebuilder:validation:Maximum=10485760
+	MaxResponseBytes int64 `json:"maxResponseBytes,omitempty"`
+}
+
+// AsyncPolicy configures background jobs for long-running paid requests.
+type AsyncPolicy struct {
+	// TimeoutSeconds bounds how long the backend may take. Defaults to 3600.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// ResultTTLSeconds is how long a finished result can be fetched.
+	// Defaults to 3600.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	ResultTTLSeconds int32 `json:"resultTTLSeconds,omitempty"`
+
+	// MaxResultBytes bounds a stored result; larger results fail the job with
+	// 502. Defaults to 10 MiB.
+	// +optional
+	// +kubebuilder:validation:Minimum=1024
+	// +kubebuilder:validation:Maximum=104857600
+	MaxResultBytes int64 `json:"maxResultBytes,omitempty"`
+}
+
+// MeteringPolicy computes the charge of a request from the backend response.
+type MeteringPolicy struct {
+	// UnitHeader is the backend response header carrying the units consumed,
+	// e.g. X-Token-Count.
+	UnitHeader string `json:"unitHeader"`
+
+	// UnitPrice is the price of one unit (e.g. "0.00001"). The charge is
+	// capped at the rule price.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	UnitPrice string `json:"unitPrice"`
+
+	// MaxResponseBytes bounds the backend response buffered until settlement.
+	// Larger responses fail with 502 and are not charged. Defaults to 10 MiB.
+	// +optional
+	// +kubebuilder:validation:Minimum=1024
+	// +kubebuilder:validation:Maximum=104857600
+	MaxResponseBytes int64 `json:"maxResponseBytes,omitempty"`
+}
+
+// GraphQLPricing sets per-operation prices for a GraphQL endpoint.
+type GraphQLPricing struct {
+	// Operations maps operation names to prices.
+	// +kubebuilder:validation:MaxProperties=256
+	Operations map[string]string `json:"operations"`
+
+	// MaxBodyBytes bounds how much of the request body is read to find the
+	// operation; larger requests are rejected. Defaults to 65536.
+	// +optional
+	// +kubebuilder:validation:Minimum=1024
+	// +kubebuilder:validation:Maximum=1048576
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+}
+
+// PriceModifier adjusts the price of a rule when a query parameter matches.
+// Exactly one of Multiplier and Price must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.multiplier) != has(self.price)",message="exactly one of multiplier and price must be set"
+type PriceModifier struct {
+	// Param is the query parameter to inspect.
+	Param string `json:"param"`
+
+	// Pattern is a regex pattern to match against the parameter value.
+	Pattern string `json:"pattern"`
+
+	// Multiplier scales every advertised price, e.g. "2.5".
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Multiplier string `json:"multiplier,omitempty"`
+
+	// Price replaces the rule price. It cannot be combined with offers.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([$€£]?\s*[0-9]+(\.[0-9]+)?\s+[A-Z]{3}|[0-9]+(\.[0-9]+)?)$`
+	Price string `json:"price,omitempty"`
+}
+
+// PriceOffer is one of several prices advertised for a path.
+type PriceOffer struct {
+	// Name identifies the offer in the accepts entry's extra.offer and the
+	// X-402-Offer header (e.g. "standard", "priority").
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// Price of the offer (e.g. "0.005").
+	// +kubebuilder:validation:Pattern=`^([$€£]?\s*[0-9]+(\.[0-9]+)?\s+[A-Z]{3}|[0-9]+(\.[0-9]+)?)$`
+	Price string `json:"price"`
+
+	// Headers are set on the request forwarded to the backend when a payment
+	// matches this offer. Client-supplied values are always removed.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// PriceExperiment is a price variant served to a share of a rule's clients.
+// +kubebuilder:validation:XValidation:rule="self.name != 'control'",message="control is reserved for clients outside every variant"
+type PriceExperiment struct {
+	// Name identifies the variant in metrics and settlement records.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// Price served to the variant's clients (e.g. "0.002").
+	// +kubebuilder:validation:Pattern=`^([$€£]?\s*[0-9]+(\.[0-9]+)?\s+[A-Z]{3}|[0-9]+(\.[0-9]+)?)$`
+	Price string `json:"price"`
+
+	// Percent of the rule's clients bucketed into the variant.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Percent int32 `json:"percent"`
 }
 
 // PaymentCondition defines a condition for conditional payment evaluation.
@@ -98,16 +1003,120 @@ type X402RouteStatus struct {
 	// +optional
 	ActiveRoutes int `json:"activeRoutes,omitempty"`
 
+	// PendingPatch is a unified diff of the Ingress changes awaiting confirmation.
+	// +optional
+	PendingPatch string `json:"pendingPatch,omitempty"`
+
+	// PendingPatchHash identifies the pending patch. Setting the x402.io/approved
+	// annotation to this value approves exactly this diff.
+	// +optional
+	PendingPatchHash string `json:"pendingPatchHash,omitempty"`
+
+	// ObservedGeneration is the generation of the spec served by the gateway.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastReconcileTime is when the controller last reconciled the route.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// LastError is the error of the last reconcile, empty once a reconcile
+	// succeeds.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// CompilerVersion is the version of the operator compile rules that last
+	// compiled the route.
+	// +optional
+	CompilerVersion int32 `json:"compilerVersion,omitempty"`
+
+	// SpecHash identifies the spec the route was last compiled from.
+	// +optional
+	SpecHash string `json:"specHash,omitempty"`
+
+	// CompiledHash identifies the compiled route. A new compiler version that
+	// changes it for an unchanged spec is reported as a behavior change.
+	// +optional
+	CompiledHash string `json:"compiledHash,omitempty"`
+
+	// Rules reports the rollout state of each route rule, in spec order.
+	// +optional
+	Rules []RuleStatus `json:"rules,omitempty"`
+
+	// Cleanup reports the progress of the cleanup run while the route is
+	// being deleted.
+	// +optional
+	Cleanup *CleanupStatus `json:"cleanup,omitempty"`
+
 	// Conditions represent the latest available observations of the X402Route's state.
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// RuleStatus reports the rollout state of a single route rule.
+type RuleStatus struct {
+	// Path is the rule's path pattern.
+	Path string `json:"path"`
+
+	// Host is the rule's virtual host, if any.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// State is "Live" when the gateway serves the rule, "Disabled" when paused.
+	State string `json:"state"`
+
+	// Generation is the X402Route generation at which the rule entered its
+	// current state.
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+}
+
+// CleanupStatus records which cleanup steps of a deleted route are done, so
+// failed steps are retried on their own.
+type CleanupStatus struct {
+	// IngressRestored is true once the Ingress points at its original
+	// backends again, or was left in place by deletionPolicy abandon.
+	// +optional
+	IngressRestored bool `json:"ingressRestored,omitempty"`
+
+	// IngressRestoreSkipped is true when the x402.io/force-cleanup annotation
+	// skipped the Ingress restoration.
+	// +optional
+	IngressRestoreSkipped bool `json:"ingressRestoreSkipped,omitempty"`
+
+	// StoreRemoved is true once the gateway stopped serving the route.
+	// +optional
+	StoreRemoved bool `json:"storeRemoved,omitempty"`
+
+	// BypassRemoved is true once the bypass Ingress was deleted.
+	// +optional
+	BypassRemoved bool `json:"bypassRemoved,omitempty"`
+
+	// ServiceCleaned is true once the gateway ExternalName Service was deleted
+	// or found to be in use by other routes.
+	// +optional
+	ServiceCleaned bool `json:"serviceCleaned,omitempty"`
+
+	// Failures counts the cleanup attempts that failed.
+	// +optional
+	Failures int `json:"failures,omitempty"`
+
+	// LastError is the error of the last failed cleanup attempt.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// +genclient
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName={x4r,x402r},categories=all
 // +kubebuilder:printcolumn:name="Ingress Patched",type="boolean",JSONPath=".status.ingressPatched"
 // +kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
 // +kubebuilder:printcolumn:name="Active Routes",type="integer",JSONPath=".status.activeRoutes"
+// +kubebuilder:printcolumn:name="Network",type="string",JSONPath=".spec.payment.network"
+// +kubebuilder:printcolumn:name="Default Price",type="string",JSONPath=".spec.payment.defaultPrice"
+// +kubebuilder:printcolumn:name="Wallet",type="string",JSONPath=".spec.payment.wallet",priority=1
+// +kubebuilder:printcolumn:name="Facilitator",type="string",JSONPath=".spec.payment.facilitatorURL",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // X402Route is the Schema for the x402routes API.
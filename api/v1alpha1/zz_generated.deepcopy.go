@@ -9,6 +9,274 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalPolicy) DeepCopyInto(out *ApprovalPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalPolicy.
+func (in *ApprovalPolicy) DeepCopy() *ApprovalPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AsyncPolicy) DeepCopyInto(out *AsyncPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AsyncPolicy.
+func (in *AsyncPolicy) DeepCopy() *AsyncPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AsyncPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackendProtocol) DeepCopyInto(out *BackendProtocol) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackendProtocol.
+func (in *BackendProtocol) DeepCopy() *BackendProtocol {
+	if in == nil {
+		return nil
+	}
+	out := new(BackendProtocol)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackendTLS) DeepCopyInto(out *BackendTLS) {
+	*out = *in
+	if in.CASecretRef != nil {
+		in, out := &in.CASecretRef, &out.CASecretRef
+		*out = new(CASecretRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackendTLS.
+func (in *BackendTLS) DeepCopy() *BackendTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(BackendTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CASecretRef) DeepCopyInto(out *CASecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CASecretRef.
+func (in *CASecretRef) DeepCopy() *CASecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(CASecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CleanupStatus) DeepCopyInto(out *CleanupStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CleanupStatus.
+func (in *CleanupStatus) DeepCopy() *CleanupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CleanupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterOverride) DeepCopyInto(out *ClusterOverride) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterOverride.
+func (in *ClusterOverride) DeepCopy() *ClusterOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionalRequestPolicy) DeepCopyInto(out *ConditionalRequestPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionalRequestPolicy.
+func (in *ConditionalRequestPolicy) DeepCopy() *ConditionalRequestPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionalRequestPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExemptionPolicy) DeepCopyInto(out *ExemptionPolicy) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExemptionPolicy.
+func (in *ExemptionPolicy) DeepCopy() *ExemptionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ExemptionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalBackend) DeepCopyInto(out *ExternalBackend) {
+	*out = *in
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(FacilitatorAuth)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalBackend.
+func (in *ExternalBackend) DeepCopy() *ExternalBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FacilitatorAuth) DeepCopyInto(out *FacilitatorAuth) {
+	*out = *in
+	in.ValueFrom.DeepCopyInto(&out.ValueFrom)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FacilitatorAuth.
+func (in *FacilitatorAuth) DeepCopy() *FacilitatorAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(FacilitatorAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FacilitatorTimeouts) DeepCopyInto(out *FacilitatorTimeouts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FacilitatorTimeouts.
+func (in *FacilitatorTimeouts) DeepCopy() *FacilitatorTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(FacilitatorTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FallbackBackend) DeepCopyInto(out *FallbackBackend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FallbackBackend.
+func (in *FallbackBackend) DeepCopy() *FallbackBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(FallbackBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GraphQLPricing) DeepCopyInto(out *GraphQLPricing) {
+	*out = *in
+	if in.Operations != nil {
+		in, out := &in.Operations, &out.Operations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GraphQLPricing.
+func (in *GraphQLPricing) DeepCopy() *GraphQLPricing {
+	if in == nil {
+		return nil
+	}
+	out := new(GraphQLPricing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdempotencyPolicy) DeepCopyInto(out *IdempotencyPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdempotencyPolicy.
+func (in *IdempotencyPolicy) DeepCopy() *IdempotencyPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(IdempotencyPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IngressReference) DeepCopyInto(out *IngressReference) {
 	*out = *in
@@ -24,6 +292,52 @@ func (in *IngressReference) DeepCopy() *IngressReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalObjectReference) DeepCopyInto(out *LocalObjectReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalObjectReference.
+func (in *LocalObjectReference) DeepCopy() *LocalObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeteringPolicy) DeepCopyInto(out *MeteringPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeteringPolicy.
+func (in *MeteringPolicy) DeepCopy() *MeteringPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MeteringPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MirrorPolicy) DeepCopyInto(out *MirrorPolicy) {
+	*out = *in
+	out.BackendRef = in.BackendRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MirrorPolicy.
+func (in *MirrorPolicy) DeepCopy() *MirrorPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MirrorPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PaymentCondition) DeepCopyInto(out *PaymentCondition) {
 	*out = *in
@@ -42,6 +356,26 @@ func (in *PaymentCondition) DeepCopy() *PaymentCondition {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PaymentDefaults) DeepCopyInto(out *PaymentDefaults) {
 	*out = *in
+	if in.WalletSecretRef != nil {
+		in, out := &in.WalletSecretRef, &out.WalletSecretRef
+		*out = new(SecretValueRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FacilitatorURLs != nil {
+		in, out := &in.FacilitatorURLs, &out.FacilitatorURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FacilitatorTimeouts != nil {
+		in, out := &in.FacilitatorTimeouts, &out.FacilitatorTimeouts
+		*out = new(FacilitatorTimeouts)
+		**out = **in
+	}
+	if in.FacilitatorAuth != nil {
+		in, out := &in.FacilitatorAuth, &out.FacilitatorAuth
+		*out = new(FacilitatorAuth)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PaymentDefaults.
@@ -54,6 +388,133 @@ func (in *PaymentDefaults) DeepCopy() *PaymentDefaults {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriceExperiment) DeepCopyInto(out *PriceExperiment) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriceExperiment.
+func (in *PriceExperiment) DeepCopy() *PriceExperiment {
+	if in == nil {
+		return nil
+	}
+	out := new(PriceExperiment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriceModifier) DeepCopyInto(out *PriceModifier) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriceModifier.
+func (in *PriceModifier) DeepCopy() *PriceModifier {
+	if in == nil {
+		return nil
+	}
+	out := new(PriceModifier)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriceOffer) DeepCopyInto(out *PriceOffer) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriceOffer.
+func (in *PriceOffer) DeepCopy() *PriceOffer {
+	if in == nil {
+		return nil
+	}
+	out := new(PriceOffer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PricePlanReference) DeepCopyInto(out *PricePlanReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PricePlanReference.
+func (in *PricePlanReference) DeepCopy() *PricePlanReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PricePlanReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReputationPolicy) DeepCopyInto(out *ReputationPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReputationPolicy.
+func (in *ReputationPolicy) DeepCopy() *ReputationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ReputationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestValidationPolicy) DeepCopyInto(out *RequestValidationPolicy) {
+	*out = *in
+	if in.RequiredHeaders != nil {
+		in, out := &in.RequiredHeaders, &out.RequiredHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ContentTypes != nil {
+		in, out := &in.ContentTypes, &out.ContentTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.JSONSchemaRef != nil {
+		in, out := &in.JSONSchemaRef, &out.JSONSchemaRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestValidationPolicy.
+func (in *RequestValidationPolicy) DeepCopy() *RequestValidationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestValidationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResponseCachingPolicy) DeepCopyInto(out *ResponseCachingPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResponseCachingPolicy.
+func (in *ResponseCachingPolicy) DeepCopy() *ResponseCachingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ResponseCachingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RouteRule) DeepCopyInto(out *RouteRule) {
 	*out = *in
@@ -62,6 +523,58 @@ func (in *RouteRule) DeepCopyInto(out *RouteRule) {
 		*out = make([]PaymentCondition, len(*in))
 		copy(*out, *in)
 	}
+	if in.Offers != nil {
+		in, out := &in.Offers, &out.Offers
+		*out = make([]PriceOffer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PriceModifiers != nil {
+		in, out := &in.PriceModifiers, &out.PriceModifiers
+		*out = make([]PriceModifier, len(*in))
+		copy(*out, *in)
+	}
+	if in.Experiments != nil {
+		in, out := &in.Experiments, &out.Experiments
+		*out = make([]PriceExperiment, len(*in))
+		copy(*out, *in)
+	}
+	if in.GraphQL != nil {
+		in, out := &in.GraphQL, &out.GraphQL
+		*out = new(GraphQLPricing)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Metering != nil {
+		in, out := &in.Metering, &out.Metering
+		*out = new(MeteringPolicy)
+		**out = **in
+	}
+	if in.Async != nil {
+		in, out := &in.Async, &out.Async
+		*out = new(AsyncPolicy)
+		**out = **in
+	}
+	if in.Idempotency != nil {
+		in, out := &in.Idempotency, &out.Idempotency
+		*out = new(IdempotencyPolicy)
+		**out = **in
+	}
+	if in.ConditionalRequests != nil {
+		in, out := &in.ConditionalRequests, &out.ConditionalRequests
+		*out = new(ConditionalRequestPolicy)
+		**out = **in
+	}
+	if in.RequestValidation != nil {
+		in, out := &in.RequestValidation, &out.RequestValidation
+		*out = new(RequestValidationPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalBackend != nil {
+		in, out := &in.ExternalBackend, &out.ExternalBackend
+		*out = new(ExternalBackend)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteRule.
@@ -74,6 +587,211 @@ func (in *RouteRule) DeepCopy() *RouteRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleStatus) DeepCopyInto(out *RuleStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleStatus.
+func (in *RuleStatus) DeepCopy() *RuleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretValueRef) DeepCopyInto(out *SecretValueRef) {
+	*out = *in
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultSecretRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretValueRef.
+func (in *SecretValueRef) DeepCopy() *SecretValueRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretValueRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SettlementCallbackPolicy) DeepCopyInto(out *SettlementCallbackPolicy) {
+	*out = *in
+	if in.AllowedHosts != nil {
+		in, out := &in.AllowedHosts, &out.AllowedHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SettlementCallbackPolicy.
+func (in *SettlementCallbackPolicy) DeepCopy() *SettlementCallbackPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SettlementCallbackPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarBackend) DeepCopyInto(out *SidecarBackend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarBackend.
+func (in *SidecarBackend) DeepCopy() *SidecarBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VariableSource) DeepCopyInto(out *VariableSource) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(LocalObjectReference)
+		**out = **in
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VariableSource.
+func (in *VariableSource) DeepCopy() *VariableSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VariableSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultSecretRef) DeepCopyInto(out *VaultSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultSecretRef.
+func (in *VaultSecretRef) DeepCopy() *VaultSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WaitingRoomPolicy) DeepCopyInto(out *WaitingRoomPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WaitingRoomPolicy.
+func (in *WaitingRoomPolicy) DeepCopy() *WaitingRoomPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(WaitingRoomPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *X402PricePlan) DeepCopyInto(out *X402PricePlan) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new X402PricePlan.
+func (in *X402PricePlan) DeepCopy() *X402PricePlan {
+	if in == nil {
+		return nil
+	}
+	out := new(X402PricePlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *X402PricePlan) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *X402PricePlanList) DeepCopyInto(out *X402PricePlanList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]X402PricePlan, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new X402PricePlanList.
+func (in *X402PricePlanList) DeepCopy() *X402PricePlanList {
+	if in == nil {
+		return nil
+	}
+	out := new(X402PricePlanList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *X402PricePlanList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *X402PricePlanSpec) DeepCopyInto(out *X402PricePlanSpec) {
+	*out = *in
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]RouteRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new X402PricePlanSpec.
+func (in *X402PricePlanSpec) DeepCopy() *X402PricePlanSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(X402PricePlanSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *X402Route) DeepCopyInto(out *X402Route) {
 	*out = *in
@@ -137,7 +855,7 @@ func (in *X402RouteList) DeepCopyObject() runtime.Object {
 func (in *X402RouteSpec) DeepCopyInto(out *X402RouteSpec) {
 	*out = *in
 	out.IngressRef = in.IngressRef
-	out.Payment = in.Payment
+	in.Payment.DeepCopyInto(&out.Payment)
 	if in.Routes != nil {
 		in, out := &in.Routes, &out.Routes
 		*out = make([]RouteRule, len(*in))
@@ -145,6 +863,80 @@ func (in *X402RouteSpec) DeepCopyInto(out *X402RouteSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PricePlanRef != nil {
+		in, out := &in.PricePlanRef, &out.PricePlanRef
+		*out = new(PricePlanReference)
+		**out = **in
+	}
+	if in.VariablesFrom != nil {
+		in, out := &in.VariablesFrom, &out.VariablesFrom
+		*out = make([]VariableSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BackendProtocols != nil {
+		in, out := &in.BackendProtocols, &out.BackendProtocols
+		*out = make([]BackendProtocol, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackendTLS != nil {
+		in, out := &in.BackendTLS, &out.BackendTLS
+		*out = make([]BackendTLS, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.WaitingRoom != nil {
+		in, out := &in.WaitingRoom, &out.WaitingRoom
+		*out = new(WaitingRoomPolicy)
+		**out = **in
+	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(ApprovalPolicy)
+		**out = **in
+	}
+	if in.Fallback != nil {
+		in, out := &in.Fallback, &out.Fallback
+		*out = new(FallbackBackend)
+		**out = **in
+	}
+	if in.SettlementCallbacks != nil {
+		in, out := &in.SettlementCallbacks, &out.SettlementCallbacks
+		*out = new(SettlementCallbackPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sidecar != nil {
+		in, out := &in.Sidecar, &out.Sidecar
+		*out = new(SidecarBackend)
+		**out = **in
+	}
+	if in.ClusterOverrides != nil {
+		in, out := &in.ClusterOverrides, &out.ClusterOverrides
+		*out = make([]ClusterOverride, len(*in))
+		copy(*out, *in)
+	}
+	if in.Mirror != nil {
+		in, out := &in.Mirror, &out.Mirror
+		*out = new(MirrorPolicy)
+		**out = **in
+	}
+	if in.PaidResponseCaching != nil {
+		in, out := &in.PaidResponseCaching, &out.PaidResponseCaching
+		*out = new(ResponseCachingPolicy)
+		**out = **in
+	}
+	if in.Exemptions != nil {
+		in, out := &in.Exemptions, &out.Exemptions
+		*out = new(ExemptionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Reputation != nil {
+		in, out := &in.Reputation, &out.Reputation
+		*out = new(ReputationPolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new X402RouteSpec.
@@ -160,6 +952,20 @@ func (in *X402RouteSpec) DeepCopy() *X402RouteSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *X402RouteStatus) DeepCopyInto(out *X402RouteStatus) {
 	*out = *in
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]RuleStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Cleanup != nil {
+		in, out := &in.Cleanup, &out.Cleanup
+		*out = new(CleanupStatus)
+		**out = **in
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -0,0 +1,166 @@
+// Command gateway runs the x402 gateway as a remote data plane, without
+// Kubernetes access: its routes are streamed from an operator running with
+// --route-sync-bind-address.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/gateway"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/logging"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routesync"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// runnable is a component run until shutdown.
+type runnable interface {
+	Start(ctx context.Context) error
+}
+
+func main() {
+	hostname, _ := os.Hostname()
+	var gatewayAddr, metricsAddr, probeAddr string
+	var upstream, tokenFile, caFile, node string
+	var useTLS bool
+	var contextKeyDir, logLevel string
+	var trustedUpstreamKeyDir, trustedUpstreamAudience string
+	flag.StringVar(&gatewayAddr, "gateway-bind-address", ":8402", "Comma-separated addresses the gateway proxy binds to.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address /metrics binds to. Empty disables it.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address /healthz and /readyz bind to.")
+	flag.StringVar(&upstream, "route-sync-upstream", "", "Address of the operator's route sync service, e.g. x402-k8s-operator.x402-system:8403. Required.")
+	flag.StringVar(&tokenFile, "route-sync-token-file", "", "File with the bearer token presented to the route sync service. Re-read on every stream.")
+	flag.BoolVar(&useTLS, "route-sync-tls", false, "Connect to the route sync service with TLS.")
+	flag.StringVar(&caFile, "route-sync-ca-file", "", "CA bundle the route sync service's certificate is verified against. Empty uses the system roots.")
+	flag.StringVar(&node, "node-name", hostname, "Name of this gateway in the operator's logs.")
+	flag.StringVar(&contextKeyDir, "context-signing-key-dir", "", "Directory with X-402-Context signing keys. Empty disables context signing.")
+	flag.StringVar(&trustedUpstreamKeyDir, "trusted-upstream-key-dir", "", "Directory with the keys shared with a trusted upstream tier. Payments it attests as verified in the X-402-Verified header are settled without verifying them again. Empty disables attestations.")
+	flag.StringVar(&trustedUpstreamAudience, "trusted-upstream-audience", "", "Audience this gateway accepts attestations for. Required by --trusted-upstream-key-dir.")
+	flag.StringVar(&logLevel, "gateway-log-level", "info", "Level of the gateway's logs: debug, info, warn or error.")
+	flag.Parse()
+
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		fatal("invalid --gateway-log-level", err)
+	}
+	slog.SetDefault(slog.New(logging.NewHandler(os.Stderr, logging.Options{Level: level, SampleRate: 1, Redact: true})))
+	if upstream == "" {
+		fatal("--route-sync-upstream is required", nil)
+	}
+
+	if trustedUpstreamKeyDir != "" && trustedUpstreamAudience == "" {
+		fatal("--trusted-upstream-key-dir requires --trusted-upstream-audience", nil)
+	}
+
+	store := routestore.New()
+	gw, err := gateway.NewServer(gatewayAddr, store, nil)
+	if err != nil {
+		fatal("invalid gateway bind address", err)
+	}
+	if contextKeyDir != "" {
+		keys, err := backend.LoadKeyDir(contextKeyDir, time.Minute)
+		if err != nil {
+			fatal("unable to load context signing keys", err)
+		}
+		gw.EnableContextSigning(keys)
+	}
+	if trustedUpstreamKeyDir != "" {
+		keys, err := backend.LoadKeyDir(trustedUpstreamKeyDir, time.Minute)
+		if err != nil {
+			fatal("unable to load trusted upstream keys", err)
+		}
+		gw.EnableTrustedUpstream(keys, trustedUpstreamAudience)
+	}
+	routeSync := &routesync.Client{
+		Upstream:  upstream,
+		Node:      node,
+		Store:     store,
+		TokenFile: tokenFile,
+		TLS:       useTLS,
+		CAFile:    caFile,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		// Not ready before the first routes arrive, so a fresh replica does
+		// not answer 404 to every request.
+		if err := errors.Join(gw.ReadyCheck(r), routeSync.ReadyCheck(r)); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	runnables := []runnable{gw, routeSync, &httpServer{name: "probe", addr: probeAddr, handler: mux}}
+	if metricsAddr != "" {
+		metricsHandler := promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{})
+		runnables = append(runnables, &httpServer{name: "metrics", addr: metricsAddr, handler: metricsHandler})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	slog.Info("starting remote gateway", "gateway", gatewayAddr, "upstream", upstream, "node", node)
+	errs := make(chan error, len(runnables))
+	var wg sync.WaitGroup
+	for _, r := range runnables {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.Start(ctx); err != nil {
+				errs <- err
+				stop()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		fatal("gateway failed", err)
+	}
+}
+
+// httpServer serves handler on addr until ctx is canceled.
+type httpServer struct {
+	name    string
+	addr    string
+	handler http.Handler
+}
+
+func (s *httpServer) Start(ctx context.Context) error {
+	srv := &http.Server{Addr: s.addr, Handler: s.handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("%s server failed: %w", s.name, err)
+	}
+	return nil
+}
+
+// fatal logs msg with err and exits.
+func fatal(msg string, err error) {
+	if err != nil {
+		slog.Error(msg, "error", err)
+	} else {
+		slog.Error(msg)
+	}
+	os.Exit(1)
+}
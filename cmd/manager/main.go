@@ -1,22 +1,50 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/billing"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/cloudevents"
 	"github.com/razvanmacovei/x402-k8s-operator/internal/controller"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/finops"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/fleet"
 	"github.com/razvanmacovei/x402-k8s-operator/internal/gateway"
-	_ "github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	_ "github.com/razvanmacovei/x402-k8s-operator/internal/kms" // KMS key URI schemes
+	"github.com/razvanmacovei/x402-k8s-operator/internal/logging"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/privacy"
 	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routesync"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/tokenmeta"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/version"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/signer"
 )
 
 var (
@@ -36,53 +64,481 @@ func main() {
 	var enableLeaderElection bool
 	var operatorNamespace string
 	var operatorSvcName string
+	var podIP string
+	var contextKeyDir, contextKeyURI string
+	var trustedUpstreamKeyDir, trustedUpstreamAudience string
+	var exchangeRateURL string
+	var chainRPCURLs string
+	var exchangeRateRefresh, exchangeRateMaxAge time.Duration
+	var vaultRefresh time.Duration
+	var validateOnly, printArgoCDHealth bool
+	var allowSidecarBackends, allowExternalBackends bool
+	var clusterName, fleetURL, fleetMode, fleetTokenFile string
+	var settlementExportDir string
+	var settlementExportInterval time.Duration
+	var billingProvider, billingURL, billingKeyFile, billingStripeMeter, billingMappings string
+	var cloudEventsSink, cloudEventsSource string
+	var gatewayLogLevel string
+	var gatewayLogSampleRate float64
+	var logRedaction bool
+	var privacyMode, privacySaltFile string
+	var charge controller.ChargePolicy
+	var rawPathLabels bool
+	var metricsExemplars bool
+	var maxPathLabels int
+	var captureFailedVerifications int
+	var reputationHalfLife time.Duration
+	var analyticsTokenFile string
+	var storageURL, storagePasswordFile string
+	var queueTokenKeyFile string
+	var gatewayTLSCertDir, clientCertHeader string
+	var trustForwardedProto bool
+	var reusePortListeners, listenBacklog, keepAliveCount int
+	var keepAliveIdle, keepAliveInterval time.Duration
+	var maxConnsPerIP int
+	var connLimitExempt string
+	connLimits := gateway.DefaultConnLimits
+	var probeInterval time.Duration
+	var probeFacilitatorURL string
+	var routeSyncAddr, routeSyncTokenFile, routeSyncTLSCertDir string
+	var enableEchoBackend bool
+	var echoBackendAddr string
+	var webhookCertDir string
+	var webhookPort int
 
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metrics endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.StringVar(&gatewayAddr, "gateway-bind-address", ":8402", "The address the gateway proxy binds to.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metrics endpoint binds to. The host may be an IP address (IPv6 in brackets, e.g. [::]:8080) or a network interface name (e.g. eth0:8080).")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to. The host may be an IP address (IPv6 in brackets) or a network interface name.")
+	flag.StringVar(&gatewayAddr, "gateway-bind-address", ":8402", "Comma-separated addresses the gateway proxy binds to, e.g. 10.0.0.5:8402,[fd00::5]:8402. A host may be an IP address (IPv6 in brackets) or a network interface name (e.g. eth0:8402). An empty host or [::] listens on IPv4 and IPv6.")
+	flag.IntVar(&reusePortListeners, "gateway-reuse-port-listeners", 0, "Listening sockets opened per gateway address with SO_REUSEPORT, each with its own accept queue, so the kernel spreads connections across them. For very high connection rates; Linux only. 0 opens one socket without SO_REUSEPORT.")
+	flag.IntVar(&listenBacklog, "gateway-listen-backlog", 0, "Length of the queue of connections waiting to be accepted by each gateway socket, capped by net.core.somaxconn. Linux only. 0 keeps the system default.")
+	flag.DurationVar(&keepAliveIdle, "gateway-tcp-keepalive", 0, "Idle time before TCP keep-alive probes are sent on gateway connections. 0 keeps Go's default of 15s; negative disables keep-alive probes.")
+	flag.DurationVar(&keepAliveInterval, "gateway-tcp-keepalive-interval", 0, "Time between TCP keep-alive probes on gateway connections. 0 keeps Go's default of 15s.")
+	flag.IntVar(&keepAliveCount, "gateway-tcp-keepalive-count", 0, "Unanswered TCP keep-alive probes before a gateway connection is dropped. 0 keeps Go's default of 9.")
+	flag.IntVar(&maxConnsPerIP, "gateway-max-conns-per-ip", 0, "Open gateway connections allowed per client IP address; further connections are closed as soon as they are accepted. 0 removes the cap. Behind an Ingress controller, exempt its pods with --gateway-conn-limit-exempt-cidrs.")
+	flag.StringVar(&connLimitExempt, "gateway-conn-limit-exempt-cidrs", "", "Comma-separated client networks or addresses (e.g. 10.0.0.0/8,fd00::/8) exempt from --gateway-max-conns-per-ip.")
+	flag.DurationVar(&connLimits.ReadHeaderTimeout, "gateway-read-header-timeout", connLimits.ReadHeaderTimeout, "Time a client has to send a request's headers to the gateway before its connection is closed.")
+	flag.DurationVar(&connLimits.ReadTimeout, "gateway-read-timeout", connLimits.ReadTimeout, "Time a client has to send a whole request to the gateway, body included.")
+	flag.DurationVar(&connLimits.WriteTimeout, "gateway-write-timeout", connLimits.WriteTimeout, "Time the gateway has to write a response, bounding clients that read slowly.")
+	flag.DurationVar(&connLimits.IdleTimeout, "gateway-idle-timeout", connLimits.IdleTimeout, "Time a kept-alive gateway connection waits for its next request before it is closed.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager.")
 	flag.StringVar(&operatorNamespace, "operator-namespace", envOrDefault("POD_NAMESPACE", "x402-system"), "Namespace where the operator runs.")
 	flag.StringVar(&operatorSvcName, "operator-service-name", envOrDefault("OPERATOR_SERVICE_NAME", "x402-k8s-operator"), "Service name of the operator.")
+	flag.StringVar(&contextKeyDir, "context-signing-key-dir", "", "Directory with X-402-Context signing keys (e.g. a mounted Secret). Empty disables context signing.")
+	flag.StringVar(&contextKeyURI, "context-signing-key-uri", "", "KMS key signing X-402-Context tokens, e.g. vault-transit://transit/x402-context or gcpkms://projects/.../cryptoKeys/<k>. Mutually exclusive with --context-signing-key-dir.")
+	flag.StringVar(&trustedUpstreamKeyDir, "trusted-upstream-key-dir", "", "Directory with the keys shared with a trusted upstream tier, such as an edge gateway (e.g. a mounted Secret). Payments it attests as verified in the X-402-Verified header are settled without verifying them again. Empty disables attestations.")
+	flag.StringVar(&trustedUpstreamAudience, "trusted-upstream-audience", "", "Audience this gateway accepts attestations for; the upstream tier names it in every attestation. Required by --trusted-upstream-key-dir.")
+	flag.StringVar(&exchangeRateURL, "exchange-rate-url", "", "Exchange-rate provider URL for fiat-denominated prices. Empty disables fiat prices.")
+	flag.DurationVar(&vaultRefresh, "vault-secret-refresh", 5*time.Minute, "How often values read from Vault (payment.walletSecretRef, payment.facilitatorAuth) are re-read. Vault is configured by the VAULT_* environment variables.")
+	flag.DurationVar(&exchangeRateRefresh, "exchange-rate-refresh", time.Minute, "How often cached exchange rates are re-fetched.")
+	flag.DurationVar(&exchangeRateMaxAge, "exchange-rate-max-age", 10*time.Minute, "Maximum age of a cached exchange rate used when the provider is unavailable.")
+	flag.StringVar(&chainRPCURLs, "chain-rpc-urls", "", "Comma-separated chainID=url JSON-RPC endpoints used to read metadata of assets outside the built-in registry (e.g. eip155:8453=https://mainnet.base.org).")
+	flag.StringVar(&podIP, "pod-ip", os.Getenv("POD_IP"), "IP address of this pod, used to detect the last ready gateway replica on shutdown.")
+	flag.BoolVar(&allowSidecarBackends, "allow-sidecar-backends", false, "Allow X402Routes to set spec.sidecar, which points the gateway at localhost ports and Unix sockets in its own pod. Enable only when the gateway runs as a sidecar.")
+	flag.BoolVar(&allowExternalBackends, "allow-external-backends", false, "Allow X402Route rules to set externalBackend, which proxies paid traffic to HTTPS upstreams outside the cluster, such as SaaS APIs.")
+	flag.StringVar(&clusterName, "cluster-name", os.Getenv("CLUSTER_NAME"), "Name of this cluster in a fleet. Selects the spec.clusterOverrides that apply and identifies the pricing exported with --fleet-url.")
+	flag.StringVar(&fleetURL, "fleet-url", "", "Central endpoint that route pricing is exchanged with across clusters. Empty disables the fleet exchange. Requires --cluster-name.")
+	flag.StringVar(&fleetMode, "fleet-mode", fleet.ModeExport, "Fleet exchange mode: export publishes this cluster's pricing and compares it with the other clusters, pull only compares.")
+	flag.StringVar(&fleetTokenFile, "fleet-token-file", "", "File with a bearer token for the fleet endpoint (e.g. a mounted Secret). Re-read on every request.")
+	flag.StringVar(&settlementExportDir, "settlement-export-config-dir", "", "Directory with the object storage settings for settlement exports (e.g. a mounted Secret). Empty disables the export.")
+	flag.DurationVar(&settlementExportInterval, "settlement-export-interval", 15*time.Minute, "How often batched settlement records are uploaded.")
+	flag.StringVar(&billingProvider, "billing-provider", "", "Mirror each settlement to a billing system: stripe (billing meter events) or rest. Empty disables the billing bridge.")
+	flag.StringVar(&billingURL, "billing-url", "", "Endpoint settlements are posted to. Required for rest; defaults to the Stripe API for stripe.")
+	flag.StringVar(&billingKeyFile, "billing-api-key-file", "", "File with the billing API key, sent as a bearer token (e.g. a mounted Secret). Re-read on every delivery.")
+	flag.StringVar(&billingStripeMeter, "billing-stripe-meter", "x402_settlement", "Event name of the Stripe billing meter that settlements are reported to.")
+	flag.StringVar(&billingMappings, "billing-mapping-configmap", "x402-billing-mappings", "ConfigMap in the operator namespace with the rules mapping routes and payers to billing customers.")
+	flag.StringVar(&cloudEventsSink, "cloudevents-sink", "", "URL that X402Route lifecycle changes are posted to as CloudEvents, such as a Knative broker. Empty disables the events.")
+	flag.StringVar(&cloudEventsSource, "cloudevents-source", "x402-k8s-operator", "Source attribute of the CloudEvents, identifying this operator.")
+	flag.StringVar(&gatewayLogLevel, "gateway-log-level", "info", "Level of the gateway's logs and those of its background components: debug, info, warn or error. The controller's level is set with --zap-log-level.")
+	flag.Float64Var(&gatewayLogSampleRate, "gateway-log-sample-rate", 1, "Fraction (0-1) of gateway log records below warn that are written, such as the per-request logs. Warnings and errors are always written.")
+	flag.BoolVar(&logRedaction, "log-redaction", true, "Remove payment headers and truncate wallet addresses in gateway logs.")
+	flag.StringVar(&privacyMode, "privacy-mode", privacy.ModeOff, "How payer and wallet addresses appear in gateway logs, metric labels and events: off, truncate or hash. Overrides the address truncation of --log-redaction.")
+	flag.StringVar(&privacySaltFile, "privacy-salt-file", "", "File with the salt of hashed addresses, typically a mounted Secret key. Required by --privacy-mode=hash.")
+	flag.StringVar(&charge.MinimumCharge, "minimum-charge", "", "Smallest amount in tokens (e.g. 0.001) a paid request is charged, for X402Routes without spec.payment.minimumCharge. Empty disables it.")
+	flag.StringVar(&charge.PriceIncrement, "price-increment", "", "Charged amounts are rounded up to a multiple of this token amount (e.g. 0.0001), for X402Routes without spec.payment.priceIncrement. Empty keeps the token's precision.")
+	flag.BoolVar(&rawPathLabels, "metrics-raw-path-labels", false, "Label x402_requests_total and x402_payment_amount_total with the raw request path instead of the matched rule pattern. Paths with IDs make the label unbounded; --metrics-max-path-labels still caps it.")
+	flag.BoolVar(&metricsExemplars, "metrics-exemplars", false, "Attach the trace ID of requests carrying a sampled W3C traceparent header as exemplars to x402_payment_verification_duration_seconds and x402_proxy_request_duration_seconds, and serve /metrics in the OpenMetrics format to scrapers that ask for it. Enable when the ingress controller or clients propagate OpenTelemetry traces.")
+	flag.IntVar(&maxPathLabels, "metrics-max-path-labels", metrics.DefaultMaxPathLabels, "Distinct values of the path metric label before new ones are counted as \"other\". 0 removes the cap.")
+	flag.IntVar(&captureFailedVerifications, "capture-failed-verifications", 0, "Keep the last N failed payment verifications of every X402Route, with signatures and nonces redacted, and serve them on the metrics endpoint at "+gateway.VerificationCapturePath+". 0 disables the capture.")
+	flag.DurationVar(&reputationHalfLife, "payer-reputation-half-life", time.Hour, "How fast payer reputation scores raised by failed payments decay; routes graylist or deny payers by score with spec.reputation. Scores are served on the metrics endpoint at "+gateway.ReputationPath+". 0 disables reputation tracking.")
+	flag.StringVar(&analyticsTokenFile, "analytics-token-file", "", "File with the bearer token required by "+gateway.AnalyticsPath+" on the gateway port (e.g. a mounted Secret). Re-read on every request. Empty disables analytics.")
+	flag.StringVar(&storageURL, "storage-url", "", "Store of the stateful gateway features, such as analytics: memory:// keeps state per replica, redis://host:6379/0 (or rediss:// for TLS) shares it across replicas. Empty is memory://.")
+	flag.StringVar(&storagePasswordFile, "storage-password-file", "", "File with the Redis password of --storage-url (e.g. a mounted Secret). Read whenever a connection is opened.")
+	flag.StringVar(&queueTokenKeyFile, "queue-token-key-file", "", "File with the key that signs the queue tokens of X402Routes with spec.waitingRoom (e.g. a mounted Secret), at least 16 bytes. Share it across gateway replicas so any replica honors a token; empty signs with a random key per replica.")
+	flag.StringVar(&gatewayTLSCertDir, "gateway-tls-cert-dir", "", "Directory with the tls.crt and tls.key the gateway serves TLS with (e.g. a mounted Secret), reloaded when they change. Clients are asked for a certificate for spec.payment.bindClient. Empty serves plain HTTP.")
+	flag.BoolVar(&trustForwardedProto, "trust-forwarded-proto", false, "Advertise https resource URLs for requests with X-Forwarded-Proto: https, for TLS terminated by a proxy in front of the Ingress. Only set it when the proxy overwrites the header on every request; otherwise only hosts in the Ingress spec.tls are https.")
+	flag.StringVar(&clientCertHeader, "client-cert-header", "", "Request header a TLS-terminating proxy forwards the client certificate in (URL-encoded PEM, e.g. ssl-client-cert of ingress-nginx, or a hex SHA-256 fingerprint), trusted by spec.payment.bindClient: certificate. Only set it when the proxy overwrites the header on every request.")
+	flag.DurationVar(&probeInterval, "probe-interval", 0, "How often a synthetic paid request is sent through the gateway for every X402Route, recording the ProbeSucceeded condition. 0 disables probes. Requires --probe-facilitator-url.")
+	flag.StringVar(&probeFacilitatorURL, "probe-facilitator-url", "", "Sandbox facilitator that accepts the mock payments of probes, e.g. cmd/mock-facilitator. Probes never use the route's facilitator.")
+	flag.StringVar(&routeSyncAddr, "route-sync-bind-address", "", "The address the route sync service binds to, streaming compiled routes to remote gateways (cmd/gateway) over gRPC, e.g. :8403. Empty disables it.")
+	flag.StringVar(&routeSyncTokenFile, "route-sync-token-file", "", "File with the bearer token remote gateways must present to the route sync service (e.g. a mounted Secret). Re-read on every stream. Empty accepts any gateway.")
+	flag.StringVar(&routeSyncTLSCertDir, "route-sync-tls-cert-dir", "", "Directory with the tls.crt and tls.key the route sync service serves TLS with (e.g. a mounted Secret), reloaded when they change. Empty serves plaintext gRPC.")
+	flag.BoolVar(&enableEchoBackend, "enable-echo-backend", false, "Serve a built-in backend that answers every request with a JSON echo of it, for soak tests of a single gateway pod without an application. X402Routes reach it with spec.sidecar.port, which requires --allow-sidecar-backends.")
+	flag.StringVar(&echoBackendAddr, "echo-backend-bind-address", gateway.DefaultEchoBackendAddr, "The address the echo backend binds to. Keep it on loopback.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "", "Directory with the tls.crt and tls.key of the X402Route validating webhook (e.g. a mounted Secret). Empty disables the webhook.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the validating webhook binds to.")
+	flag.BoolVar(&validateOnly, "validate-only", false, "Print the effective configuration, compile every X402Route in the cluster and the Ingress patches they would apply as JSON, then exit without changing anything. Exits 1 on any error.")
+	flag.BoolVar(&printArgoCDHealth, "print-argocd-health", false, "Print the Argo CD Lua health check for X402Routes and exit.")
 
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	if printArgoCDHealth {
+		fmt.Print(controller.ArgoCDHealthCheck)
+		return
+	}
+
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	logLevel, err := logging.ParseLevel(gatewayLogLevel)
+	if err != nil {
+		setupLog.Error(err, "invalid --gateway-log-level")
+		os.Exit(1)
+	}
+	var salt []byte
+	if privacySaltFile != "" {
+		if salt, err = privacy.LoadSalt(privacySaltFile); err != nil {
+			setupLog.Error(err, "unable to load privacy salt")
+			os.Exit(1)
+		}
+	}
+	redactor, err := privacy.New(privacyMode, salt)
+	if err != nil {
+		setupLog.Error(err, "invalid --privacy-mode")
+		os.Exit(1)
+	}
+	privacy.SetDefault(redactor)
+	var queueTokenKey []byte
+	if queueTokenKeyFile != "" {
+		if queueTokenKey, err = loadQueueTokenKey(queueTokenKeyFile); err != nil {
+			setupLog.Error(err, "unable to load queue token key")
+			os.Exit(1)
+		}
+	}
+	logOpts := logging.Options{Level: logLevel, SampleRate: gatewayLogSampleRate, Redact: logRedaction}
+	switch {
+	case redactor != nil:
+		logOpts.Addresses = redactor.Text
+	case logRedaction:
+		truncate, _ := privacy.New(privacy.ModeTruncate, nil)
+		logOpts.Addresses = truncate.Text
+	}
+	if err := logOpts.Validate(); err != nil {
+		setupLog.Error(err, "invalid --gateway-log-sample-rate")
+		os.Exit(1)
+	}
+	if err := charge.Validate(); err != nil {
+		setupLog.Error(err, "invalid --minimum-charge or --price-increment")
+		os.Exit(1)
+	}
+	slog.SetDefault(slog.New(logging.NewHandler(os.Stderr, logOpts)))
+	metrics.ConfigurePathLabels(rawPathLabels, maxPathLabels)
+	metrics.EnableExemplars(metricsExemplars)
+
+	if contextKeyDir != "" && contextKeyURI != "" {
+		setupLog.Error(nil, "--context-signing-key-dir and --context-signing-key-uri are mutually exclusive")
+		os.Exit(1)
+	}
+	if trustedUpstreamKeyDir != "" && trustedUpstreamAudience == "" {
+		setupLog.Error(nil, "--trusted-upstream-key-dir requires --trusted-upstream-audience")
+		os.Exit(1)
+	}
+	if probeInterval > 0 && probeFacilitatorURL == "" {
+		setupLog.Error(nil, "--probe-interval requires --probe-facilitator-url")
+		os.Exit(1)
+	}
+
+	stateStore, err := storage.Open(storageURL, storagePasswordFile)
+	if err != nil {
+		setupLog.Error(err, "invalid --storage-url")
+		os.Exit(1)
+	}
+
+	// Vault is optional: routes referencing it fail to compile without it.
+	var secrets *vault.Secrets
+	if os.Getenv("VAULT_ADDR") != "" {
+		vaultClient, err := vault.FromEnv()
+		if err != nil {
+			setupLog.Error(err, "invalid Vault configuration")
+			os.Exit(1)
+		}
+		secrets = vault.NewSecrets(vaultClient, vaultRefresh)
+	}
+
+	if validateOnly {
+		os.Exit(runValidateOnly(secrets, contextKeyDir, contextKeyURI, chainRPCURLs, settlementExportDir, operatorNamespace, operatorSvcName, clusterName, fleetURL, fleetMode, allowSidecarBackends, allowExternalBackends, charge))
+	}
+
 	// Create shared route store.
 	store := routestore.New()
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	for _, addr := range []*string{&metricsAddr, &probeAddr} {
+		resolved, err := gateway.ResolveBindAddress(*addr)
+		if err != nil {
+			setupLog.Error(err, "invalid bind address")
+			os.Exit(1)
+		}
+		*addr = resolved
+	}
+	metricsOpts := metricsserver.Options{BindAddress: metricsAddr}
+	if metricsExemplars {
+		metricsOpts.FilterProvider = metrics.OpenMetricsFilter
+	}
+	var verificationCapture *gateway.VerificationCapture
+	metricsOpts.ExtraHandlers = map[string]http.Handler{}
+	if captureFailedVerifications > 0 {
+		verificationCapture = gateway.NewVerificationCapture(captureFailedVerifications, logOpts.Addresses)
+		metricsOpts.ExtraHandlers[gateway.VerificationCapturePath] = verificationCapture
+	}
+	var reputation *gateway.Reputation
+	if reputationHalfLife > 0 {
+		reputation = gateway.NewReputation(reputationHalfLife, logOpts.Addresses, stateStore)
+		metricsOpts.ExtraHandlers[gateway.ReputationPath] = reputation
+	}
+
+	mgrOpts := ctrl.Options{
 		Scheme:                 scheme,
-		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		Metrics:                metricsOpts,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "x402-operator.x402.io",
-	})
+	}
+	if webhookCertDir != "" {
+		mgrOpts.WebhookServer = webhook.NewServer(webhook.Options{Port: webhookPort, CertDir: webhookCertDir})
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), mgrOpts)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	var fleetClient *fleet.Client
+	if fleetURL != "" {
+		fleetClient, err = fleet.NewClient(fleetURL, clusterName, fleetMode, fleetTokenFile)
+		if err != nil {
+			setupLog.Error(err, "invalid fleet configuration")
+			os.Exit(1)
+		}
+	}
+
+	var cloudEvents *cloudevents.Publisher
+	if cloudEventsSink != "" {
+		cloudEvents, err = cloudevents.NewPublisher(cloudEventsSink, cloudEventsSource)
+		if err != nil {
+			setupLog.Error(err, "invalid CloudEvents configuration")
+			os.Exit(1)
+		}
+		if err := mgr.Add(cloudEvents); err != nil {
+			setupLog.Error(err, "unable to add CloudEvents publisher to manager")
+			os.Exit(1)
+		}
+	}
+
 	// Register controller.
 	if err = (&controller.X402RouteReconciler{
-		Client:            mgr.GetClient(),
-		Scheme:            mgr.GetScheme(),
-		RouteStore:        store,
-		OperatorNamespace: operatorNamespace,
-		OperatorSvcName:   operatorSvcName,
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		RouteStore:            store,
+		OperatorNamespace:     operatorNamespace,
+		OperatorSvcName:       operatorSvcName,
+		Recorder:              privacy.NewEventRecorder(mgr.GetEventRecorder("x402-controller"), redactor),
+		CloudEvents:           cloudEvents,
+		AllowSidecarBackends:  allowSidecarBackends,
+		AllowExternalBackends: allowExternalBackends,
+		ClusterName:           clusterName,
+		Fleet:                 fleetClient,
+		Charge:                charge,
+		Secrets:               secrets,
+		APIReader:             mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "X402Route")
 		os.Exit(1)
 	}
 
+	if webhookCertDir != "" {
+		if err := (&controller.X402RouteValidator{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "X402Route")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook ready check")
+			os.Exit(1)
+		}
+	}
+
 	// Register gateway as a managed runnable.
-	gw := gateway.NewServer(gatewayAddr, store)
+	gw, err := gateway.NewServer(gatewayAddr, store, privacy.NewEventRecorder(mgr.GetEventRecorder("x402-gateway"), redactor))
+	if err != nil {
+		setupLog.Error(err, "invalid gateway bind address")
+		os.Exit(1)
+	}
+	connLimits.MaxPerIP = maxConnsPerIP
+	if connLimits.Exempt, err = parsePrefixes(connLimitExempt); err != nil {
+		setupLog.Error(err, "invalid --gateway-conn-limit-exempt-cidrs")
+		os.Exit(1)
+	}
+	gw.SetConnLimits(connLimits)
+	gw.SetListenerOptions(gateway.ListenerOptions{
+		ReusePort: reusePortListeners,
+		Backlog:   listenBacklog,
+		KeepAlive: net.KeepAliveConfig{
+			Enable:   keepAliveIdle >= 0,
+			Idle:     keepAliveIdle,
+			Interval: keepAliveInterval,
+			Count:    keepAliveCount,
+		},
+	})
+	if contextKeyDir != "" || contextKeyURI != "" {
+		keys, err := loadContextKeys(contextKeyDir, contextKeyURI)
+		if err != nil {
+			setupLog.Error(err, "unable to load context signing keys")
+			os.Exit(1)
+		}
+		gw.EnableContextSigning(keys)
+	}
+	if trustedUpstreamKeyDir != "" {
+		keys, err := backend.LoadKeyDir(trustedUpstreamKeyDir, time.Minute)
+		if err != nil {
+			setupLog.Error(err, "unable to load trusted upstream keys")
+			os.Exit(1)
+		}
+		gw.EnableTrustedUpstream(keys, trustedUpstreamAudience)
+	}
+	if secrets != nil {
+		gw.EnableSecrets(secrets)
+	}
+	if exchangeRateURL != "" {
+		gw.EnableExchangeRates(gateway.NewExchangeRates(exchangeRateURL, exchangeRateRefresh, exchangeRateMaxAge))
+	}
+	if chainRPCURLs != "" {
+		urls, err := tokenmeta.ParseRPCURLs(chainRPCURLs)
+		if err != nil {
+			setupLog.Error(err, "invalid --chain-rpc-urls")
+			os.Exit(1)
+		}
+		resolver := tokenmeta.NewResolver(urls)
+		resolver.Client = mgr.GetClient()
+		resolver.Reader = mgr.GetAPIReader()
+		resolver.Namespace = operatorNamespace
+		resolver.ConfigMapName = "x402-token-metadata"
+		gw.EnableTokenMetadata(resolver)
+	}
+	if verificationCapture != nil {
+		gw.EnableVerificationCapture(verificationCapture)
+	}
+	gw.SetStateStore(stateStore)
+	if reputation != nil {
+		gw.EnableReputation(reputation)
+	}
+	if queueTokenKey != nil {
+		gw.SetQueueTokenKey(queueTokenKey)
+	}
+	if gatewayTLSCertDir != "" {
+		watcher, err := certwatcher.New(filepath.Join(gatewayTLSCertDir, "tls.crt"), filepath.Join(gatewayTLSCertDir, "tls.key"))
+		if err != nil {
+			setupLog.Error(err, "unable to load gateway TLS certificate")
+			os.Exit(1)
+		}
+		if err := mgr.Add(watcher); err != nil {
+			setupLog.Error(err, "unable to add gateway TLS certificate watcher")
+			os.Exit(1)
+		}
+		gw.EnableTLS(watcher.GetCertificate)
+	}
+	if clientCertHeader != "" {
+		gw.EnableClientCertHeader(clientCertHeader)
+	}
+	if trustForwardedProto {
+		gw.EnableForwardedProto()
+	}
+	if analyticsTokenFile != "" {
+		gw.EnableAnalytics(gateway.NewAnalytics(analyticsTokenFile, stateStore))
+	}
+	if settlementExportDir != "" {
+		replica, _ := os.Hostname()
+		exporter, err := finops.NewExporter(settlementExportDir, replica, settlementExportInterval)
+		if err != nil {
+			setupLog.Error(err, "unable to configure settlement export")
+			os.Exit(1)
+		}
+		gw.AddSettlementSink(exporter)
+		if err := mgr.Add(exporter); err != nil {
+			setupLog.Error(err, "unable to add settlement exporter to manager")
+			os.Exit(1)
+		}
+	}
+	if billingProvider != "" {
+		bridge, err := billing.NewBridge(billingProvider, billingURL, billingKeyFile, billingStripeMeter)
+		if err != nil {
+			setupLog.Error(err, "invalid billing bridge configuration")
+			os.Exit(1)
+		}
+		bridge.Reader = mgr.GetAPIReader()
+		bridge.Namespace = operatorNamespace
+		bridge.ConfigMapName = billingMappings
+		gw.AddSettlementSink(bridge)
+		if err := mgr.Add(bridge); err != nil {
+			setupLog.Error(err, "unable to add billing bridge to manager")
+			os.Exit(1)
+		}
+	}
+	if probeInterval > 0 {
+		prober, err := gw.EnableProbes(probeFacilitatorURL)
+		if err != nil {
+			setupLog.Error(err, "unable to enable probes")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&controller.RouteProber{
+			Client:     mgr.GetClient(),
+			RouteStore: store,
+			Probe:      prober.Probe,
+			Interval:   probeInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to add route prober to manager")
+			os.Exit(1)
+		}
+	}
+	if enableEchoBackend {
+		if err := mgr.Add(gateway.NewEchoBackend(echoBackendAddr)); err != nil {
+			setupLog.Error(err, "unable to add echo backend to manager")
+			os.Exit(1)
+		}
+	}
 	if err := mgr.Add(gw); err != nil {
 		setupLog.Error(err, "unable to add gateway server to manager")
 		os.Exit(1)
 	}
+	if routeSyncAddr != "" {
+		routeSync := routesync.NewServer(routeSyncAddr, store, routeSyncTokenFile)
+		if routeSyncTLSCertDir != "" {
+			watcher, err := certwatcher.New(filepath.Join(routeSyncTLSCertDir, "tls.crt"), filepath.Join(routeSyncTLSCertDir, "tls.key"))
+			if err != nil {
+				setupLog.Error(err, "unable to load route sync TLS certificate")
+				os.Exit(1)
+			}
+			if err := mgr.Add(watcher); err != nil {
+				setupLog.Error(err, "unable to add route sync TLS certificate watcher")
+				os.Exit(1)
+			}
+			routeSync.EnableTLS(watcher.GetCertificate)
+		}
+		if err := mgr.Add(routeSync); err != nil {
+			setupLog.Error(err, "unable to add route sync server to manager")
+			os.Exit(1)
+		}
+	}
+
+	// Switch routes with a fallback off the gateway when the last replica stops.
+	if err := mgr.Add(&controller.GatewayFailover{
+		Client:            mgr.GetClient(),
+		Reader:            mgr.GetAPIReader(),
+		OperatorNamespace: operatorNamespace,
+		OperatorSvcName:   operatorSvcName,
+		PodIP:             podIP,
+	}); err != nil {
+		setupLog.Error(err, "unable to add gateway failover hook to manager")
+		os.Exit(1)
+	}
 
 	// Health checks.
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -93,8 +549,18 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("gateway", gw.ReadyCheck); err != nil {
+		setupLog.Error(err, "unable to set up gateway ready check")
+		os.Exit(1)
+	}
 
+	build := version.Get()
+	metrics.BuildInfo.WithLabelValues(build.Version, build.Commit, build.BuildDate, build.GoVersion).Set(1)
 	setupLog.Info("starting manager",
+		"version", build.Version,
+		"commit", build.Commit,
+		"buildDate", build.BuildDate,
+		"fips", signer.FIPS(),
 		"metrics", metricsAddr,
 		"probes", probeAddr,
 		"gateway", gatewayAddr,
@@ -107,6 +573,133 @@ func main() {
 	}
 }
 
+// validationReport is the output of --validate-only.
+type validationReport struct {
+	Config       map[string]string            `json:"config"`
+	ConfigErrors []string                     `json:"configErrors,omitempty"`
+	Routes       []controller.RouteValidation `json:"routes"`
+}
+
+// runValidateOnly dry-runs the configuration and every X402Route, prints the
+// report to stdout and returns the process exit code.
+func runValidateOnly(secrets *vault.Secrets, contextKeyDir, contextKeyURI, chainRPCURLs, settlementExportDir, operatorNamespace, operatorSvcName, clusterName, fleetURL, fleetMode string, allowSidecarBackends, allowExternalBackends bool, charge controller.ChargePolicy) int {
+	report := validationReport{Config: make(map[string]string)}
+	flag.VisitAll(func(f *flag.Flag) {
+		report.Config[f.Name] = f.Value.String()
+	})
+	if contextKeyDir != "" {
+		if _, err := backend.LoadKeyDir(contextKeyDir, time.Minute); err != nil {
+			report.ConfigErrors = append(report.ConfigErrors, fmt.Sprintf("--context-signing-key-dir: %v", err))
+		}
+	}
+	if contextKeyURI != "" {
+		if _, err := loadContextKeys("", contextKeyURI); err != nil {
+			report.ConfigErrors = append(report.ConfigErrors, fmt.Sprintf("--context-signing-key-uri: %v", err))
+		}
+	}
+	if chainRPCURLs != "" {
+		if _, err := tokenmeta.ParseRPCURLs(chainRPCURLs); err != nil {
+			report.ConfigErrors = append(report.ConfigErrors, fmt.Sprintf("--chain-rpc-urls: %v", err))
+		}
+	}
+	if settlementExportDir != "" {
+		if _, err := finops.NewExporter(settlementExportDir, "validate", time.Minute); err != nil {
+			report.ConfigErrors = append(report.ConfigErrors, fmt.Sprintf("--settlement-export-config-dir: %v", err))
+		}
+	}
+	if fleetURL != "" {
+		if _, err := fleet.NewClient(fleetURL, clusterName, fleetMode, ""); err != nil {
+			report.ConfigErrors = append(report.ConfigErrors, fmt.Sprintf("--fleet-url: %v", err))
+		}
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+	r := &controller.X402RouteReconciler{
+		Client:                c,
+		Scheme:                scheme,
+		RouteStore:            routestore.New(),
+		OperatorNamespace:     operatorNamespace,
+		OperatorSvcName:       operatorSvcName,
+		AllowSidecarBackends:  allowSidecarBackends,
+		AllowExternalBackends: allowExternalBackends,
+		ClusterName:           clusterName,
+		Charge:                charge,
+		Secrets:               secrets,
+	}
+	report.Routes, err = r.ValidateRoutes(context.Background())
+	if err != nil {
+		setupLog.Error(err, "unable to validate routes")
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		setupLog.Error(err, "unable to write report")
+		return 1
+	}
+	code := 0
+	if len(report.ConfigErrors) > 0 {
+		code = 1
+	}
+	for _, route := range report.Routes {
+		if route.Error != "" {
+			code = 1
+		}
+	}
+	return code
+}
+
+// loadContextKeys loads the context signing keys from a directory or a KMS key
+// URI, re-reading them every minute.
+func loadContextKeys(dir, uri string) (*backend.KeySet, error) {
+	if dir != "" {
+		return backend.LoadKeyDir(dir, time.Minute)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return backend.LoadKeyURI(ctx, uri, time.Minute)
+}
+
+// loadQueueTokenKey reads the key that signs waiting room queue tokens.
+func loadQueueTokenKey(file string) ([]byte, error) {
+	key, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) < 16 {
+		return nil, fmt.Errorf("queue token key in %s is shorter than 16 bytes", file)
+	}
+	return key, nil
+}
+
+// parsePrefixes parses a comma-separated list of networks, where a plain
+// address stands for itself.
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
 func envOrDefault(key, defaultVal string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"os"
 	"time"
@@ -30,7 +32,62 @@ type settleResponse struct {
 	Network     string `json:"network,omitempty"`
 }
 
+// chaos injects the faults of a soak test into a share of the calls.
+type chaos struct {
+	latency           time.Duration
+	jitter            time.Duration
+	failureRate       float64
+	timeoutRate       float64
+	rejectRate        float64
+	settleFailureRate float64
+}
+
+// hit reports whether a call is picked at rate.
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// disrupt delays the call and may fail it or hang it until the caller gives
+// up. It reports whether the call was answered.
+func (c *chaos) disrupt(w http.ResponseWriter, r *http.Request) bool {
+	delay := c.latency
+	if c.jitter > 0 {
+		delay += rand.N(c.jitter)
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if hit(c.timeoutRate) {
+		<-r.Context().Done()
+		return true
+	}
+	if hit(c.failureRate) {
+		http.Error(w, "injected failure", http.StatusServiceUnavailable)
+		return true
+	}
+	return false
+}
+
 func main() {
+	var c chaos
+	flag.DurationVar(&c.latency, "latency", 0, "Delay added to every /verify and /settle answer.")
+	flag.DurationVar(&c.jitter, "latency-jitter", 0, "Random extra delay, up to this much, added to every /verify and /settle answer.")
+	flag.Float64Var(&c.failureRate, "failure-rate", 0, "Fraction (0-1) of /verify and /settle calls answered 503.")
+	flag.Float64Var(&c.timeoutRate, "timeout-rate", 0, "Fraction (0-1) of /verify and /settle calls never answered, until the caller gives up.")
+	flag.Float64Var(&c.rejectRate, "reject-rate", 0, "Fraction (0-1) of payments /verify rejects as invalid.")
+	flag.Float64Var(&c.settleFailureRate, "settle-failure-rate", 0, "Fraction (0-1) of settlements /settle reports as failed.")
+	flag.Parse()
+	for name, rate := range map[string]float64{"failure-rate": c.failureRate, "timeout-rate": c.timeoutRate, "reject-rate": c.rejectRate, "settle-failure-rate": c.settleFailureRate} {
+		if rate < 0 || rate > 1 {
+			fmt.Fprintf(os.Stderr, "--%s must be between 0 and 1\n", name)
+			os.Exit(2)
+		}
+	}
+
 	port := os.Getenv("X402_PORT")
 	if port == "" {
 		port = "8080"
@@ -54,7 +111,18 @@ func main() {
 			slog.Warn("invalid request body", "error", err)
 		}
 
+		if c.disrupt(w, r) {
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
+		if hit(c.rejectRate) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(verifyResponse{
+				InvalidReason: "injected_rejection",
+				Payer:         "0x0000000000000000000000000000000000000001",
+			})
+			return
+		}
 		json.NewEncoder(w).Encode(verifyResponse{
 			IsValid: true,
 			Payer:   "0x0000000000000000000000000000000000000001",
@@ -77,7 +145,14 @@ func main() {
 			slog.Warn("invalid request body", "error", err)
 		}
 
+		if c.disrupt(w, r) {
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
+		if hit(c.settleFailureRate) {
+			json.NewEncoder(w).Encode(settleResponse{ErrorReason: "injected_settlement_failure"})
+			return
+		}
 		json.NewEncoder(w).Encode(settleResponse{
 			Success:     true,
 			Payer:       "0x0000000000000000000000000000000000000001",
@@ -86,9 +161,20 @@ func main() {
 		})
 	})
 
+	mux.HandleFunc("GET /supported", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"kinds":[`+
+			`{"x402Version":2,"scheme":"exact","network":"eip155:84532"},`+
+			`{"x402Version":2,"scheme":"upto","network":"eip155:84532"}]}`)
+	})
+
 	addr := fmt.Sprintf(":%s", port)
-	slog.Info("starting mock facilitator", "addr", addr)
-	slog.Info("endpoints", "verify", "POST /verify", "settle", "POST /settle")
+	slog.Info("starting mock facilitator", "addr", addr,
+		"latency", c.latency, "latencyJitter", c.jitter,
+		"failureRate", c.failureRate, "timeoutRate", c.timeoutRate,
+		"rejectRate", c.rejectRate, "settleFailureRate", c.settleFailureRate,
+	)
+	slog.Info("endpoints", "verify", "POST /verify", "settle", "POST /settle", "supported", "GET /supported")
 
 	if err := http.ListenAndServe(addr, mux); err != nil {
 		slog.Error("server failed", "error", err)
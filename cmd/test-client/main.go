@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/client"
 )
 
+// mockPayload is accepted by cmd/mock-facilitator; it is not a valid on-chain payment.
+const mockPayload = `{"scheme":"exact","network":"eip155:84532","payload":{"signature":"0xdeadbeef","authorization":{"from":"0x0000000000000000000000000000000000000001","to":"0x1f6004907Adc7d313768b85917e069e011150390","value":"1000","validAfter":"0","validBefore":"999999999999","nonce":"0x01"}}}`
+
 func main() {
 	endpoint := "http://localhost:8402/api/hello"
 	if len(os.Args) > 1 {
@@ -20,6 +26,15 @@ func main() {
 	fmt.Println("=== x402 Test Client ===")
 	fmt.Printf("Endpoint: %s\n\n", endpoint)
 
+	// The price table is informational; older gateways do not serve it.
+	if table, err := client.FetchPrices(context.Background(), nil, endpoint); err == nil {
+		fmt.Printf("--- Prices for %s ---\n", table.Host)
+		for _, p := range table.Prices {
+			fmt.Printf("  %-24s %s (%s)\n", p.Path, p.Price, p.Network)
+		}
+		fmt.Println()
+	}
+
 	// Step 1: Send request without payment — expect 402.
 	fmt.Println("--- Step 1: Request without payment ---")
 	resp, err := http.Get(endpoint)
@@ -33,18 +48,10 @@ func main() {
 	fmt.Printf("Status: %d %s\n", resp.StatusCode, resp.Status)
 	fmt.Printf("Content-Type: %s\n", resp.Header.Get("Content-Type"))
 
-	// Decode PAYMENT-REQUIRED Base64 header.
-	if payReqHeader := resp.Header.Get("PAYMENT-REQUIRED"); payReqHeader != "" {
-		fmt.Printf("PAYMENT-REQUIRED header (Base64): %s...\n", truncate(payReqHeader, 60))
-		if decoded, err := base64.StdEncoding.DecodeString(payReqHeader); err == nil {
-			var pretty json.RawMessage
-			if json.Unmarshal(decoded, &pretty) == nil {
-				indented, _ := json.MarshalIndent(pretty, "  ", "  ")
-				fmt.Printf("PAYMENT-REQUIRED (decoded):\n  %s\n", string(indented))
-			}
-		} else {
-			fmt.Printf("PAYMENT-REQUIRED header decode error: %v\n", err)
-		}
+	if required, err := client.ParsePaymentRequired(resp); err == nil {
+		fmt.Printf("PAYMENT-REQUIRED (decoded):\n  %s\n", indent(required))
+	} else if err != client.ErrNoPaymentRequired {
+		fmt.Printf("PAYMENT-REQUIRED header decode error: %v\n", err)
 	}
 
 	fmt.Printf("Body:\n%s\n\n", string(body))
@@ -55,22 +62,15 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Step 2: Send request with a mock payment header (Payment-Signature).
+	// Step 2: Let the x402 client transport pay with a mock payload and retry.
 	fmt.Println("--- Step 2: Request with mock payment (Payment-Signature header) ---")
 
-	fakePayload := `{"scheme":"exact","network":"eip155:84532","payload":{"signature":"0xdeadbeef","authorization":{"from":"0x0000000000000000000000000000000000000001","to":"0x1f6004907Adc7d313768b85917e069e011150390","value":"1000","validAfter":"0","validBefore":"999999999999","nonce":"0x01"}}}`
-	paymentHeader := base64.StdEncoding.EncodeToString([]byte(fakePayload))
-
-	req, err := http.NewRequest("GET", endpoint, nil)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
-		os.Exit(1)
-	}
-	req.Header.Set("Payment-Signature", paymentHeader)
-
-	fmt.Printf("Payment-Signature: %s...\n", truncate(paymentHeader, 60))
+	payer := client.NewClient(client.SignerFunc(func(_ context.Context, _ *client.PaymentRequired) ([]byte, error) {
+		fmt.Printf("Payment-Signature: %s...\n", truncate(base64.StdEncoding.EncodeToString([]byte(mockPayload)), 60))
+		return []byte(mockPayload), nil
+	}))
 
-	resp2, err := http.DefaultClient.Do(req)
+	resp2, err := payer.Get(endpoint)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -81,18 +81,10 @@ func main() {
 	fmt.Printf("Status: %d %s\n", resp2.StatusCode, resp2.Status)
 	fmt.Printf("Content-Type: %s\n", resp2.Header.Get("Content-Type"))
 
-	// Decode PAYMENT-RESPONSE Base64 header.
-	if payRespHeader := resp2.Header.Get("PAYMENT-RESPONSE"); payRespHeader != "" {
-		fmt.Printf("PAYMENT-RESPONSE header (Base64): %s...\n", truncate(payRespHeader, 60))
-		if decoded, err := base64.StdEncoding.DecodeString(payRespHeader); err == nil {
-			var pretty json.RawMessage
-			if json.Unmarshal(decoded, &pretty) == nil {
-				indented, _ := json.MarshalIndent(pretty, "  ", "  ")
-				fmt.Printf("PAYMENT-RESPONSE (decoded):\n  %s\n", string(indented))
-			}
-		} else {
-			fmt.Printf("PAYMENT-RESPONSE header decode error: %v\n", err)
-		}
+	if settlement, err := client.ParseSettlement(resp2); err != nil {
+		fmt.Printf("PAYMENT-RESPONSE header decode error: %v\n", err)
+	} else if settlement != nil {
+		fmt.Printf("PAYMENT-RESPONSE (decoded):\n  %s\n", indent(settlement))
 	}
 
 	fmt.Printf("Body:\n%s\n\n", string(body2))
@@ -104,6 +96,11 @@ func main() {
 	}
 }
 
+func indent(v any) string {
+	out, _ := json.MarshalIndent(v, "  ", "  ")
+	return string(out)
+}
+
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
@@ -0,0 +1,88 @@
+// Command x402ctl is a local helper for working with X402Routes.
+//
+//	x402ctl init --ingress my-ing -n my-ns > route.yaml
+//
+// init reads an existing Ingress and prints a ready-to-edit X402Route with
+// every path of the Ingress listed as a free rule.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/scaffold"
+)
+
+const usage = `Usage: x402ctl <command> [flags]
+
+Commands:
+  init    Generate an X402Route manifest from an existing Ingress
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "init":
+		if err := runInit(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "x402ctl init:", err)
+			os.Exit(1)
+		}
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "x402ctl: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runInit prints the X402Route manifest of an Ingress in the cluster.
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	var ingress, namespace, kubeconfig string
+	var opts scaffold.Options
+	fs.StringVar(&ingress, "ingress", "", "Name of the Ingress to generate the X402Route for.")
+	fs.StringVar(&namespace, "n", "", "Namespace of the Ingress. Defaults to the namespace of the current kubeconfig context.")
+	fs.StringVar(&namespace, "namespace", "", "Namespace of the Ingress (same as -n).")
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file. Defaults to $KUBECONFIG or ~/.kube/config.")
+	fs.StringVar(&opts.Name, "name", "", "Name of the X402Route. Defaults to <ingress>-x402.")
+	fs.StringVar(&opts.Wallet, "wallet", "", "Wallet address receiving payments. Defaults to a placeholder to replace.")
+	fs.StringVar(&opts.Network, "network", scaffold.DefaultNetwork, "Network payments are made on.")
+	fs.Parse(args)
+	if ingress == "" {
+		return fmt.Errorf("--ingress is required")
+	}
+
+	loading := clientcmd.NewDefaultClientConfigLoadingRules()
+	loading.ExplicitPath = kubeconfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loading, &clientcmd.ConfigOverrides{})
+	if namespace == "" {
+		ns, _, err := config.Namespace()
+		if err != nil {
+			return fmt.Errorf("read namespace from kubeconfig: %w", err)
+		}
+		namespace = ns
+	}
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("load kubeconfig: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("create client: %w", err)
+	}
+
+	ing, err := clientset.NetworkingV1().Ingresses(namespace).Get(context.Background(), ingress, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get ingress %s/%s: %w", namespace, ingress, err)
+	}
+	return scaffold.Route(os.Stdout, ing, opts)
+}
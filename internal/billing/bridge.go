@@ -0,0 +1,320 @@
+// Package billing mirrors settled payments into a billing system: Stripe
+// metered usage through billing meter events, or any REST endpoint. Each
+// settlement is attributed to a billing customer by mapping rules kept in a
+// ConfigMap.
+package billing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/finops"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+)
+
+// Providers of a Bridge.
+const (
+	ProviderStripe = "stripe"
+	ProviderREST   = "rest"
+)
+
+const (
+	// stripeMeterEventsURL is Stripe's billing meter events endpoint.
+	stripeMeterEventsURL = "https://api.stripe.com/v1/billing/meter_events"
+
+	// mappingKey is the ConfigMap key holding the mapping rules.
+	mappingKey = "mappings.yaml"
+
+	queueSize       = 1024
+	workers         = 4
+	attempts        = 5
+	mappingInterval = time.Minute
+)
+
+// errRejected marks a delivery the provider refused outright; it is not retried.
+var errRejected = errors.New("rejected")
+
+// Mapping attributes settlements to a billing customer. Route is
+// "namespace/name", "namespace/*" or empty for any route; Payer is a payer
+// address (case-insensitive) or empty for any payer.
+type Mapping struct {
+	Route    string `json:"route,omitempty"`
+	Payer    string `json:"payer,omitempty"`
+	Customer string `json:"customer"`
+}
+
+// matches reports whether the mapping applies to a settlement.
+func (m Mapping) matches(rec finops.Record) bool {
+	switch {
+	case m.Route == "" || m.Route == "*":
+	case strings.HasSuffix(m.Route, "/*"):
+		if strings.TrimSuffix(m.Route, "/*") != rec.Namespace {
+			return false
+		}
+	case m.Route != rec.Namespace+"/"+rec.Route:
+		return false
+	}
+	return m.Payer == "" || strings.EqualFold(m.Payer, rec.Payer)
+}
+
+// ParseMappings parses mapping rules. The first matching rule wins.
+func ParseMappings(raw []byte) ([]Mapping, error) {
+	var mappings []Mapping
+	if err := yaml.Unmarshal(raw, &mappings); err != nil {
+		return nil, err
+	}
+	for i, m := range mappings {
+		if m.Customer == "" {
+			return nil, fmt.Errorf("mapping %d: customer is required", i)
+		}
+	}
+	return mappings, nil
+}
+
+// Bridge posts every settled payment it is given to the billing provider.
+// Records are delivered in the background; Record never blocks.
+type Bridge struct {
+	Provider string
+	URL      string // REST endpoint; Stripe uses its API unless set
+	// KeyFile holds the API key sent as a bearer token. It is re-read on each
+	// delivery, so a rotated key is picked up.
+	KeyFile     string
+	StripeMeter string // Stripe meter event name
+
+	Reader        client.Reader // reads the mapping ConfigMap
+	Namespace     string
+	ConfigMapName string
+
+	httpClient *http.Client
+	retryDelay time.Duration
+	queue      chan finops.Record
+
+	mu       sync.RWMutex
+	mappings []Mapping
+}
+
+// NewBridge returns a bridge for provider ("stripe" or "rest").
+func NewBridge(provider, endpoint, keyFile, stripeMeter string) (*Bridge, error) {
+	switch provider {
+	case ProviderStripe:
+		if endpoint == "" {
+			endpoint = stripeMeterEventsURL
+		}
+		if stripeMeter == "" {
+			return nil, fmt.Errorf("a Stripe meter event name is required")
+		}
+	case ProviderREST:
+	default:
+		return nil, fmt.Errorf("invalid billing provider %q, want %s or %s", provider, ProviderStripe, ProviderREST)
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid billing URL %q", endpoint)
+	}
+	return &Bridge{
+		Provider:    provider,
+		URL:         endpoint,
+		KeyFile:     keyFile,
+		StripeMeter: stripeMeter,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		retryDelay:  time.Second,
+		queue:       make(chan finops.Record, queueSize),
+	}, nil
+}
+
+// Record queues a settlement for delivery. Records are dropped when the
+// queue is full.
+func (b *Bridge) Record(rec finops.Record) {
+	select {
+	case b.queue <- rec:
+	default:
+		slog.Warn("billing queue full, dropping settlement", "route", rec.Namespace+"/"+rec.Route, "transaction", rec.Transaction)
+		metrics.BillingRecordsTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Start implements manager.Runnable. It loads the mapping rules, reloads them
+// every minute and delivers queued records until ctx is cancelled.
+func (b *Bridge) Start(ctx context.Context) error {
+	b.loadMappings(ctx)
+	for range workers {
+		go b.run(ctx)
+	}
+	ticker := time.NewTicker(mappingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.loadMappings(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every gateway
+// replica delivers its own settlements.
+func (b *Bridge) NeedLeaderElection() bool {
+	return false
+}
+
+// loadMappings reads the mapping ConfigMap. On error the previous rules stay
+// in effect.
+func (b *Bridge) loadMappings(ctx context.Context) {
+	var cm corev1.ConfigMap
+	if err := b.Reader.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.ConfigMapName}, &cm); err != nil {
+		slog.Error("failed to read billing mappings", "configmap", b.Namespace+"/"+b.ConfigMapName, "error", err)
+		return
+	}
+	mappings, err := ParseMappings([]byte(cm.Data[mappingKey]))
+	if err != nil {
+		slog.Error("invalid billing mappings, keeping the previous ones", "configmap", b.Namespace+"/"+b.ConfigMapName, "error", err)
+		return
+	}
+	b.mu.Lock()
+	b.mappings = mappings
+	b.mu.Unlock()
+}
+
+// customer returns the billing customer of a settlement, or "".
+func (b *Bridge) customer(rec finops.Record) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, m := range b.mappings {
+		if m.matches(rec) {
+			return m.Customer
+		}
+	}
+	return ""
+}
+
+func (b *Bridge) run(ctx context.Context) {
+	for {
+		select {
+		case rec := <-b.queue:
+			b.deliver(ctx, rec)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver posts a record, retrying failed attempts with linear backoff.
+// Settlements without a matching mapping are skipped.
+func (b *Bridge) deliver(ctx context.Context, rec finops.Record) {
+	customer := b.customer(rec)
+	if customer == "" {
+		metrics.BillingRecordsTotal.WithLabelValues("unmapped").Inc()
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		err := b.post(ctx, customer, rec)
+		if err == nil {
+			metrics.BillingRecordsTotal.WithLabelValues("delivered").Inc()
+			return
+		}
+		if attempt == attempts || errors.Is(err, errRejected) || ctx.Err() != nil {
+			slog.Error("billing delivery failed", "route", rec.Namespace+"/"+rec.Route, "transaction", rec.Transaction, "attempts", attempt, "error", err)
+			metrics.BillingRecordsTotal.WithLabelValues("failed").Inc()
+			return
+		}
+		select {
+		case <-time.After(b.retryDelay * time.Duration(attempt)):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// restRecord is the body POSTed to a REST billing endpoint.
+type restRecord struct {
+	Customer    string    `json:"customer"`
+	Time        time.Time `json:"time"`
+	Namespace   string    `json:"namespace"`
+	Route       string    `json:"route"`
+	Rule        string    `json:"rule"`
+	Resource    string    `json:"resource"`
+	Offer       string    `json:"offer,omitempty"`
+	Variant     string    `json:"variant,omitempty"`
+	Network     string    `json:"network"`
+	Asset       string    `json:"asset,omitempty"`
+	Payer       string    `json:"payer"`
+	Transaction string    `json:"transaction"`
+	Amount      string    `json:"amount"`
+	Decimals    int       `json:"decimals"`
+}
+
+// post sends one record. The transaction hash identifies it, so providers
+// can drop the duplicates of a retried delivery.
+func (b *Bridge) post(ctx context.Context, customer string, rec finops.Record) error {
+	var body []byte
+	var contentType string
+	switch b.Provider {
+	case ProviderStripe:
+		form := url.Values{
+			"event_name":                  {b.StripeMeter},
+			"timestamp":                   {fmt.Sprint(rec.Time.Unix())},
+			"payload[stripe_customer_id]": {customer},
+			"payload[value]":              {rec.Amount},
+		}
+		if rec.Transaction != "" {
+			form.Set("identifier", rec.Transaction)
+		}
+		body, contentType = []byte(form.Encode()), "application/x-www-form-urlencoded"
+	default:
+		var err error
+		body, err = json.Marshal(restRecord{
+			Customer: customer, Time: rec.Time, Namespace: rec.Namespace, Route: rec.Route, Rule: rec.Rule,
+			Resource: rec.Resource, Offer: rec.Offer, Variant: rec.Variant, Network: rec.Network, Asset: rec.Asset,
+			Payer: rec.Payer, Transaction: rec.Transaction, Amount: rec.Amount, Decimals: rec.Decimals,
+		})
+		if err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if rec.Transaction != "" {
+		req.Header.Set("Idempotency-Key", rec.Transaction)
+	}
+	if b.KeyFile != "" {
+		key, err := os.ReadFile(b.KeyFile)
+		if err != nil {
+			return fmt.Errorf("read billing API key: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(key)))
+	}
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("billing endpoint %w the record with status %d: %s", errRejected, resp.StatusCode, strings.TrimSpace(string(msg)))
+	default:
+		return fmt.Errorf("billing endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+}
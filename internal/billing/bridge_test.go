@@ -0,0 +1,168 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/finops"
+)
+
+func testRecord() finops.Record {
+	return finops.Record{
+		Time: time.Unix(1760000000, 0), Namespace: "shop", Route: "orders", Rule: "/api/*", Resource: "/api/x",
+		Network: "eip155:8453", Payer: "0xAbC", Transaction: "0xtx", Amount: "1000", Decimals: 6,
+	}
+}
+
+func TestMappingMatches(t *testing.T) {
+	tests := []struct {
+		mapping Mapping
+		want    bool
+	}{
+		{mapping: Mapping{Customer: "c"}, want: true},
+		{mapping: Mapping{Route: "*", Customer: "c"}, want: true},
+		{mapping: Mapping{Route: "shop/*", Customer: "c"}, want: true},
+		{mapping: Mapping{Route: "shop/orders", Payer: "0xabc", Customer: "c"}, want: true},
+		{mapping: Mapping{Route: "shop/other", Customer: "c"}, want: false},
+		{mapping: Mapping{Route: "default/*", Customer: "c"}, want: false},
+		{mapping: Mapping{Payer: "0xdef", Customer: "c"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.mapping.matches(testRecord()); got != tt.want {
+			t.Errorf("%+v matches = %v, want %v", tt.mapping, got, tt.want)
+		}
+	}
+}
+
+func TestParseMappings(t *testing.T) {
+	mappings, err := ParseMappings([]byte("- route: shop/*\n  payer: \"0xabc\"\n  customer: cus_1\n- customer: cus_default\n"))
+	if err != nil || len(mappings) != 2 || mappings[0].Customer != "cus_1" || mappings[1].Route != "" {
+		t.Fatalf("ParseMappings() = %+v, %v", mappings, err)
+	}
+	if _, err := ParseMappings([]byte("- route: shop/*\n")); err == nil {
+		t.Error("ParseMappings() without customer succeeded")
+	}
+}
+
+func TestBridgeDeliver(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	os.WriteFile(keyFile, []byte("sk_test\n"), 0o600)
+
+	var calls atomic.Int32
+	var status atomic.Int32
+	var got atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Authorization") != "Bearer sk_test" || r.Header.Get("Idempotency-Key") != "0xtx" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if code := status.Load(); code != 0 {
+			w.WriteHeader(int(code))
+			return
+		}
+		if r.Header.Get("Content-Type") == "application/json" {
+			var body restRecord
+			json.NewDecoder(r.Body).Decode(&body)
+			got.Store(body.Customer + " " + body.Amount + " " + body.Payer)
+			return
+		}
+		r.ParseForm()
+		got.Store(r.PostForm.Get("payload[stripe_customer_id]") + " " + r.PostForm.Get("payload[value]") + " " + r.PostForm.Get("event_name") + " " + r.PostForm.Get("identifier"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		provider  string
+		status    int
+		wantCalls int32
+		want      string
+	}{
+		{name: "stripe", provider: ProviderStripe, wantCalls: 1, want: "cus_shop 1000 x402_settlement 0xtx"},
+		{name: "rest", provider: ProviderREST, wantCalls: 1, want: "cus_shop 1000 0xAbC"},
+		{name: "rejected", provider: ProviderREST, status: http.StatusBadRequest, wantCalls: 1},
+		{name: "retried", provider: ProviderREST, status: http.StatusServiceUnavailable, wantCalls: attempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			status.Store(int32(tt.status))
+			got.Store("")
+			b, err := NewBridge(tt.provider, srv.URL, keyFile, "x402_settlement")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.retryDelay = time.Millisecond
+			b.mappings = []Mapping{{Route: "default/*", Customer: "cus_default"}, {Route: "shop/*", Customer: "cus_shop"}}
+			b.deliver(context.Background(), testRecord())
+			if calls.Load() != tt.wantCalls || got.Load() != tt.want {
+				t.Errorf("calls = %d, delivered %q, want %d, %q", calls.Load(), got.Load(), tt.wantCalls, tt.want)
+			}
+		})
+	}
+
+	// Settlements without a mapping are not posted.
+	calls.Store(0)
+	b, _ := NewBridge(ProviderREST, srv.URL, keyFile, "")
+	b.deliver(context.Background(), testRecord())
+	if calls.Load() != 0 {
+		t.Errorf("unmapped settlement posted %d times", calls.Load())
+	}
+}
+
+func TestBridgeLoadMappings(t *testing.T) {
+	scheme := clientgoscheme.Scheme
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "x402-billing-mappings", Namespace: "x402-system"},
+		Data:       map[string]string{mappingKey: "- route: shop/orders\n  customer: cus_1\n"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build()
+	b, _ := NewBridge(ProviderStripe, "", "", "x402_settlement")
+	b.Reader, b.Namespace, b.ConfigMapName = c, "x402-system", "x402-billing-mappings"
+	ctx := context.Background()
+
+	b.loadMappings(ctx)
+	if got := b.customer(testRecord()); got != "cus_1" {
+		t.Fatalf("customer = %q, want cus_1", got)
+	}
+
+	// Invalid rules keep the previous ones in effect.
+	cm.Data[mappingKey] = "- route: shop/orders\n"
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	b.loadMappings(ctx)
+	if got := b.customer(testRecord()); got != "cus_1" {
+		t.Errorf("customer after invalid update = %q, want cus_1", got)
+	}
+}
+
+func TestNewBridgeValidation(t *testing.T) {
+	tests := []struct {
+		name, provider, url, meter string
+	}{
+		{name: "unknown provider", provider: "paypal", url: "https://billing.example.com"},
+		{name: "rest without url", provider: ProviderREST},
+		{name: "stripe without meter", provider: ProviderStripe},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBridge(tt.provider, tt.url, "", tt.meter); err == nil {
+				t.Error("NewBridge() succeeded")
+			}
+		})
+	}
+}
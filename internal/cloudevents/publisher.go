@@ -0,0 +1,186 @@
+// Package cloudevents publishes lifecycle changes of X402Routes as
+// CloudEvents to an HTTP sink, such as a Knative broker, so automation can
+// react to pricing changes without watching the API server. Events are sent
+// in structured mode: one JSON document per POST with the content type
+// application/cloudevents+json.
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+)
+
+// Event types.
+const (
+	RouteCreated   = "io.x402.route.created"
+	RouteUpdated   = "io.x402.route.updated"
+	RouteDeleted   = "io.x402.route.deleted"
+	IngressPatched = "io.x402.route.ingress.patched"
+	// IngressRestored is sent when a deleted route points the paid paths of
+	// its Ingress back at their original backends.
+	IngressRestored = "io.x402.route.ingress.restored"
+	CompileFailed   = "io.x402.route.compile.failed"
+)
+
+const (
+	queueSize = 1024
+	attempts  = 5
+)
+
+// errRejected marks an event the sink refused outright; it is not retried.
+var errRejected = errors.New("rejected")
+
+// RouteData is the data of every event.
+type RouteData struct {
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	// Ingress is "namespace/name" of the Ingress the route gates.
+	Ingress string `json:"ingress"`
+	Rules   int    `json:"rules,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// event is the structured-mode representation of a CloudEvent.
+type event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            RouteData `json:"data"`
+}
+
+// Publisher posts events to a sink. Events are delivered in order in the
+// background; Publish never blocks.
+type Publisher struct {
+	URL    string
+	Source string
+
+	httpClient *http.Client
+	retryDelay time.Duration
+	queue      chan event
+}
+
+// NewPublisher returns a publisher posting to sink, with source as the
+// source attribute of its events.
+func NewPublisher(sink, source string) (*Publisher, error) {
+	if u, err := url.Parse(sink); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid CloudEvents sink %q", sink)
+	}
+	if source == "" {
+		return nil, errors.New("a CloudEvents source is required")
+	}
+	return &Publisher{
+		URL:        sink,
+		Source:     source,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		retryDelay: time.Second,
+		queue:      make(chan event, queueSize),
+	}, nil
+}
+
+// Publish queues an event of eventType about a route. Events are dropped
+// when the queue is full.
+func (p *Publisher) Publish(eventType string, data RouteData) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	ev := event{
+		SpecVersion:     "1.0",
+		ID:              hex.EncodeToString(id),
+		Source:          p.Source,
+		Type:            eventType,
+		Subject:         fmt.Sprintf("/apis/x402.io/v1alpha1/namespaces/%s/x402routes/%s", data.Namespace, data.Name),
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	select {
+	case p.queue <- ev:
+	default:
+		slog.Warn("CloudEvents queue full, dropping event", "type", eventType, "route", data.Namespace+"/"+data.Name)
+		metrics.CloudEventsTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Start implements manager.Runnable. It delivers queued events until ctx is
+// cancelled.
+func (p *Publisher) Start(ctx context.Context) error {
+	for {
+		select {
+		case ev := <-p.queue:
+			p.deliver(ctx, ev)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the
+// leader reconciles, so other replicas queue nothing.
+func (p *Publisher) NeedLeaderElection() bool {
+	return false
+}
+
+// deliver posts an event, retrying failed attempts with linear backoff.
+func (p *Publisher) deliver(ctx context.Context, ev event) {
+	for attempt := 1; ; attempt++ {
+		err := p.post(ctx, ev)
+		if err == nil {
+			metrics.CloudEventsTotal.WithLabelValues("delivered").Inc()
+			return
+		}
+		if attempt == attempts || errors.Is(err, errRejected) || ctx.Err() != nil {
+			slog.Error("CloudEvent delivery failed", "type", ev.Type, "subject", ev.Subject, "attempts", attempt, "error", err)
+			metrics.CloudEventsTotal.WithLabelValues("failed").Inc()
+			return
+		}
+		select {
+		case <-time.After(p.retryDelay * time.Duration(attempt)):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// post sends one event. Its id stays the same across retries, so sinks can
+// drop the duplicates of a retried delivery.
+func (p *Publisher) post(ctx context.Context, ev event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("CloudEvents sink %w the event with status %d: %s", errRejected, resp.StatusCode, strings.TrimSpace(string(msg)))
+	default:
+		return fmt.Errorf("CloudEvents sink returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+}
@@ -0,0 +1,88 @@
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewPublisher(t *testing.T) {
+	if _, err := NewPublisher("ftp://broker", "x402"); err == nil {
+		t.Error("NewPublisher() with an ftp sink succeeded")
+	}
+	if _, err := NewPublisher("http://broker", ""); err == nil {
+		t.Error("NewPublisher() without source succeeded")
+	}
+}
+
+func TestPublisherDeliver(t *testing.T) {
+	var calls atomic.Int32
+	events := make(chan event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/cloudevents+json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var ev event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		events <- ev
+	}))
+	defer srv.Close()
+
+	p, err := NewPublisher(srv.URL, "x402-k8s-operator")
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	p.retryDelay = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Start(ctx)
+
+	p.Publish(RouteUpdated, RouteData{Namespace: "shop", Name: "orders", Generation: 2, Ingress: "shop/orders", Rules: 3})
+	select {
+	case ev := <-events:
+		if ev.SpecVersion != "1.0" || ev.Type != RouteUpdated || ev.Source != "x402-k8s-operator" || ev.ID == "" {
+			t.Errorf("event = %+v", ev)
+		}
+		if want := "/apis/x402.io/v1alpha1/namespaces/shop/x402routes/orders"; ev.Subject != want {
+			t.Errorf("subject = %q, want %q", ev.Subject, want)
+		}
+		if ev.Data.Generation != 2 || ev.Data.Rules != 3 {
+			t.Errorf("data = %+v", ev.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("sink called %d times, want a retry after the 503", n)
+	}
+}
+
+func TestPublisherRejected(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	p, err := NewPublisher(srv.URL, "x402-k8s-operator")
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	p.retryDelay = time.Millisecond
+	p.Publish(CompileFailed, RouteData{Namespace: "shop", Name: "orders", Error: "bad"})
+	p.deliver(context.Background(), <-p.queue)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("sink called %d times, want 1 for a rejected event", n)
+	}
+}
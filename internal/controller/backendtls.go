@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// backendTLSResync is how often routes with a spec.backendTLS CA Secret are
+// reconciled to pick up a rotated CA bundle.
+const backendTLSResync = 5 * time.Minute
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// resolveBackendTLS sets the TLS configuration of the backends listed in
+// spec.backendTLS, reading their CA bundles from Secrets in the Ingress
+// namespace. Listed backends not already spoken to over TLS get HTTPS.
+func (r *X402RouteReconciler) resolveBackendTLS(ctx context.Context, route *x402v1alpha1.X402Route, namespace string, backends []routestore.CompiledBackend) error {
+	if len(route.Spec.BackendTLS) == 0 {
+		return nil
+	}
+	configs := make(map[string]*routestore.CompiledBackendTLS, len(route.Spec.BackendTLS))
+	for _, bt := range route.Spec.BackendTLS {
+		compiled := &routestore.CompiledBackendTLS{
+			ServerName:         bt.ServerName,
+			InsecureSkipVerify: bt.InsecureSkipVerify,
+		}
+		if compiled.ServerName == "" {
+			compiled.ServerName = fmt.Sprintf("%s.%s.svc", bt.Service, namespace)
+		}
+		if ref := bt.CASecretRef; ref != nil {
+			ca, err := r.readCABundle(ctx, namespace, ref)
+			if err != nil {
+				return fmt.Errorf("backendTLS of %s: %w", bt.Service, err)
+			}
+			compiled.CA = ca
+		}
+		configs[bt.Service] = compiled
+	}
+
+	for i := range backends {
+		b := &backends[i]
+		tls, ok := configs[b.Service]
+		if !ok {
+			continue
+		}
+		b.TLS = tls
+		if !tlsProtocol(b.Protocol) {
+			b.Protocol = "https"
+		}
+		b.URL = strings.Replace(b.URL, "http://", "https://", 1)
+	}
+	return nil
+}
+
+// readCABundle reads the PEM CA bundle a CASecretRef names and checks it
+// holds at least one certificate.
+func (r *X402RouteReconciler) readCABundle(ctx context.Context, namespace string, ref *x402v1alpha1.CASecretRef) ([]byte, error) {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	var secret corev1.Secret
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("read CA Secret %s: %w", ref.Name, err)
+	}
+	key := ref.Key
+	if key == "" {
+		key = "ca.crt"
+	}
+	ca := secret.Data[key]
+	if len(ca) == 0 {
+		return nil, fmt.Errorf("CA Secret %s has no key %q", ref.Name, key)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("key %q of CA Secret %s holds no PEM certificate", key, ref.Name)
+	}
+	return ca, nil
+}
+
+// hasBackendCASecrets reports whether route reads a CA bundle from a Secret.
+func hasBackendCASecrets(route *x402v1alpha1.X402Route) bool {
+	for _, bt := range route.Spec.BackendTLS {
+		if bt.CASecretRef != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestResolveBackendTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	r := &X402RouteReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "api-ca", Namespace: "default"}, Data: map[string][]byte{"ca.crt": ca}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "not-a-ca", Namespace: "default"}, Data: map[string][]byte{"ca.crt": []byte("nope")}},
+	).Build()}
+
+	route := newTestRoute()
+	route.Spec.BackendTLS = []x402v1alpha1.BackendTLS{
+		{Service: "api", CASecretRef: &x402v1alpha1.CASecretRef{Name: "api-ca"}},
+		{Service: "grpc", ServerName: "grpc.internal.example", InsecureSkipVerify: true},
+	}
+	backends := []routestore.CompiledBackend{
+		{Path: "/api", Service: "api", Port: 8443, URL: "http://api.default.svc.cluster.local:8443"},
+		{Path: "/grpc", Service: "grpc", Port: 9443, URL: "https://grpc.default.svc.cluster.local:9443", Protocol: "h2"},
+		{Path: "/web", Service: "web", Port: 8080, URL: "http://web.default.svc.cluster.local:8080"},
+	}
+	if err := r.resolveBackendTLS(context.Background(), route, "default", backends); err != nil {
+		t.Fatal(err)
+	}
+
+	api := backends[0]
+	if api.Protocol != "https" || api.URL != "https://api.default.svc.cluster.local:8443" {
+		t.Errorf("api backend = %q %s, want https", api.Protocol, api.URL)
+	}
+	if api.TLS == nil || string(api.TLS.CA) != string(ca) || api.TLS.ServerName != "api.default.svc" {
+		t.Errorf("api TLS = %+v", api.TLS)
+	}
+	grpc := backends[1]
+	if grpc.Protocol != "h2" || grpc.TLS == nil || !grpc.TLS.InsecureSkipVerify || grpc.TLS.ServerName != "grpc.internal.example" {
+		t.Errorf("grpc backend = %q %+v", grpc.Protocol, grpc.TLS)
+	}
+	if backends[2].TLS != nil || backends[2].Protocol != "" {
+		t.Errorf("unlisted backend changed: %+v", backends[2])
+	}
+
+	for name, ref := range map[string]*x402v1alpha1.CASecretRef{
+		"missing Secret": {Name: "missing"},
+		"missing key":    {Name: "api-ca", Key: "bundle.pem"},
+		"not PEM":        {Name: "not-a-ca"},
+	} {
+		route.Spec.BackendTLS = []x402v1alpha1.BackendTLS{{Service: "api", CASecretRef: ref}}
+		err := r.resolveBackendTLS(context.Background(), route, "default", []routestore.CompiledBackend{{Service: "api"}})
+		if err == nil || !strings.Contains(err.Error(), "backendTLS of api") {
+			t.Errorf("%s: error = %v", name, err)
+		}
+	}
+}
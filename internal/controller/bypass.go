@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// bypassIngressSuffix names the canary Ingress created next to a patched Ingress.
+	bypassIngressSuffix = "-x402-bypass"
+
+	annotationBypassFor         = "x402.io/bypass-for"
+	annotationNginxCanary       = "nginx.ingress.kubernetes.io/canary"
+	annotationNginxCanaryWeight = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+// bypassIngressName returns the name of the canary Ingress for an Ingress.
+func bypassIngressName(ingress string) string {
+	return ingress + bypassIngressSuffix
+}
+
+// bypassPaths returns the gated paths of a patched Ingress, by host, pointing
+// at their original backends. Paths without a recorded original are skipped.
+func (r *X402RouteReconciler) bypassPaths(ingress *networkingv1.Ingress) ([]networkingv1.IngressRule, error) {
+	var originals map[string]string
+	if raw, ok := ingress.Annotations[annotationOriginalBackends]; ok {
+		if err := json.Unmarshal([]byte(raw), &originals); err != nil {
+			return nil, fmt.Errorf("unmarshal original backends: %w", err)
+		}
+	}
+
+	var rules []networkingv1.IngressRule
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		var paths []networkingv1.HTTPIngressPath
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service == nil || !r.isGatewayService(p.Backend.Service.Name) {
+				continue
+			}
+			original, ok := parseServiceBackend(originals[p.Path])
+			if !ok {
+				continue
+			}
+			pathType := pathTypeOf(p)
+			paths = append(paths, networkingv1.HTTPIngressPath{Path: p.Path, PathType: &pathType, Backend: original})
+		}
+		if len(paths) > 0 {
+			rules = append(rules, networkingv1.IngressRule{
+				Host:             rule.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths}},
+			})
+		}
+	}
+	return rules, nil
+}
+
+// reconcileBypass keeps the canary Ingress that sends spec.bypassPercent of the
+// gated traffic straight to the original backends in line with the patched
+// Ingress, and removes it when the bypass is off.
+func (r *X402RouteReconciler) reconcileBypass(ctx context.Context, route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress) error {
+	if route.Spec.BypassPercent == 0 {
+		return r.deleteBypass(ctx, route, ingress.Namespace)
+	}
+	rules, err := r.bypassPaths(ingress)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return r.deleteBypass(ctx, route, ingress.Namespace)
+	}
+
+	owner := route.Namespace + "/" + route.Name
+	canary := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: bypassIngressName(ingress.Name), Namespace: ingress.Namespace}}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, canary, func() error {
+		if canary.ResourceVersion != "" && canary.Annotations[annotationBypassFor] != owner {
+			return fmt.Errorf("ingress %s/%s exists and is not the bypass of %s", canary.Namespace, canary.Name, owner)
+		}
+		canary.Labels = map[string]string{
+			"app.kubernetes.io/managed-by": "x402-operator",
+		}
+		canary.Annotations = map[string]string{
+			annotationBypassFor:         owner,
+			annotationNginxCanary:       "true",
+			annotationNginxCanaryWeight: strconv.Itoa(int(route.Spec.BypassPercent)),
+		}
+		canary.Spec = networkingv1.IngressSpec{
+			IngressClassName: ingress.Spec.IngressClassName,
+			Rules:            rules,
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reconcile bypass ingress: %w", err)
+	}
+
+	log.FromContext(ctx).Info("bypass ingress reconciled", "name", canary.Name, "percent", route.Spec.BypassPercent, "operation", op)
+	return nil
+}
+
+// deleteBypass removes the route's canary Ingress, if any.
+func (r *X402RouteReconciler) deleteBypass(ctx context.Context, route *x402v1alpha1.X402Route, namespace string) error {
+	canary := &networkingv1.Ingress{}
+	key := client.ObjectKey{Name: bypassIngressName(route.Spec.IngressRef.Name), Namespace: namespace}
+	if err := r.Get(ctx, key, canary); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if canary.Annotations[annotationBypassFor] != route.Namespace+"/"+route.Name {
+		return nil
+	}
+	if err := r.Delete(ctx, canary); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete bypass ingress: %w", err)
+	}
+	log.FromContext(ctx).Info("bypass ingress deleted", "name", canary.Name)
+	return nil
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestReconcileBypass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	canaryKey := types.NamespacedName{Namespace: "default", Name: "my-api-ingress" + bypassIngressSuffix}
+
+	route := newTestRoute()
+	route.Name, route.Namespace = "my-api", "default"
+	route.Spec.IngressRef.Name = "my-api-ingress"
+	route.Spec.BypassPercent = 20
+
+	r := &X402RouteReconciler{
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+	ingress := newTestIngress()
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(ingress).Build()
+
+	if err := r.reconcileBypass(ctx, route, ingress); err != nil {
+		t.Fatalf("reconcileBypass() error = %v", err)
+	}
+	var canary networkingv1.Ingress
+	if err := r.Get(ctx, canaryKey, &canary); err != nil {
+		t.Fatalf("get bypass ingress: %v", err)
+	}
+	if canary.Annotations[annotationNginxCanary] != "true" || canary.Annotations[annotationNginxCanaryWeight] != "20" {
+		t.Errorf("canary annotations = %v", canary.Annotations)
+	}
+	if *canary.Spec.IngressClassName != "nginx" || len(canary.Spec.Rules) != 2 {
+		t.Fatalf("canary spec = %+v, want both hosts of the nginx Ingress", canary.Spec)
+	}
+	for _, rule := range canary.Spec.Rules {
+		p := rule.HTTP.Paths[0]
+		if p.Path != "/" || p.Backend.Service.Name != "my-api" || p.Backend.Service.Port.Number != 8080 {
+			t.Errorf("host %s bypass path = %s -> %+v, want / -> my-api:8080", rule.Host, p.Path, p.Backend.Service)
+		}
+	}
+
+	// Setting the percentage back to 0 removes the canary.
+	route.Spec.BypassPercent = 0
+	if err := r.reconcileBypass(ctx, route, ingress); err != nil {
+		t.Fatalf("reconcileBypass() error = %v", err)
+	}
+	if err := r.Get(ctx, canaryKey, &canary); !apierrors.IsNotFound(err) {
+		t.Errorf("bypass ingress after disabling: err = %v, want NotFound", err)
+	}
+
+	// An Ingress the route does not own is neither overwritten nor deleted.
+	foreign := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: canaryKey.Name, Namespace: "default"}}
+	if err := r.Create(ctx, foreign); err != nil {
+		t.Fatal(err)
+	}
+	route.Spec.BypassPercent = 50
+	if err := r.reconcileBypass(ctx, route, ingress); err == nil {
+		t.Error("reconcileBypass() over a foreign Ingress succeeded")
+	}
+	if err := r.deleteBypass(ctx, route, "default"); err != nil {
+		t.Fatalf("deleteBypass() error = %v", err)
+	}
+	if err := r.Get(ctx, canaryKey, &canary); err != nil || canary.Annotations[annotationNginxCanary] != "" {
+		t.Errorf("foreign ingress changed: err = %v, annotations = %v", err, canary.Annotations)
+	}
+}
+
+func TestCleanupDeletesBypass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+
+	route := newTestRoute()
+	route.Name, route.Namespace = "my-api", "default"
+	route.Spec.IngressRef.Name = "my-api-ingress"
+	route.Spec.BypassPercent = 10
+	route.Spec.DeletionPolicy = "abandon"
+
+	r := &X402RouteReconciler{
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+	ingress := newTestIngress()
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(ingress).Build()
+	if err := r.reconcileBypass(ctx, route, ingress); err != nil {
+		t.Fatalf("reconcileBypass() error = %v", err)
+	}
+
+	if err := r.cleanupResources(ctx, route); err != nil {
+		t.Fatalf("cleanupResources() error = %v", err)
+	}
+	var canary networkingv1.Ingress
+	key := types.NamespacedName{Namespace: "default", Name: "my-api-ingress" + bypassIngressSuffix}
+	if err := r.Get(ctx, key, &canary); !apierrors.IsNotFound(err) {
+		t.Errorf("bypass ingress after cleanup: err = %v, want NotFound", err)
+	}
+}
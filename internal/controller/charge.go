@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+// ChargePolicy is the operator-wide minimum charge and price increment, in
+// tokens, used by routes that set neither. Empty fields are unset.
+type ChargePolicy struct {
+	MinimumCharge  string
+	PriceIncrement string
+}
+
+// Validate checks that the set amounts are valid token amounts.
+func (p ChargePolicy) Validate() error {
+	if _, err := parseTokenAmount(p.MinimumCharge); err != nil {
+		return fmt.Errorf("minimum charge: %w", err)
+	}
+	if _, err := parseTokenAmount(p.PriceIncrement); err != nil {
+		return fmt.Errorf("price increment: %w", err)
+	}
+	return nil
+}
+
+// compileCharge returns the minimum charge and price increment of a route,
+// falling back to the operator's policy. Nil means unset.
+func (r *X402RouteReconciler) compileCharge(payment *x402v1alpha1.PaymentDefaults) (minimum, increment *big.Rat, err error) {
+	minStr, incStr := payment.MinimumCharge, payment.PriceIncrement
+	if minStr == "" {
+		minStr = r.Charge.MinimumCharge
+	}
+	if incStr == "" {
+		incStr = r.Charge.PriceIncrement
+	}
+	if minimum, err = parseTokenAmount(minStr); err != nil {
+		return nil, nil, fmt.Errorf("invalid minimum charge: %w", err)
+	}
+	if increment, err = parseTokenAmount(incStr); err != nil {
+		return nil, nil, fmt.Errorf("invalid price increment: %w", err)
+	}
+	return minimum, increment, nil
+}
+
+// tokenAmount matches a decimal token amount.
+var tokenAmount = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// parseTokenAmount parses a positive token amount such as "0.0001". An empty
+// amount is nil.
+func parseTokenAmount(s string) (*big.Rat, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !tokenAmount.MatchString(s) {
+		return nil, fmt.Errorf("%q is not a positive token amount", s)
+	}
+	amount, _ := new(big.Rat).SetString(s)
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%q is not a positive token amount", s)
+	}
+	return amount, nil
+}
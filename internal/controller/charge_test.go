@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"math/big"
+	"testing"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestCompileCharge(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        ChargePolicy
+		payment       x402v1alpha1.PaymentDefaults
+		wantMinimum   *big.Rat
+		wantIncrement *big.Rat
+		wantErr       bool
+	}{
+		{name: "unset"},
+		{
+			name:          "operator policy",
+			policy:        ChargePolicy{MinimumCharge: "0.001", PriceIncrement: "0.0001"},
+			wantMinimum:   big.NewRat(1, 1000),
+			wantIncrement: big.NewRat(1, 10000),
+		},
+		{
+			name:          "route overrides policy",
+			policy:        ChargePolicy{MinimumCharge: "0.001", PriceIncrement: "0.0001"},
+			payment:       x402v1alpha1.PaymentDefaults{MinimumCharge: "0.01"},
+			wantMinimum:   big.NewRat(1, 100),
+			wantIncrement: big.NewRat(1, 10000),
+		},
+		{name: "zero increment", payment: x402v1alpha1.PaymentDefaults{PriceIncrement: "0"}, wantErr: true},
+		{name: "fiat minimum", payment: x402v1alpha1.PaymentDefaults{MinimumCharge: "$0.01 USD"}, wantErr: true},
+		{name: "fraction", payment: x402v1alpha1.PaymentDefaults{MinimumCharge: "1/3"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &X402RouteReconciler{Charge: tt.policy}
+			minimum, increment, err := r.compileCharge(&tt.payment)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileCharge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !ratEqual(minimum, tt.wantMinimum) || !ratEqual(increment, tt.wantIncrement) {
+				t.Errorf("compileCharge() = %v, %v, want %v, %v", minimum, increment, tt.wantMinimum, tt.wantIncrement)
+			}
+		})
+	}
+
+	if err := (ChargePolicy{MinimumCharge: "abc"}).Validate(); err == nil {
+		t.Error("Validate() of an invalid minimum charge: error = nil")
+	}
+}
+
+func ratEqual(a, b *big.Rat) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestCleanupDeletionPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+
+	tests := []struct {
+		name        string
+		policy      string
+		wantBackend string
+		wantService bool
+	}{
+		{name: "default", policy: "", wantBackend: "my-api", wantService: false},
+		{name: "restore", policy: "restore", wantBackend: "my-api", wantService: false},
+		{name: "abandon", policy: "abandon", wantBackend: externalSvcName, wantService: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestRoute()
+			route.Name, route.Namespace = "my-api", "default"
+			route.Spec.IngressRef.Name = "my-api-ingress"
+			route.Spec.DeletionPolicy = tt.policy
+
+			r := &X402RouteReconciler{
+				RouteStore:        routestore.New(),
+				OperatorNamespace: "x402-system",
+				OperatorSvcName:   "x402-k8s-operator",
+			}
+			ingress := newTestIngress()
+			if err := r.applyGatewayPatch(route, ingress); err != nil {
+				t.Fatalf("applyGatewayPatch() error = %v", err)
+			}
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: externalSvcName, Namespace: "default"}}
+			r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(ingress, svc).Build()
+
+			if err := r.cleanupResources(context.Background(), route); err != nil {
+				t.Fatalf("cleanupResources() error = %v", err)
+			}
+
+			var got networkingv1.Ingress
+			if err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "my-api-ingress"}, &got); err != nil {
+				t.Fatal(err)
+			}
+			if name := got.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name; name != tt.wantBackend {
+				t.Errorf("gated path backend = %q, want %q", name, tt.wantBackend)
+			}
+			err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: externalSvcName}, &corev1.Service{})
+			if exists := !apierrors.IsNotFound(err); exists != tt.wantService {
+				t.Errorf("ExternalName Service exists = %v, want %v", exists, tt.wantService)
+			}
+		})
+	}
+}
+
+func TestCleanupRetryAndForce(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+
+	route := newTestRoute()
+	route.Name, route.Namespace = key.Name, key.Namespace
+	route.Spec.IngressRef.Name = "my-api-ingress"
+	route.Finalizers = []string{finalizerName}
+	now := metav1.Now()
+	route.DeletionTimestamp = &now
+
+	r := &X402RouteReconciler{
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+	ingress := newTestIngress()
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	r.RouteStore.Set(key.Namespace, key.Name, &routestore.CompiledRoute{Name: key.Name})
+	recorder := events.NewFakeRecorder(1)
+	r.Recorder = recorder
+	r.Client = fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(route, ingress).WithStatusSubresource(route).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if _, ok := obj.(*networkingv1.Ingress); ok {
+					return errors.New("admission webhook denied the request")
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).Build()
+
+	cleanup := func() *x402v1alpha1.CleanupStatus {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err == nil {
+			t.Fatal("Reconcile() error = nil, want restore failure")
+		}
+		var got x402v1alpha1.X402Route
+		if err := r.Get(ctx, key, &got); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return got.Status.Cleanup
+	}
+
+	for range forceCleanupAfter {
+		cleanup()
+	}
+	progress := cleanup()
+	if progress == nil || progress.Failures != forceCleanupAfter+1 || progress.IngressRestored || !progress.StoreRemoved || !progress.BypassRemoved || !progress.ServiceCleaned {
+		t.Fatalf("cleanup status = %+v, want only the Ingress restoration pending", progress)
+	}
+	if !strings.Contains(progress.LastError, "admission webhook denied") {
+		t.Errorf("cleanup lastError = %q", progress.LastError)
+	}
+	if r.RouteStore.Get(key.Namespace, key.Name) != nil {
+		t.Error("route still in store")
+	}
+
+	var current x402v1alpha1.X402Route
+	if err := r.Get(ctx, key, &current); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	current.Annotations = map[string]string{annotationForceCleanup: "true"}
+	if err := r.Update(ctx, &current); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() with force-cleanup error = %v", err)
+	}
+	if err := r.Get(ctx, key, &current); !apierrors.IsNotFound(err) {
+		t.Errorf("route still exists after forced cleanup: %v", err)
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.Contains(e, "ForcedCleanup") {
+			t.Errorf("event = %q, want ForcedCleanup", e)
+		}
+	default:
+		t.Error("no ForcedCleanup event")
+	}
+}
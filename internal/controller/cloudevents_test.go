@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/cloudevents"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestReconcilePublishesCloudEvents(t *testing.T) {
+	eventTypes := make(chan string, 16)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev struct {
+			Type string `json:"type"`
+		}
+		json.NewDecoder(r.Body).Decode(&ev)
+		eventTypes <- ev.Type
+	}))
+	defer sink.Close()
+	publisher, err := cloudevents.NewPublisher(sink.URL, "test")
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go publisher.Start(ctx)
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+	route := newTestRoute()
+	route.Name, route.Namespace = key.Name, key.Namespace
+	route.Spec.IngressRef.Name = "my-api-ingress"
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(route, newTestIngress()).WithStatusSubresource(route).Build()
+	r := &X402RouteReconciler{
+		Client:            c,
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+		CloudEvents:       publisher,
+	}
+
+	// Reconcile twice: the second pass changes nothing and sends nothing.
+	for range 2 {
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case typ := <-eventTypes:
+			got = append(got, typ)
+		case <-timeout:
+			t.Fatalf("events = %v, want created and ingress patched", got)
+		}
+	}
+	if want := []string{cloudevents.RouteCreated, cloudevents.IngressPatched}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	select {
+	case typ := <-eventTypes:
+		t.Errorf("unexpected event %s", typ)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// compileInputs returns a short, stable identifier for everything a route is
+// compiled from: its spec and generation, and the hosts and resolved backends
+// of its Ingress. Ingress status updates leave it unchanged.
+func compileInputs(route *x402v1alpha1.X402Route, backends []routestore.CompiledBackend, ingress *networkingv1.Ingress) string {
+	hosts := make([]string, 0, len(ingress.Spec.Rules))
+	for _, rule := range ingress.Spec.Rules {
+		hosts = append(hosts, rule.Host)
+	}
+	var tlsHosts []string
+	for _, tls := range ingress.Spec.TLS {
+		tlsHosts = append(tlsHosts, tls.Hosts...)
+	}
+	raw, err := json.Marshal(struct {
+		Spec       string
+		Generation int64
+		Namespace  string
+		Hosts      []string
+		TLSHosts   []string
+		Backends   []routestore.CompiledBackend
+	}{specHash(route), route.Generation, ingress.Namespace, hosts, tlsHosts, backends})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// compileCache remembers the inputs each route was last compiled from, so
+// reconciles that change none of them reuse the stored route. The zero value
+// is ready to use.
+type compileCache struct {
+	mu     sync.Mutex
+	inputs map[types.NamespacedName]string
+}
+
+// lookup returns the stored route of key when it was compiled from inputs.
+// A paused stored route is never reused, so resuming recompiles it.
+func (c *compileCache) lookup(store *routestore.Store, key types.NamespacedName, inputs string) *routestore.CompiledRoute {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if inputs == "" || c.inputs[key] != inputs {
+		return nil
+	}
+	if stored := store.Get(key.Namespace, key.Name); stored != nil && !stored.Paused {
+		return stored
+	}
+	return nil
+}
+
+// record notes the inputs the stored route of key was compiled from.
+func (c *compileCache) record(key types.NamespacedName, inputs string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inputs == nil {
+		c.inputs = make(map[types.NamespacedName]string)
+	}
+	c.inputs[key] = inputs
+}
+
+// forget drops key, whose stored route was removed.
+func (c *compileCache) forget(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inputs, key)
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestReconcileCompileCache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+	ingressKey := types.NamespacedName{Namespace: "default", Name: "my-api-ingress"}
+
+	route := newTestRoute()
+	route.Name, route.Namespace = key.Name, key.Namespace
+	route.Finalizers = []string{finalizerName}
+	route.Spec.IngressRef.Name = ingressKey.Name
+	route.Spec.Payment.Wallet = "0x1234567890abcdef1234567890abcdef12345678"
+	ingress := newTestIngress()
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(route, ingress).WithStatusSubresource(route, ingress).Build()
+	r := &X402RouteReconciler{
+		Client:            c,
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+	reconcile := func() *routestore.CompiledRoute {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		compiled := r.RouteStore.Get(key.Namespace, key.Name)
+		if compiled == nil {
+			t.Fatal("route not in store")
+		}
+		return compiled
+	}
+
+	// The first reconcile patches the Ingress, which splits out the free
+	// path and changes the backends the route is compiled from.
+	reconcile()
+	first := reconcile()
+	if got := reconcile(); got != first {
+		t.Error("unchanged route was recompiled")
+	}
+
+	// An Ingress status update changes neither the spec nor the backends.
+	var ing networkingv1.Ingress
+	if err := c.Get(ctx, ingressKey, &ing); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	ing.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}
+	if err := c.Status().Update(ctx, &ing); err != nil {
+		t.Fatalf("Status().Update() error = %v", err)
+	}
+	if got := reconcile(); got != first {
+		t.Error("route was recompiled after an Ingress status update")
+	}
+
+	var current x402v1alpha1.X402Route
+	if err := c.Get(ctx, key, &current); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	current.Spec.Payment.DefaultPrice = "0.02"
+	if err := c.Update(ctx, &current); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	second := reconcile()
+	if second == first {
+		t.Fatal("route was not recompiled after a spec change")
+	}
+
+	// A route removed from the store is compiled again.
+	r.RouteStore.Delete(key.Namespace, key.Name)
+	if got := reconcile(); got == second {
+		t.Error("removed route was not recompiled")
+	}
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// compilerVersion identifies the compile rules of this build. Bump it whenever
+// an unchanged X402Route spec compiles to different gateway behavior.
+const compilerVersion = 3
+
+// conditionBehaviorChanged reports a route whose behavior was changed by an
+// operator upgrade rather than by its spec.
+const conditionBehaviorChanged = "BehaviorChanged"
+
+// specHash returns a short, stable identifier for the route's spec.
+func specHash(route *x402v1alpha1.X402Route) string {
+	raw, err := json.Marshal(route.Spec)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// compiledHash returns a short, stable identifier for the gateway behavior of
+// a compiled route. Hosts and backends come from the Ingress and the pause
+// from annotations, and are left out, so only the spec and the compile rules
+// affect it.
+func compiledHash(compiled *routestore.CompiledRoute) string {
+	c := *compiled
+	c.Generation, c.Hosts, c.TLSHosts, c.Backends, c.CompilerVersion, c.Paused = 0, nil, nil, nil, 0, false
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// behaviorChanged reports whether this compiler compiles the unchanged spec of
+// a route differently than the compiler version recorded in its status.
+func behaviorChanged(route *x402v1alpha1.X402Route, spec, out string) bool {
+	st := route.Status
+	return st.CompilerVersion != 0 && st.CompilerVersion != compilerVersion && st.SpecHash == spec && st.CompiledHash != out
+}
+
+// recordCompilation stamps the route status with the compiler version and
+// hashes. When a new compiler version compiles an unchanged spec differently,
+// it sets the BehaviorChanged condition and emits a Warning event; the
+// condition clears on the next spec change.
+func (r *X402RouteReconciler) recordCompilation(route *x402v1alpha1.X402Route, compiled *routestore.CompiledRoute) {
+	spec, out := specHash(route), compiledHash(compiled)
+	st := &route.Status
+	switch {
+	case behaviorChanged(route, spec, out):
+		msg := fmt.Sprintf("Operator compiler version %d compiles the unchanged spec differently than version %d; review the route", compilerVersion, st.CompilerVersion)
+		r.setCondition(route, conditionBehaviorChanged, metav1.ConditionTrue, "CompilerUpgraded", msg)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(route, nil, corev1.EventTypeWarning, "CompiledBehaviorChanged", "Recompile", msg)
+		}
+	case st.SpecHash != spec:
+		meta.RemoveStatusCondition(&st.Conditions, conditionBehaviorChanged)
+	}
+	st.CompilerVersion, st.SpecHash, st.CompiledHash = compilerVersion, spec, out
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/events"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestCompiledHashIgnoresIngress(t *testing.T) {
+	compiled := &routestore.CompiledRoute{
+		Name:   "my-api",
+		Wallet: "0xTestWallet",
+		Rules:  []routestore.CompiledRule{{Path: "/api/*", Price: "0.01", Mode: "all-pay"}},
+	}
+	base := compiledHash(compiled)
+	if base == "" {
+		t.Fatal("compiledHash() = \"\"")
+	}
+
+	moved := *compiled
+	moved.Generation = 7
+	moved.Hosts = []string{"api.example.com"}
+	moved.TLSHosts = []string{"api.example.com"}
+	moved.Backends = []routestore.CompiledBackend{{Path: "/", URL: "http://my-api.default.svc:8080"}}
+	if got := compiledHash(&moved); got != base {
+		t.Errorf("hash changed with Ingress-derived fields: %s != %s", got, base)
+	}
+
+	repriced := *compiled
+	repriced.Rules = []routestore.CompiledRule{{Path: "/api/*", Price: "0.02", Mode: "all-pay"}}
+	if got := compiledHash(&repriced); got == base {
+		t.Error("hash unchanged after a price change")
+	}
+}
+
+func TestRecordCompilation(t *testing.T) {
+	compiled := &routestore.CompiledRoute{Rules: []routestore.CompiledRule{{Path: "/api/*", Price: "0.01"}}}
+	changed := &routestore.CompiledRoute{Rules: []routestore.CompiledRule{{Path: "/api/*", Price: "0.01", Mode: "all-pay"}}}
+
+	tests := []struct {
+		name        string
+		version     int32
+		sameSpec    bool
+		compiled    *routestore.CompiledRoute
+		wantChanged bool
+	}{
+		{name: "first compilation", version: 0, compiled: changed},
+		{name: "same compiler", version: compilerVersion, sameSpec: true, compiled: changed},
+		{name: "new compiler without behavior change", version: compilerVersion + 1, sameSpec: true, compiled: compiled},
+		{name: "new compiler with behavior change", version: compilerVersion + 1, sameSpec: true, compiled: changed, wantChanged: true},
+		{name: "new compiler with spec change", version: compilerVersion + 1, compiled: changed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := events.NewFakeRecorder(1)
+			r := &X402RouteReconciler{Recorder: recorder}
+			route := newTestRoute()
+			if tt.version != 0 {
+				route.Status.CompilerVersion = tt.version
+				route.Status.CompiledHash = compiledHash(compiled)
+				route.Status.SpecHash = "stale"
+				if tt.sameSpec {
+					route.Status.SpecHash = specHash(route)
+				}
+			}
+
+			r.recordCompilation(route, tt.compiled)
+
+			cond := meta.FindStatusCondition(route.Status.Conditions, conditionBehaviorChanged)
+			if got := cond != nil; got != tt.wantChanged {
+				t.Errorf("BehaviorChanged condition set = %v, want %v", got, tt.wantChanged)
+			}
+			select {
+			case e := <-recorder.Events:
+				if !tt.wantChanged || !strings.Contains(e, "CompiledBehaviorChanged") {
+					t.Errorf("unexpected event %q", e)
+				}
+			default:
+				if tt.wantChanged {
+					t.Error("no event recorded")
+				}
+			}
+			if route.Status.CompilerVersion != compilerVersion || route.Status.SpecHash != specHash(route) || route.Status.CompiledHash != compiledHash(tt.compiled) {
+				t.Errorf("status not stamped: %+v", route.Status)
+			}
+		})
+	}
+
+	// The condition clears once the spec changes.
+	route := newTestRoute()
+	r := &X402RouteReconciler{}
+	route.Status.CompilerVersion = compilerVersion + 1
+	route.Status.SpecHash = specHash(route)
+	r.recordCompilation(route, changed)
+	route.Spec.Routes[0].Price = "0.05"
+	r.recordCompilation(route, changed)
+	if meta.FindStatusCondition(route.Status.Conditions, conditionBehaviorChanged) != nil {
+		t.Error("BehaviorChanged condition kept after a spec change")
+	}
+}
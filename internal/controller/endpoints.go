@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// resolveEndpoints fills in the ready endpoint addresses of each backend from
+// the EndpointSlices of its Service. Backends that cannot be resolved keep an
+// empty endpoint list, so the gateway falls back to the Service DNS name.
+func (r *X402RouteReconciler) resolveEndpoints(ctx context.Context, namespace string, backends []routestore.CompiledBackend) {
+	logger := log.FromContext(ctx)
+	for i := range backends {
+		endpoints, err := r.serviceEndpoints(ctx, namespace, backends[i].Service, backends[i].Port)
+		if err != nil {
+			logger.Error(err, "failed to resolve endpoints, using Service DNS", "service", backends[i].Service)
+			continue
+		}
+		backends[i].Endpoints = endpoints
+	}
+}
+
+// serviceEndpoints returns the base URLs of the ready endpoints serving the
+// given Service port.
+func (r *X402RouteReconciler) serviceEndpoints(ctx context.Context, namespace, service string, port int32) ([]string, error) {
+	var svc corev1.Service
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: service}, &svc); err != nil {
+		return nil, fmt.Errorf("get service %s/%s: %w", namespace, service, err)
+	}
+
+	// EndpointSlice ports carry the target port under the Service port's name.
+	portName, found := "", false
+	for _, sp := range svc.Spec.Ports {
+		if sp.Port == port {
+			portName, found = sp.Name, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("service %s/%s has no port %d", namespace, service, port)
+	}
+
+	var slices discoveryv1.EndpointSliceList
+	if err := r.List(ctx, &slices,
+		client.InNamespace(namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: service},
+	); err != nil {
+		return nil, fmt.Errorf("list endpointslices for %s/%s: %w", namespace, service, err)
+	}
+
+	var endpoints []string
+	for _, slice := range slices.Items {
+		targetPort := slicePort(slice, portName)
+		if targetPort == 0 {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if !endpointReady(ep) {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				endpoints = append(endpoints, "http://"+net.JoinHostPort(addr, fmt.Sprint(targetPort)))
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// slicePort returns the port number an EndpointSlice exposes under the given name.
+func slicePort(slice discoveryv1.EndpointSlice, name string) int32 {
+	for _, p := range slice.Ports {
+		if p.Port == nil {
+			continue
+		}
+		pName := ""
+		if p.Name != nil {
+			pName = *p.Name
+		}
+		if pName == name {
+			return *p.Port
+		}
+	}
+	return 0
+}
+
+// endpointReady reports whether an endpoint can receive traffic. A nil ready
+// condition means unknown and is treated as ready, per the EndpointSlice API.
+func endpointReady(ep discoveryv1.Endpoint) bool {
+	if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+		return false
+	}
+	return ep.Conditions.Ready == nil || *ep.Conditions.Ready
+}
+
+// Field indexes backing the EndpointSlice watch, so a slice event only wakes
+// the routes whose Ingress sends traffic to the slice's Service.
+const (
+	// ingressServicesIndex indexes Ingresses by their original backend
+	// Services.
+	ingressServicesIndex = "x402.io/backend-services"
+	// endpointsIngressIndex indexes X402Routes resolving backends via
+	// endpoints by the namespace/name of their Ingress.
+	endpointsIngressIndex = "x402.io/endpoints-ingress"
+)
+
+// indexFields registers the field indexes used by the watches.
+func (r *X402RouteReconciler) indexFields(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &networkingv1.Ingress{}, ingressServicesIndex, r.ingressServices); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &x402v1alpha1.X402Route{}, endpointsIngressIndex, endpointsIngress)
+}
+
+// ingressServices returns the original backend Services of an Ingress, which
+// the gateway proxies to once it is patched.
+func (r *X402RouteReconciler) ingressServices(obj client.Object) []string {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return nil
+	}
+	// extractBackends drops a corrupted annotation; keep the cached object intact.
+	var services []string
+	for _, b := range r.extractBackends(ingress.DeepCopy()) {
+		if !slices.Contains(services, b.Service) {
+			services = append(services, b.Service)
+		}
+	}
+	return services
+}
+
+// endpointsIngress returns the namespace/name of the Ingress of a route that
+// resolves backends via endpoints, and nothing for other routes.
+func endpointsIngress(obj client.Object) []string {
+	route, ok := obj.(*x402v1alpha1.X402Route)
+	if !ok || route.Spec.BackendResolution != "endpoints" {
+		return nil
+	}
+	ingressNS := route.Spec.IngressRef.Namespace
+	if ingressNS == "" {
+		ingressNS = route.Namespace
+	}
+	return []string{ingressNS + "/" + route.Spec.IngressRef.Name}
+}
+
+// endpointSliceChanged passes EndpointSlices of a Service whose endpoints or
+// ports changed. Slices without a Service and metadata-only updates, such as
+// the endpoint controller's annotations, are dropped.
+func endpointSliceChanged() predicate.Predicate {
+	hasService := func(obj client.Object) bool {
+		return obj.GetLabels()[discoveryv1.LabelServiceName] != ""
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return hasService(e.Object) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return hasService(e.Object) },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSlice, ok := e.ObjectOld.(*discoveryv1.EndpointSlice)
+			if !ok {
+				return false
+			}
+			newSlice, ok := e.ObjectNew.(*discoveryv1.EndpointSlice)
+			if !ok || !hasService(newSlice) {
+				return false
+			}
+			return !equality.Semantic.DeepEqual(oldSlice.Endpoints, newSlice.Endpoints) ||
+				!equality.Semantic.DeepEqual(oldSlice.Ports, newSlice.Ports)
+		},
+	}
+}
+
+// endpointSliceToX402Routes maps an EndpointSlice event to the X402Routes that
+// resolve backends via endpoints and whose Ingress routes to the slice's
+// Service.
+func (r *X402RouteReconciler) endpointSliceToX402Routes(ctx context.Context, obj client.Object) []reconcile.Request {
+	service := obj.GetLabels()[discoveryv1.LabelServiceName]
+	if service == "" {
+		return nil
+	}
+
+	var ingresses networkingv1.IngressList
+	if err := r.List(ctx, &ingresses,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{ingressServicesIndex: service},
+	); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list Ingresses for EndpointSlice watch")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, ingress := range ingresses.Items {
+		var routeList x402v1alpha1.X402RouteList
+		if err := r.List(ctx, &routeList,
+			client.MatchingFields{endpointsIngressIndex: ingress.Namespace + "/" + ingress.Name},
+		); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list X402Routes for EndpointSlice watch")
+			return nil
+		}
+		for _, route := range routeList.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      route.Name,
+					Namespace: route.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
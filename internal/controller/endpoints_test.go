@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestServiceEndpoints(t *testing.T) {
+	portName := "http"
+	targetPort := int32(8080)
+	ready, notReady := true, false
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-api", Namespace: "default"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80},
+		}},
+	}
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-api-abc12",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "my-api"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &targetPort}},
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+			{Addresses: []string{"10.0.0.3"}},
+		},
+	}
+
+	r := &X402RouteReconciler{
+		Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(svc, slice).Build(),
+	}
+
+	got, err := r.serviceEndpoints(context.Background(), "default", "my-api", 80)
+	if err != nil {
+		t.Fatalf("serviceEndpoints() error = %v", err)
+	}
+	want := []string{"http://10.0.0.1:8080", "http://10.0.0.3:8080"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("serviceEndpoints() = %v, want %v", got, want)
+	}
+
+	if _, err := r.serviceEndpoints(context.Background(), "default", "my-api", 9090); err == nil {
+		t.Error("serviceEndpoints() with unknown port = nil error, want error")
+	}
+}
+
+func TestEndpointSliceToX402Routes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	// A patched Ingress points at the gateway; its original backends still count.
+	ingress := newTestIngress()
+	if err := r.applyGatewayPatch(newTestRoute(), ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	other := newTestIngress()
+	other.Name = "other-ingress"
+	for _, rule := range other.Spec.Rules {
+		rule.HTTP.Paths[0].Backend.Service.Name = "other"
+	}
+
+	newRoute := func(name, ingressName, resolution string) *x402v1alpha1.X402Route {
+		route := newTestRoute()
+		route.Name, route.Namespace = name, "default"
+		route.Spec.IngressRef.Name = ingressName
+		route.Spec.BackendResolution = resolution
+		return route
+	}
+	r.Client = fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(ingress, other,
+			newRoute("endpoints", "my-api-ingress", "endpoints"),
+			newRoute("dns", "my-api-ingress", ""),
+			newRoute("other-endpoints", "other-ingress", "endpoints"),
+		).
+		WithIndex(&networkingv1.Ingress{}, ingressServicesIndex, r.ingressServices).
+		WithIndex(&x402v1alpha1.X402Route{}, endpointsIngressIndex, endpointsIngress).
+		Build()
+
+	slice := &discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{
+		Name:      "my-api-abc12",
+		Namespace: "default",
+		Labels:    map[string]string{discoveryv1.LabelServiceName: "my-api"},
+	}}
+	got := r.endpointSliceToX402Routes(context.Background(), slice)
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "endpoints"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("endpointSliceToX402Routes() = %v, want %v", got, want)
+	}
+
+	slice.Labels[discoveryv1.LabelServiceName] = "unused"
+	if got := r.endpointSliceToX402Routes(context.Background(), slice); len(got) != 0 {
+		t.Errorf("endpointSliceToX402Routes() for an unreferenced Service = %v, want none", got)
+	}
+}
+
+func TestEndpointSliceChanged(t *testing.T) {
+	ready := true
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-api-abc12",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "my-api"},
+		},
+		Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}}},
+	}
+	p := endpointSliceChanged()
+
+	annotated := slice.DeepCopy()
+	annotated.Annotations = map[string]string{"endpoints.kubernetes.io/last-change-trigger-time": "now"}
+	if p.Update(event.UpdateEvent{ObjectOld: slice, ObjectNew: annotated}) {
+		t.Error("metadata-only update passed")
+	}
+	scaled := slice.DeepCopy()
+	scaled.Endpoints = append(scaled.Endpoints, discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}})
+	if !p.Update(event.UpdateEvent{ObjectOld: slice, ObjectNew: scaled}) {
+		t.Error("endpoint change dropped")
+	}
+	unowned := slice.DeepCopy()
+	unowned.Labels = nil
+	if p.Create(event.CreateEvent{Object: unowned}) {
+		t.Error("slice without a Service passed")
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// errExternalNotAllowed rejects external backends unless the operator opts
+// in: they turn the gateway into a proxy to hosts outside the cluster.
+var errExternalNotAllowed = errors.New("external backends are disabled; start the operator with --allow-external-backends")
+
+// externalBackend compiles the external backend of a rule.
+func (r *X402RouteReconciler) externalBackend(ext *x402v1alpha1.ExternalBackend) (*routestore.CompiledBackend, error) {
+	if !r.AllowExternalBackends {
+		return nil, errExternalNotAllowed
+	}
+	if err := validateExternalBackendURL(ext.URL); err != nil {
+		return nil, fmt.Errorf("externalBackend URL %q: %w", ext.URL, err)
+	}
+	b := &routestore.CompiledBackend{
+		Path:     "/",
+		PathType: "Prefix",
+		URL:      ext.URL,
+		Protocol: "https",
+		External: true,
+	}
+	if ext.ServerName != "" {
+		b.TLS = &routestore.CompiledBackendTLS{ServerName: ext.ServerName}
+	}
+	if ext.Auth != nil {
+		if ext.Auth.ValueFrom.Vault == nil {
+			return nil, errors.New("externalBackend.auth.valueFrom needs a vault reference")
+		}
+		auth, err := compileFacilitatorAuth(ext.Auth)
+		if err != nil {
+			return nil, err
+		}
+		b.Auth = auth
+	}
+	return b, nil
+}
+
+// validateExternalBackendURL checks that an external backend URL names a
+// public HTTPS host: the facilitator URL checks, and no in-cluster names.
+func validateExternalBackendURL(rawURL string) error {
+	if err := validateFacilitatorURL(rawURL); err != nil {
+		return err
+	}
+	u, _ := url.Parse(rawURL)
+	if u.Scheme != "https" {
+		return fmt.Errorf("scheme %q not allowed, must be https", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("credentials in the URL are not allowed, use auth")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("query and fragment are not allowed")
+	}
+	if host := strings.ToLower(u.Hostname()); isInClusterHostname(host) || strings.HasSuffix(host, ".cluster.local") {
+		return fmt.Errorf("hostname %q is in the cluster, use the Ingress backends", u.Hostname())
+	}
+	return nil
+}
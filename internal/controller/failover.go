@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// annotationFailoverPaths records the Ingress paths switched to the
+	// route's fallback backend while the gateway was unavailable.
+	annotationFailoverPaths = "x402.io/failover-paths"
+
+	// failoverTimeout bounds the failover work done during shutdown.
+	failoverTimeout = 5 * time.Second
+)
+
+// GatewayFailover is a manager runnable that switches paid Ingress paths to
+// the route's fallback backend when the last ready gateway replica shuts down.
+// The controller switches them back on its next reconcile.
+type GatewayFailover struct {
+	Client            client.Client
+	Reader            client.Reader // uncached; the informer cache stops with the manager
+	OperatorNamespace string
+	OperatorSvcName   string
+	PodIP             string // this replica's address, excluded when counting ready peers
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica
+// runs the shutdown hook, since any of them may be the last one serving.
+func (f *GatewayFailover) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable. It blocks until the manager shuts down,
+// then fails over routes with a fallback if no other gateway replica is ready.
+func (f *GatewayFailover) Start(ctx context.Context) error {
+	<-ctx.Done()
+
+	logger := ctrl.Log.WithName("failover")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), failoverTimeout)
+	defer cancel()
+
+	peers, err := f.readyPeers(shutdownCtx)
+	if err != nil {
+		logger.Error(err, "failed to count ready gateway replicas, skipping failover")
+		return nil
+	}
+	if peers > 0 {
+		return nil
+	}
+
+	var routeList x402v1alpha1.X402RouteList
+	if err := f.Reader.List(shutdownCtx, &routeList); err != nil {
+		logger.Error(err, "failed to list X402Routes for failover")
+		return nil
+	}
+	for i := range routeList.Items {
+		route := &routeList.Items[i]
+		if route.Spec.Fallback == nil {
+			continue
+		}
+		if err := f.failoverRoute(shutdownCtx, route); err != nil {
+			logger.Error(err, "failed to switch route to fallback", "route", route.Namespace+"/"+route.Name)
+			continue
+		}
+		logger.Info("switched paid paths to fallback", "route", route.Namespace+"/"+route.Name,
+			"fallback", route.Spec.Fallback.ServiceName)
+	}
+	return nil
+}
+
+// readyPeers counts the ready gateway endpoints other than this replica.
+func (f *GatewayFailover) readyPeers(ctx context.Context) (int, error) {
+	var slices discoveryv1.EndpointSliceList
+	if err := f.Reader.List(ctx, &slices,
+		client.InNamespace(f.OperatorNamespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: f.OperatorSvcName},
+	); err != nil {
+		return 0, fmt.Errorf("list gateway endpointslices: %w", err)
+	}
+
+	peers := 0
+	for _, slice := range slices.Items {
+		for _, ep := range slice.Endpoints {
+			if !endpointReady(ep) {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				if addr != f.PodIP {
+					peers++
+				}
+			}
+		}
+	}
+	return peers, nil
+}
+
+// failoverRoute points the route's gateway paths at its fallback backend and
+// marks the route's gateway as unavailable.
+func (f *GatewayFailover) failoverRoute(ctx context.Context, route *x402v1alpha1.X402Route) error {
+	ingressNS := route.Spec.IngressRef.Namespace
+	if ingressNS == "" {
+		ingressNS = route.Namespace
+	}
+	ingress := &networkingv1.Ingress{}
+	if err := f.Reader.Get(ctx, types.NamespacedName{Namespace: ingressNS, Name: route.Spec.IngressRef.Name}, ingress); err != nil {
+		return fmt.Errorf("get ingress: %w", err)
+	}
+	if !isManaged(ingress) {
+		return nil
+	}
+
+	if err := switchToFallback(ingress, route.Spec.Fallback, f.isGatewayService); err != nil {
+		return err
+	}
+	if err := f.Client.Update(ctx, ingress); err != nil {
+		return fmt.Errorf("update ingress: %w", err)
+	}
+
+	base := route.DeepCopy()
+	meta.SetStatusCondition(&route.Status.Conditions, metav1.Condition{
+		Type:               "GatewayAvailable",
+		Status:             metav1.ConditionFalse,
+		Reason:             "Failover",
+		Message:            fmt.Sprintf("No gateway replica is ready; paid paths are served by %s", route.Spec.Fallback.ServiceName),
+		ObservedGeneration: route.Generation,
+		LastTransitionTime: metav1.Now(),
+	})
+	if err := f.Client.Status().Patch(ctx, route, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("patch status: %w", err)
+	}
+	return nil
+}
+
+func (f *GatewayFailover) isGatewayService(name string) bool {
+	return name == externalSvcName || name == f.OperatorSvcName
+}
+
+// switchToFallback re-points every path routed to the gateway at the fallback
+// backend and records the switched paths in the failover annotation.
+func switchToFallback(ingress *networkingv1.Ingress, fallback *x402v1alpha1.FallbackBackend, isGateway func(string) bool) error {
+	var switched []synthesizedPath
+	for i := range ingress.Spec.Rules {
+		if ingress.Spec.Rules[i].HTTP == nil {
+			continue
+		}
+		for j := range ingress.Spec.Rules[i].HTTP.Paths {
+			p := &ingress.Spec.Rules[i].HTTP.Paths[j]
+			if p.Backend.Service == nil || !isGateway(p.Backend.Service.Name) {
+				continue
+			}
+			p.Backend = networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+				Name: fallback.ServiceName,
+				Port: networkingv1.ServiceBackendPort{Number: fallback.ServicePort},
+			}}
+			entry := synthesizedPath{Host: ingress.Spec.Rules[i].Host, Path: p.Path}
+			if p.PathType != nil {
+				entry.PathType = string(*p.PathType)
+			}
+			switched = append(switched, entry)
+		}
+	}
+	if len(switched) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(switched)
+	if err != nil {
+		return fmt.Errorf("marshal failover paths: %w", err)
+	}
+	if ingress.Annotations == nil {
+		ingress.Annotations = make(map[string]string)
+	}
+	ingress.Annotations[annotationFailoverPaths] = string(data)
+	return nil
+}
+
+// restoreFromFallback points the paths recorded in the failover annotation back
+// at the gateway service and removes the annotation.
+func restoreFromFallback(ingress *networkingv1.Ingress, gatewaySvcName string) error {
+	raw, ok := ingress.Annotations[annotationFailoverPaths]
+	if !ok {
+		return nil
+	}
+	var switched []synthesizedPath
+	if err := json.Unmarshal([]byte(raw), &switched); err != nil {
+		return fmt.Errorf("unmarshal failover paths: %w", err)
+	}
+
+	for i := range ingress.Spec.Rules {
+		if ingress.Spec.Rules[i].HTTP == nil {
+			continue
+		}
+		for j := range ingress.Spec.Rules[i].HTTP.Paths {
+			p := &ingress.Spec.Rules[i].HTTP.Paths[j]
+			if !isSynthesized(switched, ingress.Spec.Rules[i].Host, *p) {
+				continue
+			}
+			p.Backend = networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+				Name: gatewaySvcName,
+				Port: networkingv1.ServiceBackendPort{Number: gatewayPort},
+			}}
+		}
+	}
+	delete(ingress.Annotations, annotationFailoverPaths)
+	return nil
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestFallbackRoundTrip(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	route := newTestRoute()
+	route.Spec.Fallback = &x402v1alpha1.FallbackBackend{ServiceName: "maintenance", ServicePort: 80}
+	ingress := newTestIngress()
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	patched := ingress.DeepCopy()
+
+	if err := switchToFallback(ingress, route.Spec.Fallback, r.isGatewayService); err != nil {
+		t.Fatalf("switchToFallback() error = %v", err)
+	}
+	for i, rule := range ingress.Spec.Rules {
+		if got := rule.HTTP.Paths[0].Backend.Service.Name; got != "maintenance" {
+			t.Errorf("rule %d gated path backend = %q, want maintenance", i, got)
+		}
+		if got := rule.HTTP.Paths[1].Backend.Service.Name; got != "my-api" {
+			t.Errorf("rule %d free path backend = %q, want my-api", i, got)
+		}
+	}
+	if _, ok := ingress.Annotations[annotationFailoverPaths]; !ok {
+		t.Fatal("failover-paths annotation not recorded")
+	}
+
+	// The next reconcile points the paths back at the gateway.
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() after failover error = %v", err)
+	}
+	preview, err := renderPatchDiff(patched, ingress)
+	if err != nil {
+		t.Fatalf("renderPatchDiff() error = %v", err)
+	}
+	if preview != "" {
+		t.Errorf("Ingress after failover and restore differs from patched state:\n%s", preview)
+	}
+}
+
+func TestReadyPeers(t *testing.T) {
+	ready, notReady, terminating := true, false, true
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "x402-k8s-operator-abc12",
+			Namespace: "x402-system",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "x402-k8s-operator"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+			{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Terminating: &terminating}},
+		},
+	}
+	reader := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(slice).Build()
+
+	tests := []struct {
+		name  string
+		podIP string
+		want  int
+	}{
+		{name: "other replica ready", podIP: "10.0.0.3", want: 1},
+		{name: "last ready replica", podIP: "10.0.0.1", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &GatewayFailover{
+				Reader:            reader,
+				OperatorNamespace: "x402-system",
+				OperatorSvcName:   "x402-k8s-operator",
+				PodIP:             tt.podIP,
+			}
+			got, err := f.readyPeers(context.Background())
+			if err != nil {
+				t.Fatalf("readyPeers() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("readyPeers() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
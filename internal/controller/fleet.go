@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/fleet"
+)
+
+const (
+	// conditionFleetConsistent reports whether the route is priced the same
+	// in every cluster of the fleet.
+	conditionFleetConsistent = "FleetConsistent"
+
+	// fleetResyncInterval is how often routes are compared with the fleet
+	// when nothing changes locally.
+	fleetResyncInterval = 5 * time.Minute
+)
+
+// clusterPrices returns the prices spec.clusterOverrides sets for cluster, by
+// rule path; the "" key holds the default price.
+func clusterPrices(route *x402v1alpha1.X402Route, cluster string) (map[string]string, error) {
+	if cluster == "" {
+		return nil, nil
+	}
+	paths := make(map[string]bool, len(route.Spec.Routes))
+	for _, rule := range route.Spec.Routes {
+		paths[rule.Path] = true
+	}
+	prices := make(map[string]string)
+	for _, o := range route.Spec.ClusterOverrides {
+		if o.Cluster != cluster {
+			continue
+		}
+		if o.Path != "" && !paths[o.Path] {
+			return nil, fmt.Errorf("cluster override for %q: no rule with this path", o.Path)
+		}
+		if _, dup := prices[o.Path]; dup {
+			return nil, fmt.Errorf("duplicate cluster override for %q in cluster %s", o.Path, cluster)
+		}
+		prices[o.Path] = o.Price
+	}
+	return prices, nil
+}
+
+// routePricing returns the pricing the route's spec sets, without cluster
+// overrides, as compared across the fleet. The overrides for cluster are
+// attached for visibility.
+func routePricing(route *x402v1alpha1.X402Route, cluster string) fleet.RoutePricing {
+	p := fleet.RoutePricing{
+		Cluster:   cluster,
+		Namespace: route.Namespace,
+		Name:      route.Name,
+		Wallet:    route.Spec.Payment.Wallet,
+		Network:   route.Spec.Payment.Network,
+		Asset:     route.Spec.Payment.Asset,
+	}
+	for _, rule := range enabledRules(route) {
+		rp := fleet.RulePricing{Path: rule.Path, Host: rule.Host, Free: rule.Free, Mode: rule.Mode}
+		if rp.Mode == "" {
+			rp.Mode = "all-pay"
+		}
+		switch {
+		case rule.Free:
+		case len(rule.Offers) > 0:
+			rp.Offers = make(map[string]string, len(rule.Offers))
+			for _, offer := range rule.Offers {
+				rp.Offers[offer.Name] = offer.Price
+			}
+		case rule.Price != "":
+			rp.Price = rule.Price
+		default:
+			rp.Price = route.Spec.Payment.DefaultPrice
+		}
+		p.Rules = append(p.Rules, rp)
+	}
+	for _, o := range route.Spec.ClusterOverrides {
+		if o.Cluster == cluster {
+			p.Overrides = append(p.Overrides, fleet.RulePricing{Path: o.Path, Price: o.Price})
+		}
+	}
+	p.Seal()
+	return p
+}
+
+// syncFleet exchanges the route's pricing with the fleet endpoint and sets the
+// FleetConsistent condition. Fleet errors never fail the reconcile; the
+// condition turns Unknown until the endpoint answers again.
+func (r *X402RouteReconciler) syncFleet(ctx context.Context, route *x402v1alpha1.X402Route) {
+	if r.Fleet == nil {
+		return
+	}
+	local := routePricing(route, r.ClusterName)
+	// A wallet read from payment.walletSecretRef is only in the compiled route.
+	if route.Spec.Payment.WalletSecretRef != nil {
+		if compiled := r.RouteStore.Get(route.Namespace, route.Name); compiled != nil {
+			local.Wallet = compiled.Wallet
+		}
+	}
+	entries, err := r.Fleet.Sync(ctx, local)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to sync route pricing with the fleet")
+		r.setCondition(route, conditionFleetConsistent, metav1.ConditionUnknown, "FleetUnavailable", err.Error())
+		return
+	}
+
+	if conflicts := fleet.Conflicts(local, entries); len(conflicts) > 0 {
+		msg := fmt.Sprintf("Pricing differs from cluster(s) %s; align spec.routes and spec.payment or use spec.clusterOverrides", strings.Join(conflicts, ", "))
+		if c := meta.FindStatusCondition(route.Status.Conditions, conditionFleetConsistent); (c == nil || c.Message != msg) && r.Recorder != nil {
+			r.Recorder.Eventf(route, nil, corev1.EventTypeWarning, "FleetPricingConflict", "Compare", msg)
+		}
+		r.setCondition(route, conditionFleetConsistent, metav1.ConditionFalse, "PricingConflict", msg)
+		return
+	}
+
+	others := 0
+	for _, e := range entries {
+		if e.Cluster != local.Cluster {
+			others++
+		}
+	}
+	r.setCondition(route, conditionFleetConsistent, metav1.ConditionTrue, "Consistent", fmt.Sprintf("Pricing matches %d other cluster(s)", others))
+}
+
+// withdrawFleet removes the route's pricing from the fleet endpoint. Errors
+// are logged only, so an unreachable endpoint does not block deletion.
+func (r *X402RouteReconciler) withdrawFleet(ctx context.Context, route *x402v1alpha1.X402Route) {
+	if r.Fleet == nil {
+		return
+	}
+	if err := r.Fleet.Withdraw(ctx, route.Namespace, route.Name); err != nil {
+		log.FromContext(ctx).Error(err, "failed to withdraw route pricing from the fleet")
+	}
+}
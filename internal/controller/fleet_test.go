@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/fleet"
+)
+
+func newFleetRoute() *x402v1alpha1.X402Route {
+	route := newTestRoute()
+	route.Name, route.Namespace = "my-api", "default"
+	route.Spec.Payment = x402v1alpha1.PaymentDefaults{Wallet: "0xabc", Network: "base", DefaultPrice: "0.01"}
+	route.Spec.Routes = append(route.Spec.Routes, x402v1alpha1.RouteRule{Path: "/premium/*", Price: "0.10"})
+	route.Spec.ClusterOverrides = []x402v1alpha1.ClusterOverride{
+		{Cluster: "eu", Price: "0.012"},
+		{Cluster: "eu", Path: "/premium/*", Price: "0.12"},
+		{Cluster: "us", Path: "/premium/*", Price: "0.09"},
+	}
+	return route
+}
+
+func TestCompileRouteClusterOverrides(t *testing.T) {
+	tests := []struct {
+		cluster string
+		want    map[string]string // key: rule path
+	}{
+		{cluster: "", want: map[string]string{"/api/*": "0.01", "/premium/*": "0.10"}},
+		{cluster: "eu", want: map[string]string{"/api/*": "0.012", "/premium/*": "0.12"}},
+		{cluster: "us", want: map[string]string{"/api/*": "0.01", "/premium/*": "0.09"}},
+		{cluster: "ap", want: map[string]string{"/api/*": "0.01", "/premium/*": "0.10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cluster, func(t *testing.T) {
+			r := &X402RouteReconciler{ClusterName: tt.cluster}
+			compiled, err := r.compileRoute(newFleetRoute(), nil, newTestIngress())
+			if err != nil {
+				t.Fatalf("compileRoute() error = %v", err)
+			}
+			for _, rule := range compiled.Rules {
+				if want, ok := tt.want[rule.Path]; ok && rule.Price != want {
+					t.Errorf("rule %s price = %s, want %s", rule.Path, rule.Price, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileRouteClusterOverrideErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*x402v1alpha1.X402Route)
+	}{
+		{name: "unknown path", mutate: func(r *x402v1alpha1.X402Route) {
+			r.Spec.ClusterOverrides = append(r.Spec.ClusterOverrides, x402v1alpha1.ClusterOverride{Cluster: "eu", Path: "/nope", Price: "1"})
+		}},
+		{name: "duplicate", mutate: func(r *x402v1alpha1.X402Route) {
+			r.Spec.ClusterOverrides = append(r.Spec.ClusterOverrides, x402v1alpha1.ClusterOverride{Cluster: "eu", Price: "1"})
+		}},
+		{name: "offers", mutate: func(r *x402v1alpha1.X402Route) {
+			r.Spec.Routes[2].Offers = []x402v1alpha1.PriceOffer{{Name: "standard", Price: "0.10"}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newFleetRoute()
+			tt.mutate(route)
+			r := &X402RouteReconciler{ClusterName: "eu"}
+			if _, err := r.compileRoute(route, nil, newTestIngress()); err == nil {
+				t.Error("compileRoute() succeeded")
+			}
+		})
+	}
+}
+
+func TestSyncFleet(t *testing.T) {
+	var remote []fleet.RoutePricing
+	var published fleet.RoutePricing
+	down := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&published)
+		json.NewEncoder(w).Encode(append([]fleet.RoutePricing{published}, remote...))
+	}))
+	defer srv.Close()
+
+	client, err := fleet.NewClient(srv.URL, "eu", fleet.ModeExport, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &X402RouteReconciler{ClusterName: "eu", Fleet: client}
+	ctx := context.Background()
+
+	// Overrides differ between eu and us, but the shared pricing matches.
+	route := newFleetRoute()
+	remote = []fleet.RoutePricing{routePricing(route, "us")}
+	r.syncFleet(ctx, route)
+	if c := meta.FindStatusCondition(route.Status.Conditions, conditionFleetConsistent); c == nil || c.Status != metav1.ConditionTrue {
+		t.Fatalf("condition = %+v, want True", c)
+	}
+	if len(published.Overrides) != 2 || published.Hash != remote[0].Hash {
+		t.Errorf("published = %+v", published)
+	}
+
+	other := newFleetRoute()
+	other.Spec.Payment.DefaultPrice = "0.02"
+	remote = []fleet.RoutePricing{routePricing(other, "ap")}
+	r.syncFleet(ctx, route)
+	c := meta.FindStatusCondition(route.Status.Conditions, conditionFleetConsistent)
+	if c == nil || c.Status != metav1.ConditionFalse || c.Reason != "PricingConflict" {
+		t.Fatalf("condition = %+v, want False/PricingConflict", c)
+	}
+
+	down = true
+	r.syncFleet(ctx, route)
+	if c := meta.FindStatusCondition(route.Status.Conditions, conditionFleetConsistent); c == nil || c.Status != metav1.ConditionUnknown {
+		t.Errorf("condition = %+v, want Unknown", c)
+	}
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+const annotationSynthesizedPaths = "x402.io/synthesized-paths"
+
+// synthesizedPath identifies an Ingress path entry added by the operator so that
+// a free sub-path keeps its original backend instead of traversing the gateway.
+type synthesizedPath struct {
+	Host     string `json:"host,omitempty"`
+	Path     string `json:"path"`
+	PathType string `json:"pathType"`
+}
+
+// rulePrefix reduces a route rule path to its literal prefix. subtree reports
+// whether the rule covers everything below the prefix (trailing /* or /**),
+// and literal is false when the pattern contains interior wildcards that cannot
+// be expressed as an Ingress path.
+func rulePrefix(path string) (prefix string, subtree, literal bool) {
+	prefix = path
+	if strings.HasSuffix(prefix, "/**") {
+		prefix = strings.TrimSuffix(prefix, "/**")
+		subtree = true
+	} else if strings.HasSuffix(prefix, "/*") {
+		prefix = strings.TrimSuffix(prefix, "/*")
+		subtree = true
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		prefix = "/"
+	}
+	return prefix, subtree, !strings.Contains(prefix, "*")
+}
+
+// covers reports whether the request set of rule a contains that of rule b.
+func covers(aPrefix string, aSubtree bool, bPrefix string, bSubtree bool) bool {
+	if aSubtree {
+		return aPrefix == "/" || bPrefix == aPrefix || strings.HasPrefix(bPrefix, aPrefix+"/")
+	}
+	return !bSubtree && aPrefix == bPrefix
+}
+
+// freeRuleBypassable reports whether the free rule at index idx can be served
+// directly by the original backend without changing the gateway's decision.
+// The gateway picks the first matching rule, so the split is only safe when no
+// paid rule overlaps the free path, or the overlapping paid rule is a broader
+// rule listed after the free one.
+func freeRuleBypassable(rules []x402v1alpha1.RouteRule, idx int) bool {
+	fPrefix, fSubtree, fLiteral := rulePrefix(rules[idx].Path)
+	if !fLiteral {
+		return false
+	}
+	for i, rule := range rules {
+		if rule.Free {
+			continue
+		}
+		pPrefix, pSubtree, pLiteral := rulePrefix(rule.Path)
+		if !pLiteral {
+			return false
+		}
+		pCoversF := covers(pPrefix, pSubtree, fPrefix, fSubtree)
+		fCoversP := covers(fPrefix, fSubtree, pPrefix, pSubtree)
+		if !pCoversF && !fCoversP {
+			continue
+		}
+		if pCoversF && i > idx {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// nestedUnder reports whether prefix lies strictly below an Ingress Prefix path.
+func nestedUnder(prefix, ingressPath string) bool {
+	clean := strings.TrimRight(ingressPath, "/")
+	if clean == "" {
+		return prefix != "/"
+	}
+	return strings.HasPrefix(prefix, clean+"/")
+}
+
+// freePathsFor returns the Ingress path entries to synthesize below a gated
+// Ingress path of the Ingress rule for host so that free sub-paths bypass the
+// gateway. Splitting is only attempted for Prefix paths;
+// ImplementationSpecific paths (e.g. NGINX regex) keep routing free traffic
+// through the gateway.
+func freePathsFor(route *x402v1alpha1.X402Route, host string, p networkingv1.HTTPIngressPath, original networkingv1.IngressBackend) []networkingv1.HTTPIngressPath {
+	if p.PathType == nil || *p.PathType != networkingv1.PathTypePrefix {
+		return nil
+	}
+
+	var result []networkingv1.HTTPIngressPath
+	rules := hostRules(route, host)
+	for i, rule := range rules {
+		// A free rule for a virtual host is bypassed only on its own host.
+		if rule.Host != "" && !strings.EqualFold(rule.Host, host) {
+			continue
+		}
+		if !rule.Free || !freeRuleBypassable(rules, i) {
+			continue
+		}
+		prefix, subtree, _ := rulePrefix(rule.Path)
+		if !nestedUnder(prefix, p.Path) {
+			continue
+		}
+		pathType := networkingv1.PathTypeExact
+		if subtree {
+			pathType = networkingv1.PathTypePrefix
+		}
+		result = append(result, networkingv1.HTTPIngressPath{
+			Path:     prefix,
+			PathType: &pathType,
+			Backend:  *original.DeepCopy(),
+		})
+	}
+	return result
+}
+
+// hasIngressPath reports whether an Ingress rule already has an entry for path and pathType.
+func hasIngressPath(paths []networkingv1.HTTPIngressPath, path string, pathType networkingv1.PathType) bool {
+	for _, p := range paths {
+		if p.Path == path && p.PathType != nil && *p.PathType == pathType {
+			return true
+		}
+	}
+	return false
+}
+
+// removeSynthesizedPaths strips the path entries recorded in the
+// synthesized-paths annotation from the Ingress.
+func removeSynthesizedPaths(ingress *networkingv1.Ingress) error {
+	stored, ok := ingress.Annotations[annotationSynthesizedPaths]
+	if !ok {
+		return nil
+	}
+
+	var synthesized []synthesizedPath
+	if err := json.Unmarshal([]byte(stored), &synthesized); err != nil {
+		return fmt.Errorf("unmarshal synthesized paths: %w", err)
+	}
+
+	for i := range ingress.Spec.Rules {
+		rule := &ingress.Spec.Rules[i]
+		if rule.HTTP == nil {
+			continue
+		}
+		kept := rule.HTTP.Paths[:0]
+		for _, p := range rule.HTTP.Paths {
+			if !isSynthesized(synthesized, rule.Host, p) {
+				kept = append(kept, p)
+			}
+		}
+		rule.HTTP.Paths = kept
+	}
+	delete(ingress.Annotations, annotationSynthesizedPaths)
+	return nil
+}
+
+func isSynthesized(synthesized []synthesizedPath, host string, p networkingv1.HTTPIngressPath) bool {
+	pathType := ""
+	if p.PathType != nil {
+		pathType = string(*p.PathType)
+	}
+	for _, s := range synthesized {
+		if s.Host == host && s.Path == p.Path && s.PathType == pathType {
+			return true
+		}
+	}
+	return false
+}
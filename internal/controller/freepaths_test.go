@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestFreePathsFor(t *testing.T) {
+	prefix := networkingv1.PathTypePrefix
+	implSpecific := networkingv1.PathTypeImplementationSpecific
+	original, _ := parseServiceBackend("my-api:8080")
+
+	tests := []struct {
+		name     string
+		path     string
+		pathType *networkingv1.PathType
+		rules    []x402v1alpha1.RouteRule
+		want     map[string]networkingv1.PathType
+	}{
+		{
+			name:     "free exact and subtree paths under catch-all",
+			path:     "/",
+			pathType: &prefix,
+			rules: []x402v1alpha1.RouteRule{
+				{Path: "/api/*"},
+				{Path: "/health", Free: true},
+				{Path: "/docs/**", Free: true},
+			},
+			want: map[string]networkingv1.PathType{
+				"/health": networkingv1.PathTypeExact,
+				"/docs":   networkingv1.PathTypePrefix,
+			},
+		},
+		{
+			name:     "free path outside ingress prefix is skipped",
+			path:     "/api",
+			pathType: &prefix,
+			rules: []x402v1alpha1.RouteRule{
+				{Path: "/api/*"},
+				{Path: "/health", Free: true},
+			},
+			want: map[string]networkingv1.PathType{},
+		},
+		{
+			name:     "paid rule nested in free subtree blocks split",
+			path:     "/",
+			pathType: &prefix,
+			rules: []x402v1alpha1.RouteRule{
+				{Path: "/docs/premium/*"},
+				{Path: "/docs/**", Free: true},
+			},
+			want: map[string]networkingv1.PathType{},
+		},
+		{
+			name:     "broader paid rule listed first wins in gateway",
+			path:     "/",
+			pathType: &prefix,
+			rules: []x402v1alpha1.RouteRule{
+				{Path: "/**"},
+				{Path: "/health", Free: true},
+			},
+			want: map[string]networkingv1.PathType{},
+		},
+		{
+			name:     "broader paid rule listed after free rule",
+			path:     "/",
+			pathType: &prefix,
+			rules: []x402v1alpha1.RouteRule{
+				{Path: "/health", Free: true},
+				{Path: "/**"},
+			},
+			want: map[string]networkingv1.PathType{
+				"/health": networkingv1.PathTypeExact,
+			},
+		},
+		{
+			name:     "interior wildcard cannot be expressed",
+			path:     "/",
+			pathType: &prefix,
+			rules: []x402v1alpha1.RouteRule{
+				{Path: "/api/*"},
+				{Path: "/users/*/avatar", Free: true},
+			},
+			want: map[string]networkingv1.PathType{},
+		},
+		{
+			name:     "implementation specific path falls back to gateway",
+			path:     "/(.*)",
+			pathType: &implSpecific,
+			rules: []x402v1alpha1.RouteRule{
+				{Path: "/api/*"},
+				{Path: "/health", Free: true},
+			},
+			want: map[string]networkingv1.PathType{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &x402v1alpha1.X402Route{Spec: x402v1alpha1.X402RouteSpec{Routes: tt.rules}}
+			ingressPath := networkingv1.HTTPIngressPath{Path: tt.path, PathType: tt.pathType}
+
+			got := freePathsFor(route, "", ingressPath, original)
+			if len(got) != len(tt.want) {
+				t.Fatalf("freePathsFor() returned %d paths, want %d", len(got), len(tt.want))
+			}
+			for _, p := range got {
+				want, ok := tt.want[p.Path]
+				if !ok {
+					t.Errorf("unexpected synthesized path %q", p.Path)
+					continue
+				}
+				if *p.PathType != want {
+					t.Errorf("path %q pathType = %s, want %s", p.Path, *p.PathType, want)
+				}
+				if p.Backend.Service == nil || p.Backend.Service.Name != "my-api" || p.Backend.Service.Port.Number != 8080 {
+					t.Errorf("path %q backend = %+v, want my-api:8080", p.Path, p.Backend.Service)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveSynthesizedPaths(t *testing.T) {
+	prefix := networkingv1.PathTypePrefix
+	exact := networkingv1.PathTypeExact
+	ingress := &networkingv1.Ingress{}
+	ingress.Annotations = map[string]string{
+		annotationSynthesizedPaths: `[{"host":"api.example.com","path":"/health","pathType":"Exact"}]`,
+	}
+	ingress.Spec.Rules = []networkingv1.IngressRule{{
+		Host: "api.example.com",
+		IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+			Paths: []networkingv1.HTTPIngressPath{
+				{Path: "/", PathType: &prefix},
+				{Path: "/health", PathType: &exact},
+			},
+		}},
+	}}
+
+	if err := removeSynthesizedPaths(ingress); err != nil {
+		t.Fatalf("removeSynthesizedPaths() error = %v", err)
+	}
+	paths := ingress.Spec.Rules[0].HTTP.Paths
+	if len(paths) != 1 || paths[0].Path != "/" {
+		t.Errorf("remaining paths = %+v, want only /", paths)
+	}
+	if _, ok := ingress.Annotations[annotationSynthesizedPaths]; ok {
+		t.Error("synthesized-paths annotation was not removed")
+	}
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+// annotationAllowedRouteNamespaces on an Ingress lists the namespaces whose
+// X402Routes may patch it, comma-separated, or "*" for any namespace.
+const annotationAllowedRouteNamespaces = "x402.io/allowed-route-namespaces"
+
+// referenceGranted reports whether the route may patch the Ingress. Routes in
+// the Ingress namespace always may; routes elsewhere need the Ingress to grant
+// their namespace.
+func referenceGranted(route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress) bool {
+	if route.Namespace == ingress.Namespace {
+		return true
+	}
+	for _, ns := range strings.Split(ingress.Annotations[annotationAllowedRouteNamespaces], ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "*" || ns == route.Namespace {
+			return true
+		}
+	}
+	return false
+}
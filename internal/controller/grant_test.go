@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestReferenceGranted(t *testing.T) {
+	tests := []struct {
+		name     string
+		routeNS  string
+		allowed  string
+		hasGrant bool
+		want     bool
+	}{
+		{name: "same namespace", routeNS: "default", want: true},
+		{name: "cross namespace without grant", routeNS: "team-a", want: false},
+		{name: "granted namespace", routeNS: "team-a", allowed: "team-b, team-a", hasGrant: true, want: true},
+		{name: "other namespace granted", routeNS: "team-a", allowed: "team-b", hasGrant: true, want: false},
+		{name: "wildcard", routeNS: "team-a", allowed: "*", hasGrant: true, want: true},
+		{name: "empty grant", routeNS: "team-a", allowed: "", hasGrant: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestRoute()
+			route.Namespace = tt.routeNS
+			ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "my-api-ingress", Namespace: "default"}}
+			if tt.hasGrant {
+				ingress.Annotations = map[string]string{annotationAllowedRouteNamespaces: tt.allowed}
+			}
+			if got := referenceGranted(route, ingress); got != tt.want {
+				t.Errorf("referenceGranted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreIngressRequiresGrant(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+
+	r := &X402RouteReconciler{
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+	owner := newTestRoute()
+	owner.Name, owner.Namespace = "my-api", "default"
+	owner.Spec.IngressRef.Name = "my-api-ingress"
+	ingress := newTestIngress()
+	if err := r.applyGatewayPatch(owner, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(ingress).Build()
+
+	// A route in another namespace without a grant must not un-gate the Ingress.
+	intruder := newTestRoute()
+	intruder.Name, intruder.Namespace = "intruder", "team-a"
+	intruder.Spec.IngressRef.Name = "my-api-ingress"
+	intruder.Spec.IngressRef.Namespace = "default"
+	if err := r.restoreIngress(context.Background(), intruder); err != nil {
+		t.Fatalf("restoreIngress() error = %v", err)
+	}
+
+	var got networkingv1.Ingress
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "my-api-ingress"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if name := got.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name; name != externalSvcName {
+		t.Errorf("gated path backend = %q, want %q", name, externalSvcName)
+	}
+}
@@ -0,0 +1,10 @@
+package controller
+
+import _ "embed"
+
+// ArgoCDHealthCheck is the Lua health check Argo CD runs for X402Routes, set
+// as resource.customizations.health.x402.io_X402Route in argocd-cm. It reads
+// status.observedGeneration, status.lastError and the route conditions.
+//
+//go:embed health.lua
+var ArgoCDHealthCheck string
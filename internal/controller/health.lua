@@ -0,0 +1,68 @@
+-- Argo CD health check for x402.io/X402Route, printed by
+-- `x402-k8s-operator --print-argocd-health`.
+local hs = {}
+if obj.status == nil or obj.status.lastReconcileTime == nil then
+  hs.status = "Progressing"
+  hs.message = "Waiting for the operator to reconcile the route"
+  return hs
+end
+
+local generation = obj.metadata.generation or 0
+local observed = obj.status.observedGeneration or 0
+local lastError = obj.status.lastError or ""
+
+local conditions = {}
+if obj.status.conditions ~= nil then
+  for _, c in ipairs(obj.status.conditions) do
+    conditions[c.type] = c
+  end
+end
+
+if observed < generation then
+  if lastError ~= "" then
+    hs.status = "Degraded"
+    hs.message = lastError
+  else
+    hs.status = "Progressing"
+    hs.message = "Waiting for the operator to reconcile the latest spec"
+  end
+  return hs
+end
+
+local patched = conditions["IngressPatched"]
+if patched ~= nil and patched.status == "False" and
+    (patched.reason == "AwaitingConfirmation" or patched.reason == "AwaitingApproval") then
+  hs.status = "Suspended"
+  hs.message = patched.message
+  return hs
+end
+
+local gateway = conditions["GatewayAvailable"]
+if gateway ~= nil and gateway.status == "False" then
+  hs.status = "Degraded"
+  hs.message = gateway.message
+  return hs
+end
+
+if lastError ~= "" then
+  hs.status = "Degraded"
+  hs.message = lastError
+  return hs
+end
+
+local ready = conditions["Ready"]
+if obj.status.ready then
+  hs.status = "Healthy"
+  hs.message = "Route is active and serving traffic"
+  return hs
+end
+
+hs.status = "Degraded"
+if ready ~= nil and ready.message ~= nil then
+  hs.message = ready.message
+elseif patched ~= nil and patched.message ~= nil then
+  hs.message = patched.message
+else
+  hs.message = "Route is not ready"
+end
+return hs
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// annotationPrefix is the prefix of annotations owned by the operator.
+	annotationPrefix = "x402.io/"
+
+	// annotationApproved on an X402Route approves the pending Ingress patch.
+	annotationApproved = "x402.io/approved"
+)
+
+// checkPreserved verifies that a patch left everything outside the operator's
+// scope untouched: spec.tls, the default backend, the ingress class, rule hosts
+// (including wildcards) and annotations owned by other controllers.
+func checkPreserved(before, after *networkingv1.Ingress) error {
+	if !reflect.DeepEqual(before.Spec.TLS, after.Spec.TLS) {
+		return fmt.Errorf("patch would modify spec.tls")
+	}
+	if !reflect.DeepEqual(before.Spec.DefaultBackend, after.Spec.DefaultBackend) {
+		return fmt.Errorf("patch would modify spec.defaultBackend")
+	}
+	if !reflect.DeepEqual(before.Spec.IngressClassName, after.Spec.IngressClassName) {
+		return fmt.Errorf("patch would modify spec.ingressClassName")
+	}
+	if len(before.Spec.Rules) != len(after.Spec.Rules) {
+		return fmt.Errorf("patch would add or remove Ingress rules")
+	}
+	for i := range before.Spec.Rules {
+		if before.Spec.Rules[i].Host != after.Spec.Rules[i].Host {
+			return fmt.Errorf("patch would modify host %q", before.Spec.Rules[i].Host)
+		}
+	}
+	for key, val := range before.Annotations {
+		if strings.HasPrefix(key, annotationPrefix) {
+			continue
+		}
+		if after.Annotations[key] != val {
+			return fmt.Errorf("patch would modify annotation %q", key)
+		}
+	}
+	for key := range after.Annotations {
+		if _, ok := before.Annotations[key]; !ok && !strings.HasPrefix(key, annotationPrefix) {
+			return fmt.Errorf("patch would add annotation %q", key)
+		}
+	}
+	return nil
+}
+
+// patchView is the part of an Ingress rendered in a patch preview.
+type patchView struct {
+	Annotations map[string]string        `json:"annotations,omitempty"`
+	Spec        networkingv1.IngressSpec `json:"spec"`
+}
+
+// renderPatchDiff renders a unified diff of the annotations and spec of the
+// live and patched Ingress, similar to `kubectl diff`. It returns an empty
+// string when the patch is a no-op.
+func renderPatchDiff(live, patched *networkingv1.Ingress) (string, error) {
+	a, err := yaml.Marshal(patchView{Annotations: live.Annotations, Spec: live.Spec})
+	if err != nil {
+		return "", fmt.Errorf("marshal live ingress: %w", err)
+	}
+	b, err := yaml.Marshal(patchView{Annotations: patched.Annotations, Spec: patched.Spec})
+	if err != nil {
+		return "", fmt.Errorf("marshal patched ingress: %w", err)
+	}
+	if string(a) == string(b) {
+		return "", nil
+	}
+
+	name := live.Namespace + "/" + live.Name
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: "live/" + name,
+		ToFile:   "patched/" + name,
+		Context:  3,
+	})
+}
+
+// approvalRequired reports whether Ingress changes need a manual approval.
+func approvalRequired(route *x402v1alpha1.X402Route) bool {
+	return route.Spec.Approval != nil && route.Spec.Approval.Required
+}
+
+// patchHash returns a short, stable identifier for a patch preview.
+func patchHash(preview string) string {
+	sum := sha256.Sum256([]byte(preview))
+	return hex.EncodeToString(sum[:8])
+}
+
+// patchApproved reports whether the route's approval annotation covers the
+// pending patch. "true" approves whatever is pending; a hash approves only the
+// patch it identifies, so a later spec change cannot ride on a stale approval.
+func patchApproved(route *x402v1alpha1.X402Route, hash string) bool {
+	approved := route.Annotations[annotationApproved]
+	return approved == "true" || approved == hash
+}
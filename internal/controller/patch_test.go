@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func newTestIngress() *networkingv1.Ingress {
+	prefix := networkingv1.PathTypePrefix
+	className := "nginx"
+	paths := func() *networkingv1.HTTPIngressRuleValue {
+		return &networkingv1.HTTPIngressRuleValue{Paths: []networkingv1.HTTPIngressPath{{
+			Path:     "/",
+			PathType: &prefix,
+			Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+				Name: "my-api",
+				Port: networkingv1.ServiceBackendPort{Number: 8080},
+			}},
+		}}}
+	}
+
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-api-ingress",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"nginx.ingress.kubernetes.io/proxy-body-size": "8m",
+				"cert-manager.io/cluster-issuer":              "letsencrypt",
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &className,
+			TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"*.example.com", "api.example.com"}, SecretName: "example-tls"},
+			},
+			Rules: []networkingv1.IngressRule{
+				{Host: "*.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths()}},
+				{Host: "api.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths()}},
+			},
+		},
+	}
+}
+
+func newTestRoute() *x402v1alpha1.X402Route {
+	return &x402v1alpha1.X402Route{Spec: x402v1alpha1.X402RouteSpec{
+		Payment: x402v1alpha1.PaymentDefaults{Wallet: "0xabc", Network: "base-sepolia"},
+		Routes: []x402v1alpha1.RouteRule{
+			{Path: "/api/*"},
+			{Path: "/health", Free: true},
+		},
+	}}
+}
+
+func TestApplyGatewayPatchPreservesIngress(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	ingress := newTestIngress()
+	before := ingress.DeepCopy()
+
+	if err := r.applyGatewayPatch(newTestRoute(), ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	if err := checkPreserved(before, ingress); err != nil {
+		t.Fatalf("checkPreserved() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(before.Spec.TLS, ingress.Spec.TLS) {
+		t.Errorf("spec.tls changed: %+v", ingress.Spec.TLS)
+	}
+	for i, rule := range ingress.Spec.Rules {
+		if rule.Host != before.Spec.Rules[i].Host {
+			t.Errorf("rule %d host = %q, want %q", i, rule.Host, before.Spec.Rules[i].Host)
+		}
+		if got := rule.HTTP.Paths[0].Backend.Service.Name; got != externalSvcName {
+			t.Errorf("rule %d backend = %q, want %q", i, got, externalSvcName)
+		}
+		if len(rule.HTTP.Paths) != 2 || rule.HTTP.Paths[1].Path != "/health" {
+			t.Errorf("rule %d paths = %+v, want synthesized /health entry", i, rule.HTTP.Paths)
+		}
+	}
+	for key, val := range before.Annotations {
+		if ingress.Annotations[key] != val {
+			t.Errorf("annotation %q = %q, want %q", key, ingress.Annotations[key], val)
+		}
+	}
+}
+
+func TestApplyGatewayPatchVirtualHosts(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	ingress := newTestIngress()
+	ingress.Spec.Rules[0].Host = "data.example.com"
+	route := newTestRoute()
+	route.Spec.Routes = []x402v1alpha1.RouteRule{
+		{Path: "/api/*", Host: "api.example.com"},
+		{Path: "/health", Free: true},
+		{Path: "/status", Host: "data.example.com", Free: true},
+	}
+
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	data, api := ingress.Spec.Rules[0].HTTP.Paths, ingress.Spec.Rules[1].HTTP.Paths
+	if len(data) != 1 || data[0].Backend.Service.Name != "my-api" {
+		t.Errorf("data.example.com paths = %+v, want / left on my-api", data)
+	}
+	if api[0].Backend.Service.Name != externalSvcName {
+		t.Errorf("api.example.com backend = %q, want %q", api[0].Backend.Service.Name, externalSvcName)
+	}
+	if len(api) != 2 || api[1].Path != "/health" {
+		t.Errorf("api.example.com paths = %+v, want only the synthesized /health entry", api)
+	}
+}
+
+func TestCheckPreserved(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*networkingv1.Ingress)
+	}{
+		{name: "tls", mutate: func(i *networkingv1.Ingress) { i.Spec.TLS = nil }},
+		{name: "wildcard host", mutate: func(i *networkingv1.Ingress) { i.Spec.Rules[0].Host = "www.example.com" }},
+		{name: "foreign annotation", mutate: func(i *networkingv1.Ingress) {
+			i.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"] = "1m"
+		}},
+		{name: "ingress class", mutate: func(i *networkingv1.Ingress) { i.Spec.IngressClassName = nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := newTestIngress()
+			after := before.DeepCopy()
+			tt.mutate(after)
+			if err := checkPreserved(before, after); err == nil {
+				t.Error("checkPreserved() = nil, want error")
+			}
+		})
+	}
+}
+
+func TestPreviewPatch(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	ingress := newTestIngress()
+
+	preview, err := r.previewPatch(newTestRoute(), ingress)
+	if err != nil {
+		t.Fatalf("previewPatch() error = %v", err)
+	}
+	if !strings.Contains(preview, "+") || !strings.Contains(preview, externalSvcName) {
+		t.Errorf("preview does not show the gateway backend:\n%s", preview)
+	}
+	if _, ok := ingress.Annotations[annotationManagedBy]; ok {
+		t.Error("previewPatch mutated the live Ingress")
+	}
+
+	// Once applied, previewing again yields no pending changes, even when
+	// another operator version applied it.
+	if err := r.applyGatewayPatch(newTestRoute(), ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	ingress.Annotations[annotationOperatorVersion] = "v0.0.1"
+	preview, err = r.previewPatch(newTestRoute(), ingress)
+	if err != nil {
+		t.Fatalf("previewPatch() error = %v", err)
+	}
+	if preview != "" {
+		t.Errorf("preview after apply = %q, want empty", preview)
+	}
+}
+
+func TestPatchApproved(t *testing.T) {
+	hash := patchHash("--- live\n+++ patched\n")
+
+	tests := []struct {
+		name       string
+		annotation string
+		want       bool
+	}{
+		{name: "no annotation", annotation: "", want: false},
+		{name: "approved true", annotation: "true", want: true},
+		{name: "matching hash", annotation: hash, want: true},
+		{name: "stale hash", annotation: patchHash("older preview"), want: false},
+		{name: "other value", annotation: "yes", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestRoute()
+			route.Spec.Approval = &x402v1alpha1.ApprovalPolicy{Required: true}
+			if tt.annotation != "" {
+				route.Annotations = map[string]string{annotationApproved: tt.annotation}
+			}
+			if !approvalRequired(route) {
+				t.Fatal("approvalRequired() = false, want true")
+			}
+			if got := patchApproved(route, hash); got != tt.want {
+				t.Errorf("patchApproved(%q) = %v, want %v", tt.annotation, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileOriginalBackends(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	backend := func(name string, port int32) networkingv1.IngressBackend {
+		return networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+			Name: name,
+			Port: networkingv1.ServiceBackendPort{Number: port},
+		}}
+	}
+	ingress := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{
+		IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+			Paths: []networkingv1.HTTPIngressPath{
+				{Path: "/", Backend: backend(externalSvcName, gatewayPort)},
+				{Path: "/added", Backend: backend("new-svc", 9090)},
+				{Path: "/changed", Backend: backend("other-svc", 80)},
+			},
+		}},
+	}}}}
+	stored := map[string]string{
+		"/":        "my-api:8080",
+		"/changed": "old-svc:80",
+		"/removed": "gone-svc:80",
+	}
+
+	got := r.reconcileOriginalBackends(ingress, stored)
+	want := map[string]string{
+		"/":        "my-api:8080",
+		"/added":   "new-svc:9090",
+		"/changed": "other-svc:80",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reconcileOriginalBackends() = %v, want %v", got, want)
+	}
+}
+
+func TestCompileRouteTLSHosts(t *testing.T) {
+	compiled, err := (&X402RouteReconciler{}).compileRoute(newTestRoute(), nil, newTestIngress())
+	if err != nil {
+		t.Fatalf("compileRoute() error = %v", err)
+	}
+	if want := []string{"*.example.com", "api.example.com"}; !reflect.DeepEqual(compiled.TLSHosts, want) {
+		t.Errorf("TLSHosts = %v, want %v", compiled.TLSHosts, want)
+	}
+}
+
+func TestCompileRouteRuleHosts(t *testing.T) {
+	route := newTestRoute()
+	route.Spec.Routes = append(route.Spec.Routes, x402v1alpha1.RouteRule{Path: "/v1/*", Host: "data.example.com", Price: "0.05"})
+	compiled, err := (&X402RouteReconciler{}).compileRoute(route, nil, newTestIngress())
+	if err != nil {
+		t.Fatalf("compileRoute() error = %v", err)
+	}
+	if got := compiled.Rules[2].Host; got != "data.example.com" {
+		t.Errorf("rule host = %q, want data.example.com", got)
+	}
+
+	route.Spec.Routes[2].Host = "data.example.org"
+	if _, err := (&X402RouteReconciler{}).compileRoute(route, nil, newTestIngress()); err == nil || !strings.Contains(err.Error(), "not served by Ingress") {
+		t.Errorf("compileRoute() error = %v, want host not served", err)
+	}
+}
@@ -1,6 +1,10 @@
 package controller
 
-import "testing"
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
 
 func TestPathMatchesPaidRoutes(t *testing.T) {
 	r := &X402RouteReconciler{}
@@ -48,3 +52,36 @@ func TestPathMatchesPaidRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestIngressPathGated(t *testing.T) {
+	r := &X402RouteReconciler{}
+	exact := networkingv1.PathTypeExact
+	prefix := networkingv1.PathTypePrefix
+
+	tests := []struct {
+		name      string
+		path      string
+		pathType  *networkingv1.PathType
+		paidPaths []string
+		want      bool
+	}{
+		{name: "exact / not gated by paid /api/*", path: "/", pathType: &exact, paidPaths: []string{"/api/*"}, want: false},
+		{name: "prefix / gated by paid /api/*", path: "/", pathType: &prefix, paidPaths: []string{"/api/*"}, want: true},
+		{name: "exact /api gated by paid /api/*", path: "/api", pathType: &exact, paidPaths: []string{"/api/*"}, want: true},
+		{name: "exact /api/v1 gated by paid /api/**", path: "/api/v1", pathType: &exact, paidPaths: []string{"/api/**"}, want: true},
+		{name: "exact /api not gated by paid /api/v1/*", path: "/api", pathType: &exact, paidPaths: []string{"/api/v1/*"}, want: false},
+		{name: "exact /users/1/posts gated by paid /users/*/posts", path: "/users/1/posts", pathType: &exact, paidPaths: []string{"/users/*/posts"}, want: true},
+		{name: "exact /data gated by paid /data", path: "/data", pathType: &exact, paidPaths: []string{"/data"}, want: true},
+		{name: "nil pathType uses prefix heuristics", path: "/", pathType: nil, paidPaths: []string{"/api/*"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := networkingv1.HTTPIngressPath{Path: tt.path, PathType: tt.pathType}
+			got := r.ingressPathGated(p, tt.paidPaths)
+			if got != tt.want {
+				t.Errorf("ingressPathGated(%q, %v) = %v, want %v", tt.path, tt.paidPaths, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+const (
+	// annotationPaused set to "true" on an X402Route, or on its Ingress,
+	// freezes the route: the controller stops changing the Ingress and the
+	// gateway forwards the route's paid paths without payment.
+	annotationPaused = "x402.io/paused"
+
+	// conditionPaused reports a route frozen by annotationPaused.
+	conditionPaused = "Paused"
+)
+
+// isPaused reports whether obj carries the paused annotation.
+func isPaused(obj metav1.Object) bool {
+	return obj.GetAnnotations()[annotationPaused] == "true"
+}
+
+// pause freezes a route paused by the annotation on the object described by
+// by. The stored route keeps serving with payment enforcement off, and the
+// Ingress is not patched. When nothing is stored, e.g. after the manager
+// restarted, the route is compiled once so the gateway forwards its paths
+// without payment instead of answering 404. ingress is nil when it was not
+// fetched yet.
+func (r *X402RouteReconciler) pause(ctx context.Context, route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress, by string) error {
+	var err error
+	switch stored := r.RouteStore.Get(route.Namespace, route.Name); {
+	case stored == nil:
+		var compiled *routestore.CompiledRoute
+		if compiled, err = r.compilePaused(ctx, route, ingress); compiled != nil {
+			r.RouteStore.Set(route.Namespace, route.Name, compiled)
+			metrics.RouteStoreUpdatesTotal.Inc()
+			metrics.ActiveRoutes.Set(float64(r.RouteStore.Count()))
+		}
+	case !stored.Paused:
+		paused := *stored
+		paused.Paused = true
+		r.RouteStore.Set(route.Namespace, route.Name, &paused)
+		metrics.RouteStoreUpdatesTotal.Inc()
+	}
+	msg := fmt.Sprintf("Reconciliation and payment enforcement paused by the %s annotation on %s; remove it to resume", annotationPaused, by)
+	r.setCondition(route, conditionPaused, metav1.ConditionTrue, "Annotated", msg)
+	r.setCondition(route, "Ready", metav1.ConditionFalse, "Paused", msg)
+	route.Status.Ready = false
+	metrics.PausedRoutes.WithLabelValues(route.Namespace, route.Name).Set(1)
+	return err
+}
+
+// compilePaused compiles a paused route that has no stored route, with the
+// same inputs as an active one. It returns nil without an error when the
+// Ingress does not exist or does not grant the route, as there is nothing to
+// serve then.
+func (r *X402RouteReconciler) compilePaused(ctx context.Context, route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress) (*routestore.CompiledRoute, error) {
+	route = route.DeepCopy()
+	if ingress == nil {
+		key := types.NamespacedName{Name: route.Spec.IngressRef.Name, Namespace: route.Spec.IngressRef.Namespace}
+		if key.Namespace == "" {
+			key.Namespace = route.Namespace
+		}
+		ingress = &networkingv1.Ingress{}
+		if err := r.Get(ctx, key, ingress); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		if !referenceGranted(route, ingress) {
+			return nil, nil
+		}
+	}
+
+	source, backends, err := r.resolveSources(ctx, route, ingress)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := r.compileRoute(source, backends, ingress)
+	if err != nil {
+		return nil, reconcile.TerminalError(fmt.Errorf("compile: %w", err))
+	}
+	compiled.Paused = true
+	return compiled, nil
+}
+
+// resume clears the pause of a route. The paused stored route is replaced by
+// the next compile, since the compile cache never reuses it.
+func (r *X402RouteReconciler) resume(route *x402v1alpha1.X402Route) {
+	meta.RemoveStatusCondition(&route.Status.Conditions, conditionPaused)
+	metrics.PausedRoutes.DeleteLabelValues(route.Namespace, route.Name)
+}
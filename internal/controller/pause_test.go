@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestReconcilePaused(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+	ingressKey := types.NamespacedName{Namespace: "default", Name: "my-api-ingress"}
+
+	route := newTestRoute()
+	route.Name, route.Namespace = key.Name, key.Namespace
+	route.Finalizers = []string{finalizerName}
+	route.Spec.IngressRef.Name = ingressKey.Name
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(route, newTestIngress()).WithStatusSubresource(route).Build()
+	r := &X402RouteReconciler{
+		Client:            c,
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+	reconcile := func() *x402v1alpha1.X402Route {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		var got x402v1alpha1.X402Route
+		if err := c.Get(ctx, key, &got); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return &got
+	}
+	annotate := func(obj client.Object, k types.NamespacedName, value string) {
+		t.Helper()
+		if err := c.Get(ctx, k, obj); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		obj.SetAnnotations(map[string]string{annotationPaused: value})
+		if err := c.Update(ctx, obj); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	ingressSpec := func() networkingv1.IngressSpec {
+		t.Helper()
+		var ing networkingv1.Ingress
+		if err := c.Get(ctx, ingressKey, &ing); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return ing.Spec
+	}
+
+	reconcile()
+	if compiled := r.RouteStore.Get(key.Namespace, key.Name); compiled == nil || compiled.Paused {
+		t.Fatalf("stored route = %+v, want an active route", compiled)
+	}
+	patched := ingressSpec()
+
+	tests := []struct {
+		name    string
+		pause   func()
+		wantMsg string
+	}{
+		{
+			name:    "route annotation",
+			pause:   func() { annotate(&x402v1alpha1.X402Route{}, key, "true") },
+			wantMsg: "the X402Route",
+		},
+		{
+			name:    "ingress annotation",
+			pause:   func() { annotate(&networkingv1.Ingress{}, ingressKey, "true") },
+			wantMsg: "Ingress default/my-api-ingress",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(r.RouteStore.Get(key.Namespace, key.Name).Rules)
+			tt.pause()
+			// A spec change while paused is not applied.
+			var current x402v1alpha1.X402Route
+			c.Get(ctx, key, &current)
+			current.Spec.Routes = append(current.Spec.Routes, x402v1alpha1.RouteRule{Path: "/premium/*"})
+			if err := c.Update(ctx, &current); err != nil {
+				t.Fatal(err)
+			}
+
+			got := reconcile()
+			cond := meta.FindStatusCondition(got.Status.Conditions, conditionPaused)
+			if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, tt.wantMsg) {
+				t.Errorf("Paused condition = %+v, want True naming %s", cond, tt.wantMsg)
+			}
+			if ready := meta.FindStatusCondition(got.Status.Conditions, "Ready"); ready == nil || ready.Reason != "Paused" {
+				t.Errorf("Ready condition = %+v, want reason Paused", ready)
+			}
+			compiled := r.RouteStore.Get(key.Namespace, key.Name)
+			if !compiled.Paused || len(compiled.Rules) != before {
+				t.Errorf("stored route paused = %v with %d rules, want the paused route as compiled before", compiled.Paused, len(compiled.Rules))
+			}
+			if !reflect.DeepEqual(ingressSpec(), patched) {
+				t.Error("Ingress changed while paused")
+			}
+
+			// Removing the annotation resumes the route with the new spec.
+			if tt.name == "route annotation" {
+				annotate(&x402v1alpha1.X402Route{}, key, "false")
+			} else {
+				annotate(&networkingv1.Ingress{}, ingressKey, "false")
+			}
+			got = reconcile()
+			if cond := meta.FindStatusCondition(got.Status.Conditions, conditionPaused); cond != nil {
+				t.Errorf("Paused condition = %+v after resuming, want none", cond)
+			}
+			compiled = r.RouteStore.Get(key.Namespace, key.Name)
+			if compiled.Paused || len(compiled.Rules) != len(current.Spec.Routes) {
+				t.Errorf("stored route paused = %v with %d rules after resuming, want %d active rules", compiled.Paused, len(compiled.Rules), len(current.Spec.Routes))
+			}
+			patched = ingressSpec()
+		})
+	}
+}
+
+func TestReconcilePausedEmptyStore(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+	ingressKey := types.NamespacedName{Namespace: "default", Name: "my-api-ingress"}
+
+	tests := []struct {
+		name    string
+		ingress bool // the Ingress carries the annotation instead of the route
+	}{
+		{name: "route annotation"},
+		{name: "ingress annotation", ingress: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestRoute()
+			route.Name, route.Namespace = key.Name, key.Namespace
+			route.Finalizers = []string{finalizerName}
+			route.Spec.IngressRef.Name = ingressKey.Name
+			ingress := newTestIngress()
+			if tt.ingress {
+				ingress.Annotations[annotationPaused] = "true"
+			} else {
+				route.Annotations = map[string]string{annotationPaused: "true"}
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(route, ingress).WithStatusSubresource(route).Build()
+			// An empty store, as after a restart of the manager.
+			r := &X402RouteReconciler{
+				Client:            c,
+				RouteStore:        routestore.New(),
+				OperatorNamespace: "x402-system",
+				OperatorSvcName:   "x402-k8s-operator",
+			}
+
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			compiled := r.RouteStore.Get(key.Namespace, key.Name)
+			if compiled == nil || !compiled.Paused || len(compiled.Rules) != len(route.Spec.Routes) {
+				t.Fatalf("stored route = %+v, want the paused route with %d rules", compiled, len(route.Spec.Routes))
+			}
+			var got networkingv1.Ingress
+			if err := c.Get(ctx, ingressKey, &got); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Spec, ingress.Spec) {
+				t.Error("Ingress patched while paused")
+			}
+		})
+	}
+}
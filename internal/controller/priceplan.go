@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+// applyPricePlan merges the rules of the X402PricePlan route references into
+// route.Spec in memory, so compiling, patching the Ingress and the rule
+// statuses all see the effective rules. The stored route keeps only the
+// reference.
+func (r *X402RouteReconciler) applyPricePlan(ctx context.Context, route *x402v1alpha1.X402Route) error {
+	ref := route.Spec.PricePlanRef
+	if ref == nil {
+		return nil
+	}
+	var plan x402v1alpha1.X402PricePlan
+	if err := r.Get(ctx, types.NamespacedName{Namespace: route.Namespace, Name: ref.Name}, &plan); err != nil {
+		return fmt.Errorf("read X402PricePlan %s: %w", ref.Name, err)
+	}
+	mergePricePlan(&route.Spec, &plan.Spec)
+	return nil
+}
+
+// mergePricePlan appends the plan rules whose host and path have no rule in
+// spec, and takes the plan's default price when spec sets none. Own rules come
+// first, so they win where their paths overlap the plan's.
+func mergePricePlan(spec *x402v1alpha1.X402RouteSpec, plan *x402v1alpha1.X402PricePlanSpec) {
+	own := make(map[string]bool, len(spec.Routes))
+	for _, rule := range spec.Routes {
+		own[ruleKey(rule.Host, rule.Path)] = true
+	}
+	rules := make([]x402v1alpha1.RouteRule, 0, len(spec.Routes)+len(plan.Routes))
+	rules = append(rules, spec.Routes...)
+	for _, rule := range plan.Routes {
+		if !own[ruleKey(rule.Host, rule.Path)] {
+			rules = append(rules, *rule.DeepCopy())
+		}
+	}
+	spec.Routes = rules
+	if spec.Payment.DefaultPrice == "" {
+		spec.Payment.DefaultPrice = plan.DefaultPrice
+	}
+}
+
+// pricePlanToX402Routes maps an X402PricePlan event to the routes in its
+// namespace that reference it, so plan changes reach every route.
+func (r *X402RouteReconciler) pricePlanToX402Routes(ctx context.Context, obj client.Object) []reconcile.Request {
+	var routeList x402v1alpha1.X402RouteList
+	if err := r.List(ctx, &routeList, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list X402Routes for X402PricePlan watch")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, route := range routeList.Items {
+		if ref := route.Spec.PricePlanRef; ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: route.Namespace, Name: route.Name}})
+		}
+	}
+	return requests
+}
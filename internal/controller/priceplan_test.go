@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestMergePricePlan(t *testing.T) {
+	plan := &x402v1alpha1.X402PricePlanSpec{
+		DefaultPrice: "0.001",
+		Routes: []x402v1alpha1.RouteRule{
+			{Path: "/api/*", Price: "0.01"},
+			{Path: "/health", Free: true},
+			{Path: "/docs/**", Free: true},
+		},
+	}
+
+	spec := newTestRoute().Spec
+	spec.Routes[0].Price = "0.05"
+	mergePricePlan(&spec, plan)
+	var paths []string
+	for _, rule := range spec.Routes {
+		paths = append(paths, rule.Path)
+	}
+	if want := []string{"/api/*", "/health", "/docs/**"}; len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] || paths[2] != want[2] {
+		t.Errorf("merged paths = %v, want %v", paths, want)
+	}
+	if spec.Routes[0].Price != "0.05" {
+		t.Errorf("own rule price = %q, want the route's 0.05", spec.Routes[0].Price)
+	}
+	if spec.Payment.DefaultPrice != "0.001" {
+		t.Errorf("default price = %q, want the plan's 0.001", spec.Payment.DefaultPrice)
+	}
+
+	spec = newTestRoute().Spec
+	spec.Payment.DefaultPrice = "0.002"
+	mergePricePlan(&spec, plan)
+	if spec.Payment.DefaultPrice != "0.002" {
+		t.Errorf("default price = %q, want the route's 0.002", spec.Payment.DefaultPrice)
+	}
+}
+
+func TestReconcilePricePlan(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+
+	route := newTestRoute()
+	route.Name, route.Namespace = key.Name, key.Namespace
+	route.Finalizers = []string{finalizerName}
+	route.Spec.IngressRef.Name = "my-api-ingress"
+	route.Spec.Routes = []x402v1alpha1.RouteRule{{Path: "/health", Free: true}}
+	route.Spec.PricePlanRef = &x402v1alpha1.PricePlanReference{Name: "standard"}
+	plan := &x402v1alpha1.X402PricePlan{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "standard"},
+		Spec: x402v1alpha1.X402PricePlanSpec{
+			DefaultPrice: "0.001",
+			Routes:       []x402v1alpha1.RouteRule{{Path: "/api/*"}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(route, newTestIngress()).WithStatusSubresource(route).Build()
+	r := &X402RouteReconciler{
+		Client:            c,
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+
+	// Without the plan the route waits for it.
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	var current x402v1alpha1.X402Route
+	if err := c.Get(ctx, key, &current); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cond := meta.FindStatusCondition(current.Status.Conditions, "Ready"); cond == nil || cond.Reason != "PricePlanUnavailable" {
+		t.Errorf("Ready condition = %+v, want reason PricePlanUnavailable", cond)
+	}
+	if r.RouteStore.Get(key.Namespace, key.Name) != nil {
+		t.Error("route without its plan was stored")
+	}
+	if reqs := r.pricePlanToX402Routes(ctx, plan); len(reqs) != 1 || reqs[0].NamespacedName != key {
+		t.Errorf("pricePlanToX402Routes() = %v, want %v", reqs, key)
+	}
+
+	if err := c.Create(ctx, plan); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	compiled := r.RouteStore.Get(key.Namespace, key.Name)
+	if compiled == nil || len(compiled.Rules) != 2 || compiled.Rules[1].Path != "/api/*" || compiled.Rules[1].Price != "0.001" {
+		t.Fatalf("compiled route = %+v, want the plan rule at the plan price", compiled)
+	}
+
+	// A plan change reaches the route; the stored route keeps its own rules.
+	plan.Spec.DefaultPrice = "0.002"
+	if err := c.Update(ctx, plan); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if compiled = r.RouteStore.Get(key.Namespace, key.Name); compiled.Rules[1].Price != "0.002" {
+		t.Errorf("plan rule price = %q after the plan change, want 0.002", compiled.Rules[1].Price)
+	}
+	if err := c.Get(ctx, key, &current); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(current.Spec.Routes) != 1 || current.Spec.Payment.DefaultPrice != "" {
+		t.Errorf("stored spec = %+v, want the route's own rules only", current.Spec)
+	}
+}
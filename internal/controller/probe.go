@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/gateway"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+const (
+	// conditionProbeSucceeded reports the last synthetic paid request
+	// through the gateway.
+	conditionProbeSucceeded = "ProbeSucceeded"
+
+	// probeTimeout bounds the probe of one route.
+	probeTimeout = 30 * time.Second
+)
+
+// RouteProber is a manager runnable that periodically sends a paid request
+// through the gateway for every route and records the result in the route's
+// ProbeSucceeded condition and the x402_probe_succeeded metric. It runs on
+// the leader only.
+type RouteProber struct {
+	Client     client.Client
+	RouteStore *routestore.Store
+	// Probe sends a paid request for route and returns a summary of the
+	// answer, or why it failed.
+	Probe    func(ctx context.Context, route *routestore.CompiledRoute) (string, error)
+	Interval time.Duration
+
+	probed map[types.NamespacedName]bool // routes with a metric series
+}
+
+// Start implements manager.Runnable. It probes every route each Interval
+// until the manager shuts down.
+func (p *RouteProber) Start(ctx context.Context) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.probeRoutes(ctx)
+		}
+	}
+}
+
+// probeRoutes probes every compiled route in turn and removes the metric
+// series of deleted routes.
+func (p *RouteProber) probeRoutes(ctx context.Context) {
+	logger := ctrl.Log.WithName("prober")
+	seen := map[types.NamespacedName]bool{}
+	for _, route := range p.RouteStore.Snapshot() {
+		key := types.NamespacedName{Namespace: route.Namespace, Name: route.Name}
+		seen[key] = true
+
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		summary, err := p.Probe(probeCtx, route)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		status, reason, message := metav1.ConditionTrue, "ProbePassed", summary
+		switch {
+		case errors.Is(err, gateway.ErrNoProbePath):
+			status, reason, message = metav1.ConditionUnknown, "NoPaidPath", err.Error()
+			metrics.ProbeSucceeded.DeleteLabelValues(route.Namespace, route.Name)
+		case err != nil:
+			status, reason, message = metav1.ConditionFalse, "ProbeFailed", err.Error()
+			metrics.ProbeSucceeded.WithLabelValues(route.Namespace, route.Name).Set(0)
+			logger.Info("synthetic probe failed", "route", key.String(), "error", err)
+		default:
+			metrics.ProbeSucceeded.WithLabelValues(route.Namespace, route.Name).Set(1)
+		}
+		if err := p.setCondition(ctx, key, status, reason, message); err != nil {
+			logger.Error(err, "failed to record probe result", "route", key.String())
+		}
+	}
+
+	for key := range p.probed {
+		if !seen[key] {
+			metrics.ProbeSucceeded.DeleteLabelValues(key.Namespace, key.Name)
+		}
+	}
+	p.probed = seen
+}
+
+// setCondition patches the ProbeSucceeded condition of a route when it
+// changed.
+func (p *RouteProber) setCondition(ctx context.Context, key types.NamespacedName, status metav1.ConditionStatus, reason, message string) error {
+	var route x402v1alpha1.X402Route
+	if err := p.Client.Get(ctx, key, &route); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if c := meta.FindStatusCondition(route.Status.Conditions, conditionProbeSucceeded); c != nil &&
+		c.Status == status && c.Reason == reason && c.Message == message && c.ObservedGeneration == route.Generation {
+		return nil
+	}
+	base := route.DeepCopy()
+	meta.SetStatusCondition(&route.Status.Conditions, metav1.Condition{
+		Type:               conditionProbeSucceeded,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: route.Generation,
+		LastTransitionTime: metav1.Now(),
+	})
+	if err := p.Client.Status().Patch(ctx, &route, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("patch status: %w", err)
+	}
+	return nil
+}
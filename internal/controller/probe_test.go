@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/gateway"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestRouteProber(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "probe-api"}
+
+	route := newTestRoute()
+	route.Name, route.Namespace, route.Generation = key.Name, key.Namespace, 3
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(route).WithStatusSubresource(route).Build()
+	store := routestore.New()
+	store.Set(key.Namespace, key.Name, &routestore.CompiledRoute{Namespace: key.Namespace, Name: key.Name})
+
+	var result error
+	p := &RouteProber{
+		Client:     c,
+		RouteStore: store,
+		Probe: func(context.Context, *routestore.CompiledRoute) (string, error) {
+			return "Paid GET /api/x402-probe answered 200", result
+		},
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus metav1.ConditionStatus
+		wantReason string
+		wantMetric float64 // -1 when the series is absent
+	}{
+		{name: "passed", wantStatus: metav1.ConditionTrue, wantReason: "ProbePassed", wantMetric: 1},
+		{name: "failed", err: errors.New("paid GET /api/x402-probe answered 502"), wantStatus: metav1.ConditionFalse, wantReason: "ProbeFailed", wantMetric: 0},
+		{name: "no paid path", err: gateway.ErrNoProbePath, wantStatus: metav1.ConditionUnknown, wantReason: "NoPaidPath", wantMetric: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result = tt.err
+			p.probeRoutes(ctx)
+
+			var got x402v1alpha1.X402Route
+			if err := c.Get(ctx, key, &got); err != nil {
+				t.Fatal(err)
+			}
+			cond := meta.FindStatusCondition(got.Status.Conditions, conditionProbeSucceeded)
+			if cond == nil || cond.Status != tt.wantStatus || cond.Reason != tt.wantReason || cond.ObservedGeneration != 3 {
+				t.Fatalf("condition = %+v, want %s/%s", cond, tt.wantStatus, tt.wantReason)
+			}
+			if tt.err != nil && cond.Message != tt.err.Error() {
+				t.Errorf("message = %q, want %q", cond.Message, tt.err.Error())
+			}
+			if got := probeMetric(key); got != tt.wantMetric {
+				t.Errorf("x402_probe_succeeded = %v, want %v", got, tt.wantMetric)
+			}
+		})
+	}
+
+	// Deleted routes lose their series.
+	result = nil
+	p.probeRoutes(ctx)
+	store.Delete(key.Namespace, key.Name)
+	p.probeRoutes(ctx)
+	if got := probeMetric(key); got != -1 {
+		t.Errorf("x402_probe_succeeded of a deleted route = %v, want no series", got)
+	}
+}
+
+// probeMetric returns the x402_probe_succeeded value of a route, or -1 when
+// the metric has no series.
+func probeMetric(key types.NamespacedName) float64 {
+	if testutil.CollectAndCount(metrics.ProbeSucceeded) == 0 {
+		return -1
+	}
+	return testutil.ToFloat64(metrics.ProbeSucceeded.WithLabelValues(key.Namespace, key.Name))
+}
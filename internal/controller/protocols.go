@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// annotationNginxBackendProtocol is the ingress-nginx annotation naming the
+// protocol of every backend of an Ingress.
+const annotationNginxBackendProtocol = "nginx.ingress.kubernetes.io/backend-protocol"
+
+// resolveProtocols sets the protocol the gateway speaks to each backend: the
+// one listed in spec.backendProtocols, else the one of the Ingress's
+// backend-protocol annotation, else the one implied by the appProtocol of the
+// backend's Service port. Unknown backends keep HTTP/1.1. Backends spoken to
+// over TLS get an https:// URL.
+func (r *X402RouteReconciler) resolveProtocols(ctx context.Context, route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress, backends []routestore.CompiledBackend) {
+	r.resolveBackendProtocols(ctx, route, ingress, backends)
+	for i := range backends {
+		if tlsProtocol(backends[i].Protocol) {
+			backends[i].URL = strings.Replace(backends[i].URL, "http://", "https://", 1)
+		}
+	}
+}
+
+// resolveBackendProtocols sets the protocol of each backend for
+// resolveProtocols.
+func (r *X402RouteReconciler) resolveBackendProtocols(ctx context.Context, route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress, backends []routestore.CompiledBackend) {
+	overrides := make(map[string]string, len(route.Spec.BackendProtocols))
+	for _, bp := range route.Spec.BackendProtocols {
+		overrides[bp.Service] = bp.Protocol
+	}
+	namespace := ingress.Namespace
+	ingressProtocol := nginxBackendProtocol(ingress.Annotations[annotationNginxBackendProtocol])
+
+	services := make(map[string]*corev1.Service)
+	for i := range backends {
+		b := &backends[i]
+		if protocol, ok := overrides[b.Service]; ok {
+			b.Protocol = protocol
+			continue
+		}
+		if ingressProtocol != "" {
+			b.Protocol = ingressProtocol
+			continue
+		}
+		svc, ok := services[b.Service]
+		if !ok {
+			svc = &corev1.Service{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: b.Service}, svc); err != nil {
+				if !apierrors.IsNotFound(err) {
+					log.FromContext(ctx).Error(err, "failed to read backend Service, using HTTP/1.1", "service", b.Service)
+				}
+				svc = nil
+			}
+			services[b.Service] = svc
+		}
+		if svc == nil {
+			continue
+		}
+		for _, sp := range svc.Spec.Ports {
+			if sp.Port == b.Port && sp.AppProtocol != nil {
+				b.Protocol = appProtocolToProtocol(*sp.AppProtocol)
+			}
+		}
+	}
+}
+
+// nginxBackendProtocol maps an ingress-nginx backend-protocol annotation value
+// to a backend protocol. HTTPS is HTTP/1.1 over TLS, as ingress-nginx speaks
+// it. Values the gateway cannot speak, such as FCGI, and HTTP leave the
+// protocol to the Service.
+func nginxBackendProtocol(value string) string {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "HTTPS":
+		return "https"
+	case "GRPC":
+		return "h2c"
+	case "GRPCS":
+		return "h2"
+	default:
+		return ""
+	}
+}
+
+// tlsProtocol reports whether a backend protocol runs over TLS.
+func tlsProtocol(protocol string) bool {
+	return protocol == "h2" || protocol == "https"
+}
+
+// appProtocolToProtocol maps a Service port appProtocol to a backend protocol.
+func appProtocolToProtocol(appProtocol string) string {
+	switch appProtocol {
+	case "kubernetes.io/h2c", "h2c", "grpc":
+		return "h2c"
+	case "h2", "https", "grpcs":
+		return "h2"
+	default:
+		return ""
+	}
+}
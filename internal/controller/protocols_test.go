@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestResolveProtocols(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+
+	h2c := "kubernetes.io/h2c"
+	service := func(name string, appProtocol *string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "web", Port: 8080, AppProtocol: appProtocol}}},
+		}
+	}
+	r := &X402RouteReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		service("grpc", &h2c),
+		service("web", nil),
+		service("pinned", &h2c),
+	).Build()}
+
+	route := newTestRoute()
+	route.Spec.BackendProtocols = []x402v1alpha1.BackendProtocol{{Service: "pinned", Protocol: "http1"}}
+	backends := []routestore.CompiledBackend{
+		{Path: "/grpc", Service: "grpc", Port: 8080},
+		{Path: "/grpc-other-port", Service: "grpc", Port: 9090},
+		{Path: "/web", Service: "web", Port: 8080},
+		{Path: "/pinned", Service: "pinned", Port: 8080},
+		{Path: "/missing", Service: "missing", Port: 8080},
+	}
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}
+	r.resolveProtocols(context.Background(), route, ingress, backends)
+
+	want := map[string]string{"/grpc": "h2c", "/grpc-other-port": "", "/web": "", "/pinned": "http1", "/missing": ""}
+	for _, b := range backends {
+		if b.Protocol != want[b.Path] {
+			t.Errorf("backend %s protocol = %q, want %q", b.Path, b.Protocol, want[b.Path])
+		}
+	}
+}
+
+func TestResolveProtocolsBackendProtocolAnnotation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	r := &X402RouteReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+
+	tests := []struct {
+		annotation   string
+		wantProtocol string
+		wantURL      string
+	}{
+		{annotation: "HTTPS", wantProtocol: "https", wantURL: "https://api.default.svc.cluster.local:8443"},
+		{annotation: "GRPCS", wantProtocol: "h2", wantURL: "https://api.default.svc.cluster.local:8443"},
+		{annotation: "GRPC", wantProtocol: "h2c", wantURL: "http://api.default.svc.cluster.local:8443"},
+		{annotation: "HTTP", wantProtocol: "", wantURL: "http://api.default.svc.cluster.local:8443"},
+		{annotation: "FCGI", wantProtocol: "", wantURL: "http://api.default.svc.cluster.local:8443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.annotation, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+				Name:        "api",
+				Namespace:   "default",
+				Annotations: map[string]string{annotationNginxBackendProtocol: tt.annotation},
+			}}
+			backends := []routestore.CompiledBackend{
+				{Path: "/", Service: "api", Port: 8443, URL: "http://api.default.svc.cluster.local:8443"},
+			}
+			r.resolveProtocols(context.Background(), newTestRoute(), ingress, backends)
+			if backends[0].Protocol != tt.wantProtocol || backends[0].URL != tt.wantURL {
+				t.Errorf("backend = %q %s, want %q %s", backends[0].Protocol, backends[0].URL, tt.wantProtocol, tt.wantURL)
+			}
+		})
+	}
+
+	// spec.backendProtocols wins over the annotation.
+	route := newTestRoute()
+	route.Spec.BackendProtocols = []x402v1alpha1.BackendProtocol{{Service: "api", Protocol: "http1"}}
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "default",
+		Annotations: map[string]string{annotationNginxBackendProtocol: "HTTPS"},
+	}}
+	backends := []routestore.CompiledBackend{{Path: "/", Service: "api", Port: 8443, URL: "http://api.default.svc.cluster.local:8443"}}
+	r.resolveProtocols(context.Background(), route, ingress, backends)
+	if backends[0].Protocol != "http1" || backends[0].URL != "http://api.default.svc.cluster.local:8443" {
+		t.Errorf("overridden backend = %q %s", backends[0].Protocol, backends[0].URL)
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+)
+
+// reconcileResult is the result label of x402_controller_reconciles_total
+// for a reconcile that returned err after waiting on waitErr, if any.
+func reconcileResult(err, waitErr error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case waitErr != nil:
+		return "waiting"
+	}
+	return "success"
+}
+
+// serviceSet tracks the namespaces holding the operator's ExternalName
+// Service and reports their number in x402_controller_external_services.
+// Every route ensures its Service when reconciled, so the set is rebuilt
+// after a restart. The zero value is ready to use.
+type serviceSet struct {
+	mu         sync.Mutex
+	namespaces map[string]struct{}
+}
+
+// add records the Service of namespace.
+func (s *serviceSet) add(namespace string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.namespaces == nil {
+		s.namespaces = make(map[string]struct{})
+	}
+	s.namespaces[namespace] = struct{}{}
+	metrics.ExternalServices.Set(float64(len(s.namespaces)))
+}
+
+// remove drops the Service of namespace, which was deleted.
+func (s *serviceSet) remove(namespace string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.namespaces, namespace)
+	metrics.ExternalServices.Set(float64(len(s.namespaces)))
+}
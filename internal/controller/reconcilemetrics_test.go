@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestReconcileMetrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+	route := newTestRoute()
+	route.Name, route.Namespace = key.Name, key.Namespace
+	route.Spec.IngressRef.Name = "my-api-ingress"
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(route).WithStatusSubresource(route).Build()
+	r := &X402RouteReconciler{
+		Client:            c,
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+	counts := func() map[string]float64 {
+		return map[string]float64{
+			"success":  testutil.ToFloat64(metrics.ReconcilesTotal.WithLabelValues("success")),
+			"waiting":  testutil.ToFloat64(metrics.ReconcilesTotal.WithLabelValues("waiting")),
+			"patched":  testutil.ToFloat64(metrics.IngressPatchesTotal.WithLabelValues("applied")),
+			"restored": testutil.ToFloat64(metrics.IngressRestoresTotal.WithLabelValues("restored")),
+		}
+	}
+	reconcile := func(want map[string]float64) {
+		t.Helper()
+		before := counts()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		after := counts()
+		for name := range after {
+			if got := after[name] - before[name]; got != want[name] {
+				t.Errorf("%s changed by %v, want %v", name, got, want[name])
+			}
+		}
+	}
+
+	// Without its Ingress the route waits.
+	reconcile(map[string]float64{"waiting": 1})
+
+	if err := c.Create(ctx, newTestIngress()); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	reconcile(map[string]float64{"success": 1, "patched": 1})
+	if got := testutil.ToFloat64(metrics.ExternalServices); got != 1 {
+		t.Errorf("external services = %v, want 1", got)
+	}
+	// Nothing changes on the second pass.
+	reconcile(map[string]float64{"success": 1})
+
+	if err := c.Delete(ctx, route); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	reconcile(map[string]float64{"success": 1, "restored": 1})
+	if got := testutil.ToFloat64(metrics.ExternalServices); got != 0 {
+		t.Errorf("external services = %v after the deletion, want 0", got)
+	}
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/jsonschema"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// requestSchemaResync is how often routes reading a JSON Schema from a
+// ConfigMap are reconciled to pick up a changed schema.
+const requestSchemaResync = time.Minute
+
+// defaultValidationMaxBodyBytes bounds the body read for schema validation.
+const defaultValidationMaxBodyBytes = 1 << 20
+
+// resolveRequestSchemas reads the JSON Schemas of the rules' jsonSchemaRef
+// from ConfigMaps in the route namespace into their jsonSchema, in memory
+// like applyPricePlan, so the schema is part of the compiled spec. The
+// ConfigMaps are read uncached, as the operator does not watch them.
+func (r *X402RouteReconciler) resolveRequestSchemas(ctx context.Context, route *x402v1alpha1.X402Route) error {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	for i := range route.Spec.Routes {
+		rule := &route.Spec.Routes[i]
+		if rule.RequestValidation == nil || rule.RequestValidation.JSONSchemaRef == nil {
+			continue
+		}
+		ref := rule.RequestValidation.JSONSchemaRef
+		var cm corev1.ConfigMap
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: route.Namespace, Name: ref.Name}, &cm); err != nil {
+			return fmt.Errorf("rule %q: read JSON Schema ConfigMap %s: %w", rule.Path, ref.Name, err)
+		}
+		schema, ok := cm.Data[ref.Key]
+		if !ok {
+			return fmt.Errorf("rule %q: JSON Schema ConfigMap %s has no key %q", rule.Path, ref.Name, ref.Key)
+		}
+		rule.RequestValidation = rule.RequestValidation.DeepCopy()
+		rule.RequestValidation.JSONSchema = schema
+	}
+	return nil
+}
+
+// compileRequestValidation returns the checks of rule's requestValidation,
+// or nil when it has none. A jsonSchemaRef must have been resolved into
+// jsonSchema first.
+func compileRequestValidation(rule x402v1alpha1.RouteRule) (*routestore.CompiledRequestValidation, error) {
+	v := rule.RequestValidation
+	if v == nil {
+		return nil, nil
+	}
+	if rule.Free {
+		return nil, errors.New("free rules do not pass through the gateway and cannot validate requests")
+	}
+	compiled := &routestore.CompiledRequestValidation{MaxBodyBytes: v.MaxBodyBytes}
+	for _, name := range v.RequiredHeaders {
+		compiled.RequiredHeaders = append(compiled.RequiredHeaders, http.CanonicalHeaderKey(name))
+	}
+	for _, ct := range v.ContentTypes {
+		compiled.ContentTypes = append(compiled.ContentTypes, strings.ToLower(ct))
+	}
+	if v.JSONSchema != "" {
+		schema, err := jsonschema.Compile([]byte(v.JSONSchema))
+		if err != nil {
+			return nil, fmt.Errorf("requestValidation JSON Schema: %w", err)
+		}
+		compiled.Schema = schema
+	}
+	if compiled.MaxBodyBytes == 0 {
+		compiled.MaxBodyBytes = defaultValidationMaxBodyBytes
+	}
+	return compiled, nil
+}
+
+// hasRequestSchemaRefs reports whether a rule of route reads its JSON Schema
+// from a ConfigMap.
+func hasRequestSchemaRefs(route *x402v1alpha1.X402Route) bool {
+	for _, rule := range route.Spec.Routes {
+		if rule.RequestValidation != nil && rule.RequestValidation.JSONSchemaRef != nil {
+			return true
+		}
+	}
+	return false
+}
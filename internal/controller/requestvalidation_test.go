@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestCompileRequestValidation(t *testing.T) {
+	compiled, err := compileRequestValidation(x402v1alpha1.RouteRule{
+		RequestValidation: &x402v1alpha1.RequestValidationPolicy{
+			RequiredHeaders: []string{"x-tenant-id"},
+			ContentTypes:    []string{"application/json"},
+			JSONSchema:      `{"type":"object","required":["sku"]}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("compileRequestValidation() error = %v", err)
+	}
+	if !slices.Equal(compiled.RequiredHeaders, []string{"X-Tenant-Id"}) {
+		t.Errorf("RequiredHeaders = %v, want canonical names", compiled.RequiredHeaders)
+	}
+	if compiled.Schema == nil || compiled.MaxBodyBytes != defaultValidationMaxBodyBytes {
+		t.Errorf("compiled = %+v, want a schema and the default body bound", compiled)
+	}
+
+	if got, err := compileRequestValidation(x402v1alpha1.RouteRule{}); got != nil || err != nil {
+		t.Errorf("compileRequestValidation() without policy = %+v, %v, want nil", got, err)
+	}
+	for name, rule := range map[string]x402v1alpha1.RouteRule{
+		"free rule":      {Free: true, RequestValidation: &x402v1alpha1.RequestValidationPolicy{RequiredHeaders: []string{"X-Tenant-ID"}}},
+		"invalid schema": {RequestValidation: &x402v1alpha1.RequestValidationPolicy{JSONSchema: `{"type":"decimal"}`}},
+	} {
+		if _, err := compileRequestValidation(rule); err == nil {
+			t.Errorf("compileRequestValidation() with %s succeeded", name)
+		}
+	}
+}
+
+func TestResolveRequestSchemas(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "schemas"},
+		Data:       map[string]string{"order.json": `{"type":"object"}`},
+	}
+	r := &X402RouteReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build()}
+	ctx := context.Background()
+
+	route := newTestRoute()
+	route.Namespace = "default"
+	route.Spec.Routes[0].RequestValidation = &x402v1alpha1.RequestValidationPolicy{
+		JSONSchemaRef: &x402v1alpha1.ConfigMapKeyReference{Name: "schemas", Key: "order.json"},
+	}
+	shared := route.Spec.Routes[0].RequestValidation
+	if err := r.resolveRequestSchemas(ctx, route); err != nil {
+		t.Fatalf("resolveRequestSchemas() error = %v", err)
+	}
+	if got := route.Spec.Routes[0].RequestValidation.JSONSchema; got != `{"type":"object"}` {
+		t.Errorf("jsonSchema = %q, want the ConfigMap's", got)
+	}
+	if shared.JSONSchema != "" {
+		t.Error("resolveRequestSchemas() modified the policy in place")
+	}
+	if !hasRequestSchemaRefs(route) || r.resyncInterval(route) != requestSchemaResync {
+		t.Errorf("resyncInterval() = %v, want %v", r.resyncInterval(route), requestSchemaResync)
+	}
+
+	route.Spec.Routes[0].RequestValidation.JSONSchemaRef.Key = "missing.json"
+	if err := r.resolveRequestSchemas(ctx, route); err == nil || !strings.Contains(err.Error(), `no key "missing.json"`) {
+		t.Errorf("resolveRequestSchemas() error = %v, want a missing key", err)
+	}
+	route.Spec.Routes[0].RequestValidation.JSONSchemaRef.Name = "absent"
+	if err := r.resolveRequestSchemas(ctx, route); !apierrors.IsNotFound(err) {
+		t.Errorf("resolveRequestSchemas() error = %v, want NotFound", err)
+	}
+}
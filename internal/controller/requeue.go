@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Requeue delays of routes whose Ingress does not exist yet. The delay
+	// doubles on every attempt and is jittered so routes created together
+	// do not poll in step.
+	dependencyRequeueBase   = 5 * time.Second
+	dependencyRequeueMax    = 5 * time.Minute
+	dependencyRequeueJitter = 0.2
+
+	// Retry delays of reconciles that failed with a transient error, such as
+	// an API server timeout or an update conflict.
+	transientRetryBase = time.Second
+	transientRetryMax  = 5 * time.Minute
+)
+
+// transientRateLimiter spaces out the retries of reconciles that returned an
+// error. Terminal errors are not retried.
+func transientRateLimiter() workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](transientRetryBase, transientRetryMax)
+}
+
+// dependencyBackoff tracks the requeue delay of each route waiting for a
+// missing dependency. The zero value is ready to use.
+type dependencyBackoff struct {
+	mu       sync.Mutex
+	attempts map[types.NamespacedName]int
+}
+
+// next returns the delay before the route is reconciled again and counts the
+// attempt.
+func (b *dependencyBackoff) next(key types.NamespacedName) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.attempts == nil {
+		b.attempts = make(map[types.NamespacedName]int)
+	}
+	n := b.attempts[key]
+	b.attempts[key] = n + 1
+
+	d := dependencyRequeueMax
+	if n < 16 && dependencyRequeueBase<<n < dependencyRequeueMax {
+		d = dependencyRequeueBase << n
+	}
+	return wait.Jitter(d, dependencyRequeueJitter)
+}
+
+// reset forgets the attempts of a route once its dependencies exist.
+func (b *dependencyBackoff) reset(key types.NamespacedName) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.attempts, key)
+}
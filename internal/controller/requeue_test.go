@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestDependencyBackoff(t *testing.T) {
+	var b dependencyBackoff
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+	within := func(got, want time.Duration) bool {
+		return got >= want && got <= time.Duration(float64(want)*(1+dependencyRequeueJitter))
+	}
+
+	for i, want := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second} {
+		if got := b.next(key); !within(got, want) {
+			t.Errorf("attempt %d: next() = %v, want %v plus jitter", i, got, want)
+		}
+	}
+	for range 20 {
+		b.next(key)
+	}
+	if got := b.next(key); !within(got, dependencyRequeueMax) {
+		t.Errorf("next() = %v, want capped at %v", got, dependencyRequeueMax)
+	}
+
+	other := types.NamespacedName{Namespace: "default", Name: "other"}
+	if got := b.next(other); !within(got, dependencyRequeueBase) {
+		t.Errorf("next(other) = %v, want %v", got, dependencyRequeueBase)
+	}
+	b.reset(key)
+	if got := b.next(key); !within(got, dependencyRequeueBase) {
+		t.Errorf("next() after reset = %v, want %v", got, dependencyRequeueBase)
+	}
+}
+
+func TestReconcileErrorClasses(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+
+	tests := []struct {
+		name         string
+		facilitator  string
+		withIngress  bool
+		wantRequeue  bool
+		wantTerminal bool
+		wantError    string
+	}{
+		{
+			name:        "missing ingress is requeued",
+			wantRequeue: true,
+			wantError:   `ingresses.networking.k8s.io "my-api-ingress" not found`,
+		},
+		{
+			name:         "compile error is terminal",
+			facilitator:  "ftp://facilitator",
+			withIngress:  true,
+			wantTerminal: true,
+			wantError:    "terminal error: invalid facilitator URL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			route := newTestRoute()
+			route.Name, route.Namespace = key.Name, key.Namespace
+			route.Finalizers = []string{finalizerName}
+			route.Spec.IngressRef.Name = "my-api-ingress"
+			route.Spec.Payment.FacilitatorURL = tt.facilitator
+			builder := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(route).WithStatusSubresource(route)
+			if tt.withIngress {
+				builder = builder.WithObjects(newTestIngress())
+			}
+			r := &X402RouteReconciler{
+				Client:            builder.Build(),
+				RouteStore:        routestore.New(),
+				OperatorNamespace: "x402-system",
+				OperatorSvcName:   "x402-k8s-operator",
+			}
+
+			res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if got := errors.Is(err, reconcile.TerminalError(nil)); got != tt.wantTerminal {
+				t.Errorf("Reconcile() error = %v, terminal = %v, want %v", err, got, tt.wantTerminal)
+			}
+			if !tt.wantTerminal && err != nil {
+				t.Errorf("Reconcile() error = %v, want nil", err)
+			}
+			if got := res.RequeueAfter > 0; got != tt.wantRequeue {
+				t.Errorf("RequeueAfter = %v, want requeue %v", res.RequeueAfter, tt.wantRequeue)
+			}
+
+			var got x402v1alpha1.X402Route
+			if err := r.Get(ctx, key, &got); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !strings.HasPrefix(got.Status.LastError, tt.wantError) {
+				t.Errorf("lastError = %q, want prefix %q", got.Status.LastError, tt.wantError)
+			}
+		})
+	}
+}
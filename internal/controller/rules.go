@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+const (
+	ruleStateLive     = "Live"
+	ruleStateDisabled = "Disabled"
+)
+
+// enabledRules returns the route rules that are not disabled, in spec order.
+func enabledRules(route *x402v1alpha1.X402Route) []x402v1alpha1.RouteRule {
+	rules := make([]x402v1alpha1.RouteRule, 0, len(route.Spec.Routes))
+	for _, rule := range route.Spec.Routes {
+		if !rule.Disabled {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// hostRules returns the enabled rules that can serve requests an Ingress rule
+// for host routes, in the order the gateway matches them: rules for a virtual
+// host before the catch-all rules without one. An empty host routes every host.
+func hostRules(route *x402v1alpha1.X402Route, host string) []x402v1alpha1.RouteRule {
+	var own, catchAll []x402v1alpha1.RouteRule
+	for _, rule := range enabledRules(route) {
+		switch {
+		case rule.Host == "":
+			catchAll = append(catchAll, rule)
+		case host == "" || hostsOverlap(rule.Host, host):
+			own = append(own, rule)
+		}
+	}
+	return append(own, catchAll...)
+}
+
+// hostsOverlap reports whether two Ingress host patterns can match the same
+// request host. A wildcard "*.example.com" covers exactly one extra label.
+func hostsOverlap(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	covers := func(pattern, host string) bool {
+		if !strings.HasPrefix(pattern, "*.") {
+			return false
+		}
+		label, rest, ok := strings.Cut(host, ".")
+		return ok && label != "" && strings.EqualFold(rest, pattern[2:])
+	}
+	return covers(a, b) || covers(b, a)
+}
+
+// ingressServesHost reports whether a rule of the Ingress routes requests for
+// host. A rule without a host routes every host.
+func ingressServesHost(ingress *networkingv1.Ingress, host string) bool {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host == "" || hostsOverlap(rule.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleKey identifies a rule among the rules of a route: its host and path,
+// such as "api.example.com/v1/**", or the path alone for catch-all rules.
+func ruleKey(host, path string) string {
+	return host + path
+}
+
+// ruleStatuses reports the state of each rule once the route's current
+// generation is served. A rule keeps the generation recorded in previous while
+// its state is unchanged, so status shows when each rule last went live or was
+// paused.
+func ruleStatuses(route *x402v1alpha1.X402Route, previous []x402v1alpha1.RuleStatus) []x402v1alpha1.RuleStatus {
+	since := make(map[string]x402v1alpha1.RuleStatus, len(previous))
+	for _, rs := range previous {
+		since[ruleKey(rs.Host, rs.Path)] = rs
+	}
+
+	statuses := make([]x402v1alpha1.RuleStatus, 0, len(route.Spec.Routes))
+	for _, rule := range route.Spec.Routes {
+		rs := x402v1alpha1.RuleStatus{Path: rule.Path, Host: rule.Host, State: ruleStateLive, Generation: route.Generation}
+		if rule.Disabled {
+			rs.State = ruleStateDisabled
+		}
+		if prev, ok := since[ruleKey(rule.Host, rule.Path)]; ok && prev.State == rs.State {
+			rs.Generation = prev.Generation
+		}
+		statuses = append(statuses, rs)
+	}
+	return statuses
+}
@@ -0,0 +1,372 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestRuleStatuses(t *testing.T) {
+	route := newTestRoute()
+	route.Generation = 1
+	statuses := ruleStatuses(route, nil)
+
+	// Pause /health at generation 2; /api/* keeps the generation it went live at.
+	route.Generation = 2
+	route.Spec.Routes[1].Disabled = true
+	statuses = ruleStatuses(route, statuses)
+
+	want := []x402v1alpha1.RuleStatus{
+		{Path: "/api/*", State: ruleStateLive, Generation: 1},
+		{Path: "/health", State: ruleStateDisabled, Generation: 2},
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("ruleStatuses() = %+v, want %+v", statuses, want)
+	}
+}
+
+func TestHostRules(t *testing.T) {
+	route := newTestRoute()
+	route.Spec.Routes = append(route.Spec.Routes,
+		x402v1alpha1.RouteRule{Path: "/v1/*", Host: "api.example.com"},
+		x402v1alpha1.RouteRule{Path: "/v2/*", Host: "*.data.example.com"},
+	)
+	keys := func(rules []x402v1alpha1.RouteRule) []string {
+		var out []string
+		for _, rule := range rules {
+			out = append(out, ruleKey(rule.Host, rule.Path))
+		}
+		return out
+	}
+
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{host: "", want: []string{"api.example.com/v1/*", "*.data.example.com/v2/*", "/api/*", "/health"}},
+		{host: "api.example.com", want: []string{"api.example.com/v1/*", "/api/*", "/health"}},
+		{host: "*.example.com", want: []string{"api.example.com/v1/*", "/api/*", "/health"}},
+		{host: "eu.data.example.com", want: []string{"*.data.example.com/v2/*", "/api/*", "/health"}},
+		{host: "web.example.com", want: []string{"/api/*", "/health"}},
+	}
+	for _, tt := range tests {
+		if got := keys(hostRules(route, tt.host)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hostRules(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestDisabledRulesExcluded(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	route := newTestRoute()
+	route.Spec.Routes = append(route.Spec.Routes, x402v1alpha1.RouteRule{Path: "/admin/*", Disabled: true})
+
+	if got := r.collectPaidPaths(route, ""); !reflect.DeepEqual(got, []string{"/api/*"}) {
+		t.Errorf("collectPaidPaths() = %v, want [/api/*]", got)
+	}
+
+	compiled, err := r.compileRoute(route, nil, newTestIngress())
+	if err != nil {
+		t.Fatalf("compileRoute() error = %v", err)
+	}
+	for _, rule := range compiled.Rules {
+		if rule.Path == "/admin/*" {
+			t.Error("disabled rule /admin/* was compiled")
+		}
+	}
+}
+
+func TestApplyGatewayPatchDisabledRule(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	ingress := newTestIngress()
+	prefix := networkingv1.PathTypePrefix
+	ingress.Spec.Rules = ingress.Spec.Rules[1:]
+	ingress.Spec.Rules[0].HTTP.Paths = []networkingv1.HTTPIngressPath{
+		{Path: "/api", PathType: &prefix, Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+			Name: "my-api", Port: networkingv1.ServiceBackendPort{Number: 8080},
+		}}},
+		{Path: "/admin", PathType: &prefix, Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+			Name: "my-admin", Port: networkingv1.ServiceBackendPort{Number: 9090},
+		}}},
+	}
+	route := newTestRoute()
+	route.Spec.Routes = append(route.Spec.Routes, x402v1alpha1.RouteRule{Path: "/admin/*"})
+
+	backendOf := func(path string) string {
+		for _, p := range ingress.Spec.Rules[0].HTTP.Paths {
+			if p.Path == path {
+				return p.Backend.Service.Name
+			}
+		}
+		return ""
+	}
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	if got := backendOf("/admin"); got != externalSvcName {
+		t.Fatalf("/admin backend = %q, want %q", got, externalSvcName)
+	}
+
+	// Disabling the rule hands its path back to the original backend and
+	// leaves the other paid paths gated.
+	route.Spec.Routes[len(route.Spec.Routes)-1].Disabled = true
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		t.Fatalf("applyGatewayPatch() error = %v", err)
+	}
+	if got := backendOf("/admin"); got != "my-admin" {
+		t.Errorf("/admin backend = %q, want my-admin", got)
+	}
+	if got := backendOf("/api"); got != externalSvcName {
+		t.Errorf("/api backend = %q, want %q", got, externalSvcName)
+	}
+}
+
+func TestCompilePriceModifier(t *testing.T) {
+	tests := []struct {
+		name      string
+		mod       x402v1alpha1.PriceModifier
+		hasOffers bool
+		wantErr   bool
+	}{
+		{name: "multiplier", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "^4k$", Multiplier: "2.5"}},
+		{name: "price", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "^4k$", Price: "0.01"}},
+		{name: "multiplier with offers", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "^4k$", Multiplier: "2"}, hasOffers: true},
+		{name: "price with offers", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "^4k$", Price: "0.01"}, hasOffers: true, wantErr: true},
+		{name: "neither", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "^4k$"}, wantErr: true},
+		{name: "both", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "^4k$", Multiplier: "2", Price: "0.01"}, wantErr: true},
+		{name: "invalid multiplier", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "^4k$", Multiplier: "x2"}, wantErr: true},
+		{name: "invalid pattern", mod: x402v1alpha1.PriceModifier{Param: "resolution", Pattern: "(", Multiplier: "2"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compilePriceModifier(tt.mod, tt.hasOffers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("compilePriceModifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileExperiments(t *testing.T) {
+	variant := func(name string, percent int32) x402v1alpha1.PriceExperiment {
+		return x402v1alpha1.PriceExperiment{Name: name, Price: "0.002", Percent: percent}
+	}
+	tests := []struct {
+		name    string
+		rule    x402v1alpha1.RouteRule
+		wantErr bool
+	}{
+		{name: "none", rule: x402v1alpha1.RouteRule{Path: "/api"}},
+		{name: "variants", rule: x402v1alpha1.RouteRule{Path: "/api", Experiments: []x402v1alpha1.PriceExperiment{variant("a", 40), variant("b", 60)}}},
+		{
+			name: "multiplier modifier",
+			rule: x402v1alpha1.RouteRule{
+				Path:           "/api",
+				Experiments:    []x402v1alpha1.PriceExperiment{variant("a", 10)},
+				PriceModifiers: []x402v1alpha1.PriceModifier{{Param: "size", Pattern: "xl", Multiplier: "2"}},
+			},
+		},
+		{name: "over 100 percent", rule: x402v1alpha1.RouteRule{Path: "/api", Experiments: []x402v1alpha1.PriceExperiment{variant("a", 60), variant("b", 50)}}, wantErr: true},
+		{name: "no percent", rule: x402v1alpha1.RouteRule{Path: "/api", Experiments: []x402v1alpha1.PriceExperiment{variant("a", 0)}}, wantErr: true},
+		{name: "duplicate", rule: x402v1alpha1.RouteRule{Path: "/api", Experiments: []x402v1alpha1.PriceExperiment{variant("a", 10), variant("a", 10)}}, wantErr: true},
+		{name: "control", rule: x402v1alpha1.RouteRule{Path: "/api", Experiments: []x402v1alpha1.PriceExperiment{variant("control", 10)}}, wantErr: true},
+		{
+			name: "with offers",
+			rule: x402v1alpha1.RouteRule{
+				Path:        "/api",
+				Experiments: []x402v1alpha1.PriceExperiment{variant("a", 10)},
+				Offers:      []x402v1alpha1.PriceOffer{{Name: "standard", Price: "0.001"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "with fixed-price modifier",
+			rule: x402v1alpha1.RouteRule{
+				Path:           "/api",
+				Experiments:    []x402v1alpha1.PriceExperiment{variant("a", 10)},
+				PriceModifiers: []x402v1alpha1.PriceModifier{{Param: "size", Pattern: "xl", Price: "0.01"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compileExperiments(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileExperiments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(got) != len(tt.rule.Experiments) {
+				t.Errorf("compileExperiments() = %+v, want %d variants", got, len(tt.rule.Experiments))
+			}
+		})
+	}
+}
+
+func TestCompileMirror(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	route := newTestRoute()
+	route.Spec.Mirror = &x402v1alpha1.MirrorPolicy{
+		BackendRef: x402v1alpha1.FallbackBackend{ServiceName: "api-staging", ServicePort: 8080},
+		Percent:    10,
+	}
+	ingress := newTestIngress()
+
+	compiled, err := r.compileRoute(route, nil, ingress)
+	if err != nil {
+		t.Fatalf("compileRoute() error = %v", err)
+	}
+	want := "http://api-staging." + ingress.Namespace + ".svc.cluster.local:8080"
+	if compiled.Mirror == nil || compiled.Mirror.URL != want || compiled.Mirror.Percent != 10 {
+		t.Errorf("Mirror = %+v, want %s at 10%%", compiled.Mirror, want)
+	}
+}
+
+func TestCompileWaitingRoom(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	route := newTestRoute()
+	route.Spec.MaxConcurrent = 4
+	route.Spec.WaitingRoom = &x402v1alpha1.WaitingRoomPolicy{TokenTTLSeconds: 30}
+
+	compiled, err := r.compileRoute(route, nil, newTestIngress())
+	if err != nil {
+		t.Fatalf("compileRoute() error = %v", err)
+	}
+	want := routestore.CompiledWaitingRoom{MaxRetryAfter: time.Minute, TokenTTL: 30 * time.Second, PriorityWait: 10 * time.Second}
+	if compiled.WaitingRoom == nil || *compiled.WaitingRoom != want {
+		t.Errorf("WaitingRoom = %+v, want %+v", compiled.WaitingRoom, want)
+	}
+}
+
+func TestCompilePaidCaching(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *x402v1alpha1.ResponseCachingPolicy
+		want    *routestore.CompiledCaching
+		wantErr bool
+	}{
+		{name: "default", want: &routestore.CompiledCaching{CacheControl: "private, no-store", SurrogateControl: "no-store"}},
+		{name: "noStore", policy: &x402v1alpha1.ResponseCachingPolicy{Policy: "noStore"}, want: &routestore.CompiledCaching{CacheControl: "private, no-store", SurrogateControl: "no-store"}},
+		{name: "backend", policy: &x402v1alpha1.ResponseCachingPolicy{Policy: "backend"}},
+		{
+			name:   "custom",
+			policy: &x402v1alpha1.ResponseCachingPolicy{Policy: "custom", CacheControl: "private, max-age=60"},
+			want:   &routestore.CompiledCaching{CacheControl: "private, max-age=60"},
+		},
+		{name: "custom without cacheControl", policy: &x402v1alpha1.ResponseCachingPolicy{Policy: "custom"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compilePaidCaching(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compilePaidCaching() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compilePaidCaching() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileExemptions(t *testing.T) {
+	builtIn := &routestore.CompiledExemptions{
+		Methods: []string{"OPTIONS", "HEAD"},
+		Paths:   []string{"/robots.txt", "/favicon.ico", "/.well-known/*"},
+	}
+	tests := []struct {
+		name   string
+		policy *x402v1alpha1.ExemptionPolicy
+		want   *routestore.CompiledExemptions
+	}{
+		{name: "unset", want: builtIn},
+		{name: "default", policy: &x402v1alpha1.ExemptionPolicy{Policy: "default", Paths: []string{"/ignored"}}, want: builtIn},
+		{name: "none", policy: &x402v1alpha1.ExemptionPolicy{Policy: "none"}},
+		{
+			name:   "custom",
+			policy: &x402v1alpha1.ExemptionPolicy{Policy: "custom", Methods: []string{"OPTIONS"}, Paths: []string{"/health"}},
+			want:   &routestore.CompiledExemptions{Methods: []string{"OPTIONS"}, Paths: []string{"/health"}},
+		},
+		{name: "empty custom", policy: &x402v1alpha1.ExemptionPolicy{Policy: "custom"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compileExemptions(tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compileExemptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileSettle(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	metering := &x402v1alpha1.MeteringPolicy{UnitHeader: "X-Token-Count", UnitPrice: "0.0001"}
+	tests := []struct {
+		name       string
+		routeMode  string
+		rule       x402v1alpha1.RouteRule
+		wantSettle string
+		wantErr    bool
+	}{
+		{name: "default", wantSettle: "sync"},
+		{name: "route default", routeMode: "async", wantSettle: "async"},
+		{name: "rule overrides route", routeMode: "async", rule: x402v1alpha1.RouteRule{Settle: "afterResponse"}, wantSettle: "afterResponse"},
+		{name: "metered", routeMode: "async", rule: x402v1alpha1.RouteRule{Metering: metering}, wantSettle: "afterResponse"},
+		{name: "metered sync", rule: x402v1alpha1.RouteRule{Metering: metering, Settle: "sync"}, wantErr: true},
+		{name: "async job after response", rule: x402v1alpha1.RouteRule{Async: &x402v1alpha1.AsyncPolicy{}, Settle: "afterResponse"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestRoute()
+			route.Spec.Payment.Settle = tt.routeMode
+			rule := tt.rule
+			rule.Path, rule.Price = "/api/*", "0.01"
+			route.Spec.Routes = []x402v1alpha1.RouteRule{rule}
+
+			compiled, err := r.compileRoute(route, nil, newTestIngress())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileRoute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && compiled.Rules[0].Settle != tt.wantSettle {
+				t.Errorf("Settle = %q, want %q", compiled.Rules[0].Settle, tt.wantSettle)
+			}
+		})
+	}
+}
+
+func TestCompileNotModified(t *testing.T) {
+	metering := &x402v1alpha1.MeteringPolicy{UnitHeader: "X-Token-Count", UnitPrice: "0.0001"}
+	tests := []struct {
+		name      string
+		rule      x402v1alpha1.RouteRule
+		wantPrice string // empty when 304s are charged in full
+		wantErr   bool
+	}{
+		{name: "unset"},
+		{name: "full", rule: x402v1alpha1.RouteRule{ConditionalRequests: &x402v1alpha1.ConditionalRequestPolicy{NotModified: "full"}}},
+		{name: "free", rule: x402v1alpha1.RouteRule{ConditionalRequests: &x402v1alpha1.ConditionalRequestPolicy{NotModified: "free"}}, wantPrice: "0"},
+		{name: "reduced metered", rule: x402v1alpha1.RouteRule{Metering: metering, ConditionalRequests: &x402v1alpha1.ConditionalRequestPolicy{NotModified: "reduced", ReducedPrice: "0.001"}}, wantPrice: "1/1000"},
+		{name: "reduced fixed price", rule: x402v1alpha1.RouteRule{ConditionalRequests: &x402v1alpha1.ConditionalRequestPolicy{NotModified: "reduced", ReducedPrice: "0.001"}}, wantErr: true},
+		{name: "async job", rule: x402v1alpha1.RouteRule{Async: &x402v1alpha1.AsyncPolicy{}, ConditionalRequests: &x402v1alpha1.ConditionalRequestPolicy{NotModified: "free"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compileNotModified(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileNotModified() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.wantErr:
+			case tt.wantPrice == "" && got != nil:
+				t.Errorf("compileNotModified() = %+v, want nil", got)
+			case tt.wantPrice != "" && (got == nil || got.Price.RatString() != tt.wantPrice):
+				t.Errorf("compileNotModified() = %+v, want price %s", got, tt.wantPrice)
+			}
+		})
+	}
+}
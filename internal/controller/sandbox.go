@@ -0,0 +1,34 @@
+package controller
+
+import "fmt"
+
+// sandboxNetworks maps each supported network to the test network its
+// sandbox routes are served on.
+var sandboxNetworks = map[string]string{
+	"base":           "base-sepolia",
+	"base-sepolia":   "base-sepolia",
+	"eip155:8453":    "eip155:84532",
+	"eip155:84532":   "eip155:84532",
+	"avalanche":      "avalanche-fuji",
+	"avalanche-fuji": "avalanche-fuji",
+	"eip155:43114":   "eip155:43113",
+	"eip155:43113":   "eip155:43113",
+	"solana":         "solana-devnet",
+	"solana-devnet":  "solana-devnet",
+	"solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp": "solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1",
+	"solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1": "solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1",
+}
+
+// sandboxPrice is the price of paid sandbox rules without one: a single unit
+// of test USDC.
+const sandboxPrice = "0.000001"
+
+// sandboxNetwork returns the test network a sandbox route on network is
+// served on.
+func sandboxNetwork(network string) (string, error) {
+	test, ok := sandboxNetworks[network]
+	if !ok {
+		return "", fmt.Errorf("sandbox: network %q has no known test network", network)
+	}
+	return test, nil
+}
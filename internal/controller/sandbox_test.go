@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"testing"
+)
+
+func TestCompileSandbox(t *testing.T) {
+	r := &X402RouteReconciler{OperatorNamespace: "x402-system", OperatorSvcName: "x402-k8s-operator"}
+	tests := []struct {
+		network     string
+		wantNetwork string
+		wantErr     bool
+	}{
+		{network: "base", wantNetwork: "base-sepolia"},
+		{network: "base-sepolia", wantNetwork: "base-sepolia"},
+		{network: "eip155:43114", wantNetwork: "eip155:43113"},
+		{network: "solana", wantNetwork: "solana-devnet"},
+		{network: "polygon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			route := newTestRoute()
+			route.Spec.Sandbox = true
+			route.Spec.Payment.Network = tt.network
+			route.Spec.Payment.Asset = "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"
+
+			compiled, err := r.compileRoute(route, nil, newTestIngress())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileRoute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !compiled.Sandbox || compiled.Network != tt.wantNetwork || compiled.Asset != "" {
+				t.Errorf("Sandbox = %v, Network = %q, Asset = %q, want true, %q and the test USDC", compiled.Sandbox, compiled.Network, compiled.Asset, tt.wantNetwork)
+			}
+			if got := compiled.Rules[0].Price; got != sandboxPrice {
+				t.Errorf("price of a rule without one = %q, want %q", got, sandboxPrice)
+			}
+		})
+	}
+}
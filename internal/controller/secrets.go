@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// resolveWallet returns the route to compile: route itself, or a copy
+// carrying the wallet address read from payment.walletSecretRef. The copy is
+// never written back, so the address stays out of the stored spec. Errors in
+// the spec are terminal; a secret store that cannot be read is retried.
+func (r *X402RouteReconciler) resolveWallet(ctx context.Context, route *x402v1alpha1.X402Route) (*x402v1alpha1.X402Route, error) {
+	payment := &route.Spec.Payment
+	ref := payment.WalletSecretRef
+	switch {
+	case ref == nil:
+		return route, nil
+	case payment.Wallet != "":
+		return nil, reconcile.TerminalError(errors.New("payment.wallet and payment.walletSecretRef are mutually exclusive"))
+	case ref.Vault == nil:
+		return nil, reconcile.TerminalError(errors.New("payment.walletSecretRef needs a vault reference"))
+	case r.Secrets == nil:
+		return nil, reconcile.TerminalError(errors.New("payment.walletSecretRef needs Vault; set VAULT_ADDR on the operator"))
+	}
+	wallet, err := r.Secrets.Value(ctx, ref.Vault.Path, ref.Vault.Key)
+	if err != nil {
+		return nil, fmt.Errorf("read payment.walletSecretRef: %w", err)
+	}
+	resolved := route.DeepCopy()
+	resolved.Spec.Payment.Wallet = strings.TrimSpace(wallet)
+	return resolved, nil
+}
+
+// compileFacilitatorAuth compiles payment.facilitatorAuth. Only the
+// reference is compiled; the gateway reads the value when it calls the
+// facilitator.
+func compileFacilitatorAuth(auth *x402v1alpha1.FacilitatorAuth) (*routestore.CompiledFacilitatorAuth, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	if auth.ValueFrom.Vault == nil {
+		return nil, errors.New("payment.facilitatorAuth.valueFrom needs a vault reference")
+	}
+	compiled := &routestore.CompiledFacilitatorAuth{
+		Header:    auth.Header,
+		Prefix:    auth.Prefix,
+		VaultPath: auth.ValueFrom.Vault.Path,
+		VaultKey:  auth.ValueFrom.Vault.Key,
+	}
+	if compiled.Header == "" {
+		compiled.Header = "Authorization"
+	}
+	return compiled, nil
+}
+
+// resyncInterval returns how soon a reconciled route is reconciled again to
+// pick up changes it does not watch: fleet pricing, secret values, route
+// variables and request schemas. Zero waits for the next change.
+func (r *X402RouteReconciler) resyncInterval(route *x402v1alpha1.X402Route) time.Duration {
+	var d time.Duration
+	if r.Fleet != nil {
+		d = fleetResyncInterval
+	}
+	if route.Spec.Payment.WalletSecretRef != nil && r.Secrets != nil {
+		if refresh := r.Secrets.Refresh(); d == 0 || refresh < d {
+			d = refresh
+		}
+	}
+	if hasBackendCASecrets(route) && (d == 0 || backendTLSResync < d) {
+		d = backendTLSResync
+	}
+	if len(route.Spec.VariablesFrom) > 0 && (d == 0 || variablesResync < d) {
+		d = variablesResync
+	}
+	if hasRequestSchemaRefs(route) && (d == 0 || requestSchemaResync < d) {
+		d = requestSchemaResync
+	}
+	return d
+}
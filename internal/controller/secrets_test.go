@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+)
+
+func TestResolveWallet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/x402" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"wallet":"0xFromVault\n"},"metadata":{"version":2}}}`))
+	}))
+	defer srv.Close()
+	client, _ := vault.NewClient(srv.URL)
+	client.Token = "root"
+	secrets := vault.NewSecrets(client, time.Minute)
+	ref := func(path string) *x402v1alpha1.SecretValueRef {
+		return &x402v1alpha1.SecretValueRef{Vault: &x402v1alpha1.VaultSecretRef{Path: path, Key: "wallet"}}
+	}
+
+	tests := []struct {
+		name         string
+		wallet       string
+		ref          *x402v1alpha1.SecretValueRef
+		secrets      *vault.Secrets
+		want         string
+		wantErr      bool
+		wantTerminal bool
+	}{
+		{name: "inline wallet", wallet: "0xInline", secrets: secrets, want: "0xInline"},
+		{name: "vault wallet", ref: ref("secret/data/x402"), secrets: secrets, want: "0xFromVault"},
+		{name: "both set", wallet: "0xInline", ref: ref("secret/data/x402"), secrets: secrets, wantErr: true, wantTerminal: true},
+		{name: "vault not configured", ref: ref("secret/data/x402"), wantErr: true, wantTerminal: true},
+		{name: "vault unavailable", ref: ref("secret/data/other"), secrets: secrets, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &X402RouteReconciler{Secrets: tt.secrets}
+			route := newTestRoute()
+			route.Spec.Payment.Wallet, route.Spec.Payment.WalletSecretRef = tt.wallet, tt.ref
+
+			got, err := r.resolveWallet(context.Background(), route)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveWallet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if terminal := errors.Is(err, reconcile.TerminalError(nil)); terminal != tt.wantTerminal {
+					t.Errorf("terminal = %v, want %v", terminal, tt.wantTerminal)
+				}
+				return
+			}
+			if got.Spec.Payment.Wallet != tt.want {
+				t.Errorf("wallet = %q, want %q", got.Spec.Payment.Wallet, tt.want)
+			}
+			if route.Spec.Payment.Wallet != tt.wallet {
+				t.Errorf("resolveWallet() changed the route's wallet to %q", route.Spec.Payment.Wallet)
+			}
+		})
+	}
+}
+
+func TestCompileFacilitatorAuth(t *testing.T) {
+	vaultRef := x402v1alpha1.SecretValueRef{Vault: &x402v1alpha1.VaultSecretRef{Path: "secret/data/x402", Key: "apiKey"}}
+	tests := []struct {
+		name    string
+		auth    *x402v1alpha1.FacilitatorAuth
+		want    *routestore.CompiledFacilitatorAuth
+		wantErr bool
+	}{
+		{name: "none"},
+		{
+			name: "default header",
+			auth: &x402v1alpha1.FacilitatorAuth{Prefix: "Bearer ", ValueFrom: vaultRef},
+			want: &routestore.CompiledFacilitatorAuth{Header: "Authorization", Prefix: "Bearer ", VaultPath: "secret/data/x402", VaultKey: "apiKey"},
+		},
+		{
+			name: "custom header",
+			auth: &x402v1alpha1.FacilitatorAuth{Header: "X-Api-Key", ValueFrom: vaultRef},
+			want: &routestore.CompiledFacilitatorAuth{Header: "X-Api-Key", VaultPath: "secret/data/x402", VaultKey: "apiKey"},
+		},
+		{name: "no source", auth: &x402v1alpha1.FacilitatorAuth{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compileFacilitatorAuth(tt.auth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileFacilitatorAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compileFacilitatorAuth() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
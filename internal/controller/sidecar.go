@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// errSidecarNotAllowed rejects sidecar backends unless the operator opts in:
+// they reach ports and sockets in the gateway's own pod.
+var errSidecarNotAllowed = errors.New("sidecar backends are disabled; start the operator with --allow-sidecar-backends")
+
+// sidecarBackend compiles a sidecar backend into a catch-all backend that
+// replaces the Ingress backends.
+func (r *X402RouteReconciler) sidecarBackend(sidecar *x402v1alpha1.SidecarBackend) (routestore.CompiledBackend, error) {
+	if !r.AllowSidecarBackends {
+		return routestore.CompiledBackend{}, errSidecarNotAllowed
+	}
+	b := routestore.CompiledBackend{Path: "/", PathType: "Prefix"}
+	switch {
+	case (sidecar.Port == 0) == (sidecar.SocketPath == ""):
+		return b, errors.New("sidecar: set exactly one of port and socketPath")
+	case sidecar.SocketPath != "":
+		if !filepath.IsAbs(sidecar.SocketPath) {
+			return b, fmt.Errorf("sidecar: socketPath %q must be absolute", sidecar.SocketPath)
+		}
+		b.URL, b.Socket = "http://localhost", filepath.Clean(sidecar.SocketPath)
+	default:
+		b.URL = fmt.Sprintf("http://127.0.0.1:%d", sidecar.Port)
+	}
+	return b, nil
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"testing"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestSidecarBackend(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    bool
+		sidecar    x402v1alpha1.SidecarBackend
+		wantURL    string
+		wantSocket string
+		wantErr    bool
+	}{
+		{name: "port", allowed: true, sidecar: x402v1alpha1.SidecarBackend{Port: 3000}, wantURL: "http://127.0.0.1:3000"},
+		{name: "socket", allowed: true, sidecar: x402v1alpha1.SidecarBackend{SocketPath: "/var/run/app/../app/app.sock"}, wantURL: "http://localhost", wantSocket: "/var/run/app/app.sock"},
+		{name: "not allowed", sidecar: x402v1alpha1.SidecarBackend{Port: 3000}, wantErr: true},
+		{name: "both", allowed: true, sidecar: x402v1alpha1.SidecarBackend{Port: 3000, SocketPath: "/app.sock"}, wantErr: true},
+		{name: "neither", allowed: true, wantErr: true},
+		{name: "relative socket", allowed: true, sidecar: x402v1alpha1.SidecarBackend{SocketPath: "app.sock"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &X402RouteReconciler{AllowSidecarBackends: tt.allowed}
+			got, err := r.sidecarBackend(&tt.sidecar)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sidecarBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.URL != tt.wantURL || got.Socket != tt.wantSocket || got.Path != "/" || got.PathType != "Prefix" {
+				t.Errorf("sidecarBackend() = %+v, want URL %q socket %q on /", got, tt.wantURL, tt.wantSocket)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestPatchStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	x402v1alpha1.AddToScheme(scheme)
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+
+	tests := []struct {
+		name      string
+		stored    string
+		err       error
+		wantError string
+		wantReady bool
+	}{
+		{name: "success clears the last error", stored: "ingress not found", wantReady: true},
+		{name: "failure records the error", err: errors.New("compile: bad price"), wantError: "compile: bad price"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			route := newTestRoute()
+			route.Name, route.Namespace = key.Name, key.Namespace
+			route.Generation = 2
+			route.Status.LastError = tt.stored
+			route.Status.ActiveRoutes = 1
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(route).WithStatusSubresource(route).Build()
+			r := &X402RouteReconciler{Client: c}
+
+			if err := c.Get(ctx, key, route); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			base := route.DeepCopy()
+			route.Status.ObservedGeneration = route.Generation
+			r.setStatus(route, tt.wantReady, tt.wantReady, 2)
+			r.patchStatus(ctx, route, base, tt.err)
+
+			var got x402v1alpha1.X402Route
+			if err := c.Get(ctx, key, &got); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Status.LastError != tt.wantError {
+				t.Errorf("lastError = %q, want %q", got.Status.LastError, tt.wantError)
+			}
+			if got.Status.LastReconcileTime == nil {
+				t.Error("lastReconcileTime not set")
+			}
+			if got.Status.Ready != tt.wantReady || got.Status.ActiveRoutes != 2 {
+				t.Errorf("ready = %v, activeRoutes = %d, want %v, 2", got.Status.Ready, got.Status.ActiveRoutes, tt.wantReady)
+			}
+			if got.Status.ObservedGeneration != 2 {
+				t.Errorf("observedGeneration = %d, want 2", got.Status.ObservedGeneration)
+			}
+		})
+	}
+}
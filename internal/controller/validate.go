@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+// RouteValidation is the dry-run result for one X402Route.
+type RouteValidation struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Ingress   string `json:"ingress"`
+	Rules     int    `json:"rules"`
+	// Patch is the diff the controller would apply to the Ingress, empty when
+	// the Ingress is already up to date.
+	Patch string `json:"patch,omitempty"`
+	// BehaviorChanged is set when this build compiles the unchanged spec
+	// differently than the operator that last reconciled the route.
+	BehaviorChanged bool   `json:"behaviorChanged,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ValidateRoutes compiles every X402Route in the cluster and renders the
+// Ingress patch each would apply, without writing anything. Routes that are
+// being deleted are skipped.
+func (r *X402RouteReconciler) ValidateRoutes(ctx context.Context) ([]RouteValidation, error) {
+	var routes x402v1alpha1.X402RouteList
+	if err := r.List(ctx, &routes); err != nil {
+		return nil, fmt.Errorf("list X402Routes: %w", err)
+	}
+
+	results := make([]RouteValidation, 0, len(routes.Items))
+	for i := range routes.Items {
+		route := &routes.Items[i]
+		if !route.DeletionTimestamp.IsZero() {
+			continue
+		}
+		results = append(results, r.validateRoute(ctx, route))
+	}
+	return results, nil
+}
+
+func (r *X402RouteReconciler) validateRoute(ctx context.Context, route *x402v1alpha1.X402Route) RouteValidation {
+	ingressKey := types.NamespacedName{Name: route.Spec.IngressRef.Name, Namespace: route.Spec.IngressRef.Namespace}
+	if ingressKey.Namespace == "" {
+		ingressKey.Namespace = route.Namespace
+	}
+	result := RouteValidation{Namespace: route.Namespace, Name: route.Name, Ingress: ingressKey.String()}
+
+	ingress := &networkingv1.Ingress{}
+	if err := r.Get(ctx, ingressKey, ingress); err != nil {
+		result.Error = fmt.Sprintf("fetch Ingress: %v", err)
+		return result
+	}
+	if !referenceGranted(route, ingress) {
+		result.Error = fmt.Sprintf("Ingress does not allow X402Routes from namespace %s", route.Namespace)
+		return result
+	}
+
+	source, backends, err := r.resolveSources(ctx, route, ingress)
+	if err != nil {
+		result.Error = fmt.Sprintf("%s: %v", sourceReason(err), err)
+		return result
+	}
+	compiled, err := r.compileRoute(source, backends, ingress)
+	if err != nil {
+		result.Error = fmt.Sprintf("compile: %v", err)
+		return result
+	}
+	result.Rules = len(compiled.Rules)
+	result.BehaviorChanged = behaviorChanged(route, specHash(route), compiledHash(compiled))
+
+	if result.Patch, err = r.previewPatch(route, ingress); err != nil {
+		result.Error = fmt.Sprintf("patch: %v", err)
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestValidateRoutes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+
+	valid := newTestRoute()
+	valid.Name, valid.Namespace = "valid", "default"
+	valid.Spec.IngressRef.Name = "my-api-ingress"
+
+	broken := newTestRoute()
+	broken.Name, broken.Namespace = "broken", "default"
+	broken.Spec.IngressRef.Name = "my-api-ingress"
+	broken.Spec.Payment.FacilitatorURL = "http://localhost:8080"
+
+	missing := newTestRoute()
+	missing.Name, missing.Namespace = "missing", "default"
+	missing.Spec.IngressRef.Name = "no-such-ingress"
+
+	ingress := newTestIngress()
+	r := &X402RouteReconciler{
+		Client:            fake.NewClientBuilder().WithScheme(scheme).WithObjects(valid, broken, missing, ingress).Build(),
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+
+	results, err := r.ValidateRoutes(context.Background())
+	if err != nil {
+		t.Fatalf("ValidateRoutes() error = %v", err)
+	}
+	byName := make(map[string]RouteValidation)
+	for _, res := range results {
+		byName[res.Name] = res
+	}
+	if len(byName) != 3 {
+		t.Fatalf("got %d results, want 3", len(byName))
+	}
+
+	if res := byName["valid"]; res.Error != "" || res.Rules != 2 || !strings.Contains(res.Patch, externalSvcName) {
+		t.Errorf("valid route = %+v, want 2 rules and a patch to the gateway", res)
+	}
+	if res := byName["broken"]; !strings.HasPrefix(res.Error, "compile:") {
+		t.Errorf("broken route error = %q, want a compile error", res.Error)
+	}
+	if res := byName["missing"]; !strings.HasPrefix(res.Error, "fetch Ingress:") {
+		t.Errorf("missing ingress error = %q, want a fetch error", res.Error)
+	}
+
+	// Nothing is written: the Ingress keeps its original backends.
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(ingress), ingress); err != nil {
+		t.Fatal(err)
+	}
+	if isManaged(ingress) {
+		t.Error("ValidateRoutes() patched the Ingress")
+	}
+	if r.RouteStore.Count() != 0 {
+		t.Error("ValidateRoutes() populated the route store")
+	}
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"net"
+	"net/netip"
 	"net/url"
 	"strings"
 )
@@ -10,13 +11,15 @@ import (
 // privateRanges defines CIDR blocks for private/reserved IP addresses.
 var privateRanges = func() []*net.IPNet {
 	cidrs := []string{
+		"0.0.0.0/8",
 		"127.0.0.0/8",
 		"10.0.0.0/8",
 		"172.16.0.0/12",
 		"192.168.0.0/16",
 		"169.254.0.0/16",
+		"::/128",
 		"::1/128",
-		"fd00::/8",
+		"fc00::/7",
 		"fe80::/10",
 	}
 	var nets []*net.IPNet
@@ -54,7 +57,19 @@ func validateFacilitatorURL(rawURL string) error {
 		return fmt.Errorf("hostname %q is not allowed (*.internal)", hostname)
 	}
 
-	// Check if hostname is a literal IP address.
+	// Check if hostname is a literal IP address. IPv6 literals may carry a
+	// zone ("fe80::1%eth0"), which net.ParseIP rejects, and IPv4-mapped IPv6
+	// addresses are checked as the IPv4 address they map.
+	if strings.Contains(hostname, ":") {
+		addr, err := netip.ParseAddr(hostname)
+		if err != nil {
+			return fmt.Errorf("invalid IPv6 address %q", hostname)
+		}
+		if addr.Zone() != "" {
+			return fmt.Errorf("IPv6 address %s with a zone is not allowed", hostname)
+		}
+		hostname = addr.Unmap().String()
+	}
 	ip := net.ParseIP(hostname)
 	if ip != nil {
 		if isPrivateIP(ip) {
@@ -30,6 +30,13 @@ func TestValidateFacilitatorURL(t *testing.T) {
 		{name: "IPv6 loopback", url: "https://[::1]:8080", wantErr: true},
 		{name: "IPv6 fd00 ULA", url: "https://[fd00::1]", wantErr: true},
 		{name: "IPv6 fe80 link-local", url: "https://[fe80::1]", wantErr: true},
+		{name: "IPv6 zoned link-local", url: "http://[fe80::1%25eth0]:8080", wantErr: true},
+		{name: "IPv6 fc00 ULA", url: "https://[fc00::1]", wantErr: true},
+		{name: "IPv6 unspecified", url: "https://[::]:8080", wantErr: true},
+		{name: "IPv4-mapped loopback", url: "https://[::ffff:127.0.0.1]", wantErr: true},
+		{name: "IPv4 unspecified", url: "https://0.0.0.0", wantErr: true},
+		{name: "https public IPv6", url: "https://[2606:4700::1111]/verify", wantErr: false},
+		{name: "http public IPv6", url: "http://[2606:4700::1111]/verify", wantErr: true},
 
 		// Blocked: dangerous hostnames
 		{name: "localhost", url: "http://localhost:8080", wantErr: true},
@@ -76,6 +83,9 @@ func TestIsPrivateIP(t *testing.T) {
 		{"::1", true},
 		{"fd00::1", true},
 		{"fe80::1", true},
+		{"fc00::1", true},
+		{"::", true},
+		{"::ffff:10.0.0.1", true},
 		{"8.8.8.8", false},
 		{"1.1.1.1", false},
 		{"2001:db8::1", false},
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+// variablesResync is how often routes with spec.variablesFrom are reconciled
+// to pick up changed ConfigMaps and Secrets.
+const variablesResync = time.Minute
+
+var (
+	// variablePlaceholder matches ${KEY}, and $${KEY}, which escapes it.
+	variablePlaceholder = regexp.MustCompile(`\$?\$\{([-._a-zA-Z0-9]+)\}`)
+
+	// walletAddress and priceValue are the schema patterns of the fields
+	// that accept a placeholder, checked again once it is replaced.
+	walletAddress = regexp.MustCompile(`^(0x[0-9a-fA-F]{40}|[1-9A-HJ-NP-Za-km-z]{32,44})$`)
+	priceValue    = regexp.MustCompile(`^([$€£]?\s*[0-9]+(\.[0-9]+)?\s+[A-Z]{3}|[0-9]+(\.[0-9]+)?)$`)
+)
+
+// resolveVariables replaces the ${KEY} placeholders in route.Spec with the
+// keys of the ConfigMaps and Secrets in spec.variablesFrom, in memory like
+// applyPricePlan. The sources are read uncached, as the operator does not
+// watch them.
+func (r *X402RouteReconciler) resolveVariables(ctx context.Context, route *x402v1alpha1.X402Route) error {
+	if len(route.Spec.VariablesFrom) == 0 {
+		if path := placeholderField(&route.Spec); path != "" {
+			return fmt.Errorf("%s has a placeholder but spec.variablesFrom is empty", path)
+		}
+		return nil
+	}
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	vars := map[string]string{}
+	for _, src := range route.Spec.VariablesFrom {
+		key := types.NamespacedName{Namespace: route.Namespace}
+		switch {
+		case src.ConfigMapRef != nil:
+			key.Name = src.ConfigMapRef.Name
+			var cm corev1.ConfigMap
+			if err := reader.Get(ctx, key, &cm); err != nil {
+				return fmt.Errorf("read variables ConfigMap %s: %w", key.Name, err)
+			}
+			for k, v := range cm.Data {
+				vars[k] = strings.TrimSpace(v)
+			}
+		case src.SecretRef != nil:
+			key.Name = src.SecretRef.Name
+			var secret corev1.Secret
+			if err := reader.Get(ctx, key, &secret); err != nil {
+				return fmt.Errorf("read variables Secret %s: %w", key.Name, err)
+			}
+			for k, v := range secret.Data {
+				vars[k] = strings.TrimSpace(string(v))
+			}
+		}
+	}
+	return substituteVariables(&route.Spec, vars)
+}
+
+// substituteVariables replaces the placeholders in every string of spec but
+// its references.
+func substituteVariables(spec *x402v1alpha1.X402RouteSpec, vars map[string]string) error {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	raw = variablePlaceholder.ReplaceAllFunc(raw, func(m []byte) []byte {
+		if m[1] == '$' {
+			return m[1:]
+		}
+		name := string(m[2 : len(m)-1])
+		value, ok := vars[name]
+		if !ok {
+			if !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return m
+		}
+		// A JSON string without its quotes keeps the document valid.
+		quoted, _ := json.Marshal(value)
+		return quoted[1 : len(quoted)-1]
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("undefined variables: %s", strings.Join(missing, ", "))
+	}
+	var resolved x402v1alpha1.X402RouteSpec
+	if err := json.Unmarshal(raw, &resolved); err != nil {
+		return err
+	}
+	resolved.IngressRef = spec.IngressRef
+	resolved.PricePlanRef = spec.PricePlanRef
+	resolved.VariablesFrom = spec.VariablesFrom
+	if err := checkSubstituted(spec, &resolved); err != nil {
+		return err
+	}
+	*spec = resolved
+	return nil
+}
+
+// checkSubstituted checks the replaced values of the fields whose schema
+// pattern admits a placeholder against the pattern it stands for. The
+// facilitator URL is checked when it is compiled.
+func checkSubstituted(spec, resolved *x402v1alpha1.X402RouteSpec) error {
+	payment := &resolved.Payment
+	if payment.Wallet != spec.Payment.Wallet && !walletAddress.MatchString(payment.Wallet) {
+		return fmt.Errorf("payment.wallet %q is not a wallet address", payment.Wallet)
+	}
+	if payment.DefaultPrice != spec.Payment.DefaultPrice && !priceValue.MatchString(payment.DefaultPrice) {
+		return fmt.Errorf("payment.defaultPrice %q is not a price", payment.DefaultPrice)
+	}
+	for i, rule := range resolved.Routes {
+		if rule.Price != spec.Routes[i].Price && !priceValue.MatchString(rule.Price) {
+			return fmt.Errorf("rule %q: price %q is not a price", rule.Path, rule.Price)
+		}
+	}
+	return nil
+}
+
+// placeholderField returns the path of the first field whose schema pattern
+// admits a placeholder that holds one, or "".
+func placeholderField(spec *x402v1alpha1.X402RouteSpec) string {
+	switch {
+	case variablePlaceholder.MatchString(spec.Payment.Wallet):
+		return "payment.wallet"
+	case variablePlaceholder.MatchString(spec.Payment.DefaultPrice):
+		return "payment.defaultPrice"
+	case variablePlaceholder.MatchString(spec.Payment.FacilitatorURL):
+		return "payment.facilitatorURL"
+	}
+	for i, rule := range spec.Routes {
+		if variablePlaceholder.MatchString(rule.Price) {
+			return fmt.Sprintf("routes[%d].price", i)
+		}
+	}
+	return ""
+}
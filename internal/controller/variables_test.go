@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestSubstituteVariables(t *testing.T) {
+	const wallet = "0x1111111111111111111111111111111111111111"
+	vars := map[string]string{"WALLET": wallet, "price.api": "0.01", "NOTE": `say "hi"`}
+
+	spec := newTestRoute().Spec
+	spec.Payment.Wallet = "${WALLET}"
+	spec.Routes[0].Price = "${price.api}"
+	spec.Routes[0].Conditions = []x402v1alpha1.PaymentCondition{{Header: "X-Note", Pattern: "${NOTE} $${NOTE}"}}
+	if err := substituteVariables(&spec, vars); err != nil {
+		t.Fatalf("substituteVariables() error = %v", err)
+	}
+	if spec.Payment.Wallet != wallet || spec.Routes[0].Price != "0.01" {
+		t.Errorf("wallet, price = %q, %q, want %q, 0.01", spec.Payment.Wallet, spec.Routes[0].Price, wallet)
+	}
+	if got, want := spec.Routes[0].Conditions[0].Pattern, `say "hi" ${NOTE}`; got != want {
+		t.Errorf("pattern = %q, want %q", got, want)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		mutate  func(*x402v1alpha1.X402RouteSpec)
+		wantErr string
+	}{
+		{
+			name:    "undefined variable",
+			mutate:  func(s *x402v1alpha1.X402RouteSpec) { s.Payment.Wallet = "${MISSING}" },
+			wantErr: "undefined variables: MISSING",
+		},
+		{
+			name:    "value is not a price",
+			mutate:  func(s *x402v1alpha1.X402RouteSpec) { s.Payment.DefaultPrice = "${NOTE}" },
+			wantErr: "payment.defaultPrice",
+		},
+		{
+			name:    "value is not a wallet",
+			mutate:  func(s *x402v1alpha1.X402RouteSpec) { s.Payment.Wallet = "${price.api}" },
+			wantErr: "payment.wallet",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newTestRoute().Spec
+			tt.mutate(&spec)
+			err := substituteVariables(&spec, vars)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("substituteVariables() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileVariables(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	x402v1alpha1.AddToScheme(scheme)
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "my-api"}
+	route := newTestRoute()
+	route.Name, route.Namespace = key.Name, key.Namespace
+	route.Finalizers = []string{finalizerName}
+	route.Spec.IngressRef.Name = "my-api-ingress"
+	route.Spec.Payment.Wallet = "${WALLET}"
+	route.Spec.VariablesFrom = []x402v1alpha1.VariableSource{
+		{ConfigMapRef: &x402v1alpha1.LocalObjectReference{Name: "stage"}},
+		{SecretRef: &x402v1alpha1.LocalObjectReference{Name: "stage-wallet"}},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stage"},
+		Data:       map[string]string{"WALLET": "0x1111111111111111111111111111111111111111"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(route, cm, newTestIngress()).WithStatusSubresource(route).Build()
+	r := &X402RouteReconciler{
+		Client:            c,
+		RouteStore:        routestore.New(),
+		OperatorNamespace: "x402-system",
+		OperatorSvcName:   "x402-k8s-operator",
+	}
+
+	// Without the Secret the route waits for it.
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != variablesResync {
+		t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, variablesResync)
+	}
+	var current x402v1alpha1.X402Route
+	if err := c.Get(ctx, key, &current); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cond := meta.FindStatusCondition(current.Status.Conditions, "Ready"); cond == nil || cond.Reason != "VariablesUnavailable" {
+		t.Errorf("Ready condition = %+v, want reason VariablesUnavailable", cond)
+	}
+
+	// The later Secret wins over the ConfigMap.
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stage-wallet"},
+		Data:       map[string][]byte{"WALLET": []byte("0x2222222222222222222222222222222222222222\n")},
+	}
+	if err := c.Create(ctx, secret); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if result, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	compiled := r.RouteStore.Get(key.Namespace, key.Name)
+	if compiled == nil || compiled.Wallet != "0x2222222222222222222222222222222222222222" {
+		t.Fatalf("compiled route = %+v, want the Secret's wallet", compiled)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Minute {
+		t.Errorf("RequeueAfter = %v, want the variables resync", result.RequeueAfter)
+	}
+
+	// A changed source is picked up; the stored route keeps the placeholder.
+	secret.Data["WALLET"] = []byte("0x3333333333333333333333333333333333333333")
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if compiled = r.RouteStore.Get(key.Namespace, key.Name); compiled.Wallet != "0x3333333333333333333333333333333333333333" {
+		t.Errorf("wallet = %q after the Secret change, want 0x3333…", compiled.Wallet)
+	}
+	if err := c.Get(ctx, key, &current); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if current.Spec.Payment.Wallet != "${WALLET}" {
+		t.Errorf("stored wallet = %q, want the placeholder", current.Spec.Payment.Wallet)
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestIngressChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*networkingv1.Ingress)
+		want   bool
+	}{
+		{
+			name: "load-balancer status update",
+			mutate: func(ing *networkingv1.Ingress) {
+				ing.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}
+				ing.ResourceVersion = "2"
+			},
+		},
+		{
+			name:   "foreign annotation",
+			mutate: func(ing *networkingv1.Ingress) { ing.Annotations["nginx.ingress.kubernetes.io/rewrite-target"] = "/" },
+		},
+		{
+			name:   "spec change",
+			mutate: func(ing *networkingv1.Ingress) { ing.Generation = 2 },
+			want:   true,
+		},
+		{
+			name:   "grant annotation added",
+			mutate: func(ing *networkingv1.Ingress) { ing.Annotations[annotationAllowedRouteNamespaces] = "shop" },
+			want:   true,
+		},
+		{
+			name:   "managed annotation removed",
+			mutate: func(ing *networkingv1.Ingress) { delete(ing.Annotations, annotationManagedBy) },
+			want:   true,
+		},
+	}
+	p := ingressChanged()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := newTestIngress()
+			old.Generation = 1
+			old.Annotations = map[string]string{annotationManagedBy: "x402-operator"}
+			updated := old.DeepCopy()
+			tt.mutate(updated)
+
+			if got := p.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: updated}); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !p.Create(event.CreateEvent{Object: newTestIngress()}) {
+		t.Error("Create() = false, want true")
+	}
+	if !p.Delete(event.DeleteEvent{Object: newTestIngress()}) {
+		t.Error("Delete() = false, want true")
+	}
+}
@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+// WebhookPath is where the X402Route validating webhook is served.
+const WebhookPath = "/validate-x402-io-v1alpha1-x402route"
+
+// X402RouteValidator is the validating admission webhook for X402Routes. It
+// rejects specs the controller would fail to compile, including the
+// cross-field constraints the CRD schema cannot express, so they fail when
+// applied instead of in the route's status.
+type X402RouteValidator struct {
+	// Client reads the referenced Ingress to check that a route in another
+	// namespace is granted. When nil, cross-namespace references are not
+	// checked.
+	Client client.Reader
+}
+
+// SetupWebhookWithManager registers the validator on the manager's webhook
+// server at WebhookPath.
+func (v *X402RouteValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &x402v1alpha1.X402Route{}).WithValidator(v).Complete()
+}
+
+// ValidateCreate implements admission.Validator.
+func (v *X402RouteValidator) ValidateCreate(ctx context.Context, route *x402v1alpha1.X402Route) (admission.Warnings, error) {
+	return v.validate(ctx, route)
+}
+
+// ValidateUpdate implements admission.Validator. Updates that leave the spec
+// unchanged, such as finalizer removal, are always allowed, so routes created
+// before the webhook can still be deleted.
+func (v *X402RouteValidator) ValidateUpdate(ctx context.Context, old, route *x402v1alpha1.X402Route) (admission.Warnings, error) {
+	if !route.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(old.Spec, route.Spec) {
+		return nil, nil
+	}
+	return v.validate(ctx, route)
+}
+
+// ValidateDelete implements admission.Validator.
+func (v *X402RouteValidator) ValidateDelete(context.Context, *x402v1alpha1.X402Route) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *X402RouteValidator) validate(ctx context.Context, route *x402v1alpha1.X402Route) (admission.Warnings, error) {
+	errs := validateSpec(&route.Spec)
+	grantErr, err := v.validateGrant(ctx, route)
+	if err != nil {
+		return nil, err
+	}
+	if grantErr != nil {
+		errs = append(errs, grantErr)
+	}
+	return nil, invalidRoute(route, errs)
+}
+
+// validateGrant rejects a route referencing an Ingress in another namespace
+// that does not grant the route's namespace. An Ingress that does not exist
+// yet is allowed, since the controller waits for it and checks the grant
+// before patching it.
+func (v *X402RouteValidator) validateGrant(ctx context.Context, route *x402v1alpha1.X402Route) (*field.Error, error) {
+	ns := route.Spec.IngressRef.Namespace
+	if v.Client == nil || ns == "" || ns == route.Namespace {
+		return nil, nil
+	}
+	ingress := &networkingv1.Ingress{}
+	key := types.NamespacedName{Name: route.Spec.IngressRef.Name, Namespace: ns}
+	if err := v.Client.Get(ctx, key, ingress); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if referenceGranted(route, ingress) {
+		return nil, nil
+	}
+	return field.Forbidden(field.NewPath("spec", "ingressRef", "namespace"),
+		"Ingress "+key.String()+" does not list namespace "+route.Namespace+" in its "+annotationAllowedRouteNamespaces+" annotation"), nil
+}
+
+func invalidRoute(route *x402v1alpha1.X402Route, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(x402v1alpha1.GroupVersion.WithKind("X402Route").GroupKind(), route.Name, errs)
+}
+
+// validateSpec returns the errors compileRoute would report for spec, that do
+// not depend on the Ingress, the operator's flags or a secret store.
+func validateSpec(spec *x402v1alpha1.X402RouteSpec) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	payment := &spec.Payment
+	paymentPath := specPath.Child("payment")
+	if (payment.Wallet == "") == (payment.WalletSecretRef == nil) {
+		errs = append(errs, field.Required(paymentPath.Child("wallet"), "exactly one of wallet and walletSecretRef must be set"))
+	}
+	if len(spec.VariablesFrom) == 0 {
+		if path := placeholderField(spec); path != "" {
+			errs = append(errs, field.Required(specPath.Child("variablesFrom"), path+" has a placeholder"))
+		}
+	}
+	if payment.FacilitatorURL != "" && !variablePlaceholder.MatchString(payment.FacilitatorURL) {
+		if err := validateFacilitatorURL(payment.FacilitatorURL); err != nil {
+			errs = append(errs, field.Invalid(paymentPath.Child("facilitatorURL"), payment.FacilitatorURL, err.Error()))
+		}
+	}
+	for i, u := range payment.FacilitatorURLs {
+		if err := validateFacilitatorURL(u); err != nil {
+			errs = append(errs, field.Invalid(paymentPath.Child("facilitatorURLs").Index(i), u, err.Error()))
+		}
+	}
+	if spec.Sandbox {
+		if _, err := sandboxNetwork(payment.Network); err != nil {
+			errs = append(errs, field.Invalid(paymentPath.Child("network"), payment.Network, err.Error()))
+		}
+	}
+
+	if s := spec.Sidecar; s != nil {
+		sidecarPath := specPath.Child("sidecar")
+		switch {
+		case (s.Port == 0) == (s.SocketPath == ""):
+			errs = append(errs, field.Invalid(sidecarPath, "", "exactly one of port and socketPath must be set"))
+		case s.SocketPath != "" && !filepath.IsAbs(s.SocketPath):
+			errs = append(errs, field.Invalid(sidecarPath.Child("socketPath"), s.SocketPath, "must be absolute"))
+		}
+	}
+
+	for i, bt := range spec.BackendTLS {
+		if bt.CASecretRef != nil && bt.InsecureSkipVerify {
+			errs = append(errs, field.Invalid(specPath.Child("backendTLS").Index(i), bt.Service, "caSecretRef and insecureSkipVerify are mutually exclusive"))
+		}
+	}
+
+	if spec.WaitingRoom != nil && spec.MaxConcurrent <= 0 {
+		errs = append(errs, field.Required(specPath.Child("maxConcurrent"), "waitingRoom requires maxConcurrent"))
+	}
+
+	if _, err := compilePaidCaching(spec.PaidResponseCaching); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("paidResponseCaching"), spec.PaidResponseCaching.Policy, err.Error()))
+	}
+
+	errs = append(errs, validateClusterOverrides(spec, specPath.Child("clusterOverrides"))...)
+	for i := range spec.Routes {
+		errs = append(errs, validateRule(spec, &spec.Routes[i], specPath.Child("routes").Index(i))...)
+	}
+	return errs
+}
+
+// validateClusterOverrides checks the overrides of every cluster named in
+// spec.clusterOverrides, as clusterPrices does for the operator's own.
+func validateClusterOverrides(spec *x402v1alpha1.X402RouteSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	route := &x402v1alpha1.X402Route{Spec: *spec}
+	checked := map[string]bool{}
+	for _, o := range spec.ClusterOverrides {
+		if checked[o.Cluster] {
+			continue
+		}
+		checked[o.Cluster] = true
+		if _, err := clusterPrices(route, o.Cluster); err != nil {
+			errs = append(errs, field.Invalid(path, o.Cluster, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateRule checks the combinations of rule features compileRoute rejects.
+func validateRule(spec *x402v1alpha1.X402RouteSpec, rule *x402v1alpha1.RouteRule, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	hasOffers := len(rule.Offers) > 0
+
+	for i, cond := range rule.Conditions {
+		if _, err := regexp.Compile(cond.Pattern); err != nil {
+			errs = append(errs, field.Invalid(path.Child("conditions").Index(i).Child("pattern"), cond.Pattern, err.Error()))
+		}
+	}
+	seen := make(map[string]bool, len(rule.Offers))
+	for i, offer := range rule.Offers {
+		if seen[offer.Name] {
+			errs = append(errs, field.Duplicate(path.Child("offers").Index(i).Child("name"), offer.Name))
+		}
+		seen[offer.Name] = true
+	}
+	for i, mod := range rule.PriceModifiers {
+		if _, err := compilePriceModifier(mod, hasOffers); err != nil {
+			errs = append(errs, field.Invalid(path.Child("priceModifiers").Index(i), mod.Param, err.Error()))
+		}
+	}
+	if _, err := compileExperiments(*rule); err != nil {
+		errs = append(errs, field.Forbidden(path.Child("experiments"), err.Error()))
+	}
+	if hasOffers {
+		if _, ok := overriddenPaths(spec)[rule.Path]; ok {
+			errs = append(errs, field.Forbidden(path.Child("offers"), "cannot be combined with a cluster override of the rule"))
+		}
+		if rule.GraphQL != nil {
+			errs = append(errs, field.Forbidden(path.Child("graphql"), "cannot be combined with offers"))
+		}
+		if rule.Metering != nil {
+			errs = append(errs, field.Forbidden(path.Child("metering"), "cannot be combined with offers"))
+		}
+	}
+
+	settle := rule.Settle
+	if settle == "" {
+		settle = spec.Payment.Settle
+	}
+	if rule.Metering != nil {
+		if rule.Price == "" && spec.Payment.DefaultPrice == "" && !spec.Sandbox {
+			errs = append(errs, field.Required(path.Child("price"), "metering needs a price as the maximum charge"))
+		}
+		if rule.Settle != "" && rule.Settle != "afterResponse" {
+			errs = append(errs, field.Invalid(path.Child("settle"), rule.Settle, "metered rules settle after the response"))
+		}
+		if rule.Async != nil {
+			errs = append(errs, field.Forbidden(path.Child("async"), "cannot be combined with metering"))
+		}
+	} else if rule.Async != nil && settle == "afterResponse" {
+		errs = append(errs, field.Invalid(path.Child("settle"), settle, "async rules cannot settle after the response"))
+	}
+	if _, err := compileNotModified(*rule); err != nil {
+		errs = append(errs, field.Invalid(path.Child("conditionalRequests"), rule.ConditionalRequests.NotModified, err.Error()))
+	}
+	if rule.RequestValidation != nil {
+		if rule.Free {
+			errs = append(errs, field.Forbidden(path.Child("requestValidation"), "free rules do not pass through the gateway"))
+		} else if _, err := compileRequestValidation(*rule); err != nil {
+			errs = append(errs, field.Invalid(path.Child("requestValidation", "jsonSchema"), field.OmitValueType{}, err.Error()))
+		}
+	}
+	if ext := rule.ExternalBackend; ext != nil {
+		if rule.Free {
+			errs = append(errs, field.Forbidden(path.Child("externalBackend"), "free rules do not pass through the gateway"))
+		}
+		if err := validateExternalBackendURL(ext.URL); err != nil {
+			errs = append(errs, field.Invalid(path.Child("externalBackend", "url"), ext.URL, err.Error()))
+		}
+	}
+	return errs
+}
+
+// overriddenPaths returns the rule paths that have a cluster override in any
+// cluster.
+func overriddenPaths(spec *x402v1alpha1.X402RouteSpec) map[string]struct{} {
+	paths := map[string]struct{}{}
+	for _, o := range spec.ClusterOverrides {
+		if o.Path != "" {
+			paths[o.Path] = struct{}{}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func TestX402RouteValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(spec *x402v1alpha1.X402RouteSpec)
+		wantErr string // empty when the spec is valid
+	}{
+		{name: "valid", mutate: func(*x402v1alpha1.X402RouteSpec) {}},
+		{
+			name: "wallet and walletSecretRef",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Payment.WalletSecretRef = &x402v1alpha1.SecretValueRef{Vault: &x402v1alpha1.VaultSecretRef{Path: "secret/data/x402", Key: "wallet"}}
+			},
+			wantErr: "spec.payment.wallet",
+		},
+		{
+			name:    "private facilitator",
+			mutate:  func(s *x402v1alpha1.X402RouteSpec) { s.Payment.FacilitatorURL = "https://10.0.0.1/facilitator" },
+			wantErr: "spec.payment.facilitatorURL",
+		},
+		{
+			name: "private failover facilitator",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Payment.FacilitatorURLs = []string{"https://x402.org/facilitator", "http://localhost:8080"}
+			},
+			wantErr: "spec.payment.facilitatorURLs[1]",
+		},
+		{
+			name: "sandbox without a test network",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Sandbox = true
+				s.Payment.Network = "unknown"
+			},
+			wantErr: "has no known test network",
+		},
+		{
+			name: "sidecar with port and socket",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Sidecar = &x402v1alpha1.SidecarBackend{Port: 8080, SocketPath: "/tmp/app.sock"}
+			},
+			wantErr: "spec.sidecar",
+		},
+		{
+			name: "backend TLS with CA and insecureSkipVerify",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.BackendTLS = []x402v1alpha1.BackendTLS{{Service: "api", CASecretRef: &x402v1alpha1.CASecretRef{Name: "ca"}, InsecureSkipVerify: true}}
+			},
+			wantErr: "spec.backendTLS[0]",
+		},
+		{
+			name: "external backend in the cluster",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].ExternalBackend = &x402v1alpha1.ExternalBackend{URL: "https://api.default.svc.cluster.local"}
+			},
+			wantErr: "spec.routes[0].externalBackend.url",
+		},
+		{
+			name: "reduced 304 charge without metering",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].ConditionalRequests = &x402v1alpha1.ConditionalRequestPolicy{NotModified: "reduced", ReducedPrice: "0.0001"}
+			},
+			wantErr: "spec.routes[0].conditionalRequests",
+		},
+		{
+			name: "request schema using $ref",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].RequestValidation = &x402v1alpha1.RequestValidationPolicy{JSONSchema: `{"$ref":"#/$defs/order"}`}
+			},
+			wantErr: "spec.routes[0].requestValidation.jsonSchema",
+		},
+		{
+			name: "placeholder without variablesFrom",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Payment.Wallet = "${WALLET}"
+			},
+			wantErr: "spec.variablesFrom",
+		},
+		{
+			name: "placeholder facilitator URL with variablesFrom",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Payment.FacilitatorURL = "${FACILITATOR}"
+				s.VariablesFrom = []x402v1alpha1.VariableSource{{ConfigMapRef: &x402v1alpha1.LocalObjectReference{Name: "stage"}}}
+			},
+		},
+		{
+			name: "waiting room without maxConcurrent",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.WaitingRoom = &x402v1alpha1.WaitingRoomPolicy{}
+			},
+			wantErr: "spec.maxConcurrent",
+		},
+		{
+			name: "custom caching without cacheControl",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.PaidResponseCaching = &x402v1alpha1.ResponseCachingPolicy{Policy: "custom"}
+			},
+			wantErr: "spec.paidResponseCaching",
+		},
+		{
+			name: "override of an unknown path",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.ClusterOverrides = []x402v1alpha1.ClusterOverride{{Cluster: "eu", Path: "/missing", Price: "0.1"}}
+			},
+			wantErr: "no rule with this path",
+		},
+		{
+			name: "invalid condition pattern",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].Conditions = []x402v1alpha1.PaymentCondition{{Header: "X-Tier", Pattern: "(", Action: "free"}}
+			},
+			wantErr: "spec.routes[0].conditions[0].pattern",
+		},
+		{
+			name: "offers with metering",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].Offers = []x402v1alpha1.PriceOffer{{Name: "standard", Price: "0.01"}}
+				s.Routes[0].Metering = &x402v1alpha1.MeteringPolicy{UnitHeader: "X-Units", UnitPrice: "0.001"}
+			},
+			wantErr: "spec.routes[0].metering",
+		},
+		{
+			name: "modifier price with offers",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].Offers = []x402v1alpha1.PriceOffer{{Name: "standard", Price: "0.01"}}
+				s.Routes[0].PriceModifiers = []x402v1alpha1.PriceModifier{{Param: "size", Pattern: "xl", Price: "0.1"}}
+			},
+			wantErr: "spec.routes[0].priceModifiers[0]",
+		},
+		{
+			name: "experiments over 100 percent",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].Experiments = []x402v1alpha1.PriceExperiment{{Name: "a", Price: "0.01", Percent: 60}, {Name: "b", Price: "0.02", Percent: 60}}
+			},
+			wantErr: "spec.routes[0].experiments",
+		},
+		{
+			name: "metering without a price",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Routes[0].Metering = &x402v1alpha1.MeteringPolicy{UnitHeader: "X-Units", UnitPrice: "0.001"}
+			},
+			wantErr: "spec.routes[0].price",
+		},
+		{
+			name: "async settling after the response",
+			mutate: func(s *x402v1alpha1.X402RouteSpec) {
+				s.Payment.Settle = "afterResponse"
+				s.Routes[0].Async = &x402v1alpha1.AsyncPolicy{}
+			},
+			wantErr: "spec.routes[0].settle",
+		},
+	}
+
+	v := &X402RouteValidator{}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestRoute()
+			route.Name = "my-api"
+			tt.mutate(&route.Spec)
+
+			_, err := v.ValidateCreate(ctx, route)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateCreate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateCreate() error = %v, want %q", err, tt.wantErr)
+			}
+
+			// Updates that leave an invalid spec unchanged, or delete the
+			// route, are allowed.
+			if _, err := v.ValidateUpdate(ctx, route.DeepCopy(), route); err != nil {
+				t.Errorf("ValidateUpdate() of an unchanged spec error = %v", err)
+			}
+			changed := route.DeepCopy()
+			changed.Spec.Routes = append(changed.Spec.Routes, x402v1alpha1.RouteRule{Path: "/extra"})
+			if _, err := v.ValidateUpdate(ctx, route, changed); err == nil {
+				t.Error("ValidateUpdate() of a changed invalid spec succeeded")
+			}
+			now := metav1.Now()
+			changed.DeletionTimestamp = &now
+			if _, err := v.ValidateUpdate(ctx, route, changed); err != nil {
+				t.Errorf("ValidateUpdate() of a deleted route error = %v", err)
+			}
+		})
+	}
+}
+
+func TestX402RouteValidatorReferenceGrant(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+
+	tests := []struct {
+		name      string
+		ingressNS string
+		allowed   string // the Ingress grant annotation, unset when empty
+		noIngress bool
+		wantErr   bool
+	}{
+		{name: "same namespace", ingressNS: "team-a"},
+		{name: "cross namespace without grant", ingressNS: "default", wantErr: true},
+		{name: "cross namespace granted", ingressNS: "default", allowed: "team-b, team-a"},
+		{name: "other namespace granted", ingressNS: "default", allowed: "team-b", wantErr: true},
+		{name: "missing Ingress", ingressNS: "default", noIngress: true},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := newTestIngress()
+			ingress.Namespace = "default"
+			if tt.allowed != "" {
+				ingress.Annotations = map[string]string{annotationAllowedRouteNamespaces: tt.allowed}
+			}
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if !tt.noIngress {
+				builder = builder.WithObjects(ingress)
+			}
+			v := &X402RouteValidator{Client: builder.Build()}
+
+			route := newTestRoute()
+			route.Name, route.Namespace = "my-api", "team-a"
+			route.Spec.IngressRef.Name = ingress.Name
+			route.Spec.IngressRef.Namespace = tt.ingressNS
+
+			_, err := v.ValidateCreate(ctx, route)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "spec.ingressRef.namespace") {
+					t.Fatalf("ValidateCreate() error = %v, want spec.ingressRef.namespace", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateCreate() error = %v", err)
+			}
+		})
+	}
+}
@@ -3,28 +3,45 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"math/big"
+	"net/http"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/cloudevents"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/fleet"
 	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
 	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/version"
 )
 
 const (
@@ -34,33 +51,95 @@ const (
 
 	annotationOriginalBackends = "x402.io/original-backends"
 	annotationManagedBy        = "x402.io/managed-by"
+
+	// annotationOperatorVersion records the operator version that last
+	// patched the Ingress.
+	annotationOperatorVersion = "x402.io/operator-version"
+
+	// defaultGraphQLMaxBodyBytes bounds the body read of GraphQL rules.
+	defaultGraphQLMaxBodyBytes = 64 << 10
+
+	// defaultMeteringMaxResponseBytes bounds the buffered response of metered
+	// rules and the stored result of async rules.
+	defaultMeteringMaxResponseBytes = 10 << 20
+
+	// defaultAsyncTimeout bounds async jobs and how long their results are kept.
+	defaultAsyncTimeout = time.Hour
+
+	// Waiting room defaults.
+	defaultWaitingRoomMaxRetryAfter = time.Minute
+	defaultWaitingRoomTokenTTL      = 5 * time.Minute
+	defaultWaitingRoomPriorityWait  = 10 * time.Second
+
+	// Idempotency-Key replay defaults.
+	defaultIdempotencyWindow           = 10 * time.Minute
+	defaultIdempotencyMaxResponseBytes = 1 << 20
 )
 
 // X402RouteReconciler reconciles an X402Route object.
 type X402RouteReconciler struct {
 	client.Client
-	Scheme             *runtime.Scheme
-	RouteStore         *routestore.Store
-	OperatorNamespace  string // namespace where the operator runs (e.g. "x402-system")
-	OperatorSvcName    string // service name of the operator (e.g. "x402-k8s-operator")
+	Scheme            *runtime.Scheme
+	RouteStore        *routestore.Store
+	OperatorNamespace string               // namespace where the operator runs (e.g. "x402-system")
+	OperatorSvcName   string               // service name of the operator (e.g. "x402-k8s-operator")
+	Recorder          events.EventRecorder // optional; receives compile behavior changes
+	// CloudEvents publishes route lifecycle changes to a sink; optional.
+	CloudEvents *cloudevents.Publisher
+	// AllowSidecarBackends permits spec.sidecar, which points the gateway at
+	// ports and sockets in its own pod.
+	AllowSidecarBackends bool
+	// AllowExternalBackends permits rules with an externalBackend, which
+	// points the gateway at hosts outside the cluster.
+	AllowExternalBackends bool
+	// ClusterName selects the spec.clusterOverrides that apply in this cluster.
+	ClusterName string
+	Fleet       *fleet.Client // optional; exchanges route pricing with other clusters
+	// Charge is the minimum charge and price increment of routes that set
+	// neither.
+	Charge ChargePolicy
+	// Secrets reads values referenced from Vault, such as
+	// payment.walletSecretRef; optional.
+	Secrets *vault.Secrets
+	// APIReader reads the Secrets of spec.backendTLS uncached, so Secrets
+	// are not watched cluster-wide; optional, the client is used without it.
+	APIReader client.Reader
+
+	backoff          dependencyBackoff
+	compiles         compileCache
+	externalServices serviceSet
 }
 
 // +kubebuilder:rbac:groups=x402.io,resources=x402routes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=x402.io,resources=x402routes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=x402.io,resources=x402routes/finalizers,verbs=update
+// +kubebuilder:rbac:groups=x402.io,resources=x402priceplans,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
 // +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
 
-func (r *X402RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *X402RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	logger := log.FromContext(ctx)
 
+	// Errors retried with a requeue instead of being returned; they count as
+	// waiting and are recorded in the status like returned errors.
+	var waitErr error
+	defer func() {
+		metrics.ReconcilesTotal.WithLabelValues(reconcileResult(err, waitErr)).Inc()
+	}()
+
 	// Fetch the X402Route instance.
 	var route x402v1alpha1.X402Route
 	if err := r.Get(ctx, req.NamespacedName, &route); err != nil {
 		if apierrors.IsNotFound(err) {
 			logger.Info("X402Route resource not found, likely deleted")
+			r.backoff.reset(req.NamespacedName)
+			r.compiles.forget(req.NamespacedName)
+			metrics.PausedRoutes.DeleteLabelValues(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "unable to fetch X402Route")
@@ -70,7 +149,14 @@ func (r *X402RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	// Handle deletion with finalizer.
 	if !route.DeletionTimestamp.IsZero() {
 		if controllerutil.ContainsFinalizer(&route, finalizerName) {
-			if err := r.cleanupResources(ctx, &route); err != nil {
+			// Record the cleanup progress, so a blocked deletion shows which
+			// steps are left.
+			base := route.DeepCopy()
+			err := r.cleanupResources(ctx, &route)
+			if perr := r.Status().Patch(ctx, &route, client.MergeFrom(base)); perr != nil {
+				logger.Error(perr, "failed to patch X402Route cleanup status")
+			}
+			if err != nil {
 				logger.Error(err, "failed to clean up resources")
 				return ctrl.Result{}, err
 			}
@@ -78,7 +164,11 @@ func (r *X402RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			if err := r.Update(ctx, &route); err != nil {
 				return ctrl.Result{}, err
 			}
+			r.publish(cloudevents.RouteDeleted, &route, nil)
 		}
+		r.backoff.reset(req.NamespacedName)
+		r.compiles.forget(req.NamespacedName)
+		metrics.PausedRoutes.DeleteLabelValues(req.Namespace, req.Name)
 		return ctrl.Result{}, nil
 	}
 
@@ -88,6 +178,24 @@ func (r *X402RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		if err := r.Update(ctx, &route); err != nil {
 			return ctrl.Result{}, err
 		}
+		r.publish(cloudevents.RouteCreated, &route, nil)
+	}
+
+	// Whatever the outcome, record it in the status, including the errors
+	// that are retried with a requeue instead of being returned.
+	base := route.DeepCopy()
+	defer func() {
+		statusErr := err
+		if statusErr == nil {
+			statusErr = waitErr
+		}
+		r.patchStatus(ctx, &route, base, statusErr)
+	}()
+
+	// A paused route is left as it is; deletion above still cleans it up.
+	if isPaused(&route) {
+		logger.Info("X402Route is paused, skipping reconciliation")
+		return ctrl.Result{}, r.pause(ctx, &route, nil, "the X402Route")
 	}
 
 	// Resolve Ingress namespace.
@@ -103,64 +211,222 @@ func (r *X402RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		Namespace: ingressNS,
 	}
 	if err := r.Get(ctx, ingressKey, ingress); err != nil {
-		logger.Error(err, "failed to fetch referenced Ingress")
 		r.setCondition(&route, "IngressPatched", metav1.ConditionFalse, "IngressNotFound", err.Error())
-		r.updateStatus(ctx, &route, false, false, 0)
+		r.setStatus(&route, false, false, 0)
+		// Creating an Ingress does not wake the routes referencing it, so
+		// poll for it with a growing delay rather than failing the reconcile.
+		if apierrors.IsNotFound(err) {
+			after := r.backoff.next(req.NamespacedName)
+			logger.Info("referenced Ingress not found, requeueing", "ingress", ingressKey, "after", after)
+			waitErr = err
+			return ctrl.Result{RequeueAfter: after}, nil
+		}
+		logger.Error(err, "failed to fetch referenced Ingress")
 		return ctrl.Result{}, err
 	}
+	r.backoff.reset(req.NamespacedName)
+
+	// A route in another namespace may only gate the Ingress once the Ingress
+	// grants it; until then the gateway does not serve it either.
+	if !referenceGranted(&route, ingress) {
+		msg := fmt.Sprintf("Ingress %s/%s does not allow X402Routes from namespace %s; add it to the %s annotation", ingressNS, ingress.Name, route.Namespace, annotationAllowedRouteNamespaces)
+		logger.Info("cross-namespace reference not granted", "ingress", ingressKey)
+		r.RouteStore.Delete(route.Namespace, route.Name)
+		r.compiles.forget(req.NamespacedName)
+		metrics.ActiveRoutes.Set(float64(r.RouteStore.Count()))
+		r.setCondition(&route, "IngressPatched", metav1.ConditionFalse, "ReferenceNotGranted", msg)
+		r.setCondition(&route, "Ready", metav1.ConditionFalse, "ReferenceNotGranted", msg)
+		r.setStatus(&route, false, false, 0)
+		return ctrl.Result{}, nil
+	}
 
-	backends := r.extractBackends(ingress)
+	if isPaused(ingress) {
+		logger.Info("Ingress is paused, skipping reconciliation", "ingress", ingressKey)
+		return ctrl.Result{}, r.pause(ctx, &route, ingress, "Ingress "+ingressKey.String())
+	}
+	r.resume(&route)
 
-	// Step 2: Compile CRD rules into route store.
-	compiled, err := r.compileRoute(&route, backends, ingress)
+	source, backends, err := r.resolveSources(ctx, &route, ingress)
 	if err != nil {
-		logger.Error(err, "failed to compile route rules")
-		r.setCondition(&route, "Ready", metav1.ConditionFalse, "CompileError", err.Error())
-		r.updateStatus(ctx, &route, false, false, 0)
+		reason := sourceReason(err)
+		r.setCondition(&route, "Ready", metav1.ConditionFalse, reason, err.Error())
+		r.setStatus(&route, false, false, 0)
+		// A missing source is waited for: price plans are watched, and other
+		// sources are looked for again on their resync.
+		if after, ok := sourceResync[reason]; ok && apierrors.IsNotFound(err) {
+			logger.Info("referenced source not found", "reason", reason, "error", err.Error())
+			waitErr = err
+			return ctrl.Result{RequeueAfter: after}, nil
+		}
+		logger.Error(err, "failed to resolve route sources", "reason", reason)
 		return ctrl.Result{}, err
 	}
 
-	r.RouteStore.Set(route.Namespace, route.Name, compiled)
-	metrics.RouteStoreUpdatesTotal.Inc()
-	metrics.ActiveRoutes.Set(float64(r.RouteStore.Count()))
+	// Step 2: Compile CRD rules into route store, unless the stored route
+	// was compiled from the same spec, secret values and backends.
+	inputs := compileInputs(source, backends, ingress)
+	compiled := r.compiles.lookup(r.RouteStore, req.NamespacedName, inputs)
+	cached := compiled != nil
+	if cached {
+		metrics.CompileCacheTotal.WithLabelValues("hit").Inc()
+	} else {
+		metrics.CompileCacheTotal.WithLabelValues("miss").Inc()
+		compiled, err = r.compileRoute(source, backends, ingress)
+		if err != nil {
+			logger.Error(err, "failed to compile route rules")
+			r.setCondition(&route, "Ready", metav1.ConditionFalse, "CompileError", err.Error())
+			r.setStatus(&route, false, false, 0)
+			r.publish(cloudevents.CompileFailed, &route, err)
+			// Compile errors come from the spec: retrying cannot succeed
+			// until the spec changes, which wakes the route.
+			return ctrl.Result{}, reconcile.TerminalError(err)
+		}
+		r.RouteStore.Set(route.Namespace, route.Name, compiled)
+		r.compiles.record(req.NamespacedName, inputs)
+		metrics.RouteStoreUpdatesTotal.Inc()
+		metrics.ActiveRoutes.Set(float64(r.RouteStore.Count()))
+	}
+
+	r.recordCompilation(&route, compiled)
+	route.Status.Rules = ruleStatuses(&route, route.Status.Rules)
+	if observed := route.Status.ObservedGeneration; observed != 0 && observed != route.Generation {
+		r.publish(cloudevents.RouteUpdated, &route, nil)
+	}
+	route.Status.ObservedGeneration = route.Generation
+	r.syncFleet(ctx, &route)
 
 	// Step 3: Ensure ExternalName service for cross-namespace routing.
 	if err := r.ensureExternalNameService(ctx, ingressNS); err != nil {
 		logger.Error(err, "failed to create ExternalName service")
 		r.setCondition(&route, "ExternalServiceReady", metav1.ConditionFalse, "ServiceError", err.Error())
-		r.updateStatus(ctx, &route, false, false, len(compiled.Rules))
+		r.setStatus(&route, false, false, len(compiled.Rules))
 		return ctrl.Result{}, err
 	}
 
 	// Step 4: Patch Ingress — paid paths -> operator service, free paths unchanged.
+	// With confirmPatch or a required approval, publish the pending changes
+	// and hold them until confirmed or approved.
+	var preview string
+	if route.Spec.ConfirmPatch || approvalRequired(&route) {
+		preview, err = r.previewPatch(&route, ingress)
+		if err != nil {
+			logger.Error(err, "failed to preview Ingress patch")
+			r.setCondition(&route, "IngressPatched", metav1.ConditionFalse, "PatchError", err.Error())
+			r.setStatus(&route, false, false, len(compiled.Rules))
+			return ctrl.Result{}, err
+		}
+	}
+	if preview != "" {
+		hash := patchHash(preview)
+		var reason, message string
+		switch {
+		case route.Spec.ConfirmPatch:
+			reason = "AwaitingConfirmation"
+			message = "Ingress patch preview published in status.pendingPatch; set spec.confirmPatch to false to apply"
+		case !patchApproved(&route, hash):
+			reason = "AwaitingApproval"
+			message = fmt.Sprintf("Ingress patch preview published in status.pendingPatch; annotate with %s=true or %s=%s to apply", annotationApproved, annotationApproved, hash)
+		}
+		if reason != "" {
+			route.Status.PendingPatch = preview
+			route.Status.PendingPatchHash = hash
+			r.setCondition(&route, "IngressPatched", metav1.ConditionFalse, reason, message)
+			r.setStatus(&route, isManaged(ingress), false, len(compiled.Rules))
+			return ctrl.Result{}, nil
+		}
+		logger.Info("applying approved Ingress patch", "hash", hash)
+	}
+	route.Status.PendingPatch = ""
+	route.Status.PendingPatchHash = ""
+
 	if err := r.patchIngress(ctx, &route, ingress); err != nil {
 		logger.Error(err, "failed to patch Ingress")
 		r.setCondition(&route, "IngressPatched", metav1.ConditionFalse, "PatchError", err.Error())
-		r.updateStatus(ctx, &route, false, false, len(compiled.Rules))
+		r.setStatus(&route, false, false, len(compiled.Rules))
 		return ctrl.Result{}, err
 	}
 	r.setCondition(&route, "IngressPatched", metav1.ConditionTrue, "Reconciled", "Ingress patched for payment gating")
+	if err := r.reconcileBypass(ctx, &route, ingress); err != nil {
+		logger.Error(err, "failed to reconcile bypass Ingress")
+		r.setCondition(&route, "Ready", metav1.ConditionFalse, "BypassError", err.Error())
+		r.setStatus(&route, true, false, len(compiled.Rules))
+		return ctrl.Result{}, err
+	}
+	if route.Spec.Fallback != nil {
+		r.setCondition(&route, "GatewayAvailable", metav1.ConditionTrue, "GatewayServing", "Paid paths are served by the gateway")
+	}
+
+	// An approval covers a single patch; later changes need a fresh one.
+	if preview != "" {
+		if err := r.consumeApproval(ctx, &route); err != nil {
+			logger.Error(err, "failed to remove approval annotation")
+			return ctrl.Result{}, err
+		}
+	}
 
 	// Step 5: Update status.
 	r.setCondition(&route, "Ready", metav1.ConditionTrue, "Reconciled", "Route is active and serving traffic")
-	r.updateStatus(ctx, &route, true, true, len(compiled.Rules))
+	r.setStatus(&route, true, true, len(compiled.Rules))
 
-	logger.Info("reconciliation complete",
+	// Reconciles that changed nothing are only logged at debug level.
+	done := logger
+	if cached {
+		done = logger.V(1)
+	}
+	done.Info("reconciliation complete",
 		"ingress", ingressKey.String(),
 		"activeRoutes", len(compiled.Rules),
 	)
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.resyncInterval(&route)}, nil
 }
 
 // compileRoute converts CRD route rules into a CompiledRoute for the gateway.
-func (r *X402RouteReconciler) compileRoute(route *x402v1alpha1.X402Route, backends map[string]string, ingress *networkingv1.Ingress) (*routestore.CompiledRoute, error) {
-	facilitatorURL := route.Spec.Payment.FacilitatorURL
-	if facilitatorURL == "" {
-		facilitatorURL = "https://x402.org/facilitator"
+func (r *X402RouteReconciler) compileRoute(route *x402v1alpha1.X402Route, backends []routestore.CompiledBackend, ingress *networkingv1.Ingress) (*routestore.CompiledRoute, error) {
+	facilitatorURLs := route.Spec.Payment.FacilitatorURLs
+	if len(facilitatorURLs) == 0 {
+		facilitatorURLs = []string{route.Spec.Payment.FacilitatorURL}
+	}
+	if facilitatorURLs[0] == "" {
+		facilitatorURLs[0] = "https://x402.org/facilitator"
+	}
+	for _, facilitatorURL := range facilitatorURLs {
+		if err := validateFacilitatorURL(facilitatorURL); err != nil {
+			return nil, fmt.Errorf("invalid facilitator URL %q: %w", facilitatorURL, err)
+		}
+	}
+	if route.Spec.Payment.Wallet == "" {
+		return nil, errors.New("payment.wallet or payment.walletSecretRef is required")
+	}
+	facilitatorAuth, err := compileFacilitatorAuth(route.Spec.Payment.FacilitatorAuth)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := validateFacilitatorURL(facilitatorURL); err != nil {
-		return nil, fmt.Errorf("invalid facilitator URL %q: %w", facilitatorURL, err)
+	if route.Spec.Sidecar != nil {
+		sidecar, err := r.sidecarBackend(route.Spec.Sidecar)
+		if err != nil {
+			return nil, err
+		}
+		backends = []routestore.CompiledBackend{sidecar}
+	}
+
+	overrides, err := clusterPrices(route, r.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	defaultPrice := route.Spec.Payment.DefaultPrice
+	if price, ok := overrides[""]; ok {
+		defaultPrice = price
+	}
+	network, asset := route.Spec.Payment.Network, route.Spec.Payment.Asset
+	if route.Spec.Sandbox {
+		if network, err = sandboxNetwork(network); err != nil {
+			return nil, err
+		}
+		asset = ""
+		if defaultPrice == "" {
+			defaultPrice = sandboxPrice
+		}
 	}
 
 	// Extract hosts from ingress rules.
@@ -170,21 +436,97 @@ func (r *X402RouteReconciler) compileRoute(route *x402v1alpha1.X402Route, backen
 			hosts = append(hosts, rule.Host)
 		}
 	}
+	// Resource URLs use https on the hosts the Ingress serves TLS for.
+	var tlsHosts []string
+	for _, tls := range ingress.Spec.TLS {
+		tlsHosts = append(tlsHosts, tls.Hosts...)
+	}
 
 	compiled := &routestore.CompiledRoute{
-		Name:           route.Name,
-		Namespace:      route.Namespace,
-		Hosts:          hosts,
-		Wallet:         route.Spec.Payment.Wallet,
-		Network:        route.Spec.Payment.Network,
-		FacilitatorURL: facilitatorURL,
-		DefaultPrice:   route.Spec.Payment.DefaultPrice,
-		Backends:       backends,
+		Name:            route.Name,
+		Namespace:       route.Namespace,
+		Generation:      route.Generation,
+		Hosts:           hosts,
+		TLSHosts:        tlsHosts,
+		Wallet:          route.Spec.Payment.Wallet,
+		Network:         network,
+		Asset:           asset,
+		FacilitatorURL:  facilitatorURLs[0],
+		FacilitatorType: route.Spec.Payment.FacilitatorType,
+		FacilitatorAuth: facilitatorAuth,
+		DefaultPrice:    defaultPrice,
+		Backends:        backends,
+		Unmatched:       route.Spec.UnmatchedBehavior,
+		CompilerVersion: compilerVersion,
+		MaxConcurrent:   route.Spec.MaxConcurrent,
+		QueueWait:       time.Duration(route.Spec.MaxQueueWaitSeconds) * time.Second,
+		Sandbox:         route.Spec.Sandbox,
+		SettleAbandoned: route.Spec.Payment.SettleAbandoned,
+		BindResource:    route.Spec.Payment.BindResource,
+		BindClient:      route.Spec.Payment.BindClient,
+	}
+	if compiled.Unmatched == "" {
+		compiled.Unmatched = "404"
+	}
+	if len(facilitatorURLs) > 1 {
+		compiled.FailoverURLs = slices.Clone(facilitatorURLs[1:])
+		compiled.FacilitatorHealthy = route.Spec.Payment.FacilitatorSelection == "healthy"
+	}
+	if compiled.MinimumCharge, compiled.PriceIncrement, err = r.compileCharge(&route.Spec.Payment); err != nil {
+		return nil, err
+	}
+	if t := route.Spec.Payment.FacilitatorTimeouts; t != nil {
+		compiled.VerifyTimeout = time.Duration(t.VerifySeconds) * time.Second
+		compiled.SettleTimeout = time.Duration(t.SettleSeconds) * time.Second
+		compiled.PaymentBudget = time.Duration(t.BudgetMilliseconds) * time.Millisecond
+	}
+	compiled.OnFacilitatorError = route.Spec.OnFacilitatorError
+	if compiled.OnFacilitatorError == "" {
+		compiled.OnFacilitatorError = "failClosed"
+	}
+	if cb := route.Spec.SettlementCallbacks; cb != nil && cb.Enabled {
+		compiled.Callbacks = true
+		compiled.CallbackHosts = cb.AllowedHosts
+	}
+	if m := route.Spec.Mirror; m != nil {
+		compiled.Mirror = &routestore.CompiledMirror{
+			URL:     fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", m.BackendRef.ServiceName, ingress.Namespace, m.BackendRef.ServicePort),
+			Percent: m.Percent,
+		}
+	}
+
+	compiled.PaidCaching, err = compilePaidCaching(route.Spec.PaidResponseCaching)
+	if err != nil {
+		return nil, err
+	}
+	compiled.Exemptions = compileExemptions(route.Spec.Exemptions)
+	if wr := route.Spec.WaitingRoom; wr != nil && route.Spec.MaxConcurrent > 0 {
+		compiled.WaitingRoom = &routestore.CompiledWaitingRoom{
+			MaxRetryAfter: time.Duration(wr.MaxRetryAfterSeconds) * time.Second,
+			TokenTTL:      time.Duration(wr.TokenTTLSeconds) * time.Second,
+			PriorityWait:  time.Duration(wr.PriorityWaitSeconds) * time.Second,
+		}
+		if compiled.WaitingRoom.MaxRetryAfter == 0 {
+			compiled.WaitingRoom.MaxRetryAfter = defaultWaitingRoomMaxRetryAfter
+		}
+		if compiled.WaitingRoom.TokenTTL == 0 {
+			compiled.WaitingRoom.TokenTTL = defaultWaitingRoomTokenTTL
+		}
+		if compiled.WaitingRoom.PriorityWait == 0 {
+			compiled.WaitingRoom.PriorityWait = defaultWaitingRoomPriorityWait
+		}
+	}
+	if rep := route.Spec.Reputation; rep != nil && (rep.GraylistScore > 0 || rep.DenyScore > 0) {
+		compiled.Reputation = &routestore.CompiledReputation{
+			GraylistScore: float64(rep.GraylistScore),
+			DenyScore:     float64(rep.DenyScore),
+		}
 	}
 
-	for _, rule := range route.Spec.Routes {
+	for _, rule := range enabledRules(route) {
 		cr := routestore.CompiledRule{
 			Path: rule.Path,
+			Host: rule.Host,
 			Free: rule.Free,
 			Mode: rule.Mode,
 		}
@@ -192,12 +534,38 @@ func (r *X402RouteReconciler) compileRoute(route *x402v1alpha1.X402Route, backen
 		if cr.Mode == "" {
 			cr.Mode = "all-pay"
 		}
+		if rule.Host != "" && !ingressServesHost(ingress, rule.Host) {
+			return nil, fmt.Errorf("rule %q: host %q is not served by Ingress %s", rule.Path, rule.Host, ingress.Name)
+		}
 
 		// Resolve effective price.
 		if rule.Price != "" {
 			cr.Price = rule.Price
 		} else {
-			cr.Price = route.Spec.Payment.DefaultPrice
+			cr.Price = defaultPrice
+		}
+		if price, ok := overrides[rule.Path]; ok {
+			if len(rule.Offers) > 0 {
+				return nil, fmt.Errorf("rule %q: cluster override cannot replace offers", rule.Path)
+			}
+			cr.Price = price
+		}
+
+		// Compile offers; the first offer's price stands in for the rule price.
+		seen := make(map[string]bool, len(rule.Offers))
+		for _, offer := range rule.Offers {
+			if seen[offer.Name] {
+				return nil, fmt.Errorf("rule %q: duplicate offer %q", rule.Path, offer.Name)
+			}
+			seen[offer.Name] = true
+			cr.Offers = append(cr.Offers, routestore.CompiledOffer{
+				Name:    offer.Name,
+				Price:   offer.Price,
+				Headers: offer.Headers,
+			})
+		}
+		if len(cr.Offers) > 0 {
+			cr.Price = cr.Offers[0].Price
 		}
 
 		// Compile conditions.
@@ -213,54 +581,371 @@ func (r *X402RouteReconciler) compileRoute(route *x402v1alpha1.X402Route, backen
 			})
 		}
 
+		// Compile price modifiers.
+		for _, mod := range rule.PriceModifiers {
+			cm, err := compilePriceModifier(mod, len(rule.Offers) > 0)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: price modifier for %q: %w", rule.Path, mod.Param, err)
+			}
+			cr.Modifiers = append(cr.Modifiers, cm)
+		}
+
+		cr.Experiments, err = compileExperiments(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Path, err)
+		}
+
+		if gql := rule.GraphQL; gql != nil {
+			if len(rule.Offers) > 0 {
+				return nil, fmt.Errorf("rule %q: graphql pricing cannot be combined with offers", rule.Path)
+			}
+			cr.GraphQL = &routestore.CompiledGraphQL{
+				Operations:   gql.Operations,
+				MaxBodyBytes: gql.MaxBodyBytes,
+			}
+			if cr.GraphQL.MaxBodyBytes == 0 {
+				cr.GraphQL.MaxBodyBytes = defaultGraphQLMaxBodyBytes
+			}
+		}
+
+		if m := rule.Metering; m != nil {
+			if len(rule.Offers) > 0 {
+				return nil, fmt.Errorf("rule %q: metering cannot be combined with offers", rule.Path)
+			}
+			if cr.Price == "" {
+				return nil, fmt.Errorf("rule %q: metering needs a price as the maximum charge", rule.Path)
+			}
+			unitPrice, ok := new(big.Rat).SetString(m.UnitPrice)
+			if !ok || unitPrice.Sign() < 0 {
+				return nil, fmt.Errorf("rule %q: invalid metering unit price %q", rule.Path, m.UnitPrice)
+			}
+			cr.Metering = &routestore.CompiledMetering{
+				UnitHeader:       m.UnitHeader,
+				UnitPrice:        unitPrice,
+				MaxResponseBytes: m.MaxResponseBytes,
+			}
+			if cr.Metering.MaxResponseBytes == 0 {
+				cr.Metering.MaxResponseBytes = defaultMeteringMaxResponseBytes
+			}
+		}
+
+		if a := rule.Async; a != nil {
+			if rule.Metering != nil {
+				return nil, fmt.Errorf("rule %q: async cannot be combined with metering", rule.Path)
+			}
+			cr.Async = &routestore.CompiledAsync{
+				Timeout:        time.Duration(a.TimeoutSeconds) * time.Second,
+				ResultTTL:      time.Duration(a.ResultTTLSeconds) * time.Second,
+				MaxResultBytes: a.MaxResultBytes,
+			}
+			if cr.Async.Timeout == 0 {
+				cr.Async.Timeout = defaultAsyncTimeout
+			}
+			if cr.Async.ResultTTL == 0 {
+				cr.Async.ResultTTL = defaultAsyncTimeout
+			}
+			if cr.Async.MaxResultBytes == 0 {
+				cr.Async.MaxResultBytes = defaultMeteringMaxResponseBytes
+			}
+		}
+
+		if idem := rule.Idempotency; idem != nil {
+			cr.Idempotency = &routestore.CompiledIdempotency{
+				Window:           time.Duration(idem.WindowSeconds) * time.Second,
+				MaxResponseBytes: idem.MaxResponseBytes,
+			}
+			if cr.Idempotency.Window == 0 {
+				cr.Idempotency.Window = defaultIdempotencyWindow
+			}
+			if cr.Idempotency.MaxResponseBytes == 0 {
+				cr.Idempotency.MaxResponseBytes = defaultIdempotencyMaxResponseBytes
+			}
+		}
+
+		if cr.NotModified, err = compileNotModified(rule); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Path, err)
+		}
+		if cr.Validation, err = compileRequestValidation(rule); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Path, err)
+		}
+
+		if rule.ExternalBackend != nil {
+			if rule.Free {
+				return nil, fmt.Errorf("rule %q: free rules do not pass through the gateway and cannot use an external backend", rule.Path)
+			}
+			if cr.Backend, err = r.externalBackend(rule.ExternalBackend); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", rule.Path, err)
+			}
+		}
+
+		cr.Settle = rule.Settle
+		if cr.Settle == "" {
+			cr.Settle = route.Spec.Payment.Settle
+		}
+		switch {
+		case rule.Metering != nil:
+			if rule.Settle != "" && rule.Settle != "afterResponse" {
+				return nil, fmt.Errorf("rule %q: metered rules settle after the response", rule.Path)
+			}
+			cr.Settle = "afterResponse"
+		case rule.Async != nil && cr.Settle == "afterResponse":
+			return nil, fmt.Errorf("rule %q: async rules cannot settle after the response", rule.Path)
+		case cr.Settle == "":
+			cr.Settle = "sync"
+		}
+
 		compiled.Rules = append(compiled.Rules, cr)
 	}
 
 	return compiled, nil
 }
 
-// extractBackends reads original backend info from the Ingress.
-func (r *X402RouteReconciler) extractBackends(ingress *networkingv1.Ingress) map[string]string {
+// compileNotModified returns the charge of conditional requests of rule
+// answered 304, or nil when they are charged in full.
+func compileNotModified(rule x402v1alpha1.RouteRule) (*routestore.CompiledNotModified, error) {
+	c := rule.ConditionalRequests
+	if c == nil || c.NotModified == "" || c.NotModified == "full" {
+		return nil, nil
+	}
+	if rule.Async != nil {
+		return nil, errors.New("async cannot be combined with conditionalRequests")
+	}
+	switch c.NotModified {
+	case "free":
+		return &routestore.CompiledNotModified{Price: new(big.Rat)}, nil
+	case "reduced":
+		if rule.Metering == nil {
+			return nil, errors.New("reduced charges of 304 responses need metering")
+		}
+		price, ok := new(big.Rat).SetString(c.ReducedPrice)
+		if !ok || price.Sign() < 0 {
+			return nil, fmt.Errorf("invalid conditionalRequests.reducedPrice %q", c.ReducedPrice)
+		}
+		return &routestore.CompiledNotModified{Price: price}, nil
+	}
+	return nil, fmt.Errorf("invalid conditionalRequests.notModified %q", c.NotModified)
+}
+
+// compilePaidCaching returns the caching headers of paid responses: none
+// stored by default, the backend's (nil) or the custom ones.
+func compilePaidCaching(policy *x402v1alpha1.ResponseCachingPolicy) (*routestore.CompiledCaching, error) {
+	if policy == nil {
+		return &routestore.CompiledCaching{CacheControl: "private, no-store", SurrogateControl: "no-store"}, nil
+	}
+	switch policy.Policy {
+	case "backend":
+		return nil, nil
+	case "custom":
+		if policy.CacheControl == "" {
+			return nil, fmt.Errorf("paidResponseCaching: the custom policy needs cacheControl")
+		}
+		return &routestore.CompiledCaching{CacheControl: policy.CacheControl, SurrogateControl: policy.SurrogateControl}, nil
+	}
+	return compilePaidCaching(nil)
+}
+
+// compileExemptions returns the requests forwarded without payment: the
+// built-in exemptions by default, the custom ones, or none (nil).
+func compileExemptions(policy *x402v1alpha1.ExemptionPolicy) *routestore.CompiledExemptions {
+	if policy == nil {
+		return &routestore.CompiledExemptions{
+			Methods: []string{http.MethodOptions, http.MethodHead},
+			Paths:   []string{"/robots.txt", "/favicon.ico", "/.well-known/*"},
+		}
+	}
+	switch policy.Policy {
+	case "none":
+		return nil
+	case "custom":
+		if len(policy.Methods) == 0 && len(policy.Paths) == 0 {
+			return nil
+		}
+		return &routestore.CompiledExemptions{Methods: policy.Methods, Paths: policy.Paths}
+	}
+	return compileExemptions(nil)
+}
+
+// compilePriceModifier compiles a query-parameter price modifier. A fixed
+// price cannot be combined with offers, as it would replace all of them.
+func compilePriceModifier(mod x402v1alpha1.PriceModifier, hasOffers bool) (routestore.CompiledPriceModifier, error) {
+	cm := routestore.CompiledPriceModifier{Param: mod.Param, Price: mod.Price}
+	if (mod.Multiplier == "") == (mod.Price == "") {
+		return cm, fmt.Errorf("exactly one of multiplier and price must be set")
+	}
+	if mod.Price != "" && hasOffers {
+		return cm, fmt.Errorf("price cannot be combined with offers; use multiplier")
+	}
+	if mod.Multiplier != "" {
+		m, ok := new(big.Rat).SetString(mod.Multiplier)
+		if !ok || m.Sign() < 0 {
+			return cm, fmt.Errorf("invalid multiplier %q", mod.Multiplier)
+		}
+		cm.Multiplier = m
+	}
+	re, err := regexp.Compile(mod.Pattern)
+	if err != nil {
+		return cm, fmt.Errorf("compile pattern %q: %w", mod.Pattern, err)
+	}
+	cm.Pattern = re
+	return cm, nil
+}
+
+// compileExperiments compiles the price variants of a rule. Variants replace
+// the rule's single price, so they cannot be combined with offers, GraphQL
+// operation prices or fixed-price modifiers.
+func compileExperiments(rule x402v1alpha1.RouteRule) ([]routestore.CompiledExperiment, error) {
+	if len(rule.Experiments) == 0 {
+		return nil, nil
+	}
+	switch {
+	case len(rule.Offers) > 0:
+		return nil, fmt.Errorf("experiments cannot be combined with offers")
+	case rule.GraphQL != nil:
+		return nil, fmt.Errorf("experiments cannot be combined with graphql pricing")
+	}
+	for _, mod := range rule.PriceModifiers {
+		if mod.Price != "" {
+			return nil, fmt.Errorf("price modifier for %q: price cannot be combined with experiments; use multiplier", mod.Param)
+		}
+	}
+	var total int32
+	seen := make(map[string]bool, len(rule.Experiments))
+	compiled := make([]routestore.CompiledExperiment, 0, len(rule.Experiments))
+	for _, e := range rule.Experiments {
+		switch {
+		case e.Name == routestore.ExperimentControl:
+			return nil, fmt.Errorf("experiment name %q is reserved", e.Name)
+		case seen[e.Name]:
+			return nil, fmt.Errorf("duplicate experiment %q", e.Name)
+		case e.Percent < 1 || e.Percent > 100:
+			return nil, fmt.Errorf("experiment %q: percent must be between 1 and 100", e.Name)
+		}
+		seen[e.Name] = true
+		total += e.Percent
+		compiled = append(compiled, routestore.CompiledExperiment{Name: e.Name, Price: e.Price, Percent: e.Percent})
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("experiments take %d percent of the clients, more than 100", total)
+	}
+	return compiled, nil
+}
+
+// sourceResync is how long a route waits for a missing source, by the Ready
+// reason it reports. Sources not listed are not waited for.
+var sourceResync = map[string]time.Duration{
+	"PricePlanUnavailable":     0, // woken by the price plan watch
+	"VariablesUnavailable":     variablesResync,
+	"RequestSchemaUnavailable": requestSchemaResync,
+}
+
+// sourceError is a failure to resolve one of the sources a route is compiled
+// from, with the Ready reason it is reported under.
+type sourceError struct {
+	reason string
+	err    error
+}
+
+func (e *sourceError) Error() string { return e.err.Error() }
+func (e *sourceError) Unwrap() error { return e.err }
+
+// sourceReason returns the Ready reason of an error from resolveSources.
+func sourceReason(err error) string {
+	var serr *sourceError
+	if errors.As(err, &serr) {
+		return serr.reason
+	}
+	return "ReconcileError"
+}
+
+// resolveSources resolves everything a route is compiled from, so active and
+// paused routes compile alike: it applies the price plan, variables and
+// request schemas to route, resolves the backends of ingress, and returns the
+// route with its wallet resolved. Errors are *sourceError.
+func (r *X402RouteReconciler) resolveSources(ctx context.Context, route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress) (*x402v1alpha1.X402Route, []routestore.CompiledBackend, error) {
+	if err := r.applyPricePlan(ctx, route); err != nil {
+		return nil, nil, &sourceError{"PricePlanUnavailable", err}
+	}
+	if err := r.resolveVariables(ctx, route); err != nil {
+		return nil, nil, &sourceError{"VariablesUnavailable", err}
+	}
+	if err := r.resolveRequestSchemas(ctx, route); err != nil {
+		return nil, nil, &sourceError{"RequestSchemaUnavailable", err}
+	}
+
+	backends := r.extractBackends(ingress)
+	r.resolveProtocols(ctx, route, ingress, backends)
+	if err := r.resolveBackendTLS(ctx, route, ingress.Namespace, backends); err != nil {
+		return nil, nil, &sourceError{"BackendTLSUnavailable", err}
+	}
+	if route.Spec.BackendResolution == "endpoints" {
+		r.resolveEndpoints(ctx, ingress.Namespace, backends)
+	}
+
+	source, err := r.resolveWallet(ctx, route)
+	if err != nil {
+		return nil, nil, &sourceError{"SecretUnavailable", err}
+	}
+	return source, backends, nil
+}
+
+// extractBackends reads original backend info from the Ingress, keeping the
+// pathType of each Ingress path so the gateway can apply the same matching.
+func (r *X402RouteReconciler) extractBackends(ingress *networkingv1.Ingress) []routestore.CompiledBackend {
 	logger := log.Log.WithValues("ingress", ingress.Name, "namespace", ingress.Namespace)
 
-	// Check if we already stored original backends.
-	if ingress.Annotations != nil {
-		if stored, ok := ingress.Annotations[annotationOriginalBackends]; ok {
-			var backends map[string]string
-			if err := json.Unmarshal([]byte(stored), &backends); err != nil {
-				logger.Error(err, "corrupted original-backends annotation, re-extracting from Ingress rules")
-				delete(ingress.Annotations, annotationOriginalBackends)
-			} else {
-				result := make(map[string]string)
-				for path, svcPort := range backends {
-					parts := strings.SplitN(svcPort, ":", 2)
-					if len(parts) == 2 {
-						result[path] = fmt.Sprintf("http://%s.%s.svc.cluster.local:%s", parts[0], ingress.Namespace, parts[1])
-					}
-				}
-				return result
-			}
+	// Prefer stored original backends: once patched, the live Ingress points at the gateway.
+	var stored map[string]string
+	if raw, ok := ingress.Annotations[annotationOriginalBackends]; ok {
+		if err := json.Unmarshal([]byte(raw), &stored); err != nil {
+			logger.Error(err, "corrupted original-backends annotation, re-extracting from Ingress rules")
+			delete(ingress.Annotations, annotationOriginalBackends)
+			stored = nil
 		}
 	}
 
-	// Extract from current Ingress rules.
-	backends := make(map[string]string)
+	var backends []routestore.CompiledBackend
+	seen := make(map[string]bool)
 	for _, rule := range ingress.Spec.Rules {
 		if rule.HTTP == nil {
 			continue
 		}
 		for _, p := range rule.HTTP.Paths {
-			if p.Backend.Service != nil {
-				svcName := p.Backend.Service.Name
-				ns := ingress.Namespace
-				port := resolveBackendPort(p.Backend.Service.Port)
-				backends[p.Path] = fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", svcName, ns, port)
+			pathType := pathTypeOf(p)
+			key := string(pathType) + " " + p.Path
+			if seen[key] {
+				continue
 			}
+
+			var original networkingv1.IngressBackend
+			if svcPort, ok := stored[p.Path]; ok {
+				original, _ = parseServiceBackend(svcPort)
+			} else if p.Backend.Service != nil && !r.isGatewayService(p.Backend.Service.Name) {
+				original = p.Backend
+			}
+			if original.Service == nil {
+				continue
+			}
+			svcName := original.Service.Name
+			port := resolveBackendPort(original.Service.Port)
+
+			seen[key] = true
+			backends = append(backends, routestore.CompiledBackend{
+				Path:     p.Path,
+				PathType: string(pathType),
+				URL:      fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", svcName, ingress.Namespace, port),
+				Service:  svcName,
+				Port:     port,
+			})
 		}
 	}
 	return backends
 }
 
+// isGatewayService reports whether a backend service name is the operator's gateway.
+func (r *X402RouteReconciler) isGatewayService(name string) bool {
+	return name == externalSvcName || name == r.OperatorSvcName
+}
+
 // resolveBackendPort returns the port number from an IngressServiceBackendPort.
 func resolveBackendPort(port networkingv1.ServiceBackendPort) int32 {
 	if port.Number != 0 {
@@ -307,6 +992,7 @@ func (r *X402RouteReconciler) ensureExternalNameService(ctx context.Context, nam
 	if err != nil {
 		return fmt.Errorf("ensure ExternalName service in %s: %w", namespace, err)
 	}
+	r.externalServices.add(namespace)
 
 	log.FromContext(ctx).Info("ExternalName service reconciled", "namespace", namespace, "operation", op)
 	return nil
@@ -314,33 +1000,71 @@ func (r *X402RouteReconciler) ensureExternalNameService(ctx context.Context, nam
 
 // patchIngress patches the Ingress to route paid paths through the operator's gateway.
 func (r *X402RouteReconciler) patchIngress(ctx context.Context, route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress) error {
-	if ingress.Annotations == nil {
-		ingress.Annotations = make(map[string]string)
+	before := ingress.DeepCopy()
+	if err := r.applyGatewayPatch(route, ingress); err != nil {
+		return err
+	}
+	if err := checkPreserved(before, ingress); err != nil {
+		return err
 	}
+	// Stamped outside applyGatewayPatch, so an upgrade alone does not
+	// produce a pending patch that needs approval.
+	ingress.Annotations[annotationOperatorVersion] = version.Version
 
-	// Store original backends before patching.
-	if _, ok := ingress.Annotations[annotationOriginalBackends]; !ok {
-		backends := make(map[string]string)
-		for _, rule := range ingress.Spec.Rules {
-			if rule.HTTP == nil {
-				continue
-			}
-			for _, p := range rule.HTTP.Paths {
-				if p.Backend.Service != nil {
-					svcName := p.Backend.Service.Name
-					port := resolveBackendPort(p.Backend.Service.Port)
-					backends[p.Path] = fmt.Sprintf("%s:%d", svcName, port)
-				}
-			}
-		}
-		data, err := json.Marshal(backends)
-		if err != nil {
-			return fmt.Errorf("marshal original backends: %w", err)
-		}
-		ingress.Annotations[annotationOriginalBackends] = string(data)
+	changed := !equality.Semantic.DeepEqual(before, ingress)
+
+	if err := r.Update(ctx, ingress); err != nil {
+		metrics.IngressPatchesTotal.WithLabelValues("failed").Inc()
+		return fmt.Errorf("update ingress: %w", err)
 	}
 
-	ingress.Annotations[annotationManagedBy] = "x402-operator"
+	log.FromContext(ctx).Info("ingress patched", "name", ingress.Name, "namespace", ingress.Namespace)
+	if changed {
+		metrics.IngressPatchesTotal.WithLabelValues("applied").Inc()
+		r.publish(cloudevents.IngressPatched, route, nil)
+	}
+	return nil
+}
+
+// previewPatch renders the changes patchIngress would make to the Ingress
+// without mutating it.
+func (r *X402RouteReconciler) previewPatch(route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress) (string, error) {
+	desired := ingress.DeepCopy()
+	if err := r.applyGatewayPatch(route, desired); err != nil {
+		return "", err
+	}
+	if err := checkPreserved(ingress, desired); err != nil {
+		return "", err
+	}
+	return renderPatchDiff(ingress, desired)
+}
+
+// consumeApproval removes the approval annotation after an approved patch is applied.
+func (r *X402RouteReconciler) consumeApproval(ctx context.Context, route *x402v1alpha1.X402Route) error {
+	if _, ok := route.Annotations[annotationApproved]; !ok {
+		return nil
+	}
+	base := route.DeepCopy()
+	delete(route.Annotations, annotationApproved)
+	// The patch response carries the stored status; keep the one being built.
+	status := route.Status
+	err := r.Patch(ctx, route, client.MergeFrom(base))
+	route.Status = status
+	return err
+}
+
+// isManaged reports whether the Ingress currently carries the operator's patch.
+func isManaged(ingress *networkingv1.Ingress) bool {
+	return ingress.Annotations[annotationManagedBy] == "x402-operator"
+}
+
+// applyGatewayPatch mutates the Ingress in memory so paid paths point at the
+// gateway. Only path backends, synthesized free paths and x402.io annotations
+// are touched; TLS, hosts and foreign annotations are left as-is.
+func (r *X402RouteReconciler) applyGatewayPatch(route *x402v1alpha1.X402Route, ingress *networkingv1.Ingress) error {
+	if ingress.Annotations == nil {
+		ingress.Annotations = make(map[string]string)
+	}
 
 	// Determine the gateway service name to use in the Ingress.
 	ingressNS := ingress.Namespace
@@ -349,17 +1073,51 @@ func (r *X402RouteReconciler) patchIngress(ctx context.Context, route *x402v1alp
 		gatewaySvcName = r.OperatorSvcName
 	}
 
-	// Collect paid paths from route rules.
-	paidPaths := r.collectPaidPaths(route)
+	// The gateway is serving again; undo a failover to the fallback backend.
+	if err := restoreFromFallback(ingress, gatewaySvcName); err != nil {
+		return err
+	}
+
+	// Drop free-path entries from a previous patch; they are recomputed below.
+	if err := removeSynthesizedPaths(ingress); err != nil {
+		return err
+	}
+
+	// Reconcile stored original backends with the current Ingress paths.
+	var stored map[string]string
+	if raw, ok := ingress.Annotations[annotationOriginalBackends]; ok {
+		if err := json.Unmarshal([]byte(raw), &stored); err != nil {
+			return fmt.Errorf("unmarshal original backends: %w", err)
+		}
+	}
+	originalBackends := r.reconcileOriginalBackends(ingress, stored)
+	data, err := json.Marshal(originalBackends)
+	if err != nil {
+		return fmt.Errorf("marshal original backends: %w", err)
+	}
+	ingress.Annotations[annotationOriginalBackends] = string(data)
+
+	ingress.Annotations[annotationManagedBy] = "x402-operator"
 
-	// Patch Ingress rules: redirect paid paths to gateway.
+	// Patch Ingress rules: redirect paid paths to gateway. Free sub-paths of a
+	// redirected path get their own entry pointing at the original backend.
+	// Rules for a virtual host only gate the Ingress rules serving it. Paths
+	// no longer gated, such as those of a disabled rule, get their original
+	// backend back.
+	var synthesized []synthesizedPath
 	for i := range ingress.Spec.Rules {
 		if ingress.Spec.Rules[i].HTTP == nil {
 			continue
 		}
+		host := ingress.Spec.Rules[i].Host
+		paidPaths := r.collectPaidPaths(route, host)
+		var freePaths []networkingv1.HTTPIngressPath
 		for j := range ingress.Spec.Rules[i].HTTP.Paths {
 			path := ingress.Spec.Rules[i].HTTP.Paths[j].Path
-			if r.pathMatchesPaidRoutes(path, paidPaths) {
+			if r.ingressPathGated(ingress.Spec.Rules[i].HTTP.Paths[j], paidPaths) {
+				if original, ok := parseServiceBackend(originalBackends[path]); ok {
+					freePaths = append(freePaths, freePathsFor(route, host, ingress.Spec.Rules[i].HTTP.Paths[j], original)...)
+				}
 				ingress.Spec.Rules[i].HTTP.Paths[j].Backend = networkingv1.IngressBackend{
 					Service: &networkingv1.IngressServiceBackend{
 						Name: gatewaySvcName,
@@ -368,22 +1126,39 @@ func (r *X402RouteReconciler) patchIngress(ctx context.Context, route *x402v1alp
 						},
 					},
 				}
+			} else if svc := ingress.Spec.Rules[i].HTTP.Paths[j].Backend.Service; svc != nil && r.isGatewayService(svc.Name) {
+				if original, ok := parseServiceBackend(originalBackends[path]); ok {
+					ingress.Spec.Rules[i].HTTP.Paths[j].Backend = original
+				}
 			}
 		}
+		for _, fp := range freePaths {
+			if hasIngressPath(ingress.Spec.Rules[i].HTTP.Paths, fp.Path, *fp.PathType) {
+				continue
+			}
+			ingress.Spec.Rules[i].HTTP.Paths = append(ingress.Spec.Rules[i].HTTP.Paths, fp)
+			synthesized = append(synthesized, synthesizedPath{
+				Host:     host,
+				Path:     fp.Path,
+				PathType: string(*fp.PathType),
+			})
+		}
 	}
-
-	if err := r.Update(ctx, ingress); err != nil {
-		return fmt.Errorf("update ingress: %w", err)
+	if len(synthesized) > 0 {
+		data, err := json.Marshal(synthesized)
+		if err != nil {
+			return fmt.Errorf("marshal synthesized paths: %w", err)
+		}
+		ingress.Annotations[annotationSynthesizedPaths] = string(data)
 	}
-
-	log.FromContext(ctx).Info("ingress patched", "name", ingress.Name, "namespace", ingress.Namespace)
 	return nil
 }
 
-// collectPaidPaths extracts all non-free paths from the route rules.
-func (r *X402RouteReconciler) collectPaidPaths(route *x402v1alpha1.X402Route) []string {
+// collectPaidPaths extracts the enabled, non-free paths of the route rules
+// that serve the Ingress host.
+func (r *X402RouteReconciler) collectPaidPaths(route *x402v1alpha1.X402Route, host string) []string {
 	var paths []string
-	for _, rule := range route.Spec.Routes {
+	for _, rule := range hostRules(route, host) {
 		if !rule.Free {
 			paths = append(paths, rule.Path)
 		}
@@ -391,6 +1166,56 @@ func (r *X402RouteReconciler) collectPaidPaths(route *x402v1alpha1.X402Route) []
 	return paths
 }
 
+// ingressPathGated checks if an Ingress path should be routed to the gateway,
+// honoring its pathType. An Exact path only serves its literal path, so it is
+// gated only when a paid rule matches that path. Prefix and
+// ImplementationSpecific paths use the prefix heuristics of pathMatchesPaidRoutes.
+func (r *X402RouteReconciler) ingressPathGated(p networkingv1.HTTPIngressPath, paidPaths []string) bool {
+	if pathTypeOf(p) != networkingv1.PathTypeExact {
+		return r.pathMatchesPaidRoutes(p.Path, paidPaths)
+	}
+	for _, paid := range paidPaths {
+		if ruleMatchesPath(paid, p.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleMatchesPath reports whether a route rule pattern matches a concrete
+// request path, mirroring the gateway's matching.
+func ruleMatchesPath(pattern, path string) bool {
+	prefix, subtree, literal := rulePrefix(pattern)
+	cleanPath := strings.TrimRight(path, "/")
+	if cleanPath == "" {
+		cleanPath = "/"
+	}
+	if literal {
+		return covers(prefix, subtree, cleanPath, false)
+	}
+
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return false
+	}
+	for i, pp := range patternParts {
+		if pp != "*" && pp != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pathTypeOf returns the pathType of an Ingress path, defaulting to
+// ImplementationSpecific when unset.
+func pathTypeOf(p networkingv1.HTTPIngressPath) networkingv1.PathType {
+	if p.PathType == nil {
+		return networkingv1.PathTypeImplementationSpecific
+	}
+	return *p.PathType
+}
+
 // pathMatchesPaidRoutes checks if an Ingress path should be routed to the gateway.
 func (r *X402RouteReconciler) pathMatchesPaidRoutes(ingressPath string, paidPaths []string) bool {
 	cleanIngress := strings.TrimSuffix(ingressPath, "(.*)")
@@ -444,10 +1269,20 @@ func (r *X402RouteReconciler) restoreIngress(ctx context.Context, route *x402v1a
 		}
 		return fmt.Errorf("get ingress for restore: %w", err)
 	}
+	if !referenceGranted(route, ingress) {
+		return nil
+	}
 
 	if ingress.Annotations == nil {
 		return nil
 	}
+	if err := restoreFromFallback(ingress, externalSvcName); err != nil {
+		return err
+	}
+	if err := removeSynthesizedPaths(ingress); err != nil {
+		return err
+	}
+
 	stored, ok := ingress.Annotations[annotationOriginalBackends]
 	if !ok {
 		return nil
@@ -463,30 +1298,21 @@ func (r *X402RouteReconciler) restoreIngress(ctx context.Context, route *x402v1a
 			continue
 		}
 		for j := range ingress.Spec.Rules[i].HTTP.Paths {
+			// Only restore paths the operator routed to the gateway.
+			backend := ingress.Spec.Rules[i].HTTP.Paths[j].Backend
+			if backend.Service == nil || !r.isGatewayService(backend.Service.Name) {
+				continue
+			}
 			path := ingress.Spec.Rules[i].HTTP.Paths[j].Path
-			if original, ok := originalBackends[path]; ok {
-				parts := strings.SplitN(original, ":", 2)
-				if len(parts) == 2 {
-					svcName := parts[0]
-					var port int32 = 80
-					if p, err := strconv.ParseInt(parts[1], 10, 32); err == nil {
-						port = int32(p)
-					}
-					ingress.Spec.Rules[i].HTTP.Paths[j].Backend = networkingv1.IngressBackend{
-						Service: &networkingv1.IngressServiceBackend{
-							Name: svcName,
-							Port: networkingv1.ServiceBackendPort{
-								Number: port,
-							},
-						},
-					}
-				}
+			if original, ok := parseServiceBackend(originalBackends[path]); ok {
+				ingress.Spec.Rules[i].HTTP.Paths[j].Backend = original
 			}
 		}
 	}
 
 	delete(ingress.Annotations, annotationOriginalBackends)
 	delete(ingress.Annotations, annotationManagedBy)
+	delete(ingress.Annotations, annotationOperatorVersion)
 
 	if err := r.Update(ctx, ingress); err != nil {
 		return fmt.Errorf("restore ingress: %w", err)
@@ -496,36 +1322,151 @@ func (r *X402RouteReconciler) restoreIngress(ctx context.Context, route *x402v1a
 	return nil
 }
 
-// cleanupResources handles finalizer cleanup.
+// reconcileOriginalBackends returns the "service:port" backend of every path in
+// the Ingress before it was routed to the gateway. Paths still pointing at the
+// gateway keep their stored entry; other paths record their live backend, so
+// paths added or re-pointed by the user are picked up and removed paths are
+// dropped instead of being restored later.
+func (r *X402RouteReconciler) reconcileOriginalBackends(ingress *networkingv1.Ingress, stored map[string]string) map[string]string {
+	backends := make(map[string]string)
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service == nil {
+				continue
+			}
+			if r.isGatewayService(p.Backend.Service.Name) {
+				if original, ok := stored[p.Path]; ok {
+					backends[p.Path] = original
+				}
+				continue
+			}
+			port := resolveBackendPort(p.Backend.Service.Port)
+			backends[p.Path] = fmt.Sprintf("%s:%d", p.Backend.Service.Name, port)
+		}
+	}
+	return backends
+}
+
+// parseServiceBackend converts a stored "service:port" entry back into an IngressBackend.
+func parseServiceBackend(stored string) (networkingv1.IngressBackend, bool) {
+	parts := strings.SplitN(stored, ":", 2)
+	if len(parts) != 2 {
+		return networkingv1.IngressBackend{}, false
+	}
+	var port int32 = 80
+	if p, err := strconv.ParseInt(parts[1], 10, 32); err == nil {
+		port = int32(p)
+	}
+	return networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: parts[0],
+			Port: networkingv1.ServiceBackendPort{
+				Number: port,
+			},
+		},
+	}, true
+}
+
+const (
+	// annotationForceCleanup set to "true" on an X402Route lets its deletion
+	// skip the Ingress restoration once cleanup failed forceCleanupAfter times.
+	annotationForceCleanup = "x402.io/force-cleanup"
+	forceCleanupAfter      = 3
+)
+
+// forceCleanup reports whether the route's Ingress restoration is skipped.
+func forceCleanup(route *x402v1alpha1.X402Route) bool {
+	return route.Annotations[annotationForceCleanup] == "true" &&
+		route.Status.Cleanup != nil && route.Status.Cleanup.Failures >= forceCleanupAfter
+}
+
+// cleanupResources handles finalizer cleanup. Completed steps are recorded in
+// status.cleanup and skipped when a failed cleanup is retried.
 func (r *X402RouteReconciler) cleanupResources(ctx context.Context, route *x402v1alpha1.X402Route) error {
 	logger := log.FromContext(ctx)
 	var errs []error
-
-	if err := r.restoreIngress(ctx, route); err != nil {
-		logger.Error(err, "failed to restore ingress during cleanup")
-		errs = append(errs, fmt.Errorf("restore ingress: %w", err))
+	if route.Status.Cleanup == nil {
+		route.Status.Cleanup = &x402v1alpha1.CleanupStatus{}
+	}
+	progress := route.Status.Cleanup
+
+	// Abandoned Ingresses stay routed to the gateway through the ExternalName
+	// Service, so both are left in place for the route that takes over.
+	abandon := route.Spec.DeletionPolicy == "abandon"
+	switch {
+	case progress.IngressRestored || progress.IngressRestoreSkipped:
+	case abandon:
+		logger.Info("finalizer: deletionPolicy is abandon, leaving ingress routed to the gateway")
+		progress.IngressRestored = true
+	case forceCleanup(route):
+		msg := fmt.Sprintf("Skipping restoration of Ingress %s after %d failed cleanup attempts; it may still route paid paths to the gateway", route.Spec.IngressRef.Name, progress.Failures)
+		logger.Info("finalizer: " + msg)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(route, nil, corev1.EventTypeWarning, "ForcedCleanup", "Cleanup", msg)
+		}
+		progress.IngressRestoreSkipped = true
+		metrics.IngressRestoresTotal.WithLabelValues("skipped").Inc()
+	default:
+		if err := r.restoreIngress(ctx, route); err != nil {
+			logger.Error(err, "failed to restore ingress during cleanup")
+			errs = append(errs, fmt.Errorf("restore ingress: %w", err))
+			metrics.IngressRestoresTotal.WithLabelValues("failed").Inc()
+		} else {
+			progress.IngressRestored = true
+			metrics.IngressRestoresTotal.WithLabelValues("restored").Inc()
+			r.publish(cloudevents.IngressRestored, route, nil)
+		}
 	}
 
 	// Remove from route store.
-	r.RouteStore.Delete(route.Namespace, route.Name)
-	metrics.ActiveRoutes.Set(float64(r.RouteStore.Count()))
-	metrics.RouteStoreUpdatesTotal.Inc()
+	if !progress.StoreRemoved {
+		r.RouteStore.Delete(route.Namespace, route.Name)
+		metrics.ActiveRoutes.Set(float64(r.RouteStore.Count()))
+		metrics.RouteStoreUpdatesTotal.Inc()
+		r.withdrawFleet(ctx, route)
+		progress.StoreRemoved = true
+	}
 
-	// Clean up ExternalName service if no other X402Routes use this namespace.
 	ingressNS := route.Spec.IngressRef.Namespace
 	if ingressNS == "" {
 		ingressNS = route.Namespace
 	}
-	if ingressNS != r.OperatorNamespace {
+
+	// The bypass Ingress goes with the route, whatever the deletion policy.
+	if !progress.BypassRemoved {
+		if err := r.deleteBypass(ctx, route, ingressNS); err != nil {
+			logger.Error(err, "failed to delete bypass ingress during cleanup")
+			errs = append(errs, fmt.Errorf("delete bypass ingress: %w", err))
+		} else {
+			progress.BypassRemoved = true
+		}
+	}
+
+	// Clean up ExternalName service if no other X402Routes use this namespace.
+	switch {
+	case progress.ServiceCleaned:
+	case ingressNS == r.OperatorNamespace || abandon:
+		progress.ServiceCleaned = true
+	default:
 		if err := r.cleanupExternalNameService(ctx, route, ingressNS); err != nil {
 			logger.Error(err, "failed to clean up ExternalName service")
 			errs = append(errs, fmt.Errorf("cleanup ExternalName service: %w", err))
+		} else {
+			progress.ServiceCleaned = true
 		}
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("cleanup errors: %v", errs)
+		err := fmt.Errorf("cleanup errors: %v", errs)
+		progress.Failures++
+		progress.LastError = err.Error()
+		metrics.CleanupFailuresTotal.Inc()
+		return err
 	}
+	progress.LastError = ""
 
 	logger.Info("finalizer: cleanup complete")
 	return nil
@@ -560,6 +1501,7 @@ func (r *X402RouteReconciler) cleanupExternalNameService(ctx context.Context, ro
 	if err := r.Delete(ctx, svc); err != nil && !apierrors.IsNotFound(err) {
 		return err
 	}
+	r.externalServices.remove(namespace)
 	return nil
 }
 
@@ -574,24 +1516,94 @@ func (r *X402RouteReconciler) setCondition(route *x402v1alpha1.X402Route, condTy
 	})
 }
 
-func (r *X402RouteReconciler) updateStatus(ctx context.Context, route *x402v1alpha1.X402Route, ingressPatched, ready bool, activeRoutes int) {
+// publish sends a lifecycle CloudEvent about route, with the error that
+// caused it, when a sink is configured.
+func (r *X402RouteReconciler) publish(eventType string, route *x402v1alpha1.X402Route, err error) {
+	if r.CloudEvents == nil {
+		return
+	}
+	ingressNS := route.Spec.IngressRef.Namespace
+	if ingressNS == "" {
+		ingressNS = route.Namespace
+	}
+	data := cloudevents.RouteData{
+		Namespace:  route.Namespace,
+		Name:       route.Name,
+		Generation: route.Generation,
+		Ingress:    ingressNS + "/" + route.Spec.IngressRef.Name,
+		Rules:      len(route.Spec.Routes),
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	r.CloudEvents.Publish(eventType, data)
+}
+
+// setStatus sets the summary fields of the route status, written when the
+// reconcile ends.
+func (r *X402RouteReconciler) setStatus(route *x402v1alpha1.X402Route, ingressPatched, ready bool, activeRoutes int) {
 	route.Status.IngressPatched = ingressPatched
 	route.Status.Ready = ready
 	route.Status.ActiveRoutes = activeRoutes
+}
 
-	if err := r.Status().Update(ctx, route); err != nil {
-		log.FromContext(ctx).Error(err, "failed to update X402Route status")
+// patchStatus stamps the route status with the reconcile time and error and
+// patches the changes made since base, so concurrent writers such as the
+// gateway failover do not conflict with the controller.
+func (r *X402RouteReconciler) patchStatus(ctx context.Context, route, base *x402v1alpha1.X402Route, reconcileErr error) {
+	now := metav1.Now()
+	route.Status.LastReconcileTime = &now
+	route.Status.LastError = ""
+	if reconcileErr != nil {
+		route.Status.LastError = reconcileErr.Error()
+	}
+	if err := r.Status().Patch(ctx, route, client.MergeFrom(base)); err != nil {
+		log.FromContext(ctx).Error(err, "failed to patch X402Route status")
 	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *X402RouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := r.indexFields(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&x402v1alpha1.X402Route{}).
-		Watches(&networkingv1.Ingress{}, handler.EnqueueRequestsFromMapFunc(r.ingressToX402Routes)).
+		Watches(&networkingv1.Ingress{}, handler.EnqueueRequestsFromMapFunc(r.ingressToX402Routes),
+			builder.WithPredicates(ingressChanged())).
+		Watches(&discoveryv1.EndpointSlice{}, handler.EnqueueRequestsFromMapFunc(r.endpointSliceToX402Routes),
+			builder.WithPredicates(endpointSliceChanged())).
+		Watches(&x402v1alpha1.X402PricePlan{}, handler.EnqueueRequestsFromMapFunc(r.pricePlanToX402Routes),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{RateLimiter: transientRateLimiter()}).
 		Complete(r)
 }
 
+// ingressChanged passes Ingress updates that can change a route: spec changes,
+// which bump the generation, and changes to x402.io annotations. Status
+// updates, such as load-balancer address flaps, are dropped.
+func ingressChanged() predicate.Predicate {
+	return predicate.Or(predicate.GenerationChangedPredicate{}, predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			return !maps.Equal(ownedAnnotations(e.ObjectOld), ownedAnnotations(e.ObjectNew))
+		},
+	})
+}
+
+// ownedAnnotations returns the x402.io annotations of obj.
+func ownedAnnotations(obj client.Object) map[string]string {
+	owned := make(map[string]string)
+	for key, value := range obj.GetAnnotations() {
+		if strings.HasPrefix(key, annotationPrefix) {
+			owned[key] = value
+		}
+	}
+	return owned
+}
+
 // ingressToX402Routes maps an Ingress event to the X402Route(s) that reference it.
 func (r *X402RouteReconciler) ingressToX402Routes(ctx context.Context, obj client.Object) []reconcile.Request {
 	ingress, ok := obj.(*networkingv1.Ingress)
@@ -599,7 +1611,9 @@ func (r *X402RouteReconciler) ingressToX402Routes(ctx context.Context, obj clien
 		return nil
 	}
 
-	if ingress.Annotations == nil || ingress.Annotations[annotationManagedBy] != "x402-operator" {
+	// Granting access to a new namespace must wake its routes before the
+	// Ingress is managed.
+	if !isManaged(ingress) && ingress.Annotations[annotationAllowedRouteNamespaces] == "" {
 		return nil
 	}
 
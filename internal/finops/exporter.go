@@ -0,0 +1,291 @@
+package finops
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+)
+
+// maxBufferedRecords bounds the records held while uploads fail. Beyond it the
+// oldest records are dropped and counted.
+const maxBufferedRecords = 100000
+
+// Config files read from the mounted Secret.
+const (
+	fileEndpoint        = "endpoint"
+	fileBucket          = "bucket"
+	fileRegion          = "region"
+	fileAccessKeyID     = "access-key-id"
+	fileSecretAccessKey = "secret-access-key"
+	filePrefix          = "prefix" // optional
+)
+
+// manifest is the completeness marker of one replica's files for a day.
+type manifest struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	Date          string   `json:"date"`
+	Replica       string   `json:"replica"`
+	Parts         []string `json:"parts"`
+	Records       int      `json:"records"`
+}
+
+// dayState tracks the parts uploaded for one day.
+type dayState struct {
+	parts   []string
+	records int
+}
+
+// Exporter batches settlement records and uploads them as CSV parts under
+//
+//	<prefix>/settlements/v<SchemaVersion>/date=YYYY-MM-DD/replica=<replica>/part-NNNNN.csv
+//
+// Once a day has passed, or when the exporter stops, it writes a _SUCCESS
+// manifest next to the parts: the replica will add no more files for that day.
+// Records are kept in memory until uploaded, so a crash loses the current batch.
+type Exporter struct {
+	store    *objectStore
+	prefix   string
+	replica  string
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[string][]Record // key: UTC date
+	days    map[string]*dayState
+}
+
+// NewExporter returns an exporter configured by the files of a mounted Secret
+// in dir. Replica names this gateway instance, usually the pod name.
+func NewExporter(dir, replica string, interval time.Duration) (*Exporter, error) {
+	read := func(name string, required bool) (string, error) {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) && !required {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", name, err)
+		}
+		return strings.TrimSpace(string(raw)), nil
+	}
+	cfg := make(map[string]string)
+	for _, name := range []string{fileEndpoint, fileBucket, fileRegion, fileAccessKeyID, fileSecretAccessKey, filePrefix} {
+		v, err := read(name, name != filePrefix)
+		if err != nil {
+			return nil, err
+		}
+		cfg[name] = v
+	}
+	if u, err := url.Parse(cfg[fileEndpoint]); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", cfg[fileEndpoint])
+	}
+	if replica == "" {
+		return nil, fmt.Errorf("a replica name is required")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid export interval %s", interval)
+	}
+	return &Exporter{
+		store: &objectStore{
+			endpoint:        strings.TrimSuffix(cfg[fileEndpoint], "/"),
+			bucket:          cfg[fileBucket],
+			region:          cfg[fileRegion],
+			accessKeyID:     cfg[fileAccessKeyID],
+			secretAccessKey: cfg[fileSecretAccessKey],
+			httpClient:      &http.Client{Timeout: 30 * time.Second},
+			now:             time.Now,
+		},
+		prefix:   strings.Trim(cfg[filePrefix], "/"),
+		replica:  replica,
+		interval: interval,
+		now:      time.Now,
+		pending:  make(map[string][]Record),
+		days:     make(map[string]*dayState),
+	}, nil
+}
+
+// Record queues a settlement for export. It never blocks on the upload. The
+// record goes to the UTC day on which it is queued, so a day that has been
+// closed never receives more records.
+func (e *Exporter) Record(rec Record) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	day := e.now().UTC().Format(time.DateOnly)
+	e.pending[day] = append(e.pending[day], rec)
+	if n := e.buffered(); n > maxBufferedRecords {
+		e.dropOldest(n - maxBufferedRecords)
+	}
+}
+
+// Start implements manager.Runnable. It uploads a part every interval and
+// flushes and closes all open days when ctx is cancelled.
+func (e *Exporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush(ctx, false)
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			e.flush(flushCtx, true)
+			cancel()
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every gateway
+// replica exports its own settlements.
+func (e *Exporter) NeedLeaderElection() bool {
+	return false
+}
+
+// flush uploads the pending records of each day as a new part and writes the
+// manifest of every day before today, or of all days when final. Every day the
+// exporter runs gets a manifest, even without records.
+func (e *Exporter) flush(ctx context.Context, final bool) {
+	today := e.now().UTC().Format(time.DateOnly)
+	e.mu.Lock()
+	batches := e.pending
+	e.pending = make(map[string][]Record)
+	if e.days[today] == nil {
+		e.days[today] = &dayState{}
+	}
+	e.mu.Unlock()
+
+	for _, day := range sortedKeys(batches) {
+		if err := e.uploadPart(ctx, day, batches[day]); err != nil {
+			slog.Error("settlement export failed, retrying next interval", "date", day, "records", len(batches[day]), "error", err)
+			metrics.SettlementExportRecordsTotal.WithLabelValues("retried").Add(float64(len(batches[day])))
+			e.requeue(day, batches[day])
+		}
+	}
+
+	e.mu.Lock()
+	var closing []string
+	for day := range e.days {
+		if (final || day < today) && len(e.pending[day]) == 0 {
+			closing = append(closing, day)
+		}
+	}
+	e.mu.Unlock()
+	sort.Strings(closing)
+	for _, day := range closing {
+		if err := e.closeDay(ctx, day); err != nil {
+			slog.Error("failed to write settlement export manifest", "date", day, "error", err)
+		}
+	}
+}
+
+// uploadPart writes records as the next part of day.
+func (e *Exporter) uploadPart(ctx context.Context, day string, records []Record) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(header)
+	for _, rec := range records {
+		w.Write(rec.row())
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	st := e.days[day]
+	if st == nil {
+		st = &dayState{}
+		e.days[day] = st
+	}
+	name := fmt.Sprintf("part-%05d.csv", len(st.parts)+1)
+	e.mu.Unlock()
+
+	if err := e.store.put(ctx, e.dayPath(day)+"/"+name, "text/csv", buf.Bytes()); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	st.parts = append(st.parts, name)
+	st.records += len(records)
+	e.mu.Unlock()
+	metrics.SettlementExportRecordsTotal.WithLabelValues("exported").Add(float64(len(records)))
+	return nil
+}
+
+// closeDay writes the _SUCCESS manifest of day and forgets it.
+func (e *Exporter) closeDay(ctx context.Context, day string) error {
+	e.mu.Lock()
+	st := e.days[day]
+	m := manifest{SchemaVersion: SchemaVersion, Date: day, Replica: e.replica, Parts: st.parts, Records: st.records}
+	e.mu.Unlock()
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := e.store.put(ctx, e.dayPath(day)+"/_SUCCESS", "application/json", body); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	delete(e.days, day)
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *Exporter) dayPath(day string) string {
+	return path.Join(e.prefix, "settlements", fmt.Sprintf("v%d", SchemaVersion), "date="+day, "replica="+e.replica)
+}
+
+// requeue puts records of a failed upload back in front of newer ones.
+func (e *Exporter) requeue(day string, records []Record) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending[day] = append(records, e.pending[day]...)
+	if n := e.buffered(); n > maxBufferedRecords {
+		e.dropOldest(n - maxBufferedRecords)
+	}
+}
+
+// buffered returns the number of pending records. e.mu must be held.
+func (e *Exporter) buffered() int {
+	n := 0
+	for _, records := range e.pending {
+		n += len(records)
+	}
+	return n
+}
+
+// dropOldest discards the n oldest pending records. e.mu must be held.
+func (e *Exporter) dropOldest(n int) {
+	metrics.SettlementExportRecordsTotal.WithLabelValues("dropped").Add(float64(n))
+	for _, day := range sortedKeys(e.pending) {
+		records := e.pending[day]
+		if len(records) > n {
+			e.pending[day] = records[n:]
+			return
+		}
+		n -= len(records)
+		delete(e.pending, day)
+	}
+}
+
+func sortedKeys(m map[string][]Record) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,148 @@
+package finops
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBucket is an S3-compatible endpoint that stores objects by path.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    bool
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case b.fail:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	case r.Method != http.MethodPut,
+		!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"),
+		r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body):
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	b.objects[r.URL.Path] = body
+}
+
+func (b *fakeBucket) get(key string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.objects[key]
+}
+
+func newTestExporter(t *testing.T, clock *time.Time) (*Exporter, *fakeBucket) {
+	t.Helper()
+	bucket := &fakeBucket{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(bucket)
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	for name, value := range map[string]string{
+		fileEndpoint:        srv.URL,
+		fileBucket:          "revenue",
+		fileRegion:          "auto",
+		fileAccessKeyID:     "AKID",
+		fileSecretAccessKey: "secret\n",
+		filePrefix:          "/x402/",
+	} {
+		os.WriteFile(filepath.Join(dir, name), []byte(value), 0o600)
+	}
+	e, err := NewExporter(dir, "gw-0", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return *clock }
+	return e, bucket
+}
+
+func TestExporterDailyParts(t *testing.T) {
+	clock := time.Date(2026, 10, 16, 23, 50, 0, 0, time.UTC)
+	e, bucket := newTestExporter(t, &clock)
+	ctx := context.Background()
+	dayPath := "/revenue/x402/settlements/v2/date=2026-10-16/replica=gw-0/"
+
+	e.Record(Record{Time: clock, Namespace: "default", Route: "my-api", Rule: "/api/*", Resource: "/api/data", Amount: "1000", Decimals: 6})
+	e.Record(Record{Time: clock, Namespace: "default", Route: "my-api", Rule: "/api/*", Resource: "/api/a,b", Variant: "discount", Amount: "2000", Decimals: 6})
+	e.flush(ctx, false)
+
+	rows, err := csv.NewReader(strings.NewReader(string(bucket.get(dayPath + "part-00001.csv")))).ReadAll()
+	if err != nil {
+		t.Fatalf("read part: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "time" || rows[2][4] != "/api/a,b" || rows[2][6] != "discount" || rows[2][13] != "2000" {
+		t.Fatalf("part rows = %q", rows)
+	}
+	if bucket.get(dayPath+"_SUCCESS") != nil {
+		t.Fatal("manifest written before the day ended")
+	}
+
+	// Uploads that fail are retried with the next part.
+	bucket.fail = true
+	e.Record(Record{Time: clock, Amount: "3000"})
+	e.flush(ctx, false)
+	bucket.fail = false
+
+	// After midnight the remaining records go out and the day is closed.
+	clock = clock.Add(20 * time.Minute)
+	e.flush(ctx, false)
+	var m manifest
+	if err := json.Unmarshal(bucket.get(dayPath+"_SUCCESS"), &m); err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if m.SchemaVersion != SchemaVersion || m.Records != 3 || len(m.Parts) != 2 || m.Parts[1] != "part-00002.csv" {
+		t.Errorf("manifest = %+v", m)
+	}
+
+	// Stopping closes the current day, even without records.
+	e.flush(ctx, true)
+	if bucket.get("/revenue/x402/settlements/v2/date=2026-10-17/replica=gw-0/_SUCCESS") == nil {
+		t.Error("no manifest for the current day on stop")
+	}
+}
+
+func TestExporterDropsOldestWhenFull(t *testing.T) {
+	clock := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	e, _ := newTestExporter(t, &clock)
+	for i := range maxBufferedRecords + 10 {
+		e.Record(Record{Amount: string(rune('0' + i%10))})
+	}
+	if n := e.buffered(); n != maxBufferedRecords {
+		t.Errorf("buffered = %d, want %d", n, maxBufferedRecords)
+	}
+}
+
+func TestNewExporterValidation(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewExporter(dir, "gw-0", time.Minute); err == nil {
+		t.Error("NewExporter() without configuration succeeded")
+	}
+	for name, value := range map[string]string{
+		fileEndpoint: "s3.amazonaws.com", fileBucket: "b", fileRegion: "r", fileAccessKeyID: "a", fileSecretAccessKey: "s",
+	} {
+		os.WriteFile(filepath.Join(dir, name), []byte(value), 0o600)
+	}
+	if _, err := NewExporter(dir, "gw-0", time.Minute); err == nil {
+		t.Error("NewExporter() with an endpoint without scheme succeeded")
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	if got, want := escapePath("/b/x/date=2026-10-16/part 1.csv"), "/b/x/date%3D2026-10-16/part%201.csv"; got != want {
+		t.Errorf("escapePath() = %q, want %q", got, want)
+	}
+}
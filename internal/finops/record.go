@@ -0,0 +1,45 @@
+// Package finops exports a record of every settled payment to object storage
+// as daily-partitioned CSV files, for revenue reporting outside the cluster.
+package finops
+
+import (
+	"strconv"
+	"time"
+)
+
+// SchemaVersion is the version of the exported CSV columns. It is part of the
+// object path, so a column change never mixes with files of the old layout.
+const SchemaVersion = 2
+
+// Record is one settled payment.
+type Record struct {
+	Time        time.Time
+	Namespace   string // of the X402Route
+	Route       string // X402Route name
+	Rule        string // path pattern of the matched rule
+	Resource    string // request path
+	Offer       string
+	Variant     string // price experiment variant; "" for rules without experiments
+	Scheme      string // "exact" or "upto"
+	Network     string
+	Asset       string
+	PayTo       string
+	Payer       string
+	Transaction string
+	Amount      string // atomic units of the asset
+	Decimals    int
+}
+
+// header is the CSV header row of schema version 2.
+var header = []string{
+	"time", "namespace", "route", "rule", "resource", "offer", "variant", "scheme",
+	"network", "asset", "pay_to", "payer", "transaction", "amount", "decimals",
+}
+
+// row returns the CSV columns of the record, in header order.
+func (r Record) row() []string {
+	return []string{
+		r.Time.UTC().Format(time.RFC3339Nano), r.Namespace, r.Route, r.Rule, r.Resource, r.Offer, r.Variant, r.Scheme,
+		r.Network, r.Asset, r.PayTo, r.Payer, r.Transaction, r.Amount, strconv.Itoa(r.Decimals),
+	}
+}
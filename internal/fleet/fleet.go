@@ -0,0 +1,194 @@
+// Package fleet shares the pricing of X402Routes between clusters through a
+// central HTTP endpoint, so mirrored services are gated at the same prices.
+// Each cluster publishes the pricing it compiled for a route and reads back
+// what the other clusters published; differences are reported as conflicts.
+//
+// The endpoint stores one entry per route and cluster:
+//
+//	PUT    /v1/routes/{namespace}/{name}/clusters/{cluster}  publish, returns all entries
+//	GET    /v1/routes/{namespace}/{name}                     returns all entries
+//	DELETE /v1/routes/{namespace}/{name}/clusters/{cluster}  withdraw
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Modes of a Client.
+const (
+	// ModeExport publishes this cluster's pricing and compares it to the others.
+	ModeExport = "export"
+	// ModePull only reads the other clusters' pricing and compares to it.
+	ModePull = "pull"
+)
+
+// RoutePricing is the pricing of one X402Route as compiled by one cluster.
+type RoutePricing struct {
+	Cluster   string        `json:"cluster"`
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Wallet    string        `json:"wallet"`
+	Network   string        `json:"network"`
+	Asset     string        `json:"asset,omitempty"`
+	Rules     []RulePricing `json:"rules"`
+	// Overrides are the cluster-specific prices applied on top of Rules.
+	// They are published for visibility and not compared.
+	Overrides []RulePricing `json:"overrides,omitempty"`
+	// Hash identifies Wallet, Network, Asset and Rules.
+	Hash string `json:"hash"`
+}
+
+// RulePricing is the pricing of one rule.
+type RulePricing struct {
+	Path   string            `json:"path"`
+	Host   string            `json:"host,omitempty"`
+	Price  string            `json:"price,omitempty"`
+	Free   bool              `json:"free,omitempty"`
+	Mode   string            `json:"mode,omitempty"`
+	Offers map[string]string `json:"offers,omitempty"` // key: offer name
+}
+
+// Seal sets p.Hash from the compared fields.
+func (p *RoutePricing) Seal() {
+	raw, err := json.Marshal(struct {
+		Wallet  string        `json:"wallet"`
+		Network string        `json:"network"`
+		Asset   string        `json:"asset"`
+		Rules   []RulePricing `json:"rules"`
+	}{p.Wallet, p.Network, p.Asset, p.Rules})
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(raw)
+	p.Hash = hex.EncodeToString(sum[:8])
+}
+
+// Conflicts returns the sorted names of the clusters whose pricing differs
+// from local.
+func Conflicts(local RoutePricing, entries []RoutePricing) []string {
+	var clusters []string
+	for _, e := range entries {
+		if e.Cluster != local.Cluster && e.Hash != local.Hash {
+			clusters = append(clusters, e.Cluster)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters
+}
+
+// Client talks to the central endpoint on behalf of one cluster.
+type Client struct {
+	URL     string
+	Cluster string
+	Mode    string // ModeExport (default) or ModePull
+	// TokenFile holds a bearer token sent with every request. It is re-read
+	// on each request, so a rotated token is picked up. Empty sends none.
+	TokenFile string
+
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the endpoint at baseURL.
+func NewClient(baseURL, cluster, mode, tokenFile string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid fleet URL %q", baseURL)
+	}
+	if cluster == "" {
+		return nil, fmt.Errorf("a cluster name is required")
+	}
+	switch mode {
+	case "":
+		mode = ModeExport
+	case ModeExport, ModePull:
+	default:
+		return nil, fmt.Errorf("invalid fleet mode %q, want %s or %s", mode, ModeExport, ModePull)
+	}
+	return &Client{
+		URL:        strings.TrimSuffix(baseURL, "/"),
+		Cluster:    cluster,
+		Mode:       mode,
+		TokenFile:  tokenFile,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Sync publishes p in export mode, then returns the pricing of every cluster
+// known to the endpoint for the route.
+func (c *Client) Sync(ctx context.Context, p RoutePricing) ([]RoutePricing, error) {
+	if c.Mode == ModePull {
+		return c.do(ctx, http.MethodGet, c.routePath(p.Namespace, p.Name), nil)
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPut, c.clusterPath(p.Namespace, p.Name), body)
+}
+
+// Withdraw removes this cluster's entry for a route. It is a no-op in pull mode.
+func (c *Client) Withdraw(ctx context.Context, namespace, name string) error {
+	if c.Mode == ModePull {
+		return nil
+	}
+	_, err := c.do(ctx, http.MethodDelete, c.clusterPath(namespace, name), nil)
+	return err
+}
+
+func (c *Client) routePath(namespace, name string) string {
+	return c.URL + "/v1/routes/" + url.PathEscape(namespace) + "/" + url.PathEscape(name)
+}
+
+func (c *Client) clusterPath(namespace, name string) string {
+	return c.routePath(namespace, name) + "/clusters/" + url.PathEscape(c.Cluster)
+}
+
+// do sends a request and decodes the entries in the response, if any.
+func (c *Client) do(ctx context.Context, method, target string, body []byte) ([]RoutePricing, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.TokenFile != "" {
+		token, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read fleet token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound && method != http.MethodPut:
+		return nil, nil
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("fleet endpoint returned status %d", resp.StatusCode)
+	}
+	if method == http.MethodDelete {
+		return nil, nil
+	}
+	var entries []RoutePricing
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decode fleet response: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,150 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newEndpoint returns an in-memory fleet endpoint and the Authorization
+// headers it received.
+func newEndpoint(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var auth []string
+	entries := make(map[string]map[string]RoutePricing) // key: ns/name, cluster
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/routes/"), "/")
+		route := parts[0] + "/" + parts[1]
+		switch r.Method {
+		case http.MethodPut:
+			var p RoutePricing
+			json.NewDecoder(r.Body).Decode(&p)
+			if entries[route] == nil {
+				entries[route] = make(map[string]RoutePricing)
+			}
+			entries[route][parts[3]] = p
+		case http.MethodDelete:
+			delete(entries[route], parts[3])
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if entries[route] == nil {
+			http.NotFound(w, r)
+			return
+		}
+		var all []RoutePricing
+		for _, p := range entries[route] {
+			all = append(all, p)
+		}
+		json.NewEncoder(w).Encode(all)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &auth
+}
+
+func pricing(cluster, price string) RoutePricing {
+	p := RoutePricing{
+		Cluster: cluster, Namespace: "default", Name: "my-api",
+		Wallet: "0xabc", Network: "base",
+		Rules: []RulePricing{{Path: "/api/**", Price: price, Mode: "all-pay"}},
+	}
+	p.Seal()
+	return p
+}
+
+func TestSealIgnoresClusterAndOverrides(t *testing.T) {
+	a, b := pricing("eu", "0.01"), pricing("us", "0.01")
+	b.Overrides = []RulePricing{{Path: "/api/**", Price: "0.02"}}
+	b.Seal()
+	if a.Hash != b.Hash {
+		t.Errorf("hashes differ across clusters: %s != %s", a.Hash, b.Hash)
+	}
+	if c := pricing("eu", "0.02"); c.Hash == a.Hash {
+		t.Error("hash did not change with the price")
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	local := pricing("eu", "0.01")
+	entries := []RoutePricing{local, pricing("us", "0.01"), pricing("ap", "0.02"), pricing("sa", "0.03")}
+	if got, want := Conflicts(local, entries), []string{"ap", "sa"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Conflicts() = %v, want %v", got, want)
+	}
+}
+
+func TestClientSync(t *testing.T) {
+	srv, auth := newEndpoint(t)
+	ctx := context.Background()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600)
+
+	eu, err := NewClient(srv.URL+"/", "eu", "", tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	us, _ := NewClient(srv.URL, "us", ModeExport, "")
+	mirror, _ := NewClient(srv.URL, "mirror", ModePull, "")
+
+	// Pulling a route no cluster published yet is not an error.
+	if entries, err := mirror.Sync(ctx, pricing("mirror", "0.01")); err != nil || len(entries) != 0 {
+		t.Fatalf("pull before export = %v, %v, want no entries", entries, err)
+	}
+	if _, err := eu.Sync(ctx, pricing("eu", "0.01")); err != nil {
+		t.Fatalf("eu Sync() error = %v", err)
+	}
+	entries, err := us.Sync(ctx, pricing("us", "0.02"))
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("us Sync() = %v, %v, want 2 entries", entries, err)
+	}
+	if got := Conflicts(pricing("us", "0.02"), entries); !reflect.DeepEqual(got, []string{"eu"}) {
+		t.Errorf("us conflicts = %v, want [eu]", got)
+	}
+
+	// Pull mode reads without publishing.
+	entries, err = mirror.Sync(ctx, pricing("mirror", "0.01"))
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("pull Sync() = %v, %v, want 2 entries", entries, err)
+	}
+	if err := mirror.Withdraw(ctx, "default", "my-api"); err != nil {
+		t.Fatalf("pull Withdraw() error = %v", err)
+	}
+
+	if err := us.Withdraw(ctx, "default", "my-api"); err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+	if entries, _ := mirror.Sync(ctx, pricing("mirror", "0.01")); len(entries) != 1 || entries[0].Cluster != "eu" {
+		t.Errorf("entries after withdraw = %v, want eu only", entries)
+	}
+	if (*auth)[1] != "Bearer s3cret" || (*auth)[0] != "" {
+		t.Errorf("authorization headers = %q", *auth)
+	}
+}
+
+func TestNewClientValidation(t *testing.T) {
+	tests := []struct {
+		name, url, cluster, mode string
+	}{
+		{name: "bad scheme", url: "ftp://fleet", cluster: "eu"},
+		{name: "no host", url: "https://", cluster: "eu"},
+		{name: "no cluster", url: "https://fleet.example.com"},
+		{name: "bad mode", url: "https://fleet.example.com", cluster: "eu", mode: "push"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.url, tt.cluster, tt.mode, ""); err == nil {
+				t.Error("NewClient() succeeded")
+			}
+		})
+	}
+}
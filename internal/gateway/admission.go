@@ -0,0 +1,149 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// A paid request goes through these steps, in order:
+//
+//	verify → admission checks → settle → proxy
+//
+// Metered rules proxy before they settle. Admission checks are the local
+// reasons to turn a request away. They run after the payment is verified, so an
+// invalid payment is answered 402 whatever the gateway's load. They run before
+// it is settled, so the gateway never takes money for a request it rejects.
+
+// admissionCheck decides locally whether a verified paid request can be
+// served. A check that holds a resource for the request returns the function
+// that frees it; it is called once the backend has answered, or when a later
+// check rejects the request.
+type admissionCheck func(r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule, path string) (release func(), err error)
+
+// admissionError is the answer to a rejected request.
+type admissionError struct {
+	status     int
+	reason     string // status label of x402_requests_total
+	retryAfter string // Retry-After header; empty for none
+	header     http.Header
+	body       any // answered as JSON; nil answers err as text
+	err        error
+}
+
+func (e *admissionError) Error() string { return e.err.Error() }
+
+func (e *admissionError) Unwrap() error { return e.err }
+
+// admissionChecks returns the handler's checks in the order they run.
+func (h *Handler) admissionChecks() []admissionCheck {
+	return []admissionCheck{checkBackend, h.admitJob, h.acquireBackendSlot}
+}
+
+// admit runs the admission checks. When one rejects the request, it answers
+// it, frees what earlier checks took and returns false. Otherwise it returns
+// the function that frees everything the checks took.
+func (h *Handler) admit(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule, path string) (func(), bool) {
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, check := range h.admissionChecks() {
+		free, err := check(r, route, rule, path)
+		if err != nil {
+			release()
+			aerr := &admissionError{status: http.StatusServiceUnavailable, reason: "admission_rejected", err: err}
+			errors.As(err, &aerr)
+			slog.Warn("paid request not admitted", "path", path, "route", route.Name, "reason", aerr.reason, "error", err)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, aerr.reason).Inc()
+			if aerr.retryAfter != "" {
+				w.Header().Set("Retry-After", aerr.retryAfter)
+			}
+			maps.Copy(w.Header(), aerr.header)
+			if aerr.body != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(aerr.status)
+				json.NewEncoder(w).Encode(aerr.body)
+				return nil, false
+			}
+			http.Error(w, err.Error(), aerr.status)
+			return nil, false
+		}
+		if free != nil {
+			releases = append(releases, free)
+		}
+	}
+	return release, true
+}
+
+// checkBackend rejects requests the route has no backend for.
+func checkBackend(_ *http.Request, route *routestore.CompiledRoute, _ *routestore.CompiledRule, path string) (func(), error) {
+	if findBackend(route.Backends, path) == nil {
+		return nil, &admissionError{status: http.StatusBadGateway, reason: "no_backend", err: errors.New("no backend configured")}
+	}
+	return nil, nil
+}
+
+// admitJob admits the background job of an async rule.
+func (h *Handler) admitJob(r *http.Request, _ *routestore.CompiledRoute, rule *routestore.CompiledRule, _ string) (func(), error) {
+	if rule.Async == nil {
+		return nil, nil
+	}
+	if err := h.jobs.admit(r); err != nil {
+		status := http.StatusServiceUnavailable
+		switch {
+		case errors.Is(err, errJobBodyTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case !errors.Is(err, errTooManyJobs):
+			status = http.StatusBadRequest
+		}
+		return nil, &admissionError{status: status, reason: "async_rejected", err: err}
+	}
+	return nil, nil
+}
+
+// acquireBackendSlot takes one of the route's maxConcurrent backend slots.
+// Routes with a waiting room admit requests holding a queue token first, and
+// answer requests they cannot admit 429 with a new token.
+func (h *Handler) acquireBackendSlot(r *http.Request, route *routestore.CompiledRoute, _ *routestore.CompiledRule, _ string) (func(), error) {
+	var release func()
+	var ok bool
+	if route.WaitingRoom != nil && h.waitingRoom.redeem(r.Context(), r.Header.Get(headerQueueToken), route) {
+		release, ok = h.concurrency.acquirePriority(r.Context(), route, route.WaitingRoom.PriorityWait)
+	} else {
+		release, ok = h.concurrency.acquire(r.Context(), route)
+	}
+	if ok && route.WaitingRoom != nil {
+		acquired, free := time.Now(), release
+		release = func() {
+			free()
+			h.waitingRoom.observe(r.Context(), route, time.Since(acquired))
+		}
+	}
+	if ok {
+		return release, nil
+	}
+	err := errors.New("backend at capacity, retry later")
+	if route.WaitingRoom != nil {
+		if queued, ok := h.waitingRoom.issue(r.Context(), route); ok {
+			return nil, &admissionError{
+				status:     http.StatusTooManyRequests,
+				reason:     "queued",
+				retryAfter: strconv.Itoa(queued.RetryAfter),
+				header:     http.Header{headerQueueToken: {queued.QueueToken}},
+				body:       queued,
+				err:        err,
+			}
+		}
+	}
+	return nil, &admissionError{status: http.StatusServiceUnavailable, reason: "concurrency_limited", retryAfter: retryAfter(route), err: err}
+}
@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// TestPaidRequestOrdering checks the invariants of verify → admission checks →
+// settle → proxy: nothing is settled for a request the gateway rejects, and
+// invalid payments are answered 402 before any local check.
+func TestPaidRequestOrdering(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record("backend")
+		w.Header().Set("X-Token-Count", "10")
+		io.WriteString(w, "ok")
+	}))
+	defer backendSrv.Close()
+
+	tests := []struct {
+		name       string
+		valid      bool
+		settles    bool
+		metered    bool
+		async      bool
+		noBackend  bool
+		atCapacity bool
+		jobsFull   bool
+		wantStatus int
+		wantCalls  string
+	}{
+		{name: "admitted", valid: true, settles: true, wantStatus: http.StatusOK, wantCalls: "verify settle backend"},
+		{name: "invalid payment at capacity", atCapacity: true, wantStatus: http.StatusPaymentRequired, wantCalls: "verify"},
+		{name: "at capacity", valid: true, settles: true, atCapacity: true, wantStatus: http.StatusServiceUnavailable, wantCalls: "verify"},
+		{name: "no backend", valid: true, settles: true, noBackend: true, wantStatus: http.StatusBadGateway, wantCalls: "verify"},
+		{name: "async jobs full", valid: true, settles: true, async: true, jobsFull: true, wantStatus: http.StatusServiceUnavailable, wantCalls: "verify"},
+		{name: "settlement failed", valid: true, wantStatus: http.StatusPaymentRequired, wantCalls: "verify settle"},
+		{name: "metered", valid: true, settles: true, metered: true, wantStatus: http.StatusOK, wantCalls: "verify backend settle"},
+		{name: "metered at capacity", valid: true, settles: true, metered: true, atCapacity: true, wantStatus: http.StatusServiceUnavailable, wantCalls: "verify"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/supported"):
+					io.WriteString(w, `{"kinds":[{"x402Version":2,"scheme":"upto","network":"eip155:84532"}]}`)
+				case strings.HasSuffix(r.URL.Path, "/verify"):
+					record("verify")
+					if !tt.valid {
+						io.WriteString(w, `{"isValid":false,"invalidReason":"invalid_signature"}`)
+						return
+					}
+					io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+				default:
+					record("settle")
+					if !tt.settles {
+						io.WriteString(w, `{"success":false,"errorReason":"insufficient_funds"}`)
+						return
+					}
+					io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+				}
+			}))
+			defer facilitator.Close()
+
+			rule := routestore.CompiledRule{Path: "/v1/*", Price: "0.01", Mode: "all-pay"}
+			if tt.metered {
+				rule.Metering = &routestore.CompiledMetering{UnitHeader: "X-Token-Count", UnitPrice: big.NewRat(1, 1000), MaxResponseBytes: 4096}
+			}
+			if tt.async {
+				rule.Async = &routestore.CompiledAsync{Timeout: time.Minute, ResultTTL: time.Minute, MaxResultBytes: 4096}
+			}
+			route := &routestore.CompiledRoute{
+				Name:           "gpu",
+				Namespace:      "default",
+				Wallet:         "0xTestWallet",
+				Network:        "base-sepolia",
+				FacilitatorURL: facilitator.URL,
+				Rules:          []routestore.CompiledRule{rule},
+				Backends:       []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+				MaxConcurrent:  1,
+			}
+			if tt.noBackend {
+				route.Backends = nil
+			}
+			store := routestore.New()
+			store.Set("default", "gpu", route)
+			h := NewHandler(store)
+			if tt.atCapacity {
+				if _, ok := h.concurrency.acquire(context.Background(), route); !ok {
+					t.Fatal("could not take the backend slot")
+				}
+			}
+			if tt.jobsFull {
+				h.jobs.running = maxJobs
+			}
+			// The 402 response discovers the facilitator's schemes.
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/run", nil))
+
+			mu.Lock()
+			calls = nil
+			mu.Unlock()
+			req := httptest.NewRequest("POST", "/v1/run", nil)
+			req.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			mu.Lock()
+			got := strings.Join(calls, " ")
+			mu.Unlock()
+			if got != tt.wantCalls {
+				t.Errorf("calls = %q, want %q", got, tt.wantCalls)
+			}
+
+			// Whatever the outcome, the request gave its backend slot back.
+			if !tt.atCapacity {
+				if _, ok := h.concurrency.acquire(context.Background(), route); !ok {
+					t.Error("backend slot not released")
+				}
+			}
+		})
+	}
+}
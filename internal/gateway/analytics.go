@@ -0,0 +1,398 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/finops"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/privacy"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+// AnalyticsPath serves the paid traffic summary of each route.
+const AnalyticsPath = "/x402/analytics"
+
+const (
+	// analyticsBucketWidth is the resolution of the sliding windows.
+	analyticsBucketWidth = time.Minute
+	// analyticsRetention is the longest window that can be queried.
+	analyticsRetention = 24 * time.Hour
+	// analyticsFlushInterval is how often counts are written to storage.
+	analyticsFlushInterval = 5 * time.Second
+	// analyticsWriteTimeout bounds the storage writes of one flush; counts
+	// that could not be written are dropped.
+	analyticsWriteTimeout = 5 * time.Second
+	// analyticsRevenueScale is the unit of stored revenue: 1e-9 tokens.
+	analyticsRevenueScale = 1e9
+	// analyticsPrefix starts the storage keys of analytics.
+	analyticsPrefix = "analytics:"
+
+	defaultAnalyticsWindow = time.Hour
+	defaultAnalyticsTop    = 10
+	maxAnalyticsTop        = 100
+)
+
+// Kinds of analytics counters.
+const (
+	analyticsIssued       = "issued"        // 402 responses, by rule pattern
+	analyticsPaid         = "paid"          // settlements, by rule pattern
+	analyticsRevenue      = "revenue"       // revenue, by rule pattern
+	analyticsPayerPaid    = "payer-paid"    // settlements, by payer
+	analyticsPayerRevenue = "payer-revenue" // revenue, by payer
+)
+
+// Analytics aggregates the 402 responses and settlements of every route per
+// minute, for the last 24 hours, in counters of a storage.Interface. With an
+// in-memory store each gateway replica only sees its own traffic; with a
+// shared store every replica reports the traffic of all of them. Requests
+// only count in memory; the counts are flushed to storage in the background.
+type Analytics struct {
+	tokenFile string
+	store     storage.Interface
+	now       func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]int64 // counts not yet flushed, by storage key
+}
+
+// analyticsKey identifies the counters of a route (namespace/name) and a rule
+// pattern or payer.
+type analyticsKey struct {
+	route, item string
+}
+
+type analyticsTally struct {
+	payments int
+	revenue  float64 // in tokens
+}
+
+// NewAnalytics returns an aggregator keeping its counters in store, whose
+// endpoint requires the bearer token in tokenFile. The file is re-read on
+// every request, so the token can be rotated without a restart.
+func NewAnalytics(tokenFile string, store storage.Interface) *Analytics {
+	return &Analytics{tokenFile: tokenFile, store: store, now: time.Now, pending: map[string]int64{}}
+}
+
+// issued counts a 402 response asking for payment of rule.
+func (a *Analytics) issued(route *routestore.CompiledRoute, rule *routestore.CompiledRule) {
+	if a == nil {
+		return
+	}
+	a.add(analyticsIssued, analyticsKey{route.Namespace + "/" + route.Name, rule.Path}, 1)
+}
+
+// Record implements SettlementSink.
+func (a *Analytics) Record(rec finops.Record) {
+	tokens, ok := tokenAmount(rec.Amount, rec.Decimals)
+	if !ok {
+		return
+	}
+	revenue := int64(math.Round(tokens * analyticsRevenueScale))
+	route := rec.Namespace + "/" + rec.Route
+	path := analyticsKey{route, rec.Rule}
+	payer := analyticsKey{route, privacy.Address(rec.Payer)}
+	a.add(analyticsPaid, path, 1)
+	a.add(analyticsRevenue, path, revenue)
+	a.add(analyticsPayerPaid, payer, 1)
+	a.add(analyticsPayerRevenue, payer, revenue)
+}
+
+// add adds delta to a counter of the current minute, in memory until the
+// next flush.
+func (a *Analytics) add(kind string, key analyticsKey, delta int64) {
+	bucket := a.now().Truncate(analyticsBucketWidth).Unix()
+	storeKey := fmt.Sprintf("%s%d:%s:%s:%s", analyticsPrefix, bucket, kind, key.route, key.item)
+	a.mu.Lock()
+	a.pending[storeKey] += delta
+	a.mu.Unlock()
+}
+
+// run flushes the counts every analyticsFlushInterval until ctx is canceled,
+// and once more then.
+func (a *Analytics) run(ctx context.Context) {
+	ticker := time.NewTicker(analyticsFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.flush(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			a.flush(ctx)
+		}
+	}
+}
+
+// flush adds the pending counts to the counters in storage. The counters
+// expire once they are older than the retention.
+func (a *Analytics) flush(ctx context.Context) {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = make(map[string]int64, len(pending))
+	a.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyticsWriteTimeout)
+	defer cancel()
+	failed := 0
+	var lastErr error
+	for storeKey, delta := range pending {
+		if _, err := a.store.Incr(ctx, storeKey, delta, analyticsRetention+analyticsBucketWidth); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		slog.Warn("failed to record analytics", "counters", failed, "error", lastErr)
+	}
+}
+
+// counters reads the counters of the buckets that overlap the window
+// starting at since, summed by kind and key. The counts of this replica are
+// flushed first, so they are included.
+func (a *Analytics) counters(ctx context.Context, since time.Time) (map[string]map[analyticsKey]int64, error) {
+	a.flush(ctx)
+	values, err := a.store.Scan(ctx, analyticsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sums := map[string]map[analyticsKey]int64{}
+	for storeKey, value := range values {
+		// analytics:<bucket>:<kind>:<namespace/name>:<item>; items may
+		// hold colons.
+		parts := strings.SplitN(strings.TrimPrefix(storeKey, analyticsPrefix), ":", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		bucket, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || time.Unix(bucket, 0).Add(analyticsBucketWidth).Before(since) {
+			continue
+		}
+		n, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		kind := parts[1]
+		if sums[kind] == nil {
+			sums[kind] = map[analyticsKey]int64{}
+		}
+		sums[kind][analyticsKey{parts[2], parts[3]}] += n
+	}
+	return sums, nil
+}
+
+// tallies combines the payment and revenue counters of one kind of item.
+func tallies(payments, revenue map[analyticsKey]int64) map[analyticsKey]*analyticsTally {
+	t := map[analyticsKey]*analyticsTally{}
+	for key, n := range payments {
+		t[key] = &analyticsTally{payments: int(n), revenue: float64(revenue[key]) / analyticsRevenueScale}
+	}
+	return t
+}
+
+// analyticsReport is the body of an analytics response.
+type analyticsReport struct {
+	Window string                 `json:"window"`
+	Since  time.Time              `json:"since"`
+	Routes []routeAnalyticsReport `json:"routes"`
+}
+
+type routeAnalyticsReport struct {
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	PaymentRequired int    `json:"paymentRequired"`
+	Paid            int    `json:"paid"`
+	// ConversionRate is paid over paymentRequired, or 0 without 402s.
+	ConversionRate float64               `json:"conversionRate"`
+	Revenue        float64               `json:"revenue"`
+	TopPayers      []payerAnalyticsEntry `json:"topPayers"`
+	TopPaths       []pathAnalyticsEntry  `json:"topPaths"`
+}
+
+type payerAnalyticsEntry struct {
+	Payer    string  `json:"payer"`
+	Payments int     `json:"payments"`
+	Revenue  float64 `json:"revenue"`
+}
+
+type pathAnalyticsEntry struct {
+	Path            string  `json:"path"`
+	PaymentRequired int     `json:"paymentRequired"`
+	Paid            int     `json:"paid"`
+	ConversionRate  float64 `json:"conversionRate"`
+	Revenue         float64 `json:"revenue"`
+}
+
+// report sums the buckets of the last window for the route named
+// "namespace/name", or for all routes when only is empty, keeping the top
+// payers and paths by revenue.
+func (a *Analytics) report(ctx context.Context, window time.Duration, top int, only string) (analyticsReport, error) {
+	since := a.now().Add(-window)
+	sums, err := a.counters(ctx, since)
+	if err != nil {
+		return analyticsReport{}, err
+	}
+	issued := sums[analyticsIssued]
+	paths := tallies(sums[analyticsPaid], sums[analyticsRevenue])
+	payers := tallies(sums[analyticsPayerPaid], sums[analyticsPayerRevenue])
+
+	routes := map[string]*routeAnalyticsReport{}
+	routeReport := func(key string) *routeAnalyticsReport {
+		r := routes[key]
+		if r == nil {
+			namespace, name, _ := strings.Cut(key, "/")
+			r = &routeAnalyticsReport{Namespace: namespace, Name: name, TopPayers: []payerAnalyticsEntry{}, TopPaths: []pathAnalyticsEntry{}}
+			routes[key] = r
+		}
+		return r
+	}
+	pathEntries := map[analyticsKey]*pathAnalyticsEntry{}
+	pathEntry := func(key analyticsKey) *pathAnalyticsEntry {
+		e := pathEntries[key]
+		if e == nil {
+			e = &pathAnalyticsEntry{Path: key.item}
+			pathEntries[key] = e
+		}
+		return e
+	}
+	for key, n := range issued {
+		routeReport(key.route).PaymentRequired += int(n)
+		pathEntry(key).PaymentRequired += int(n)
+	}
+	for key, t := range paths {
+		r := routeReport(key.route)
+		r.Paid += t.payments
+		r.Revenue += t.revenue
+		e := pathEntry(key)
+		e.Paid, e.Revenue = t.payments, t.revenue
+	}
+	for key, e := range pathEntries {
+		e.ConversionRate = conversionRate(e.Paid, e.PaymentRequired)
+		r := routes[key.route]
+		r.TopPaths = append(r.TopPaths, *e)
+	}
+	for key, t := range payers {
+		r := routeReport(key.route)
+		r.TopPayers = append(r.TopPayers, payerAnalyticsEntry{Payer: key.item, Payments: t.payments, Revenue: t.revenue})
+	}
+
+	report := analyticsReport{Window: window.String(), Since: since.UTC(), Routes: []routeAnalyticsReport{}}
+	for key, r := range routes {
+		if only != "" && key != only {
+			continue
+		}
+		r.ConversionRate = conversionRate(r.Paid, r.PaymentRequired)
+		slices.SortFunc(r.TopPaths, func(x, y pathAnalyticsEntry) int {
+			return byRevenue(x.Revenue, y.Revenue, x.Path, y.Path)
+		})
+		slices.SortFunc(r.TopPayers, func(x, y payerAnalyticsEntry) int {
+			return byRevenue(x.Revenue, y.Revenue, x.Payer, y.Payer)
+		})
+		r.TopPaths = r.TopPaths[:min(top, len(r.TopPaths))]
+		r.TopPayers = r.TopPayers[:min(top, len(r.TopPayers))]
+		report.Routes = append(report.Routes, *r)
+	}
+	slices.SortFunc(report.Routes, func(x, y routeAnalyticsReport) int {
+		return strings.Compare(x.Namespace+"/"+x.Name, y.Namespace+"/"+y.Name)
+	})
+	return report, nil
+}
+
+func conversionRate(paid, issued int) float64 {
+	if issued == 0 {
+		return 0
+	}
+	return float64(paid) / float64(issued)
+}
+
+// byRevenue orders by descending revenue, then by name.
+func byRevenue(x, y float64, xName, yName string) int {
+	switch {
+	case x > y:
+		return -1
+	case x < y:
+		return 1
+	}
+	return strings.Compare(xName, yName)
+}
+
+// ServeHTTP answers with the activity of every route over the last window
+// (?window=, a duration up to 24h, default 1h), with the top payers and
+// paths by revenue (?top=, default 10). The route query parameter
+// (namespace/name) limits the answer to one route.
+func (a *Analytics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := a.authorize(r); err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="x402-analytics"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	window := defaultAnalyticsWindow
+	if s := query.Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 || d > analyticsRetention {
+			http.Error(w, fmt.Sprintf("window must be a duration up to %s", analyticsRetention), http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	top := defaultAnalyticsTop
+	if s := query.Get("top"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxAnalyticsTop {
+			http.Error(w, fmt.Sprintf("top must be between 1 and %d", maxAnalyticsTop), http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	report, err := a.report(r.Context(), window, top, query.Get("route"))
+	if err != nil {
+		slog.Error("failed to read analytics", "error", err)
+		http.Error(w, "analytics unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(report)
+}
+
+// authorize checks the request's bearer token against the token file.
+func (a *Analytics) authorize(r *http.Request) error {
+	token, err := os.ReadFile(a.tokenFile)
+	if err != nil {
+		slog.Error("failed to read analytics token", "file", a.tokenFile, "error", err)
+		return fmt.Errorf("analytics token unavailable")
+	}
+	want := strings.TrimSpace(string(token))
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return fmt.Errorf("missing or invalid bearer token")
+	}
+	return nil
+}
+
+// serveAnalytics answers GET /x402/analytics, or 404 when analytics are not
+// enabled.
+func (h *Handler) serveAnalytics(w http.ResponseWriter, r *http.Request) {
+	if h.analytics == nil {
+		http.NotFound(w, r)
+		return
+	}
+	h.analytics.ServeHTTP(w, r)
+}
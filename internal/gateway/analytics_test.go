@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/finops"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+func TestAnalytics(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("secret\n"), 0o600)
+	store := storage.NewMemory()
+	a := NewAnalytics(tokenFile, store)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 12, 0, 30, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	route := &routestore.CompiledRoute{Namespace: "default", Name: "api"}
+	users := &routestore.CompiledRule{Path: "/users/*"}
+	reports := &routestore.CompiledRule{Path: "/reports"}
+	settle := func(rule, payer, amount string) {
+		a.Record(finops.Record{Namespace: "default", Route: "api", Rule: rule, Payer: payer, Amount: amount, Decimals: 6})
+	}
+
+	// Two hours ago: outside the default window.
+	now = now.Add(-2 * time.Hour)
+	a.issued(route, users)
+	settle("/users/*", "0xOld", "9000000")
+	now = now.Add(2 * time.Hour)
+
+	for range 4 {
+		a.issued(route, users)
+	}
+	a.issued(route, reports)
+	a.issued(route, reports)
+	settle("/users/*", "0xA", "10000")
+	settle("/users/*", "0xB", "20000")
+	settle("/reports", "0xA", "500000")
+	a.issued(&routestore.CompiledRoute{Namespace: "other", Name: "web"}, users)
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, AnalyticsPath+query, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, tt := range []struct {
+		name, query, token string
+		want               int
+	}{
+		{name: "no token", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "guess", want: http.StatusUnauthorized},
+		{name: "bad window", query: "?window=48h", token: "secret", want: http.StatusBadRequest},
+		{name: "bad top", query: "?top=0", token: "secret", want: http.StatusBadRequest},
+	} {
+		if w := get(tt.query, tt.token); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	w := get("?route=default/api&top=1", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var report analyticsReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	want := []routeAnalyticsReport{{
+		Namespace:       "default",
+		Name:            "api",
+		PaymentRequired: 6,
+		Paid:            3,
+		ConversionRate:  0.5,
+		Revenue:         0.53,
+		TopPayers:       []payerAnalyticsEntry{{Payer: "0xA", Payments: 2, Revenue: 0.51}},
+		TopPaths:        []pathAnalyticsEntry{{Path: "/reports", PaymentRequired: 2, Paid: 1, ConversionRate: 0.5, Revenue: 0.5}},
+	}}
+	if !reflect.DeepEqual(report.Routes, want) {
+		t.Errorf("routes = %+v, want %+v", report.Routes, want)
+	}
+
+	// A longer window includes the old activity; no route lists every route.
+	json.Unmarshal(get("?window=3h", "secret").Body.Bytes(), &report)
+	if len(report.Routes) != 2 || report.Routes[0].PaymentRequired != 7 || report.Routes[0].Paid != 4 {
+		t.Errorf("3h window routes = %+v, want default/api with 7 402s and 4 payments, and other/web", report.Routes)
+	}
+
+	// Counters expire once they are older than the retention.
+	for key := range mustScan(t, store, analyticsPrefix) {
+		if ttl, err := store.TTL(ctx, key); err != nil || ttl <= analyticsRetention {
+			t.Errorf("TTL(%s) = %v, %v, want just over the retention", key, ttl, err)
+		}
+	}
+	now = now.Add(25 * time.Hour)
+	json.Unmarshal(get("?window=24h", "secret").Body.Bytes(), &report)
+	if len(report.Routes) != 0 {
+		t.Errorf("routes after a day = %+v, want none", report.Routes)
+	}
+}
+
+// TestAnalyticsSharedStore checks that replicas sharing a store report each
+// other's traffic.
+func TestAnalyticsSharedStore(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	replicas := []*Analytics{NewAnalytics("", store), NewAnalytics("", store)}
+	route := &routestore.CompiledRoute{Namespace: "default", Name: "api"}
+	for _, a := range replicas {
+		a.issued(route, &routestore.CompiledRule{Path: "/users/*"})
+		a.Record(finops.Record{Namespace: "default", Route: "api", Rule: "/users/*", Payer: "0xA", Amount: "10000", Decimals: 6})
+	}
+	// Requests only count in memory; storage is written by the flush.
+	if values := mustScan(t, store, analyticsPrefix); len(values) != 0 {
+		t.Errorf("stored counters before a flush = %v, want none", values)
+	}
+	// The other replica flushes in the background when it stops.
+	stopped, stop := context.WithCancel(ctx)
+	stop()
+	replicas[1].run(stopped)
+	report, err := replicas[0].report(ctx, time.Hour, 10, "")
+	if err != nil {
+		t.Fatalf("report() error = %v", err)
+	}
+	if len(report.Routes) != 1 || report.Routes[0].PaymentRequired != 2 || report.Routes[0].Paid != 2 || report.Routes[0].Revenue != 0.02 {
+		t.Errorf("routes = %+v, want 2 402s and 2 payments of 0.01", report.Routes)
+	}
+}
+
+func mustScan(t *testing.T, store storage.Interface, prefix string) map[string][]byte {
+	t.Helper()
+	values, err := store.Scan(context.Background(), prefix)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	return values
+}
+
+func TestServeAnalyticsDisabled(t *testing.T) {
+	h := NewHandler(routestore.New())
+	w := httptest.NewRecorder()
+	h.serveAnalytics(w, httptest.NewRequest(http.MethodGet, AnalyticsPath, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 without analytics", w.Code)
+	}
+}
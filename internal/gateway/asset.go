@@ -0,0 +1,24 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/tokenmeta"
+)
+
+// tokenMetadata resolves assets outside the registry; nil until
+// EnableTokenMetadata is called.
+var tokenMetadata *tokenmeta.Resolver
+
+// lookupAsset returns the metadata of an asset override.
+func lookupAsset(ctx context.Context, chainID, asset string) (assetInfo, error) {
+	if tokenMetadata == nil {
+		return assetInfo{}, fmt.Errorf("asset %s on %s is not in the registry and no chain RPC endpoints are configured", asset, chainID)
+	}
+	meta, err := tokenMetadata.Lookup(ctx, chainID, asset)
+	if err != nil {
+		return assetInfo{}, err
+	}
+	return assetInfo{Name: meta.Name, Version: meta.Version, Decimals: meta.Decimals}, nil
+}
@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// endpointCooldown is how long an endpoint is skipped after a proxy error.
+const endpointCooldown = 10 * time.Second
+
+// balancer round-robins over backend endpoints and passively ejects endpoints
+// that fail, so a terminating pod stops receiving traffic before its
+// EndpointSlice update arrives.
+type balancer struct {
+	next      atomic.Uint64
+	mu        sync.Mutex
+	unhealthy map[string]time.Time // endpoint -> retry after
+}
+
+func newBalancer() *balancer {
+	return &balancer{unhealthy: make(map[string]time.Time)}
+}
+
+// pick returns the next healthy endpoint. When every endpoint is ejected it
+// still returns one, preferring a possibly recovered endpoint over failing.
+func (b *balancer) pick(endpoints []string) string {
+	start := int(b.next.Add(1) % uint64(len(endpoints)))
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range endpoints {
+		ep := endpoints[(start+i)%len(endpoints)]
+		if until, ok := b.unhealthy[ep]; !ok || now.After(until) {
+			delete(b.unhealthy, ep)
+			return ep
+		}
+	}
+	return endpoints[start]
+}
+
+// markFailed ejects an endpoint for endpointCooldown.
+func (b *balancer) markFailed(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unhealthy[endpoint] = time.Now().Add(endpointCooldown)
+}
@@ -0,0 +1,33 @@
+package gateway
+
+import "testing"
+
+func TestBalancerPick(t *testing.T) {
+	b := newBalancer()
+	eps := []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080"}
+
+	seen := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		seen[b.pick(eps)]++
+	}
+	for _, ep := range eps {
+		if seen[ep] != 10 {
+			t.Errorf("endpoint %s picked %d times, want 10", ep, seen[ep])
+		}
+	}
+
+	b.markFailed(eps[1])
+	for i := 0; i < 10; i++ {
+		if got := b.pick(eps); got == eps[1] {
+			t.Fatalf("pick() returned ejected endpoint %s", got)
+		}
+	}
+
+	// With every endpoint ejected, pick still returns one of them.
+	for _, ep := range eps {
+		b.markFailed(ep)
+	}
+	if got := b.pick(eps); got == "" {
+		t.Error("pick() returned empty endpoint when all are ejected")
+	}
+}
@@ -0,0 +1,176 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func newBenchRoute() *routestore.CompiledRoute {
+	return &routestore.CompiledRoute{
+		Name:       "my-api",
+		Namespace:  "default",
+		Generation: 1,
+		Hosts:      []string{"api.example.com"},
+		Wallet:     "0xTestWallet",
+		Network:    "base-sepolia",
+		Rules: []routestore.CompiledRule{
+			{Path: "/health", Free: true},
+			{Path: "/api/v1/users/*/profile", Price: "0.001", Mode: "all-pay"},
+			{Path: "/api/**", Price: "0.002", Mode: "all-pay"},
+		},
+		Backends: []routestore.CompiledBackend{
+			{Path: "/", PathType: "Prefix", URL: "http://my-api.default.svc.cluster.local:8080"},
+			{Path: "/api", PathType: "Prefix", URL: "http://api-v2.default.svc.cluster.local:8080"},
+		},
+	}
+}
+
+func newBenchHandler(b *testing.B) *Handler {
+	b.Helper()
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	b.Cleanup(func() { slog.SetDefault(prev) })
+
+	store := routestore.New()
+	store.Set("default", "my-api", newBenchRoute())
+	return NewHandler(store)
+}
+
+func BenchmarkMatchPath(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		matchPath("/api/v1/users/*/profile", "/api/v1/users/42/profile")
+	}
+}
+
+func BenchmarkFindBackend(b *testing.B) {
+	backends := newBenchRoute().Backends
+	b.ReportAllocs()
+	for b.Loop() {
+		findBackend(backends, "/api/v1/users")
+	}
+}
+
+func BenchmarkHandlerPaymentRequired(b *testing.B) {
+	h := newBenchHandler(b)
+	r := httptest.NewRequest("GET", "http://api.example.com/api/v1/users/42/profile", nil)
+	b.ReportAllocs()
+	for b.Loop() {
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
+
+func BenchmarkHandlerNotFound(b *testing.B) {
+	h := newBenchHandler(b)
+	r := httptest.NewRequest("GET", "http://api.example.com/blog", nil)
+	b.ReportAllocs()
+	for b.Loop() {
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
+
+func BenchmarkBuildPaymentRequirements(b *testing.B) {
+	route := newBenchRoute()
+	r := httptest.NewRequest("GET", "http://api.example.com/api/v1/users/42/profile", nil)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := buildPaymentRequirements(r, route, &route.Rules[1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProxySetup(b *testing.B) {
+	route := newBenchRoute()
+	b.ReportAllocs()
+	for b.Loop() {
+		backend := findBackend(route.Backends, "/api/v1/users")
+		if _, err := proxies.get(backend.URL, backend); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProxyToBackend(b *testing.B) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	route := newBenchRoute()
+	route.Backends = []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}}
+	r := httptest.NewRequest("GET", "/health", nil)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		proxyToBackend(httptest.NewRecorder(), r, route, nil, "/health")
+	}
+}
+
+// BenchmarkListenerAccept measures new connections served per second by one
+// socket against four SO_REUSEPORT sockets (--gateway-reuse-port-listeners),
+// each request on a fresh connection.
+func BenchmarkListenerAccept(b *testing.B) {
+	if runtime.GOOS != "linux" {
+		b.Skip("SO_REUSEPORT is only supported on Linux")
+	}
+	for _, sockets := range []int{0, 4} {
+		b.Run(fmt.Sprintf("reusePort=%d", sockets), func(b *testing.B) {
+			listeners, err := ListenerOptions{ReusePort: sockets, Backlog: 4096}.listen(context.Background(), "127.0.0.1:0")
+			if err != nil {
+				b.Fatal(err)
+			}
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+			for _, ln := range listeners {
+				go srv.Serve(ln)
+			}
+			defer srv.Close()
+			url := "http://" + listeners[0].Addr().String()
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := client.Get(url)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					resp.Body.Close()
+				}
+			})
+		})
+	}
+}
+
+// TestHotPathAllocations guards the allocation budget of the per-request
+// matching and proxy lookup paths. Run with `make bench`.
+func TestHotPathAllocations(t *testing.T) {
+	route := newBenchRoute()
+	if _, err := proxies.get(route.Backends[1].URL, &route.Backends[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		budget float64
+		fn     func()
+	}{
+		{name: "matchPath", fn: func() { matchPath("/api/v1/users/*/profile", "/api/v1/users/42/profile") }},
+		{name: "matchPath double wildcard", fn: func() { matchPath("/api/**", "/api/v1/users") }},
+		{name: "findBackend", fn: func() { findBackend(route.Backends, "/api/v1/users") }},
+		{name: "proxy lookup", fn: func() { proxies.get(route.Backends[1].URL, &route.Backends[1]) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allocs := testing.AllocsPerRun(100, tt.fn); allocs > tt.budget {
+				t.Errorf("allocs per run = %v, budget %v", allocs, tt.budget)
+			}
+		})
+	}
+}
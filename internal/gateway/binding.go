@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// resourceHash binds a payment requirement to the route, resource and amount
+// it was offered for. It is deterministic, so every replica advertises and
+// accepts the same hash, and cached 402 responses stay valid.
+func resourceHash(r *http.Request, route *routestore.CompiledRoute, amount string) string {
+	h := sha256.New()
+	for _, part := range []string{route.Namespace, route.Name, r.URL.RequestURI(), amount} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// resourceBindingError returns why a payment was not made for the accepted
+// requirements of this resource, or "" when its "accepted" requirements echo
+// their resource hash.
+func resourceBindingError(paymentHeader string, accept *paymentAccept) string {
+	const reason = "resource_mismatch: payment was not made for an offer of this resource"
+	payloadBytes, err := base64.StdEncoding.DecodeString(paymentHeader)
+	if err != nil {
+		return reason
+	}
+	var payload struct {
+		Accepted *paymentAccept `json:"accepted"`
+	}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil || payload.Accepted == nil || payload.Accepted.Extra == nil {
+		return reason
+	}
+	if payload.Accepted.Extra.ResourceHash != accept.Extra.ResourceHash {
+		return reason
+	}
+	return ""
+}
+
+// Client binding modes of spec.payment.bindClient.
+const (
+	bindCertificate = "certificate"
+	bindSession     = "session"
+)
+
+// clientBindingLabel is the TLS exporter label of session bindings and the
+// domain of client nonces.
+const clientBindingLabel = "EXPORTER-x402-client-binding"
+
+// clientCertHeader is the request header a TLS-terminating proxy forwards
+// the client certificate in; empty trusts none. Set by
+// Server.EnableClientCertHeader.
+var clientCertHeader string
+
+// clientFingerprint returns what mode binds the payments of r to: the SHA-256
+// of the client certificate, or keying material exported from the TLS
+// session. It returns "" when r has neither.
+func clientFingerprint(r *http.Request, mode string) string {
+	switch mode {
+	case bindCertificate:
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			sum := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+			return hex.EncodeToString(sum[:])
+		}
+		if clientCertHeader != "" {
+			return headerCertFingerprint(r.Header.Get(clientCertHeader))
+		}
+	case bindSession:
+		if r.TLS != nil {
+			if material, err := r.TLS.ExportKeyingMaterial(clientBindingLabel, nil, 32); err == nil {
+				return hex.EncodeToString(material)
+			}
+		}
+	}
+	return ""
+}
+
+// headerCertFingerprint returns the SHA-256 fingerprint of a client
+// certificate forwarded by a proxy, either as a URL-encoded PEM certificate,
+// as ingress-nginx sends it, or as a hex fingerprint.
+func headerCertFingerprint(value string) string {
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		sum := sha256.Sum256(block.Bytes)
+		return hex.EncodeToString(sum[:])
+	}
+	fingerprint := strings.ToLower(strings.ReplaceAll(value, ":", ""))
+	if raw, err := hex.DecodeString(fingerprint); err != nil || len(raw) != sha256.Size {
+		return ""
+	}
+	return fingerprint
+}
+
+// clientChallenge returns a random challenge for an offer made to the client
+// with fingerprint, and the nonce its payment must be signed with.
+func clientChallenge(fingerprint string) (challenge, nonce string) {
+	var raw [32]byte
+	rand.Read(raw[:])
+	challenge = hex.EncodeToString(raw[:])
+	return challenge, clientNonce(challenge, fingerprint)
+}
+
+// clientNonce derives the authorization nonce of a challenge for the client
+// with fingerprint. Another client cannot find a challenge that derives the
+// same nonce, so a signed payment only verifies for the client it was
+// offered to.
+func clientNonce(challenge, fingerprint string) string {
+	h := sha256.New()
+	for _, part := range []string{clientBindingLabel, challenge, fingerprint} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return "0x" + hex.EncodeToString(h.Sum(nil))
+}
+
+// clientBindingError returns why a payment was not signed for an offer made to
+// the client with fingerprint, or "" when its authorization nonce derives from
+// the challenge of its "accepted" requirements. accept was built with a
+// challenge of its own; it is given the payment's, so the facilitator sees the
+// requirements the payment was made for.
+func clientBindingError(paymentHeader string, accept *paymentAccept, fingerprint string) string {
+	const reason = "client_mismatch: payment was not signed for an offer made to this client"
+	payloadBytes, err := base64.StdEncoding.DecodeString(paymentHeader)
+	if err != nil {
+		return reason
+	}
+	var payload struct {
+		Accepted *paymentAccept `json:"accepted"`
+		Payload  struct {
+			Authorization struct {
+				Nonce string `json:"nonce"`
+			} `json:"authorization"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil || payload.Accepted == nil || payload.Accepted.Extra == nil {
+		return reason
+	}
+	challenge := payload.Accepted.Extra.Challenge
+	nonce := clientNonce(challenge, fingerprint)
+	if challenge == "" || !strings.EqualFold(payload.Payload.Authorization.Nonce, nonce) {
+		return reason
+	}
+	accept.Extra.Challenge, accept.Extra.Nonce = challenge, nonce
+	return ""
+}
@@ -0,0 +1,275 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestBindResource(t *testing.T) {
+	var verifies atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			verifies.Add(1)
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backend")
+	}))
+	defer backend.Close()
+
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+		BindResource: true,
+		Rules: []routestore.CompiledRule{
+			{Path: "/api/cheap", Price: "0.01", Mode: "all-pay"},
+			{Path: "/api/costly", Price: "0.01", Mode: "all-pay"},
+		},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}},
+	})
+	h := NewHandler(store)
+
+	// Take the offer of the cheap path.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/cheap", nil))
+	var reqs paymentRequirements
+	if err := json.Unmarshal(w.Body.Bytes(), &reqs); err != nil {
+		t.Fatalf("decode 402: %v", err)
+	}
+	if reqs.Accepts[0].Extra.ResourceHash == "" {
+		t.Fatal("402 offer has no extra.resourceHash")
+	}
+	offer, _ := json.Marshal(map[string]any{"x402Version": 2, "accepted": reqs.Accepts[0]})
+	paid := base64.StdEncoding.EncodeToString(offer)
+	unbound := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`))
+
+	tests := []struct {
+		name         string
+		path         string
+		payment      string
+		wantStatus   int
+		wantVerifies int32
+	}{
+		{name: "offered resource", path: "/api/cheap", payment: paid, wantStatus: http.StatusOK, wantVerifies: 1},
+		{name: "other resource at the same price", path: "/api/costly", payment: paid, wantStatus: http.StatusPaymentRequired},
+		{name: "no accepted requirements", path: "/api/cheap", payment: unbound, wantStatus: http.StatusPaymentRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifies.Store(0)
+			r := httptest.NewRequest("GET", tt.path, nil)
+			r.Header.Set("Payment-Signature", tt.payment)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := verifies.Load(); got != tt.wantVerifies {
+				t.Errorf("facilitator verifies = %d, want %d", got, tt.wantVerifies)
+			}
+			if tt.wantStatus == http.StatusPaymentRequired && !strings.Contains(w.Body.String(), "resource_mismatch") {
+				t.Errorf("body = %s, want resource_mismatch error", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHeaderCertFingerprint(t *testing.T) {
+	cert := httptest.NewUnstartedServer(nil)
+	cert.StartTLS()
+	defer cert.Close()
+	raw := cert.Certificate().Raw
+	sum := sha256.Sum256(raw)
+	want := hex.EncodeToString(sum[:])
+	pemCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}))
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "url-encoded PEM", value: url.PathEscape(pemCert), want: want},
+		{name: "PEM", value: pemCert, want: want},
+		{name: "colon-separated fingerprint", value: strings.ToUpper(want[:2] + ":" + want[2:]), want: want},
+		{name: "empty", value: "", want: ""},
+		{name: "garbage", value: "not a certificate", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := headerCertFingerprint(tt.value); got != tt.want {
+				t.Errorf("headerCertFingerprint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindClient(t *testing.T) {
+	var facilitated atomic.Value
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		facilitated.Store(string(body))
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backend")
+	}))
+	defer backend.Close()
+
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+		BindClient: bindCertificate,
+		Rules:      []routestore.CompiledRule{{Path: "/api/data", Price: "0.01", Mode: "all-pay"}},
+		Backends:   []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}},
+	})
+	h := NewHandler(store)
+	clientCertHeader = "X-Client-Cert-Fingerprint"
+	defer func() { clientCertHeader = "" }()
+	alice, mallory := strings.Repeat("a", 64), strings.Repeat("b", 64)
+
+	request := func(client, payment string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/data", nil)
+		if client != "" {
+			r.Header.Set(clientCertHeader, client)
+		}
+		if payment != "" {
+			r.Header.Set("Payment-Signature", payment)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// Each offer carries a challenge of its own and the nonce derived from it.
+	w := request(alice, "")
+	var reqs paymentRequirements
+	if err := json.Unmarshal(w.Body.Bytes(), &reqs); err != nil {
+		t.Fatalf("decode 402: %v", err)
+	}
+	extra := reqs.Accepts[0].Extra
+	if extra.Challenge == "" || extra.Nonce != clientNonce(extra.Challenge, alice) {
+		t.Fatalf("402 extra = %+v, want a challenge and its nonce", extra)
+	}
+	var again paymentRequirements
+	json.Unmarshal(request(alice, "").Body.Bytes(), &again)
+	if again.Accepts[0].Extra.Challenge == extra.Challenge {
+		t.Error("two 402 responses share a challenge")
+	}
+
+	payment := func(nonce string) string {
+		body, _ := json.Marshal(map[string]any{
+			"x402Version": 2,
+			"accepted":    reqs.Accepts[0],
+			"payload":     map[string]any{"authorization": map[string]string{"nonce": nonce}},
+		})
+		return base64.StdEncoding.EncodeToString(body)
+	}
+	tests := []struct {
+		name       string
+		client     string
+		payment    string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "no client certificate", payment: payment(extra.Nonce), wantStatus: http.StatusForbidden, wantBody: "TLS certificate"},
+		{name: "replayed by another client", client: mallory, payment: payment(extra.Nonce), wantStatus: http.StatusPaymentRequired, wantBody: "client_mismatch"},
+		{name: "random nonce", client: alice, payment: payment("0x01"), wantStatus: http.StatusPaymentRequired, wantBody: "client_mismatch"},
+		{name: "bound nonce", client: alice, payment: payment(extra.Nonce), wantStatus: http.StatusOK, wantBody: "backend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(tt.client, tt.payment)
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("status = %d, body %q, want %d with %q", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+
+	// The facilitator sees the challenge the payment was made for.
+	if got, _ := facilitated.Load().(string); !strings.Contains(got, extra.Challenge) {
+		t.Errorf("facilitator request %s lacks the paid challenge", got)
+	}
+}
+
+func TestBindClientSession(t *testing.T) {
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backend")
+	}))
+	defer backend.Close()
+
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+		BindClient: bindSession,
+		Rules:      []routestore.CompiledRule{{Path: "/api/data", Price: "0.01", Mode: "all-pay"}},
+		Backends:   []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}},
+	})
+	gateway := httptest.NewTLSServer(NewHandler(store))
+	defer gateway.Close()
+
+	get := func(c *http.Client, payment string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", gateway.URL+"/api/data", nil)
+		if payment != "" {
+			req.Header.Set("Payment-Signature", payment)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	client := gateway.Client()
+	_, body := get(client, "")
+	var reqs paymentRequirements
+	if err := json.Unmarshal([]byte(body), &reqs); err != nil {
+		t.Fatalf("decode 402: %v", err)
+	}
+	signed, _ := json.Marshal(map[string]any{
+		"x402Version": 2,
+		"accepted":    reqs.Accepts[0],
+		"payload":     map[string]any{"authorization": map[string]string{"nonce": reqs.Accepts[0].Extra.Nonce}},
+	})
+	payment := base64.StdEncoding.EncodeToString(signed)
+
+	// The payment is only honored on the connection the offer was made on.
+	other := &http.Client{Transport: client.Transport.(*http.Transport).Clone()}
+	if resp, body := get(other, payment); resp.StatusCode != http.StatusPaymentRequired || !strings.Contains(body, "client_mismatch") {
+		t.Errorf("other connection: status %d, body %q, want 402 client_mismatch", resp.StatusCode, body)
+	}
+	if resp, body := get(client, payment); resp.StatusCode != http.StatusOK || body != "backend" {
+		t.Errorf("same connection: status %d, body %q, want 200 backend", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+)
+
+// errBudgetExceeded is the cause of a facilitator call canceled because its
+// request ran over the route's payment latency budget.
+var errBudgetExceeded = errors.New("payment latency budget exceeded")
+
+// budgetExceededReason is the error of the 402 answer to a payment over its
+// latency budget.
+const budgetExceededReason = "payment_latency_budget_exceeded"
+
+// budgetRetryAfter is the Retry-After hint, in seconds, sent with it.
+const budgetRetryAfter = "1"
+
+// budgeted bounds ctx, the context of the /verify call made for req, by the
+// route's payment latency budget. The returned done func ends the call with
+// its error and reports whether the call failed because the budget ran out,
+// rather than because of the client or the call's own timeout. Settlement is
+// never budgeted: once /settle is sent the payment may land, so the gateway
+// waits for its result rather than asking the client to pay again.
+func budgeted(ctx context.Context, req *request) (context.Context, func(err error) bool) {
+	budget := req.route.PaymentBudget
+	if budget == 0 {
+		return ctx, func(error) bool { return false }
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, budget, errBudgetExceeded)
+	return ctx, func(err error) bool {
+		exceeded := err != nil && errors.Is(context.Cause(ctx), errBudgetExceeded)
+		cancel()
+		return exceeded
+	}
+}
+
+// overBudget answers a paid request whose /verify call ran over the payment
+// latency budget with a fresh 402 and a Retry-After hint, so the client pays
+// again rather than waiting on a slow facilitator. Nothing was settled, so
+// the first payment cannot be charged.
+func overBudget(req *request) {
+	route := req.route
+	slog.Warn("payment over latency budget", "path", req.path, "route", route.Name, "budget", route.PaymentBudget)
+	metrics.PaymentBudgetViolationsTotal.WithLabelValues(facilitatorHost(route.FacilitatorURL), "verify").Inc()
+	metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), route.Namespace, route.Name, "budget_exceeded").Inc()
+	req.w.Header().Set("Retry-After", budgetRetryAfter)
+	writePaymentError(req.w, req.reqs, budgetExceededReason)
+}
+
+// facilitatorHost is the facilitator label of a facilitator URL: its host,
+// which keeps paths and credentials out of the metrics.
+func facilitatorHost(facilitatorURL string) string {
+	u, err := url.Parse(facilitatorURL)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// maxCachedResponsesPerRoute bounds the 402 cache of a single route. Once full,
+// the route's cache is reset rather than tracking recency.
+const maxCachedResponsesPerRoute = 1024
+
+// paymentRequiredCacheControl lets clients reuse 402 requirements briefly.
+// private keeps shared caches such as CDNs from storing them.
+const paymentRequiredCacheControl = "private, max-age=30"
+
+// cachedResponse is a serialized 402 response.
+type cachedResponse struct {
+	body   []byte
+	header string // Base64-encoded PAYMENT-REQUIRED header
+	etag   string
+}
+
+// paymentRequiredETag returns the entity tag of a 402 body: the route
+// generation and a hash of the requirements, which carry the price.
+func paymentRequiredETag(route *routestore.CompiledRoute, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%s"`, route.Generation, hex.EncodeToString(sum[:8]))
+}
+
+// notModified reports whether the If-None-Match header of r lists etag.
+// Weak tags match too, as for GET.
+func notModified(r *http.Request, etag string) bool {
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// responseKey identifies a 402 response of a compiled route.
+type responseKey struct {
+	price    string // pricingKey of the rule
+	resource string
+}
+
+// routeResponses holds the cached responses of one compiled route.
+type routeResponses struct {
+	route      *routestore.CompiledRoute
+	generation int64
+	entries    map[responseKey]*cachedResponse
+}
+
+// current reports whether the entries were built from route as it is now.
+// Every compilation stores a new route, also when the generation is
+// unchanged, e.g. after a route variable or wallet Secret changed the payTo.
+func (rr *routeResponses) current(route *routestore.CompiledRoute) bool {
+	return rr.route == route && rr.generation == route.Generation
+}
+
+// responseCache caches serialized 402 responses per route, price and resource
+// so bursts of unpaid requests skip requirement building and marshaling. A
+// route's entries are dropped as soon as it is compiled again, or once it is
+// deleted when the cache follows the route store.
+type responseCache struct {
+	mu     sync.RWMutex
+	routes map[string]*routeResponses // key: "namespace/name"
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{routes: make(map[string]*routeResponses)}
+}
+
+// get returns the cached response for the route, price and resource, calling
+// build on a miss.
+func (c *responseCache) get(route *routestore.CompiledRoute, price, resource string, build func() (*cachedResponse, error)) (*cachedResponse, error) {
+	routeKey := route.Namespace + "/" + route.Name
+	key := responseKey{price: price, resource: resource}
+
+	c.mu.RLock()
+	if rr, ok := c.routes[routeKey]; ok && rr.current(route) {
+		if resp, ok := rr.entries[key]; ok {
+			c.mu.RUnlock()
+			metrics.PaymentRequiredCacheTotal.WithLabelValues("hit").Inc()
+			return resp, nil
+		}
+	}
+	c.mu.RUnlock()
+
+	metrics.PaymentRequiredCacheTotal.WithLabelValues("miss").Inc()
+	resp, err := build()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rr, ok := c.routes[routeKey]
+	if !ok || !rr.current(route) || len(rr.entries) >= maxCachedResponsesPerRoute {
+		rr = &routeResponses{route: route, generation: route.Generation, entries: make(map[responseKey]*cachedResponse)}
+		c.routes[routeKey] = rr
+	}
+	rr.entries[key] = resp
+	return resp, nil
+}
+
+// follow drops the entries of routes deleted from store until ctx is
+// canceled. Updated routes need nothing: the store holds a new route.
+func (c *responseCache) follow(ctx context.Context, store *routestore.Store) {
+	events := make(chan routestore.Event, 16)
+	cancel := store.Subscribe(events)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			switch ev.Type {
+			case routestore.EventDelete:
+				c.forget(ev.Namespace + "/" + ev.Name)
+			case routestore.EventResync:
+				c.retain(store.Snapshot())
+			}
+		}
+	}
+}
+
+// forget drops the entries of the route named "namespace/name".
+func (c *responseCache) forget(routeKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.routes, routeKey)
+}
+
+// retain drops the entries of every route not in routes.
+func (c *responseCache) retain(routes []*routestore.CompiledRoute) {
+	keep := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		keep[route.Namespace+"/"+route.Name] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.routes {
+		if !keep[key] {
+			delete(c.routes, key)
+		}
+	}
+}
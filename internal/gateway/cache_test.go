@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestResponseCache(t *testing.T) {
+	c := newResponseCache()
+	route := &routestore.CompiledRoute{Name: "my-api", Namespace: "default", Generation: 1}
+
+	builds := 0
+	build := func() (*cachedResponse, error) {
+		builds++
+		return &cachedResponse{body: []byte("{}")}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.get(route, "0.001", "/api/a", build); err != nil {
+			t.Fatalf("get() error = %v", err)
+		}
+	}
+	if builds != 1 {
+		t.Errorf("builds after repeated lookups = %d, want 1", builds)
+	}
+
+	// A different price or resource is a separate entry.
+	c.get(route, "0.01", "/api/a", build)
+	c.get(route, "0.001", "/api/b", build)
+	if builds != 3 {
+		t.Errorf("builds after distinct keys = %d, want 3", builds)
+	}
+
+	// A new route generation invalidates cached responses.
+	route.Generation = 2
+	c.get(route, "0.001", "/api/a", build)
+	if builds != 4 {
+		t.Errorf("builds after generation change = %d, want 4", builds)
+	}
+}
+
+func TestPaymentRequiredWalletChange(t *testing.T) {
+	store := routestore.New()
+	route := func(wallet string) *routestore.CompiledRoute {
+		return &routestore.CompiledRoute{
+			Name: "wallet-api", Namespace: "default", Generation: 1, Wallet: wallet, Network: "base-sepolia",
+			Rules: []routestore.CompiledRule{{Path: "/api/**", Price: "0.01", Mode: "all-pay"}},
+		}
+	}
+	h := NewHandler(store)
+	payTo := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+		var body paymentRequirements
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Accepts) == 0 {
+			t.Fatalf("402 body %s: %v", w.Body.String(), err)
+		}
+		return body.Accepts[0].PayTo
+	}
+
+	store.Set("default", "wallet-api", route("0xOldWallet"))
+	if got := payTo(); got != "0xOldWallet" {
+		t.Fatalf("payTo = %q, want 0xOldWallet", got)
+	}
+	// A changed route variable or wallet Secret recompiles the route under
+	// the same generation.
+	store.Set("default", "wallet-api", route("0xNewWallet"))
+	if got := payTo(); got != "0xNewWallet" {
+		t.Errorf("payTo after the wallet changed = %q, want 0xNewWallet", got)
+	}
+}
+
+func TestPaymentRequiredRevalidation(t *testing.T) {
+	store := routestore.New()
+	route := &routestore.CompiledRoute{
+		Name: "etag-api", Namespace: "default", Generation: 1, Wallet: "0xTestWallet", Network: "base-sepolia",
+		Rules: []routestore.CompiledRule{{Path: "/api/**", Price: "0.01", Mode: "all-pay"}},
+	}
+	store.Set("default", "etag-api", route)
+	h := NewHandler(store)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusPaymentRequired || etag == "" {
+		t.Fatalf("first response = %d with ETag %q, want 402 with an ETag", w.Code, etag)
+	}
+	if got := w.Header().Get("Cache-Control"); got != paymentRequiredCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, paymentRequiredCacheControl)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		payment     string
+		generation  int64
+		wantStatus  int
+	}{
+		{name: "matching tag", ifNoneMatch: etag, generation: 1, wantStatus: http.StatusNotModified},
+		{name: "weak tag in a list", ifNoneMatch: `"other", W/` + etag, generation: 1, wantStatus: http.StatusNotModified},
+		{name: "stale tag", ifNoneMatch: `"0-deadbeef"`, generation: 1, wantStatus: http.StatusPaymentRequired},
+		{name: "route changed", ifNoneMatch: etag, generation: 2, wantStatus: http.StatusPaymentRequired},
+		{name: "with a payment", ifNoneMatch: etag, payment: "not base64!", generation: 1, wantStatus: http.StatusPaymentRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route.Generation = tt.generation
+			r := httptest.NewRequest("GET", "/api/data", nil)
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			if tt.payment != "" {
+				r.Header.Set("Payment-Signature", tt.payment)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 has a body: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestResponseCacheFollow(t *testing.T) {
+	c := newResponseCache()
+	store := routestore.New()
+	kept := &routestore.CompiledRoute{Name: "kept", Namespace: "default"}
+	store.Set("default", "kept", kept)
+	build := func() (*cachedResponse, error) { return &cachedResponse{}, nil }
+	// A route deleted before the cache follows the store is dropped on the
+	// initial resync.
+	c.get(&routestore.CompiledRoute{Name: "stale", Namespace: "default"}, "0.01", "/a", build)
+	c.get(kept, "0.01", "/a", build)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.follow(ctx, store)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	cached := func() []string {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		var keys []string
+		for key := range c.routes {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return keys
+	}
+	waitFor := func(want ...string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !slices.Equal(cached(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("cached routes = %v, want %v", cached(), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor("default/kept")
+
+	store.Delete("default", "kept")
+	waitFor()
+}
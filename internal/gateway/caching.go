@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// applyPaidCaching replaces the caching headers of responses to paid requests
+// with the route's, so caches in front of the Ingress cannot hand paid
+// content to clients that did not pay. 402 and 304 answers keep their own.
+func applyPaidCaching(req *request, next func()) {
+	if req.route.PaidCaching != nil {
+		req.w = &cachingWriter{ResponseWriter: req.w, caching: req.route.PaidCaching}
+	}
+	next()
+}
+
+// cachingWriter sets the caching headers of a response as it is written.
+type cachingWriter struct {
+	http.ResponseWriter
+	caching *routestore.CompiledCaching
+	wrote   bool
+}
+
+func (w *cachingWriter) WriteHeader(status int) {
+	if !w.wrote && status >= http.StatusOK {
+		w.wrote = true
+		if status != http.StatusPaymentRequired && status != http.StatusNotModified {
+			setCachingHeaders(w.Header(), w.caching)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cachingWriter) Write(p []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush lets streamed responses through the reverse proxy.
+func (w *cachingWriter) Flush() {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *cachingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setCachingHeaders replaces the caching headers of h with caching.
+func setCachingHeaders(h http.Header, caching *routestore.CompiledCaching) {
+	h.Set("Cache-Control", caching.CacheControl)
+	h.Del("Expires")
+	for _, name := range []string{"Surrogate-Control", "CDN-Cache-Control"} {
+		if caching.SurrogateControl == "" {
+			h.Del(name)
+		} else {
+			h.Set(name, caching.SurrogateControl)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestPaidResponseCaching(t *testing.T) {
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("Surrogate-Control", "max-age=86400")
+		w.Header().Set("Expires", "Thu, 01 Jan 2099 00:00:00 GMT")
+		io.WriteString(w, "paid content")
+	}))
+	defer backend.Close()
+	noStore := &routestore.CompiledCaching{CacheControl: "private, no-store", SurrogateControl: "no-store"}
+
+	tests := []struct {
+		name             string
+		caching          *routestore.CompiledCaching
+		path             string
+		paid             bool
+		wantCacheControl string
+		wantSurrogate    string
+	}{
+		{name: "no store", caching: noStore, path: "/api/data", paid: true, wantCacheControl: "private, no-store", wantSurrogate: "no-store"},
+		{name: "backend headers", path: "/api/data", paid: true, wantCacheControl: "public, max-age=3600", wantSurrogate: "max-age=86400"},
+		{
+			name: "custom without surrogate", caching: &routestore.CompiledCaching{CacheControl: "private, max-age=60"},
+			path: "/api/data", paid: true, wantCacheControl: "private, max-age=60",
+		},
+		{name: "free path", caching: noStore, path: "/health", wantCacheControl: "public, max-age=3600", wantSurrogate: "max-age=86400"},
+		{name: "402 keeps its own", caching: noStore, path: "/api/data", wantCacheControl: paymentRequiredCacheControl},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := routestore.New()
+			store.Set("default", "my-api", &routestore.CompiledRoute{
+				Name: "my-api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+				PaidCaching: tt.caching,
+				Rules: []routestore.CompiledRule{
+					{Path: "/health", Free: true},
+					{Path: "/api/*", Price: "0.001", Mode: "all-pay"},
+				},
+				Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}},
+			})
+
+			r := httptest.NewRequest("GET", tt.path, nil)
+			if tt.paid {
+				r.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+			}
+			w := httptest.NewRecorder()
+			NewHandler(store).ServeHTTP(w, r)
+
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+			if got := w.Header().Get("Surrogate-Control"); got != tt.wantSurrogate {
+				t.Errorf("Surrogate-Control = %q, want %q", got, tt.wantSurrogate)
+			}
+			if tt.caching != nil && tt.paid {
+				if got := w.Header().Get("CDN-Cache-Control"); got != tt.wantSurrogate {
+					t.Errorf("CDN-Cache-Control = %q, want %q", got, tt.wantSurrogate)
+				}
+				if got := w.Header().Get("Expires"); got != "" {
+					t.Errorf("Expires = %q, want it removed", got)
+				}
+			}
+		})
+	}
+}
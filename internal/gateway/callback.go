@@ -0,0 +1,223 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// headerPaymentCallback carries the settlement callback URL when the payment
+// payload has no extra.callbackUrl.
+const headerPaymentCallback = "X-Payment-Callback"
+
+const (
+	callbackQueueSize = 256
+	callbackWorkers   = 4
+	callbackAttempts  = 3
+)
+
+// cgnat is the carrier-grade NAT range, which net.IP.IsPrivate does not cover.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// settlementCallback is the body POSTed to a client's callback URL.
+type settlementCallback struct {
+	Route       string `json:"route"`
+	Resource    string `json:"resource"`
+	Success     bool   `json:"success"`
+	ErrorReason string `json:"errorReason,omitempty"`
+	Payer       string `json:"payer,omitempty"`
+	Transaction string `json:"transaction,omitempty"`
+	Network     string `json:"network,omitempty"`
+}
+
+type callbackJob struct {
+	url  string
+	body settlementCallback
+}
+
+// callbackNotifier delivers settlement callbacks in the background so paid
+// requests never wait on a client's callback endpoint.
+type callbackNotifier struct {
+	client     *http.Client
+	retryDelay time.Duration
+	queue      chan callbackJob
+	once       sync.Once
+}
+
+func newCallbackNotifier() *callbackNotifier {
+	// Callback URLs are client-controlled; refuse to dial cluster-internal
+	// addresses, including ones a public hostname resolves to.
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicAddressOnly}
+	return &callbackNotifier{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		retryDelay: time.Second,
+		queue:      make(chan callbackJob, callbackQueueSize),
+	}
+}
+
+// notifySettlement queues a callback with the settlement result if the client
+// asked for one and the route allows it.
+func (h *Handler) notifySettlement(r *http.Request, route *routestore.CompiledRoute, paymentHeader string, settled *settleResponse, settleErr error) {
+	target, err := callbackURL(r, paymentHeader, route.CallbackHosts)
+	if err != nil {
+		slog.Warn("rejected settlement callback URL", "path", r.URL.Path, "route", route.Name, "error", err)
+		metrics.SettlementCallbacksTotal.WithLabelValues("rejected").Inc()
+		return
+	}
+	if target == "" {
+		return
+	}
+
+	body := settlementCallback{
+		Route:    route.Namespace + "/" + route.Name,
+		Resource: r.URL.String(),
+		Network:  route.Network,
+	}
+	if settleErr != nil {
+		body.ErrorReason = settleErr.Error()
+	} else {
+		body.Success = true
+		body.Payer = settled.Payer
+		body.Transaction = settled.Transaction
+		if settled.Network != "" {
+			body.Network = settled.Network
+		}
+	}
+	h.callbacks.enqueue(target, body)
+}
+
+// enqueue schedules a callback without blocking. Callbacks are dropped when
+// the queue is full.
+func (n *callbackNotifier) enqueue(target string, body settlementCallback) {
+	n.once.Do(func() {
+		for range callbackWorkers {
+			go n.run()
+		}
+	})
+	select {
+	case n.queue <- callbackJob{url: target, body: body}:
+	default:
+		slog.Warn("settlement callback queue full, dropping callback", "route", body.Route)
+		metrics.SettlementCallbacksTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (n *callbackNotifier) run() {
+	for job := range n.queue {
+		n.deliver(job)
+	}
+}
+
+// deliver POSTs the callback, retrying failed attempts with linear backoff.
+func (n *callbackNotifier) deliver(job callbackJob) {
+	payload, err := json.Marshal(job.body)
+	if err != nil {
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		err := n.post(job.url, payload)
+		if err == nil {
+			metrics.SettlementCallbacksTotal.WithLabelValues("delivered").Inc()
+			return
+		}
+		if attempt == callbackAttempts {
+			slog.Warn("settlement callback failed", "route", job.body.Route, "attempts", attempt, "error", err)
+			metrics.SettlementCallbacksTotal.WithLabelValues("failed").Inc()
+			return
+		}
+		time.Sleep(n.retryDelay * time.Duration(attempt))
+	}
+}
+
+func (n *callbackNotifier) post(target string, payload []byte) error {
+	resp, err := n.client.Post(target, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// callbackURL returns the settlement callback URL requested by the client,
+// taken from the payment payload's extra.callbackUrl or the
+// X-Payment-Callback header. It returns "" if none was requested.
+func callbackURL(r *http.Request, paymentHeader string, allowedHosts []string) (string, error) {
+	var payload struct {
+		Extra struct {
+			CallbackURL string `json:"callbackUrl"`
+		} `json:"extra"`
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(paymentHeader); err == nil {
+		json.Unmarshal(decoded, &payload)
+	}
+	raw := payload.Extra.CallbackURL
+	if raw == "" {
+		raw = r.Header.Get(headerPaymentCallback)
+	}
+	if raw == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("parse callback URL: %w", err)
+	}
+	if u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return "", fmt.Errorf("callback URL %q must be an https URL without credentials", raw)
+	}
+	if len(allowedHosts) == 0 {
+		return u.String(), nil
+	}
+	for _, pattern := range allowedHosts {
+		if matchHost(pattern, u.Hostname()) {
+			return u.String(), nil
+		}
+	}
+	return "", fmt.Errorf("callback host %q is not allowed", u.Hostname())
+}
+
+// publicAddressOnly is a net.Dialer Control function that refuses loopback,
+// private, link-local and other non-public addresses.
+func publicAddressOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || cgnat.Contains(addr) {
+		return errors.New("address " + addr.String() + " is not public")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"math/big"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// chargeAmount applies the route's price increment and minimum charge to an
+// atomic amount: it is rounded up to a multiple of the increment, then raised
+// to the minimum, so facilitators are not asked to move dust. Zero amounts
+// are not charged and stay zero.
+func chargeAmount(route *routestore.CompiledRoute, atomic string, decimals int) string {
+	if route.MinimumCharge == nil && route.PriceIncrement == nil {
+		return atomic
+	}
+	amount, ok := new(big.Int).SetString(atomic, 10)
+	if !ok || amount.Sign() <= 0 {
+		return atomic
+	}
+	if route.PriceIncrement != nil {
+		if step := atomicCeil(route.PriceIncrement, decimals); step.Cmp(big.NewInt(1)) > 0 {
+			q, rem := new(big.Int).QuoRem(amount, step, new(big.Int))
+			if rem.Sign() > 0 {
+				q.Add(q, big.NewInt(1))
+			}
+			amount = q.Mul(q, step)
+		}
+	}
+	if route.MinimumCharge != nil {
+		if minimum := atomicCeil(route.MinimumCharge, decimals); amount.Cmp(minimum) < 0 {
+			amount = minimum
+		}
+	}
+	return amount.String()
+}
+
+// atomicCeil converts a token amount to atomic units, rounding up.
+func atomicCeil(tokens *big.Rat, decimals int) *big.Int {
+	amount := new(big.Rat).Mul(tokens, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	atomic, rem := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+	if rem.Sign() > 0 {
+		atomic.Add(atomic, big.NewInt(1))
+	}
+	return atomic
+}
+
+// capAmount returns the smaller of two atomic amounts.
+func capAmount(atomic, limit string) string {
+	a, ok := new(big.Int).SetString(atomic, 10)
+	b, ok2 := new(big.Int).SetString(limit, 10)
+	if ok && ok2 && a.Cmp(b) > 0 {
+		return limit
+	}
+	return atomic
+}
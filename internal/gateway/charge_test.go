@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestChargeAmount(t *testing.T) {
+	minimum, increment := big.NewRat(1, 1000), big.NewRat(1, 10000) // 0.001 and 0.0001 tokens
+
+	tests := []struct {
+		name      string
+		minimum   *big.Rat
+		increment *big.Rat
+		atomic    string
+		want      string
+	}{
+		{name: "unset", atomic: "1", want: "1"},
+		{name: "raised to minimum", minimum: minimum, atomic: "334", want: "1000"},
+		{name: "above minimum", minimum: minimum, atomic: "2500", want: "2500"},
+		{name: "rounded up to increment", increment: increment, atomic: "2501", want: "2600"},
+		{name: "multiple of increment", increment: increment, atomic: "2500", want: "2500"},
+		{name: "rounded then raised", minimum: minimum, increment: increment, atomic: "901", want: "1000"},
+		{name: "increment finer than token", increment: big.NewRat(1, 100000000), atomic: "2501", want: "2501"},
+		{name: "zero is not charged", minimum: minimum, increment: increment, atomic: "0", want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &routestore.CompiledRoute{MinimumCharge: tt.minimum, PriceIncrement: tt.increment}
+			if got := chargeAmount(route, tt.atomic, 6); got != tt.want {
+				t.Errorf("chargeAmount(%s) = %s, want %s", tt.atomic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChargeAmountAdvertised(t *testing.T) {
+	route := &routestore.CompiledRoute{
+		Wallet: "0xTestWallet", Network: "base-sepolia",
+		MinimumCharge: big.NewRat(1, 1000), PriceIncrement: big.NewRat(1, 10000),
+	}
+	rule := &routestore.CompiledRule{
+		Price: "0.002",
+		Modifiers: []routestore.CompiledPriceModifier{
+			{Param: "quality", Pattern: regexp.MustCompile(`^draft$`), Multiplier: big.NewRat(1, 3)},
+			{Param: "quality", Pattern: regexp.MustCompile(`^low$`), Multiplier: big.NewRat(1, 10)},
+		},
+	}
+
+	for url, want := range map[string]string{
+		"/render":               "2000",
+		"/render?quality=draft": "1000", // 667 rounded to 700, then raised to the minimum
+		"/render?quality=low":   "1000",
+	} {
+		reqs, err := buildPaymentRequirements(httptest.NewRequest("GET", url, nil), route, rule)
+		if err != nil {
+			t.Fatalf("buildPaymentRequirements(%s) error = %v", url, err)
+		}
+		if got := reqs.Accepts[0].Amount; got != want {
+			t.Errorf("%s: amount = %s, want %s", url, got, want)
+		}
+	}
+
+	route.MinimumCharge = nil
+	reqs, err := buildPaymentRequirements(httptest.NewRequest("GET", "/render?quality=draft", nil), route, rule)
+	if err != nil {
+		t.Fatalf("buildPaymentRequirements() error = %v", err)
+	}
+	if got := reqs.Accepts[0].Amount; got != "700" {
+		t.Errorf("amount without minimum = %s, want 700", got)
+	}
+}
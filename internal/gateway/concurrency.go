@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// concurrencyLimiter caps the paid requests in flight to each route's backend.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	slots    map[string]chan struct{} // key: namespace/name
+	priority map[string]int           // queue token holders waiting for a slot, by route
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{slots: make(map[string]chan struct{}), priority: make(map[string]int)}
+}
+
+// routeSlots returns the semaphore of a route, replacing it when
+// maxConcurrent changed. Requests holding a slot of the old semaphore release
+// it there.
+func (l *concurrencyLimiter) routeSlots(route *routestore.CompiledRoute) chan struct{} {
+	key := route.Namespace + "/" + route.Name
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots := l.slots[key]
+	if slots == nil || cap(slots) != int(route.MaxConcurrent) {
+		slots = make(chan struct{}, route.MaxConcurrent)
+		l.slots[key] = slots
+	}
+	return slots
+}
+
+// acquire takes a backend slot for the route, waiting up to the route's
+// queue wait or until ctx is done. It returns the function that frees the
+// slot, or false when the route is at capacity. Routes without a cap always
+// succeed.
+func (l *concurrencyLimiter) acquire(ctx context.Context, route *routestore.CompiledRoute) (func(), bool) {
+	if route.MaxConcurrent <= 0 {
+		return func() {}, true
+	}
+	slots := l.routeSlots(route)
+	release := func() { <-slots }
+	if !l.prioritized(route) {
+		select {
+		case slots <- struct{}{}:
+			return release, true
+		default:
+		}
+	}
+	if route.QueueWait <= 0 {
+		return nil, false
+	}
+	return waitSlot(ctx, slots, route.QueueWait)
+}
+
+// acquirePriority takes a backend slot for a request holding a queue token,
+// waiting up to wait or until ctx is done. Requests without a token do not
+// take a free slot while one waits.
+func (l *concurrencyLimiter) acquirePriority(ctx context.Context, route *routestore.CompiledRoute, wait time.Duration) (func(), bool) {
+	key := route.Namespace + "/" + route.Name
+	slots := l.routeSlots(route)
+	l.mu.Lock()
+	l.priority[key]++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.priority[key]--
+		if l.priority[key] == 0 {
+			delete(l.priority, key)
+		}
+		l.mu.Unlock()
+	}()
+	return waitSlot(ctx, slots, wait)
+}
+
+// prioritized reports whether queue token holders wait for a slot of route.
+func (l *concurrencyLimiter) prioritized(route *routestore.CompiledRoute) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.priority[route.Namespace+"/"+route.Name] > 0
+}
+
+// waitSlot takes a slot of slots, waiting up to d or until ctx is done.
+func waitSlot(ctx context.Context, slots chan struct{}, d time.Duration) (func(), bool) {
+	release := func() { <-slots }
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return release, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return nil, false
+}
+
+// retryAfter is the Retry-After value of a request rejected for capacity.
+func retryAfter(route *routestore.CompiledRoute) string {
+	return strconv.Itoa(max(1, int(route.QueueWait/time.Second)))
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestConcurrencyLimiterAcquire(t *testing.T) {
+	l := newConcurrencyLimiter()
+	ctx := context.Background()
+	route := &routestore.CompiledRoute{Namespace: "default", Name: "gpu", MaxConcurrent: 1}
+
+	release, ok := l.acquire(ctx, route)
+	if !ok {
+		t.Fatal("first acquire failed")
+	}
+	if _, ok := l.acquire(ctx, route); ok {
+		t.Fatal("acquire over the cap succeeded")
+	}
+
+	// A queued request gets the slot once it is released.
+	route.QueueWait = time.Second
+	time.AfterFunc(20*time.Millisecond, release)
+	release, ok = l.acquire(ctx, route)
+	if !ok {
+		t.Fatal("queued acquire failed")
+	}
+
+	// The wait is bounded.
+	route.QueueWait = 20 * time.Millisecond
+	if _, ok := l.acquire(ctx, route); ok {
+		t.Fatal("acquire succeeded after the queue wait")
+	}
+	release()
+
+	// Unlimited routes always get a slot.
+	if _, ok := l.acquire(ctx, &routestore.CompiledRoute{Name: "free"}); !ok {
+		t.Error("acquire on an unlimited route failed")
+	}
+}
+
+func TestHandlerRejectsOverCapacityBeforeSettling(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+		io.WriteString(w, "ok")
+	}))
+	defer backendSrv.Close()
+
+	var settles atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		settles.Add(1)
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc","network":"eip155:84532"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "gpu", &routestore.CompiledRoute{
+		Name:           "gpu",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules:          []routestore.CompiledRule{{Path: "/infer", Price: "0.01", Mode: "all-pay"}},
+		Backends:       []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+		MaxConcurrent:  1,
+	})
+	h := NewHandler(store)
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`))
+	paid := func() *http.Request {
+		req := httptest.NewRequest("POST", "/infer", nil)
+		req.Header.Set("Payment-Signature", payload)
+		return req
+	}
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(first, paid())
+		close(done)
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, paid())
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("over capacity: status = %d, Retry-After = %q, want 503 and 1", w.Code, w.Header().Get("Retry-After"))
+	}
+	if n := settles.Load(); n != 1 {
+		t.Errorf("settlements = %d, want only the admitted request", n)
+	}
+
+	close(unblock)
+	<-done
+	if first.Code != http.StatusOK {
+		t.Errorf("admitted request status = %d, want 200", first.Code)
+	}
+
+	// The slot is free again once the first request is served.
+	go func() { <-entered }()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, paid())
+	if w.Code != http.StatusOK {
+		t.Errorf("status after release = %d, want 200", w.Code)
+	}
+}
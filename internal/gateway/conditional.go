@@ -0,0 +1,24 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// conditional reports whether r carries a validator the backend can answer
+// 304 Not Modified to. The validators reach the backend unchanged, as do
+// the ETag and Last-Modified of its responses.
+func conditional(r *http.Request) bool {
+	return r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != ""
+}
+
+// notModifiedAmount returns the atomic amount to settle for a 304 answer to
+// a conditional request: nothing, or the reduced price rounded by the
+// route's charge policy and capped at the authorized maximum.
+func notModifiedAmount(route *routestore.CompiledRoute, nm *routestore.CompiledNotModified, maxAmount string, decimals int) string {
+	if nm.Price.Sign() == 0 {
+		return "0"
+	}
+	return capAmount(chargeAmount(route, atomicCeil(nm.Price, decimals).String(), decimals), maxAmount)
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	free := &routestore.CompiledNotModified{Price: new(big.Rat)}
+	tests := []struct {
+		name        string
+		notModified *routestore.CompiledNotModified
+		ifNoneMatch string
+		wantStatus  int
+		wantCalls   string
+	}{
+		{name: "free 304", notModified: free, ifNoneMatch: `"v1"`, wantStatus: http.StatusNotModified, wantCalls: "backend"},
+		{name: "free changed", notModified: free, ifNoneMatch: `"v0"`, wantStatus: http.StatusOK, wantCalls: "backend settle"},
+		{name: "free unconditional", notModified: free, wantStatus: http.StatusOK, wantCalls: "settle backend"},
+		{name: "full 304", ifNoneMatch: `"v1"`, wantStatus: http.StatusNotModified, wantCalls: "settle backend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls []string
+			record := func(call string) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, call)
+			}
+			backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				record("backend")
+				w.Header().Set("ETag", `"v1"`)
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				io.WriteString(w, "data")
+			}))
+			defer backendSrv.Close()
+			facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/verify") {
+					io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+					return
+				}
+				record("settle")
+				io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+			}))
+			defer facilitator.Close()
+
+			store := routestore.New()
+			store.Set("default", "api", &routestore.CompiledRoute{
+				Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+				Rules:    []routestore.CompiledRule{{Path: "/api/*", Price: "0.01", Mode: "all-pay", Settle: settleSync, NotModified: tt.notModified}},
+				Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+			})
+			h := NewHandler(store)
+
+			r := httptest.NewRequest("GET", "/api/data", nil)
+			r.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"v1"` {
+				t.Errorf("ETag = %q, want the backend's", etag)
+			}
+			mu.Lock()
+			got := strings.Join(calls, " ")
+			mu.Unlock()
+			if got != tt.wantCalls {
+				t.Errorf("calls = %q, want %q", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNotModifiedAmount(t *testing.T) {
+	route := &routestore.CompiledRoute{}
+	reduced := &routestore.CompiledNotModified{Price: big.NewRat(1, 10000)}
+	if got := notModifiedAmount(route, reduced, "10000", 6); got != "100" {
+		t.Errorf("reduced amount = %s, want 100", got)
+	}
+	if got := notModifiedAmount(route, reduced, "50", 6); got != "50" {
+		t.Errorf("reduced amount = %s, want the cap 50", got)
+	}
+	if got := notModifiedAmount(route, &routestore.CompiledNotModified{Price: new(big.Rat)}, "10000", 6); got != "0" {
+		t.Errorf("free amount = %s, want 0", got)
+	}
+}
@@ -0,0 +1,133 @@
+package gateway
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+)
+
+// ConnLimits keeps clients that open many connections, or hold them with
+// slow requests, from exhausting the connections paying clients need.
+type ConnLimits struct {
+	// MaxPerIP caps the open connections of each client IP address. A
+	// connection over the cap is closed as soon as it is accepted. 0 removes
+	// the cap.
+	MaxPerIP int
+	// Exempt lists the client networks the cap does not apply to, such as
+	// the pods of an Ingress controller that forwards every client over a
+	// few addresses.
+	Exempt []netip.Prefix
+	// ReadHeaderTimeout bounds the time to read a request's headers, so a
+	// client trickling headers loses its connection before the body timeout.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds the time to read a whole request, body included.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds the time to write a response, for clients that
+	// read it slowly.
+	WriteTimeout time.Duration
+	// IdleTimeout bounds the time a kept-alive connection waits for its
+	// next request.
+	IdleTimeout time.Duration
+}
+
+// DefaultConnLimits are the limits of a gateway without SetConnLimits.
+var DefaultConnLimits = ConnLimits{
+	ReadHeaderTimeout: 5 * time.Second,
+	ReadTimeout:       15 * time.Second,
+	WriteTimeout:      30 * time.Second,
+	IdleTimeout:       60 * time.Second,
+}
+
+// ipConnLimiter counts the open connections of each client IP address.
+type ipConnLimiter struct {
+	max    int
+	exempt []netip.Prefix
+
+	mu    sync.Mutex
+	conns map[netip.Addr]int
+}
+
+func newIPConnLimiter(max int, exempt []netip.Prefix) *ipConnLimiter {
+	return &ipConnLimiter{max: max, exempt: exempt, conns: make(map[netip.Addr]int)}
+}
+
+// acquire reports whether ip may open another connection, counting it if so.
+// Exempt addresses are not counted.
+func (l *ipConnLimiter) acquire(ip netip.Addr) (counted, ok bool) {
+	for _, p := range l.exempt {
+		if p.Contains(ip) {
+			return false, true
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[ip] >= l.max {
+		return false, false
+	}
+	l.conns[ip]++
+	return true, true
+}
+
+func (l *ipConnLimiter) release(ip netip.Addr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[ip] <= 1 {
+		delete(l.conns, ip)
+		return
+	}
+	l.conns[ip]--
+}
+
+// connLimitListener closes accepted connections of clients over the limit of
+// its limiter.
+type connLimitListener struct {
+	net.Listener
+	limiter *ipConnLimiter
+}
+
+func (l *connLimitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		ip := remoteAddrIP(c.RemoteAddr())
+		counted, ok := l.limiter.acquire(ip)
+		if !ok {
+			metrics.RejectedConnectionsTotal.WithLabelValues("per_ip_limit").Inc()
+			c.Close()
+			continue
+		}
+		if !counted {
+			return c, nil
+		}
+		return &limitedConn{Conn: c, release: sync.OnceFunc(func() { l.limiter.release(ip) })}, nil
+	}
+}
+
+// limitedConn releases its slot in the limiter when it is closed.
+type limitedConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	c.release()
+	return c.Conn.Close()
+}
+
+// remoteAddrIP returns the IP address of a connection's remote address, with
+// IPv4-mapped IPv6 addresses unmapped so both forms share one count.
+func remoteAddrIP(addr net.Addr) netip.Addr {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.AddrPort().Addr().Unmap()
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ap.Addr().Unmap()
+}
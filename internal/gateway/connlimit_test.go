@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIPConnLimiter(t *testing.T) {
+	l := newIPConnLimiter(2, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	client := netip.MustParseAddr("203.0.113.7")
+
+	for i := range 2 {
+		if _, ok := l.acquire(client); !ok {
+			t.Fatalf("connection %d rejected under the cap", i+1)
+		}
+	}
+	if _, ok := l.acquire(client); ok {
+		t.Fatal("third connection accepted over the cap")
+	}
+	if _, ok := l.acquire(netip.MustParseAddr("203.0.113.8")); !ok {
+		t.Error("another client rejected")
+	}
+	l.release(client)
+	if _, ok := l.acquire(client); !ok {
+		t.Error("connection rejected after a release")
+	}
+
+	for range 5 {
+		if counted, ok := l.acquire(netip.MustParseAddr("10.1.2.3")); counted || !ok {
+			t.Fatalf("exempt client: counted = %v, ok = %v", counted, ok)
+		}
+	}
+}
+
+func TestConnLimitListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go srv.Serve(&connLimitListener{Listener: ln, limiter: newIPConnLimiter(1, nil)})
+	defer srv.Close()
+
+	first, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(first), nil)
+	if err != nil {
+		t.Fatalf("first connection: %v", err)
+	}
+	resp.Body.Close()
+
+	second, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("second connection read = %v, want EOF", err)
+	}
+
+	// Closing the first connection frees the client's slot.
+	first.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("slot not released: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	s, err := NewServer("127.0.0.1:0", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := DefaultConnLimits
+	limits.ReadHeaderTimeout = 50 * time.Millisecond
+	s.SetConnLimits(limits)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.srv.Serve(ln)
+	defer s.srv.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// A client trickling its headers is cut off by the header timeout,
+	// well before the body timeout.
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(limits.ReadTimeout / 2))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("connection not closed by the header timeout: %v", err)
+	}
+}
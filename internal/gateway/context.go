@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// contextTokenTTL is the lifetime of a signed payment context; it only has to
+// outlive the proxied request.
+const contextTokenTTL = time.Minute
+
+// signContext attaches a signed X-402-Context header describing the payment
+// to the request forwarded to the backend. The amount is the one the client
+// accepted; settlement says whether it was charged or only authorized.
+func (h *Handler) signContext(r *http.Request, route *routestore.CompiledRoute, accept *paymentAccept, settlement, path string, settled *settleResponse) error {
+	now := time.Now()
+	token, err := backend.SignContext(r.Context(), h.contextKeys, backend.Claims{
+		Issuer:      backend.Issuer,
+		Payer:       settled.Payer,
+		Amount:      accept.Amount,
+		Asset:       accept.Asset,
+		Settlement:  settlement,
+		Network:     accept.Network,
+		Transaction: settled.Transaction,
+		Route:       route.Namespace + "/" + route.Name,
+		Path:        path,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(contextTokenTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	r.Header.Set(backend.HeaderContext, token)
+	return nil
+}
+
+// serveJWKS publishes the public context signing keys so backends can verify
+// X-402-Context without holding a shared secret.
+func (h *Handler) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	if h.contextKeys == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=300")
+	json.NewEncoder(w).Encode(h.contextKeys.JWKS())
+}
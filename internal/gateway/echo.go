@@ -0,0 +1,147 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEchoBackendAddr is the loopback address the echo backend listens on
+// unless told otherwise. X402Routes reach it with sidecar.port.
+const DefaultEchoBackendAddr = "127.0.0.1:8405"
+
+// Limits of the knobs of echo requests, so a soak test cannot tie the
+// gateway's pod up.
+const (
+	maxEchoDelay = time.Minute
+	maxEchoBytes = 10 << 20
+)
+
+// EchoBackend is a built-in backend that answers every request with a JSON
+// description of what reached it, so a soak test covers the whole data path
+// of a single gateway pod without an application behind it. Query
+// parameters shape the answer:
+//
+//   - status: the status code, 200 by default
+//   - delay: how long to wait before answering, such as 250ms
+//   - bytes: filler bytes added to the answer
+type EchoBackend struct {
+	addr string
+	srv  *http.Server
+}
+
+// echoResponse is the answer of the echo backend.
+type echoResponse struct {
+	Method    string      `json:"method"`
+	Path      string      `json:"path"`
+	Query     string      `json:"query,omitempty"`
+	Headers   http.Header `json:"headers"`
+	BodyBytes int64       `json:"bodyBytes"`
+	Paid      bool        `json:"paid"` // the gateway attached an X-402-Context
+	Filler    string      `json:"filler,omitempty"`
+}
+
+// NewEchoBackend returns an echo backend listening on addr, typically
+// DefaultEchoBackendAddr.
+func NewEchoBackend(addr string) *EchoBackend {
+	e := &EchoBackend{addr: addr}
+	e.srv = &http.Server{
+		Addr:              addr,
+		Handler:           e,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return e
+}
+
+// ServeHTTP answers r with its description.
+func (e *EchoBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := http.StatusOK
+	if v := query.Get("status"); v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil || code < 200 || code > 599 {
+			http.Error(w, fmt.Sprintf("invalid status %q", v), http.StatusBadRequest)
+			return
+		}
+		status = code
+	}
+	var delay time.Duration
+	if v := query.Get("delay"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 || d > maxEchoDelay {
+			http.Error(w, fmt.Sprintf("invalid delay %q, want up to %v", v, maxEchoDelay), http.StatusBadRequest)
+			return
+		}
+		delay = d
+	}
+	var filler int
+	if v := query.Get("bytes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxEchoBytes {
+			http.Error(w, fmt.Sprintf("invalid bytes %q, want up to %d", v, maxEchoBytes), http.StatusBadRequest)
+			return
+		}
+		filler = n
+	}
+
+	bodyBytes, _ := io.Copy(io.Discard, r.Body)
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	resp := echoResponse{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     r.URL.RawQuery,
+		Headers:   r.Header,
+		BodyBytes: bodyBytes,
+		Paid:      r.Header.Get("X-402-Context") != "",
+	}
+	if filler > 0 {
+		resp.Filler = strings.Repeat("x", filler)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Start serves the echo backend until ctx is canceled. It implements
+// manager.Runnable.
+func (e *EchoBackend) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", e.addr)
+	if err != nil {
+		return fmt.Errorf("echo backend failed: %w", err)
+	}
+	slog.Info("starting echo backend", "addr", e.addr)
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := e.srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("echo backend graceful shutdown failed", "error", err)
+		}
+	}()
+
+	if err := e.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("echo backend failed: %w", err)
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every gateway
+// replica serves its own echo backend.
+func (e *EchoBackend) NeedLeaderElection() bool {
+	return false
+}
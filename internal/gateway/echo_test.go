@@ -0,0 +1,93 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/gateway/gatewaytest"
+)
+
+func TestEchoBackend(t *testing.T) {
+	e := NewEchoBackend(DefaultEchoBackendAddr)
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantFiller int
+	}{
+		{name: "plain", target: "/api/data?id=7", wantStatus: http.StatusOK},
+		{name: "status", target: "/api/data?status=503", wantStatus: http.StatusServiceUnavailable},
+		{name: "filler", target: "/api/data?bytes=1024", wantStatus: http.StatusOK, wantFiller: 1024},
+		{name: "delay", target: "/api/data?delay=10ms", wantStatus: http.StatusOK},
+		{name: "invalid status", target: "/api/data?status=42", wantStatus: http.StatusBadRequest},
+		{name: "delay over the limit", target: "/api/data?delay=2h", wantStatus: http.StatusBadRequest},
+		{name: "bytes over the limit", target: "/api/data?bytes=1000000000", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.target, strings.NewReader("hello"))
+			r.Header.Set("X-402-Context", "token")
+			w := gatewaytest.Serve(e, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code == http.StatusBadRequest {
+				return
+			}
+			var got echoResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("answer is not JSON: %v", err)
+			}
+			if got.Method != "POST" || got.Path != "/api/data" || got.BodyBytes != 5 || !got.Paid {
+				t.Errorf("answer = %+v, want the POST to /api/data with 5 bytes and a context", got)
+			}
+			if len(got.Filler) != tt.wantFiller {
+				t.Errorf("filler = %d bytes, want %d", len(got.Filler), tt.wantFiller)
+			}
+		})
+	}
+}
+
+func TestEchoBackendBehindGateway(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	e := NewEchoBackend(addr)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Start(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	}()
+
+	facilitator := gatewaytest.NewFacilitator(t)
+	h := NewHandler(gatewaytest.Store(gatewaytest.Route("api", facilitator, "http://"+addr, gatewaytest.PaidRule("/api/*", "0.01"))))
+	var w *httptest.ResponseRecorder
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if w = gatewaytest.Serve(h, gatewaytest.PaidRequest("GET", "/api/data")); w.Code != http.StatusBadGateway {
+			break
+		}
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var got echoResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Path != "/api/data" {
+		t.Errorf("answer = %s, want the echo of /api/data", w.Body)
+	}
+	if len(facilitator.Calls("/settle")) != 1 {
+		t.Errorf("settlements = %d, want 1", len(facilitator.Calls("/settle")))
+	}
+}
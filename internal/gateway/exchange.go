@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// fiatPrice matches prices given in a fiat currency, e.g. "$0.01 USD" or
+// "0.01 EUR".
+var fiatPrice = regexp.MustCompile(`^[$€£]?\s*([0-9]+(?:\.[0-9]+)?)\s+([A-Z]{3})$`)
+
+// exchangeRates converts fiat prices; nil until EnableExchangeRates is called.
+var exchangeRates *ExchangeRates
+
+// ExchangeRates fetches and caches token exchange rates from a provider.
+//
+// The provider is queried with GET <url>?currency=USD&asset=USDC and answers
+// {"rate": "1.0002"}, the value of one token in the fiat currency.
+type ExchangeRates struct {
+	url     string
+	refresh time.Duration // age after which a rate is re-fetched
+	maxAge  time.Duration // age after which a rate may no longer be used
+	client  *http.Client
+
+	mu       sync.Mutex
+	rates    map[string]exchangeRate // key: "USD/USDC"
+	fetching map[string]bool
+}
+
+type exchangeRate struct {
+	value   *big.Rat
+	fetched time.Time
+}
+
+// NewExchangeRates returns a rate cache for the provider at url.
+func NewExchangeRates(url string, refresh, maxAge time.Duration) *ExchangeRates {
+	return &ExchangeRates{
+		url:      url,
+		refresh:  refresh,
+		maxAge:   maxAge,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		rates:    make(map[string]exchangeRate),
+		fetching: make(map[string]bool),
+	}
+}
+
+// rate returns the value of one asset token in currency. A rate older than the
+// refresh interval is re-fetched; if that fails, it is still used until it
+// exceeds the maximum age.
+func (e *ExchangeRates) rate(currency, asset string) (*big.Rat, error) {
+	key := currency + "/" + asset
+
+	e.mu.Lock()
+	cached, ok := e.rates[key]
+	fresh := ok && time.Since(cached.fetched) < e.refresh
+	// One request refreshes a stale rate while the others keep using it.
+	if fresh || (ok && e.fetching[key]) {
+		e.mu.Unlock()
+		return e.use(currency, asset, cached), nil
+	}
+	e.fetching[key] = true
+	e.mu.Unlock()
+
+	value, err := e.fetch(currency, asset)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.fetching, key)
+	if err != nil {
+		metrics.ExchangeRateFetchErrorsTotal.WithLabelValues(currency, asset).Inc()
+		if ok && time.Since(cached.fetched) < e.maxAge {
+			slog.Warn("exchange rate refresh failed, using cached rate", "currency", currency, "asset", asset,
+				"age", time.Since(cached.fetched).Round(time.Second), "error", err)
+			return e.use(currency, asset, cached), nil
+		}
+		return nil, fmt.Errorf("exchange rate %s: %w", key, err)
+	}
+	cached = exchangeRate{value: value, fetched: time.Now()}
+	e.rates[key] = cached
+	return e.use(currency, asset, cached), nil
+}
+
+// use records the age of the rate being served.
+func (e *ExchangeRates) use(currency, asset string, r exchangeRate) *big.Rat {
+	metrics.ExchangeRateAgeSeconds.WithLabelValues(currency, asset).Set(time.Since(r.fetched).Seconds())
+	return r.value
+}
+
+func (e *ExchangeRates) fetch(currency, asset string) (*big.Rat, error) {
+	u, err := url.Parse(e.url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("currency", currency)
+	q.Set("asset", asset)
+	u.RawQuery = q.Encode()
+
+	resp, err := e.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Rate json.Number `json:"rate"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode provider response: %w", err)
+	}
+	value, ok := new(big.Rat).SetString(body.Rate.String())
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid rate %q", body.Rate)
+	}
+	return value, nil
+}
+
+// isFiatPrice reports whether a price names a fiat currency. It is a cheap
+// pre-check for fiatPrice.
+func isFiatPrice(price string) bool {
+	return price != "" && price[len(price)-1] >= 'A' && price[len(price)-1] <= 'Z'
+}
+
+// hasFiatPrice reports whether any price of the rule is given in fiat. Such
+// responses depend on the current rate and are not cached.
+func hasFiatPrice(rule *routestore.CompiledRule) bool {
+	if isFiatPrice(rule.Price) {
+		return true
+	}
+	for _, offer := range rule.Offers {
+		if isFiatPrice(offer.Price) {
+			return true
+		}
+	}
+	for _, mod := range rule.Modifiers {
+		if isFiatPrice(mod.Price) {
+			return true
+		}
+	}
+	return false
+}
+
+// priceToAtomicUnits converts a token price or a fiat price to atomic units of
+// the asset. Fiat amounts are converted at the current rate and rounded up to
+// the token's precision.
+func priceToAtomicUnits(price string, info assetInfo) (string, error) {
+	if !isFiatPrice(price) {
+		return humanToAtomicUnits(price, info.Decimals)
+	}
+	m := fiatPrice.FindStringSubmatch(strings.TrimSpace(price))
+	if m == nil {
+		return "", fmt.Errorf("invalid fiat price format: %q", price)
+	}
+	if exchangeRates == nil {
+		return "", fmt.Errorf("price %q is in %s but no exchange-rate provider is configured", price, m[2])
+	}
+	rate, err := exchangeRates.rate(m[2], info.Name)
+	if err != nil {
+		return "", err
+	}
+
+	amount, _ := new(big.Rat).SetString(m[1])
+	amount.Quo(amount, rate)
+	amount.Mul(amount, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(info.Decimals)), nil)))
+
+	// Round up so a payment never falls short of the fiat price.
+	atomic, rem := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+	if rem.Sign() > 0 {
+		atomic.Add(atomic, big.NewInt(1))
+	}
+	return atomic.String(), nil
+}
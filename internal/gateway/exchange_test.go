@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPriceToAtomicUnits(t *testing.T) {
+	var rate atomic.Value
+	rate.Store(`{"rate":"1"}`)
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("currency") != "USD" || r.URL.Query().Get("asset") != "USDC" {
+			http.Error(w, "unknown pair", http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, rate.Load().(string))
+	}))
+	defer provider.Close()
+
+	usdc := assetInfo{Name: "USDC", Version: "2", Decimals: 6}
+	tests := []struct {
+		name    string
+		price   string
+		rate    string
+		want    string
+		wantErr bool
+	}{
+		{name: "token price", price: "0.001", want: "1000"},
+		{name: "fiat at par", price: "$0.01 USD", rate: `{"rate":"1"}`, want: "10000"},
+		{name: "fiat without symbol", price: "0.01 USD", rate: `{"rate":0.5}`, want: "20000"},
+		{name: "rounds up", price: "$0.01 USD", rate: `{"rate":"3"}`, want: "3334"},
+		{name: "unknown currency", price: "0.01 EUR", rate: `{"rate":"1"}`, wantErr: true},
+		{name: "invalid fiat format", price: "$0.01USD", wantErr: true},
+	}
+
+	exchangeRates = NewExchangeRates(provider.URL, 0, 0)
+	defer func() { exchangeRates = nil }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.rate != "" {
+				rate.Store(tt.rate)
+			}
+			got, err := priceToAtomicUnits(tt.price, usdc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("priceToAtomicUnits(%q) error = %v, wantErr %v", tt.price, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("priceToAtomicUnits(%q) = %q, want %q", tt.price, got, tt.want)
+			}
+		})
+	}
+
+	exchangeRates = nil
+	if _, err := priceToAtomicUnits("$0.01 USD", usdc); err == nil {
+		t.Error("priceToAtomicUnits() without provider succeeded, want error")
+	}
+}
+
+func TestExchangeRatesStaleness(t *testing.T) {
+	var failing atomic.Bool
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"rate":"1"}`)
+	}))
+	defer provider.Close()
+
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		wantErr bool
+	}{
+		{name: "within max age", maxAge: time.Hour},
+		{name: "beyond max age", maxAge: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing.Store(false)
+			rates := NewExchangeRates(provider.URL, 0, tt.maxAge)
+			if _, err := rates.rate("USD", "USDC"); err != nil {
+				t.Fatalf("rate() error = %v", err)
+			}
+			failing.Store(true)
+			if _, err := rates.rate("USD", "USDC"); (err != nil) != tt.wantErr {
+				t.Errorf("rate() after provider failure error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
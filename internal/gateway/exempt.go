@@ -0,0 +1,39 @@
+package gateway
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// exemption returns the exemption of route that r matches: its method or the
+// exempted path, or "" when it must be paid for as usual.
+func exemption(route *routestore.CompiledRoute, r *http.Request, path string) string {
+	e := route.Exemptions
+	if e == nil {
+		return ""
+	}
+	if slices.Contains(e.Methods, r.Method) {
+		return r.Method
+	}
+	for _, p := range e.Paths {
+		prefix, isPrefix := strings.CutSuffix(p, "*")
+		if p == path || isPrefix && strings.HasPrefix(path, prefix) {
+			return p
+		}
+	}
+	return ""
+}
+
+// exempt marks a request forwarded without payment by the exemption of its
+// route.
+func exempt(req *request, exemption string) {
+	slog.Debug("exempt request, forwarding", "method", req.r.Method, "path", req.path, "route", req.route.Name, "exemption", exemption)
+	metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), req.route.Namespace, req.route.Name, "exempt").Inc()
+	metrics.ExemptedRequestsTotal.WithLabelValues(req.route.Namespace, req.route.Name, exemption).Inc()
+	req.free = "exempt"
+}
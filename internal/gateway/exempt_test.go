@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestHandlerExemptions(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backend:"+r.Method+" "+r.URL.Path)
+	}))
+	defer backend.Close()
+
+	builtIn := &routestore.CompiledExemptions{
+		Methods: []string{http.MethodOptions, http.MethodHead},
+		Paths:   []string{"/robots.txt", "/favicon.ico", "/.well-known/*"},
+	}
+	tests := []struct {
+		name          string
+		exemptions    *routestore.CompiledExemptions
+		method        string
+		path          string
+		wantStatus    int
+		wantExemption string
+	}{
+		{name: "CORS preflight on a paid path", exemptions: builtIn, method: http.MethodOptions, path: "/api/data", wantStatus: http.StatusOK, wantExemption: "OPTIONS"},
+		{name: "HEAD on a paid path", exemptions: builtIn, method: http.MethodHead, path: "/api/data", wantStatus: http.StatusOK, wantExemption: "HEAD"},
+		{name: "robots.txt under a paid rule", exemptions: builtIn, method: http.MethodGet, path: "/robots.txt", wantStatus: http.StatusOK, wantExemption: "/robots.txt"},
+		{name: "well-known prefix", exemptions: builtIn, method: http.MethodGet, path: "/.well-known/security.txt", wantStatus: http.StatusOK, wantExemption: "/.well-known/*"},
+		{name: "unmatched favicon", exemptions: builtIn, method: http.MethodGet, path: "/favicon.ico", wantStatus: http.StatusOK, wantExemption: "/favicon.ico"},
+		{name: "GET on a paid path", exemptions: builtIn, method: http.MethodGet, path: "/api/data", wantStatus: http.StatusPaymentRequired},
+		{name: "prefix needs the slash", exemptions: builtIn, method: http.MethodGet, path: "/.well-known", wantStatus: http.StatusPaymentRequired},
+		{name: "no exemptions", method: http.MethodOptions, path: "/api/data", wantStatus: http.StatusPaymentRequired},
+		{name: "unmatched without exemptions", method: http.MethodGet, path: "/favicon.ico", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := routestore.New()
+			rules := []routestore.CompiledRule{{Path: "/api/*", Price: "0.001", Mode: "all-pay"}}
+			if tt.path != "/favicon.ico" {
+				rules = append(rules, routestore.CompiledRule{Path: "/*", Price: "0.001", Mode: "all-pay"})
+			}
+			store.Set("default", "exempt-api", &routestore.CompiledRoute{
+				Name:       "exempt-api",
+				Namespace:  "default",
+				Wallet:     "0xTestWallet",
+				Network:    "base-sepolia",
+				Rules:      rules,
+				Backends:   []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}},
+				Unmatched:  "404",
+				Exemptions: tt.exemptions,
+			})
+			var before float64
+			if tt.wantExemption != "" {
+				before = testutil.ToFloat64(metrics.ExemptedRequestsTotal.WithLabelValues("default", "exempt-api", tt.wantExemption))
+			}
+
+			w := httptest.NewRecorder()
+			NewHandler(store).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantExemption == "" {
+				return
+			}
+			if got := testutil.ToFloat64(metrics.ExemptedRequestsTotal.WithLabelValues("default", "exempt-api", tt.wantExemption)) - before; got != 1 {
+				t.Errorf("x402_exempted_requests_total{exemption=%q} grew by %v, want 1", tt.wantExemption, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// experimentRule returns the rule priced for the experiment variant the
+// client of r is bucketed into, and the variant's name. Clients outside every
+// variant get the rule itself as the control.
+func experimentRule(r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule) (*routestore.CompiledRule, string) {
+	bucket := experimentBucket(route, rule, clientAddress(r))
+	for i := range rule.Experiments {
+		e := &rule.Experiments[i]
+		if bucket < e.Percent {
+			priced := *rule
+			priced.Price = e.Price
+			return &priced, e.Name
+		}
+		bucket -= e.Percent
+	}
+	return rule, routestore.ExperimentControl
+}
+
+// experimentBucket maps a client to one of 100 buckets. The route and rule
+// are part of the hash, so each rule buckets clients independently and a
+// client keeps its bucket across requests and gateway replicas.
+func experimentBucket(route *routestore.CompiledRoute, rule *routestore.CompiledRule, client string) int32 {
+	h := fnv.New32a()
+	for _, s := range []string{route.Namespace, route.Name, rule.Path, client} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return int32(h.Sum32() % 100)
+}
+
+// clientAddress identifies the client of r: the address the ingress
+// controller reports in X-Real-IP, else the last X-Forwarded-For hop, else
+// the peer address.
+func clientAddress(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(xff[len(xff)-1], ",")
+		if hop := strings.TrimSpace(hops[len(hops)-1]); hop != "" {
+			return hop
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// countExperiment counts a 402 issued or a payment settled for the variant of
+// req, with the amount settled in tokens.
+func countExperiment(req *request, result string, tokens float64) {
+	if req.variant == "" || req.probe {
+		return
+	}
+	route, path := req.route, pathLabel(req.rule, req.path)
+	metrics.ExperimentRequestsTotal.WithLabelValues(route.Namespace, route.Name, path, req.variant, result).Inc()
+	if tokens > 0 {
+		metrics.ExperimentRevenueTotal.WithLabelValues(route.Namespace, route.Name, path, req.variant).Add(tokens)
+	}
+}
@@ -0,0 +1,151 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestExperimentRule(t *testing.T) {
+	route := &routestore.CompiledRoute{Namespace: "default", Name: "my-api"}
+	rule := &routestore.CompiledRule{
+		Path:  "/api/*",
+		Price: "0.001",
+		Experiments: []routestore.CompiledExperiment{
+			{Name: "discount", Price: "0.0005", Percent: 20},
+			{Name: "premium", Price: "0.002", Percent: 30},
+		},
+	}
+
+	counts := map[string]int{}
+	for i := range 10000 {
+		r := httptest.NewRequest("GET", "/api/data", nil)
+		r.Header.Set("X-Real-IP", fmt.Sprintf("10.%d.%d.1", i/256, i%256))
+		priced, variant := experimentRule(r, route, rule)
+		counts[variant]++
+
+		wantPrice := map[string]string{"discount": "0.0005", "premium": "0.002", "control": "0.001"}[variant]
+		if priced.Price != wantPrice {
+			t.Fatalf("variant %s priced %q, want %q", variant, priced.Price, wantPrice)
+		}
+		if again, _ := experimentRule(r, route, rule); again.Price != priced.Price {
+			t.Fatalf("client %s changed variant", r.Header.Get("X-Real-IP"))
+		}
+	}
+	if rule.Price != "0.001" {
+		t.Errorf("rule price changed to %q", rule.Price)
+	}
+	for variant, want := range map[string]int{"discount": 2000, "premium": 3000, "control": 5000} {
+		if got := counts[variant]; got < want-300 || got > want+300 {
+			t.Errorf("%s clients = %d, want about %d", variant, got, want)
+		}
+	}
+}
+
+func TestClientAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "peer address", want: "192.0.2.1"},
+		{name: "real ip", headers: map[string]string{"X-Real-IP": "203.0.113.7", "X-Forwarded-For": "198.51.100.1"}, want: "203.0.113.7"},
+		{name: "last forwarded hop", headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.9"}, want: "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := clientAddress(r); got != tt.want {
+				t.Errorf("clientAddress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerPriceExperiments(t *testing.T) {
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer backendSrv.Close()
+	var settledAmount atomic.Value
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PaymentRequirements paymentAccept `json:"paymentRequirements"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		settledAmount.Store(req.PaymentRequirements.Amount)
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("experiments", "my-api", &routestore.CompiledRoute{
+		Name:           "my-api",
+		Namespace:      "experiments",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules: []routestore.CompiledRule{{
+			Path:        "/api/*",
+			Price:       "0.001",
+			Mode:        "all-pay",
+			Experiments: []routestore.CompiledExperiment{{Name: "discount", Price: "0.0005", Percent: 100}},
+		}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	})
+	h := NewHandler(store)
+	sink := &recordingSink{}
+	h.settlementSinks = []SettlementSink{sink}
+
+	// Unpaid requests are quoted the variant price.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+	var reqs paymentRequirements
+	if err := json.Unmarshal(w.Body.Bytes(), &reqs); err != nil {
+		t.Fatalf("unmarshal 402 body: %v", err)
+	}
+	if reqs.Accepts[0].Amount != "500" {
+		t.Fatalf("quoted amount = %s, want 500", reqs.Accepts[0].Amount)
+	}
+
+	req := httptest.NewRequest("GET", "/api/data", nil)
+	req.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := settledAmount.Load(); got != "500" {
+		t.Errorf("settled amount = %v, want 500", got)
+	}
+	if len(sink.records) != 1 || sink.records[0].Variant != "discount" {
+		t.Errorf("records = %+v, want one tagged with the discount variant", sink.records)
+	}
+
+	issued := metrics.ExperimentRequestsTotal.WithLabelValues("experiments", "my-api", "/api/*", "discount", "payment_required")
+	paid := metrics.ExperimentRequestsTotal.WithLabelValues("experiments", "my-api", "/api/*", "discount", "paid")
+	if testutil.ToFloat64(issued) != 1 || testutil.ToFloat64(paid) != 1 {
+		t.Errorf("experiment requests = %v issued, %v paid, want 1 each", testutil.ToFloat64(issued), testutil.ToFloat64(paid))
+	}
+	if got := testutil.ToFloat64(metrics.ExperimentRevenueTotal.WithLabelValues("experiments", "my-api", "/api/*", "discount")); got != 0.0005 {
+		t.Errorf("experiment revenue = %v, want 0.0005", got)
+	}
+}
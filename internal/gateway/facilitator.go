@@ -0,0 +1,239 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+)
+
+// Facilitator types of a route.
+const (
+	facilitatorCoinbase = "coinbase"
+	facilitatorX402Org  = "x402.org"
+	facilitatorCustom   = "custom"
+)
+
+// facilitatorProvider absorbs how one facilitator vendor answers /verify and
+// /settle, so the payment flow only sees the x402 schema.
+type facilitatorProvider struct {
+	name string
+	// rejectionBodies means 4xx answers carry a regular verify or settle
+	// body explaining the rejection.
+	rejectionBodies bool
+	// normalize rewrites an answer into the x402 schema; nil when it already is.
+	normalize func(fields map[string]json.RawMessage)
+}
+
+// facilitatorProviders are the known vendors by facilitator type.
+var facilitatorProviders = map[string]*facilitatorProvider{
+	// The CDP facilitator rejects requests with an error object:
+	// {"errorType": "...", "errorMessage": "..."}.
+	facilitatorCoinbase: {name: facilitatorCoinbase, rejectionBodies: true, normalize: coinbaseErrors},
+	// x402.org answers invalid payments with 400 and a regular body.
+	facilitatorX402Org: {name: facilitatorX402Org, rejectionBodies: true},
+	// Self-hosted facilitators vary; accept snake_case fields and 4xx bodies.
+	facilitatorCustom: {name: facilitatorCustom, rejectionBodies: true, normalize: snakeCaseFields},
+}
+
+// facilitatorSecrets reads the facilitator credentials of routes; nil when
+// Vault is not configured.
+var facilitatorSecrets *vault.Secrets
+
+// setFacilitatorAuth adds the facilitator credential of auth, when set, to a
+// facilitator request.
+func setFacilitatorAuth(req *http.Request, auth *routestore.CompiledFacilitatorAuth) error {
+	if auth == nil {
+		return nil
+	}
+	if facilitatorSecrets == nil {
+		return errors.New("facilitator credential needs Vault; set VAULT_ADDR on the operator")
+	}
+	value, err := facilitatorSecrets.Value(req.Context(), auth.VaultPath, auth.VaultKey)
+	if err != nil {
+		return fmt.Errorf("read facilitator credential: %w", err)
+	}
+	req.Header.Set(auth.Header, auth.Prefix+value)
+	return nil
+}
+
+// providerFor returns the provider of facilitatorURL, a facilitator of
+// route: the route's compiled facilitator type, or the one the URL points at.
+func providerFor(route *routestore.CompiledRoute, facilitatorURL string) *facilitatorProvider {
+	if p, ok := facilitatorProviders[route.FacilitatorType]; ok {
+		return p
+	}
+	return facilitatorProviders[detectFacilitatorType(facilitatorURL)]
+}
+
+// facilitatorCooldown is how long a facilitator that failed is tried last by
+// routes that select healthy facilitators.
+const facilitatorCooldown = 30 * time.Second
+
+// facilitatorHealth records the facilitators that failed recently.
+var facilitatorHealth = &healthTracker{failedAt: make(map[string]time.Time)}
+
+// healthTracker records when facilitators last failed.
+type healthTracker struct {
+	mu       sync.Mutex
+	failedAt map[string]time.Time
+}
+
+// failed records a call to facilitatorURL that failed with err, if the
+// facilitator itself failed rather than rejecting the payment.
+func (t *healthTracker) failed(facilitatorURL string, err error) {
+	var facErr *facilitatorError
+	if !errors.As(err, &facErr) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failedAt[facilitatorURL] = time.Now()
+}
+
+// succeeded records a call to facilitatorURL that it answered.
+func (t *healthTracker) succeeded(facilitatorURL string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failedAt, facilitatorURL)
+}
+
+// unhealthy returns the facilitators of urls that failed within the cooldown.
+func (t *healthTracker) unhealthy(urls []string) map[string]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	unhealthy := make(map[string]bool)
+	for _, u := range urls {
+		if failedAt, ok := t.failedAt[u]; ok && time.Since(failedAt) < facilitatorCooldown {
+			unhealthy[u] = true
+		}
+	}
+	return unhealthy
+}
+
+// facilitatorOrder returns the facilitators of route in the order a payment
+// tries them: as listed, or with those that failed recently last when the
+// route selects healthy facilitators.
+func facilitatorOrder(route *routestore.CompiledRoute) []string {
+	if len(route.FailoverURLs) == 0 {
+		return []string{route.FacilitatorURL}
+	}
+	urls := append([]string{route.FacilitatorURL}, route.FailoverURLs...)
+	if !route.FacilitatorHealthy {
+		return urls
+	}
+	unhealthy := facilitatorHealth.unhealthy(urls)
+	ordered := make([]string, 0, len(urls))
+	for _, last := range []bool{false, true} {
+		for _, u := range urls {
+			if unhealthy[u] == last {
+				ordered = append(ordered, u)
+			}
+		}
+	}
+	return ordered
+}
+
+// detectFacilitatorType maps a facilitator URL to the vendor serving it.
+func detectFacilitatorType(facilitatorURL string) string {
+	u, err := url.Parse(facilitatorURL)
+	if err != nil {
+		return facilitatorCustom
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "coinbase.com" || strings.HasSuffix(host, ".coinbase.com"):
+		return facilitatorCoinbase
+	case host == "x402.org" || strings.HasSuffix(host, ".x402.org"):
+		return facilitatorX402Org
+	}
+	return facilitatorCustom
+}
+
+// decode reads a facilitator answer into out. 5xx and 429 answers are
+// facilitator failures. Other non-200 answers reject the payment; when the
+// vendor explains rejections in the body, it is decoded too and rejected
+// reports true.
+func (p *facilitatorProvider) decode(endpoint string, status int, body []byte, out any) (rejected bool, err error) {
+	ok := status == http.StatusOK
+	if !ok && (!p.rejectionBodies || status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || status < http.StatusBadRequest) {
+		return false, facilitatorStatusError(endpoint, status, body)
+	}
+	if err := p.unmarshal(body, out); err != nil {
+		if ok {
+			return false, &facilitatorError{fmt.Errorf("unmarshal %s response: %w", endpoint, err)}
+		}
+		return false, facilitatorStatusError(endpoint, status, body)
+	}
+	return !ok, nil
+}
+
+// unmarshal decodes an answer body, normalized into the x402 schema.
+func (p *facilitatorProvider) unmarshal(body []byte, out any) error {
+	if p.normalize == nil {
+		return json.Unmarshal(body, out)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+	p.normalize(fields)
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(normalized, out)
+}
+
+// coinbaseErrors turns a CDP error object into the rejection reason of a
+// verify or settle answer.
+func coinbaseErrors(fields map[string]json.RawMessage) {
+	reason, ok := fields["errorMessage"]
+	if !ok {
+		reason, ok = fields["errorType"]
+	}
+	if !ok {
+		return
+	}
+	for _, name := range []string{"invalidReason", "errorReason"} {
+		if _, set := fields[name]; !set {
+			fields[name] = reason
+		}
+	}
+}
+
+// snakeCaseFields renames snake_case fields (is_valid, transaction_hash) to
+// the x402 camelCase names.
+func snakeCaseFields(fields map[string]json.RawMessage) {
+	for name, value := range fields {
+		if !strings.Contains(name, "_") {
+			continue
+		}
+		camel := camelCase(name)
+		if camel == "transactionHash" {
+			camel = "transaction"
+		}
+		if _, set := fields[camel]; !set {
+			fields[camel] = value
+		}
+		delete(fields, name)
+	}
+}
+
+// camelCase converts a snake_case name to camelCase.
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
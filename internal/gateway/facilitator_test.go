@@ -0,0 +1,263 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+)
+
+func TestDetectFacilitatorType(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://x402.org/facilitator", want: facilitatorX402Org},
+		{url: "https://api.cdp.coinbase.com/platform/v2/x402", want: facilitatorCoinbase},
+		{url: "https://facilitator.example.com", want: facilitatorCustom},
+		{url: "https://notx402.org", want: facilitatorCustom},
+	}
+	for _, tt := range tests {
+		if got := detectFacilitatorType(tt.url); got != tt.want {
+			t.Errorf("detectFacilitatorType(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestFacilitatorProviders(t *testing.T) {
+	tests := []struct {
+		name            string
+		facilitatorType string
+		status          int
+		verify          string
+		settle          string
+		wantErr         string // substring of the error; empty means success
+		wantFacilitator bool
+		wantTransaction string
+	}{
+		{
+			name: "x402.org rejection body", facilitatorType: facilitatorX402Org,
+			status: http.StatusBadRequest, verify: `{"isValid":false,"invalidReason":"insufficient_funds"}`,
+			wantErr: "payment invalid: insufficient_funds",
+		},
+		{
+			name: "coinbase error object", facilitatorType: facilitatorCoinbase,
+			status: http.StatusBadRequest, verify: `{"errorType":"invalid_request","errorMessage":"signature expired"}`,
+			wantErr: "payment invalid: signature expired",
+		},
+		{
+			name: "custom snake_case answers", facilitatorType: facilitatorCustom,
+			status: http.StatusOK, verify: `{"is_valid":true,"payer":"0xPayer"}`, settle: `{"success":true,"transaction_hash":"0xabc","network":"eip155:84532"}`,
+			wantTransaction: "0xabc",
+		},
+		{
+			name: "4xx without a body", facilitatorType: facilitatorX402Org,
+			status: http.StatusBadRequest, verify: `bad request`,
+			wantErr: "returned status 400",
+		},
+		{
+			name: "server error", facilitatorType: facilitatorX402Org,
+			status: http.StatusBadGateway, verify: `{"isValid":false}`,
+			wantErr: "returned status 502", wantFacilitator: true,
+		},
+		{
+			name: "malformed 200", facilitatorType: facilitatorCoinbase,
+			status: http.StatusOK, verify: `not json`,
+			wantErr: "unmarshal /verify response", wantFacilitator: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body := tt.verify
+				if strings.HasSuffix(r.URL.Path, "/settle") {
+					body = tt.settle
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(body))
+			}))
+			defer facilitator.Close()
+			route := &routestore.CompiledRoute{FacilitatorURL: facilitator.URL, FacilitatorType: tt.facilitatorType}
+			header := base64.StdEncoding.EncodeToString([]byte(`{}`))
+
+			payment, verified, err := verifyPayment(context.Background(), header, &paymentAccept{}, route)
+			var settled *settleResponse
+			if err == nil {
+				settled, err = newFacilitatorClient(route).Settle(context.Background(), verified, payment, &paymentAccept{})
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("payment error = %v", err)
+				}
+				if settled.Transaction != tt.wantTransaction {
+					t.Errorf("transaction = %q, want %q", settled.Transaction, tt.wantTransaction)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			var facErr *facilitatorError
+			if got := errors.As(err, &facErr); got != tt.wantFacilitator {
+				t.Errorf("facilitator error = %v, want %v", got, tt.wantFacilitator)
+			}
+		})
+	}
+}
+
+func TestFacilitatorAuth(t *testing.T) {
+	vaultServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/x402" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"apiKey":"cdp-key"},"metadata":{"version":1}}}`))
+	}))
+	defer vaultServer.Close()
+	client, _ := vault.NewClient(vaultServer.URL)
+	client.Token = "root"
+
+	var gotAuth string
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"isValid":true}`))
+	}))
+	defer facilitator.Close()
+	header := base64.StdEncoding.EncodeToString([]byte(`{}`))
+
+	tests := []struct {
+		name            string
+		secrets         *vault.Secrets
+		auth            *routestore.CompiledFacilitatorAuth
+		wantAuth        string
+		wantFacilitator bool
+	}{
+		{name: "no credential", secrets: vault.NewSecrets(client, time.Minute)},
+		{
+			name: "vault credential", secrets: vault.NewSecrets(client, time.Minute),
+			auth:     &routestore.CompiledFacilitatorAuth{Header: "Authorization", Prefix: "Bearer ", VaultPath: "secret/data/x402", VaultKey: "apiKey"},
+			wantAuth: "Bearer cdp-key",
+		},
+		{
+			name: "missing key", secrets: vault.NewSecrets(client, time.Minute),
+			auth:            &routestore.CompiledFacilitatorAuth{Header: "Authorization", VaultPath: "secret/data/x402", VaultKey: "other"},
+			wantFacilitator: true,
+		},
+		{
+			name:            "vault not configured",
+			auth:            &routestore.CompiledFacilitatorAuth{Header: "Authorization", VaultPath: "secret/data/x402", VaultKey: "apiKey"},
+			wantFacilitator: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facilitatorSecrets = tt.secrets
+			defer func() { facilitatorSecrets = nil }()
+			gotAuth = ""
+			route := &routestore.CompiledRoute{FacilitatorURL: facilitator.URL, FacilitatorAuth: tt.auth}
+
+			_, _, err := verifyPayment(context.Background(), header, &paymentAccept{}, route)
+			var facErr *facilitatorError
+			if tt.wantFacilitator {
+				if !errors.As(err, &facErr) {
+					t.Fatalf("verifyPayment() error = %v, want a facilitator error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyPayment() error = %v", err)
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", gotAuth, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestFacilitatorFailover(t *testing.T) {
+	tests := []struct {
+		name          string
+		healthy       bool
+		primaryVerify int // status of the primary's /verify answers
+		wantErr       string
+		wantPrimary   int // /verify calls to the primary over both payments
+		wantSecondary string
+	}{
+		{name: "primary answers", primaryVerify: http.StatusOK, wantPrimary: 2, wantSecondary: ""},
+		{name: "primary down", primaryVerify: http.StatusServiceUnavailable, wantPrimary: 2, wantSecondary: "verify settle verify settle"},
+		{name: "primary down, healthy first", healthy: true, primaryVerify: http.StatusServiceUnavailable, wantPrimary: 1, wantSecondary: "verify settle verify settle"},
+		{name: "rejections are not failed over", primaryVerify: http.StatusBadRequest, wantErr: "payment invalid", wantPrimary: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primaryCalls int
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/settle") {
+					w.Write([]byte(`{"success":true,"transaction":"0xprimary"}`))
+					return
+				}
+				primaryCalls++
+				switch tt.primaryVerify {
+				case http.StatusOK:
+					w.Write([]byte(`{"isValid":true}`))
+				case http.StatusBadRequest:
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"isValid":false,"invalidReason":"insufficient_funds"}`))
+				default:
+					w.WriteHeader(tt.primaryVerify)
+				}
+			}))
+			defer primary.Close()
+			var secondaryCalls []string
+			secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/settle") {
+					secondaryCalls = append(secondaryCalls, "settle")
+					w.Write([]byte(`{"success":true,"transaction":"0xsecondary"}`))
+					return
+				}
+				secondaryCalls = append(secondaryCalls, "verify")
+				w.Write([]byte(`{"isValid":true}`))
+			}))
+			defer secondary.Close()
+			route := &routestore.CompiledRoute{FacilitatorURL: primary.URL, FailoverURLs: []string{secondary.URL}, FacilitatorHealthy: tt.healthy}
+			header := base64.StdEncoding.EncodeToString([]byte(`{}`))
+
+			for range 2 {
+				payment, verified, err := verifyPayment(context.Background(), header, &paymentAccept{}, route)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("verifyPayment() error = %v, want %q", err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("verifyPayment() error = %v", err)
+				}
+				settled, err := newFacilitatorClient(route).Settle(context.Background(), verified, payment, &paymentAccept{})
+				if err != nil {
+					t.Fatalf("Settle() error = %v", err)
+				}
+				want := "0xprimary"
+				if verified.facilitator == secondary.URL {
+					want = "0xsecondary"
+				}
+				if settled.Transaction != want {
+					t.Errorf("settled by %s, verified by %s", settled.Transaction, verified.facilitator)
+				}
+			}
+			if primaryCalls != tt.wantPrimary {
+				t.Errorf("primary /verify calls = %d, want %d", primaryCalls, tt.wantPrimary)
+			}
+			if got := strings.Join(secondaryCalls, " "); got != tt.wantSecondary {
+				t.Errorf("secondary calls = %q, want %q", got, tt.wantSecondary)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// facilitatorHTTP is the HTTP client for facilitator API calls. Each call is
+// bounded by its own context.
+var facilitatorHTTP = &http.Client{}
+
+// FacilitatorCall describes one /verify or /settle call to a facilitator.
+// Request and Response hold signed payment payloads: hooks must not keep or
+// modify them, and should not log them unredacted.
+type FacilitatorCall struct {
+	Facilitator string // URL of the facilitator
+	Endpoint    string // "/verify" or "/settle"
+	Request     []byte
+	Status      int // of the answer; 0 when there was none
+	Response    []byte
+	Duration    time.Duration
+	Err         error // transport failure, if any
+}
+
+// FacilitatorHook observes the facilitator calls of the gateway. It runs on
+// the request path after each call, so it must be quick.
+type FacilitatorHook func(ctx context.Context, call *FacilitatorCall)
+
+// facilitatorHooks are the registered hooks, set before the gateway serves.
+var facilitatorHooks []FacilitatorHook
+
+// facilitatorPayment is a decoded payment payload and the requirements it is
+// checked against, marshaled once into the body of the facilitator calls.
+type facilitatorPayment struct {
+	payload json.RawMessage
+	accept  paymentAccept
+	body    []byte
+}
+
+// decodePayment decodes a Payment-Signature header for the accepted
+// requirements.
+func decodePayment(paymentHeader string, accept *paymentAccept) (*facilitatorPayment, error) {
+	// Decode the Base64 Payment-Signature header to get the payment payload JSON.
+	payloadBytes, err := base64.StdEncoding.DecodeString(paymentHeader)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode Payment-Signature: %w", err)
+	}
+
+	// Validate that payloadBytes is valid JSON.
+	if !json.Valid(payloadBytes) {
+		return nil, fmt.Errorf("Payment-Signature is not valid JSON after base64 decode")
+	}
+	return newFacilitatorPayment(payloadBytes, accept)
+}
+
+// newFacilitatorPayment marshals payload and accept into a facilitator
+// request body.
+func newFacilitatorPayment(payload json.RawMessage, accept *paymentAccept) (*facilitatorPayment, error) {
+	body, err := json.Marshal(facilitatorRequest{
+		PaymentPayload:      payload,
+		PaymentRequirements: accept,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal facilitator request: %w", err)
+	}
+	return &facilitatorPayment{payload: payload, accept: *accept, body: body}, nil
+}
+
+// withAccept returns the payment checked against accept instead: p itself
+// when the requirements are unchanged, so the body is reused.
+func (p *facilitatorPayment) withAccept(accept *paymentAccept) (*facilitatorPayment, error) {
+	if *accept == p.accept {
+		return p, nil
+	}
+	return newFacilitatorPayment(p.payload, accept)
+}
+
+// facilitatorClient calls the /verify and /settle endpoints of the
+// facilitators of one route.
+type facilitatorClient struct {
+	route *routestore.CompiledRoute
+}
+
+// newFacilitatorClient returns the facilitator client of route.
+func newFacilitatorClient(route *routestore.CompiledRoute) *facilitatorClient {
+	return &facilitatorClient{route: route}
+}
+
+// Verify asks the route's facilitators, in failover order, to verify
+// payment, each within the route's verify timeout. A facilitator that fails
+// hands the payment to the next one. A rejected payment also returns the
+// facilitator's answer, which may name the payer.
+func (c *facilitatorClient) Verify(ctx context.Context, payment *facilitatorPayment) (*verifyResponse, error) {
+	urls := facilitatorOrder(c.route)
+	var verified *verifyResponse
+	var err error
+	for i, facilitatorURL := range urls {
+		verified, err = c.verifyWith(ctx, facilitatorURL, payment)
+		// Rejected payments and canceled calls are not failed over.
+		var facErr *facilitatorError
+		if i == len(urls)-1 || !errors.As(err, &facErr) || ctx.Err() != nil {
+			break
+		}
+		slog.Warn("facilitator failed, failing over", "route", c.route.Name, "facilitator", facilitatorURL, "next", urls[i+1], "error", err)
+		metrics.FacilitatorFailoversTotal.WithLabelValues(facilitatorHost(facilitatorURL)).Inc()
+	}
+	return verified, err
+}
+
+// verifyWith calls the /verify endpoint of one facilitator.
+func (c *facilitatorClient) verifyWith(ctx context.Context, facilitatorURL string, payment *facilitatorPayment) (*verifyResponse, error) {
+	status, body, err := c.post(ctx, facilitatorURL, "/verify", c.route.VerifyTimeout, payment.body)
+	if err != nil {
+		facilitatorHealth.failed(facilitatorURL, err)
+		return nil, err
+	}
+
+	verified := verifyResponse{facilitator: facilitatorURL}
+	rejected, err := providerFor(c.route, facilitatorURL).decode("/verify", status, body, &verified)
+	if err != nil {
+		facilitatorHealth.failed(facilitatorURL, err)
+		return nil, err
+	}
+	facilitatorHealth.succeeded(facilitatorURL)
+
+	if rejected || !verified.IsValid {
+		reason := verified.InvalidReason
+		if reason == "" {
+			reason = "payment not valid"
+		}
+		return &verified, fmt.Errorf("payment invalid: %s", reason)
+	}
+	return &verified, nil
+}
+
+// Settle asks the facilitator that verified payment to settle it for accept,
+// the requirements it is charged, within the route's settle timeout.
+// Settlements are never failed over: another facilitator did not verify the
+// payment.
+func (c *facilitatorClient) Settle(ctx context.Context, verified *verifyResponse, payment *facilitatorPayment, accept *paymentAccept) (*settleResponse, error) {
+	facilitatorURL := verified.facilitator
+	if facilitatorURL == "" {
+		facilitatorURL = c.route.FacilitatorURL
+	}
+	payment, err := payment.withAccept(accept)
+	if err != nil {
+		return nil, err
+	}
+	status, body, err := c.post(ctx, facilitatorURL, "/settle", c.route.SettleTimeout, payment.body)
+	if err != nil {
+		facilitatorHealth.failed(facilitatorURL, err)
+		return nil, err
+	}
+
+	var settled settleResponse
+	rejected, err := providerFor(c.route, facilitatorURL).decode("/settle", status, body, &settled)
+	if err != nil {
+		facilitatorHealth.failed(facilitatorURL, err)
+		return nil, err
+	}
+	facilitatorHealth.succeeded(facilitatorURL)
+
+	if rejected || !settled.Success {
+		reason := settled.ErrorReason
+		if reason == "" {
+			reason = "settlement failed"
+		}
+		return nil, fmt.Errorf("settlement failed: %s", reason)
+	}
+	return &settled, nil
+}
+
+// post sends a request body to a facilitator endpoint, with the route's
+// facilitator credential when set, and returns the status and body of the
+// answer. The call is bound to ctx, so a client that disconnects cancels it,
+// and runs out of time after timeout, or defaultFacilitatorTimeout when it
+// is zero. The call is logged at debug level and passed to the hooks.
+func (c *facilitatorClient) post(ctx context.Context, facilitatorURL, endpoint string, timeout time.Duration, reqBody []byte) (int, []byte, error) {
+	if timeout == 0 {
+		timeout = defaultFacilitatorTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	status, body, err := c.do(ctx, strings.TrimRight(facilitatorURL, "/")+endpoint, reqBody)
+	if err != nil {
+		err = callError(ctx, fmt.Errorf("POST to facilitator %s: %w", endpoint, err))
+	}
+	call := &FacilitatorCall{
+		Facilitator: facilitatorURL,
+		Endpoint:    endpoint,
+		Request:     reqBody,
+		Status:      status,
+		Response:    body,
+		Duration:    time.Since(start),
+		Err:         err,
+	}
+	slog.Debug("facilitator call", "route", c.route.Name, "facilitator", facilitatorURL, "endpoint", endpoint, "status", status, "duration", call.Duration, "error", err)
+	for _, hook := range facilitatorHooks {
+		hook(ctx, call)
+	}
+	return status, body, err
+}
+
+// do makes one POST to target.
+func (c *facilitatorClient) do(ctx context.Context, target string, reqBody []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := setFacilitatorAuth(req, c.route.FacilitatorAuth); err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := facilitatorHTTP.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("read response: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestFacilitatorClient(t *testing.T) {
+	var received [][]byte
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, body)
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	var calls []*FacilitatorCall
+	facilitatorHooks = []FacilitatorHook{func(_ context.Context, call *FacilitatorCall) { calls = append(calls, call) }}
+	defer func() { facilitatorHooks = nil }()
+
+	route := &routestore.CompiledRoute{FacilitatorURL: facilitator.URL}
+	accept := &paymentAccept{Scheme: "exact", Network: "base-sepolia", Amount: "10000"}
+	payment, verified, err := verifyPayment(context.Background(), base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)), accept, route)
+	if err != nil {
+		t.Fatalf("verifyPayment() error = %v", err)
+	}
+	client := newFacilitatorClient(route)
+	if _, err := client.Settle(context.Background(), verified, payment, accept); err != nil {
+		t.Fatalf("Settle() error = %v", err)
+	}
+	charged := *accept
+	charged.Amount = "5000"
+	if _, err := client.Settle(context.Background(), verified, payment, &charged); err != nil {
+		t.Fatalf("Settle() error = %v", err)
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("facilitator received %d calls, want 3", len(received))
+	}
+	if !bytes.Equal(received[0], received[1]) || !bytes.Equal(received[0], payment.body) {
+		t.Errorf("settle body %s differs from verify body %s for the same requirements", received[1], received[0])
+	}
+	if !strings.Contains(string(received[2]), `"amount":"5000"`) {
+		t.Errorf("settle body %s, want the charged amount", received[2])
+	}
+
+	var endpoints []string
+	for _, call := range calls {
+		endpoints = append(endpoints, call.Endpoint)
+		if call.Facilitator != facilitator.URL || call.Status != http.StatusOK || call.Err != nil || len(call.Response) == 0 {
+			t.Errorf("hook call = %+v", call)
+		}
+	}
+	if got := strings.Join(endpoints, " "); got != "/verify /settle /settle" {
+		t.Errorf("hook endpoints = %q", got)
+	}
+}
+
+func TestFacilitatorPaymentWithAccept(t *testing.T) {
+	accept := &paymentAccept{Scheme: "exact", Amount: "10"}
+	payment, err := newFacilitatorPayment([]byte(`{}`), accept)
+	if err != nil {
+		t.Fatalf("newFacilitatorPayment() error = %v", err)
+	}
+	same := *accept
+	if got, _ := payment.withAccept(&same); got != payment {
+		t.Error("withAccept() of the same requirements marshaled again")
+	}
+	same.Amount = "0"
+	if got, _ := payment.withAccept(&same); got == payment || bytes.Equal(got.body, payment.body) {
+		t.Error("withAccept() of other requirements reused the body")
+	}
+}
@@ -0,0 +1,68 @@
+package gateway
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/events"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// failOpenEventInterval limits fail-open events to one per route per interval;
+// metrics and logs still record every request.
+const failOpenEventInterval = time.Minute
+
+// failOpenReporter records requests served unpaid during a facilitator outage.
+type failOpenReporter struct {
+	recorder events.EventRecorder // optional
+
+	mu   sync.Mutex
+	last map[string]time.Time // key: "namespace/name"
+}
+
+func newFailOpenReporter() *failOpenReporter {
+	return &failOpenReporter{last: make(map[string]time.Time)}
+}
+
+// report counts a fail-open request and emits a Warning event on the route.
+func (f *failOpenReporter) report(route *routestore.CompiledRoute, path string, err error) {
+	metrics.FacilitatorFailOpenTotal.WithLabelValues(route.Namespace, route.Name, route.OnFacilitatorError).Inc()
+	slog.Warn("facilitator unavailable, serving paid path without payment",
+		"path", path, "route", route.Name, "behavior", route.OnFacilitatorError, "error", err)
+
+	if f.recorder == nil || !f.due(route.Namespace+"/"+route.Name) {
+		return
+	}
+	ref := &corev1.ObjectReference{
+		APIVersion: "x402.io/v1alpha1",
+		Kind:       "X402Route",
+		Namespace:  route.Namespace,
+		Name:       route.Name,
+	}
+	f.recorder.Eventf(ref, nil, corev1.EventTypeWarning, "FacilitatorFailOpen", "ServeUnpaid",
+		"Facilitator unavailable, serving paid paths without payment (%s): %v", route.OnFacilitatorError, err)
+}
+
+// due reports whether an event for the route may be emitted now.
+func (f *failOpenReporter) due(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if now.Sub(f.last[key]) < failOpenEventInterval {
+		return false
+	}
+	f.last[key] = now
+	return true
+}
+
+// writeStaticOK answers a paid request with 200 without contacting the backend.
+func writeStaticOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK\n"))
+}
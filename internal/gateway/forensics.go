@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// VerificationCapturePath serves the captured failed verifications. It is
+// mounted on the metrics server, never on the public gateway port.
+const VerificationCapturePath = "/debug/x402/failed-verifications"
+
+// redactedValue replaces secret fields of captured payloads.
+const redactedValue = "[redacted]"
+
+// secretPayloadFields are payload fields that could replay or forge a payment.
+var secretPayloadFields = []string{"signature", "nonce"}
+
+// VerificationCapture keeps the last failed payment verifications of every
+// route, so operators can see why a client's payments are rejected.
+type VerificationCapture struct {
+	size      int
+	addresses func(string) string // rewrites addresses in text; nil keeps them
+
+	mu     sync.Mutex
+	routes map[string][]capturedFailure // by namespace/name, oldest first
+}
+
+// capturedFailure is one failed verification, with the payment redacted.
+type capturedFailure struct {
+	Time         time.Time       `json:"time"`
+	Path         string          `json:"path"`
+	Reason       string          `json:"reason"`
+	Payment      json.RawMessage `json:"payment,omitempty"`
+	PaymentError string          `json:"paymentError,omitempty"`
+	Requirements *paymentAccept  `json:"requirements,omitempty"`
+}
+
+// NewVerificationCapture returns a capture keeping the last size failed
+// verifications of every route. addresses, if non-nil, rewrites the wallet
+// addresses in captured payments and reasons, like those in logs.
+func NewVerificationCapture(size int, addresses func(string) string) *VerificationCapture {
+	return &VerificationCapture{size: size, addresses: addresses, routes: map[string][]capturedFailure{}}
+}
+
+// record captures a failed verification of paymentHeader against accept,
+// which may be nil. It does nothing on a nil capture.
+func (c *VerificationCapture) record(route *routestore.CompiledRoute, path, paymentHeader string, accept *paymentAccept, reason string) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	failure := capturedFailure{Time: time.Now().UTC(), Path: path, Reason: c.text(reason), Requirements: accept}
+	failure.Payment, failure.PaymentError = c.redactPayment(paymentHeader)
+
+	key := route.Namespace + "/" + route.Name
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	failures := append(c.routes[key], failure)
+	if len(failures) > c.size {
+		failures = slices.Delete(failures, 0, len(failures)-c.size)
+	}
+	c.routes[key] = failures
+}
+
+// ServeHTTP lists the captured failures by route, newest first. The route
+// query parameter (namespace/name) limits the answer to one route.
+func (c *VerificationCapture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	only := r.URL.Query().Get("route")
+	c.mu.Lock()
+	routes := map[string][]capturedFailure{}
+	for key, failures := range c.routes {
+		if only != "" && key != only {
+			continue
+		}
+		newest := slices.Clone(failures)
+		slices.Reverse(newest)
+		routes[key] = newest
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{"capacity": c.size, "routes": routes})
+}
+
+// text rewrites the addresses in s.
+func (c *VerificationCapture) text(s string) string {
+	if c.addresses == nil {
+		return s
+	}
+	return c.addresses(s)
+}
+
+// redactPayment decodes a payment header with its signature and nonce removed
+// and its addresses rewritten, or explains why it could not be decoded.
+func (c *VerificationCapture) redactPayment(paymentHeader string) (json.RawMessage, string) {
+	decoded, err := base64.StdEncoding.DecodeString(paymentHeader)
+	if err != nil {
+		return nil, "payment header is not base64"
+	}
+	var payment any
+	if err := json.Unmarshal(decoded, &payment); err != nil {
+		return nil, "payment header is not JSON"
+	}
+	redacted, err := json.Marshal(c.redactFields(payment))
+	if err != nil {
+		return nil, err.Error()
+	}
+	return redacted, ""
+}
+
+// redactFields redacts the secret fields of a decoded payment and rewrites
+// the addresses in the others.
+func (c *VerificationCapture) redactFields(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for name, value := range v {
+			if slices.Contains(secretPayloadFields, name) {
+				v[name] = redactedValue
+			} else {
+				v[name] = c.redactFields(value)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = c.redactFields(v[i])
+		}
+	case string:
+		return c.text(v)
+	}
+	return v
+}
+
+// captureFailure records a failed verification of req, with reason
+// describing why it failed.
+func (h *Handler) captureFailure(req *request, accept *paymentAccept, reason string) {
+	h.verificationCapture.record(req.route, req.path, req.paymentHeader, accept, reason)
+}
@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestVerificationCapture(t *testing.T) {
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"isValid":false,"invalidReason":"insufficient_funds"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+		Rules:    []routestore.CompiledRule{{Path: "/api/**", Price: "0.01", Mode: "all-pay"}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: "http://127.0.0.1:1"}},
+	})
+	h := NewHandler(store)
+	truncate := func(s string) string {
+		return strings.ReplaceAll(s, "0x1111111111111111111111111111111111111111", "0x1111…1111")
+	}
+	capture := NewVerificationCapture(2, truncate)
+	h.verificationCapture = capture
+
+	pay := func(path, payment string) {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Payment-Signature", payment)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusPaymentRequired {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusPaymentRequired)
+		}
+	}
+	for i := range 3 {
+		payload := fmt.Sprintf(`{"x402Version":2,"payload":{"signature":"0xsecret","authorization":{"from":"0x1111111111111111111111111111111111111111","nonce":"0x%d"}}}`, i)
+		pay(fmt.Sprintf("/api/%d", i), base64.StdEncoding.EncodeToString([]byte(payload)))
+	}
+	pay("/api/bad", "not base64!")
+
+	tests := []struct {
+		name      string
+		query     string
+		wantPaths []string
+	}{
+		{name: "route", query: "?route=default/api", wantPaths: []string{"/api/bad", "/api/2"}},
+		{name: "all routes", wantPaths: []string{"/api/bad", "/api/2"}},
+		{name: "other route", query: "?route=default/other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			capture.ServeHTTP(w, httptest.NewRequest("GET", VerificationCapturePath+tt.query, nil))
+			var got struct {
+				Capacity int                          `json:"capacity"`
+				Routes   map[string][]capturedFailure `json:"routes"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			failures := got.Routes["default/api"]
+			if len(failures) != len(tt.wantPaths) {
+				t.Fatalf("captured %d failures, want %d: %s", len(failures), len(tt.wantPaths), w.Body.String())
+			}
+			for i, want := range tt.wantPaths {
+				if failures[i].Path != want {
+					t.Errorf("failure %d path = %q, want %q", i, failures[i].Path, want)
+				}
+			}
+			if len(failures) == 0 {
+				return
+			}
+			if failures[0].PaymentError == "" {
+				t.Error("undecodable payment has no paymentError")
+			}
+			captured := string(failures[1].Payment)
+			if strings.Contains(captured, "0xsecret") || strings.Contains(captured, `"0x2"`) || strings.Contains(captured, "0x1111111111111111111111111111111111111111") {
+				t.Errorf("payment not redacted: %s", captured)
+			}
+			if !strings.Contains(captured, "0x1111…1111") {
+				t.Errorf("payment = %s, want the rewritten payer address", captured)
+			}
+			if !strings.Contains(failures[1].Reason, "insufficient_funds") {
+				t.Errorf("reason = %q, want the facilitator's reason", failures[1].Reason)
+			}
+		})
+	}
+}
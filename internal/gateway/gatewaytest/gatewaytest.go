@@ -0,0 +1,280 @@
+// Package gatewaytest provides a fake facilitator, a fake backend, route
+// fixtures and request helpers for testing the gateway handler end to end.
+package gatewaytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// Fixture values of the routes and payments built here.
+const (
+	Namespace   = "default"
+	Wallet      = "0xTestWallet"
+	Network     = "base-sepolia"
+	Payer       = "0x0000000000000000000000000000000000000001"
+	Transaction = "0xtest"
+)
+
+// Call is a request received by a fake server.
+type Call struct {
+	Path string
+	Body []byte
+}
+
+// Facilitator is a fake x402 facilitator. It verifies and settles every
+// payment until told otherwise, and records the calls it receives.
+type Facilitator struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	status        int // answers every call with it when set
+	invalidReason string
+	settleError   string
+	delay         time.Duration
+	calls         []Call
+}
+
+// NewFacilitator starts a fake facilitator, closed when the test ends.
+func NewFacilitator(t testing.TB) *Facilitator {
+	f := &Facilitator{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Reject makes /verify reject payments for reason; "" accepts them again.
+func (f *Facilitator) Reject(reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalidReason = reason
+}
+
+// FailSettlement makes /settle fail for reason; "" settles again.
+func (f *Facilitator) FailSettlement(reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settleError = reason
+}
+
+// Fail makes every call answer status without a body, such as 503 for an
+// outage; 0 answers normally again.
+func (f *Facilitator) Fail(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+// Delay holds every answer for d, or until the caller gives up.
+func (f *Facilitator) Delay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = d
+}
+
+// Calls returns the calls received on path, such as "/verify", or on every
+// path when path is "".
+func (f *Facilitator) Calls(path string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []Call
+	for _, c := range f.calls {
+		if path == "" || c.Path == path {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (f *Facilitator) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Path: r.URL.Path, Body: body})
+	status, invalidReason, settleError, delay := f.status, f.invalidReason, f.settleError, f.delay
+	f.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/verify":
+		if invalidReason != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"isValid": false, "invalidReason": invalidReason, "payer": Payer})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"isValid": true, "payer": Payer})
+	case "/settle":
+		if settleError != "" {
+			json.NewEncoder(w).Encode(map[string]any{"success": false, "errorReason": settleError})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "payer": Payer, "transaction": Transaction, "network": "eip155:84532"})
+	case "/supported":
+		io.WriteString(w, `{"kinds":[{"x402Version":2,"scheme":"exact","network":"eip155:84532"},{"x402Version":2,"scheme":"upto","network":"eip155:84532"}]}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Backend is a fake backend answering "backend:<path>" with its status, 200
+// by default, and recording the requests it receives.
+type Backend struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	status int
+	calls  []Call
+}
+
+// NewBackend starts a fake backend, closed when the test ends. Close it
+// earlier to take the backend down.
+func NewBackend(t testing.TB) *Backend {
+	b := &Backend{status: http.StatusOK}
+	b.Server = httptest.NewServer(http.HandlerFunc(b.serve))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// SetStatus makes the backend answer status.
+func (b *Backend) SetStatus(status int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status = status
+}
+
+// Calls returns the requests the backend received.
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Call(nil), b.calls...)
+}
+
+func (b *Backend) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	b.mu.Lock()
+	b.calls = append(b.calls, Call{Path: r.URL.Path, Body: body})
+	status := b.status
+	b.mu.Unlock()
+	w.WriteHeader(status)
+	io.WriteString(w, "backend:"+r.URL.Path)
+}
+
+// Route returns a route named name in Namespace that pays Wallet on Network
+// through facilitator, forwards every path to backendURL and serves rules.
+// Tests adjust the returned route before storing it.
+func Route(name string, facilitator *Facilitator, backendURL string, rules ...routestore.CompiledRule) *routestore.CompiledRoute {
+	return &routestore.CompiledRoute{
+		Name:           name,
+		Namespace:      Namespace,
+		Wallet:         Wallet,
+		Network:        Network,
+		FacilitatorURL: facilitator.URL,
+		Rules:          rules,
+		Backends:       []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendURL}},
+		Unmatched:      "404",
+	}
+}
+
+// PaidRule returns a rule charging price for path, settled before the
+// request is forwarded.
+func PaidRule(path, price string) routestore.CompiledRule {
+	return routestore.CompiledRule{Path: path, Price: price, Mode: "all-pay", Settle: "sync"}
+}
+
+// FreeRule returns a rule forwarding path without payment.
+func FreeRule(path string) routestore.CompiledRule {
+	return routestore.CompiledRule{Path: path, Free: true, Mode: "all-pay"}
+}
+
+// ConditionalRule returns a rule charging price for path only to requests
+// whose header matches pattern; others are free.
+func ConditionalRule(path, price, header, pattern string) routestore.CompiledRule {
+	rule := PaidRule(path, price)
+	rule.Mode = "conditional"
+	rule.Conditions = []routestore.CompiledCondition{
+		{Header: header, Pattern: regexp.MustCompile(pattern), Action: "pay"},
+		{Header: header, Pattern: regexp.MustCompile(".*"), Action: "free"},
+	}
+	return rule
+}
+
+// Store returns a route store holding routes.
+func Store(routes ...*routestore.CompiledRoute) *routestore.Store {
+	store := routestore.New()
+	for _, route := range routes {
+		store.Set(route.Namespace, route.Name, route)
+	}
+	return store
+}
+
+// PaymentHeader is a Payment-Signature header the fake facilitator accepts.
+var PaymentHeader = base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2,"payload":{"signature":"0xsig"}}`))
+
+// Request returns a request for target without payment.
+func Request(method, target string) *http.Request {
+	return httptest.NewRequest(method, target, nil)
+}
+
+// PaidRequest returns a request for target carrying PaymentHeader.
+func PaidRequest(method, target string) *http.Request {
+	r := Request(method, target)
+	r.Header.Set("Payment-Signature", PaymentHeader)
+	return r
+}
+
+// Serve has h answer r and returns the response.
+func Serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// PaymentRequired is the decoded PAYMENT-REQUIRED header of a 402 answer.
+type PaymentRequired struct {
+	Error   string `json:"error"`
+	Accepts []struct {
+		Scheme  string `json:"scheme"`
+		Network string `json:"network"`
+		Asset   string `json:"asset"`
+		Amount  string `json:"amount"`
+		PayTo   string `json:"payTo"`
+	} `json:"accepts"`
+}
+
+// DecodePaymentRequired decodes the PAYMENT-REQUIRED header of w, failing
+// the test when it is missing or malformed.
+func DecodePaymentRequired(t testing.TB, w *httptest.ResponseRecorder) *PaymentRequired {
+	t.Helper()
+	header := w.Header().Get("PAYMENT-REQUIRED")
+	if header == "" {
+		t.Fatalf("no PAYMENT-REQUIRED header in %d answer: %s", w.Code, w.Body)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("PAYMENT-REQUIRED is not base64: %v", err)
+	}
+	var required PaymentRequired
+	if err := json.Unmarshal(decoded, &required); err != nil {
+		t.Fatalf("PAYMENT-REQUIRED is not JSON: %v", err)
+	}
+	return &required
+}
@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// graphqlRule returns the rule priced for the GraphQL operation of the
+// request. Operations without a listed price keep the rule price.
+func graphqlRule(r *http.Request, rule *routestore.CompiledRule) (*routestore.CompiledRule, error) {
+	name, err := graphqlOperation(r, rule.GraphQL.MaxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	price, ok := rule.GraphQL.Operations[name]
+	if !ok {
+		return rule, nil
+	}
+	priced := *rule
+	priced.Price = price
+	return &priced, nil
+}
+
+// graphqlOperation returns the name of the operation a GraphQL request
+// executes, "" for an anonymous one. At most limit bytes of the body are read,
+// and the body is restored for the backend.
+func graphqlOperation(r *http.Request, limit int64) (string, error) {
+	var doc, name string
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		doc, name = q.Get("query"), q.Get("operationName")
+	} else {
+		body, tooLarge, err := peekBody(r, limit)
+		if err != nil {
+			return "", err
+		}
+		if tooLarge {
+			return "", errors.New("graphql request body too large")
+		}
+
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			doc = string(body)
+		} else {
+			var req struct {
+				Query         string `json:"query"`
+				OperationName string `json:"operationName"`
+			}
+			if err := json.Unmarshal(body, &req); err != nil {
+				return "", errors.New("graphql request is not a single JSON operation")
+			}
+			doc, name = req.Query, req.OperationName
+		}
+	}
+
+	// The server executes the named operation, or the only one in the document.
+	if name != "" {
+		return name, nil
+	}
+	ops := documentOperations(doc)
+	if len(ops) != 1 {
+		return "", errors.New("graphql request must name its operation")
+	}
+	return ops[0], nil
+}
+
+// documentOperations returns the names of the operations defined in a GraphQL
+// document, "" for anonymous ones. Fragments, comments, strings, variable
+// definitions and directives are skipped.
+func documentOperations(doc string) []string {
+	var ops []string
+	depth, parens := 0, 0
+	inOp, inFragment, named := false, false, false
+	opName := ""
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(doc[i:], `"""`):
+			end := strings.Index(doc[i+3:], `"""`)
+			if end < 0 {
+				return ops
+			}
+			i += end + 6
+		case c == '"':
+			for i++; i < len(doc) && doc[i] != '"'; i++ {
+				if doc[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		case c == '{':
+			if depth == 0 && parens == 0 && !inFragment {
+				ops = append(ops, opName)
+			}
+			if depth == 0 {
+				inOp, inFragment, named, opName = false, false, false, ""
+			}
+			depth++
+			i++
+		case c == '}':
+			depth--
+			i++
+		case c == '(':
+			parens++
+			i++
+		case c == ')':
+			parens--
+			i++
+		case c == '$' || c == '@':
+			// Variable and directive names are not definition keywords.
+			i++
+			for i < len(doc) && isNameChar(doc[i]) {
+				i++
+			}
+		case isNameChar(c):
+			start := i
+			for i < len(doc) && isNameChar(doc[i]) {
+				i++
+			}
+			if depth != 0 || parens != 0 {
+				break
+			}
+			word := doc[start:i]
+			switch {
+			case inOp && !named:
+				opName, named = word, true
+			case !inOp && !inFragment && (word == "query" || word == "mutation" || word == "subscription"):
+				inOp = true
+			case !inOp && !inFragment && word == "fragment":
+				inFragment = true
+			}
+		default:
+			i++
+		}
+	}
+	return ops
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
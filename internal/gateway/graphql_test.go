@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestDocumentOperations(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{name: "shorthand query", doc: `{ user(id: 1) { name } }`, want: []string{""}},
+		{name: "named query", doc: `query GetUser { user { name } }`, want: []string{"GetUser"}},
+		{name: "anonymous mutation", doc: `mutation { deleteAll }`, want: []string{""}},
+		{name: "variables and directives", doc: `mutation Upload($query: String = "mutation Cheap {") @auth(role: "query") { upload(q: $query) }`, want: []string{"Upload"}},
+		{name: "fragment skipped", doc: "fragment F on User { name }\n# query Commented { x }\nquery Q { user { ...F } }", want: []string{"Q"}},
+		{name: "block string", doc: `query Q { search(text: """ mutation M { x } """) }`, want: []string{"Q"}},
+		{name: "multiple operations", doc: `query A { a } mutation B { b }`, want: []string{"A", "B"}},
+		{name: "empty", doc: ``, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := documentOperations(tt.doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("documentOperations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLRule(t *testing.T) {
+	route := &routestore.CompiledRoute{Wallet: "0xTestWallet", Network: "base-sepolia"}
+	rule := &routestore.CompiledRule{
+		Path:  "/graphql",
+		Price: "0.001",
+		GraphQL: &routestore.CompiledGraphQL{
+			Operations:   map[string]string{"CreateReport": "0.05"},
+			MaxBodyBytes: 1024,
+		},
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		wantAmount  string
+		wantErr     bool
+	}{
+		{name: "priced mutation", method: "POST", url: "/graphql", body: `{"query":"mutation CreateReport { createReport }"}`, wantAmount: "50000"},
+		{name: "operationName selects", method: "POST", url: "/graphql", body: `{"query":"query A { a } mutation CreateReport { r }","operationName":"CreateReport"}`, wantAmount: "50000"},
+		{name: "unlisted query", method: "POST", url: "/graphql", body: `{"query":"query GetUser { user { name } }"}`, wantAmount: "1000"},
+		{name: "graphql content type", method: "POST", url: "/graphql", contentType: "application/graphql", body: `mutation CreateReport { createReport }`, wantAmount: "50000"},
+		{name: "get", method: "GET", url: "/graphql?query=query+GetUser+%7B+user+%7D", wantAmount: "1000"},
+		{name: "ambiguous document", method: "POST", url: "/graphql", body: `{"query":"query A { a } mutation CreateReport { r }"}`, wantErr: true},
+		{name: "batch", method: "POST", url: "/graphql", body: `[{"query":"mutation CreateReport { r }"}]`, wantErr: true},
+		{name: "body too large", method: "POST", url: "/graphql", body: `{"query":"mutation CreateReport { r }","pad":"` + strings.Repeat("x", 1024) + `"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			priced, err := graphqlRule(r, rule)
+
+			// The backend must still receive the full body.
+			if body, _ := io.ReadAll(r.Body); string(body) != tt.body {
+				t.Errorf("forwarded body = %q, want %q", body, tt.body)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("graphqlRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			reqs, err := buildPaymentRequirements(r, route, priced)
+			if err != nil {
+				t.Fatalf("buildPaymentRequirements() error = %v", err)
+			}
+			if got := reqs.Accepts[0].Amount; got != tt.wantAmount {
+				t.Errorf("Amount = %q, want %q", got, tt.wantAmount)
+			}
+		})
+	}
+}
@@ -1,117 +1,94 @@
 package gateway
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
 	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/version"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
 )
 
 // Handler handles incoming HTTP requests, performing route matching,
-// payment verification, and proxying to backends.
+// payment verification, and proxying to backends. Requests go through a
+// pipeline of stages; see pipeline.go.
 type Handler struct {
-	store *routestore.Store
+	store       *routestore.Store
+	stages      []stage
+	failOpen    *failOpenReporter
+	callbacks   *callbackNotifier
+	contextKeys *backend.KeySet  // signs X-402-Context for paid requests; optional
+	upstream    *trustedUpstream // accepts verifications attested upstream; optional
+	// state holds the idempotent responses, async jobs and waiting room
+	// tickets, shared by the replicas of a shared store.
+	state       storage.Interface
+	jobs        *jobStore
+	concurrency *concurrencyLimiter
+	// settlementSinks receive a record of each settled payment.
+	settlementSinks []SettlementSink
+	build           version.Info // reported by the status and version endpoints
+	// verificationCapture keeps failed verifications for debugging; optional.
+	verificationCapture *VerificationCapture
+	// analytics counts 402 responses for the analytics endpoint; optional.
+	analytics *Analytics
+	// prober sends synthetic paid requests; optional.
+	prober *Prober
+	// reputation scores payers by their failed payments; optional.
+	reputation *Reputation
+	// waitingRoom issues the queue tokens of routes at capacity.
+	waitingRoom *waitingRoom
 }
 
-// NewHandler creates a new gateway handler.
+// storeTimeout bounds each call the handler makes to its state store.
+const storeTimeout = 5 * time.Second
+
+// NewHandler creates a new gateway handler keeping its state in memory.
 func NewHandler(store *routestore.Store) *Handler {
-	return &Handler{store: store}
+	state := storage.NewMemory()
+	h := &Handler{store: store, build: version.Get(), failOpen: newFailOpenReporter(), callbacks: newCallbackNotifier(), state: state, jobs: newJobStore(state), concurrency: newConcurrencyLimiter(), waitingRoom: newWaitingRoom(nil, state)}
+	h.stages = h.defaultStages()
+	return h
+}
+
+// setState keeps the handler's state in state from now on.
+func (h *Handler) setState(state storage.Interface) {
+	h.state = state
+	h.jobs.store = state
+	h.waitingRoom.store = state
 }
 
 // ServeHTTP implements http.Handler.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	path := r.URL.Path
-	host := r.Host
-	// Strip port from host if present.
-	if idx := strings.LastIndex(host, ":"); idx != -1 {
-		host = host[:idx]
-	}
-	routes := h.store.Snapshot()
-
-	for _, route := range routes {
-		if !h.matchesHost(host, route) {
-			continue
-		}
-		rule, matched := h.findMatchingRule(path, route)
-		if !matched {
-			continue
-		}
-
-		// Free path — forward directly.
-		if rule.Free {
-			slog.Info("free path, forwarding", "path", path, "route", route.Name)
-			metrics.RequestsTotal.WithLabelValues(path, route.Namespace, route.Name, "free").Inc()
-			proxyToBackend(w, r, route, path)
-			metrics.ProxyRequestDuration.Observe(time.Since(start).Seconds())
-			return
-		}
-
-		// Determine if payment is required for conditional mode.
-		if rule.Mode == "conditional" && len(rule.Conditions) > 0 {
-			if !evaluateConditions(r, rule.Conditions) {
-				slog.Info("conditional: no payment needed", "path", path, "route", route.Name)
-				metrics.RequestsTotal.WithLabelValues(path, route.Namespace, route.Name, "conditional_free").Inc()
-				proxyToBackend(w, r, route, path)
-				metrics.ProxyRequestDuration.Observe(time.Since(start).Seconds())
-				return
-			}
-		}
+	h.serve(&request{w: w, r: r, path: r.URL.Path, start: time.Now()}, 0)
+}
 
-		// Payment required — check for payment header.
-		paymentHeader := getPaymentHeader(r)
-		if paymentHeader == "" {
-			slog.Info("paid path, no payment header", "path", path, "route", route.Name)
-			metrics.RequestsTotal.WithLabelValues(path, route.Namespace, route.Name, "payment_required").Inc()
-			writePaymentRequired(w, r, route, rule.Price)
+// paymentFailed answers a paid request whose payment could not be verified or
+// settled. Facilitator failures honor the route's fail-open policy.
+func (h *Handler) paymentFailed(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule, path string, err error, start time.Time) {
+	var facErr *facilitatorError
+	if errors.As(err, &facErr) {
+		switch route.OnFacilitatorError {
+		case "failOpen":
+			h.failOpen.report(route, path, err)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "facilitator_fail_open").Inc()
+			proxyToBackend(w, r, route, rule, path)
+			metrics.ObserveDuration(metrics.ProxyRequestDuration, r, time.Since(start).Seconds())
 			return
-		}
-
-		// Build payment requirements for facilitator request.
-		paymentReqs, err := buildPaymentRequirements(r, route, rule.Price)
-		if err != nil {
-			slog.Error("failed to build payment requirements", "path", path, "route", route.Name, "error", err)
-			http.Error(w, "internal error building payment requirements", http.StatusInternalServerError)
+		case "staticOK":
+			h.failOpen.report(route, path, err)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "facilitator_static_ok").Inc()
+			writeStaticOK(w)
 			return
 		}
-
-		// Verify and settle payment with facilitator.
-		verifyStart := time.Now()
-		settleResp, err := verifyAndSettlePayment(paymentHeader, paymentReqs, route.FacilitatorURL)
-		metrics.PaymentVerificationDuration.Observe(time.Since(verifyStart).Seconds())
-
-		if err != nil {
-			slog.Error("payment verification/settlement failed", "path", path, "route", route.Name, "error", err)
-			metrics.RequestsTotal.WithLabelValues(path, route.Namespace, route.Name, "verification_error").Inc()
-			writePaymentRequired(w, r, route, rule.Price)
-			return
-		}
-
-		slog.Info("payment verified and settled, forwarding", "path", path, "route", route.Name)
-		metrics.RequestsTotal.WithLabelValues(path, route.Namespace, route.Name, "payment_accepted").Inc()
-		if amount, err := strconv.ParseFloat(rule.Price, 64); err == nil {
-			metrics.PaymentAmountTotal.WithLabelValues(path, route.Wallet, route.Network).Add(amount)
-		}
-
-		// Set PAYMENT-RESPONSE header as Base64-encoded settle response JSON.
-		if settleJSON, err := json.Marshal(settleResp); err == nil {
-			w.Header().Set("PAYMENT-RESPONSE", base64.StdEncoding.EncodeToString(settleJSON))
-		}
-
-		proxyToBackend(w, r, route, path)
-		metrics.ProxyRequestDuration.Observe(time.Since(start).Seconds())
-		return
 	}
 
-	// No route matched.
-	slog.Info("no matching route", "path", path)
-	http.Error(w, "no x402 route configured for this path", http.StatusNotFound)
+	slog.Error("payment verification/settlement failed", "path", path, "route", route.Name, "error", err)
+	metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "verification_error").Inc()
+	writePaymentRequired(w, r, route, rule)
 }
 
 // matchesHost checks if the request host matches any host in the route.
@@ -121,19 +98,44 @@ func (h *Handler) matchesHost(host string, route *routestore.CompiledRoute) bool
 		return true
 	}
 	for _, rh := range route.Hosts {
-		if strings.EqualFold(rh, host) {
+		if matchHost(rh, host) {
 			return true
 		}
 	}
 	return false
 }
 
-// findMatchingRule finds the first rule in a route that matches the given path.
-func (h *Handler) findMatchingRule(path string, route *routestore.CompiledRoute) (*routestore.CompiledRule, bool) {
+// findMatchingRule finds the first rule in a route for the given host that
+// matches the given path. Rules without a host are the catch-all of every
+// host: they match only when no rule for the host does.
+func (h *Handler) findMatchingRule(host, path string, route *routestore.CompiledRoute) (*routestore.CompiledRule, bool) {
+	catchAll := -1
 	for i := range route.Rules {
-		if matchPath(route.Rules[i].Path, path) {
-			return &route.Rules[i], true
+		rule := &route.Rules[i]
+		if rule.Host != "" && !matchHost(rule.Host, host) {
+			continue
 		}
+		if !matchPath(rule.Path, path) {
+			continue
+		}
+		if rule.Host != "" {
+			return rule, true
+		}
+		if catchAll < 0 {
+			catchAll = i
+		}
+	}
+	if catchAll < 0 {
+		return nil, false
+	}
+	return &route.Rules[catchAll], true
+}
+
+// pathLabel is the path label of the metrics of a request to path matched by
+// rule: the rule pattern, or the raw path when raw path labels are enabled.
+func pathLabel(rule *routestore.CompiledRule, path string) string {
+	if rule == nil {
+		return metrics.PathLabel("", path)
 	}
-	return nil, false
+	return metrics.PathLabel(rule.Path, path)
 }
@@ -0,0 +1,468 @@
+package gateway
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/gateway/gatewaytest"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+func TestHandlerUnmatchedBehavior(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backend:"+r.URL.Path)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name       string
+		unmatched  string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "404", unmatched: "404", wantStatus: http.StatusNotFound},
+		{name: "passthrough", unmatched: "passthrough", wantStatus: http.StatusOK, wantBody: "backend:/blog/post"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := routestore.New()
+			store.Set("default", "my-api", &routestore.CompiledRoute{
+				Name:      "my-api",
+				Namespace: "default",
+				Wallet:    "0xTestWallet",
+				Network:   "base-sepolia",
+				Rules:     []routestore.CompiledRule{{Path: "/api/*", Price: "0.001", Mode: "all-pay"}},
+				Backends:  []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}},
+				Unmatched: tt.unmatched,
+			})
+
+			w := httptest.NewRecorder()
+			NewHandler(store).ServeHTTP(w, httptest.NewRequest("GET", "/blog/post", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandlerOnFacilitatorError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backend")
+	}))
+	defer backend.Close()
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer facilitator.Close()
+
+	tests := []struct {
+		name       string
+		behavior   string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "failClosed", behavior: "failClosed", wantStatus: http.StatusPaymentRequired},
+		{name: "failOpen", behavior: "failOpen", wantStatus: http.StatusOK, wantBody: "backend"},
+		{name: "staticOK", behavior: "staticOK", wantStatus: http.StatusOK, wantBody: "OK\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := routestore.New()
+			store.Set("default", "my-api", &routestore.CompiledRoute{
+				Name:               "my-api",
+				Namespace:          "default",
+				Wallet:             "0xTestWallet",
+				Network:            "base-sepolia",
+				FacilitatorURL:     facilitator.URL,
+				Rules:              []routestore.CompiledRule{{Path: "/api/*", Price: "0.001", Mode: "all-pay"}},
+				Backends:           []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL}},
+				OnFacilitatorError: tt.behavior,
+			})
+
+			req := httptest.NewRequest("GET", "/api/data", nil)
+			req.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+			w := httptest.NewRecorder()
+			NewHandler(store).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandlerSignsPaymentContext(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+	keys, err := backend.NewKeySet("k1", map[string][]byte{"k1": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})})
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	store := routestore.New()
+	h := NewHandler(store)
+	h.contextKeys = keys
+
+	// The backend verifies with the public keys the gateway publishes.
+	jwksSrv := httptest.NewServer(http.HandlerFunc(h.serveJWKS))
+	defer jwksSrv.Close()
+	published, err := backend.LoadJWKS(jwksSrv.URL, time.Minute)
+	if err != nil {
+		t.Fatalf("LoadJWKS() error = %v", err)
+	}
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := backend.Verify(published, r.Header.Get(backend.HeaderContext), time.Now())
+		if err != nil {
+			io.WriteString(w, "unverified")
+			return
+		}
+		if claims.Asset == "" {
+			io.WriteString(w, "no asset")
+			return
+		}
+		io.WriteString(w, claims.Payer+" "+claims.Amount+" "+claims.Settlement)
+	}))
+	defer backendSrv.Close()
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	route := &routestore.CompiledRoute{
+		Name:           "my-api",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules: []routestore.CompiledRule{
+			{Path: "/health", Free: true},
+			{Path: "/api/*", Price: "0.001", Mode: "all-pay"},
+			{Path: "/async/*", Price: "0.001", Mode: "all-pay", Settle: "async"},
+		},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	}
+	store.Set("default", "my-api", route)
+
+	// The context carries the atomic amount the client accepted, and whether
+	// it was charged before the request was forwarded.
+	for path, want := range map[string]string{
+		"/api/data":   "0xPayer 1000 " + backend.SettlementSettled,
+		"/async/data": "0xPayer 1000 " + backend.SettlementAuthorized,
+	} {
+		paid := httptest.NewRequest("GET", path, nil)
+		paid.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, paid)
+		if w.Body.String() != want {
+			t.Errorf("%s body = %q, want %q", path, w.Body.String(), want)
+		}
+	}
+
+	// A client-supplied context is stripped on free paths.
+	forged, _ := backend.Sign(keys, backend.Claims{Issuer: backend.Issuer, Path: "/health", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	free := httptest.NewRequest("GET", "/health", nil)
+	free.Header.Set(backend.HeaderContext, forged)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, free)
+	if w.Body.String() != "unverified" {
+		t.Errorf("free request body = %q, want client context stripped", w.Body.String())
+	}
+}
+
+func TestHandlerPriceOffers(t *testing.T) {
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-402-Offer")+"/"+r.Header.Get("X-Priority"))
+	}))
+	defer backendSrv.Close()
+	var settledAmount atomic.Value
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PaymentRequirements paymentAccept `json:"paymentRequirements"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		settledAmount.Store(req.PaymentRequirements.Amount)
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "my-api", &routestore.CompiledRoute{
+		Name:           "my-api",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules: []routestore.CompiledRule{{
+			Path:  "/api/*",
+			Price: "0.001",
+			Mode:  "all-pay",
+			Offers: []routestore.CompiledOffer{
+				{Name: "standard", Price: "0.001"},
+				{Name: "priority", Price: "0.005", Headers: map[string]string{"X-Priority": "high"}},
+			},
+		}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	})
+	h := NewHandler(store)
+
+	// Unpaid requests see one accepts entry per offer.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+	var reqs paymentRequirements
+	if err := json.Unmarshal(w.Body.Bytes(), &reqs); err != nil {
+		t.Fatalf("unmarshal 402 body: %v", err)
+	}
+	if len(reqs.Accepts) != 2 || reqs.Accepts[1].Extra.Offer != "priority" || reqs.Accepts[1].Amount != "5000" {
+		t.Fatalf("accepts = %+v, want standard and priority offers", reqs.Accepts)
+	}
+
+	tests := []struct {
+		name       string
+		payload    string
+		wantBody   string
+		wantAmount string
+	}{
+		{name: "offer by name", payload: `{"x402Version":2,"accepted":{"amount":"5000","extra":{"offer":"priority"}}}`, wantBody: "priority/high", wantAmount: "5000"},
+		{name: "offer by amount", payload: `{"x402Version":2,"accepted":{"amount":"5000"}}`, wantBody: "priority/high", wantAmount: "5000"},
+		{name: "no accepted requirements", payload: `{"x402Version":2}`, wantBody: "standard/", wantAmount: "1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/data", nil)
+			req.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(tt.payload)))
+			// Client-supplied offer headers never reach the backend.
+			req.Header.Set("X-Priority", "forged")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("backend saw %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := settledAmount.Load(); got != tt.wantAmount {
+				t.Errorf("settled amount = %v, want %s", got, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestHandlerWrongNetwork(t *testing.T) {
+	var facilitatorCalls atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		facilitatorCalls.Add(1)
+		io.WriteString(w, `{"isValid":true}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "my-api", &routestore.CompiledRoute{
+		Name:           "my-api",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules:          []routestore.CompiledRule{{Path: "/api/*", Price: "0.001", Mode: "all-pay"}},
+	})
+
+	req := httptest.NewRequest("GET", "/api/data", nil)
+	req.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2,"accepted":{"network":"eip155:8453"}}`)))
+	w := httptest.NewRecorder()
+	NewHandler(store).ServeHTTP(w, req)
+
+	if w.Code != http.StatusPaymentRequired {
+		t.Fatalf("status = %d, want 402", w.Code)
+	}
+	var reqs paymentRequirements
+	if err := json.Unmarshal(w.Body.Bytes(), &reqs); err != nil {
+		t.Fatalf("unmarshal 402 body: %v", err)
+	}
+	if !strings.HasPrefix(reqs.Error, "invalid_network:") || len(reqs.Accepts) != 1 {
+		t.Errorf("402 body error = %q, accepts = %d, want invalid_network with accepted requirements", reqs.Error, len(reqs.Accepts))
+	}
+	if n := facilitatorCalls.Load(); n != 0 {
+		t.Errorf("facilitator called %d times, want 0", n)
+	}
+}
+
+func TestHandlerScenarios(t *testing.T) {
+	tests := []struct {
+		name string
+		// rules of the catch-all route "api"; nil sets up overlapping routes
+		rules   []routestore.CompiledRule
+		setup   func(f *gatewaytest.Facilitator, b *gatewaytest.Backend)
+		request func() *http.Request
+
+		wantStatus   int
+		wantAmount   string // of the first accepted requirements of a 402
+		wantReason   string // error of a 402
+		wantBackend  int
+		wantVerify   int
+		wantSettle   int
+		wantResponse bool // PAYMENT-RESPONSE header sent
+	}{
+		{
+			name:       "free",
+			rules:      []routestore.CompiledRule{gatewaytest.FreeRule("/docs/*")},
+			request:    func() *http.Request { return gatewaytest.Request("GET", "/docs/intro") },
+			wantStatus: http.StatusOK, wantBackend: 1,
+		},
+		{
+			name:  "conditional, free",
+			rules: []routestore.CompiledRule{gatewaytest.ConditionalRule("/api/*", "0.01", "X-Tier", "^premium$")},
+			request: func() *http.Request {
+				r := gatewaytest.Request("GET", "/api/data")
+				r.Header.Set("X-Tier", "basic")
+				return r
+			},
+			wantStatus: http.StatusOK, wantBackend: 1,
+		},
+		{
+			name:  "conditional, paid",
+			rules: []routestore.CompiledRule{gatewaytest.ConditionalRule("/api/*", "0.01", "X-Tier", "^premium$")},
+			request: func() *http.Request {
+				r := gatewaytest.Request("GET", "/api/data")
+				r.Header.Set("X-Tier", "premium")
+				return r
+			},
+			wantStatus: http.StatusPaymentRequired, wantAmount: "10000",
+		},
+		{
+			name:       "paid without a payment",
+			rules:      []routestore.CompiledRule{gatewaytest.PaidRule("/api/*", "0.01")},
+			request:    func() *http.Request { return gatewaytest.Request("GET", "/api/data") },
+			wantStatus: http.StatusPaymentRequired, wantAmount: "10000",
+		},
+		{
+			name:       "invalid payment",
+			rules:      []routestore.CompiledRule{gatewaytest.PaidRule("/api/*", "0.01")},
+			setup:      func(f *gatewaytest.Facilitator, _ *gatewaytest.Backend) { f.Reject("insufficient_funds") },
+			request:    func() *http.Request { return gatewaytest.PaidRequest("GET", "/api/data") },
+			wantStatus: http.StatusPaymentRequired, wantAmount: "10000", wantVerify: 1,
+		},
+		{
+			name:       "valid payment",
+			rules:      []routestore.CompiledRule{gatewaytest.PaidRule("/api/*", "0.01")},
+			request:    func() *http.Request { return gatewaytest.PaidRequest("GET", "/api/data") },
+			wantStatus: http.StatusOK, wantBackend: 1, wantVerify: 1, wantSettle: 1, wantResponse: true,
+		},
+		{
+			name:       "settlement failed",
+			rules:      []routestore.CompiledRule{gatewaytest.PaidRule("/api/*", "0.01")},
+			setup:      func(f *gatewaytest.Facilitator, _ *gatewaytest.Backend) { f.FailSettlement("insufficient_funds") },
+			request:    func() *http.Request { return gatewaytest.PaidRequest("GET", "/api/data") },
+			wantStatus: http.StatusPaymentRequired, wantAmount: "10000", wantVerify: 1, wantSettle: 1,
+		},
+		{
+			name:       "backend down, settled first",
+			rules:      []routestore.CompiledRule{gatewaytest.PaidRule("/api/*", "0.01")},
+			setup:      func(_ *gatewaytest.Facilitator, b *gatewaytest.Backend) { b.Close() },
+			request:    func() *http.Request { return gatewaytest.PaidRequest("GET", "/api/data") },
+			wantStatus: http.StatusBadGateway, wantVerify: 1, wantSettle: 1, wantResponse: true,
+		},
+		{
+			name: "backend down, settled after the response",
+			rules: func() []routestore.CompiledRule {
+				rule := gatewaytest.PaidRule("/api/*", "0.01")
+				rule.Settle = settleAfterResponse
+				return []routestore.CompiledRule{rule}
+			}(),
+			setup:      func(_ *gatewaytest.Facilitator, b *gatewaytest.Backend) { b.Close() },
+			request:    func() *http.Request { return gatewaytest.PaidRequest("GET", "/api/data") },
+			wantStatus: http.StatusBadGateway, wantVerify: 1, wantResponse: true,
+		},
+		{
+			name:       "unmatched path",
+			rules:      []routestore.CompiledRule{gatewaytest.PaidRule("/api/*", "0.01")},
+			request:    func() *http.Request { return gatewaytest.Request("GET", "/blog/post") },
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "overlapping routes, host route",
+			request: func() *http.Request {
+				r := gatewaytest.Request("GET", "/api/data")
+				r.Host = "shop.example.com"
+				return r
+			},
+			wantStatus: http.StatusPaymentRequired, wantAmount: "50000",
+		},
+		{
+			name:       "overlapping routes, first by name",
+			request:    func() *http.Request { return gatewaytest.Request("GET", "/api/data") },
+			wantStatus: http.StatusPaymentRequired, wantAmount: "20000",
+		},
+		{
+			name:       "overlapping routes, free path of a later route",
+			request:    func() *http.Request { return gatewaytest.Request("GET", "/docs/intro") },
+			wantStatus: http.StatusOK, wantBackend: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facilitator := gatewaytest.NewFacilitator(t)
+			backend := gatewaytest.NewBackend(t)
+			routes := []*routestore.CompiledRoute{gatewaytest.Route("api", facilitator, backend.URL, tt.rules...)}
+			if tt.rules == nil {
+				shop := gatewaytest.Route("a-shop", facilitator, backend.URL, gatewaytest.PaidRule("/api/*", "0.05"))
+				shop.Hosts = []string{"shop.example.com"}
+				routes = []*routestore.CompiledRoute{
+					shop,
+					gatewaytest.Route("b-api", facilitator, backend.URL, gatewaytest.PaidRule("/api/*", "0.02")),
+					gatewaytest.Route("c-api", facilitator, backend.URL, gatewaytest.PaidRule("/api/*", "0.01"), gatewaytest.FreeRule("/docs/*")),
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(facilitator, backend)
+			}
+
+			w := gatewaytest.Serve(NewHandler(gatewaytest.Store(routes...)), tt.request())
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusPaymentRequired {
+				required := gatewaytest.DecodePaymentRequired(t, w)
+				if got := required.Accepts[0].Amount; got != tt.wantAmount {
+					t.Errorf("amount = %s, want %s", got, tt.wantAmount)
+				}
+			}
+			if got := len(backend.Calls()); got != tt.wantBackend {
+				t.Errorf("backend calls = %d, want %d", got, tt.wantBackend)
+			}
+			if got := len(facilitator.Calls("/verify")); got != tt.wantVerify {
+				t.Errorf("/verify calls = %d, want %d", got, tt.wantVerify)
+			}
+			if got := len(facilitator.Calls("/settle")); got != tt.wantSettle {
+				t.Errorf("/settle calls = %d, want %d", got, tt.wantSettle)
+			}
+			if got := w.Header().Get("PAYMENT-RESPONSE") != ""; got != tt.wantResponse {
+				t.Errorf("PAYMENT-RESPONSE sent = %v, want %v", got, tt.wantResponse)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+// headerIdempotencyKey names a client-chosen key identifying retries of one
+// request.
+const headerIdempotencyKey = "Idempotency-Key"
+
+const (
+	// maxIdempotencyKeyLength bounds the keys clients may choose.
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodyBytes bounds the request body fingerprinted for a key;
+	// larger requests are served without replay protection.
+	maxIdempotentBodyBytes = 1 << 20
+	// idempotencyPrefix starts the state store keys of idempotent requests,
+	// followed by "namespace/name rule-path key".
+	idempotencyPrefix = "idempotency:"
+)
+
+// idempotentEntry is a paid request in flight or its stored response, as kept
+// in the state store for the rule's idempotency window.
+type idempotentEntry struct {
+	Fingerprint []byte          `json:"fingerprint"`     // SHA-256 of the method, URL and body of the request
+	Receipt     []byte          `json:"receipt"`         // SHA-256 of the payment header that paid for it
+	Owner       string          `json:"owner,omitempty"` // identifies the request holding the key while in flight
+	Payer       string          `json:"payer,omitempty"`
+	Result      *storedResponse `json:"result,omitempty"` // nil while in flight
+}
+
+// idempotencyKey returns the Idempotency-Key of a paid POST to a rule that
+// replays them, or "".
+func idempotencyKey(r *http.Request, rule *routestore.CompiledRule) string {
+	if rule.Idempotency == nil || r.Method != http.MethodPost {
+		return ""
+	}
+	key := r.Header.Get(headerIdempotencyKey)
+	if len(key) > maxIdempotencyKeyLength {
+		return ""
+	}
+	return key
+}
+
+// beginIdempotent looks up a retry of the request. It answers the request
+// itself when the key has a stored response or conflicts, returning nil.
+// Otherwise it reserves the key and returns a writer that records the
+// response; call finish on it once the request is served.
+func (h *Handler) beginIdempotent(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule, path, key, paymentHeader string, accept *paymentAccept, start time.Time) *idempotentWriter {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodyBytes+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxIdempotentBodyBytes {
+		slog.Warn("request not eligible for idempotent replay", "path", path, "route", route.Name, "bodyBytes", len(body), "error", err)
+		return &idempotentWriter{ResponseWriter: w}
+	}
+
+	fp := sha256.New()
+	io.WriteString(fp, r.Method+" "+r.URL.String()+"\n")
+	fp.Write(body)
+	receipt := sha256.Sum256([]byte(paymentHeader))
+	entry := idempotentEntry{Fingerprint: fp.Sum(nil), Receipt: receipt[:], Owner: newID()}
+
+	// The first request reserves the key; retries find the reservation or
+	// the stored response, on whichever replica they arrive.
+	ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+	defer cancel()
+	storeKey := idempotencyPrefix + route.Namespace + "/" + route.Name + " " + rule.Path + " " + key
+	reservation, _ := json.Marshal(entry)
+	added, err := h.state.Add(ctx, storeKey, reservation, rule.Idempotency.Window)
+	if err == nil && added {
+		return &idempotentWriter{ResponseWriter: w, store: h.state, ctx: context.WithoutCancel(r.Context()), key: storeKey, entry: entry,
+			window: rule.Idempotency.Window, buf: newResponseBuffer(rule.Idempotency.MaxResponseBytes)}
+	}
+	var existing idempotentEntry
+	if err == nil {
+		var raw []byte
+		if raw, err = h.state.Get(ctx, storeKey); err == nil {
+			err = json.Unmarshal(raw, &existing)
+		}
+	}
+	if err != nil {
+		slog.Warn("idempotency state unavailable, serving without replay protection", "path", path, "route", route.Name, "error", err)
+		return &idempotentWriter{ResponseWriter: w}
+	}
+
+	switch {
+	case !bytes.Equal(existing.Fingerprint, entry.Fingerprint):
+		metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "idempotency_conflict").Inc()
+		http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+		return nil
+	case existing.Result == nil:
+		metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "idempotency_conflict").Inc()
+		http.Error(w, "a request with this Idempotency-Key is in progress", http.StatusConflict)
+		return nil
+	}
+
+	// A retry may carry the same payment or a new one from the same payer;
+	// the new one is verified but never settled.
+	if !bytes.Equal(existing.Receipt, entry.Receipt) {
+		_, verified, err := verifyPayment(r.Context(), paymentHeader, accept, route)
+		if err != nil {
+			h.paymentFailed(w, r, route, rule, path, err, start)
+			return nil
+		}
+		if !strings.EqualFold(verified.Payer, existing.Payer) {
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "idempotency_conflict").Inc()
+			http.Error(w, "Idempotency-Key was already used by another payer", http.StatusConflict)
+			return nil
+		}
+	}
+
+	slog.Info("replaying idempotent response", "path", path, "route", route.Name)
+	metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "idempotent_replay").Inc()
+	w.Header().Set("Idempotent-Replayed", "true")
+	existing.Result.writeTo(w)
+	return nil
+}
+
+// idempotentWriter holds back a response until the request is served, so a
+// response cut short by a client disconnect is still recorded in full for the
+// retry. Responses outgrowing the buffer are streamed and not recorded.
+type idempotentWriter struct {
+	http.ResponseWriter
+	store     storage.Interface // nil when the request is not recorded
+	ctx       context.Context   // outlives the client connection
+	key       string
+	entry     idempotentEntry
+	window    time.Duration
+	buf       *responseBuffer
+	streaming bool
+}
+
+func (w *idempotentWriter) WriteHeader(status int) {
+	if w.store == nil || w.streaming {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.buf.WriteHeader(status)
+}
+
+func (w *idempotentWriter) Write(p []byte) (int, error) {
+	if w.store == nil || w.streaming {
+		return w.ResponseWriter.Write(p)
+	}
+	if int64(w.buf.body.Len()+len(p)) > w.buf.limit {
+		// Too large to record: release the key and stream the rest.
+		w.streaming = true
+		w.release()
+		if w.buf.status == 0 {
+			w.buf.status = http.StatusOK
+		}
+		w.ResponseWriter.WriteHeader(w.buf.status)
+		if _, err := w.ResponseWriter.Write(w.buf.body.Bytes()); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// Flush flushes streamed responses; held back responses are sent by finish.
+func (w *idempotentWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && (w.store == nil || w.streaming) {
+		f.Flush()
+	}
+}
+
+// finish sends a held back response and stores it if the request was paid
+// for; otherwise it releases the key so the client can retry.
+func (w *idempotentWriter) finish() {
+	if w.store == nil || w.streaming {
+		return
+	}
+	if w.buf.status == 0 {
+		w.buf.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.buf.status)
+	w.ResponseWriter.Write(w.buf.body.Bytes())
+
+	ctx, cancel := context.WithTimeout(w.ctx, storeTimeout)
+	defer cancel()
+	if !w.reserved(ctx) {
+		return
+	}
+
+	var settled settleResponse
+	raw, err := base64.StdEncoding.DecodeString(w.Header().Get("PAYMENT-RESPONSE"))
+	if err != nil || json.Unmarshal(raw, &settled) != nil || !settled.Success {
+		w.store.Delete(ctx, w.key)
+		return
+	}
+	for name, values := range w.Header() {
+		w.buf.header[name] = values
+	}
+	entry := w.entry
+	entry.Owner, entry.Payer, entry.Result = "", settled.Payer, w.buf.stored()
+	stored, _ := json.Marshal(entry)
+	if err := w.store.Set(ctx, w.key, stored, w.window); err != nil {
+		slog.Warn("idempotent response not stored, retries will be refused", "key", w.key, "error", err)
+	}
+}
+
+// release drops the reservation of a response that will not be recorded.
+func (w *idempotentWriter) release() {
+	ctx, cancel := context.WithTimeout(w.ctx, storeTimeout)
+	defer cancel()
+	if w.reserved(ctx) {
+		w.store.Delete(ctx, w.key)
+	}
+}
+
+// reserved reports whether the key is still reserved for this request, and
+// not taken over by another once the reservation expired.
+func (w *idempotentWriter) reserved(ctx context.Context) bool {
+	raw, err := w.store.Get(ctx, w.key)
+	if err != nil {
+		return false
+	}
+	var current idempotentEntry
+	return json.Unmarshal(raw, &current) == nil && current.Owner == w.entry.Owner
+}
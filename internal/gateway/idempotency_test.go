@@ -0,0 +1,156 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+func TestHandlerIdempotentReplay(t *testing.T) {
+	var calls atomic.Int32
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Order", "42")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "order "+string(body)+" #"+string(rune('0'+n)))
+	}))
+	defer backendSrv.Close()
+	var settles atomic.Int32
+	var rejectSettle atomic.Bool
+	var payer atomic.Value
+	payer.Store("0xPayer")
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"`+payer.Load().(string)+`"}`)
+			return
+		}
+		if rejectSettle.Load() {
+			io.WriteString(w, `{"success":false,"errorReason":"insufficient_funds"}`)
+			return
+		}
+		settles.Add(1)
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "orders", &routestore.CompiledRoute{
+		Name:           "orders",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules: []routestore.CompiledRule{{
+			Path:        "/orders",
+			Price:       "0.01",
+			Mode:        "all-pay",
+			Idempotency: &routestore.CompiledIdempotency{Window: time.Minute, MaxResponseBytes: 1024},
+		}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	})
+	h := NewHandler(store)
+	replica := NewHandler(store)
+	replica.setState(h.state)
+
+	payment := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`))
+	retryPayment := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2,"retry":true}`))
+	postTo := func(h *Handler, key, body, paymentHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set(headerIdempotencyKey, key)
+		if paymentHeader != "" {
+			req.Header.Set("Payment-Signature", paymentHeader)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	post := func(key, body, paymentHeader string) *httptest.ResponseRecorder {
+		return postTo(h, key, body, paymentHeader)
+	}
+
+	first := post("k1", "book", payment)
+	if first.Code != http.StatusCreated || first.Body.String() != "order book #1" {
+		t.Fatalf("first = %d %q, want 201 %q", first.Code, first.Body.String(), "order book #1")
+	}
+
+	// Retrying with the same payment replays the stored response.
+	w := post("k1", "book", payment)
+	if w.Code != http.StatusCreated || w.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %q, want 201 %q", w.Code, w.Body.String(), first.Body.String())
+	}
+	if w.Header().Get("Idempotent-Replayed") != "true" || w.Header().Get("X-Order") != "42" || w.Header().Get("PAYMENT-RESPONSE") == "" {
+		t.Errorf("replay headers = %v", w.Header())
+	}
+	// So does a retry reaching another replica sharing the state store.
+	if w := postTo(replica, "k1", "book", payment); w.Code != http.StatusCreated || w.Body.String() != first.Body.String() {
+		t.Errorf("replay on a replica = %d %q, want 201 %q", w.Code, w.Body.String(), first.Body.String())
+	}
+
+	// A new payment from the same payer is verified but not settled.
+	if w := post("k1", "book", retryPayment); w.Code != http.StatusCreated || w.Body.String() != first.Body.String() {
+		t.Errorf("replay with new payment = %d %q, want 201 %q", w.Code, w.Body.String(), first.Body.String())
+	}
+	payer.Store("0xOther")
+	if w := post("k1", "book", retryPayment); w.Code != http.StatusConflict {
+		t.Errorf("replay for another payer: status = %d, want 409", w.Code)
+	}
+	payer.Store("0xPayer")
+
+	if w := post("k1", "other", payment); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body: status = %d, want 422", w.Code)
+	}
+	if calls.Load() != 1 || settles.Load() != 1 {
+		t.Errorf("backend calls = %d, settles = %d, want 1 and 1", calls.Load(), settles.Load())
+	}
+
+	// Unpaid and failed requests release the key for a retry.
+	if w := post("k2", "book", ""); w.Code != http.StatusPaymentRequired {
+		t.Fatalf("unpaid: status = %d, want 402", w.Code)
+	}
+	if w := post("k2", "book", payment); w.Code != http.StatusCreated {
+		t.Errorf("after unpaid attempt: status = %d, want 201", w.Code)
+	}
+	rejectSettle.Store(true)
+	if w := post("k3", "book", payment); w.Code != http.StatusPaymentRequired {
+		t.Fatalf("failed settlement: status = %d, want 402", w.Code)
+	}
+	rejectSettle.Store(false)
+	if w := post("k3", "book", payment); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("after failed settlement = %d replayed=%q, want a fresh 201", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+func TestIdempotentWriterStreamsLargeResponses(t *testing.T) {
+	store := storage.NewMemory()
+	entry := idempotentEntry{Owner: "request-1"}
+	reservation, _ := json.Marshal(entry)
+	store.Set(context.Background(), "k", reservation, time.Minute)
+	rec := httptest.NewRecorder()
+	w := &idempotentWriter{ResponseWriter: rec, store: store, ctx: context.Background(), key: "k", entry: entry, window: time.Minute, buf: newResponseBuffer(4)}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("abc"))
+	if rec.Body.Len() != 0 {
+		t.Fatal("response was not held back")
+	}
+	w.Write([]byte("def"))
+	w.finish()
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "abcdef" {
+		t.Errorf("response = %d %q, want 202 %q", rec.Code, rec.Body.String(), "abcdef")
+	}
+	if _, err := store.Get(context.Background(), "k"); !errors.Is(err, storage.ErrNotFound) {
+		t.Error("oversized response kept its reservation")
+	}
+}
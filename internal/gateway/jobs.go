@@ -0,0 +1,211 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+// JobsPath is the prefix of the URLs async jobs are polled at.
+const JobsPath = "/.well-known/x402/jobs/"
+
+const (
+	// maxJobs bounds the jobs a gateway replica runs at once.
+	maxJobs = 1024
+	// maxJobRequestBytes bounds the request body kept for a background job.
+	maxJobRequestBytes = 10 << 20
+	// jobRetryAfter is the polling interval suggested to clients.
+	jobRetryAfter = "5"
+	// jobsPrefix starts the state store keys of async jobs, followed by the
+	// job ID.
+	jobsPrefix = "jobs:"
+)
+
+var (
+	errTooManyJobs     = errors.New("too many async jobs in progress, retry later")
+	errJobBodyTooLarge = errors.New("request body too large for an async job")
+)
+
+// jobStore runs the background jobs of async rules. Jobs are kept in the
+// state store, so they can be polled at any replica sharing it.
+type jobStore struct {
+	store storage.Interface
+
+	mu      sync.Mutex
+	running int // jobs run by this replica
+}
+
+// job is a paid request proxied in the background, as kept in the state
+// store.
+type job struct {
+	Receipt []byte          `json:"receipt"` // SHA-256 of the payment header that paid for the job
+	Settled *settleResponse `json:"settled,omitempty"`
+	Result  *storedResponse `json:"result,omitempty"` // nil while running
+}
+
+func newJobStore(store storage.Interface) *jobStore {
+	return &jobStore{store: store}
+}
+
+// admit checks that a job can be started for the request and buffers its
+// body, which must outlive the client connection. It runs before payment so
+// a request that cannot be served is not charged.
+func (s *jobStore) admit(r *http.Request) error {
+	s.mu.Lock()
+	full := s.running >= maxJobs
+	s.mu.Unlock()
+	if full {
+		return errTooManyJobs
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxJobRequestBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxJobRequestBytes {
+		return errJobBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// start proxies the paid request in the background and answers 202 with the
+// job URL. The settle response is returned again with the result. release is
+// called once the backend has answered. A job that cannot be stored is served
+// in the foreground instead, since its payment is settled.
+func (s *jobStore) start(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule, path, paymentHeader string, settled *settleResponse, release func()) {
+	id := newID()
+	receipt := sha256.Sum256([]byte(paymentHeader))
+	j := job{Receipt: receipt[:], Settled: settled}
+
+	// The job is kept until its result expires, even if it runs until its
+	// timeout.
+	if err := s.save(context.WithoutCancel(r.Context()), id, j, rule.Async.Timeout+rule.Async.ResultTTL); err != nil {
+		slog.Error("async job not stored, serving it in the foreground", "path", path, "route", route.Name, "error", err)
+		setPaymentResponse(w, settled)
+		proxyToBackend(w, r, route, rule, path)
+		if release != nil {
+			release()
+		}
+		return
+	}
+	s.mu.Lock()
+	s.running++
+	s.mu.Unlock()
+	metrics.AsyncJobs.WithLabelValues("running").Inc()
+
+	ctx, cancel := context.WithTimeout(context.Background(), rule.Async.Timeout)
+	req := r.Clone(ctx)
+	go func() {
+		defer cancel()
+		buf := newResponseBuffer(rule.Async.MaxResultBytes)
+		proxyToBackend(buf, req, route, rule, path)
+		if release != nil {
+			release()
+		}
+		if buf.overflow {
+			slog.Error("async job result too large", "job", id, "path", path, "route", route.Name, "limit", rule.Async.MaxResultBytes)
+			buf = newResponseBuffer(0)
+			buf.status = http.StatusBadGateway
+			buf.body.WriteString("async job result too large\n")
+		}
+		j.Result = buf.stored()
+		if err := s.save(context.Background(), id, j, rule.Async.ResultTTL); err != nil {
+			slog.Error("async job result not stored", "job", id, "path", path, "route", route.Name, "error", err)
+		}
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+		metrics.AsyncJobs.WithLabelValues("running").Dec()
+		slog.Info("async job finished", "job", id, "path", path, "route", route.Name, "status", buf.status)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", JobsPath+id)
+	w.Header().Set("Retry-After", jobRetryAfter)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(jobStatus{ID: id, Status: "running", URL: JobsPath + id})
+}
+
+// save writes j to the state store, expiring after ttl.
+func (s *jobStore) save(ctx context.Context, id string, j job, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	raw, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(ctx, jobsPrefix+id, raw, ttl)
+}
+
+// jobStatus is the body of 202 responses for running jobs.
+type jobStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	URL    string `json:"url"`
+}
+
+// serveJob answers polls for a job. Only the client holding the payment
+// header that paid for the job may read it; anyone else gets 404.
+func (s *jobStore) serveJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	receipt := sha256.Sum256([]byte(getPaymentHeader(r)))
+
+	ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+	defer cancel()
+	raw, err := s.store.Get(ctx, jobsPrefix+id)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		slog.Error("async job state unavailable", "job", id, "error", err)
+		w.Header().Set("Retry-After", jobRetryAfter)
+		http.Error(w, "job state unavailable, retry later", http.StatusServiceUnavailable)
+		return
+	}
+	var j job
+	if err != nil || json.Unmarshal(raw, &j) != nil || subtle.ConstantTimeCompare(j.Receipt, receipt[:]) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if j.Result == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", jobRetryAfter)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(jobStatus{ID: id, Status: "running", URL: JobsPath + id})
+		return
+	}
+	setPaymentResponse(w, j.Settled)
+	j.Result.writeTo(w)
+}
+
+// newID returns a random 128-bit identifier in hex.
+func newID() string {
+	var raw [16]byte
+	rand.Read(raw[:])
+	return hex.EncodeToString(raw[:])
+}
+
+// setPaymentResponse sets the PAYMENT-RESPONSE header to the Base64-encoded
+// settle response.
+func setPaymentResponse(w http.ResponseWriter, settled *settleResponse) {
+	if settled == nil {
+		return
+	}
+	if settleJSON, err := json.Marshal(settled); err == nil {
+		w.Header().Set("PAYMENT-RESPONSE", base64.StdEncoding.EncodeToString(settleJSON))
+	}
+}
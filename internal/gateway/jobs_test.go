@@ -0,0 +1,137 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+func TestHandlerAsyncJobs(t *testing.T) {
+	release := make(chan struct{})
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Job", "done")
+		io.WriteString(w, "processed "+string(body))
+	}))
+	defer backendSrv.Close()
+	var settles atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		settles.Add(1)
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "batch", &routestore.CompiledRoute{
+		Name:           "batch",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules: []routestore.CompiledRule{{
+			Path:  "/jobs/*",
+			Price: "0.01",
+			Mode:  "all-pay",
+			Async: &routestore.CompiledAsync{Timeout: time.Minute, ResultTTL: time.Minute, MaxResultBytes: 1024},
+		}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	})
+	h := NewHandler(store)
+	// Polls are answered by another replica sharing the state store.
+	replica := NewHandler(store)
+	replica.setState(h.state)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+JobsPath+"{id}", replica.jobs.serveJob)
+	mux.Handle("/", h)
+
+	payment := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`))
+	poll := func(location, paymentHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", location, nil)
+		if paymentHeader != "" {
+			req.Header.Set("Payment-Signature", paymentHeader)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest("POST", "/jobs/render", strings.NewReader("scene-1"))
+	req.Header.Set("Payment-Signature", payment)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	if w.Header().Get("PAYMENT-RESPONSE") == "" || settles.Load() != 1 {
+		t.Fatal("payment was not settled before the job was accepted")
+	}
+	var status jobStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unmarshal job status: %v", err)
+	}
+	location := w.Header().Get("Location")
+	if location != status.URL || !strings.HasPrefix(location, JobsPath) {
+		t.Fatalf("Location = %q, body url = %q", location, status.URL)
+	}
+
+	// The job is only visible to the holder of the payment that paid for it.
+	if w := poll(location, ""); w.Code != http.StatusNotFound {
+		t.Errorf("poll without payment: status = %d, want 404", w.Code)
+	}
+	other := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2,"other":true}`))
+	if w := poll(location, other); w.Code != http.StatusNotFound {
+		t.Errorf("poll with other payment: status = %d, want 404", w.Code)
+	}
+	if w := poll(location, payment); w.Code != http.StatusAccepted {
+		t.Errorf("poll while running: status = %d, want 202", w.Code)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w = poll(location, payment)
+		if w.Code != http.StatusAccepted || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "processed scene-1" || w.Header().Get("X-Job") != "done" {
+		t.Fatalf("result = %d %q, want 200 %q", w.Code, w.Body.String(), "processed scene-1")
+	}
+	if w.Header().Get("PAYMENT-RESPONSE") == "" {
+		t.Error("result is missing PAYMENT-RESPONSE")
+	}
+	if settles.Load() != 1 {
+		t.Errorf("settled %d times, want 1", settles.Load())
+	}
+}
+
+func TestJobStoreAdmit(t *testing.T) {
+	s := newJobStore(storage.NewMemory())
+	req := httptest.NewRequest("POST", "/jobs/render", strings.NewReader("payload"))
+	if err := s.admit(req); err != nil {
+		t.Fatalf("admit() error = %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "payload" {
+		t.Errorf("buffered body = %q, want %q", body, "payload")
+	}
+
+	s.running = maxJobs
+	if err := s.admit(httptest.NewRequest("POST", "/jobs/render", nil)); err != errTooManyJobs {
+		t.Errorf("admit() on a full store error = %v, want %v", err, errTooManyJobs)
+	}
+}
@@ -0,0 +1,169 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ResolveBindAddress returns addr with a network interface name in its host
+// part (e.g. "eth0:8402") replaced by an address of that interface: its first
+// IPv4 address, or its first IPv6 address when it has none. IP literals,
+// empty hosts and host names are returned unchanged; IPv6 literals must be
+// bracketed, as in "[::]:8402", which listens on both IPv4 and IPv6.
+func ResolveBindAddress(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		if strings.Count(addr, ":") > 1 && !strings.HasPrefix(addr, "[") {
+			return "", fmt.Errorf("bind address %q: IPv6 addresses must be bracketed, e.g. [::]:8402", addr)
+		}
+		// Left to the listener, e.g. "0" disabling the metrics server.
+		return addr, nil
+	}
+	if host == "" {
+		return addr, nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return addr, nil
+	}
+	ifi, err := net.InterfaceByName(host)
+	if err != nil {
+		// Not an interface; the listener resolves it as a host name.
+		return addr, nil
+	}
+	ip, err := interfaceAddr(ifi)
+	if err != nil {
+		return "", fmt.Errorf("bind address %q: %w", addr, err)
+	}
+	return net.JoinHostPort(ip, port), nil
+}
+
+// resolveBindAddresses resolves each of the comma-separated addresses in
+// addrs with ResolveBindAddress.
+func resolveBindAddresses(addrs string) ([]string, error) {
+	var resolved []string
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		r, err := ResolveBindAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no bind address in %q", addrs)
+	}
+	return resolved, nil
+}
+
+// interfaceAddr returns the address of ifi to bind to. Link-local IPv6
+// addresses carry the interface as their zone.
+func interfaceAddr(ifi *net.Interface) (string, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("interface %s: %w", ifi.Name, err)
+	}
+	var v6 string
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+		if err != nil {
+			continue
+		}
+		ip := prefix.Addr()
+		if ip.Is4() || ip.Is4In6() {
+			return ip.Unmap().String(), nil
+		}
+		if v6 == "" {
+			if ip.IsLinkLocalUnicast() {
+				ip = ip.WithZone(ifi.Name)
+			}
+			v6 = ip.String()
+		}
+	}
+	if v6 == "" {
+		return "", fmt.Errorf("interface %s has no IP address", ifi.Name)
+	}
+	return v6, nil
+}
+
+// loopbackFor returns the loopback address that reaches a listener bound to
+// host: the host itself, or the loopback address of its family when it is
+// empty or unspecified ("0.0.0.0", "::").
+func loopbackFor(host string) string {
+	if host == "" {
+		return "127.0.0.1"
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !ip.IsUnspecified() {
+		return host
+	}
+	if ip.Is4() {
+		return "127.0.0.1"
+	}
+	return "::1"
+}
+
+// ListenerOptions tunes the gateway's listening sockets for high request
+// rates.
+type ListenerOptions struct {
+	// ReusePort is the number of sockets opened per address with
+	// SO_REUSEPORT, each with its own accept queue and accept loop, so the
+	// kernel spreads new connections across them. 0 opens a single socket
+	// without SO_REUSEPORT.
+	ReusePort int
+	// Backlog is the length of each socket's queue of connections waiting
+	// to be accepted, capped by net.core.somaxconn. 0 keeps the system
+	// default.
+	Backlog int
+	// KeepAlive configures TCP keep-alive probes of accepted connections.
+	// The zero value keeps Go's defaults (15s idle and interval, 9 probes);
+	// a negative Idle without Enable disables the probes.
+	KeepAlive net.KeepAliveConfig
+}
+
+// listen opens the listening sockets of addr.
+func (o ListenerOptions) listen(ctx context.Context, addr string) ([]net.Listener, error) {
+	lc := net.ListenConfig{KeepAliveConfig: o.KeepAlive}
+	if !o.KeepAlive.Enable && o.KeepAlive.Idle < 0 {
+		lc.KeepAlive = -1
+	}
+	count := 1
+	if o.ReusePort > 0 {
+		count = o.ReusePort
+		lc.Control = func(_, _ string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) { sockErr = setReusePort(fd) }); err != nil {
+				return err
+			}
+			return sockErr
+		}
+	}
+
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+	for range count {
+		ln, err := lc.Listen(ctx, "tcp", addr)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+		if o.Backlog > 0 {
+			if err := setBacklog(ln, o.Backlog); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("listen backlog of %s: %w", addr, err)
+			}
+		}
+	}
+	return listeners, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package gateway
+
+import (
+	"errors"
+	"net"
+)
+
+// errSocketTuning is returned for listener options only supported on Linux.
+var errSocketTuning = errors.New("SO_REUSEPORT and listen backlog tuning are only supported on Linux")
+
+func setReusePort(uintptr) error { return errSocketTuning }
+
+func setBacklog(net.Listener, int) error { return errSocketTuning }
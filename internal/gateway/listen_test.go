@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveBindAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{addr: ":8402", want: ":8402"},
+		{addr: "0.0.0.0:8402", want: "0.0.0.0:8402"},
+		{addr: "[::]:8402", want: "[::]:8402"},
+		{addr: "[fe80::1%eth0]:8402", want: "[fe80::1%eth0]:8402"},
+		{addr: "localhost:8402", want: "localhost:8402"},
+		{addr: "0", want: "0"},
+		{addr: "::8402", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveBindAddress(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveBindAddress(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResolveBindAddress(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestResolveBindAddressInterface(t *testing.T) {
+	if _, err := net.InterfaceByName("lo"); err != nil {
+		t.Skip("no lo interface")
+	}
+	got, err := ResolveBindAddress("lo:8402")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "127.0.0.1:8402" {
+		t.Errorf("ResolveBindAddress(lo:8402) = %q", got)
+	}
+}
+
+func TestResolveBindAddresses(t *testing.T) {
+	got, err := resolveBindAddresses("0.0.0.0:8402, [::1]:8402")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "0.0.0.0:8402,[::1]:8402" {
+		t.Errorf("addresses = %v", got)
+	}
+	if _, err := resolveBindAddresses(" , "); err == nil {
+		t.Error("expected an error without addresses")
+	}
+}
+
+func TestLoopbackFor(t *testing.T) {
+	for host, want := range map[string]string{
+		"":          "127.0.0.1",
+		"0.0.0.0":   "127.0.0.1",
+		"::":        "::1",
+		"10.0.0.5":  "10.0.0.5",
+		"localhost": "localhost",
+	} {
+		if got := loopbackFor(host); got != want {
+			t.Errorf("loopbackFor(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestListenerOptionsReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT is only supported on Linux")
+	}
+	first, err := ListenerOptions{ReusePort: 1}.listen(context.Background(), "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first[0].Close()
+	addr := first[0].Addr().String()
+
+	more, err := ListenerOptions{ReusePort: 2, Backlog: 16}.listen(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("listen with SO_REUSEPORT on %s: %v", addr, err)
+	}
+	if len(more) != 2 {
+		t.Fatalf("listeners = %d, want 2", len(more))
+	}
+	for _, ln := range more {
+		ln.Close()
+	}
+
+	if _, err := (ListenerOptions{}).listen(context.Background(), addr); err == nil {
+		t.Error("expected a socket without SO_REUSEPORT to fail on a taken port")
+	}
+}
@@ -34,21 +34,62 @@ func matchPath(pattern, path string) bool {
 		return cleanPath == prefix || strings.HasPrefix(cleanPath, prefix+"/")
 	}
 
-	// Segment-by-segment matching with single * wildcards.
-	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
-
-	if len(patternParts) != len(pathParts) {
-		return false
+	// Segment-by-segment matching with single * wildcards. Segments are walked
+	// in place to keep the hot path allocation-free.
+	pattern = strings.Trim(pattern, "/")
+	path = strings.Trim(path, "/")
+	for {
+		patternSeg, patternRest, patternMore := strings.Cut(pattern, "/")
+		pathSeg, pathRest, pathMore := strings.Cut(path, "/")
+		if patternSeg != "*" && patternSeg != pathSeg {
+			return false
+		}
+		if patternMore != pathMore {
+			return false
+		}
+		if !patternMore {
+			return true
+		}
+		pattern, path = patternRest, pathRest
 	}
+}
 
-	for i, pp := range patternParts {
-		if pp == "*" {
-			continue
+// matchIngressPath checks if a request path matches an Ingress path with the
+// given pathType:
+//   - Exact: the path must match exactly (case-sensitive).
+//   - Prefix: matches element-wise on "/"-separated segments, so "/foo" matches
+//     "/foo" and "/foo/bar" but not "/foobar". A trailing slash is ignored.
+//   - ImplementationSpecific: falls back to matchPath after stripping the NGINX
+//     regex suffix "(.*)".
+func matchIngressPath(pathType, ingressPath, path string) bool {
+	switch pathType {
+	case "Exact":
+		return ingressPath == path
+	case "Prefix":
+		prefix := strings.TrimRight(ingressPath, "/")
+		if prefix == "" {
+			return true
 		}
-		if pp != pathParts[i] {
-			return false
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	default:
+		clean := strings.TrimSuffix(ingressPath, "(.*)")
+		if clean != ingressPath {
+			return strings.HasPrefix(path, clean)
 		}
+		return matchPath(ingressPath, path)
+	}
+}
+
+// matchHost checks if a request host matches an Ingress host. Wildcard hosts
+// follow Ingress semantics: "*.example.com" matches "foo.example.com" but not
+// "example.com" or "bar.foo.example.com".
+func matchHost(pattern, host string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return strings.EqualFold(pattern, host)
+	}
+	label, rest, ok := strings.Cut(host, ".")
+	if !ok || label == "" {
+		return false
 	}
-	return true
+	return strings.EqualFold(rest, pattern[2:])
 }
@@ -0,0 +1,129 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestMatchIngressPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		pathType    string
+		ingressPath string
+		path        string
+		want        bool
+	}{
+		{name: "exact match", pathType: "Exact", ingressPath: "/foo", path: "/foo", want: true},
+		{name: "exact rejects trailing slash", pathType: "Exact", ingressPath: "/foo", path: "/foo/", want: false},
+		{name: "exact rejects subpath", pathType: "Exact", ingressPath: "/foo", path: "/foo/bar", want: false},
+		{name: "prefix matches itself", pathType: "Prefix", ingressPath: "/foo", path: "/foo", want: true},
+		{name: "prefix matches subpath", pathType: "Prefix", ingressPath: "/foo", path: "/foo/bar", want: true},
+		{name: "prefix ignores trailing slash", pathType: "Prefix", ingressPath: "/foo/", path: "/foo", want: true},
+		{name: "prefix is element-wise", pathType: "Prefix", ingressPath: "/foo", path: "/foobar", want: false},
+		{name: "prefix / matches all", pathType: "Prefix", ingressPath: "/", path: "/anything", want: true},
+		{name: "implementation specific nginx regex", pathType: "ImplementationSpecific", ingressPath: "/api(.*)", path: "/api/v1", want: true},
+		{name: "implementation specific glob", pathType: "ImplementationSpecific", ingressPath: "/api/*", path: "/api/v1", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchIngressPath(tt.pathType, tt.ingressPath, tt.path)
+			if got != tt.want {
+				t.Errorf("matchIngressPath(%q, %q, %q) = %v, want %v", tt.pathType, tt.ingressPath, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindBackend(t *testing.T) {
+	backends := []routestore.CompiledBackend{
+		{Path: "/", PathType: "Prefix", URL: "http://root"},
+		{Path: "/api", PathType: "Prefix", URL: "http://api"},
+		{Path: "/api/status", PathType: "Exact", URL: "http://status"},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/api/status", want: "http://status"},
+		{path: "/api/status/detail", want: "http://api"},
+		{path: "/api/users", want: "http://api"},
+		{path: "/apis", want: "http://root"},
+		{path: "/", want: "http://root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := findBackend(backends, tt.path)
+			if got == nil || got.URL != tt.want {
+				t.Errorf("findBackend(%q) = %+v, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got := findBackend(backends[2:], "/other"); got == nil || got.URL != "http://status" {
+		t.Errorf("findBackend fallback = %+v, want %q", got, "http://status")
+	}
+	if got := findBackend(nil, "/"); got != nil {
+		t.Errorf("findBackend(nil) = %+v, want nil", got)
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{pattern: "api.example.com", host: "api.example.com", want: true},
+		{pattern: "api.example.com", host: "API.example.com", want: true},
+		{pattern: "api.example.com", host: "web.example.com", want: false},
+		{pattern: "*.example.com", host: "foo.example.com", want: true},
+		{pattern: "*.example.com", host: "example.com", want: false},
+		{pattern: "*.example.com", host: "bar.foo.example.com", want: false},
+		{pattern: "*.example.com", host: ".example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.host, func(t *testing.T) {
+			if got := matchHost(tt.pattern, tt.host); got != tt.want {
+				t.Errorf("matchHost(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindMatchingRuleVirtualHosts(t *testing.T) {
+	route := &routestore.CompiledRoute{Rules: []routestore.CompiledRule{
+		{Path: "/v1/**", Price: "0.01"},
+		{Path: "/v1/**", Host: "data.example.com", Price: "0.05"},
+		{Path: "/v1/search", Host: "*.example.com", Price: "0.02"},
+		{Path: "/v1/search", Host: "api.example.com", Price: "0.03"},
+	}}
+	tests := []struct {
+		host, path string
+		want       string
+	}{
+		{host: "data.example.com", path: "/v1/items", want: "0.05"},
+		{host: "DATA.example.com", path: "/v1/search", want: "0.05"},
+		{host: "api.example.com", path: "/v1/search", want: "0.02"},
+		{host: "api.example.com", path: "/v1/items", want: "0.01"},
+		{host: "example.com", path: "/v1/search", want: "0.01"},
+		{host: "other.test", path: "/v1/items", want: "0.01"},
+		{host: "data.example.com", path: "/v2", want: ""},
+	}
+	h := &Handler{}
+	for _, tt := range tests {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			var got string
+			if rule, ok := h.findMatchingRule(tt.host, tt.path, route); ok {
+				got = rule.Price
+			}
+			if got != tt.want {
+				t.Errorf("findMatchingRule(%q, %q) price = %q, want %q", tt.host, tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// schemeUpto authorizes a maximum amount and settles a lower final amount.
+const schemeUpto = "upto"
+
+// facilitatorSupportTTL is how long a facilitator's supported payment kinds
+// are cached; failed lookups are retried sooner.
+const (
+	facilitatorSupportTTL      = 10 * time.Minute
+	facilitatorSupportRetryTTL = time.Minute
+)
+
+// facilitatorKinds caches the payment kinds each facilitator supports.
+var facilitatorKinds = &supportCache{entries: make(map[string]supportEntry)}
+
+type supportCache struct {
+	mu      sync.Mutex
+	entries map[string]supportEntry // key: facilitator URL
+}
+
+type supportEntry struct {
+	kinds     map[string]bool // key: "scheme/network"
+	reachable bool            // the last /supported lookup succeeded
+	checked   time.Time
+	expires   time.Time
+}
+
+// supports reports whether the facilitator settles the scheme on the network,
+// as advertised by its /supported endpoint.
+func (c *supportCache) supports(facilitatorURL, scheme, network string) bool {
+	return c.lookup(facilitatorURL).kinds[scheme+"/"+network]
+}
+
+// lookup returns the cached entry of a facilitator, fetching it when missing
+// or expired.
+func (c *supportCache) lookup(facilitatorURL string) supportEntry {
+	c.mu.Lock()
+	entry, ok := c.entries[facilitatorURL]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry
+	}
+	now := time.Now()
+	entry = supportEntry{reachable: true, checked: now, expires: now.Add(facilitatorSupportTTL)}
+	kinds, err := fetchSupportedKinds(facilitatorURL)
+	if err != nil {
+		slog.Warn("failed to fetch facilitator supported kinds", "facilitator", facilitatorURL, "error", err)
+		entry.reachable = false
+		entry.expires = now.Add(facilitatorSupportRetryTTL)
+	}
+	entry.kinds = kinds
+	c.mu.Lock()
+	c.entries[facilitatorURL] = entry
+	c.mu.Unlock()
+	return entry
+}
+
+func fetchSupportedKinds(facilitatorURL string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultFacilitatorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(facilitatorURL, "/")+"/supported", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := facilitatorHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("facilitator /supported returned status %d", resp.StatusCode)
+	}
+	var body struct {
+		Kinds []struct {
+			Scheme  string `json:"scheme"`
+			Network string `json:"network"`
+		} `json:"kinds"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode /supported response: %w", err)
+	}
+	kinds := make(map[string]bool, len(body.Kinds))
+	for _, k := range body.Kinds {
+		network := k.Network
+		if mapped, ok := networkToChainID[network]; ok {
+			network = mapped
+		}
+		kinds[k.Scheme+"/"+network] = true
+	}
+	return kinds, nil
+}
+
+// errResponseTooLarge answers metered requests whose response outgrew its buffer.
+var errResponseTooLarge = errors.New("backend response exceeds metering buffer")
+
+// responseBuffer holds a backend response until the payment is settled.
+type responseBuffer struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func newResponseBuffer(limit int64) *responseBuffer {
+	return &responseBuffer{header: make(http.Header), limit: limit}
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// Write buffers p. Past the limit the rest of the response is discarded
+// rather than failed, since a write error makes the reverse proxy abort the
+// client connection.
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if b.overflow || int64(b.body.Len()+len(p)) > b.limit {
+		b.overflow = true
+		b.body.Reset()
+		return len(p), nil
+	}
+	return b.body.Write(p)
+}
+
+// writeTo sends the buffered response to the client.
+func (b *responseBuffer) writeTo(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// stored returns the buffered response as kept in the state store.
+func (b *responseBuffer) stored() *storedResponse {
+	status := b.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &storedResponse{Status: status, Header: b.header, Body: b.body.Bytes()}
+}
+
+// storedResponse is a buffered response kept in the state store, such as an
+// idempotent response or the result of an async job.
+type storedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// writeTo sends the stored response to the client.
+func (s *storedResponse) writeTo(w http.ResponseWriter) {
+	for name, values := range s.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(s.Status)
+	w.Write(s.Body)
+}
+
+// meteredAmount returns the atomic amount to settle for a backend response:
+// the reported units at the unit price, rounded up and capped at the
+// authorized maximum. Failed responses are not charged; successful responses
+// without a valid unit count are charged the maximum.
+func meteredAmount(status int, header http.Header, m *routestore.CompiledMetering, maxAmount string, decimals int) string {
+	if status >= http.StatusInternalServerError {
+		return "0"
+	}
+	units, ok := new(big.Rat).SetString(strings.TrimSpace(header.Get(m.UnitHeader)))
+	if !ok || units.Sign() < 0 {
+		return maxAmount
+	}
+	amount := units.Mul(units, m.UnitPrice)
+	amount.Mul(amount, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	atomic, rem := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+	if rem.Sign() > 0 {
+		atomic.Add(atomic, big.NewInt(1))
+	}
+	limit, ok := new(big.Int).SetString(maxAmount, 10)
+	if ok && atomic.Cmp(limit) > 0 {
+		return maxAmount
+	}
+	return atomic.String()
+}
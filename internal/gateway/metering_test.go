@@ -0,0 +1,151 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestMeteredAmount(t *testing.T) {
+	m := &routestore.CompiledMetering{UnitHeader: "X-Token-Count", UnitPrice: big.NewRat(1, 100000)}
+
+	tests := []struct {
+		name   string
+		status int
+		units  string
+		want   string
+	}{
+		{name: "units at unit price", status: 200, units: "120", want: "1200"},
+		{name: "rounded up", status: 200, units: "0.5", want: "5"},
+		{name: "capped at maximum", status: 200, units: "5000", want: "10000"},
+		{name: "zero units", status: 200, units: "0", want: "0"},
+		{name: "missing header", status: 200, want: "10000"},
+		{name: "negative units", status: 200, units: "-3", want: "10000"},
+		{name: "backend error", status: 502, units: "120", want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.units != "" {
+				header.Set("X-Token-Count", tt.units)
+			}
+			// 0.00001 USDC per unit is 10 atomic units; the maximum is 0.01 USDC.
+			if got := meteredAmount(tt.status, header, m, "10000", 6); got != tt.want {
+				t.Errorf("meteredAmount() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerMetering(t *testing.T) {
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Token-Count", "120")
+		io.WriteString(w, strings.Repeat("x", 2048))
+	}))
+	defer backendSrv.Close()
+
+	newFacilitator := func(kinds string, settled *atomic.Value) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/supported"):
+				io.WriteString(w, kinds)
+			case strings.HasSuffix(r.URL.Path, "/verify"):
+				io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			default:
+				var req struct {
+					PaymentRequirements paymentAccept `json:"paymentRequirements"`
+				}
+				json.NewDecoder(r.Body).Decode(&req)
+				settled.Store(req.PaymentRequirements.Amount)
+				io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+			}
+		}))
+	}
+
+	tests := []struct {
+		name         string
+		kinds        string
+		maxBytes     int64
+		wantScheme   string
+		wantStatus   int
+		wantSettled  any
+		wantResponse string
+	}{
+		{name: "upto supported", kinds: `{"kinds":[{"x402Version":2,"scheme":"upto","network":"eip155:84532"}]}`, maxBytes: 4096,
+			wantScheme: "upto", wantStatus: http.StatusOK, wantSettled: "1200", wantResponse: "1200"},
+		{name: "falls back to exact", kinds: `{"kinds":[{"x402Version":2,"scheme":"exact","network":"eip155:84532"}]}`, maxBytes: 4096,
+			wantScheme: "exact", wantStatus: http.StatusOK, wantSettled: "10000"},
+		{name: "response too large", kinds: `{"kinds":[{"x402Version":2,"scheme":"upto","network":"base-sepolia"}]}`, maxBytes: 1024,
+			wantScheme: "upto", wantStatus: http.StatusBadGateway, wantSettled: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var settled atomic.Value
+			facilitator := newFacilitator(tt.kinds, &settled)
+			defer facilitator.Close()
+
+			store := routestore.New()
+			store.Set("default", "llm", &routestore.CompiledRoute{
+				Name:           "llm",
+				Namespace:      "default",
+				Wallet:         "0xTestWallet",
+				Network:        "base-sepolia",
+				FacilitatorURL: facilitator.URL,
+				Rules: []routestore.CompiledRule{{
+					Path:  "/v1/*",
+					Price: "0.01",
+					Mode:  "all-pay",
+					Metering: &routestore.CompiledMetering{
+						UnitHeader:       "X-Token-Count",
+						UnitPrice:        big.NewRat(1, 100000),
+						MaxResponseBytes: tt.maxBytes,
+					},
+				}},
+				Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+			})
+			h := NewHandler(store)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/v1/complete", nil))
+			var reqs paymentRequirements
+			if err := json.Unmarshal(w.Body.Bytes(), &reqs); err != nil {
+				t.Fatalf("unmarshal 402 body: %v", err)
+			}
+			if reqs.Accepts[0].Scheme != tt.wantScheme || reqs.Accepts[0].Amount != "10000" {
+				t.Fatalf("accept = %s %s, want %s 10000", reqs.Accepts[0].Scheme, reqs.Accepts[0].Amount, tt.wantScheme)
+			}
+
+			req := httptest.NewRequest("POST", "/v1/complete", nil)
+			req.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := settled.Load(); got != tt.wantSettled {
+				t.Errorf("settled amount = %v, want %v", got, tt.wantSettled)
+			}
+			if tt.wantResponse == "" {
+				return
+			}
+			raw, _ := base64.StdEncoding.DecodeString(w.Header().Get("PAYMENT-RESPONSE"))
+			var resp settleResponse
+			if err := json.Unmarshal(raw, &resp); err != nil {
+				t.Fatalf("unmarshal PAYMENT-RESPONSE: %v", err)
+			}
+			if resp.Amount != tt.wantResponse || w.Body.Len() != 2048 {
+				t.Errorf("PAYMENT-RESPONSE amount = %q, body %d bytes; want %q, 2048 bytes", resp.Amount, w.Body.Len(), tt.wantResponse)
+			}
+		})
+	}
+}
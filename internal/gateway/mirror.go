@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// headerMirror marks the copies sent to a mirror backend.
+const headerMirror = "X-402-Mirror"
+
+const (
+	// maxMirrorBodyBytes bounds the request body buffered for a mirror; larger
+	// requests are not mirrored.
+	maxMirrorBodyBytes = 1 << 20
+	// maxMirrorsInFlight bounds concurrent mirror requests; beyond it requests
+	// are not mirrored rather than queued.
+	maxMirrorsInFlight = 64
+	mirrorTimeout      = 30 * time.Second
+)
+
+var (
+	mirrorSlots  = make(chan struct{}, maxMirrorsInFlight)
+	mirrorClient = &http.Client{Timeout: mirrorTimeout}
+)
+
+// mirrorRequest is a copy of a paid request waiting for its settlement. It
+// holds a mirror slot until sent or cancelled. A nil mirrorRequest is a
+// request that is not mirrored.
+type mirrorRequest struct {
+	req   *http.Request
+	route string
+}
+
+// prepareMirror samples r for the route's mirror and copies it. The body is
+// buffered and restored for the backend. Payment headers are not copied, so the
+// mirror can neither settle nor replay the payment.
+func prepareMirror(r *http.Request, route *routestore.CompiledRoute) *mirrorRequest {
+	m := route.Mirror
+	if m == nil || rand.Int32N(100) >= m.Percent {
+		return nil
+	}
+	select {
+	case mirrorSlots <- struct{}{}:
+	default:
+		metrics.MirrorRequestsTotal.WithLabelValues("skipped").Inc()
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxMirrorBodyBytes+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err != nil || len(body) > maxMirrorBodyBytes {
+			<-mirrorSlots
+			metrics.MirrorRequestsTotal.WithLabelValues("skipped").Inc()
+			return nil
+		}
+	}
+
+	target, err := url.Parse(m.URL)
+	if err != nil {
+		<-mirrorSlots
+		slog.Error("invalid mirror URL", "url", m.URL, "route", route.Name, "error", err)
+		return nil
+	}
+	target.Path, target.RawPath, target.RawQuery = r.URL.Path, r.URL.RawPath, r.URL.RawQuery
+	req, err := http.NewRequest(r.Method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		<-mirrorSlots
+		slog.Error("failed to build mirror request", "url", m.URL, "route", route.Name, "error", err)
+		return nil
+	}
+	req.Host = r.Host
+	req.Header = r.Header.Clone()
+	for _, h := range []string{"Payment-Signature", "X-Payment", backend.HeaderContext, headerIdempotencyKey} {
+		req.Header.Del(h)
+	}
+	req.Header.Set(headerMirror, "true")
+	return &mirrorRequest{req: req, route: route.Namespace + "/" + route.Name}
+}
+
+// send delivers the copy in the background and discards the response.
+func (m *mirrorRequest) send() {
+	if m == nil {
+		return
+	}
+	go func() {
+		defer func() { <-mirrorSlots }()
+		resp, err := mirrorClient.Do(m.req)
+		if err != nil {
+			slog.Warn("mirror request failed", "route", m.route, "url", m.req.URL.String(), "error", err)
+			metrics.MirrorRequestsTotal.WithLabelValues("failed").Inc()
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		metrics.MirrorRequestsTotal.WithLabelValues("sent").Inc()
+	}()
+}
+
+// cancel drops the copy of a request whose payment did not settle.
+func (m *mirrorRequest) cancel() {
+	if m != nil {
+		<-mirrorSlots
+	}
+}
@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestHandlerMirrorsPaidRequests(t *testing.T) {
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "primary "+string(body))
+	}))
+	defer backendSrv.Close()
+
+	type copied struct {
+		path, body string
+		header     http.Header
+	}
+	mirrored := make(chan copied, 4)
+	mirrorSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirrored <- copied{path: r.URL.RequestURI(), body: string(body), header: r.Header}
+		io.WriteString(w, "staging")
+	}))
+	defer mirrorSrv.Close()
+
+	settle := `{"success":true,"payer":"0xPayer","transaction":"0xabc","network":"eip155:84532"}`
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, settle)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "my-api", &routestore.CompiledRoute{
+		Name:           "my-api",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules:          []routestore.CompiledRule{{Path: "/api/*", Price: "0.001", Mode: "all-pay"}},
+		Backends:       []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+		Mirror:         &routestore.CompiledMirror{URL: mirrorSrv.URL, Percent: 100},
+	})
+	h := NewHandler(store)
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`))
+
+	req := httptest.NewRequest("POST", "/api/infer?model=large", strings.NewReader("prompt"))
+	req.Header.Set("Payment-Signature", payload)
+	req.Header.Set("X-Tenant", "acme")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "primary prompt" {
+		t.Fatalf("response = %d %q, want the primary backend's", w.Code, w.Body.String())
+	}
+
+	select {
+	case got := <-mirrored:
+		if got.path != "/api/infer?model=large" || got.body != "prompt" {
+			t.Errorf("mirrored %s %q", got.path, got.body)
+		}
+		if got.header.Get("X-Tenant") != "acme" || got.header.Get(headerMirror) != "true" {
+			t.Errorf("mirrored headers = %v", got.header)
+		}
+		if got.header.Get("Payment-Signature") != "" {
+			t.Error("payment header was mirrored")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not mirrored")
+	}
+
+	// Requests whose payment does not settle are not mirrored.
+	settle = `{"success":false,"errorReason":"insufficient_funds"}`
+	req = httptest.NewRequest("POST", "/api/infer", strings.NewReader("prompt"))
+	req.Header.Set("Payment-Signature", payload)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	select {
+	case got := <-mirrored:
+		t.Errorf("unsettled request mirrored: %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPrepareMirrorSkipsLargeBodies(t *testing.T) {
+	route := &routestore.CompiledRoute{Name: "my-api", Mirror: &routestore.CompiledMirror{URL: "http://staging.default.svc.cluster.local:80", Percent: 100}}
+	body := strings.Repeat("x", maxMirrorBodyBytes+1)
+	r := httptest.NewRequest("POST", "/api/upload", strings.NewReader(body))
+	if m := prepareMirror(r, route); m != nil {
+		m.cancel()
+		t.Fatal("oversized request was mirrored")
+	}
+	if got, _ := io.ReadAll(r.Body); len(got) != len(body) {
+		t.Errorf("backend body = %d bytes, want %d", len(got), len(body))
+	}
+	if len(mirrorSlots) != 0 {
+		t.Errorf("mirror slots in use = %d, want 0", len(mirrorSlots))
+	}
+}
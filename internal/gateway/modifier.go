@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"math/big"
+	"net/http"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// matchPriceModifier returns the first price modifier of the rule whose query
+// parameter has a value matching its pattern, or nil.
+func matchPriceModifier(r *http.Request, rule *routestore.CompiledRule) *routestore.CompiledPriceModifier {
+	if len(rule.Modifiers) == 0 {
+		return nil
+	}
+	query := r.URL.Query()
+	for i := range rule.Modifiers {
+		mod := &rule.Modifiers[i]
+		for _, val := range query[mod.Param] {
+			if mod.Pattern.MatchString(val) {
+				return mod
+			}
+		}
+	}
+	return nil
+}
+
+// modifiedAtomicAmount converts price to atomic units of the asset after
+// applying the modifier. Scaled amounts are rounded up to the token's
+// precision, so a payment never falls short of the modified price.
+func modifiedAtomicAmount(price string, mod *routestore.CompiledPriceModifier, info assetInfo) (string, error) {
+	if mod != nil && mod.Price != "" {
+		return priceToAtomicUnits(mod.Price, info)
+	}
+	atomic, err := priceToAtomicUnits(price, info)
+	if err != nil || mod == nil {
+		return atomic, err
+	}
+	amount, _ := new(big.Rat).SetString(atomic)
+	amount.Mul(amount, mod.Multiplier)
+	scaled, rem := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+	if rem.Sign() > 0 {
+		scaled.Add(scaled, big.NewInt(1))
+	}
+	return scaled.String(), nil
+}
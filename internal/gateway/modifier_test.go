@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestPriceModifiers(t *testing.T) {
+	route := &routestore.CompiledRoute{Wallet: "0xTestWallet", Network: "base-sepolia"}
+	rule := &routestore.CompiledRule{
+		Price: "0.001",
+		Modifiers: []routestore.CompiledPriceModifier{
+			{Param: "resolution", Pattern: regexp.MustCompile(`^4k$`), Multiplier: big.NewRat(5, 2)},
+			{Param: "resolution", Pattern: regexp.MustCompile(`^8k$`), Price: "0.01"},
+			{Param: "quality", Pattern: regexp.MustCompile(`^draft$`), Multiplier: big.NewRat(1, 3)},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		wantAmount string
+	}{
+		{name: "no parameter", url: "/render", wantAmount: "1000"},
+		{name: "unmatched value", url: "/render?resolution=hd", wantAmount: "1000"},
+		{name: "multiplier", url: "/render?resolution=4k", wantAmount: "2500"},
+		{name: "fixed price", url: "/render?resolution=8k", wantAmount: "10000"},
+		{name: "any value matches", url: "/render?resolution=hd&resolution=4k", wantAmount: "2500"},
+		{name: "first modifier wins", url: "/render?quality=draft&resolution=4k", wantAmount: "2500"},
+		{name: "rounded up", url: "/render?quality=draft", wantAmount: "334"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			reqs, err := buildPaymentRequirements(r, route, rule)
+			if err != nil {
+				t.Fatalf("buildPaymentRequirements() error = %v", err)
+			}
+			if got := reqs.Accepts[0].Amount; got != tt.wantAmount {
+				t.Errorf("Amount = %q, want %q", got, tt.wantAmount)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// headerOffer names the offer a payment matched on requests forwarded to the
+// backend.
+const headerOffer = "X-402-Offer"
+
+// stripOfferHeaders removes client-supplied offer headers, so backends can
+// trust them on any request to a rule with offers.
+func stripOfferHeaders(r *http.Request, rule *routestore.CompiledRule) {
+	r.Header.Del(headerOffer)
+	for _, offer := range rule.Offers {
+		for name := range offer.Headers {
+			r.Header.Del(name)
+		}
+	}
+}
+
+// applyOffer marks the request forwarded to the backend with the paid offer.
+func applyOffer(r *http.Request, offer *routestore.CompiledOffer) {
+	r.Header.Set(headerOffer, offer.Name)
+	for name, value := range offer.Headers {
+		r.Header.Set(name, value)
+	}
+}
@@ -1,23 +1,23 @@
 package gateway
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"math/big"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
 )
 
-// facilitatorClient is an HTTP client with timeout for facilitator API calls.
-var facilitatorClient = &http.Client{
-	Timeout: 10 * time.Second,
-}
+// defaultFacilitatorTimeout bounds a facilitator call of a route that sets no
+// timeout for it.
+const defaultFacilitatorTimeout = 10 * time.Second
 
 // networkAssets maps network identifiers to their USDC contract addresses.
 var networkAssets = map[string]string{
@@ -77,8 +77,13 @@ type paymentResource struct {
 
 // paymentExtra carries asset metadata in the payment schema.
 type paymentExtra struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Offer        string `json:"offer,omitempty"`        // set when the rule advertises several offers
+	Faucet       string `json:"faucet,omitempty"`       // test funds for sandbox routes
+	ResourceHash string `json:"resourceHash,omitempty"` // set when the route binds payments to resources
+	Challenge    string `json:"challenge,omitempty"`    // set when the route binds payments to clients
+	Nonce        string `json:"nonce,omitempty"`        // authorization nonce bound to the challenge and client
 }
 
 // paymentAccept is a single accepted payment method.
@@ -98,6 +103,8 @@ type paymentRequirements struct {
 	Resource    *paymentResource  `json:"resource"`
 	Accepts     []paymentAccept   `json:"accepts"`
 	Error       string            `json:"error,omitempty"`
+
+	decimals int // of the asset, for converting amounts back to tokens
 }
 
 // facilitatorRequest is the request body sent to /verify and /settle.
@@ -111,6 +118,10 @@ type verifyResponse struct {
 	IsValid       bool   `json:"isValid"`
 	InvalidReason string `json:"invalidReason,omitempty"`
 	Payer         string `json:"payer,omitempty"`
+
+	// facilitator is the URL of the facilitator that verified the payment,
+	// which settles it too.
+	facilitator string
 }
 
 // settleResponse is the response from /settle.
@@ -120,6 +131,7 @@ type settleResponse struct {
 	Payer       string `json:"payer,omitempty"`
 	Transaction string `json:"transaction,omitempty"`
 	Network     string `json:"network,omitempty"`
+	Amount      string `json:"amount,omitempty"` // charged amount of metered payments, set by the gateway
 }
 
 // --- Helper functions ---
@@ -149,8 +161,10 @@ func humanToAtomicUnits(price string, decimals int) (string, error) {
 	return rat.Num().String(), nil
 }
 
-// buildPaymentRequirements constructs the full paymentRequirements from a route and price.
-func buildPaymentRequirements(r *http.Request, route *routestore.CompiledRoute, price string) (*paymentRequirements, error) {
+// buildPaymentRequirements constructs the full paymentRequirements from a route
+// and rule, with one accepts entry per offer of the rule. Prices are adjusted
+// by the rule's price modifier matching the request, if any.
+func buildPaymentRequirements(r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule) (*paymentRequirements, error) {
 	network := route.Network
 	chainID := network
 	if mapped, ok := networkToChainID[network]; ok {
@@ -165,146 +179,304 @@ func buildPaymentRequirements(r *http.Request, route *routestore.CompiledRoute,
 		info = assetInfo{Name: "USDC", Version: "2", Decimals: 6}
 	}
 
-	atomicAmount, err := humanToAtomicUnits(price, info.Decimals)
-	if err != nil {
-		return nil, fmt.Errorf("convert price to atomic units: %w", err)
+	// An asset override outside the registry needs its metadata from the chain.
+	if route.Asset != "" && !strings.EqualFold(route.Asset, asset) {
+		resolved, err := lookupAsset(r.Context(), chainID, route.Asset)
+		if err != nil {
+			return nil, err
+		}
+		asset, info = route.Asset, resolved
+	}
+
+	// Metered rules authorize the price as a maximum where the facilitator
+	// can settle less; otherwise the full price is charged.
+	scheme := "exact"
+	if rule.Metering != nil && facilitatorKinds.supports(route.FacilitatorURL, schemeUpto, chainID) {
+		scheme = schemeUpto
+	}
+
+	// Offers bound to the client carry a challenge of their own.
+	var fingerprint string
+	if route.BindClient != "" {
+		fingerprint = clientFingerprint(r, route.BindClient)
+	}
+
+	mod := matchPriceModifier(r, rule)
+	accept := func(price, offer string) (paymentAccept, error) {
+		if route.Sandbox {
+			price = sandboxTokenPrice(price, info.Decimals)
+		}
+		atomicAmount, err := modifiedAtomicAmount(price, mod, info)
+		if err != nil {
+			return paymentAccept{}, fmt.Errorf("convert price to atomic units: %w", err)
+		}
+		atomicAmount = chargeAmount(route, atomicAmount, info.Decimals)
+		extra := &paymentExtra{
+			Name:    info.Name,
+			Version: info.Version,
+			Offer:   offer,
+			Faucet:  faucetURL(route, chainID),
+		}
+		if route.BindResource {
+			extra.ResourceHash = resourceHash(r, route, atomicAmount)
+		}
+		if fingerprint != "" {
+			extra.Challenge, extra.Nonce = clientChallenge(fingerprint)
+		}
+		return paymentAccept{
+			Scheme:            scheme,
+			Network:           chainID,
+			Amount:            atomicAmount,
+			PayTo:             route.Wallet,
+			MaxTimeoutSeconds: 300,
+			Asset:             asset,
+			Extra:             extra,
+		}, nil
+	}
+
+	accepts := make([]paymentAccept, 0, max(len(rule.Offers), 1))
+	if len(rule.Offers) == 0 {
+		a, err := accept(rule.Price, "")
+		if err != nil {
+			return nil, err
+		}
+		accepts = append(accepts, a)
+	}
+	for _, offer := range rule.Offers {
+		a, err := accept(offer.Price, offer.Name)
+		if err != nil {
+			return nil, fmt.Errorf("offer %s: %w", offer.Name, err)
+		}
+		accepts = append(accepts, a)
 	}
 
 	return &paymentRequirements{
 		X402Version: 2,
 		Resource: &paymentResource{
-			URL:         r.URL.String(),
+			URL:         resourceURL(r, route),
 			Description: "Payment required to access this resource",
 		},
-		Accepts: []paymentAccept{
-			{
-				Scheme:            "exact",
-				Network:           chainID,
-				Amount:            atomicAmount,
-				PayTo:             route.Wallet,
-				MaxTimeoutSeconds: 300,
-				Asset:             asset,
-				Extra: &paymentExtra{
-					Name:    info.Name,
-					Version: info.Version,
-				},
-			},
-		},
+		Accepts:  accepts,
+		decimals: info.Decimals,
 	}, nil
 }
 
+// pricingKey identifies the advertised prices of a rule in the 402 cache.
+func pricingKey(rule *routestore.CompiledRule) string {
+	if len(rule.Offers) == 0 {
+		return rule.Price
+	}
+	var b strings.Builder
+	for _, offer := range rule.Offers {
+		b.WriteString(offer.Name)
+		b.WriteByte('=')
+		b.WriteString(offer.Price)
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// selectAccept returns the index of the accepts entry the payment was made
+// for, read from the payload's "accepted" requirements: by extra.offer, then
+// by amount. It falls back to the first entry.
+func selectAccept(paymentHeader string, accepts []paymentAccept) int {
+	if len(accepts) < 2 {
+		return 0
+	}
+	payloadBytes, err := base64.StdEncoding.DecodeString(paymentHeader)
+	if err != nil {
+		return 0
+	}
+	var payload struct {
+		Accepted *paymentAccept `json:"accepted"`
+	}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil || payload.Accepted == nil {
+		return 0
+	}
+	if extra := payload.Accepted.Extra; extra != nil && extra.Offer != "" {
+		for i := range accepts {
+			if accepts[i].Extra.Offer == extra.Offer {
+				return i
+			}
+		}
+	}
+	for i := range accepts {
+		if accepts[i].Amount == payload.Accepted.Amount {
+			return i
+		}
+	}
+	return 0
+}
+
 // --- Main functions ---
 
+// paymentRequiredResponses caches serialized 402 responses.
+var paymentRequiredResponses = newResponseCache()
+
 // writePaymentRequired writes a 402 Payment Required response with x402 format.
 // Sets both the JSON body and the Base64-encoded PAYMENT-REQUIRED header.
-func writePaymentRequired(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, price string) {
-	reqs, err := buildPaymentRequirements(r, route, price)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to build payment requirements: %v", err), http.StatusInternalServerError)
-		return
+func writePaymentRequired(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule) {
+	build := func() (*cachedResponse, error) {
+		reqs, err := buildPaymentRequirements(r, route, rule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build payment requirements: %w", err)
+		}
+		respJSON, err := json.Marshal(reqs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payment requirements: %w", err)
+		}
+		return &cachedResponse{
+			body:   respJSON,
+			header: base64.StdEncoding.EncodeToString(respJSON),
+			etag:   paymentRequiredETag(route, respJSON),
+		}, nil
 	}
 
-	respJSON, err := json.Marshal(reqs)
+	var resp *cachedResponse
+	var err error
+	if hasFiatPrice(rule) || rule.Metering != nil || route.BindClient != "" {
+		resp, err = build()
+	} else {
+		resp, err = paymentRequiredResponses.get(route, pricingKey(rule), resourceURL(r, route), build)
+	}
 	if err != nil {
-		http.Error(w, "failed to marshal payment requirements", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Cache-Control", paymentRequiredCacheControl)
+	w.Header().Set("ETag", resp.etag)
+	// Clients that kept the requirements only revalidate them.
+	if getPaymentHeader(r) == "" && notModified(r, resp.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("PAYMENT-REQUIRED", base64.StdEncoding.EncodeToString(respJSON))
+	w.Header().Set("PAYMENT-REQUIRED", resp.header)
 	w.WriteHeader(http.StatusPaymentRequired)
-	w.Write(respJSON)
+	w.Write(resp.body)
 }
 
-// verifyAndSettlePayment decodes the Payment-Signature header, calls the facilitator's
-// /verify endpoint, and on success calls /settle. Returns the settle response.
-func verifyAndSettlePayment(paymentHeader string, paymentReqs *paymentRequirements, facilitatorURL string) (*settleResponse, error) {
-	// Decode the Base64 Payment-Signature header to get the payment payload JSON.
+// paymentNetwork returns the network a payment payload was signed for: the
+// network of its "accepted" requirements (v2) or its top-level network (v1),
+// normalized to a chain identifier.
+func paymentNetwork(paymentHeader string) (string, bool) {
 	payloadBytes, err := base64.StdEncoding.DecodeString(paymentHeader)
 	if err != nil {
-		return nil, fmt.Errorf("base64 decode Payment-Signature: %w", err)
+		return "", false
 	}
-
-	// Validate that payloadBytes is valid JSON.
-	if !json.Valid(payloadBytes) {
-		return nil, fmt.Errorf("Payment-Signature is not valid JSON after base64 decode")
+	var payload struct {
+		Network  string `json:"network"`
+		Accepted *struct {
+			Network string `json:"network"`
+		} `json:"accepted"`
 	}
-
-	if len(paymentReqs.Accepts) == 0 {
-		return nil, fmt.Errorf("no payment accepts in requirements")
-	}
-
-	facReq := facilitatorRequest{
-		PaymentPayload:      json.RawMessage(payloadBytes),
-		PaymentRequirements: &paymentReqs.Accepts[0],
-	}
-
-	reqBody, err := json.Marshal(facReq)
-	if err != nil {
-		return nil, fmt.Errorf("marshal facilitator request: %w", err)
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return "", false
 	}
-
-	baseURL := strings.TrimRight(facilitatorURL, "/")
-
-	// --- /verify ---
-	verifyResp, err := facilitatorClient.Post(baseURL+"/verify", "application/json", bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("POST to facilitator /verify: %w", err)
+	network := payload.Network
+	if payload.Accepted != nil && payload.Accepted.Network != "" {
+		network = payload.Accepted.Network
 	}
-	defer verifyResp.Body.Close()
-
-	verifyBody, err := io.ReadAll(verifyResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read /verify response: %w", err)
+	if network == "" {
+		return "", false
 	}
-
-	if verifyResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("facilitator /verify returned status %d: %s", verifyResp.StatusCode, string(verifyBody))
+	if mapped, ok := networkToChainID[network]; ok {
+		network = mapped
 	}
+	return network, true
+}
 
-	var vResp verifyResponse
-	if err := json.Unmarshal(verifyBody, &vResp); err != nil {
-		return nil, fmt.Errorf("unmarshal /verify response: %w", err)
+// wrongNetworkError describes a payment for a network the route does not
+// accept, or returns "" if the payment's network is accepted or unknown.
+func wrongNetworkError(paymentHeader string, accepts []paymentAccept) string {
+	network, ok := paymentNetwork(paymentHeader)
+	if !ok {
+		return ""
 	}
-
-	if !vResp.IsValid {
-		reason := vResp.InvalidReason
-		if reason == "" {
-			reason = "payment not valid"
+	var accepted []string
+	for _, a := range accepts {
+		if a.Network == network {
+			return ""
+		}
+		if !slices.Contains(accepted, a.Network) {
+			accepted = append(accepted, a.Network)
 		}
-		return nil, fmt.Errorf("payment invalid: %s", reason)
 	}
+	return fmt.Sprintf("invalid_network: payment is for %s; accepted networks: %s", network, strings.Join(accepted, ", "))
+}
 
-	// --- /settle ---
-	settleResp, err := facilitatorClient.Post(baseURL+"/settle", "application/json", bytes.NewReader(reqBody))
+// writePaymentError writes a 402 response that explains why a payment was
+// rejected alongside the accepted payment requirements.
+func writePaymentError(w http.ResponseWriter, reqs *paymentRequirements, reason string) {
+	withError := *reqs
+	withError.Error = reason
+	respJSON, err := json.Marshal(&withError)
 	if err != nil {
-		return nil, fmt.Errorf("POST to facilitator /settle: %w", err)
+		http.Error(w, "failed to marshal payment requirements", http.StatusInternalServerError)
+		return
 	}
-	defer settleResp.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("PAYMENT-REQUIRED", base64.StdEncoding.EncodeToString(respJSON))
+	w.WriteHeader(http.StatusPaymentRequired)
+	w.Write(respJSON)
+}
 
-	settleBody, err := io.ReadAll(settleResp.Body)
+// verifyPayment decodes the Payment-Signature header and has the route's
+// facilitators verify it for the accepted requirements. Returns the decoded
+// payment for settlement. A rejected payment also returns the facilitator's
+// answer, which may name the payer.
+func verifyPayment(ctx context.Context, paymentHeader string, accept *paymentAccept, route *routestore.CompiledRoute) (*facilitatorPayment, *verifyResponse, error) {
+	payment, err := decodePayment(paymentHeader, accept)
 	if err != nil {
-		return nil, fmt.Errorf("read /settle response: %w", err)
+		return nil, nil, err
 	}
-
-	if settleResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("facilitator /settle returned status %d: %s", settleResp.StatusCode, string(settleBody))
+	verified, err := newFacilitatorClient(route).Verify(ctx, payment)
+	if err != nil {
+		return nil, verified, err
 	}
+	return payment, verified, nil
+}
+
+// facilitatorError marks a failure of the facilitator itself (unreachable,
+// server error or malformed response), as opposed to a rejected payment.
+type facilitatorError struct {
+	err error
+}
+
+func (e *facilitatorError) Error() string { return e.err.Error() }
+
+func (e *facilitatorError) Unwrap() error { return e.err }
 
-	var sResp settleResponse
-	if err := json.Unmarshal(settleBody, &sResp); err != nil {
-		return nil, fmt.Errorf("unmarshal /settle response: %w", err)
+// callError classifies a failed facilitator call. A call canceled because the
+// client went away is not a failure of the facilitator; one that timed out is.
+func callError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return err
 	}
+	return &facilitatorError{err}
+}
 
-	if !sResp.Success {
-		reason := sResp.ErrorReason
-		if reason == "" {
-			reason = "settlement failed"
-		}
-		return nil, fmt.Errorf("settlement failed: %s", reason)
+// facilitatorStatusError builds the error for a non-200 facilitator response.
+// 5xx and 429 responses are facilitator failures; other statuses reject the
+// payment.
+func facilitatorStatusError(endpoint string, status int, body []byte) error {
+	err := fmt.Errorf("facilitator %s returned status %d: %s", endpoint, status, string(body))
+	if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		return &facilitatorError{err}
 	}
+	return err
+}
 
-	return &sResp, nil
+// tokenAmount converts an atomic amount back to tokens.
+func tokenAmount(atomic string, decimals int) (float64, bool) {
+	amount, ok := new(big.Rat).SetString(atomic)
+	if !ok {
+		return 0, false
+	}
+	amount.Quo(amount, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	f, _ := amount.Float64()
+	return f, true
 }
 
 // getPaymentHeader extracts the payment header from the request.
@@ -1,11 +1,14 @@
 package gateway
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
 )
@@ -52,7 +55,7 @@ func TestBuildPaymentRequirements(t *testing.T) {
 	}
 
 	r := httptest.NewRequest("GET", "/api/test", nil)
-	reqs, err := buildPaymentRequirements(r, route, "0.001")
+	reqs, err := buildPaymentRequirements(r, route, &routestore.CompiledRule{Price: "0.001"})
 	if err != nil {
 		t.Fatalf("buildPaymentRequirements returned error: %v", err)
 	}
@@ -108,7 +111,7 @@ func TestWritePaymentRequired(t *testing.T) {
 	r := httptest.NewRequest("GET", "/api/test", nil)
 	w := httptest.NewRecorder()
 
-	writePaymentRequired(w, r, route, "0.01")
+	writePaymentRequired(w, r, route, &routestore.CompiledRule{Price: "0.01"})
 
 	resp := w.Result()
 
@@ -160,3 +163,118 @@ func TestWritePaymentRequired(t *testing.T) {
 		t.Error("body and header X402Version mismatch")
 	}
 }
+
+func TestFacilitatorStatusError(t *testing.T) {
+	tests := []struct {
+		status      int
+		facilitator bool
+	}{
+		{status: http.StatusBadRequest, facilitator: false},
+		{status: http.StatusTooManyRequests, facilitator: true},
+		{status: http.StatusBadGateway, facilitator: true},
+	}
+
+	for _, tt := range tests {
+		var facErr *facilitatorError
+		if got := errors.As(facilitatorStatusError("/verify", tt.status, nil), &facErr); got != tt.facilitator {
+			t.Errorf("status %d: facilitator error = %v, want %v", tt.status, got, tt.facilitator)
+		}
+	}
+}
+
+func TestBuildPaymentRequirementsUnknownAsset(t *testing.T) {
+	route := &routestore.CompiledRoute{
+		Wallet:  "0xTestWallet",
+		Network: "base-sepolia",
+		Asset:   "0x00000000000000000000000000000000000000aa",
+	}
+	r := httptest.NewRequest("GET", "/api/test", nil)
+	if _, err := buildPaymentRequirements(r, route, &routestore.CompiledRule{Price: "0.001"}); err == nil {
+		t.Error("buildPaymentRequirements() with unresolvable asset succeeded, want error")
+	}
+
+	// The registry asset needs no lookup.
+	route.Asset = "0x036CbD53842c5426634e7929541eC2318f3dCF7e"
+	if _, err := buildPaymentRequirements(r, route, &routestore.CompiledRule{Price: "0.001"}); err != nil {
+		t.Errorf("buildPaymentRequirements() with registry asset error = %v", err)
+	}
+}
+
+func TestWrongNetworkError(t *testing.T) {
+	accepts := []paymentAccept{{Network: "eip155:84532"}}
+	encode := func(payload string) string {
+		return base64.StdEncoding.EncodeToString([]byte(payload))
+	}
+
+	tests := []struct {
+		name    string
+		payment string
+		want    string
+	}{
+		{name: "v2 matching", payment: encode(`{"x402Version":2,"accepted":{"network":"eip155:84532"}}`)},
+		{name: "v1 friendly name", payment: encode(`{"x402Version":1,"network":"base-sepolia"}`)},
+		{name: "no network", payment: encode(`{"x402Version":2}`)},
+		{name: "not base64", payment: "%%%"},
+		{name: "v2 mismatch", payment: encode(`{"x402Version":2,"accepted":{"network":"eip155:8453"}}`), want: "invalid_network: payment is for eip155:8453; accepted networks: eip155:84532"},
+		{name: "v1 mismatch", payment: encode(`{"x402Version":1,"network":"avalanche"}`), want: "invalid_network: payment is for eip155:43114; accepted networks: eip155:84532"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrongNetworkError(tt.payment, accepts); got != tt.want {
+				t.Errorf("wrongNetworkError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPaymentTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer facilitator.Close()
+	defer close(release)
+	header := base64.StdEncoding.EncodeToString([]byte(`{}`))
+	route := &routestore.CompiledRoute{FacilitatorURL: facilitator.URL, VerifyTimeout: 50 * time.Millisecond}
+
+	tests := []struct {
+		name        string
+		ctx         func() (context.Context, context.CancelFunc)
+		facilitator bool
+	}{
+		{
+			name:        "timed out",
+			ctx:         func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			facilitator: true,
+		},
+		{
+			name: "client gone",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			facilitator: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			start := time.Now()
+			_, _, err := verifyPayment(ctx, header, &paymentAccept{}, route)
+			if err == nil {
+				t.Fatal("verifyPayment() succeeded, want error")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("verifyPayment() took %v, want it bounded by the timeout", elapsed)
+			}
+			var facErr *facilitatorError
+			if got := errors.As(err, &facErr); got != tt.facilitator {
+				t.Errorf("facilitator error = %v, want %v (%v)", got, tt.facilitator, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package gateway
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// Names of the stages of the default pipeline, in the order they run.
+const (
+	stageRouting    = "routing"
+	stageAccess     = "access"
+	stageConditions = "conditions"
+	stageValidation = "validation"
+	stageCaching    = "caching"
+	stagePayment    = "payment"
+	stageAdmission  = "admission"
+	stageSettlement = "settlement"
+	stageProxy      = "proxy"
+)
+
+// request is the state of one request as it moves through the pipeline.
+type request struct {
+	w     http.ResponseWriter
+	r     *http.Request
+	path  string
+	start time.Time
+
+	route *routestore.CompiledRoute
+	rule  *routestore.CompiledRule // nil for unmatched requests passed through
+	// free is the x402_requests_total status of a request served without
+	// payment; paid stages skip such requests.
+	free string
+	// probe marks synthetic requests of the prober, whose settlements are
+	// not recorded.
+	probe bool
+	// variant is the price experiment variant of the client, "" for rules
+	// without experiments.
+	variant string
+	// payer is the address the payment is from, once known; strict makes
+	// the payment settle before the backend is called.
+	payer  string
+	strict bool
+	// attestation is the X-402-Verified header of a trusted upstream tier.
+	attestation string
+
+	paymentHeader string
+	reqs          *paymentRequirements
+	accepted      int // index of the accepted requirements in reqs.Accepts
+	accept        *paymentAccept
+	payment       *facilitatorPayment
+	verified      *verifyResponse
+	settled       *settleResponse
+	// release frees what admission checks took. A stage that hands the
+	// request off to the background takes it over and sets it to nil.
+	release func()
+}
+
+// stage is one step of the gateway pipeline. It either answers the request
+// itself or calls next to pass it on; code after next runs once the later
+// stages are done.
+type stage struct {
+	name  string
+	paid  bool // run only for requests that must be paid for
+	serve func(req *request, next func())
+}
+
+// defaultStages returns the gateway pipeline.
+func (h *Handler) defaultStages() []stage {
+	return []stage{
+		{name: stageRouting, serve: h.routeRequest},
+		{name: stageAccess, serve: stripGatewayHeaders},
+		{name: stageConditions, paid: true, serve: applyConditions},
+		{name: stageValidation, paid: true, serve: validateRequestStage},
+		{name: stageCaching, paid: true, serve: applyPaidCaching},
+		{name: stagePayment, paid: true, serve: h.verifyPaymentStage},
+		{name: stageAdmission, paid: true, serve: h.admitStage},
+		{name: stageSettlement, paid: true, serve: h.settleStage},
+		{name: stageProxy, serve: h.proxyStage},
+	}
+}
+
+// insertStage adds s to the pipeline before the stage named before. It must
+// be called before the handler serves requests.
+func (h *Handler) insertStage(before string, s stage) error {
+	for i := range h.stages {
+		if h.stages[i].name == s.name {
+			return fmt.Errorf("gateway stage %q already registered", s.name)
+		}
+	}
+	for i := range h.stages {
+		if h.stages[i].name == before {
+			h.stages = append(h.stages[:i], append([]stage{s}, h.stages[i:]...)...)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown gateway stage %q", before)
+}
+
+// serve runs the pipeline from stage i.
+func (h *Handler) serve(req *request, i int) {
+	for i < len(h.stages) && h.stages[i].paid && req.free != "" {
+		i++
+	}
+	if i == len(h.stages) {
+		return
+	}
+	h.stages[i].serve(req, func() { h.serve(req, i+1) })
+}
+
+// routeRequest finds the route and rule of the request. Requests matching no
+// rule are passed through for routes that allow it, or answered 404.
+// Requests exempted by their route are forwarded without payment either way,
+// like every request of a paused route.
+func (h *Handler) routeRequest(req *request, next func()) {
+	host := requestHost(req.r)
+
+	// First route for this host that forwards unmatched requests.
+	var passthrough, exempting *routestore.CompiledRoute
+	var exempted string
+	for _, route := range h.store.Snapshot() {
+		if !h.matchesHost(host, route) {
+			continue
+		}
+		rule, matched := h.findMatchingRule(host, req.path, route)
+		if !matched {
+			if passthrough == nil && route.Unmatched == "passthrough" {
+				passthrough = route
+			}
+			if exempting == nil {
+				if exempted = exemption(route, req.r, req.path); exempted != "" {
+					exempting = route
+				}
+			}
+			continue
+		}
+		req.route, req.rule = route, rule
+		if h.prober.isProbe(req.r) {
+			req.route, req.probe = h.prober.probeRoute(route), true
+		}
+		switch exempted := exemption(route, req.r, req.path); {
+		case route.Paused:
+			slog.Debug("route paused, forwarding without payment", "path", req.path, "route", route.Name)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, req.path), route.Namespace, route.Name, "paused").Inc()
+			req.free = "paused"
+		case exempted != "":
+			exempt(req, exempted)
+		}
+		next()
+		return
+	}
+
+	if exempting != nil {
+		req.route = exempting
+		exempt(req, exempted)
+		next()
+		return
+	}
+
+	if passthrough != nil {
+		slog.Info("no matching rule, passing through", "path", req.path, "route", passthrough.Name)
+		metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), passthrough.Namespace, passthrough.Name, "unmatched_passthrough").Inc()
+		req.route, req.free = passthrough, "unmatched_passthrough"
+		next()
+		return
+	}
+
+	slog.Info("no matching route", "path", req.path)
+	http.Error(req.w, "no x402 route configured for this path", http.StatusNotFound)
+}
+
+// stripGatewayHeaders removes the headers only the gateway may set: the
+// payment context, the offer that was paid for and the probe token. The
+// attestation of a trusted upstream tier is kept for the payment stage.
+func stripGatewayHeaders(req *request, next func()) {
+	req.r.Header.Del(backend.HeaderContext)
+	req.attestation = req.r.Header.Get(backend.HeaderVerified)
+	req.r.Header.Del(backend.HeaderVerified)
+	req.r.Header.Del(ProbeHeader)
+	if req.rule != nil && len(req.rule.Offers) > 0 {
+		stripOfferHeaders(req.r, req.rule)
+	}
+	next()
+}
+
+// applyConditions marks free and conditionally free requests, prices GraphQL
+// rules by the operation the request executes and rules with experiments by
+// the client's variant.
+func applyConditions(req *request, next func()) {
+	route, rule := req.route, req.rule
+	switch {
+	case rule.Free:
+		slog.Info("free path, forwarding", "path", req.path, "route", route.Name)
+		req.free = "free"
+	case rule.Mode == "conditional" && len(rule.Conditions) > 0 && !evaluateConditions(req.r, rule.Conditions):
+		slog.Info("conditional: no payment needed", "path", req.path, "route", route.Name)
+		req.free = "conditional_free"
+	case rule.GraphQL != nil:
+		priced, err := graphqlRule(req.r, rule)
+		if err != nil {
+			slog.Info("graphql operation not determined", "path", req.path, "route", route.Name, "error", err)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), route.Namespace, route.Name, "graphql_invalid").Inc()
+			http.Error(req.w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.rule = priced
+	case len(rule.Experiments) > 0:
+		req.rule, req.variant = experimentRule(req.r, route, rule)
+	}
+	if req.free != "" {
+		metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), route.Namespace, route.Name, req.free).Inc()
+	}
+	next()
+}
+
+// verifyPaymentStage answers requests without a payment with 402, replays
+// idempotent retries and verifies the payment with the facilitator.
+func (h *Handler) verifyPaymentStage(req *request, next func()) {
+	route, rule, path := req.route, req.rule, req.path
+
+	// Offers bound to the client need its TLS certificate or session.
+	var fingerprint string
+	if route.BindClient != "" && !req.probe {
+		if fingerprint = clientFingerprint(req.r, route.BindClient); fingerprint == "" {
+			slog.Info("paid path, client not identified for binding", "path", path, "route", route.Name, "bindClient", route.BindClient)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "client_unbound").Inc()
+			http.Error(req.w, "payments require a TLS "+route.BindClient+" to bind them to", http.StatusForbidden)
+			return
+		}
+	}
+
+	req.paymentHeader = getPaymentHeader(req.r)
+	if req.paymentHeader == "" {
+		slog.Info("paid path, no payment header", "path", path, "route", route.Name)
+		metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "payment_required").Inc()
+		if !req.probe {
+			h.analytics.issued(route, rule)
+		}
+		countExperiment(req, "payment_required", 0)
+		writePaymentRequired(req.w, req.r, route, rule)
+		return
+	}
+
+	reqs, err := buildPaymentRequirements(req.r, route, rule)
+	if err != nil {
+		slog.Error("failed to build payment requirements", "path", path, "route", route.Name, "error", err)
+		http.Error(req.w, "internal error building payment requirements", http.StatusInternalServerError)
+		return
+	}
+
+	// Reject payments signed for another chain without asking the facilitator.
+	if reason := wrongNetworkError(req.paymentHeader, reqs.Accepts); reason != "" {
+		slog.Info("payment for wrong network", "path", path, "route", route.Name, "reason", reason)
+		h.captureFailure(req, nil, reason)
+		metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "wrong_network").Inc()
+		writePaymentError(req.w, reqs, reason)
+		return
+	}
+
+	req.reqs = reqs
+	req.accepted = selectAccept(req.paymentHeader, reqs.Accepts)
+	req.accept = &reqs.Accepts[req.accepted]
+
+	// Refuse payers over the route's deny score without asking the facilitator.
+	req.payer = paymentPayer(req.paymentHeader)
+	if !h.admitPayer(req) {
+		return
+	}
+
+	// Reject offers made for another resource without asking the facilitator.
+	if route.BindResource {
+		if reason := resourceBindingError(req.paymentHeader, req.accept); reason != "" {
+			slog.Info("payment for another resource", "path", path, "route", route.Name, "reason", reason)
+			h.captureFailure(req, req.accept, reason)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "resource_mismatch").Inc()
+			writePaymentError(req.w, reqs, reason)
+			return
+		}
+	}
+
+	// Reject payments signed for an offer made to another client.
+	if fingerprint != "" {
+		if reason := clientBindingError(req.paymentHeader, req.accept, fingerprint); reason != "" {
+			slog.Info("payment for another client", "path", path, "route", route.Name, "reason", reason)
+			h.captureFailure(req, req.accept, reason)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "client_mismatch").Inc()
+			writePaymentError(req.w, reqs, reason)
+			return
+		}
+	}
+
+	// Retries of a paid POST replay the original response.
+	if key := idempotencyKey(req.r, rule); key != "" {
+		iw := h.beginIdempotent(req.w, req.r, route, rule, path, key, req.paymentHeader, req.accept, req.start)
+		if iw == nil {
+			return
+		}
+		defer iw.finish()
+		req.w = iw
+	}
+
+	var payment *facilitatorPayment
+	var verified *verifyResponse
+	var exceeded bool
+	if attested := h.upstream.verified(req); attested != nil {
+		// A trusted upstream tier already had the payment verified.
+		if payment, err = decodePayment(req.paymentHeader, req.accept); err == nil {
+			verified = attested
+		}
+	} else {
+		verifyStart := time.Now()
+		ctx, done := budgeted(req.r.Context(), req)
+		payment, verified, err = verifyPayment(ctx, req.paymentHeader, req.accept, route)
+		exceeded = done(err)
+		metrics.ObserveDuration(metrics.PaymentVerificationDuration, req.r, time.Since(verifyStart).Seconds())
+	}
+	if err != nil {
+		// Only payers the facilitator names are scored, so a forged payload
+		// cannot spend another payer's reputation.
+		if verified != nil && !req.probe {
+			h.reputation.recordFailure(req.r.Context(), verified.Payer, err, false)
+		}
+		// No settlement callback: the payment was never verified, so the
+		// callback URL comes from an unauthenticated client.
+		h.captureFailure(req, req.accept, err.Error())
+		if exceeded {
+			overBudget(req)
+			return
+		}
+		h.paymentFailed(req.w, req.r, route, rule, path, err, req.start)
+		return
+	}
+	req.payment, req.verified = payment, verified
+	if verified.Payer != "" && !strings.EqualFold(verified.Payer, req.payer) {
+		req.payer = verified.Payer
+		if !h.admitPayer(req) {
+			return
+		}
+	}
+	next()
+}
+
+// admitStage runs the admission checks and holds what they took until the
+// later stages are done.
+func (h *Handler) admitStage(req *request, next func()) {
+	release, ok := h.admit(req.w, req.r, req.route, req.rule, req.path)
+	if !ok {
+		return
+	}
+	req.release = release
+	defer func() {
+		if req.release != nil {
+			req.release()
+		}
+	}()
+	next()
+}
+
+// proxyStage forwards the request to the backend, or starts the background
+// job of a paid async rule.
+func (h *Handler) proxyStage(req *request, next func()) {
+	if req.free == "" && req.rule.Async != nil {
+		// The job holds the admission resources until it finishes.
+		h.jobs.start(req.w, req.r, req.route, req.rule, req.path, req.paymentHeader, req.settled, req.release)
+		req.release = nil
+		return
+	}
+	proxyToBackend(req.w, req.r, req.route, req.rule, req.path)
+	metrics.ObserveDuration(metrics.ProxyRequestDuration, req.r, time.Since(req.start).Seconds())
+	next()
+}
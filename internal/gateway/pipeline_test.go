@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// runStage runs one stage on req and reports whether it passed the request on.
+func runStage(serve func(*request, func()), req *request) bool {
+	passed := false
+	serve(req, func() { passed = true })
+	return passed
+}
+
+func newTestRequest(method, target string) *request {
+	r := httptest.NewRequest(method, target, nil)
+	return &request{w: httptest.NewRecorder(), r: r, path: r.URL.Path, start: time.Now()}
+}
+
+func stageNames(h *Handler) []string {
+	var names []string
+	for _, s := range h.stages {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func TestInsertStage(t *testing.T) {
+	h := NewHandler(routestore.New())
+	if err := h.insertStage(stageSettlement, stage{name: "quota", paid: true, serve: func(*request, func()) {}}); err != nil {
+		t.Fatalf("insertStage() error = %v", err)
+	}
+	want := []string{stageRouting, stageAccess, stageConditions, stageValidation, stageCaching, stagePayment, stageAdmission, "quota", stageSettlement, stageProxy}
+	if got := stageNames(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("stages = %v, want %v", got, want)
+	}
+	if err := h.insertStage(stageProxy, stage{name: "quota"}); err == nil {
+		t.Error("insertStage() with a duplicate name succeeded")
+	}
+	if err := h.insertStage("missing", stage{name: "transform"}); err == nil {
+		t.Error("insertStage() before an unknown stage succeeded")
+	}
+}
+
+func TestPipelineSkipsPaidStagesForFreeRequests(t *testing.T) {
+	h := &Handler{}
+	var ran []string
+	for _, s := range []struct {
+		name string
+		paid bool
+	}{{"routing", false}, {"payment", true}, {"settlement", true}, {"proxy", false}} {
+		h.stages = append(h.stages, stage{name: s.name, paid: s.paid, serve: func(req *request, next func()) {
+			ran = append(ran, s.name)
+			if s.name == "routing" {
+				req.free = "free"
+			}
+			next()
+		}})
+	}
+	h.serve(newTestRequest("GET", "/health"), 0)
+	if want := []string{"routing", "proxy"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("stages run = %v, want %v", ran, want)
+	}
+}
+
+func TestRouteRequestStage(t *testing.T) {
+	store := routestore.New()
+	store.Set("default", "shop", &routestore.CompiledRoute{
+		Name: "shop", Namespace: "default", Hosts: []string{"shop.example.com"},
+		Rules: []routestore.CompiledRule{{Path: "/api/*"}},
+	})
+	store.Set("default", "blog", &routestore.CompiledRoute{
+		Name: "blog", Namespace: "default", Hosts: []string{"blog.example.com"}, Unmatched: "passthrough",
+		Rules: []routestore.CompiledRule{{Path: "/premium/*"}},
+	})
+	store.Set("default", "docs", &routestore.CompiledRoute{
+		Name: "docs", Namespace: "default", Hosts: []string{"docs.example.com"}, Paused: true,
+		Rules: []routestore.CompiledRule{{Path: "/api/*"}},
+	})
+	h := NewHandler(store)
+
+	tests := []struct {
+		target     string
+		wantPassed bool
+		wantRoute  string
+		wantFree   string
+	}{
+		{target: "http://shop.example.com:8080/api/orders", wantPassed: true, wantRoute: "shop"},
+		{target: "http://blog.example.com/about", wantPassed: true, wantRoute: "blog", wantFree: "unmatched_passthrough"},
+		{target: "http://docs.example.com/api/search", wantPassed: true, wantRoute: "docs", wantFree: "paused"},
+		{target: "http://shop.example.com/about"},
+		{target: "http://other.example.com/api/orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			req := newTestRequest("GET", tt.target)
+			passed := runStage(h.routeRequest, req)
+			if passed != tt.wantPassed {
+				t.Fatalf("passed = %v, want %v", passed, tt.wantPassed)
+			}
+			if !passed {
+				if code := req.w.(*httptest.ResponseRecorder).Code; code != http.StatusNotFound {
+					t.Errorf("status = %d, want 404", code)
+				}
+				return
+			}
+			if req.route.Name != tt.wantRoute || req.free != tt.wantFree {
+				t.Errorf("route = %s, free = %q, want %s, %q", req.route.Name, req.free, tt.wantRoute, tt.wantFree)
+			}
+		})
+	}
+}
+
+func TestStripGatewayHeadersStage(t *testing.T) {
+	req := newTestRequest("GET", "/api/data")
+	req.rule = &routestore.CompiledRule{Offers: []routestore.CompiledOffer{{Name: "priority", Headers: map[string]string{"X-Tier": "priority"}}}}
+	req.r.Header.Set(backend.HeaderContext, "forged")
+	req.r.Header.Set(headerOffer, "priority")
+	req.r.Header.Set("X-Tier", "priority")
+	req.r.Header.Set("X-Tenant", "acme")
+	if !runStage(stripGatewayHeaders, req) {
+		t.Fatal("stage did not pass the request on")
+	}
+	for _, name := range []string{backend.HeaderContext, headerOffer, "X-Tier"} {
+		if req.r.Header.Get(name) != "" {
+			t.Errorf("%s was not stripped", name)
+		}
+	}
+	if req.r.Header.Get("X-Tenant") != "acme" {
+		t.Error("unrelated header was stripped")
+	}
+}
+
+func TestApplyConditionsStage(t *testing.T) {
+	route := &routestore.CompiledRoute{Name: "api", Namespace: "default"}
+	conditional := routestore.CompiledRule{Mode: "conditional", Conditions: []routestore.CompiledCondition{
+		{Header: "X-Plan", Pattern: regexp.MustCompile("^free$"), Action: "free"},
+	}}
+	tests := []struct {
+		name       string
+		rule       routestore.CompiledRule
+		plan       string
+		wantPassed bool
+		wantFree   string
+	}{
+		{name: "free rule", rule: routestore.CompiledRule{Free: true}, wantPassed: true, wantFree: "free"},
+		{name: "condition frees", rule: conditional, plan: "free", wantPassed: true, wantFree: "conditional_free"},
+		{name: "condition requires payment", rule: conditional, plan: "pro", wantPassed: true},
+		{name: "paid rule", rule: routestore.CompiledRule{Price: "0.01"}, wantPassed: true},
+		{name: "invalid graphql", rule: routestore.CompiledRule{GraphQL: &routestore.CompiledGraphQL{MaxBodyBytes: 1024}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTestRequest("POST", "/graphql")
+			req.r.Body = io.NopCloser(strings.NewReader("not json"))
+			req.r.Header.Set("X-Plan", tt.plan)
+			req.route, req.rule = route, &tt.rule
+			if passed := runStage(applyConditions, req); passed != tt.wantPassed || req.free != tt.wantFree {
+				t.Errorf("passed = %v, free = %q, want %v, %q", passed, req.free, tt.wantPassed, tt.wantFree)
+			}
+		})
+	}
+}
+
+// TestInsertedStageRunsBeforeSettlement checks that a stage registered before
+// settlement can turn a verified request away without it being charged.
+func TestInsertedStageRunsBeforeSettlement(t *testing.T) {
+	var settles atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		settles.Add(1)
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+		Rules:    []routestore.CompiledRule{{Path: "/api/*", Price: "0.01", Mode: "all-pay"}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: "http://127.0.0.1:1"}},
+	})
+	h := NewHandler(store)
+	var payer string
+	err := h.insertStage(stageSettlement, stage{name: "quota", paid: true, serve: func(req *request, next func()) {
+		payer = req.verified.Payer
+		http.Error(req.w, "quota exceeded", http.StatusTooManyRequests)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/api/data", nil)
+	r.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests || payer != "0xPayer" {
+		t.Errorf("status = %d, payer = %q, want 429 and the verified payer", w.Code, payer)
+	}
+	if n := settles.Load(); n != 0 {
+		t.Errorf("settlements = %d, want 0", n)
+	}
+}
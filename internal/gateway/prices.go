@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// PricesPath serves the price table of the requesting host, for publishers to
+// link customers to.
+const PricesPath = "/x402/prices"
+
+// priceTable is the body of a prices response.
+type priceTable struct {
+	Host    string      `json:"host"`
+	BaseURL string      `json:"baseURL,omitempty"` // public origin of the paths, from the Ingress
+	Prices  []pathPrice `json:"prices"`
+}
+
+// pathPrice is what a gated path charges. Price is the most a request pays
+// for metered rules.
+type pathPrice struct {
+	Path        string            `json:"path"`
+	Price       string            `json:"price"`
+	Asset       string            `json:"asset,omitempty"` // token override; empty is the network's USDC
+	Network     string            `json:"network"`
+	Sandbox     bool              `json:"sandbox,omitempty"`
+	Metered     bool              `json:"metered,omitempty"`
+	Conditional bool              `json:"conditional,omitempty"` // only requests matching the rule conditions pay
+	Offers      []offerPrice      `json:"offers,omitempty"`
+	Operations  map[string]string `json:"operations,omitempty"` // GraphQL operation prices
+	VariesBy    []string          `json:"variesBy,omitempty"`   // query parameters that change the price
+}
+
+type offerPrice struct {
+	Name  string `json:"name"`
+	Price string `json:"price"`
+}
+
+// servePrices answers GET /x402/prices with the gated paths and prices of the
+// routes serving the request host, in the order the gateway matches them.
+// Browsers, or ?format=html, get an HTML table.
+func (h *Handler) servePrices(w http.ResponseWriter, r *http.Request) {
+	table := priceTable{Host: requestHost(r), Prices: []pathPrice{}}
+	seen := make(map[string]bool)
+	for _, route := range h.store.Snapshot() {
+		if !h.matchesHost(table.Host, route) {
+			continue
+		}
+		if table.BaseURL == "" {
+			table.BaseURL = publicOrigin(r, route)
+		}
+		for _, rule := range hostRules(route, table.Host) {
+			// The first route with a rule for a path serves it.
+			if seen[rule.Path] {
+				continue
+			}
+			seen[rule.Path] = true
+			if !rule.Free {
+				table.Prices = append(table.Prices, describePrice(route, rule))
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	if wantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		pricesPage.Execute(w, table)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(table)
+}
+
+// hostRules returns the rules of route that serve host in the order the
+// gateway matches them: the host's own rules, then the catch-all rules.
+func hostRules(route *routestore.CompiledRoute, host string) []*routestore.CompiledRule {
+	rules := make([]*routestore.CompiledRule, 0, len(route.Rules))
+	for i := range route.Rules {
+		if route.Rules[i].Host != "" && matchHost(route.Rules[i].Host, host) {
+			rules = append(rules, &route.Rules[i])
+		}
+	}
+	for i := range route.Rules {
+		if route.Rules[i].Host == "" {
+			rules = append(rules, &route.Rules[i])
+		}
+	}
+	return rules
+}
+
+func describePrice(route *routestore.CompiledRoute, rule *routestore.CompiledRule) pathPrice {
+	p := pathPrice{
+		Path:        rule.Path,
+		Price:       rule.Price,
+		Asset:       route.Asset,
+		Network:     route.Network,
+		Sandbox:     route.Sandbox,
+		Metered:     rule.Metering != nil,
+		Conditional: rule.Mode == "conditional" && len(rule.Conditions) > 0,
+	}
+	for _, offer := range rule.Offers {
+		p.Offers = append(p.Offers, offerPrice{Name: offer.Name, Price: offer.Price})
+	}
+	if rule.GraphQL != nil {
+		p.Operations = rule.GraphQL.Operations
+	}
+	for _, mod := range rule.Modifiers {
+		if !slices.Contains(p.VariesBy, mod.Param) {
+			p.VariesBy = append(p.VariesBy, mod.Param)
+		}
+	}
+	return p
+}
+
+// requestHost returns the request host without its port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	return host
+}
+
+// wantsHTML reports whether the price table is rendered as HTML: with
+// ?format=html, or for browsers unless ?format=json.
+func wantsHTML(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "html":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+var pricesPage = template.Must(template.New("prices").Funcs(template.FuncMap{
+	"fiat": isFiatPrice,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Prices for {{.Host}}</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.4rem 0.8rem; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Prices for {{.Host}}</h1>
+{{- if .Prices}}
+<table>
+<tr><th>Path</th><th>Price</th><th>Network</th><th>Notes</th></tr>
+{{- range .Prices}}
+<tr>
+<td><code>{{.Path}}</code></td>
+<td>{{if .Metered}}up to {{end}}{{.Price}}{{if not (fiat .Price)}} {{if .Asset}}<code>{{.Asset}}</code>{{else}}USDC{{end}}{{end}}
+{{- range .Offers}}<br>{{.Name}}: {{.Price}}{{end}}
+{{- range $op, $price := .Operations}}<br><code>{{$op}}</code>: {{$price}}{{end}}</td>
+<td>{{.Network}}</td>
+<td>
+{{- if .Sandbox}}Test network. {{end}}
+{{- if .Metered}}Charged by usage. {{end}}
+{{- if .Conditional}}Only some requests pay. {{end}}
+{{- if .VariesBy}}Varies with {{range $i, $p := .VariesBy}}{{if $i}}, {{end}}<code>?{{$p}}</code>{{end}}.{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No paid paths.</p>
+{{- end}}
+<p>Paid with the <a href="https://x402.org">x402</a> protocol.</p>
+</body>
+</html>
+`))
@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestServePrices(t *testing.T) {
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Hosts: []string{"api.example.com"}, TLSHosts: []string{"api.example.com"}, Network: "base",
+		Rules: []routestore.CompiledRule{
+			{Path: "/health", Free: true},
+			{Path: "/api/report", Price: "0.05", Offers: []routestore.CompiledOffer{{Name: "basic", Price: "0.05"}, {Name: "pro", Price: "0.20"}}},
+			{Path: "/api/search", Price: "0.01", Modifiers: []routestore.CompiledPriceModifier{{Param: "resolution"}, {Param: "resolution"}}},
+			{Path: "/api/*", Price: "$0.01 USD", Mode: "conditional", Conditions: []routestore.CompiledCondition{{Header: "User-Agent"}}},
+		},
+	})
+	store.Set("default", "llm", &routestore.CompiledRoute{
+		Name: "llm", Namespace: "default", Hosts: []string{"*.example.com"}, Network: "base-sepolia", Sandbox: true,
+		Rules: []routestore.CompiledRule{
+			{Path: "/api/*", Price: "9"},
+			{Path: "/v1/chat", Price: "0.10", Metering: &routestore.CompiledMetering{}},
+			{Path: "/graphql", Price: "0.01", GraphQL: &routestore.CompiledGraphQL{Operations: map[string]string{"search": "0.02"}}},
+		},
+	})
+	store.Set("shop", "web", &routestore.CompiledRoute{
+		Name: "web", Namespace: "shop", Hosts: []string{"shop.test"}, Network: "base",
+		Rules: []routestore.CompiledRule{{Path: "/buy", Price: "1"}},
+	})
+	store.Set("shop", "data", &routestore.CompiledRoute{
+		Name: "data", Namespace: "shop", Hosts: []string{"api.data.test", "files.data.test"}, Network: "base",
+		Rules: []routestore.CompiledRule{
+			{Path: "/v1/*", Price: "0.01"},
+			{Path: "/v1/*", Host: "files.data.test", Price: "0.05"},
+			{Path: "/v1/admin", Host: "api.data.test", Price: "1"},
+		},
+	})
+	h := NewHandler(store)
+
+	tests := []struct {
+		name        string
+		host        string
+		wantBaseURL string
+		want        []pathPrice
+	}{
+		{
+			name:        "routes of the host in match order",
+			host:        "api.example.com:443",
+			wantBaseURL: "https://api.example.com",
+			want: []pathPrice{
+				{Path: "/api/report", Price: "0.05", Network: "base", Offers: []offerPrice{{"basic", "0.05"}, {"pro", "0.20"}}},
+				{Path: "/api/search", Price: "0.01", Network: "base", VariesBy: []string{"resolution"}},
+				{Path: "/api/*", Price: "$0.01 USD", Network: "base", Conditional: true},
+				{Path: "/v1/chat", Price: "0.10", Network: "base-sepolia", Sandbox: true, Metered: true},
+				{Path: "/graphql", Price: "0.01", Network: "base-sepolia", Sandbox: true, Operations: map[string]string{"search": "0.02"}},
+			},
+		},
+		{
+			name:        "wildcard host only",
+			host:        "llm.example.com",
+			wantBaseURL: "http://llm.example.com",
+			want: []pathPrice{
+				{Path: "/api/*", Price: "9", Network: "base-sepolia", Sandbox: true},
+				{Path: "/v1/chat", Price: "0.10", Network: "base-sepolia", Sandbox: true, Metered: true},
+				{Path: "/graphql", Price: "0.01", Network: "base-sepolia", Sandbox: true, Operations: map[string]string{"search": "0.02"}},
+			},
+		},
+		{
+			name:        "virtual host rules before catch-all rules",
+			host:        "api.data.test",
+			wantBaseURL: "http://api.data.test",
+			want: []pathPrice{
+				{Path: "/v1/admin", Price: "1", Network: "base"},
+				{Path: "/v1/*", Price: "0.01", Network: "base"},
+			},
+		},
+		{
+			name:        "virtual host rule shadows catch-all rule",
+			host:        "files.data.test",
+			wantBaseURL: "http://files.data.test",
+			want:        []pathPrice{{Path: "/v1/*", Price: "0.05", Network: "base"}},
+		},
+		{name: "unknown host", host: "other.test", want: []pathPrice{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", PricesPath, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			h.servePrices(w, req)
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var table priceTable
+			if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil {
+				t.Fatalf("unmarshal %s: %v", w.Body.String(), err)
+			}
+			if table.BaseURL != tt.wantBaseURL {
+				t.Errorf("baseURL = %q, want %q", table.BaseURL, tt.wantBaseURL)
+			}
+			if !reflect.DeepEqual(table.Prices, tt.want) {
+				t.Errorf("prices = %+v, want %+v", table.Prices, tt.want)
+			}
+		})
+	}
+
+	for _, target := range []string{PricesPath + "?format=html", PricesPath} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Host = "api.example.com"
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		w := httptest.NewRecorder()
+		h.servePrices(w, req)
+
+		body := w.Body.String()
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: Content-Type = %q", target, ct)
+		}
+		for _, want := range []string{"Prices for api.example.com", "<code>/api/report</code>", "0.05 USDC", "pro: 0.20", "up to 0.10 USDC", "<td>$0.01 USD</td>", "<code>?resolution</code>"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: HTML lacks %q:\n%s", target, want, body)
+			}
+		}
+	}
+}
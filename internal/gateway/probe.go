@@ -0,0 +1,215 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/client"
+)
+
+// ProbeHeader carries the token of synthetic probe requests. It is removed
+// before requests reach the backend.
+const ProbeHeader = "X-402-Probe"
+
+// probeSegment replaces the wildcards of a rule's path in probe requests.
+const probeSegment = "x402-probe"
+
+// probePayer is the payer of mock probe payments.
+const probePayer = "0x0000000000000000000000000000000000000001"
+
+// ErrNoProbePath is returned for routes without a path a probe can pay for:
+// every rule is free, conditional or GraphQL.
+var ErrNoProbePath = errors.New("no unconditionally paid path to probe")
+
+// Prober sends synthetic paid requests through the gateway. Probe requests
+// are paid with a mock payment that the probe facilitator accepts instead of
+// the route's facilitator, and their settlements are not recorded, billed,
+// mirrored or called back.
+type Prober struct {
+	gatewayURL     string
+	token          string
+	facilitatorURL string
+	client         *http.Client
+}
+
+// EnableProbes makes the gateway accept probe requests paid through the
+// facilitator at facilitatorURL, typically cmd/mock-facilitator or another
+// sandbox facilitator, and returns the prober that sends them. Call before
+// Start.
+func (s *Server) EnableProbes(facilitatorURL string) (*Prober, error) {
+	host, port, err := net.SplitHostPort(s.addrs[0])
+	if err != nil {
+		return nil, fmt.Errorf("gateway address %q: %w", s.addrs[0], err)
+	}
+	host = loopbackFor(host)
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	p := &Prober{
+		gatewayURL:     "http://" + net.JoinHostPort(host, port),
+		token:          hex.EncodeToString(token),
+		facilitatorURL: facilitatorURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	s.handler.prober = p
+	return p, nil
+}
+
+// Probe requests the first unconditionally paid path of route without
+// payment, pays the 402 response with a mock payment and checks the
+// backend's answer. It returns a summary of a successful probe.
+func (p *Prober) Probe(ctx context.Context, route *routestore.CompiledRoute) (string, error) {
+	host, path := probeTarget(route)
+	if path == "" {
+		return "", ErrNoProbePath
+	}
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.gatewayURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if host != "" {
+			req.Host = host
+		}
+		req.Header.Set(ProbeHeader, p.token)
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GET %s: %w", path, err)
+	}
+	closeProbeBody(resp)
+	if resp.StatusCode != http.StatusPaymentRequired {
+		return "", fmt.Errorf("GET %s without payment answered %d, want 402", path, resp.StatusCode)
+	}
+	required, err := client.ParsePaymentRequired(resp)
+	if err != nil {
+		return "", fmt.Errorf("GET %s: %w", path, err)
+	}
+	payment, err := mockPayment(required)
+	if err != nil {
+		return "", fmt.Errorf("GET %s: %w", path, err)
+	}
+
+	if req, err = newRequest(); err != nil {
+		return "", err
+	}
+	req.Header.Set(client.HeaderPaymentSignature, payment)
+	resp, err = p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("paid GET %s: %w", path, err)
+	}
+	closeProbeBody(resp)
+	switch {
+	case resp.StatusCode == http.StatusPaymentRequired:
+		reason := "payment rejected"
+		if required, err := client.ParsePaymentRequired(resp); err == nil && required.Error != "" {
+			reason = required.Error
+		}
+		return "", fmt.Errorf("paid GET %s answered 402: %s", path, reason)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return "", fmt.Errorf("paid GET %s answered %d", path, resp.StatusCode)
+	}
+	return fmt.Sprintf("Paid GET %s answered %d", path, resp.StatusCode), nil
+}
+
+// probeTarget returns the host and request path for the first rule of route
+// that every GET request pays for, with wildcards filled in, or "". Rules
+// for a wildcard host are skipped, as they name no host to request.
+func probeTarget(route *routestore.CompiledRoute) (host, path string) {
+	for _, rule := range route.Rules {
+		if rule.Free || rule.Mode == "conditional" || rule.GraphQL != nil || strings.HasPrefix(rule.Host, "*.") {
+			continue
+		}
+		host = rule.Host
+		if host == "" && len(route.Hosts) > 0 {
+			host = route.Hosts[0]
+		}
+		segments := strings.Split(rule.Path, "/")
+		for i, s := range segments {
+			if s == "*" || s == "**" {
+				segments[i] = probeSegment
+			}
+		}
+		return host, strings.Join(segments, "/")
+	}
+	return "", ""
+}
+
+// mockPayment returns a Payment-Signature header paying the first of
+// required.Accepts with a transfer from probePayer. It is not a valid
+// on-chain payment.
+func mockPayment(required *client.PaymentRequired) (string, error) {
+	if len(required.Accepts) == 0 {
+		return "", errors.New("402 response offers no payment requirements")
+	}
+	accept := required.Accepts[0]
+	now := time.Now()
+	payload, err := json.Marshal(map[string]any{
+		"x402Version": required.X402Version,
+		"scheme":      accept.Scheme,
+		"network":     accept.Network,
+		"accepted":    accept,
+		"payload": map[string]any{
+			"signature": "0x" + probeSegment,
+			"authorization": map[string]string{
+				"from":        probePayer,
+				"to":          accept.PayTo,
+				"value":       accept.Amount,
+				"validAfter":  "0",
+				"validBefore": fmt.Sprint(now.Add(time.Hour).Unix()),
+				"nonce":       fmt.Sprintf("0x%x", now.UnixNano()),
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// closeProbeBody drains and closes a probe response body.
+func closeProbeBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+// isProbe reports whether r carries the token of p.
+func (p *Prober) isProbe(r *http.Request) bool {
+	return p != nil && subtle.ConstantTimeCompare([]byte(r.Header.Get(ProbeHeader)), []byte(p.token)) == 1
+}
+
+// probeRoute returns a copy of route that pays through the probe facilitator
+// and neither fails open, mirrors nor calls back.
+func (p *Prober) probeRoute(route *routestore.CompiledRoute) *routestore.CompiledRoute {
+	probe := *route
+	probe.FacilitatorURL, probe.FacilitatorType, probe.FacilitatorAuth = p.facilitatorURL, "", nil
+	probe.FailoverURLs = nil
+	probe.OnFacilitatorError = "failClosed"
+	probe.Mirror = nil
+	probe.Callbacks = false
+	return &probe
+}
@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestProbe(t *testing.T) {
+	var backendStatus atomic.Int32
+	var sawToken atomic.Bool
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(ProbeHeader) != "" {
+			sawToken.Store(true)
+		}
+		w.WriteHeader(int(backendStatus.Load()))
+	}))
+	defer backendSrv.Close()
+	var valid atomic.Bool
+	sandbox := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/verify") && valid.Load():
+			io.WriteString(w, `{"isValid":true,"payer":"0x0000000000000000000000000000000000000001"}`)
+		case strings.HasSuffix(r.URL.Path, "/verify"):
+			io.WriteString(w, `{"isValid":false,"invalidReason":"insufficient_funds"}`)
+		default:
+			io.WriteString(w, `{"success":true,"transaction":"0xmock","network":"eip155:84532"}`)
+		}
+	}))
+	defer sandbox.Close()
+	var production atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		production.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer facilitator.Close()
+
+	route := &routestore.CompiledRoute{
+		Name:               "my-api",
+		Namespace:          "default",
+		Hosts:              []string{"api.example.com"},
+		Wallet:             "0xTestWallet",
+		Network:            "base-sepolia",
+		FacilitatorURL:     facilitator.URL,
+		OnFacilitatorError: "failOpen",
+		Rules: []routestore.CompiledRule{
+			{Path: "/health", Free: true},
+			{Path: "/api/*/items", Price: "0.001", Mode: "all-pay"},
+		},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	}
+	store := routestore.New()
+	store.Set("default", "my-api", route)
+	h := NewHandler(store)
+	sink := &recordingSink{}
+	h.settlementSinks = []SettlementSink{sink}
+	gw := httptest.NewServer(h)
+	defer gw.Close()
+	p := &Prober{gatewayURL: gw.URL, token: "probe-token", facilitatorURL: sandbox.URL, client: gw.Client()}
+	h.prober = p
+
+	tests := []struct {
+		name          string
+		backendStatus int
+		valid         bool
+		want          string
+		wantErr       string
+	}{
+		{name: "paid", backendStatus: http.StatusOK, valid: true, want: "Paid GET /api/x402-probe/items answered 200"},
+		{name: "backend error", backendStatus: http.StatusBadGateway, valid: true, wantErr: "answered 502"},
+		{name: "payment rejected", backendStatus: http.StatusOK, wantErr: "answered 402"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backendStatus.Store(int32(tt.backendStatus))
+			valid.Store(tt.valid)
+			got, err := p.Probe(context.Background(), route)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Probe() = %q, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Probe() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if production.Load() != 0 {
+		t.Errorf("route facilitator called %d times, want only the sandbox facilitator", production.Load())
+	}
+	if len(sink.records) != 0 {
+		t.Errorf("probe settlements recorded: %+v", sink.records)
+	}
+	if sawToken.Load() {
+		t.Error("backend received the probe token")
+	}
+
+	// Without the token the route's own facilitator is used.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/items", nil)
+	req.Host = "api.example.com"
+	req.Header.Set(ProbeHeader, "guess")
+	req.Header.Set("Payment-Signature", "e30=")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if production.Load() == 0 {
+		t.Error("request with a wrong probe token did not use the route's facilitator")
+	}
+
+	free := &routestore.CompiledRoute{Rules: []routestore.CompiledRule{{Path: "/health", Free: true}}}
+	if _, err := p.Probe(context.Background(), free); !errors.Is(err, ErrNoProbePath) {
+		t.Errorf("Probe() of a free route error = %v, want ErrNoProbePath", err)
+	}
+}
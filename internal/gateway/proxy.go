@@ -1,51 +1,302 @@
 package gateway
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync"
+	"time"
 
 	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
 )
 
-// proxyToBackend forwards the request to the appropriate backend.
-func proxyToBackend(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, path string) {
-	backendURL := findBackend(route.Backends, path)
-	if backendURL == "" {
+// endpoints balances requests for backends resolved to EndpointSlice addresses.
+var endpoints = newBalancer()
+
+// proxyToBackend forwards the request to the appropriate backend: the
+// external backend of rule when it has one, otherwise the route backend of
+// path. rule may be nil.
+func proxyToBackend(w http.ResponseWriter, r *http.Request, route *routestore.CompiledRoute, rule *routestore.CompiledRule, path string) {
+	backend := findBackend(route.Backends, path)
+	if rule != nil && rule.Backend != nil {
+		backend = rule.Backend
+	}
+	if backend == nil {
 		slog.Error("no backend found for path", "path", path, "route", route.Name)
 		http.Error(w, "no backend configured", http.StatusBadGateway)
 		return
 	}
 
-	target, err := url.Parse(backendURL)
+	backendURL := backend.URL
+	if len(backend.Endpoints) > 0 {
+		backendURL = endpoints.pick(backend.Endpoints)
+	}
+
+	proxy, err := proxies.get(backendURL, backend)
 	if err != nil {
 		slog.Error("failed to parse backend URL", "url", backendURL, "error", err)
 		http.Error(w, "bad backend URL", http.StatusBadGateway)
 		return
 	}
-
-	proxy := httputil.NewSingleHostReverseProxy(target)
+	if backend.External {
+		if r, err = externalRequest(r, backend); err != nil {
+			slog.Error("failed to prepare external backend request", "url", backendURL, "route", route.Name, "error", err)
+			http.Error(w, "external backend unavailable", http.StatusBadGateway)
+			return
+		}
+	}
 	proxy.ServeHTTP(w, r)
 }
 
-// findBackend finds the best matching backend URL for a path.
-func findBackend(backends map[string]string, path string) string {
-	// Exact match first.
-	if u, ok := backends[path]; ok {
-		return u
+// maxCachedProxies bounds the reverse proxy cache; endpoint addresses churn
+// with pod rollouts, so the cache is reset once it grows past this size.
+const maxCachedProxies = 1024
+
+// proxyCache reuses one ReverseProxy per backend URL and protocol instead of
+// constructing a proxy for every request.
+type proxyCache struct {
+	mu      sync.RWMutex
+	proxies map[proxyKey]*httputil.ReverseProxy
+}
+
+type proxyKey struct {
+	url      string
+	protocol string
+	socket   string
+	external bool
+	// tls is the TLS configuration of the compiled backend. Recompiled routes
+	// get new proxies, which share transports by backendTLSKey.
+	tls *routestore.CompiledBackendTLS
+}
+
+// proxies is the gateway's shared reverse proxy cache.
+var proxies = &proxyCache{proxies: make(map[proxyKey]*httputil.ReverseProxy)}
+
+// backendTransports are the transports of the backend protocols, shared by all
+// proxies so connections are pooled per backend address. HTTP/1.1 backends use
+// http.DefaultTransport.
+var backendTransports = map[string]http.RoundTripper{
+	"h2c":   newBackendTransport(func(p *http.Protocols) { p.SetUnencryptedHTTP2(true) }),
+	"h2":    newBackendTransport(func(p *http.Protocols) { p.SetHTTP2(true); p.SetHTTP1(true) }),
+	"https": newBackendTransport(func(p *http.Protocols) { p.SetHTTP1(true) }),
+}
+
+// newBackendTransport clones http.DefaultTransport with the given protocols.
+// Like ingress-nginx with backend-protocol HTTPS, it does not verify backend
+// certificates: in-cluster backends rarely carry publicly trusted ones.
+func newBackendTransport(protocols func(*http.Protocols)) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Protocols = new(http.Protocols)
+	protocols(t.Protocols)
+	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return t
+}
+
+// tlsTransports holds one transport per backend protocol and TLS
+// configuration of spec.backendTLS, keyed by protocol and backendTLSKey.
+var tlsTransports sync.Map
+
+// backendTLSKey identifies a backend TLS configuration by its contents.
+func backendTLSKey(cfg *routestore.CompiledBackendTLS) string {
+	sum := sha256.Sum256(cfg.CA)
+	return fmt.Sprintf("%x|%s|%t", sum[:8], cfg.ServerName, cfg.InsecureSkipVerify)
+}
+
+// tlsTransport returns the transport of protocol that verifies backends with
+// cfg.
+func tlsTransport(protocol string, cfg *routestore.CompiledBackendTLS) http.RoundTripper {
+	key := protocol + "|" + backendTLSKey(cfg)
+	if t, ok := tlsTransports.Load(key); ok {
+		return t.(http.RoundTripper)
+	}
+	t := newBackendTransport(func(p *http.Protocols) {
+		p.SetHTTP1(true)
+		if protocol == "h2" {
+			p.SetHTTP2(true)
+		}
+	})
+	t.TLSClientConfig = &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if len(cfg.CA) > 0 {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(cfg.CA)
+		t.TLSClientConfig.RootCAs = pool
 	}
+	actual, _ := tlsTransports.LoadOrStore(key, t)
+	return actual.(http.RoundTripper)
+}
+
+// externalTransports holds one transport per TLS configuration of external
+// backends, keyed by backendTLSKey; "" verifies the URL host.
+var externalTransports sync.Map
 
-	// Pattern match.
-	for pattern, u := range backends {
-		if matchPath(pattern, path) {
-			return u
+// externalTransport returns the transport of external backends. It verifies
+// upstream certificates against the system roots, bypasses HTTP proxies from
+// the environment and refuses to dial non-public addresses, so a host name
+// resolving into the cluster network cannot be reached.
+func externalTransport(cfg *routestore.CompiledBackendTLS) http.RoundTripper {
+	var key string
+	if cfg != nil {
+		key = backendTLSKey(cfg)
+	}
+	if t, ok := externalTransports.Load(key); ok {
+		return t.(http.RoundTripper)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: externalDialControl}
+	t.DialContext = dialer.DialContext
+	t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg != nil {
+		t.TLSClientConfig.ServerName = cfg.ServerName
+		if len(cfg.CA) > 0 {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(cfg.CA)
+			t.TLSClientConfig.RootCAs = pool
 		}
 	}
+	actual, _ := externalTransports.LoadOrStore(key, t)
+	return actual.(http.RoundTripper)
+}
+
+// externalDialControl vets the addresses external backends are dialed at;
+// tests replace it to reach local servers.
+var externalDialControl = publicAddressOnly
+
+// externalRequest returns a copy of r to forward to an external backend:
+// without the payment headers, which are of no use to the upstream, and with
+// the backend credential in place of any value the client sent.
+func externalRequest(r *http.Request, backend *routestore.CompiledBackend) (*http.Request, error) {
+	out := r.Clone(r.Context())
+	out.Header.Del("Payment-Signature")
+	out.Header.Del("X-Payment")
+	if auth := backend.Auth; auth != nil {
+		if facilitatorSecrets == nil {
+			return nil, errors.New("external backend credential needs Vault; set VAULT_ADDR on the operator")
+		}
+		value, err := facilitatorSecrets.Value(r.Context(), auth.VaultPath, auth.VaultKey)
+		if err != nil {
+			return nil, fmt.Errorf("read external backend credential: %w", err)
+		}
+		out.Header.Set(auth.Header, auth.Prefix+value)
+	}
+	return out, nil
+}
+
+// socketTransports holds one transport per Unix domain socket of a sidecar
+// backend, keyed by socket path.
+var socketTransports sync.Map
+
+// socketTransport returns the transport that dials a Unix domain socket.
+func socketTransport(socket string) http.RoundTripper {
+	if t, ok := socketTransports.Load(socket); ok {
+		return t.(http.RoundTripper)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	actual, _ := socketTransports.LoadOrStore(socket, t)
+	return actual.(http.RoundTripper)
+}
+
+// get returns the reverse proxy for a backend URL, creating it on first use.
+// backendURL is the backend's URL or one of its endpoints.
+func (c *proxyCache) get(backendURL string, backend *routestore.CompiledBackend) (*httputil.ReverseProxy, error) {
+	protocol, socket, tlsCfg := backend.Protocol, backend.Socket, backend.TLS
+	key := proxyKey{url: backendURL, protocol: protocol, socket: socket, external: backend.External, tls: tlsCfg}
+	c.mu.RLock()
+	proxy, ok := c.proxies[key]
+	c.mu.RUnlock()
+	if ok {
+		return proxy, nil
+	}
+
+	target, err := url.Parse(backendURL)
+	if err != nil {
+		return nil, err
+	}
+	// Endpoint URLs are built as http://; TLS protocols dial them over TLS.
+	if protocol == "h2" || protocol == "https" {
+		target.Scheme = "https"
+	}
+	proxy = httputil.NewSingleHostReverseProxy(target)
+	if transport, ok := backendTransports[protocol]; ok {
+		proxy.Transport = transport
+	}
+	if tlsCfg != nil && (protocol == "h2" || protocol == "https") {
+		proxy.Transport = tlsTransport(protocol, tlsCfg)
+	}
+	if socket != "" {
+		proxy.Transport = socketTransport(socket)
+	}
+	if backend.External {
+		proxy.Transport = externalTransport(tlsCfg)
+		// Upstreams outside the cluster route by their own host name, not
+		// the Ingress host the client called.
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			r.Host = target.Host
+		}
+	}
+	// Failed endpoints are ejected from balancing; Service URLs are never
+	// picked by the balancer, so marking them is harmless. A client hanging
+	// up says nothing about the endpoint, so it is not ejected for that.
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, context.Canceled) || r.Context().Err() != nil {
+			slog.Debug("client canceled backend request", "backend", backendURL, "error", err)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		slog.Error("backend request failed", "backend", backendURL, "error", err)
+		endpoints.markFailed(backendURL)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.proxies) >= maxCachedProxies {
+		c.proxies = make(map[proxyKey]*httputil.ReverseProxy)
+	}
+	c.proxies[key] = proxy
+	return proxy, nil
+}
+
+// findBackend finds the best matching backend for a path, following Ingress
+// precedence: an Exact match wins, then the longest matching path.
+func findBackend(backends []routestore.CompiledBackend, path string) *routestore.CompiledBackend {
+	var best *routestore.CompiledBackend
+	for i := range backends {
+		b := &backends[i]
+		if !matchIngressPath(b.PathType, b.Path, path) {
+			continue
+		}
+		if b.PathType == "Exact" {
+			return b
+		}
+		if best == nil || len(b.Path) > len(best.Path) {
+			best = b
+		}
+	}
+	if best != nil {
+		return best
+	}
 
 	// Fallback to any backend (single-backend common case).
-	for _, u := range backends {
-		return u
+	if len(backends) > 0 {
+		return &backends[0]
 	}
-	return ""
+	return nil
 }
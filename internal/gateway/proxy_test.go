@@ -0,0 +1,235 @@
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestProxyBackendProtocols(t *testing.T) {
+	proto := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+
+	http1 := httptest.NewServer(proto)
+	defer http1.Close()
+
+	// A cleartext HTTP/2 server with prior knowledge, as gRPC servers are.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2cSrv := &http.Server{Handler: proto, Protocols: new(http.Protocols)}
+	h2cSrv.Protocols.SetUnencryptedHTTP2(true)
+	go h2cSrv.Serve(ln)
+	defer h2cSrv.Close()
+
+	h2 := httptest.NewUnstartedServer(proto)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		protocol string
+		want     string
+	}{
+		{name: "default", url: http1.URL, want: "HTTP/1.1"},
+		{name: "http1", url: http1.URL, protocol: "http1", want: "HTTP/1.1"},
+		{name: "h2c", url: "http://" + ln.Addr().String(), protocol: "h2c", want: "HTTP/2.0"},
+		{name: "h2", url: strings.Replace(h2.URL, "https://", "http://", 1), protocol: "h2", want: "HTTP/2.0"},
+		{name: "https", url: h2.URL, protocol: "https", want: "HTTP/1.1"},
+		{name: "https endpoint", url: strings.Replace(h2.URL, "https://", "http://", 1), protocol: "https", want: "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &routestore.CompiledRoute{
+				Name:     "proto",
+				Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: tt.url, Protocol: tt.protocol}},
+			}
+			w := httptest.NewRecorder()
+			proxyToBackend(w, httptest.NewRequest("GET", "/", nil), route, nil, "/")
+			if w.Code != http.StatusOK || w.Body.String() != tt.want {
+				t.Errorf("backend saw %d %q, want 200 %q", w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyBackendTLS(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.ServerName)
+	}))
+	defer backend.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw})
+	otherCA := selfSignedPEM(t)
+
+	tests := []struct {
+		name     string
+		tls      *routestore.CompiledBackendTLS
+		wantCode int
+		wantBody string
+	}{
+		{name: "trusted CA", tls: &routestore.CompiledBackendTLS{CA: ca, ServerName: "example.com"}, wantCode: http.StatusOK, wantBody: "example.com"},
+		{name: "untrusted CA", tls: &routestore.CompiledBackendTLS{CA: otherCA, ServerName: "example.com"}, wantCode: http.StatusBadGateway},
+		{name: "wrong server name", tls: &routestore.CompiledBackendTLS{CA: ca, ServerName: "api.default.svc"}, wantCode: http.StatusBadGateway},
+		{name: "insecure", tls: &routestore.CompiledBackendTLS{ServerName: "api.default.svc", InsecureSkipVerify: true}, wantCode: http.StatusOK, wantBody: "api.default.svc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &routestore.CompiledRoute{
+				Name:     "tls",
+				Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backend.URL, Protocol: "https", TLS: tt.tls}},
+			}
+			w := httptest.NewRecorder()
+			proxyToBackend(w, httptest.NewRequest("GET", "/", nil), route, nil, "/")
+			if w.Code != tt.wantCode || (tt.wantBody != "" && w.Body.String() != tt.wantBody) {
+				t.Errorf("backend answered %d %q, want %d %q", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+// selfSignedPEM returns a new self-signed CA certificate, which httptest
+// servers do not share.
+func selfSignedPEM(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestProxyExternalBackend(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host+" "+r.URL.Path+" "+r.Header.Get("X-Payment")+r.Header.Get("Payment-Signature"))
+	}))
+	defer upstream.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	t.Cleanup(func() {
+		externalDialControl = publicAddressOnly
+		externalTransports.Clear()
+	})
+
+	tests := []struct {
+		name     string
+		control  func(network, address string, c syscall.RawConn) error
+		wantCode int
+		wantBody string
+	}{
+		{name: "public addresses only", control: publicAddressOnly, wantCode: http.StatusBadGateway},
+		{name: "reachable", wantCode: http.StatusOK, wantBody: strings.TrimPrefix(upstream.URL, "https://") + " /v1/api/data "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			externalDialControl = tt.control
+			externalTransports.Clear()
+			rule := &routestore.CompiledRule{Path: "/api/data", Backend: &routestore.CompiledBackend{
+				Path: "/", PathType: "Prefix", URL: upstream.URL + "/v1", Protocol: "https", External: true,
+				TLS: &routestore.CompiledBackendTLS{CA: ca, ServerName: "example.com"},
+			}}
+			route := &routestore.CompiledRoute{
+				Name:     "external",
+				Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: "http://127.0.0.1:1"}},
+			}
+			r := httptest.NewRequest("GET", "http://shop.example.com/api/data", nil)
+			r.Header.Set("X-Payment", "proof")
+			r.Header.Set("Payment-Signature", "proof")
+			w := httptest.NewRecorder()
+			proxyToBackend(w, r, route, rule, "/api/data")
+			if w.Code != tt.wantCode || (tt.wantBody != "" && w.Body.String() != tt.wantBody) {
+				t.Errorf("upstream answered %d %q, want %d %q", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+			if r.Header.Get("X-Payment") != "proof" {
+				t.Error("client request headers were modified")
+			}
+		})
+	}
+}
+
+func TestProxySidecarSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "backend.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "sidecar "+r.URL.Path)
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	route := &routestore.CompiledRoute{
+		Name:     "sidecar",
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: "http://localhost", Socket: socket}},
+	}
+	w := httptest.NewRecorder()
+	proxyToBackend(w, httptest.NewRequest("GET", "/api/data", nil), route, nil, "/api/data")
+	if w.Code != http.StatusOK || w.Body.String() != "sidecar /api/data" {
+		t.Errorf("response = %d %q, want 200 %q", w.Code, w.Body.String(), "sidecar /api/data")
+	}
+}
+
+func TestProxyClientCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	route := &routestore.CompiledRoute{
+		Name:     "canceled",
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: url}},
+	}
+	unhealthy := func() bool {
+		endpoints.mu.Lock()
+		defer endpoints.mu.Unlock()
+		_, ok := endpoints.unhealthy[url]
+		return ok
+	}
+
+	// A client hanging up does not eject the backend.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	proxyToBackend(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx), route, nil, "/")
+	if unhealthy() {
+		t.Fatal("backend ejected after the client canceled")
+	}
+
+	proxyToBackend(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), route, nil, "/")
+	if !unhealthy() {
+		t.Error("unreachable backend not ejected")
+	}
+	endpoints.mu.Lock()
+	delete(endpoints.unhealthy, url)
+	endpoints.mu.Unlock()
+}
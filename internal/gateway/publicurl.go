@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// trustForwardedProto makes the scheme of public URLs follow the
+// X-Forwarded-Proto header of a TLS-terminating proxy in front of the
+// Ingress. Set by Server.EnableForwardedProto.
+var trustForwardedProto bool
+
+// publicOrigin returns the scheme and host clients reach route on, such as
+// https://api.example.com, read from the route's Ingress: the request host
+// when the Ingress serves it, or else its first named host, preferring those
+// it serves TLS for. A request host the Ingress does not name is never
+// echoed. The scheme is https for the TLS hosts of the Ingress, and for
+// X-Forwarded-Proto: https only when trustForwardedProto is set. It returns
+// "" for routes whose Ingress names no host.
+func publicOrigin(r *http.Request, route *routestore.CompiledRoute) string {
+	host := requestHost(r)
+	if !slices.ContainsFunc(route.Hosts, func(pattern string) bool { return matchHost(pattern, host) }) {
+		host = fallbackHost(route)
+	}
+	if host == "" {
+		return ""
+	}
+	scheme := "http"
+	if slices.ContainsFunc(route.TLSHosts, func(pattern string) bool { return matchHost(pattern, host) }) ||
+		trustForwardedProto && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + strings.ToLower(host)
+}
+
+// fallbackHost returns the first concrete host of the route's Ingress,
+// served with TLS if any is, or "" when it names only wildcards.
+func fallbackHost(route *routestore.CompiledRoute) string {
+	for _, hosts := range [][]string{route.TLSHosts, route.Hosts} {
+		for _, host := range hosts {
+			if host != "" && !strings.HasPrefix(host, "*.") && (len(route.Hosts) == 0 || slices.Contains(route.Hosts, host)) {
+				return host
+			}
+		}
+	}
+	return ""
+}
+
+// resourceURL returns the URL of the requested resource advertised in 402
+// responses: absolute on the route's public origin, or the request URI when
+// the origin is unknown.
+func resourceURL(r *http.Request, route *routestore.CompiledRoute) string {
+	origin := publicOrigin(r, route)
+	if origin == "" {
+		return r.URL.String()
+	}
+	return origin + r.URL.RequestURI()
+}
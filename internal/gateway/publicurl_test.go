@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestResourceURL(t *testing.T) {
+	tls := &routestore.CompiledRoute{
+		Hosts:    []string{"*.example.com", "api.example.com", "plain.test"},
+		TLSHosts: []string{"*.example.com", "api.example.com"},
+	}
+	tests := []struct {
+		name      string
+		route     *routestore.CompiledRoute
+		host      string
+		forwarded string
+		trusted   bool // trustForwardedProto
+		want      string
+	}{
+		{name: "TLS host", route: tls, host: "api.example.com", want: "https://api.example.com/api/data?id=1"},
+		{name: "wildcard TLS host", route: tls, host: "Data.Example.com:443", want: "https://data.example.com/api/data?id=1"},
+		{name: "host without TLS", route: tls, host: "plain.test", want: "http://plain.test/api/data?id=1"},
+		{name: "TLS terminated in front of the Ingress", route: tls, host: "plain.test", forwarded: "https", trusted: true, want: "https://plain.test/api/data?id=1"},
+		{name: "untrusted X-Forwarded-Proto", route: tls, host: "plain.test", forwarded: "https", want: "http://plain.test/api/data?id=1"},
+		{name: "unknown host falls back to a TLS host", route: tls, host: "gateway.x402-system.svc", want: "https://api.example.com/api/data?id=1"},
+		{
+			name:  "unknown host falls back to a named host",
+			route: &routestore.CompiledRoute{Hosts: []string{"plain.test"}, TLSHosts: []string{"other.test"}},
+			host:  "10.0.0.1:8402",
+			want:  "http://plain.test/api/data?id=1",
+		},
+		{
+			name:  "catch-all Ingress with TLS",
+			route: &routestore.CompiledRoute{TLSHosts: []string{"api.example.com"}},
+			host:  "anything.test",
+			want:  "https://api.example.com/api/data?id=1",
+		},
+		{name: "no named host", route: &routestore.CompiledRoute{}, host: "anything.test", want: "/api/data?id=1"},
+		{name: "wildcards only", route: &routestore.CompiledRoute{Hosts: []string{"*.example.com"}}, host: "other.test", want: "/api/data?id=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/data?id=1", nil)
+			r.Host = tt.host
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.forwarded)
+			}
+			trustForwardedProto = tt.trusted
+			defer func() { trustForwardedProto = false }()
+			if got := resourceURL(r, tt.route); got != tt.want {
+				t.Errorf("resourceURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package gateway
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+// ReputationPath serves the payer reputation scores. It is mounted on the
+// metrics server, never on the public gateway port.
+const ReputationPath = "/debug/x402/reputation"
+
+// Payer reputation events and how much each raises a payer's score.
+const (
+	reputationInvalid   = "invalid"   // the facilitator rejected the payment
+	reputationReplay    = "replay"    // the payment reused a spent authorization
+	reputationUnsettled = "unsettled" // the payment verified but failed to settle
+)
+
+var reputationWeights = map[string]float64{
+	reputationInvalid:   1,
+	reputationReplay:    3,
+	reputationUnsettled: 5,
+}
+
+// minReputationScore is the score below which a payer is forgotten.
+const minReputationScore = 0.01
+
+// reputationPrefix starts the state store keys of payer scores, followed by
+// the lowercased payer address.
+const reputationPrefix = "reputation:"
+
+// Reputation scores payers by their failed payments. Scores halve every
+// half-life, so a payer who stops failing recovers on its own. Scores are
+// kept in a storage.Interface: with a shared store every replica sees the
+// failures seen by the others.
+type Reputation struct {
+	halfLife  time.Duration
+	addresses func(string) string // rewrites addresses when listed; nil keeps them
+	store     storage.Interface
+	now       func() time.Time
+
+	mu sync.Mutex // serializes the updates of this replica
+}
+
+// payerReputation is the score of one payer as of Updated, as kept in the
+// store.
+type payerReputation struct {
+	Score   float64        `json:"score"`
+	Updated time.Time      `json:"updated"`
+	Events  map[string]int `json:"events"`
+}
+
+// NewReputation returns a store whose scores halve every halfLife, kept in
+// store. addresses, if non-nil, rewrites the payer addresses it lists, like
+// those in logs.
+func NewReputation(halfLife time.Duration, addresses func(string) string, store storage.Interface) *Reputation {
+	return &Reputation{halfLife: halfLife, addresses: addresses, store: store, now: time.Now}
+}
+
+// decayed returns the score of p at now, rounded to two decimals so a
+// threshold is reached by the failures that add up to it.
+func (r *Reputation) decayed(p payerReputation, now time.Time) float64 {
+	score := p.Score * math.Exp2(-now.Sub(p.Updated).Seconds()/r.halfLife.Seconds())
+	return math.Round(score*100) / 100
+}
+
+// load returns the stored reputation of the payer under key.
+func (r *Reputation) load(ctx context.Context, key string) (payerReputation, error) {
+	var p payerReputation
+	raw, err := r.store.Get(ctx, key)
+	if err != nil {
+		return p, err
+	}
+	return p, json.Unmarshal(raw, &p)
+}
+
+// record raises the score of payer for event. It does nothing on a nil
+// store or an unknown payer. Updates are read-modify-write: failures of one
+// payer recorded at once on two replicas may count once.
+func (r *Reputation) record(ctx context.Context, payer, event string) {
+	if r == nil || payer == "" {
+		return
+	}
+	metrics.PayerReputationEventsTotal.WithLabelValues(event).Inc()
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+	key := reputationPrefix + strings.ToLower(payer)
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, err := r.load(ctx, key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		slog.Warn("payer reputation unavailable", "payer", payer, "error", err)
+		return
+	}
+	if err != nil || p.Events == nil {
+		p = payerReputation{Events: map[string]int{}}
+	}
+	p.Score = r.decayed(p, now) + reputationWeights[event]
+	p.Updated = now
+	p.Events[event]++
+	raw, _ := json.Marshal(p)
+	// The payer is forgotten once its score has decayed away.
+	ttl := time.Duration(math.Log2(p.Score/minReputationScore) * float64(r.halfLife))
+	if err := r.store.Set(ctx, key, raw, ttl); err != nil {
+		slog.Warn("payer reputation not stored", "payer", payer, "error", err)
+	}
+}
+
+// score returns the current score of payer, 0 for a nil store or when the
+// store is unavailable.
+func (r *Reputation) score(ctx context.Context, payer string) float64 {
+	if r == nil || payer == "" {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	p, err := r.load(ctx, reputationPrefix+strings.ToLower(payer))
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			slog.Warn("payer reputation unavailable", "payer", payer, "error", err)
+		}
+		return 0
+	}
+	return r.decayed(p, r.now())
+}
+
+// recordFailure records a failed verification or settlement of payer. Errors
+// of the facilitator itself, such as timeouts, are not the payer's doing.
+func (r *Reputation) recordFailure(ctx context.Context, payer string, err error, settling bool) {
+	var facErr *facilitatorError
+	if errors.As(err, &facErr) {
+		return
+	}
+	reason := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(reason, "nonce"), strings.Contains(reason, "replay"):
+		r.record(ctx, payer, reputationReplay)
+	case settling:
+		r.record(ctx, payer, reputationUnsettled)
+	default:
+		r.record(ctx, payer, reputationInvalid)
+	}
+}
+
+// listedPayer is one payer in the reputation listing.
+type listedPayer struct {
+	Payer   string         `json:"payer"`
+	Score   float64        `json:"score"`
+	Updated time.Time      `json:"updated"`
+	Events  map[string]int `json:"events"`
+}
+
+// ServeHTTP lists the tracked payers by score, highest first, on GET, and
+// clears the score of the payer query parameter, or of every payer, on
+// DELETE.
+func (r *Reputation) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		payer := req.URL.Query().Get("payer")
+		var err error
+		if payer == "" {
+			err = r.clear(req.Context())
+		} else {
+			err = r.store.Delete(req.Context(), reputationPrefix+strings.ToLower(payer))
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		slog.Info("payer reputation cleared", "payer", payer)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stored, err := r.store.Scan(req.Context(), reputationPrefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	now := r.now()
+	payers := make([]listedPayer, 0, len(stored))
+	for key, raw := range stored {
+		var p payerReputation
+		if json.Unmarshal(raw, &p) != nil {
+			continue
+		}
+		score := r.decayed(p, now)
+		if score < minReputationScore {
+			continue
+		}
+		payer := strings.TrimPrefix(key, reputationPrefix)
+		if r.addresses != nil {
+			payer = r.addresses(payer)
+		}
+		payers = append(payers, listedPayer{Payer: payer, Score: score, Updated: p.Updated.UTC(), Events: p.Events})
+	}
+	slices.SortFunc(payers, func(a, b listedPayer) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"payers": payers})
+}
+
+// clear deletes the score of every payer.
+func (r *Reputation) clear(ctx context.Context) error {
+	stored, err := r.store.Scan(ctx, reputationPrefix)
+	if err != nil {
+		return err
+	}
+	for key := range stored {
+		if err := r.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paymentPayer returns the payer of a payment header as the payload states it
+// (the authorization's from address of EVM payments), or "".
+func paymentPayer(paymentHeader string) string {
+	payloadBytes, err := base64.StdEncoding.DecodeString(paymentHeader)
+	if err != nil {
+		return ""
+	}
+	var payment struct {
+		Payload struct {
+			Authorization struct {
+				From string `json:"from"`
+			} `json:"authorization"`
+		} `json:"payload"`
+	}
+	if json.Unmarshal(payloadBytes, &payment) != nil {
+		return ""
+	}
+	return payment.Payload.Authorization.From
+}
+
+// admitPayer applies the route's reputation thresholds to the payer of req:
+// payers over the deny score are answered 403, and payers over the graylist
+// score are marked strict. It reports whether the request may go on.
+func (h *Handler) admitPayer(req *request) bool {
+	policy, route := req.route.Reputation, req.route
+	if policy == nil || req.payer == "" || req.probe {
+		return true
+	}
+	score := h.reputation.score(req.r.Context(), req.payer)
+	switch {
+	case policy.DenyScore > 0 && score >= policy.DenyScore:
+		slog.Info("payer denied by reputation", "path", req.path, "route", route.Name, "payer", req.payer, "score", score)
+		metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), route.Namespace, route.Name, "payer_denied").Inc()
+		http.Error(req.w, "payer denied: too many failed payments", http.StatusForbidden)
+		return false
+	case policy.GraylistScore > 0 && score >= policy.GraylistScore:
+		slog.Info("payer graylisted by reputation, settling first", "path", req.path, "route", route.Name, "payer", req.payer, "score", score)
+		metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), route.Namespace, route.Name, "payer_graylisted").Inc()
+		req.strict = true
+	}
+	return true
+}
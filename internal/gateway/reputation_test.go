@@ -0,0 +1,181 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+func TestReputationScores(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	rep := NewReputation(time.Hour, nil, storage.NewMemory())
+	rep.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	rep.recordFailure(ctx, "0xABC", errors.New("payment invalid: invalid_signature"), false)
+	rep.recordFailure(ctx, "0xabc", errors.New("payment invalid: insufficient_funds"), false)
+	if got := rep.score(ctx, "0xAbC"); got != 2 {
+		t.Fatalf("score = %v, want 2", got)
+	}
+
+	// Scores halve every half-life.
+	now = now.Add(time.Hour)
+	if got := rep.score(ctx, "0xabc"); got != 1 {
+		t.Fatalf("score after a half-life = %v, want 1", got)
+	}
+
+	rep.recordFailure(ctx, "0xabc", errors.New("settlement failed: nonce already used"), true)
+	rep.recordFailure(ctx, "0xabc", errors.New("settlement failed: transfer reverted"), true)
+	if got := rep.score(ctx, "0xabc"); got != 9 {
+		t.Fatalf("score after replay and unsettled = %v, want 9", got)
+	}
+
+	// Facilitator outages and unknown payers are not scored.
+	rep.recordFailure(ctx, "0xdef", &facilitatorError{err: errors.New("timeout")}, false)
+	rep.recordFailure(ctx, "", errors.New("payment invalid"), false)
+	if got := rep.score(ctx, "0xdef"); got != 0 {
+		t.Errorf("score after an outage = %v, want 0", got)
+	}
+
+	// Payers are forgotten once their score has decayed away: 9 halves to
+	// 0.01 in about ten half-lives.
+	if ttl, err := rep.store.TTL(ctx, reputationPrefix+"0xabc"); err != nil || ttl < 9*time.Hour || ttl > 10*time.Hour {
+		t.Errorf("score TTL = %v, %v, want about 10h", ttl, err)
+	}
+
+	var nilRep *Reputation
+	nilRep.recordFailure(ctx, "0xabc", errors.New("payment invalid"), false)
+	if got := nilRep.score(ctx, "0xabc"); got != 0 {
+		t.Errorf("nil store score = %v, want 0", got)
+	}
+}
+
+func TestReputationServeHTTP(t *testing.T) {
+	store := storage.NewMemory()
+	rep := NewReputation(time.Hour, func(s string) string { return strings.ReplaceAll(s, "0x", "0x…") }, store)
+	ctx := context.Background()
+	rep.record(ctx, "0xaaa", reputationInvalid)
+	rep.record(ctx, "0xbbb", reputationUnsettled)
+	// Another replica sharing the store scores the same payers.
+	NewReputation(time.Hour, nil, store).record(ctx, "0xccc", reputationReplay)
+
+	list := func() []listedPayer {
+		t.Helper()
+		w := httptest.NewRecorder()
+		rep.ServeHTTP(w, httptest.NewRequest("GET", ReputationPath, nil))
+		var body struct {
+			Payers []listedPayer `json:"payers"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal listing: %v", err)
+		}
+		return body.Payers
+	}
+
+	payers := list()
+	if len(payers) != 3 || payers[0].Payer != "0x…bbb" || payers[2].Payer != "0x…aaa" || payers[0].Events[reputationUnsettled] != 1 {
+		t.Fatalf("payers = %+v, want bbb, ccc, aaa with rewritten addresses", payers)
+	}
+
+	w := httptest.NewRecorder()
+	rep.ServeHTTP(w, httptest.NewRequest("DELETE", ReputationPath+"?payer=0xBBB", nil))
+	if w.Code != http.StatusNoContent || len(list()) != 2 {
+		t.Errorf("DELETE one: status %d, %d payers left, want 204 and 2", w.Code, len(list()))
+	}
+	rep.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", ReputationPath, nil))
+	if n := len(list()); n != 0 {
+		t.Errorf("payers after DELETE all = %d, want 0", n)
+	}
+
+	w = httptest.NewRecorder()
+	rep.ServeHTTP(w, httptest.NewRequest("POST", ReputationPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", w.Code)
+	}
+}
+
+func TestHandlerPayerReputation(t *testing.T) {
+	var settled atomic.Bool
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if settled.Load() {
+			io.WriteString(w, "settled first")
+			return
+		}
+		io.WriteString(w, "served first")
+	}))
+	defer backendSrv.Close()
+	var valid atomic.Bool
+	var verifies atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			verifies.Add(1)
+			if !valid.Load() {
+				io.WriteString(w, `{"isValid":false,"invalidReason":"insufficient_funds","payer":"0xBad"}`)
+				return
+			}
+			io.WriteString(w, `{"isValid":true,"payer":"0xBad"}`)
+			return
+		}
+		settled.Store(true)
+		io.WriteString(w, `{"success":true,"payer":"0xBad","transaction":"0xabc"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "my-api", &routestore.CompiledRoute{
+		Name:           "my-api",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Reputation:     &routestore.CompiledReputation{GraylistScore: 2, DenyScore: 3},
+		Rules:          []routestore.CompiledRule{{Path: "/api/*", Price: "0.001", Mode: "all-pay", Settle: settleAfterResponse}},
+		Backends:       []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	})
+	h := NewHandler(store)
+	h.reputation = NewReputation(time.Hour, nil, storage.NewMemory())
+
+	pay := func() *httptest.ResponseRecorder {
+		payload := `{"x402Version":2,"payload":{"authorization":{"from":"0xbad"}}}`
+		req := httptest.NewRequest("GET", "/api/data", nil)
+		req.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(payload)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// Two rejected payments graylist the payer: it settles before the
+	// backend is called, although the rule settles after the response.
+	pay()
+	pay()
+	valid.Store(true)
+	if w := pay(); w.Code != http.StatusOK || w.Body.String() != "settled first" {
+		t.Fatalf("graylisted payer: status %d, body %q, want 200 settled first", w.Code, w.Body.String())
+	}
+
+	// A third rejection denies the payer without asking the facilitator.
+	valid.Store(false)
+	pay()
+	if got := h.reputation.score(context.Background(), "0xBAD"); math.Abs(got-3) > 0.01 {
+		t.Fatalf("score = %v, want 3", got)
+	}
+	before := verifies.Load()
+	if w := pay(); w.Code != http.StatusForbidden {
+		t.Fatalf("denied payer status = %d, want 403", w.Code)
+	}
+	if verifies.Load() != before {
+		t.Error("denied payment was sent to the facilitator")
+	}
+}
@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// circleFaucet hands out test USDC on every supported test network.
+const circleFaucet = "https://faucet.circle.com/"
+
+// sandboxFaucets maps test networks to a faucet for their payment asset.
+var sandboxFaucets = map[string]string{
+	"eip155:84532": circleFaucet,
+	"eip155:43113": circleFaucet,
+	"solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1": circleFaucet,
+}
+
+// faucetURL returns the faucet advertised in the 402 responses of a sandbox
+// route, or "" for other routes.
+func faucetURL(route *routestore.CompiledRoute, chainID string) string {
+	if !route.Sandbox {
+		return ""
+	}
+	return sandboxFaucets[chainID]
+}
+
+// sandboxTokenPrice rounds a token price up to the token's precision, so
+// sandbox prices below one unit cost one unit instead of failing. Fiat and
+// invalid prices are returned unchanged.
+func sandboxTokenPrice(price string, decimals int) string {
+	amount, ok := new(big.Rat).SetString(price)
+	if !ok || isFiatPrice(price) {
+		return price
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	amount.Mul(amount, new(big.Rat).SetInt(scale))
+	units, rem := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+	if rem.Sign() > 0 {
+		units.Add(units, big.NewInt(1))
+	}
+	return new(big.Rat).SetFrac(units, scale).FloatString(decimals)
+}
+
+// sandboxLabel is the sandbox label of a route's payment metrics.
+func sandboxLabel(route *routestore.CompiledRoute) string {
+	return strconv.FormatBool(route.Sandbox)
+}
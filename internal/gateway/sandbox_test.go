@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestSandboxPaymentRequirements(t *testing.T) {
+	tests := []struct {
+		name       string
+		sandbox    bool
+		price      string
+		wantAmount string
+		wantFaucet string
+		wantErr    bool
+	}{
+		{name: "sandbox", sandbox: true, price: "0.01", wantAmount: "10000", wantFaucet: circleFaucet},
+		{name: "sandbox below one unit", sandbox: true, price: "0.0000001", wantAmount: "1", wantFaucet: circleFaucet},
+		{name: "production", price: "0.01", wantAmount: "10000"},
+		{name: "production below one unit", price: "0.0000001", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &routestore.CompiledRoute{Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", Sandbox: tt.sandbox}
+			rule := &routestore.CompiledRule{Path: "/api/*", Price: tt.price}
+			reqs, err := buildPaymentRequirements(httptest.NewRequest("GET", "/api/data", nil), route, rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildPaymentRequirements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			accept := reqs.Accepts[0]
+			if accept.Amount != tt.wantAmount || accept.Extra.Faucet != tt.wantFaucet {
+				t.Errorf("amount = %s, faucet = %q, want %s, %q", accept.Amount, accept.Extra.Faucet, tt.wantAmount, tt.wantFaucet)
+			}
+		})
+	}
+}
+
+func TestSandboxTokenPrice(t *testing.T) {
+	tests := []struct {
+		price string
+		want  string
+	}{
+		{"0.01", "0.010000"},
+		{"0.0000001", "0.000001"},
+		{"0.0000011", "0.000002"},
+		{"0", "0.000000"},
+		{"5 USD", "5 USD"},
+		{"abc", "abc"},
+	}
+	for _, tt := range tests {
+		if got := sandboxTokenPrice(tt.price, 6); got != tt.want {
+			t.Errorf("sandboxTokenPrice(%q) = %q, want %q", tt.price, got, tt.want)
+		}
+	}
+}
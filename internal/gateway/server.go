@@ -2,53 +2,101 @@ package gateway
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"k8s.io/client-go/tools/events"
+
 	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/tokenmeta"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
 )
 
 // Server is the gateway HTTP server that implements manager.Runnable.
 type Server struct {
-	addr    string
-	handler *Handler
-	srv     *http.Server
+	addrs        []string
+	listenerOpts ListenerOptions
+	connLimiter  *ipConnLimiter
+	handler      *Handler
+	srv          *http.Server
+	ready        atomic.Bool
 }
 
-// NewServer creates a new gateway server.
-func NewServer(addr string, store *routestore.Store) *Server {
+// NewServer creates a new gateway server listening on addr, a comma-separated
+// list of addresses such as "10.0.0.5:8402,[fd00::5]:8402" whose hosts may name a
+// network interface (see ResolveBindAddress). The recorder, if non-nil,
+// receives Warning events for requests served without payment during
+// facilitator outages.
+func NewServer(addr string, store *routestore.Store, recorder events.EventRecorder) (*Server, error) {
+	addrs, err := resolveBindAddresses(addr)
+	if err != nil {
+		return nil, err
+	}
 	handler := NewHandler(store)
+	handler.failOpen.recorder = recorder
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
+	mux.HandleFunc("GET "+backend.JWKSPath, handler.serveJWKS)
+	mux.HandleFunc("GET "+JobsPath+"{id}", handler.jobs.serveJob)
+	mux.HandleFunc("GET "+StatusPath, handler.serveStatus)
+	mux.HandleFunc("GET "+VersionPath, handler.serveVersion)
+	mux.HandleFunc("GET "+PricesPath, handler.servePrices)
+	mux.HandleFunc("GET "+AnalyticsPath, handler.serveAnalytics)
 	mux.Handle("/", handler)
 
-	return &Server{
-		addr:    addr,
+	s := &Server{
+		addrs:   addrs,
 		handler: handler,
-		srv: &http.Server{
-			Addr:         addr,
-			Handler:      mux,
-			ReadTimeout:  15 * time.Second,
-			WriteTimeout: 30 * time.Second,
-			IdleTimeout:  60 * time.Second,
-		},
+		srv:     &http.Server{Handler: mux},
 	}
+	s.SetConnLimits(DefaultConnLimits)
+	return s, nil
 }
 
 // Start implements manager.Runnable. It starts the HTTP server and blocks until
 // the context is cancelled, then gracefully shuts down.
 func (s *Server) Start(ctx context.Context) error {
-	slog.Info("starting x402 gateway", "addr", s.addr)
+	slog.Info("starting x402 gateway", "addr", strings.Join(s.addrs, ","))
+
+	var listeners []net.Listener
+	for _, addr := range s.addrs {
+		lns, err := s.listenerOpts.listen(ctx, addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("gateway server failed: %w", err)
+		}
+		listeners = append(listeners, lns...)
+	}
+	if s.connLimiter != nil {
+		for i, ln := range listeners {
+			listeners[i] = &connLimitListener{Listener: ln, limiter: s.connLimiter}
+		}
+	}
+	s.ready.Store(true)
+	go paymentRequiredResponses.follow(ctx, s.handler.store)
+	if s.handler.analytics != nil {
+		go s.handler.analytics.run(ctx)
+	}
 
 	// Shut down gracefully when context is cancelled.
 	go func() {
 		<-ctx.Done()
+		s.ready.Store(false)
 		slog.Info("shutting down gateway server")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
@@ -57,9 +105,162 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
-	if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("gateway server failed: %w", err)
+	serve := s.srv.Serve
+	if s.srv.TLSConfig != nil {
+		serve = func(ln net.Listener) error { return s.srv.ServeTLS(ln, "", "") }
+	}
+	// Every listener is served until the server shuts down; the first to
+	// fail stops the others.
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() { errs <- serve(ln) }()
+	}
+	var serveErr error
+	for range listeners {
+		if err := <-errs; err != nil && err != http.ErrServerClosed && serveErr == nil {
+			serveErr = err
+			s.ready.Store(false)
+			s.srv.Close()
+		}
+	}
+	if serveErr != nil {
+		return fmt.Errorf("gateway server failed: %w", serveErr)
 	}
 	slog.Info("gateway server stopped")
 	return nil
 }
+
+// SetListenerOptions tunes the gateway's listening sockets. Call before
+// Start.
+func (s *Server) SetListenerOptions(opts ListenerOptions) {
+	s.listenerOpts = opts
+}
+
+// SetConnLimits replaces the gateway's per-client connection cap and request
+// timeouts, DefaultConnLimits by default. Call before Start.
+func (s *Server) SetConnLimits(limits ConnLimits) {
+	s.srv.ReadHeaderTimeout = limits.ReadHeaderTimeout
+	s.srv.ReadTimeout = limits.ReadTimeout
+	s.srv.WriteTimeout = limits.WriteTimeout
+	s.srv.IdleTimeout = limits.IdleTimeout
+	s.connLimiter = nil
+	if limits.MaxPerIP > 0 {
+		s.connLimiter = newIPConnLimiter(limits.MaxPerIP, limits.Exempt)
+	}
+}
+
+// ReadyCheck is a healthz.Checker that passes only while the gateway is
+// accepting connections, so the pod leaves the Service endpoints before the
+// gateway stops serving.
+func (s *Server) ReadyCheck(_ *http.Request) error {
+	if !s.ready.Load() {
+		return errors.New("gateway is not serving")
+	}
+	return nil
+}
+
+// EnableContextSigning makes the gateway attach a signed X-402-Context header
+// to paid requests forwarded to backends. Call before Start.
+func (s *Server) EnableContextSigning(keys *backend.KeySet) {
+	s.handler.contextKeys = keys
+}
+
+// EnableTrustedUpstream makes the gateway accept payment verifications
+// attested in the X-402-Verified header by an upstream tier signing with a
+// key in keys, for the given audience, and skip verifying those payments
+// with the facilitator. Call before Start.
+func (s *Server) EnableTrustedUpstream(keys *backend.KeySet, audience string) {
+	s.handler.upstream = &trustedUpstream{keys: keys, audience: audience}
+}
+
+// EnableExchangeRates lets routes price paths in fiat (e.g. "$0.01 USD"),
+// converted to token amounts with rates from the provider. Call before Start.
+func (s *Server) EnableExchangeRates(rates *ExchangeRates) {
+	exchangeRates = rates
+}
+
+// EnableSecrets lets routes read facilitator credentials from Vault. Call
+// before Start.
+func (s *Server) EnableSecrets(secrets *vault.Secrets) {
+	facilitatorSecrets = secrets
+}
+
+// AddSettlementSink registers a sink that receives a record of every settled
+// payment. Call before Start.
+func (s *Server) AddSettlementSink(sink SettlementSink) {
+	s.handler.settlementSinks = append(s.handler.settlementSinks, sink)
+}
+
+// AddFacilitatorHook registers a hook that observes every /verify and
+// /settle call to a facilitator, such as for request logging. Call before
+// Start.
+func (s *Server) AddFacilitatorHook(hook FacilitatorHook) {
+	facilitatorHooks = append(facilitatorHooks, hook)
+}
+
+// EnableVerificationCapture makes the gateway keep failed payment
+// verifications in capture, for an operator to inspect. Call before Start.
+func (s *Server) EnableVerificationCapture(capture *VerificationCapture) {
+	s.handler.verificationCapture = capture
+}
+
+// EnableReputation makes the gateway score payers by their failed payments
+// and apply the reputation thresholds of routes. Call before Start.
+func (s *Server) EnableReputation(reputation *Reputation) {
+	s.handler.reputation = reputation
+}
+
+// EnableTLS serves the gateway over TLS with the certificates of
+// getCertificate, such as a certwatcher reloading a mounted Secret. Clients
+// are asked for a certificate, which is not verified; it identifies them for
+// spec.payment.bindClient. Call before Start.
+func (s *Server) EnableTLS(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) {
+	s.srv.TLSConfig = &tls.Config{
+		GetCertificate: getCertificate,
+		ClientAuth:     tls.RequestClientCert,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// EnableClientCertHeader trusts the client certificate a TLS-terminating
+// proxy forwards in header, as a URL-encoded PEM certificate or a hex SHA-256
+// fingerprint, for spec.payment.bindClient. The proxy must overwrite the
+// header on every request. Call before Start.
+func (s *Server) EnableClientCertHeader(header string) {
+	clientCertHeader = header
+}
+
+// EnableForwardedProto trusts the X-Forwarded-Proto header of a
+// TLS-terminating proxy for the scheme of the resource URLs in 402
+// responses. The proxy must overwrite the header on every request. Call
+// before Start.
+func (s *Server) EnableForwardedProto() {
+	trustForwardedProto = true
+}
+
+// SetQueueTokenKey signs waiting room queue tokens with key, so every gateway
+// replica sharing it honors them. Without it each replica signs with a random
+// key of its own. Call before Start.
+func (s *Server) SetQueueTokenKey(key []byte) {
+	s.handler.waitingRoom.key = key
+}
+
+// SetStateStore keeps idempotent responses, async jobs and waiting room
+// tickets in store, so every gateway replica sharing it sees them. Without it
+// each replica keeps its own in memory. Call before Start.
+func (s *Server) SetStateStore(store storage.Interface) {
+	s.handler.setState(store)
+}
+
+// EnableAnalytics makes the gateway aggregate 402 responses and settlements
+// in analytics and serve them at /x402/analytics. Call before Start.
+func (s *Server) EnableAnalytics(analytics *Analytics) {
+	s.handler.analytics = analytics
+	s.AddSettlementSink(analytics)
+}
+
+// EnableTokenMetadata lets routes accept assets outside the built-in registry,
+// with decimals, name and version read from the chain. Call before Start.
+func (s *Server) EnableTokenMetadata(resolver *tokenmeta.Resolver) {
+	tokenMetadata = resolver
+}
@@ -0,0 +1,260 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/privacy"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// Settle modes of a rule.
+const (
+	settleSync          = "sync"
+	settleAsync         = "async"
+	settleAfterResponse = "afterResponse"
+)
+
+// maxSettleAfterResponseBytes bounds the response of an afterResponse rule
+// held until its payment is settled. Metered rules set their own bound.
+const maxSettleAfterResponseBytes = 10 << 20
+
+// settleStage settles the verified payment as the rule's settle mode says:
+// before passing the request on, alongside it, or once the backend has
+// answered. Metered rules always settle after the response, and graylisted
+// payers otherwise always settle first. Conditional requests to rules that
+// discount 304 answers settle after the response, once the status is known.
+func (h *Handler) settleStage(req *request, next func()) {
+	switch {
+	case req.accept.Scheme == schemeUpto:
+		h.settleAfterResponse(req, next)
+	case req.strict:
+		h.settleSync(req, next)
+	case req.rule.NotModified != nil && conditional(req.r):
+		h.settleAfterResponse(req, next)
+	case req.rule.Settle == settleAfterResponse:
+		h.settleAfterResponse(req, next)
+	case req.rule.Settle == settleAsync:
+		h.settleAsync(req, next)
+	default:
+		h.settleSync(req, next)
+	}
+}
+
+// settleSync settles the payment and only then passes the request on.
+func (h *Handler) settleSync(req *request, next func()) {
+	route, rule, path := req.route, req.rule, req.path
+	if abandoned(req, settleSync) {
+		return
+	}
+	settled, err := newFacilitatorClient(route).Settle(settleContext(req), req.verified, req.payment, req.accept)
+	if route.Callbacks {
+		h.notifySettlement(req.r, route, req.paymentHeader, settled, err)
+	}
+	if err != nil {
+		h.settlementFailed(req, err)
+		metrics.SettlementsTotal.WithLabelValues(route.Namespace, route.Name, settleSync, "failed", sandboxLabel(route)).Inc()
+		h.paymentFailed(req.w, req.r, route, rule, path, err, req.start)
+		return
+	}
+	req.settled = settled
+
+	offerName := paidOffer(req)
+	h.acceptSettlement(req, settleSync, offerName, req.accept.Amount, settled, prepareMirror(req.r, route))
+	slog.Info("payment verified and settled, forwarding", "path", path, "route", route.Name)
+
+	// Set PAYMENT-RESPONSE header as Base64-encoded settle response JSON.
+	setPaymentResponse(req.w, settled)
+	h.signPaidContext(req, backend.SettlementSettled, settled)
+	next()
+}
+
+// settleAsync passes the request on at once and settles in the background,
+// for rules where latency matters more than serving the odd payment that
+// fails to settle. No PAYMENT-RESPONSE header is sent, the payment context
+// carries no transaction, and the settlement is outside the payment latency
+// budget.
+func (h *Handler) settleAsync(req *request, next func()) {
+	route, path := req.route, req.path
+	offerName := paidOffer(req)
+	h.signPaidContext(req, backend.SettlementAuthorized, &settleResponse{Payer: req.verified.Payer})
+
+	mirror := prepareMirror(req.r, route)
+	// The settlement outlives the request.
+	r := req.r.Clone(context.Background())
+	go func() {
+		settled, err := newFacilitatorClient(route).Settle(r.Context(), req.verified, req.payment, req.accept)
+		if route.Callbacks {
+			h.notifySettlement(r, route, req.paymentHeader, settled, err)
+		}
+		if err != nil {
+			slog.Error("background settlement failed", "path", path, "route", route.Name, "error", err)
+			h.settlementFailed(req, err)
+			metrics.SettlementsTotal.WithLabelValues(route.Namespace, route.Name, settleAsync, "failed", sandboxLabel(route)).Inc()
+			metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, path), route.Namespace, route.Name, "settlement_failed").Inc()
+			mirror.cancel()
+			return
+		}
+		h.acceptSettlement(req, settleAsync, offerName, req.accept.Amount, settled, mirror)
+	}()
+
+	slog.Info("payment verified, forwarding while settling", "path", path, "route", route.Name)
+	next()
+}
+
+// settleAfterResponse passes the request on into a buffer and settles once
+// the backend has answered, releasing the response only then. Failed
+// responses are not charged, metered rules are charged by the response, and
+// 304 answers to conditional requests as the rule's conditionalRequests say.
+func (h *Handler) settleAfterResponse(req *request, next func()) {
+	w, r, route, rule, path, accept := req.w, req.r, req.route, req.rule, req.path, req.accept
+	offerName := paidOffer(req)
+	h.signPaidContext(req, backend.SettlementAuthorized, &settleResponse{Payer: req.verified.Payer})
+
+	// The backend consumes the body, so the mirror copy is taken first and
+	// only sent once the charge settles.
+	mirror := prepareMirror(r, route)
+	limit, tooLarge := int64(maxSettleAfterResponseBytes), "response_too_large"
+	if rule.Metering != nil {
+		limit, tooLarge = rule.Metering.MaxResponseBytes, "metering_error"
+	}
+	buf := newResponseBuffer(limit)
+	req.w = buf
+	next()
+	req.w = w
+	if buf.overflow {
+		slog.Error("response too large to hold for settlement, not charging", "path", path, "route", route.Name, "limit", limit)
+		metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, tooLarge).Inc()
+		http.Error(w, errResponseTooLarge.Error(), http.StatusBadGateway)
+		mirror.cancel()
+		return
+	}
+
+	charged := *accept
+	switch {
+	case buf.status == http.StatusNotModified && rule.NotModified != nil && conditional(r):
+		charged.Amount = notModifiedAmount(route, rule.NotModified, accept.Amount, req.reqs.decimals)
+	case rule.Metering != nil:
+		charged.Amount = meteredAmount(buf.status, buf.header, rule.Metering, accept.Amount, req.reqs.decimals)
+		charged.Amount = capAmount(chargeAmount(route, charged.Amount, req.reqs.decimals), accept.Amount)
+	case buf.status >= http.StatusInternalServerError:
+		charged.Amount = "0"
+	}
+	settled := &settleResponse{Success: true, Payer: req.verified.Payer, Network: accept.Network}
+	if charged.Amount != "0" && abandoned(req, settleAfterResponse) {
+		mirror.cancel()
+		return
+	}
+	var err error
+	if charged.Amount != "0" {
+		settled, err = newFacilitatorClient(route).Settle(settleContext(req), req.verified, req.payment, &charged)
+	}
+	if route.Callbacks {
+		h.notifySettlement(r, route, req.paymentHeader, settled, err)
+	}
+	if err != nil {
+		slog.Error("settlement after response failed", "path", path, "route", route.Name, "error", err)
+		h.settlementFailed(req, err)
+		metrics.SettlementsTotal.WithLabelValues(route.Namespace, route.Name, settleAfterResponse, "failed", sandboxLabel(route)).Inc()
+		mirror.cancel()
+		metrics.RequestsTotal.WithLabelValues(pathLabel(rule, path), route.Namespace, route.Name, "verification_error").Inc()
+		writePaymentRequired(w, r, route, rule)
+		return
+	}
+	if rule.Metering != nil {
+		settled.Amount = charged.Amount
+	}
+	req.settled = settled
+	h.acceptSettlement(req, settleAfterResponse, offerName, charged.Amount, settled, mirror)
+
+	slog.Info("payment settled, forwarding response", "path", path, "route", route.Name, "amount", charged.Amount)
+	setPaymentResponse(buf, settled)
+	buf.writeTo(w)
+}
+
+// abandoned reports whether the client of req disconnected before its payment
+// was settled, and counts it. Such requests are skipped unless the route
+// settles abandoned requests.
+func abandoned(req *request, mode string) bool {
+	route := req.route
+	if req.r.Context().Err() == nil {
+		return false
+	}
+	if route.SettleAbandoned {
+		metrics.AbandonedRequestsTotal.WithLabelValues(route.Namespace, route.Name, mode, "settled").Inc()
+		return false
+	}
+	slog.Info("client disconnected before settlement, not charging", "path", req.path, "route", route.Name)
+	metrics.AbandonedRequestsTotal.WithLabelValues(route.Namespace, route.Name, mode, "skipped").Inc()
+	metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), route.Namespace, route.Name, "abandoned").Inc()
+	return true
+}
+
+// settlementFailed scores the payer of a verified payment that failed to
+// settle.
+func (h *Handler) settlementFailed(req *request, err error) {
+	if !req.probe {
+		h.reputation.recordFailure(req.r.Context(), req.verified.Payer, err, true)
+	}
+}
+
+// settleContext is the context of the settlement of req. Routes that settle
+// abandoned requests detach it from the client, so a disconnect does not
+// cancel the settlement in flight.
+func settleContext(req *request) context.Context {
+	if req.route.SettleAbandoned {
+		return context.WithoutCancel(req.r.Context())
+	}
+	return req.r.Context()
+}
+
+// paidOffer applies the offer the request paid for and returns its name, or
+// "" for the rule price.
+func paidOffer(req *request) string {
+	if len(req.rule.Offers) == 0 {
+		return ""
+	}
+	offer := &req.rule.Offers[req.accepted]
+	applyOffer(req.r, offer)
+	return offer.Name
+}
+
+// acceptSettlement records a settled payment with the settlement sinks and
+// metrics, and sends the mirror copy of the request. Probe payments are only
+// counted as probe requests.
+func (h *Handler) acceptSettlement(req *request, mode, offerName, amount string, settled *settleResponse, mirror *mirrorRequest) {
+	route, path := req.route, req.path
+	if req.probe {
+		metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, path), route.Namespace, route.Name, "probe").Inc()
+		return
+	}
+	h.recordSettlement(route, req.rule, path, offerName, req.variant, req.accept, amount, req.reqs.decimals, settled)
+	mirror.send()
+
+	result := "settled"
+	if amount == "0" {
+		result = "skipped"
+	}
+	metrics.SettlementsTotal.WithLabelValues(route.Namespace, route.Name, mode, result, sandboxLabel(route)).Inc()
+	metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, path), route.Namespace, route.Name, "payment_accepted").Inc()
+	tokens, ok := tokenAmount(amount, req.reqs.decimals)
+	if ok {
+		metrics.PaymentAmountTotal.WithLabelValues(pathLabel(req.rule, path), privacy.Address(route.Wallet), route.Network, sandboxLabel(route)).Add(tokens)
+	}
+	if result == "settled" {
+		countExperiment(req, "paid", tokens)
+	}
+}
+
+// signPaidContext attaches the signed payment context to the request when
+// context signing is enabled.
+func (h *Handler) signPaidContext(req *request, settlement string, settled *settleResponse) {
+	if h.contextKeys == nil {
+		return
+	}
+	if err := h.signContext(req.r, req.route, req.accept, settlement, req.path, settled); err != nil {
+		slog.Error("failed to sign payment context", "path", req.path, "route", req.route.Name, "error", err)
+	}
+}
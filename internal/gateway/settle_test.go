@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestSettleModes(t *testing.T) {
+	tests := []struct {
+		name          string
+		settle        string
+		backendStatus int
+		settles       bool
+		wantStatus    int
+		wantCalls     string
+		wantResponse  bool // PAYMENT-RESPONSE header sent
+	}{
+		{name: "sync", settle: settleSync, backendStatus: http.StatusOK, settles: true, wantStatus: http.StatusOK, wantCalls: "settle backend", wantResponse: true},
+		{name: "sync settlement failed", settle: settleSync, backendStatus: http.StatusOK, wantStatus: http.StatusPaymentRequired, wantCalls: "settle"},
+		{name: "async", settle: settleAsync, backendStatus: http.StatusOK, settles: true, wantStatus: http.StatusOK, wantCalls: "backend settle"},
+		{name: "async settlement failed", settle: settleAsync, backendStatus: http.StatusOK, wantStatus: http.StatusOK, wantCalls: "backend settle"},
+		{name: "after response", settle: settleAfterResponse, backendStatus: http.StatusOK, settles: true, wantStatus: http.StatusOK, wantCalls: "backend settle", wantResponse: true},
+		{name: "after response settlement failed", settle: settleAfterResponse, backendStatus: http.StatusOK, wantStatus: http.StatusPaymentRequired, wantCalls: "backend settle"},
+		{name: "after failed response", settle: settleAfterResponse, backendStatus: http.StatusInternalServerError, settles: true, wantStatus: http.StatusInternalServerError, wantCalls: "backend", wantResponse: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls []string
+			settled := make(chan struct{}, 1)
+			forwarded := make(chan struct{})
+			record := func(call string) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, call)
+			}
+			backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				record("backend")
+				close(forwarded)
+				w.WriteHeader(tt.backendStatus)
+			}))
+			defer backendSrv.Close()
+			facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/verify") {
+					io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+					return
+				}
+				if tt.settle == settleAsync {
+					// The request is forwarded without waiting for settlement.
+					<-forwarded
+				}
+				record("settle")
+				defer func() { settled <- struct{}{} }()
+				if !tt.settles {
+					io.WriteString(w, `{"success":false,"errorReason":"insufficient_funds"}`)
+					return
+				}
+				io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc"}`)
+			}))
+			defer facilitator.Close()
+
+			store := routestore.New()
+			store.Set("default", "api", &routestore.CompiledRoute{
+				Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+				Rules:    []routestore.CompiledRule{{Path: "/api/*", Price: "0.01", Mode: "all-pay", Settle: tt.settle}},
+				Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+			})
+			h := NewHandler(store)
+
+			r := httptest.NewRequest("GET", "/api/data", nil)
+			r.Header.Set("Payment-Signature", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`)))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if tt.settle == settleAsync {
+				select {
+				case <-settled:
+				case <-time.After(5 * time.Second):
+					t.Fatal("background settlement did not run")
+				}
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			mu.Lock()
+			got := strings.Join(calls, " ")
+			mu.Unlock()
+			if got != tt.wantCalls {
+				t.Errorf("calls = %q, want %q", got, tt.wantCalls)
+			}
+			if sent := w.Header().Get("PAYMENT-RESPONSE") != ""; sent != tt.wantResponse {
+				t.Errorf("PAYMENT-RESPONSE sent = %v, want %v", sent, tt.wantResponse)
+			}
+		})
+	}
+}
+
+func TestAbandoned(t *testing.T) {
+	tests := []struct {
+		name            string
+		disconnected    bool
+		settleAbandoned bool
+		wantSkip        bool
+	}{
+		{name: "connected"},
+		{name: "disconnected", disconnected: true, wantSkip: true},
+		{name: "disconnected settles abandoned", disconnected: true, settleAbandoned: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.disconnected {
+				cancel()
+			}
+			req := &request{
+				r:     httptest.NewRequest("GET", "/api/data", nil).WithContext(ctx),
+				path:  "/api/data",
+				route: &routestore.CompiledRoute{Name: "api", Namespace: "default", SettleAbandoned: tt.settleAbandoned},
+			}
+			if got := abandoned(req, settleSync); got != tt.wantSkip {
+				t.Errorf("abandoned() = %v, want %v", got, tt.wantSkip)
+			}
+			// Settlements that go ahead are not canceled by the disconnect.
+			if !tt.wantSkip && settleContext(req).Err() != nil {
+				t.Errorf("settleContext() is canceled: %v", settleContext(req).Err())
+			}
+		})
+	}
+}
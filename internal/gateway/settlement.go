@@ -0,0 +1,45 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/finops"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// SettlementSink receives a record of every settled payment. Record is called
+// on the request path and must not block.
+type SettlementSink interface {
+	Record(finops.Record)
+}
+
+// recordSettlement passes a settled payment to the configured sinks. Metered
+// requests that were charged nothing are not recorded.
+func (h *Handler) recordSettlement(route *routestore.CompiledRoute, rule *routestore.CompiledRule, path, offer, variant string, accept *paymentAccept, amount string, decimals int, settled *settleResponse) {
+	if len(h.settlementSinks) == 0 || amount == "0" {
+		return
+	}
+	rec := finops.Record{
+		Time:        time.Now(),
+		Namespace:   route.Namespace,
+		Route:       route.Name,
+		Rule:        rule.Path,
+		Resource:    path,
+		Offer:       offer,
+		Variant:     variant,
+		Scheme:      accept.Scheme,
+		Network:     accept.Network,
+		Asset:       accept.Asset,
+		PayTo:       accept.PayTo,
+		Payer:       settled.Payer,
+		Transaction: settled.Transaction,
+		Amount:      amount,
+		Decimals:    decimals,
+	}
+	if settled.Network != "" {
+		rec.Network = settled.Network
+	}
+	for _, sink := range h.settlementSinks {
+		sink.Record(rec)
+	}
+}
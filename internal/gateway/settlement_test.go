@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/finops"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	records []finops.Record
+}
+
+func (s *recordingSink) Record(rec finops.Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+}
+
+func TestHandlerRecordsSettlements(t *testing.T) {
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer backendSrv.Close()
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc","network":"eip155:84532"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "my-api", &routestore.CompiledRoute{
+		Name:           "my-api",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules: []routestore.CompiledRule{{
+			Path:   "/api/*",
+			Price:  "0.001",
+			Mode:   "all-pay",
+			Offers: []routestore.CompiledOffer{{Name: "standard", Price: "0.001"}, {Name: "priority", Price: "0.005"}},
+		}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+	})
+	h := NewHandler(store)
+	sink := &recordingSink{}
+	h.settlementSinks = []SettlementSink{sink}
+
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2,"accepted":{"amount":"5000","extra":{"offer":"priority"}}}`))
+	req := httptest.NewRequest("GET", "/api/data", nil)
+	req.Header.Set("Payment-Signature", payload)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("records = %+v, want 1", sink.records)
+	}
+	rec := sink.records[0]
+	if rec.Namespace != "default" || rec.Route != "my-api" || rec.Rule != "/api/*" || rec.Resource != "/api/data" {
+		t.Errorf("record route = %+v", rec)
+	}
+	if rec.Offer != "priority" || rec.Amount != "5000" || rec.Decimals != 6 || rec.PayTo != "0xTestWallet" {
+		t.Errorf("record pricing = %+v", rec)
+	}
+	if rec.Payer != "0xPayer" || rec.Transaction != "0xabc" || rec.Network != "eip155:84532" || rec.Time.IsZero() {
+		t.Errorf("record settlement = %+v", rec)
+	}
+
+	// Failed settlements are not revenue.
+	facilitator.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":false,"errorReason":"insufficient_funds"}`)
+	})
+	req = httptest.NewRequest("GET", "/api/data", nil)
+	req.Header.Set("Payment-Signature", payload)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(sink.records) != 1 {
+		t.Errorf("records after failed settlement = %d, want 1", len(sink.records))
+	}
+}
@@ -0,0 +1,162 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// StatusPath serves the gateway status that client SDKs check before
+// constructing a payment.
+const StatusPath = "/x402/status"
+
+// VersionPath serves the build information of the gateway.
+const VersionPath = "/x402/version"
+
+// gatewayStatus is the body of a status response.
+type gatewayStatus struct {
+	Version      string              `json:"version"`
+	Facilitators []facilitatorStatus `json:"facilitators"`
+	Route        *routeStatus        `json:"route,omitempty"`
+}
+
+// facilitatorStatus reports the last /supported lookup of a facilitator.
+type facilitatorStatus struct {
+	URL       string    `json:"url"`
+	Reachable bool      `json:"reachable"`
+	Networks  []string  `json:"networks"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// routeStatus describes what a route charges and whether it can be paid.
+type routeStatus struct {
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	Network     string            `json:"network"`
+	Sandbox     bool              `json:"sandbox,omitempty"`
+	Facilitator facilitatorStatus `json:"facilitator"`
+	// Failover are the facilitators payments fail over to, in order.
+	Failover []facilitatorStatus `json:"failover,omitempty"`
+	// Payable is false when no facilitator is reachable and supports the
+	// route's network.
+	Payable bool              `json:"payable"`
+	Rules   []routeRuleStatus `json:"rules"`
+}
+
+type routeRuleStatus struct {
+	Path    string `json:"path"`
+	Host    string `json:"host,omitempty"`
+	Price   string `json:"price,omitempty"`
+	Free    bool   `json:"free,omitempty"`
+	Metered bool   `json:"metered,omitempty"`
+	Settle  string `json:"settle,omitempty"`
+}
+
+// serveVersion answers GET /x402/version with the build information of the
+// gateway, to tell replicas of a mixed-version rollout apart.
+func (h *Handler) serveVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(h.build)
+}
+
+// serveStatus answers GET /x402/status with the gateway version and the
+// reachability and networks of the facilitators of all routes. With
+// ?route=<namespace>/<name> it describes that route instead.
+func (h *Handler) serveStatus(w http.ResponseWriter, r *http.Request) {
+	status := gatewayStatus{Version: h.build.Version, Facilitators: []facilitatorStatus{}}
+	routes := h.store.Snapshot()
+	if key := r.URL.Query().Get("route"); key != "" {
+		route := findRoute(routes, key)
+		if route == nil {
+			http.Error(w, "unknown route "+key, http.StatusNotFound)
+			return
+		}
+		status.Route = describeRoute(route)
+		status.Facilitators = append(status.Facilitators, status.Route.Facilitator)
+		status.Facilitators = append(status.Facilitators, status.Route.Failover...)
+	} else {
+		seen := make(map[string]bool)
+		for _, route := range routes {
+			for _, facilitatorURL := range append([]string{route.FacilitatorURL}, route.FailoverURLs...) {
+				if !seen[facilitatorURL] {
+					seen[facilitatorURL] = true
+					status.Facilitators = append(status.Facilitators, lookupFacilitator(facilitatorURL))
+				}
+			}
+		}
+		sort.Slice(status.Facilitators, func(i, j int) bool { return status.Facilitators[i].URL < status.Facilitators[j].URL })
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(status)
+}
+
+// findRoute returns the route named "namespace/name", or nil.
+func findRoute(routes []*routestore.CompiledRoute, key string) *routestore.CompiledRoute {
+	namespace, name, ok := strings.Cut(key, "/")
+	if !ok {
+		return nil
+	}
+	for _, route := range routes {
+		if route.Namespace == namespace && route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// lookupFacilitator reports a facilitator from the supported-kinds cache,
+// fetching /supported when the cached answer is stale.
+func lookupFacilitator(url string) facilitatorStatus {
+	entry := facilitatorKinds.lookup(url)
+	networks := []string{}
+	seen := make(map[string]bool)
+	for kind := range entry.kinds {
+		_, network, _ := strings.Cut(kind, "/")
+		if !seen[network] {
+			seen[network] = true
+			networks = append(networks, network)
+		}
+	}
+	sort.Strings(networks)
+	return facilitatorStatus{URL: url, Reachable: entry.reachable, Networks: networks, CheckedAt: entry.checked}
+}
+
+func describeRoute(route *routestore.CompiledRoute) *routeStatus {
+	network := route.Network
+	if mapped, ok := networkToChainID[network]; ok {
+		network = mapped
+	}
+	facilitator := lookupFacilitator(route.FacilitatorURL)
+	rs := &routeStatus{
+		Namespace:   route.Namespace,
+		Name:        route.Name,
+		Network:     network,
+		Sandbox:     route.Sandbox,
+		Facilitator: facilitator,
+		Payable:     facilitator.Reachable && facilitatorKinds.supports(route.FacilitatorURL, "exact", network),
+		Rules:       []routeRuleStatus{},
+	}
+	for _, facilitatorURL := range route.FailoverURLs {
+		failover := lookupFacilitator(facilitatorURL)
+		rs.Failover = append(rs.Failover, failover)
+		rs.Payable = rs.Payable || failover.Reachable && facilitatorKinds.supports(facilitatorURL, "exact", network)
+	}
+	for _, rule := range route.Rules {
+		rs.Rules = append(rs.Rules, routeRuleStatus{
+			Path:    rule.Path,
+			Host:    rule.Host,
+			Price:   rule.Price,
+			Free:    rule.Free,
+			Metered: rule.Metering != nil,
+			Settle:  rule.Settle,
+		})
+	}
+	return rs
+}
@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/version"
+)
+
+func TestServeStatus(t *testing.T) {
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"kinds":[{"scheme":"exact","network":"base-sepolia"},{"scheme":"upto","network":"eip155:84532"},{"scheme":"exact","network":"eip155:8453"}]}`)
+	}))
+	defer facilitator.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Network: "base-sepolia", FacilitatorURL: facilitator.URL, Sandbox: true,
+		Rules: []routestore.CompiledRule{{Path: "/api/*", Price: "0.01", Settle: "sync"}, {Path: "/health", Free: true}},
+	})
+	store.Set("default", "avax", &routestore.CompiledRoute{Name: "avax", Namespace: "default", Network: "avalanche", FacilitatorURL: facilitator.URL})
+	store.Set("shop", "web", &routestore.CompiledRoute{Name: "web", Namespace: "shop", Network: "base", FacilitatorURL: down.URL})
+	h := NewHandler(store)
+	h.build.Version = "v1.2.3"
+
+	get := func(target string) (int, gatewayStatus) {
+		w := httptest.NewRecorder()
+		h.serveStatus(w, httptest.NewRequest("GET", target, nil))
+		var status gatewayStatus
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+				t.Fatalf("unmarshal %s: %v", w.Body.String(), err)
+			}
+		}
+		return w.Code, status
+	}
+
+	code, status := get(StatusPath)
+	if code != http.StatusOK || status.Version != "v1.2.3" || status.Route != nil || len(status.Facilitators) != 2 {
+		t.Fatalf("status = %d, %+v", code, status)
+	}
+	for _, f := range status.Facilitators {
+		want := f.URL == facilitator.URL
+		if f.Reachable != want {
+			t.Errorf("%s reachable = %v, want %v", f.URL, f.Reachable, want)
+		}
+	}
+
+	tests := []struct {
+		route       string
+		wantCode    int
+		wantPayable bool
+	}{
+		{route: "default/api", wantCode: http.StatusOK, wantPayable: true},
+		{route: "default/avax", wantCode: http.StatusOK},
+		{route: "shop/web", wantCode: http.StatusOK},
+		{route: "default/missing", wantCode: http.StatusNotFound},
+		{route: "api", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			code, status := get(StatusPath + "?route=" + tt.route)
+			if code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", code, tt.wantCode)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if status.Route == nil || status.Route.Payable != tt.wantPayable {
+				t.Errorf("route = %+v, want payable %v", status.Route, tt.wantPayable)
+			}
+		})
+	}
+
+	_, status = get(StatusPath + "?route=default/api")
+	if want := []string{"eip155:8453", "eip155:84532"}; !reflect.DeepEqual(status.Route.Facilitator.Networks, want) {
+		t.Errorf("networks = %v, want %v", status.Route.Facilitator.Networks, want)
+	}
+	if r := status.Route; r.Network != "eip155:84532" || !r.Sandbox || len(r.Rules) != 2 || r.Rules[0].Price != "0.01" || !r.Rules[1].Free {
+		t.Errorf("route = %+v", r)
+	}
+}
+
+func TestServeVersion(t *testing.T) {
+	h := NewHandler(routestore.New())
+	h.build = version.Info{Version: "v1.2.3", Commit: "abc1234", BuildDate: "2026-01-02T15:04:05Z", GoVersion: "go1.25.0"}
+
+	w := httptest.NewRecorder()
+	h.serveVersion(w, httptest.NewRequest("GET", VersionPath, nil))
+	var got version.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", w.Body.String(), err)
+	}
+	if got != h.build {
+		t.Errorf("version = %+v, want %+v", got, h.build)
+	}
+}
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// trustedUpstream accepts payment verifications attested by an upstream
+// tier, such as an edge gateway, that shares a key with this gateway. The
+// payment is settled as usual; only the facilitator's /verify is skipped.
+type trustedUpstream struct {
+	keys     *backend.KeySet
+	audience string // this gateway's name in the attestations it accepts
+}
+
+// verified returns the verification attested for the payment of req, or nil
+// when there is none or it does not hold, in which case the facilitator
+// verifies the payment as if no attestation had been sent.
+func (u *trustedUpstream) verified(req *request) *verifyResponse {
+	if u == nil || req.attestation == "" {
+		return nil
+	}
+	route := req.route
+	a, err := backend.VerifyAttestation(u.keys, req.attestation, u.audience, time.Now())
+	var facilitator string
+	if err == nil {
+		facilitator, err = attestationMismatch(a, req.paymentHeader, req.accept, route)
+	}
+	if err != nil {
+		slog.Warn("upstream attestation rejected, verifying with the facilitator", "path", req.path, "route", route.Name, "error", err)
+		metrics.TrustedVerificationsTotal.WithLabelValues(route.Namespace, route.Name, "rejected").Inc()
+		return nil
+	}
+	slog.Debug("payment verified upstream", "path", req.path, "route", route.Name, "issuer", a.Issuer, "facilitator", facilitator)
+	metrics.TrustedVerificationsTotal.WithLabelValues(route.Namespace, route.Name, "accepted").Inc()
+	return &verifyResponse{IsValid: true, Payer: a.Payer, facilitator: facilitator}
+}
+
+// attestationMismatch reports why attestation a does not vouch for the
+// payment in paymentHeader against accept, the requirements this gateway
+// charges: the upstream tier may have verified another payment, price or
+// asset. Otherwise it returns the facilitator of route that verified the
+// payment upstream, which must settle it too; a facilitator the route does
+// not use is a mismatch.
+func attestationMismatch(a *backend.Attestation, paymentHeader string, accept *paymentAccept, route *routestore.CompiledRoute) (string, error) {
+	switch {
+	case a.Payment != backend.PaymentDigest(paymentHeader):
+		return "", errors.New("attestation is for another payment")
+	case a.Scheme != accept.Scheme:
+		return "", errors.New("attestation is for scheme " + a.Scheme)
+	case a.Network != accept.Network:
+		return "", errors.New("attestation is for network " + a.Network)
+	case !strings.EqualFold(a.Asset, accept.Asset):
+		return "", errors.New("attestation is for asset " + a.Asset)
+	case a.Amount != accept.Amount:
+		return "", errors.New("attestation is for amount " + a.Amount)
+	case !strings.EqualFold(a.PayTo, accept.PayTo):
+		return "", errors.New("attestation is for another recipient")
+	}
+	for _, facilitatorURL := range append([]string{route.FacilitatorURL}, route.FailoverURLs...) {
+		if a.Facilitator != "" && strings.TrimRight(a.Facilitator, "/") == strings.TrimRight(facilitatorURL, "/") {
+			return facilitatorURL, nil
+		}
+	}
+	return "", errors.New("attestation is for facilitator " + a.Facilitator + ", which the route does not use")
+}
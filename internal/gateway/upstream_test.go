@@ -0,0 +1,115 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/gateway/gatewaytest"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+func TestTrustedUpstream(t *testing.T) {
+	keys, err := backend.NewKeySet("k1", map[string][]byte{"k1": []byte("shared-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	facilitator, failover := gatewaytest.NewFacilitator(t), gatewaytest.NewFacilitator(t)
+	b := gatewaytest.NewBackend(t)
+	route := gatewaytest.Route("api", facilitator, b.URL, gatewaytest.PaidRule("/api/*", "0.01"))
+	route.FailoverURLs = []string{failover.URL}
+	h := NewHandler(gatewaytest.Store(route))
+	h.upstream = &trustedUpstream{keys: keys, audience: "inner"}
+	accept := gatewaytest.DecodePaymentRequired(t, gatewaytest.Serve(h, gatewaytest.Request("GET", "/api/data"))).Accepts[0]
+
+	attest := func(change func(a *backend.Attestation)) string {
+		now := time.Now()
+		a := backend.Attestation{
+			Issuer:    "edge",
+			Audience:  "inner",
+			Payer:     gatewaytest.Payer,
+			Payment:     backend.PaymentDigest(gatewaytest.PaymentHeader),
+			Scheme:      accept.Scheme,
+			Network:     accept.Network,
+			Asset:       accept.Asset,
+			Amount:      accept.Amount,
+			PayTo:       accept.PayTo,
+			Facilitator: facilitator.URL,
+			IssuedAt:    now.Unix(),
+			ExpiresAt:   now.Add(30 * time.Second).Unix(),
+		}
+		if change != nil {
+			change(&a)
+		}
+		token, err := backend.SignAttestation(context.Background(), keys, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	other, _ := backend.NewKeySet("k1", map[string][]byte{"k1": []byte("other-secret")})
+	forged, _ := backend.SignAttestation(context.Background(), other, backend.Attestation{Audience: "inner", Payment: "x", IssuedAt: time.Now().Unix(), ExpiresAt: time.Now().Add(time.Second).Unix()})
+
+	tests := []struct {
+		name        string
+		attestation string
+		wantVerify  int
+		wantSettler *gatewaytest.Facilitator // default: facilitator
+	}{
+		{name: "attested", attestation: attest(nil), wantVerify: 0},
+		{name: "attested by the failover facilitator", attestation: attest(func(a *backend.Attestation) { a.Facilitator = failover.URL + "/" }), wantVerify: 0, wantSettler: failover},
+		{name: "no attestation", wantVerify: 1},
+		{name: "other audience", attestation: attest(func(a *backend.Attestation) { a.Audience = "other" }), wantVerify: 1},
+		{name: "other payment", attestation: attest(func(a *backend.Attestation) { a.Payment = backend.PaymentDigest("other") }), wantVerify: 1},
+		{name: "lower amount", attestation: attest(func(a *backend.Attestation) { a.Amount = "1" }), wantVerify: 1},
+		{name: "other recipient", attestation: attest(func(a *backend.Attestation) { a.PayTo = "0xOther" }), wantVerify: 1},
+		{name: "other asset", attestation: attest(func(a *backend.Attestation) { a.Asset = "0xOtherToken" }), wantVerify: 1},
+		{name: "other scheme", attestation: attest(func(a *backend.Attestation) { a.Scheme = "upto" }), wantVerify: 1},
+		{name: "other facilitator", attestation: attest(func(a *backend.Attestation) { a.Facilitator = "https://facilitator.example" }), wantVerify: 1},
+		{name: "no facilitator", attestation: attest(func(a *backend.Attestation) { a.Facilitator = "" }), wantVerify: 1},
+		{name: "expired", attestation: attest(func(a *backend.Attestation) { a.IssuedAt, a.ExpiresAt = a.IssuedAt-60, a.IssuedAt-1 }), wantVerify: 1},
+		{name: "forged", attestation: forged, wantVerify: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settler := facilitator
+			if tt.wantSettler != nil {
+				settler = tt.wantSettler
+			}
+			verifies, settles, calls := len(facilitator.Calls("/verify")), len(settler.Calls("/settle")), len(b.Calls())
+			r := gatewaytest.PaidRequest("GET", "/api/data")
+			if tt.attestation != "" {
+				r.Header.Set(backend.HeaderVerified, tt.attestation)
+			}
+			w := gatewaytest.Serve(h, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			if got := len(facilitator.Calls("/verify")) - verifies; got != tt.wantVerify {
+				t.Errorf("verifications = %d, want %d", got, tt.wantVerify)
+			}
+			// The facilitator that verified the payment settles it.
+			if got := len(settler.Calls("/settle")) - settles; got != 1 {
+				t.Errorf("settlements = %d, want 1", got)
+			}
+			if len(b.Calls()) != calls+1 {
+				t.Errorf("backend calls = %d, want %d", len(b.Calls()), calls+1)
+			}
+		})
+	}
+}
+
+func TestUntrustedUpstream(t *testing.T) {
+	facilitator := gatewaytest.NewFacilitator(t)
+	h := NewHandler(gatewaytest.Store(gatewaytest.Route("api", facilitator, gatewaytest.NewBackend(t).URL, gatewaytest.PaidRule("/api/*", "0.01"))))
+	r := gatewaytest.PaidRequest("GET", "/api/data")
+	r.Header.Set(backend.HeaderVerified, "anything")
+	if w := gatewaytest.Serve(h, r); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	// Without a trusted upstream the header is ignored.
+	if len(facilitator.Calls("/verify")) != 1 {
+		t.Errorf("verifications = %d, want 1", len(facilitator.Calls("/verify")))
+	}
+}
@@ -0,0 +1,90 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// validateRequestStage answers requests the rule's requestValidation rejects
+// 400 before payment is demanded, so clients do not pay for requests the
+// backend would refuse. Probe requests are not checked.
+func validateRequestStage(req *request, next func()) {
+	if v := req.rule.Validation; v != nil && !req.probe {
+		if err := validateRequest(req.r, v); err != nil {
+			route := req.route
+			slog.Info("request failed validation", "path", req.path, "route", route.Name, "error", err)
+			metrics.RequestsTotal.WithLabelValues(pathLabel(req.rule, req.path), route.Namespace, route.Name, "invalid_request").Inc()
+			http.Error(req.w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	next()
+}
+
+// validateRequest checks the headers and body of r. The body is restored
+// for the backend.
+func validateRequest(r *http.Request, v *routestore.CompiledRequestValidation) error {
+	for _, name := range v.RequiredHeaders {
+		if strings.TrimSpace(r.Header.Get(name)) == "" {
+			return fmt.Errorf("missing required header %s", name)
+		}
+	}
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	if len(v.ContentTypes) > 0 {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !acceptedContentType(mediaType, v.ContentTypes) {
+			return fmt.Errorf("content type %q is not accepted, want %s", r.Header.Get("Content-Type"), strings.Join(v.ContentTypes, ", "))
+		}
+	}
+	if v.Schema == nil {
+		return nil
+	}
+	body, tooLarge, err := peekBody(r, v.MaxBodyBytes)
+	if err != nil {
+		return fmt.Errorf("read request body: %w", err)
+	}
+	if tooLarge {
+		return fmt.Errorf("request body exceeds %d bytes", v.MaxBodyBytes)
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("request body is not JSON: %w", err)
+	}
+	if err := v.Schema.Validate(doc); err != nil {
+		return fmt.Errorf("request body does not match the schema: %w", err)
+	}
+	return nil
+}
+
+// acceptedContentType reports whether mediaType is one of accepted, where
+// "type/*" accepts every subtype.
+func acceptedContentType(mediaType string, accepted []string) bool {
+	for _, a := range accepted {
+		if a == mediaType || strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// peekBody reads at most limit bytes of the request body and restores the
+// body for the backend. tooLarge reports a body longer than limit.
+func peekBody(r *http.Request, limit int64) (body []byte, tooLarge bool, err error) {
+	body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return body, int64(len(body)) > limit, err
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/jsonschema"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+func TestRequestValidation(t *testing.T) {
+	schema, err := jsonschema.Compile([]byte(`{"type":"object","required":["sku"],"properties":{"sku":{"type":"string"}}}`))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	var facilitatorCalls atomic.Int32
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		facilitatorCalls.Add(1)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "api", &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Wallet: "0xTestWallet", Network: "base-sepolia", FacilitatorURL: facilitator.URL,
+		Rules: []routestore.CompiledRule{{Path: "/orders", Price: "0.01", Mode: "all-pay", Settle: settleSync,
+			Validation: &routestore.CompiledRequestValidation{
+				RequiredHeaders: []string{"X-Tenant-Id"},
+				ContentTypes:    []string{"application/json", "text/*"},
+				Schema:          schema,
+				MaxBodyBytes:    64,
+			},
+		}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: "http://127.0.0.1:1"}},
+	})
+	h := NewHandler(store)
+
+	tests := []struct {
+		name        string
+		tenant      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{name: "valid", tenant: "acme", contentType: "application/json; charset=utf-8", body: `{"sku":"A-1"}`, wantStatus: http.StatusPaymentRequired},
+		{name: "no body", tenant: "acme", wantStatus: http.StatusPaymentRequired},
+		{name: "missing header", contentType: "application/json", body: `{"sku":"A-1"}`, wantStatus: http.StatusBadRequest, wantBody: "missing required header X-Tenant-Id"},
+		{name: "wrong content type", tenant: "acme", contentType: "application/xml", body: `<order/>`, wantStatus: http.StatusBadRequest, wantBody: "is not accepted"},
+		{name: "not JSON", tenant: "acme", contentType: "text/plain", body: `sku=A-1`, wantStatus: http.StatusBadRequest, wantBody: "not JSON"},
+		{name: "schema violation", tenant: "acme", contentType: "application/json", body: `{"sku":1}`, wantStatus: http.StatusBadRequest, wantBody: "/sku: must be string"},
+		{name: "body too large", tenant: "acme", contentType: "application/json", body: `{"sku":"` + strings.Repeat("A", 64) + `"}`, wantStatus: http.StatusBadRequest, wantBody: "exceeds 64 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest("POST", "/orders", body)
+			if tt.tenant != "" {
+				r.Header.Set("X-Tenant-ID", tt.tenant)
+			}
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+	if n := facilitatorCalls.Load(); n != 0 {
+		t.Errorf("facilitator called %d times", n)
+	}
+}
+
+func TestValidateRequestRestoresBody(t *testing.T) {
+	schema, _ := jsonschema.Compile([]byte(`{"type":"object"}`))
+	r := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"sku":"A-1"}`))
+	if err := validateRequest(r, &routestore.CompiledRequestValidation{Schema: schema, MaxBodyBytes: 1024}); err != nil {
+		t.Fatalf("validateRequest() error = %v", err)
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != `{"sku":"A-1"}` {
+		t.Errorf("body after validation = %q", body)
+	}
+}
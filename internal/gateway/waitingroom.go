@@ -0,0 +1,219 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+// headerQueueToken carries the queue token a waiting room hands to requests
+// over a route's maxConcurrent. Clients send it back when they retry to be
+// admitted before new arrivals.
+const headerQueueToken = "X-402-Queue-Token"
+
+// maxQueueTickets bounds the tickets a waiting room tracks per route.
+const maxQueueTickets = 10000
+
+// queueTokenEarly is how long before its retry time a queue token is
+// honored, so clients that round Retry-After down are not turned away.
+const queueTokenEarly = time.Second
+
+// queueHoldTTL is how long the hold time of a route is remembered after its
+// last request.
+const queueHoldTTL = time.Hour
+
+// waitingRoomPrefix starts the state store keys of waiting rooms, followed by
+// the route's namespace/name and the kind of key: "tickets:<ticket>" for the
+// tokens still waiting, "redeemed:<ticket>" for those redeemed, and "hold".
+const waitingRoomPrefix = "waitingroom:"
+
+// waitingRoom issues the signed queue tokens of routes at capacity and redeems
+// them for priority admission. Tickets are kept in the state store, so
+// replicas sharing it count each other's positions and redeem a token once;
+// tokens verify on every replica sharing the key.
+type waitingRoom struct {
+	key   []byte
+	store storage.Interface
+	now   func() time.Time
+}
+
+// queueClaims are the signed contents of a queue token.
+type queueClaims struct {
+	Route   string `json:"route"`
+	Ticket  uint64 `json:"ticket"`
+	RetryAt int64  `json:"retryAt"`
+	Expires int64  `json:"expires"`
+}
+
+// queuedResponse is the body of the 429 responses of a waiting room.
+type queuedResponse struct {
+	Error      string    `json:"error"`
+	QueueToken string    `json:"queueToken"`
+	Position   int       `json:"position"`
+	RetryAfter int       `json:"retryAfter"`
+	RetryAt    time.Time `json:"retryAt"`
+}
+
+// newWaitingRoom returns a waiting room keeping its tickets in store and
+// signing tokens with key, or with a random key when key is nil.
+func newWaitingRoom(key []byte, store storage.Interface) *waitingRoom {
+	if key == nil {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &waitingRoom{key: key, store: store, now: time.Now}
+}
+
+// storeKey returns the state store key of route for kind.
+func (wr *waitingRoom) storeKey(route *routestore.CompiledRoute, kind string) string {
+	return waitingRoomPrefix + route.Namespace + "/" + route.Name + ":" + kind
+}
+
+// issue hands a queue token to a request of route turned away for capacity.
+// Its position counts the tokens of the route still waiting, and its retry
+// time is when that many requests are expected to have been served. It
+// returns false when the route's waiting room is full or its state is
+// unavailable.
+func (wr *waitingRoom) issue(ctx context.Context, route *routestore.CompiledRoute) (*queuedResponse, bool) {
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	now := wr.now()
+	waiting, err := wr.store.Scan(ctx, wr.storeKey(route, "tickets:"))
+	if err != nil {
+		slog.Warn("waiting room state unavailable", "route", route.Name, "error", err)
+		return nil, false
+	}
+	if len(waiting) >= maxQueueTickets {
+		return nil, false
+	}
+	position := len(waiting) + 1
+	hold := time.Second
+	if raw, err := wr.store.Get(ctx, wr.storeKey(route, "hold")); err == nil {
+		if ms, err := strconv.ParseInt(string(raw), 10, 64); err == nil && ms > 0 {
+			hold = time.Duration(ms) * time.Millisecond
+		}
+	}
+	batches := (position + int(route.MaxConcurrent) - 1) / int(route.MaxConcurrent)
+	wait := min(max(time.Duration(batches)*hold, time.Second), route.WaitingRoom.MaxRetryAfter)
+	wait = wait.Round(time.Second)
+	retryAt := now.Add(wait)
+	claims := queueClaims{
+		Route:   route.Namespace + "/" + route.Name,
+		Ticket:  randomTicket(),
+		RetryAt: retryAt.Unix(),
+		Expires: retryAt.Add(route.WaitingRoom.TokenTTL).Unix(),
+	}
+	ticket := wr.storeKey(route, "tickets:"+strconv.FormatUint(claims.Ticket, 10))
+	if err := wr.store.Set(ctx, ticket, nil, time.Unix(claims.Expires, 0).Sub(now)); err != nil {
+		slog.Warn("waiting room state unavailable", "route", route.Name, "error", err)
+		return nil, false
+	}
+
+	metrics.QueueTokensTotal.WithLabelValues(route.Namespace, route.Name, "issued").Inc()
+	return &queuedResponse{
+		Error:      "backend at capacity, retry with the queue token",
+		QueueToken: wr.sign(claims),
+		Position:   position,
+		RetryAfter: int(wait / time.Second),
+		RetryAt:    retryAt.UTC(),
+	}, true
+}
+
+// redeem reports whether token is a valid queue token of route whose retry
+// time has come. Each token is redeemed once, on whichever replica.
+func (wr *waitingRoom) redeem(ctx context.Context, token string, route *routestore.CompiledRoute) bool {
+	if token == "" {
+		return false
+	}
+	claims, ok := wr.verify(token)
+	now := wr.now()
+	if !ok || claims.Route != route.Namespace+"/"+route.Name ||
+		now.Add(queueTokenEarly).Unix() < claims.RetryAt || now.Unix() >= claims.Expires {
+		metrics.QueueTokensTotal.WithLabelValues(route.Namespace, route.Name, "invalid").Inc()
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	ticket := strconv.FormatUint(claims.Ticket, 10)
+	added, err := wr.store.Add(ctx, wr.storeKey(route, "redeemed:"+ticket), nil, time.Unix(claims.Expires, 0).Sub(now))
+	if err != nil || !added {
+		if err != nil {
+			slog.Warn("waiting room state unavailable", "route", route.Name, "error", err)
+		}
+		metrics.QueueTokensTotal.WithLabelValues(route.Namespace, route.Name, "invalid").Inc()
+		return false
+	}
+	wr.store.Delete(ctx, wr.storeKey(route, "tickets:"+ticket))
+	metrics.QueueTokensTotal.WithLabelValues(route.Namespace, route.Name, "redeemed").Inc()
+	return true
+}
+
+// observe records that a request held a backend slot of route for d, which
+// paces the retry times of later tokens. The hold time is a moving average
+// of the requests of every replica.
+func (wr *waitingRoom) observe(ctx context.Context, route *routestore.CompiledRoute, d time.Duration) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+	key := wr.storeKey(route, "hold")
+	hold := d
+	if raw, err := wr.store.Get(ctx, key); err == nil {
+		if ms, err := strconv.ParseInt(string(raw), 10, 64); err == nil && ms > 0 {
+			hold = (4*time.Duration(ms)*time.Millisecond + d) / 5
+		}
+	}
+	if err := wr.store.Set(ctx, key, strconv.AppendInt(nil, hold.Milliseconds(), 10), queueHoldTTL); err != nil {
+		slog.Warn("waiting room state unavailable", "route", route.Name, "error", err)
+	}
+}
+
+// sign encodes claims as a token: the base64url JSON claims and their
+// HMAC-SHA256, separated by a dot.
+func (wr *waitingRoom) sign(claims queueClaims) string {
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, wr.key)
+	mac.Write([]byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the claims of a token signed by wr.
+func (wr *waitingRoom) verify(token string) (queueClaims, bool) {
+	var claims queueClaims
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return claims, false
+	}
+	mac := hmac.New(sha256.New, wr.key)
+	mac.Write([]byte(encoded))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return claims, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, false
+	}
+	return claims, true
+}
+
+// randomTicket returns a random ticket number.
+func randomTicket() uint64 {
+	var raw [8]byte
+	rand.Read(raw[:])
+	return binary.BigEndian.Uint64(raw[:])
+}
@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/storage"
+)
+
+func TestWaitingRoomTokens(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	store := storage.NewMemory()
+	wr := newWaitingRoom([]byte("0123456789abcdef"), store)
+	wr.now = func() time.Time { return now }
+	ctx := context.Background()
+	route := &routestore.CompiledRoute{
+		Namespace:     "default",
+		Name:          "gpu",
+		MaxConcurrent: 2,
+		WaitingRoom:   &routestore.CompiledWaitingRoom{MaxRetryAfter: time.Minute, TokenTTL: time.Minute},
+	}
+
+	// Requests take 10s each, two at a time: the third in line waits for
+	// two rounds.
+	wr.observe(ctx, route, 10*time.Second)
+	first, _ := wr.issue(ctx, route)
+	wr.issue(ctx, route)
+	third, ok := wr.issue(ctx, route)
+	if !ok || first.Position != 1 || third.Position != 3 || third.RetryAfter != 20 {
+		t.Fatalf("third = %+v, want position 3 retrying after 20s", third)
+	}
+
+	if wr.redeem(ctx, third.QueueToken, route) {
+		t.Error("token redeemed before its retry time")
+	}
+	now = now.Add(20 * time.Second)
+	if !wr.redeem(ctx, third.QueueToken, route) {
+		t.Fatal("token not redeemed at its retry time")
+	}
+	if wr.redeem(ctx, third.QueueToken, route) {
+		t.Error("token redeemed twice")
+	}
+
+	// Redeemed tokens leave the queue.
+	if next, _ := wr.issue(ctx, route); next.Position != 3 {
+		t.Errorf("position after a redemption = %d, want 3", next.Position)
+	}
+
+	other := *route
+	other.Name = "other"
+	forged := strings.Replace(first.QueueToken, ".", ".x", 1)
+	if wr.redeem(ctx, first.QueueToken, &other) || wr.redeem(ctx, forged, route) {
+		t.Error("token redeemed on another route or with a bad signature")
+	}
+	replica, stranger := newWaitingRoom([]byte("0123456789abcdef"), store), newWaitingRoom(nil, store)
+	replica.now, stranger.now = wr.now, wr.now
+	if stranger.redeem(ctx, first.QueueToken, route) {
+		t.Error("token redeemed by a replica with another key")
+	}
+	if !replica.redeem(ctx, first.QueueToken, route) {
+		t.Error("token not redeemed by a replica sharing the key")
+	}
+	if wr.redeem(ctx, first.QueueToken, route) {
+		t.Error("token redeemed again after a replica redeemed it")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if wr.redeem(ctx, first.QueueToken, route) {
+		t.Error("expired token redeemed")
+	}
+}
+
+func TestConcurrencyLimiterPriority(t *testing.T) {
+	l := newConcurrencyLimiter()
+	ctx := context.Background()
+	route := &routestore.CompiledRoute{Namespace: "default", Name: "gpu", MaxConcurrent: 1}
+
+	release, _ := l.acquire(ctx, route)
+	admitted := make(chan bool)
+	go func() {
+		free, ok := l.acquirePriority(ctx, route, time.Second)
+		if ok {
+			defer free()
+		}
+		admitted <- ok
+	}()
+	for !l.prioritized(route) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// A freed slot goes to the token holder, not to a new request.
+	release()
+	if _, ok := l.acquire(ctx, route); ok {
+		t.Error("new request admitted while a token holder waits")
+	}
+	if !<-admitted {
+		t.Fatal("token holder not admitted")
+	}
+	if l.prioritized(route) {
+		t.Error("route still prioritized after the token holder was admitted")
+	}
+}
+
+func TestHandlerWaitingRoom(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+		io.WriteString(w, "ok")
+	}))
+	defer backendSrv.Close()
+	facilitator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			io.WriteString(w, `{"isValid":true,"payer":"0xPayer"}`)
+			return
+		}
+		io.WriteString(w, `{"success":true,"payer":"0xPayer","transaction":"0xabc","network":"eip155:84532"}`)
+	}))
+	defer facilitator.Close()
+
+	store := routestore.New()
+	store.Set("default", "gpu", &routestore.CompiledRoute{
+		Name:           "gpu",
+		Namespace:      "default",
+		Wallet:         "0xTestWallet",
+		Network:        "base-sepolia",
+		FacilitatorURL: facilitator.URL,
+		Rules:          []routestore.CompiledRule{{Path: "/infer", Price: "0.01", Mode: "all-pay"}},
+		Backends:       []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: backendSrv.URL}},
+		MaxConcurrent:  1,
+		WaitingRoom:    &routestore.CompiledWaitingRoom{MaxRetryAfter: time.Minute, TokenTTL: time.Minute, PriorityWait: 5 * time.Second},
+	})
+	h := NewHandler(store)
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"x402Version":2}`))
+	paid := func(token string) *http.Request {
+		req := httptest.NewRequest("POST", "/infer", nil)
+		req.Header.Set("Payment-Signature", payload)
+		if token != "" {
+			req.Header.Set(headerQueueToken, token)
+		}
+		return req
+	}
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(first, paid(""))
+		close(done)
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, paid(""))
+	var queued queuedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &queued); err != nil {
+		t.Fatalf("unmarshal 429 body %q: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" || queued.Position != 1 ||
+		queued.QueueToken == "" || w.Header().Get(headerQueueToken) != queued.QueueToken {
+		t.Fatalf("over capacity: status %d, Retry-After %q, body %+v, want 429 with a queue token", w.Code, w.Header().Get("Retry-After"), queued)
+	}
+
+	// The token holder waits for the slot the first request frees.
+	time.AfterFunc(50*time.Millisecond, func() { close(unblock) })
+	go func() { <-entered }()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, paid(queued.QueueToken))
+	if w.Code != http.StatusOK {
+		t.Errorf("token holder status = %d, want 200", w.Code)
+	}
+	<-done
+	if first.Code != http.StatusOK {
+		t.Errorf("first request status = %d, want 200", first.Code)
+	}
+}
@@ -0,0 +1,453 @@
+// Package jsonschema validates JSON documents against the validation
+// vocabulary of JSON Schema: type, enum, const, the numeric, string, array
+// and object bounds, properties, additionalProperties, items and the allOf,
+// anyOf, oneOf and not combinators. References, conditionals and formats
+// are not supported; schemas using them fail to compile rather than being
+// silently enforced in part.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// annotations are keywords that do not constrain documents.
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+	"format": true,
+}
+
+var typeNames = []string{"null", "boolean", "object", "array", "number", "integer", "string"}
+
+// Schema is a compiled JSON Schema. The zero value accepts every document.
+type Schema struct {
+	never bool // the false schema
+
+	types []string
+	enum  []any
+	// konst is the const value, set when hasConst.
+	konst    any
+	hasConst bool
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         *float64
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	items              *Schema
+	minItems, maxItems *int
+	uniqueItems        bool
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	minProperties        *int
+	maxProperties        *int
+
+	allOf, anyOf, oneOf []*Schema
+	not                 *Schema
+
+	// source is the document a top-level schema was compiled from.
+	source json.RawMessage
+}
+
+// Compile parses a JSON Schema document.
+func Compile(data []byte) (*Schema, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("schema is not JSON: %w", err)
+	}
+	s, err := compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	s.source = slices.Clone(data)
+	return s, nil
+}
+
+// MarshalJSON returns the document the schema was compiled from, so compiled
+// routes holding schemas can be sent to remote gateways.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.source == nil {
+		return nil, fmt.Errorf("schema was not compiled from a document")
+	}
+	return s.source, nil
+}
+
+// UnmarshalJSON compiles a schema document.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	compiled, err := Compile(data)
+	if err != nil {
+		return err
+	}
+	*s = *compiled
+	return nil
+}
+
+func compile(doc any, path string) (*Schema, error) {
+	switch v := doc.(type) {
+	case bool:
+		return &Schema{never: !v}, nil
+	case map[string]any:
+		s := &Schema{}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := s.keyword(k, v[k], path+"/"+k); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", location(path))
+	}
+}
+
+// keyword compiles one keyword of an object schema.
+func (s *Schema) keyword(k string, v any, path string) error {
+	var err error
+	switch k {
+	case "type":
+		s.types, err = typeList(v, path)
+	case "enum":
+		values, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an array", location(path))
+		}
+		s.enum = values
+	case "const":
+		s.konst, s.hasConst = v, true
+	case "minimum":
+		s.minimum, err = number(v, path)
+	case "maximum":
+		s.maximum, err = number(v, path)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = number(v, path)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = number(v, path)
+	case "multipleOf":
+		if s.multipleOf, err = number(v, path); err == nil && *s.multipleOf <= 0 {
+			err = fmt.Errorf("%s: must be greater than 0", location(path))
+		}
+	case "minLength":
+		s.minLength, err = count(v, path)
+	case "maxLength":
+		s.maxLength, err = count(v, path)
+	case "pattern":
+		p, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", location(path))
+		}
+		if s.pattern, err = regexp.Compile(p); err != nil {
+			err = fmt.Errorf("%s: %w", location(path), err)
+		}
+	case "items":
+		s.items, err = compile(v, path)
+	case "minItems":
+		s.minItems, err = count(v, path)
+	case "maxItems":
+		s.maxItems, err = count(v, path)
+	case "uniqueItems":
+		unique, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("%s: must be a boolean", location(path))
+		}
+		s.uniqueItems = unique
+	case "properties":
+		props, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an object", location(path))
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, sub := range props {
+			if s.properties[name], err = compile(sub, path+"/"+escape(name)); err != nil {
+				return err
+			}
+		}
+	case "required":
+		names, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an array of strings", location(path))
+		}
+		for _, n := range names {
+			name, ok := n.(string)
+			if !ok {
+				return fmt.Errorf("%s: must be an array of strings", location(path))
+			}
+			s.required = append(s.required, name)
+		}
+	case "additionalProperties":
+		s.additionalProperties, err = compile(v, path)
+	case "minProperties":
+		s.minProperties, err = count(v, path)
+	case "maxProperties":
+		s.maxProperties, err = count(v, path)
+	case "allOf", "anyOf", "oneOf":
+		subs, ok := v.([]any)
+		if !ok || len(subs) == 0 {
+			return fmt.Errorf("%s: must be a non-empty array", location(path))
+		}
+		compiled := make([]*Schema, len(subs))
+		for i, sub := range subs {
+			if compiled[i], err = compile(sub, path+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		switch k {
+		case "allOf":
+			s.allOf = compiled
+		case "anyOf":
+			s.anyOf = compiled
+		default:
+			s.oneOf = compiled
+		}
+	case "not":
+		s.not, err = compile(v, path)
+	default:
+		if !annotations[k] {
+			return fmt.Errorf("%s: unsupported keyword", location(path))
+		}
+	}
+	return err
+}
+
+// Validate checks a decoded JSON document, as returned by json.Unmarshal
+// into an any. The error names the location of the first violation.
+func (s *Schema) Validate(doc any) error {
+	return s.validate(doc, "")
+}
+
+func (s *Schema) validate(v any, path string) error {
+	if s.never {
+		return fmt.Errorf("%s: not allowed", location(path))
+	}
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(v, t) }) {
+		return fmt.Errorf("%s: must be %s", location(path), strings.Join(s.types, " or "))
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		return fmt.Errorf("%s: must be one of the enumerated values", location(path))
+	}
+	if s.hasConst && !reflect.DeepEqual(s.konst, v) {
+		return fmt.Errorf("%s: must be the constant value", location(path))
+	}
+
+	var err error
+	switch v := v.(type) {
+	case float64:
+		err = s.validateNumber(v, path)
+	case string:
+		err = s.validateString(v, path)
+	case []any:
+		err = s.validateArray(v, path)
+	case map[string]any:
+		err = s.validateObject(v, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(v, path); err != nil {
+			return err
+		}
+	}
+	if s.anyOf != nil && !slices.ContainsFunc(s.anyOf, func(sub *Schema) bool { return sub.validate(v, path) == nil }) {
+		return fmt.Errorf("%s: must match at least one schema of anyOf", location(path))
+	}
+	if s.oneOf != nil {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(v, path) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s: must match exactly one schema of oneOf, matches %d", location(path), matched)
+		}
+	}
+	if s.not != nil && s.not.validate(v, path) == nil {
+		return fmt.Errorf("%s: must not match the schema of not", location(path))
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(v float64, path string) error {
+	switch {
+	case s.minimum != nil && v < *s.minimum:
+		return fmt.Errorf("%s: must be at least %v", location(path), *s.minimum)
+	case s.maximum != nil && v > *s.maximum:
+		return fmt.Errorf("%s: must be at most %v", location(path), *s.maximum)
+	case s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum:
+		return fmt.Errorf("%s: must be greater than %v", location(path), *s.exclusiveMinimum)
+	case s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum:
+		return fmt.Errorf("%s: must be less than %v", location(path), *s.exclusiveMaximum)
+	case s.multipleOf != nil && !isInteger(v / *s.multipleOf):
+		return fmt.Errorf("%s: must be a multiple of %v", location(path), *s.multipleOf)
+	}
+	return nil
+}
+
+func (s *Schema) validateString(v string, path string) error {
+	n := utf8.RuneCountInString(v)
+	switch {
+	case s.minLength != nil && n < *s.minLength:
+		return fmt.Errorf("%s: must be at least %d characters long", location(path), *s.minLength)
+	case s.maxLength != nil && n > *s.maxLength:
+		return fmt.Errorf("%s: must be at most %d characters long", location(path), *s.maxLength)
+	case s.pattern != nil && !s.pattern.MatchString(v):
+		return fmt.Errorf("%s: must match the pattern %q", location(path), s.pattern.String())
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(v []any, path string) error {
+	switch {
+	case s.minItems != nil && len(v) < *s.minItems:
+		return fmt.Errorf("%s: must have at least %d items", location(path), *s.minItems)
+	case s.maxItems != nil && len(v) > *s.maxItems:
+		return fmt.Errorf("%s: must have at most %d items", location(path), *s.maxItems)
+	}
+	if s.uniqueItems {
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if reflect.DeepEqual(v[i], v[j]) {
+					return fmt.Errorf("%s: items %d and %d are equal", location(path), i, j)
+				}
+			}
+		}
+	}
+	if s.items != nil {
+		for i, item := range v {
+			if err := s.items.validate(item, path+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(v map[string]any, path string) error {
+	switch {
+	case s.minProperties != nil && len(v) < *s.minProperties:
+		return fmt.Errorf("%s: must have at least %d properties", location(path), *s.minProperties)
+	case s.maxProperties != nil && len(v) > *s.maxProperties:
+		return fmt.Errorf("%s: must have at most %d properties", location(path), *s.maxProperties)
+	}
+	for _, name := range s.required {
+		if _, ok := v[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", location(path), name)
+		}
+	}
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sub, ok := s.properties[name]
+		if !ok {
+			sub = s.additionalProperties
+		}
+		if sub == nil {
+			continue
+		}
+		if !ok && sub.never {
+			return fmt.Errorf("%s: property %q is not allowed", location(path), name)
+		}
+		if err := sub.validate(v[name], path+"/"+escape(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasType reports whether a decoded JSON value is of the JSON Schema type t.
+func hasType(v any, t string) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case float64:
+		return t == "number" || t == "integer" && isInteger(v)
+	case string:
+		return t == "string"
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+func isInteger(v float64) bool {
+	return !math.IsInf(v, 0) && v == math.Trunc(v)
+}
+
+func typeList(v any, path string) ([]string, error) {
+	var types []string
+	switch v := v.(type) {
+	case string:
+		types = []string{v}
+	case []any:
+		for _, t := range v {
+			name, ok := t.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a type name or an array of them", location(path))
+			}
+			types = append(types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s: must be a type name or an array of them", location(path))
+	}
+	for _, t := range types {
+		if !slices.Contains(typeNames, t) {
+			return nil, fmt.Errorf("%s: unknown type %q", location(path), t)
+		}
+	}
+	return types, nil
+}
+
+func number(v any, path string) (*float64, error) {
+	n, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", location(path))
+	}
+	return &n, nil
+}
+
+func count(v any, path string) (*int, error) {
+	n, ok := v.(float64)
+	if !ok || n < 0 || !isInteger(n) {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", location(path))
+	}
+	c := int(n)
+	return &c, nil
+}
+
+// location formats a JSON pointer for errors, "/" for the root.
+func location(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// escape escapes a property name for a JSON pointer.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
@@ -0,0 +1,121 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const orderSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["sku", "quantity"],
+	"additionalProperties": false,
+	"properties": {
+		"sku": {"type": "string", "pattern": "^[A-Z]{3}-[0-9]+$"},
+		"quantity": {"type": "integer", "minimum": 1, "maximum": 100},
+		"tags": {"type": "array", "items": {"type": "string", "maxLength": 8}, "uniqueItems": true},
+		"shipping": {"enum": ["standard", "express"]},
+		"note": {"type": ["string", "null"]}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(orderSchema))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	tests := []struct {
+		doc     string
+		wantErr string
+	}{
+		{doc: `{"sku":"ABC-1","quantity":2,"tags":["a","b"],"shipping":"express","note":null}`},
+		{doc: `{"sku":"ABC-1","quantity":2}`},
+		{doc: `[]`, wantErr: "/: must be object"},
+		{doc: `{"sku":"ABC-1"}`, wantErr: `/: missing required property "quantity"`},
+		{doc: `{"sku":"abc","quantity":2}`, wantErr: "/sku: must match the pattern"},
+		{doc: `{"sku":"ABC-1","quantity":2.5}`, wantErr: "/quantity: must be integer"},
+		{doc: `{"sku":"ABC-1","quantity":0}`, wantErr: "/quantity: must be at least 1"},
+		{doc: `{"sku":"ABC-1","quantity":2,"tags":["a","a"]}`, wantErr: "/tags: items 0 and 1 are equal"},
+		{doc: `{"sku":"ABC-1","quantity":2,"tags":["toolongtag"]}`, wantErr: "/tags/0: must be at most 8 characters long"},
+		{doc: `{"sku":"ABC-1","quantity":2,"shipping":"drone"}`, wantErr: "/shipping: must be one of the enumerated values"},
+		{doc: `{"sku":"ABC-1","quantity":2,"gift":true}`, wantErr: `/: property "gift" is not allowed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc, func(t *testing.T) {
+			var doc any
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatalf("bad test document: %v", err)
+			}
+			err := schema.Validate(doc)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	schema, err := Compile([]byte(`{"oneOf":[{"type":"integer"},{"type":"number","multipleOf":0.5}],"not":{"const":0}}`))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	for doc, valid := range map[string]bool{`1.5`: true, `0.25`: false, `2`: false, `0`: false, `"x"`: false} {
+		var v any
+		json.Unmarshal([]byte(doc), &v)
+		if err := schema.Validate(v); (err == nil) != valid {
+			t.Errorf("Validate(%s) error = %v, want valid %v", doc, err, valid)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]string{
+		`{"type":"object"`: "schema is not JSON",
+		`"object"`:         "must be an object or a boolean",
+		`{"properties":{"a":{"$ref":"#/$defs/a"}}}`: "/properties/a/$ref: unsupported keyword",
+		`{"type":"decimal"}`:                        `unknown type "decimal"`,
+		`{"minLength":-1}`:                          "/minLength: must be a non-negative integer",
+		`{"pattern":"("}`:                           "/pattern:",
+		`{"anyOf":[]}`:                              "/anyOf: must be a non-empty array",
+	}
+	for schema, wantErr := range tests {
+		if _, err := Compile([]byte(schema)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Compile(%s) error = %v, want %q", schema, err, wantErr)
+		}
+	}
+}
+
+func TestSchemaJSON(t *testing.T) {
+	s, err := Compile([]byte(orderSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(struct{ Schema *Schema }{s})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded struct{ Schema *Schema }
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for doc, wantValid := range map[string]bool{
+		`{"sku":"ABC-1","quantity":2}`:   true,
+		`{"sku":"ABC-1","quantity":200}`: false,
+	} {
+		var v any
+		json.Unmarshal([]byte(doc), &v)
+		if err := decoded.Schema.Validate(v); (err == nil) != wantValid {
+			t.Errorf("decoded schema Validate(%s) error = %v, want valid %v", doc, err, wantValid)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"Schema":{"type":"decimal"}}`), &decoded); err == nil {
+		t.Error("Unmarshal() of an invalid schema succeeded")
+	}
+}
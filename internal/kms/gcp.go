@@ -0,0 +1,213 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/signer"
+)
+
+// gcpKMSEndpoint is the Cloud KMS API; replaced in tests.
+var gcpKMSEndpoint = "https://cloudkms.googleapis.com/v1/"
+
+// gcpHTTPClient calls Cloud KMS and the metadata server.
+var gcpHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// openGCPKMS opens gcpkms://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>,
+// an EC_SIGN_ED25519 key, optionally pinned to one .../cryptoKeyVersions/<n>.
+// Calls are authorized with the pod's service account token from the
+// metadata server, as provided by GKE Workload Identity.
+func openGCPKMS(ctx context.Context, uri string) (signer.Signer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.Trim(u.Host+u.Path, "/")
+	parts := strings.Split(name, "/")
+	if (len(parts) != 8 && len(parts) != 10) || parts[0] != "projects" || parts[6] != "cryptoKeys" {
+		return nil, fmt.Errorf("key URI %q: want %s://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>", uri, SchemeGCPKMS)
+	}
+	k := &gcpKey{name: strings.Join(parts[:8], "/"), token: &metadataToken{}}
+	if len(parts) == 10 {
+		k.pinned = name
+	}
+	return openRing(ctx, k.list)
+}
+
+// gcpKey is a Cloud KMS crypto key.
+type gcpKey struct {
+	name   string // projects/.../cryptoKeys/<k>
+	pinned string // version resource name when the URI names one
+	token  *metadataToken
+}
+
+// list reads the enabled Ed25519 versions of the key, or its pinned version.
+// The highest version is active. Versions are identified as "<k>-v<n>".
+func (k *gcpKey) list(ctx context.Context, cached map[string]*version) (string, map[string]*version, error) {
+	names := []string{k.pinned}
+	if k.pinned == "" {
+		var listed struct {
+			CryptoKeyVersions []struct {
+				Name      string `json:"name"`
+				Algorithm string `json:"algorithm"`
+			} `json:"cryptoKeyVersions"`
+		}
+		if err := k.call(ctx, http.MethodGet, k.name+"/cryptoKeyVersions?filter=state%3DENABLED&pageSize=1000", nil, &listed); err != nil {
+			return "", nil, err
+		}
+		names = names[:0]
+		for _, v := range listed.CryptoKeyVersions {
+			if v.Algorithm == "EC_SIGN_ED25519" {
+				names = append(names, v.Name)
+			}
+		}
+	}
+
+	var active string
+	activeNum := -1
+	versions := make(map[string]*version)
+	for _, name := range names {
+		num, _ := strconv.Atoi(path.Base(name))
+		id := fmt.Sprintf("%s-v%d", path.Base(k.name), num)
+		if num > activeNum {
+			active, activeNum = id, num
+		}
+		if v, ok := cached[id]; ok {
+			versions[id] = v
+			continue
+		}
+		public, err := k.publicKey(ctx, id, name)
+		if err != nil {
+			return "", nil, err
+		}
+		versions[id] = &version{public: public, sign: k.signWith(name)}
+	}
+	return active, versions, nil
+}
+
+// publicKey fetches the public key of a version.
+func (k *gcpKey) publicKey(ctx context.Context, id, name string) (ed25519.PublicKey, error) {
+	var answer struct {
+		PEM string `json:"pem"`
+	}
+	if err := k.call(ctx, http.MethodGet, name+"/publicKey", nil, &answer); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(answer.PEM))
+	if block == nil {
+		return nil, fmt.Errorf("version %s: public key is not PEM", id)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("version %s: %w", id, err)
+	}
+	public, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("version %s: not an Ed25519 public key", id)
+	}
+	return public, nil
+}
+
+// signWith signs with the version named name.
+func (k *gcpKey) signWith(name string) func(ctx context.Context, message []byte) ([]byte, error) {
+	return func(ctx context.Context, message []byte) ([]byte, error) {
+		var signed struct {
+			Signature []byte `json:"signature"` // base64 in JSON
+		}
+		if err := k.call(ctx, http.MethodPost, name+":asymmetricSign", map[string][]byte{"data": message}, &signed); err != nil {
+			return nil, err
+		}
+		return signed.Signature, nil
+	}
+}
+
+// call sends a Cloud KMS request for resource and decodes the answer into out.
+func (k *gcpKey) call(ctx context.Context, method, resource string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, gcpKMSEndpoint+resource, body)
+	if err != nil {
+		return err
+	}
+	token, err := k.token.get(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := gcpHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloud kms %s: %w", resource, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cloud kms %s: status %d: %s", resource, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// metadataToken caches the access token of the pod's service account.
+type metadataToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// get returns the cached token, fetching a new one a minute before it expires.
+func (t *metadataToken) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := gcpHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch GCP access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch GCP access token: status %d", resp.StatusCode)
+	}
+	var answer struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return "", fmt.Errorf("decode GCP access token: %w", err)
+	}
+	t.token = answer.AccessToken
+	t.expires = time.Now().Add(time.Duration(answer.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
@@ -0,0 +1,111 @@
+// Package kms registers signers for keys held in a key management service,
+// so the operator signs without the private key ever entering its pod:
+//
+//	vault-transit://<mount>/<key>                                    HashiCorp Vault transit engine
+//	gcpkms://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>  Google Cloud KMS
+//
+// Keys must be Ed25519. Each is a signer.Ring: it signs with the newest
+// version and lists every enabled version, so rotating the key in the KMS
+// rotates the signing key while tokens signed by older versions still verify.
+// Public keys are cached per version; only signing calls out to the KMS.
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/signer"
+)
+
+// Key URI schemes.
+const (
+	SchemeVaultTransit = "vault-transit"
+	SchemeGCPKMS       = "gcpkms"
+)
+
+func init() {
+	signer.Register(SchemeVaultTransit, openVaultTransit)
+	signer.Register(SchemeGCPKMS, openGCPKMS)
+}
+
+// version is one version of a KMS key. It signs remotely and verifies with
+// its public key.
+type version struct {
+	public ed25519.PublicKey
+	sign   func(ctx context.Context, message []byte) ([]byte, error)
+}
+
+func (v *version) Algorithm() string        { return signer.AlgEdDSA }
+func (v *version) Public() crypto.PublicKey { return v.public }
+
+func (v *version) Sign(ctx context.Context, message []byte) ([]byte, error) {
+	return v.sign(ctx, message)
+}
+
+// lister lists the versions of a key by ID and names the newest. cached holds
+// the versions of the previous listing, whose public keys can be reused.
+type lister func(ctx context.Context, cached map[string]*version) (active string, versions map[string]*version, err error)
+
+// ring is a KMS key: it signs with its active version and caches the listed
+// versions.
+type ring struct {
+	list lister
+
+	mu       sync.Mutex
+	active   string
+	versions map[string]*version
+}
+
+// openRing lists the versions of a key once, so a misconfigured key fails at
+// startup.
+func openRing(ctx context.Context, list lister) (*ring, error) {
+	r := &ring{list: list}
+	if _, _, err := r.Versions(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Versions implements signer.Ring.
+func (r *ring) Versions(ctx context.Context) (string, map[string]signer.Signer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active, versions, err := r.list(ctx, r.versions)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, ok := versions[active]; !ok {
+		return "", nil, errors.New("key has no enabled Ed25519 version")
+	}
+	r.active, r.versions = active, versions
+	signers := make(map[string]signer.Signer, len(versions))
+	for id, v := range versions {
+		signers[id] = v
+	}
+	return active, signers, nil
+}
+
+func (r *ring) activeVersion() *version {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.versions[r.active]
+}
+
+func (r *ring) Algorithm() string        { return signer.AlgEdDSA }
+func (r *ring) Public() crypto.PublicKey { return r.activeVersion().public }
+
+func (r *ring) Sign(ctx context.Context, message []byte) ([]byte, error) {
+	return r.activeVersion().Sign(ctx, message)
+}
+
+// ed25519Public checks that raw is an Ed25519 public key.
+func ed25519Public(id string, raw []byte) (ed25519.PublicKey, error) {
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("version %s: not an Ed25519 public key", id)
+	}
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,250 @@
+package kms
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/backend"
+)
+
+// fakeKey is a KMS key with numbered Ed25519 versions.
+type fakeKey struct {
+	mu       sync.Mutex
+	versions []ed25519.PrivateKey
+	signs    int
+}
+
+func (k *fakeKey) rotate(t *testing.T) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.versions = append(k.versions, private)
+}
+
+func (k *fakeKey) sign(num int, message []byte) ([]byte, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if num < 1 || num > len(k.versions) {
+		return nil, false
+	}
+	k.signs++
+	return ed25519.Sign(k.versions[num-1], message), true
+}
+
+// fakeVaultTransit serves the transit key "transit/ctx".
+func fakeVaultTransit(t *testing.T, key *fakeKey) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/transit/keys/ctx":
+			key.mu.Lock()
+			keys := make(map[string]any)
+			for i, private := range key.versions {
+				keys[strconv.Itoa(i+1)] = map[string]string{"public_key": base64.StdEncoding.EncodeToString(private.Public().(ed25519.PublicKey))}
+			}
+			latest := len(key.versions)
+			key.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+				"type": "ed25519", "latest_version": latest, "min_decryption_version": 1, "keys": keys,
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/transit/sign/ctx":
+			var in struct {
+				Input      string `json:"input"`
+				KeyVersion int    `json:"key_version"`
+			}
+			json.NewDecoder(r.Body).Decode(&in)
+			message, _ := base64.StdEncoding.DecodeString(in.Input)
+			sig, ok := key.sign(in.KeyVersion, message)
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+				"signature": fmt.Sprintf("vault:v%d:%s", in.KeyVersion, base64.StdEncoding.EncodeToString(sig)),
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeCloudKMS serves the metadata token and the crypto key
+// projects/p/locations/l/keyRings/r/cryptoKeys/ctx.
+func fakeCloudKMS(t *testing.T, key *fakeKey) *httptest.Server {
+	const keyName = "projects/p/locations/l/keyRings/r/cryptoKeys/ctx"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token" {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "test-token", "expires_in": 3600})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resource := strings.TrimPrefix(r.URL.Path, "/v1/")
+		switch {
+		case resource == keyName+"/cryptoKeyVersions":
+			key.mu.Lock()
+			var versions []map[string]string
+			for i := range key.versions {
+				versions = append(versions, map[string]string{
+					"name":      fmt.Sprintf("%s/cryptoKeyVersions/%d", keyName, i+1),
+					"algorithm": "EC_SIGN_ED25519",
+				})
+			}
+			key.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]any{"cryptoKeyVersions": versions})
+		case strings.HasSuffix(resource, "/publicKey"):
+			num, _ := strconv.Atoi(strings.TrimSuffix(resource[strings.LastIndex(resource, "/cryptoKeyVersions/")+19:], "/publicKey"))
+			key.mu.Lock()
+			der, _ := x509.MarshalPKIXPublicKey(key.versions[num-1].Public())
+			key.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]string{"pem": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))})
+		case strings.HasSuffix(resource, ":asymmetricSign"):
+			num, _ := strconv.Atoi(strings.TrimSuffix(resource[strings.LastIndex(resource, "/")+1:], ":asymmetricSign"))
+			var in struct {
+				Data []byte `json:"data"`
+			}
+			json.NewDecoder(r.Body).Decode(&in)
+			sig, ok := key.sign(num, in.Data)
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string][]byte{"signature": sig})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestKeyRotation(t *testing.T) {
+	tests := []struct {
+		name  string
+		uri   string
+		serve func(t *testing.T, key *fakeKey)
+	}{
+		{
+			name: "vault transit",
+			uri:  "vault-transit://transit/ctx",
+			serve: func(t *testing.T, key *fakeKey) {
+				srv := fakeVaultTransit(t, key)
+				prev := newVaultClient
+				newVaultClient = func() (*vault.Client, error) {
+					c, err := vault.NewClient(srv.URL)
+					if err == nil {
+						c.Token = "test-token"
+					}
+					return c, err
+				}
+				t.Cleanup(func() { newVaultClient = prev })
+			},
+		},
+		{
+			name: "cloud kms",
+			uri:  "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/ctx",
+			serve: func(t *testing.T, key *fakeKey) {
+				srv := fakeCloudKMS(t, key)
+				t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+				prev := gcpKMSEndpoint
+				gcpKMSEndpoint = srv.URL + "/v1/"
+				t.Cleanup(func() { gcpKMSEndpoint = prev })
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &fakeKey{}
+			key.rotate(t)
+			tt.serve(t, key)
+
+			// A zero refresh re-lists the versions on every use.
+			keys, err := backend.LoadKeyURI(context.Background(), tt.uri, 0)
+			if err != nil {
+				t.Fatalf("LoadKeyURI() error = %v", err)
+			}
+			now := time.Now()
+			claims := backend.Claims{Issuer: backend.Issuer, Payer: "0xPayer", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+			old, err := backend.SignContext(context.Background(), keys, claims)
+			if err != nil {
+				t.Fatalf("SignContext() error = %v", err)
+			}
+			if id, alg, _ := keys.Active(); id != "ctx-v1" || alg != "EdDSA" {
+				t.Errorf("Active() = %q, %q, want ctx-v1, EdDSA", id, alg)
+			}
+
+			key.rotate(t)
+			rotated, err := backend.SignContext(context.Background(), keys, claims)
+			if err != nil {
+				t.Fatalf("SignContext() after rotation error = %v", err)
+			}
+			if id, _, _ := keys.Active(); id != "ctx-v2" {
+				t.Errorf("Active() after rotation = %q, want ctx-v2", id)
+			}
+			for name, token := range map[string]string{"old": old, "rotated": rotated} {
+				if _, err := backend.Verify(keys, token, now); err != nil {
+					t.Errorf("Verify(%s token) error = %v", name, err)
+				}
+			}
+			if set := keys.JWKS(); len(set.Keys) != 2 {
+				t.Errorf("JWKS() has %d keys, want both versions", len(set.Keys))
+			}
+			if key.signs != 2 {
+				t.Errorf("KMS signed %d times, want 2", key.signs)
+			}
+		})
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	tests := []struct {
+		uri     string
+		wantErr string
+	}{
+		{uri: "vault-transit://ctx", wantErr: "want vault-transit://<mount>/<key>"},
+		{uri: "vault-transit://transit/ctx", wantErr: "VAULT_ADDR is not set"},
+		{uri: "gcpkms://projects/p/cryptoKeys/ctx", wantErr: "want gcpkms://projects/"},
+	}
+	for _, tt := range tests {
+		var err error
+		if strings.HasPrefix(tt.uri, SchemeVaultTransit) {
+			_, err = openVaultTransit(context.Background(), tt.uri)
+		} else {
+			_, err = openGCPKMS(context.Background(), tt.uri)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("open(%q) error = %v, want %q", tt.uri, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/vault"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/signer"
+)
+
+// newVaultClient returns the Vault client of transit keys; replaced in tests.
+var newVaultClient = vault.FromEnv
+
+// openVaultTransit opens vault-transit://<mount>/<key>, an ed25519 key of a
+// transit secrets engine, with the Vault client configured by the VAULT_*
+// environment variables.
+func openVaultTransit(ctx context.Context, uri string) (signer.Signer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	full := strings.Trim(u.Host+u.Path, "/")
+	i := strings.LastIndex(full, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("key URI %q: want %s://<mount>/<key>", uri, SchemeVaultTransit)
+	}
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, fmt.Errorf("key URI %q: %w", uri, err)
+	}
+	t := &transitKey{client: client, mount: full[:i], name: full[i+1:]}
+	return openRing(ctx, t.list)
+}
+
+// transitKey is a key of a Vault transit secrets engine.
+type transitKey struct {
+	client *vault.Client
+	mount  string
+	name   string
+}
+
+// list reads the key's versions from at least min_decryption_version up to
+// latest_version. Versions are identified as "<key>-v<n>".
+func (t *transitKey) list(ctx context.Context, cached map[string]*version) (string, map[string]*version, error) {
+	var key struct {
+		Type                 string `json:"type"`
+		LatestVersion        int    `json:"latest_version"`
+		MinDecryptionVersion int    `json:"min_decryption_version"`
+		Keys                 map[string]struct {
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	}
+	if err := t.client.Read(ctx, t.mount+"/keys/"+t.name, &key); err != nil {
+		return "", nil, err
+	}
+	if key.Type != "ed25519" {
+		return "", nil, fmt.Errorf("transit key %s/%s is %s, want ed25519", t.mount, t.name, key.Type)
+	}
+
+	versions := make(map[string]*version)
+	for n, k := range key.Keys {
+		num, err := strconv.Atoi(n)
+		if err != nil || num < key.MinDecryptionVersion {
+			continue
+		}
+		id := fmt.Sprintf("%s-v%d", t.name, num)
+		if v, ok := cached[id]; ok {
+			versions[id] = v
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(k.PublicKey)
+		if err != nil {
+			return "", nil, fmt.Errorf("version %s: %w", id, err)
+		}
+		public, err := ed25519Public(id, raw)
+		if err != nil {
+			return "", nil, err
+		}
+		versions[id] = &version{public: public, sign: t.signWith(num)}
+	}
+	return fmt.Sprintf("%s-v%d", t.name, key.LatestVersion), versions, nil
+}
+
+// signWith signs with version num of the key.
+func (t *transitKey) signWith(num int) func(ctx context.Context, message []byte) ([]byte, error) {
+	return func(ctx context.Context, message []byte) ([]byte, error) {
+		var signed struct {
+			Signature string `json:"signature"`
+		}
+		err := t.client.Write(ctx, t.mount+"/sign/"+t.name, map[string]any{
+			"input":       base64.StdEncoding.EncodeToString(message),
+			"key_version": num,
+		}, &signed)
+		if err != nil {
+			return nil, err
+		}
+		// Signatures read "vault:v<n>:<base64>".
+		encoded := signed.Signature[strings.LastIndex(signed.Signature, ":")+1:]
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+}
@@ -0,0 +1,120 @@
+// Package logging builds the slog handler of the gateway and its background
+// components: a level of their own, sampling of routine records and
+// redaction of payment headers and wallet addresses. The controller logs
+// through controller-runtime and is configured with the --zap-* flags.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+)
+
+// redacted replaces the value of sensitive attributes.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged, compared
+// case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"payment-signature": true,
+	"x-payment":         true,
+	"paymentheader":     true,
+	"authorization":     true,
+	"signature":         true,
+}
+
+// Options configures the handler.
+type Options struct {
+	Level slog.Level
+	// SampleRate is the fraction of records below WARN that are written, in
+	// [0, 1]. Warnings and errors are always written.
+	SampleRate float64
+	// Redact removes payment headers.
+	Redact bool
+	// Addresses rewrites the wallet addresses in string and error values;
+	// nil keeps them. See the privacy package.
+	Addresses func(string) string
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, want debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// Validate checks the options.
+func (o Options) Validate() error {
+	if o.SampleRate < 0 || o.SampleRate > 1 {
+		return fmt.Errorf("invalid log sample rate %v, want a value between 0 and 1", o.SampleRate)
+	}
+	return nil
+}
+
+// NewHandler returns a JSON handler writing to w.
+func NewHandler(w io.Writer, opts Options) slog.Handler {
+	hopts := &slog.HandlerOptions{Level: opts.Level}
+	if opts.Redact || opts.Addresses != nil {
+		hopts.ReplaceAttr = opts.redactAttr
+	}
+	var h slog.Handler = slog.NewJSONHandler(w, hopts)
+	if opts.SampleRate < 1 {
+		h = &samplingHandler{Handler: h, rate: opts.SampleRate, sample: rand.Float64}
+	}
+	return h
+}
+
+// redactAttr hides sensitive attributes and rewrites wallet addresses in
+// string and error values.
+func (o Options) redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if o.Redact && sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	if o.Addresses == nil {
+		return a
+	}
+	var s string
+	switch v := a.Value; v.Kind() {
+	case slog.KindString:
+		s = v.String()
+	case slog.KindAny:
+		err, ok := v.Any().(error)
+		if !ok {
+			return a
+		}
+		s = err.Error()
+	default:
+		return a
+	}
+	if out := o.Addresses(s); out != s {
+		return slog.String(a.Key, out)
+	}
+	return a
+}
+
+// samplingHandler drops a share of the records below WARN.
+type samplingHandler struct {
+	slog.Handler
+	rate   float64
+	sample func() float64
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn && h.sample() >= h.rate {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), rate: h.rate, sample: h.sample}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), rate: h.rate, sample: h.sample}
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/privacy"
+)
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	truncate, _ := privacy.New(privacy.ModeTruncate, nil)
+	logger := slog.New(NewHandler(&buf, Options{Level: slog.LevelInfo, SampleRate: 1, Redact: true, Addresses: truncate.Text}))
+	tx := "0x" + strings.Repeat("ab", 32)
+	logger.Info("settled",
+		"Payment-Signature", "eyJ4NDAy",
+		"payer", "0x1234567890abcdef1234567890abcdef12345678",
+		"transaction", tx,
+		"error", errors.New("insufficient funds for 0xAbCdEf0000000000000000000000000000009876"),
+	)
+
+	var got map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	want := map[string]string{
+		"Payment-Signature": redacted,
+		"payer":             "0x1234…5678",
+		"transaction":       tx,
+		"error":             "insufficient funds for 0xAbCd…9876",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandler(&buf, Options{Level: slog.LevelDebug, SampleRate: 0.25})
+	draws := []float64{0.1, 0.5, 0.9, 0.2}
+	h.(*samplingHandler).sample = func() float64 {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+	logger := slog.New(h).With("component", "gateway")
+	for range 4 {
+		logger.Info("request")
+	}
+	logger.Warn("backend request failed")
+	logger.Error("settlement failed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("wrote %d records, want 2 sampled infos, the warning and the error:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[2], `"level":"WARN"`) || !strings.Contains(lines[3], `"component":"gateway"`) {
+		t.Errorf("records = %q", lines)
+	}
+}
+
+func TestParseLevelAndValidate(t *testing.T) {
+	if level, err := ParseLevel("warn"); err != nil || level != slog.LevelWarn {
+		t.Errorf("ParseLevel(warn) = %v, %v", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) succeeded")
+	}
+	for _, rate := range []float64{-0.1, 1.5} {
+		if err := (Options{SampleRate: rate}).Validate(); err == nil {
+			t.Errorf("Validate() with sample rate %v succeeded", rate)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// metricsPath is the path of the controller-runtime metrics endpoint.
+const metricsPath = "/metrics"
+
+// exemplars reports whether request durations carry trace exemplars.
+var exemplars atomic.Bool
+
+// EnableExemplars makes ObserveDuration attach the trace of a request to its
+// observation. Call before serving.
+func EnableExemplars(enabled bool) {
+	exemplars.Store(enabled)
+}
+
+// ObserveDuration records seconds, the duration of work done for r, in h.
+// With exemplars enabled and a sampled W3C trace context on r, the
+// observation carries the trace ID as exemplar, so a latency spike links to
+// the trace of a request that caused it.
+func ObserveDuration(h prometheus.Histogram, r *http.Request, seconds float64) {
+	if exemplars.Load() {
+		if traceID := sampledTraceID(r.Header.Get("traceparent")); traceID != "" {
+			if eo, ok := h.(prometheus.ExemplarObserver); ok {
+				eo.ObserveWithExemplar(seconds, prometheus.Labels{"trace_id": traceID})
+				return
+			}
+		}
+	}
+	h.Observe(seconds)
+}
+
+// sampledTraceID returns the trace ID of a traceparent header
+// ("00-<trace-id>-<parent-id>-<flags>") whose sampled flag is set, or "" for
+// a malformed or unsampled one: unsampled traces are not recorded, so there
+// is nothing to link to.
+func sampledTraceID(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || !isHex(parts[0], 2) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return ""
+	}
+	traceID, parentID, flags := parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(flags, 2) ||
+		strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return ""
+	}
+	if f, _ := strconv.ParseUint(flags, 16, 8); f&1 == 0 {
+		return ""
+	}
+	return traceID
+}
+
+// isHex reports whether s is n lower-case hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// OpenMetricsFilter is a metrics server FilterProvider that serves the
+// metrics endpoint in the OpenMetrics format to scrapers that ask for it,
+// the only format that carries exemplars. Other scrapers and the extra
+// handlers of the metrics server are served as before.
+func OpenMetricsFilter(*rest.Config, *http.Client) (metricsserver.Filter, error) {
+	openMetrics := promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
+		ErrorHandling:     promhttp.HTTPErrorOnError,
+		EnableOpenMetrics: true,
+	})
+	return func(_ logr.Logger, handler http.Handler) (http.Handler, error) {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == metricsPath {
+				openMetrics.ServeHTTP(w, r)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		}), nil
+	}, nil
+}
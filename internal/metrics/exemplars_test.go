@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func TestSampledTraceID(t *testing.T) {
+	tests := map[string]string{
+		"00-" + traceID + "-00f067aa0ba902b7-01":                  traceID,
+		"00-" + traceID + "-00f067aa0ba902b7-03":                  traceID,
+		"00-" + traceID + "-00f067aa0ba902b7-00":                  "",
+		"01-" + traceID + "-00f067aa0ba902b7-01-x":                traceID,
+		"00-" + traceID + "-00f067aa0ba902b7-01-x":                "",
+		"ff-" + traceID + "-00f067aa0ba902b7-01":                  "",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": "",
+		"00-" + traceID + "-0000000000000000-01":                  "",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01": "",
+		"": "",
+	}
+	for header, want := range tests {
+		if got := sampledTraceID(header); got != want {
+			t.Errorf("sampledTraceID(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestObserveDuration(t *testing.T) {
+	defer EnableExemplars(false)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	for _, enabled := range []bool{false, true} {
+		EnableExemplars(enabled)
+		h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_duration_seconds", Buckets: []float64{1}})
+		ObserveDuration(h, r, 0.5)
+		var m dto.Metric
+		if err := h.Write(&m); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		exemplar := m.GetHistogram().GetBucket()[0].GetExemplar()
+		switch {
+		case m.GetHistogram().GetSampleCount() != 1:
+			t.Errorf("sample count = %d, want 1", m.GetHistogram().GetSampleCount())
+		case !enabled && exemplar != nil:
+			t.Errorf("exemplar = %v with exemplars disabled", exemplar)
+		case enabled && (exemplar == nil || exemplar.GetLabel()[0].GetValue() != traceID):
+			t.Errorf("exemplar = %v, want trace_id %s", exemplar, traceID)
+		}
+	}
+}
+
+func TestOpenMetricsFilter(t *testing.T) {
+	filter, err := OpenMetricsFilter(nil, nil)
+	if err != nil {
+		t.Fatalf("OpenMetricsFilter() error = %v", err)
+	}
+	extra := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("extra")) })
+	handler, err := filter(logr.Discard(), extra)
+	if err != nil {
+		t.Fatalf("filter() error = %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Content-Type = %q, want OpenMetrics", ct)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/x402/reputation", nil))
+	if w.Body.String() != "extra" {
+		t.Errorf("extra handler body = %q, want it served unchanged", w.Body)
+	}
+}
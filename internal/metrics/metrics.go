@@ -19,7 +19,7 @@ var (
 			Name: "x402_payment_amount_total",
 			Help: "Total payment amounts processed",
 		},
-		[]string{"path", "wallet", "network"},
+		[]string{"path", "wallet", "network", "sandbox"},
 	)
 
 	PaymentVerificationDuration = prometheus.NewHistogram(
@@ -45,12 +45,265 @@ var (
 		},
 	)
 
+	PaymentRequiredCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_payment_required_cache_total",
+			Help: "Lookups of the serialized 402 response cache by result",
+		},
+		[]string{"result"},
+	)
+
+	CompileCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_compile_cache_total",
+			Help: "Reconciles that reused the compiled route (hit) or compiled it (miss)",
+		},
+		[]string{"result"},
+	)
+
+	BuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "x402_build_info",
+			Help: "Build information of the operator binary; always 1",
+		},
+		[]string{"version", "commit", "build_date", "go_version"},
+	)
+
+	FacilitatorFailOpenTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_facilitator_fail_open_total",
+			Help: "Paid requests served without payment because the facilitator was unavailable",
+		},
+		[]string{"namespace", "route_name", "behavior"},
+	)
+
+	FacilitatorFailoversTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_facilitator_failovers_total",
+			Help: "Payments handed to a route's next facilitator, by the host of the facilitator that failed",
+		},
+		[]string{"facilitator"},
+	)
+
+	PaymentBudgetViolationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_payment_budget_violations_total",
+			Help: "Paid requests answered 402 because verification ran over the route's payment latency budget, by facilitator host and the call that ran out",
+		},
+		[]string{"facilitator", "stage"},
+	)
+
+	ExemptedRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_exempted_requests_total",
+			Help: "Requests forwarded without payment by spec.exemptions, by the exemption that matched",
+		},
+		[]string{"namespace", "route_name", "exemption"},
+	)
+
+	SettlementsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_settlements_total",
+			Help: "Payment settlements by route, settle mode and result",
+		},
+		[]string{"namespace", "route_name", "mode", "result", "sandbox"},
+	)
+
+	AbandonedRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_abandoned_requests_total",
+			Help: "Verified paid requests whose client disconnected before settlement, by settle mode and whether they were settled or skipped",
+		},
+		[]string{"namespace", "route_name", "mode", "action"},
+	)
+
+	SettlementCallbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_settlement_callbacks_total",
+			Help: "Settlement result callbacks to client-provided URLs by result",
+		},
+		[]string{"result"},
+	)
+
+	SettlementExportRecordsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_settlement_export_records_total",
+			Help: "Settlement records exported to object storage by result",
+		},
+		[]string{"result"},
+	)
+
+	BillingRecordsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_billing_records_total",
+			Help: "Settlements mirrored to the billing provider by result",
+		},
+		[]string{"result"},
+	)
+
+	CloudEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_cloudevents_total",
+			Help: "X402Route lifecycle CloudEvents sent to the sink by result",
+		},
+		[]string{"result"},
+	)
+
+	MirrorRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_mirror_requests_total",
+			Help: "Paid requests copied to a mirror backend by result",
+		},
+		[]string{"result"},
+	)
+
+	ExchangeRateAgeSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "x402_exchange_rate_age_seconds",
+			Help: "Age of the exchange rate last used to convert a fiat price",
+		},
+		[]string{"currency", "asset"},
+	)
+
+	ExchangeRateFetchErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_exchange_rate_fetch_errors_total",
+			Help: "Failed exchange rate fetches from the rate provider",
+		},
+		[]string{"currency", "asset"},
+	)
+
+	AsyncJobs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "x402_async_jobs",
+			Help: "Async jobs the gateway replica is running, by state",
+		},
+		[]string{"state"},
+	)
+
+	PausedRoutes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "x402_route_paused",
+			Help: "1 for each X402Route frozen by the x402.io/paused annotation",
+		},
+		[]string{"namespace", "route_name"},
+	)
+
+	ProbeSucceeded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "x402_probe_succeeded",
+			Help: "1 if the last synthetic paid request through the gateway succeeded, 0 if it failed",
+		},
+		[]string{"namespace", "route_name"},
+	)
+
+	ExperimentRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_experiment_requests_total",
+			Help: "Requests to rules with price experiments by variant and result: payment_required (402 issued) or paid (settled)",
+		},
+		[]string{"namespace", "route_name", "path", "variant", "result"},
+	)
+
+	ExperimentRevenueTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_experiment_revenue_total",
+			Help: "Amounts settled on rules with price experiments by variant, in tokens",
+		},
+		[]string{"namespace", "route_name", "path", "variant"},
+	)
+
+	PayerReputationEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_payer_reputation_events_total",
+			Help: "Failed payments that raised a payer's reputation score, by event: invalid, replay or unsettled",
+		},
+		[]string{"event"},
+	)
+
+	QueueTokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_queue_tokens_total",
+			Help: "Waiting room queue tokens by event: issued, redeemed, or invalid (forged, expired, early or reused)",
+		},
+		[]string{"namespace", "route_name", "event"},
+	)
+
+	TrustedVerificationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_trusted_verifications_total",
+			Help: "Payment verifications attested by a trusted upstream tier, by result: accepted (facilitator /verify skipped) or rejected (verified with the facilitator instead)",
+		},
+		[]string{"namespace", "route_name", "result"},
+	)
+
+	RejectedConnectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_gateway_rejected_connections_total",
+			Help: "Connections closed by the gateway as soon as they were accepted, by reason",
+		},
+		[]string{"reason"},
+	)
+
 	RouteStoreUpdatesTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "x402_route_store_updates_total",
 			Help: "Total number of route store updates",
 		},
 	)
+
+	ReconcilesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_controller_reconciles_total",
+			Help: "X402Route reconciles by result: success, waiting (on a missing dependency) or error",
+		},
+		[]string{"result"},
+	)
+
+	IngressPatchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_controller_ingress_patches_total",
+			Help: "Ingress updates routing paid paths to the gateway, by result: applied (the Ingress changed) or failed",
+		},
+		[]string{"result"},
+	)
+
+	IngressRestoresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_controller_ingress_restores_total",
+			Help: "Ingress restorations of deleted X402Routes by result: restored, failed, or skipped after repeated failures",
+		},
+		[]string{"result"},
+	)
+
+	CleanupFailuresTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "x402_controller_cleanup_failures_total",
+			Help: "Failed finalizer cleanups of deleted X402Routes; each is retried",
+		},
+	)
+
+	ExternalServices = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "x402_controller_external_services",
+			Help: "ExternalName Services the operator manages to route Ingress namespaces to the gateway",
+		},
+	)
+
+	RouteSyncDataPlanes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "x402_route_sync_data_planes",
+			Help: "Remote gateways streaming routes from this control plane",
+		},
+	)
+
+	RouteSyncUpdatesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "x402_route_sync_updates_total",
+			Help: "Route updates pushed to remote gateways, by their answer (acked, nacked); on a remote gateway, by outcome (applied, rejected)",
+		},
+		[]string{"result"},
+	)
 )
 
 func init() {
@@ -61,5 +314,37 @@ func init() {
 		ProxyRequestDuration,
 		ActiveRoutes,
 		RouteStoreUpdatesTotal,
+		PaymentRequiredCacheTotal,
+		CompileCacheTotal,
+		BuildInfo,
+		FacilitatorFailOpenTotal,
+		FacilitatorFailoversTotal,
+		PaymentBudgetViolationsTotal,
+		ExemptedRequestsTotal,
+		SettlementsTotal,
+		AbandonedRequestsTotal,
+		SettlementCallbacksTotal,
+		SettlementExportRecordsTotal,
+		BillingRecordsTotal,
+		CloudEventsTotal,
+		MirrorRequestsTotal,
+		ExchangeRateAgeSeconds,
+		ExchangeRateFetchErrorsTotal,
+		AsyncJobs,
+		ProbeSucceeded,
+		PausedRoutes,
+		ExperimentRequestsTotal,
+		ExperimentRevenueTotal,
+		PayerReputationEventsTotal,
+		QueueTokensTotal,
+		TrustedVerificationsTotal,
+		RejectedConnectionsTotal,
+		ReconcilesTotal,
+		IngressPatchesTotal,
+		IngressRestoresTotal,
+		CleanupFailuresTotal,
+		ExternalServices,
+		RouteSyncDataPlanes,
+		RouteSyncUpdatesTotal,
 	)
 }
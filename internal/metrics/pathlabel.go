@@ -0,0 +1,62 @@
+package metrics
+
+import "sync"
+
+// DefaultMaxPathLabels bounds the distinct values of the path label.
+const DefaultMaxPathLabels = 500
+
+// OtherPathLabel is the path label of requests matching no rule, and of new
+// paths once the label has reached its bound.
+const OtherPathLabel = "other"
+
+// pathLabels tracks the values handed out for the path label of
+// x402_requests_total and x402_payment_amount_total.
+var pathLabels = &pathLabelSet{max: DefaultMaxPathLabels, seen: map[string]struct{}{}}
+
+type pathLabelSet struct {
+	mu   sync.RWMutex
+	raw  bool
+	max  int
+	seen map[string]struct{}
+}
+
+// ConfigurePathLabels sets whether the path label carries the raw request
+// path instead of the matched rule pattern, and how many distinct values it
+// takes before new ones are counted as "other"; max <= 0 removes the bound.
+// Call before serving.
+func ConfigurePathLabels(raw bool, max int) {
+	pathLabels.mu.Lock()
+	defer pathLabels.mu.Unlock()
+	pathLabels.raw, pathLabels.max = raw, max
+	clear(pathLabels.seen)
+}
+
+// PathLabel returns the path label of a request for path that matched the
+// rule pattern, or no rule when pattern is empty.
+func PathLabel(pattern, path string) string {
+	s := pathLabels
+	s.mu.RLock()
+	value := pattern
+	if s.raw {
+		value = path
+	}
+	_, seen := s.seen[value]
+	s.mu.RUnlock()
+	if value == "" {
+		return OtherPathLabel
+	}
+	if seen {
+		return value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[value]; ok {
+		return value
+	}
+	if s.max > 0 && len(s.seen) >= s.max {
+		return OtherPathLabel
+	}
+	s.seen[value] = struct{}{}
+	return value
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import "testing"
+
+func TestPathLabel(t *testing.T) {
+	defer ConfigurePathLabels(false, DefaultMaxPathLabels)
+
+	tests := []struct {
+		name    string
+		raw     bool
+		max     int
+		pattern string
+		paths   []string
+		want    []string
+	}{
+		{
+			name: "rule pattern", max: 10, pattern: "/api/users/*",
+			paths: []string{"/api/users/1", "/api/users/2"},
+			want:  []string{"/api/users/*", "/api/users/*"},
+		},
+		{
+			name: "no rule", max: 10,
+			paths: []string{"/unknown"},
+			want:  []string{OtherPathLabel},
+		},
+		{
+			name: "raw paths capped", raw: true, max: 2, pattern: "/api/users/*",
+			paths: []string{"/api/users/1", "/api/users/2", "/api/users/3", "/api/users/1"},
+			want:  []string{"/api/users/1", "/api/users/2", OtherPathLabel, "/api/users/1"},
+		},
+		{
+			name: "uncapped", raw: true, max: 0,
+			paths: []string{"/a", "/b", "/c"},
+			want:  []string{"/a", "/b", "/c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigurePathLabels(tt.raw, tt.max)
+			for i, path := range tt.paths {
+				if got := PathLabel(tt.pattern, path); got != tt.want[i] {
+					t.Errorf("PathLabel(%q, %q) = %q, want %q", tt.pattern, path, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Package privacy hides payer and wallet addresses in logs, metric labels and
+// events. Addresses are either truncated, which keeps them recognizable to
+// their owner, or replaced by a keyed hash, which keeps them correlatable
+// across records without revealing them. Settlement exports and billing
+// records keep full addresses.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+)
+
+// Modes of a Redactor.
+const (
+	ModeOff      = "off"
+	ModeTruncate = "truncate"
+	ModeHash     = "hash"
+)
+
+// hashPrefix marks hashed addresses so they are not mistaken for real ones.
+const hashPrefix = "h:"
+
+// addressPattern matches EVM addresses. Longer hex strings, such as
+// transaction hashes, are left alone.
+var addressPattern = regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`)
+
+// Redactor rewrites addresses. A nil Redactor leaves them unchanged.
+type Redactor struct {
+	mode string
+	salt []byte
+}
+
+// New returns a redactor for mode. Hash mode requires a salt, which must be
+// the same on every replica for hashes to correlate.
+func New(mode string, salt []byte) (*Redactor, error) {
+	switch mode {
+	case ModeOff, "":
+		return nil, nil
+	case ModeTruncate:
+		return &Redactor{mode: mode}, nil
+	case ModeHash:
+		if len(salt) == 0 {
+			return nil, fmt.Errorf("privacy mode %q requires a salt", mode)
+		}
+		return &Redactor{mode: mode, salt: salt}, nil
+	}
+	return nil, fmt.Errorf("invalid privacy mode %q, want off, truncate or hash", mode)
+}
+
+// LoadSalt reads a salt from file, typically a mounted Secret key.
+func LoadSalt(file string) ([]byte, error) {
+	salt, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read privacy salt: %w", err)
+	}
+	salt = []byte(strings.TrimSpace(string(salt)))
+	if len(salt) < 16 {
+		return nil, fmt.Errorf("privacy salt in %s is shorter than 16 bytes", file)
+	}
+	return salt, nil
+}
+
+// Address rewrites a single address or account. Truncation keeps the first
+// and last four characters; values too short to truncate are kept.
+func (r *Redactor) Address(addr string) string {
+	if r == nil || addr == "" {
+		return addr
+	}
+	if r.mode == ModeHash {
+		mac := hmac.New(sha256.New, r.salt)
+		mac.Write([]byte(strings.ToLower(addr)))
+		return hashPrefix + hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+	if len(addr) <= 12 {
+		return addr
+	}
+	return addr[:6] + "…" + addr[len(addr)-4:]
+}
+
+// Text rewrites every EVM address in s.
+func (r *Redactor) Text(s string) string {
+	if r == nil || !addressPattern.MatchString(s) {
+		return s
+	}
+	return addressPattern.ReplaceAllStringFunc(s, r.Address)
+}
+
+// defaultRedactor is used by Address; set once at startup.
+var defaultRedactor *Redactor
+
+// SetDefault sets the redactor used by Address.
+func SetDefault(r *Redactor) {
+	defaultRedactor = r
+}
+
+// Address rewrites addr with the default redactor, for metric labels.
+func Address(addr string) string {
+	return defaultRedactor.Address(addr)
+}
+
+// NewEventRecorder returns a recorder that rewrites the addresses in event
+// notes. It returns rec itself for a nil redactor.
+func NewEventRecorder(rec events.EventRecorder, r *Redactor) events.EventRecorder {
+	if r == nil {
+		return rec
+	}
+	return &eventRecorder{EventRecorder: rec, redactor: r}
+}
+
+type eventRecorder struct {
+	events.EventRecorder
+	redactor *Redactor
+}
+
+func (e *eventRecorder) Eventf(regarding, related runtime.Object, eventtype, reason, action, note string, args ...interface{}) {
+	if len(args) > 0 {
+		note = fmt.Sprintf(note, args...)
+	}
+	e.EventRecorder.Eventf(regarding, related, eventtype, reason, action, "%s", e.redactor.Text(note))
+}
@@ -0,0 +1,98 @@
+package privacy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/events"
+)
+
+const payer = "0x1234567890abcdef1234567890abcdef12345678"
+
+func TestAddress(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	truncate, _ := New(ModeTruncate, nil)
+	hash, _ := New(ModeHash, salt)
+	other, _ := New(ModeHash, []byte("fedcba9876543210"))
+
+	if got := (*Redactor)(nil).Address(payer); got != payer {
+		t.Errorf("off: Address() = %q", got)
+	}
+	if got := truncate.Address(payer); got != "0x1234…5678" {
+		t.Errorf("truncate: Address() = %q", got)
+	}
+	hashed := hash.Address(payer)
+	if !strings.HasPrefix(hashed, hashPrefix) || len(hashed) != len(hashPrefix)+16 || strings.Contains(hashed, "1234") {
+		t.Errorf("hash: Address() = %q", hashed)
+	}
+	if got := hash.Address("0x" + strings.ToUpper(payer[2:])); got != hashed {
+		t.Errorf("hash: checksummed address = %q, want %q", got, hashed)
+	}
+	if other.Address(payer) == hashed {
+		t.Error("hash: different salts gave the same hash")
+	}
+}
+
+func TestText(t *testing.T) {
+	truncate, _ := New(ModeTruncate, nil)
+	tx := "0x" + strings.Repeat("ab", 32)
+	s := "payer " + payer + " paid in " + tx
+	if got, want := truncate.Text(s), "payer 0x1234…5678 paid in "+tx; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		mode    string
+		salt    []byte
+		wantNil bool
+		wantErr bool
+	}{
+		{mode: ModeOff, wantNil: true},
+		{mode: "", wantNil: true},
+		{mode: ModeTruncate},
+		{mode: ModeHash, salt: []byte("0123456789abcdef")},
+		{mode: ModeHash, wantErr: true},
+		{mode: "mask", wantErr: true},
+	}
+	for _, tt := range tests {
+		r, err := New(tt.mode, tt.salt)
+		if (err != nil) != tt.wantErr || (err == nil && (r == nil) != tt.wantNil) {
+			t.Errorf("New(%q) = %v, %v", tt.mode, r, err)
+		}
+	}
+}
+
+func TestLoadSalt(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "salt")
+	short := filepath.Join(dir, "short")
+	os.WriteFile(good, []byte("0123456789abcdef\n"), 0o600)
+	os.WriteFile(short, []byte("salt"), 0o600)
+
+	if salt, err := LoadSalt(good); err != nil || string(salt) != "0123456789abcdef" {
+		t.Errorf("LoadSalt() = %q, %v", salt, err)
+	}
+	for _, file := range []string{short, filepath.Join(dir, "missing")} {
+		if _, err := LoadSalt(file); err == nil {
+			t.Errorf("LoadSalt(%s) succeeded", filepath.Base(file))
+		}
+	}
+}
+
+func TestEventRecorder(t *testing.T) {
+	fake := events.NewFakeRecorder(1)
+	truncate, _ := New(ModeTruncate, nil)
+	rec := NewEventRecorder(fake, truncate)
+	rec.Eventf(&corev1.Pod{}, nil, corev1.EventTypeWarning, "FleetPricingConflict", "Compare", "wallet %s differs", payer)
+	if got := <-fake.Events; !strings.HasSuffix(got, "wallet 0x1234…5678 differs") {
+		t.Errorf("event = %q", got)
+	}
+	if NewEventRecorder(fake, nil) != events.EventRecorder(fake) {
+		t.Error("NewEventRecorder() wrapped the recorder with privacy off")
+	}
+}
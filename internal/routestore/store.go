@@ -1,18 +1,31 @@
 package routestore
 
-import "sync"
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
 
 // Store is a thread-safe in-memory route store shared between the controller and gateway.
+//
+// Writers rebuild an immutable view of all routes and swap it in atomically, so
+// readers never take a lock or allocate. Components that act on changes
+// subscribe to them with Subscribe rather than polling Snapshot.
 type Store struct {
-	mu     sync.RWMutex
-	routes map[string]*CompiledRoute // key: "namespace/name"
+	mu       sync.Mutex                // serializes writers
+	routes   map[string]*CompiledRoute // key: "namespace/name"
+	view     atomic.Pointer[[]*CompiledRoute]
+	revision uint64                     // changes made; guarded by mu
+	subs     map[*subscription]struct{} // guarded by mu
 }
 
 // New creates a new empty route store.
 func New() *Store {
-	return &Store{
+	s := &Store{
 		routes: make(map[string]*CompiledRoute),
 	}
+	s.view.Store(&[]*CompiledRoute{})
+	return s
 }
 
 // Set adds or updates a compiled route in the store.
@@ -20,6 +33,8 @@ func (s *Store) Set(namespace, name string, route *CompiledRoute) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.routes[namespace+"/"+name] = route
+	s.publish()
+	s.notify(Event{Type: EventSet, Namespace: namespace, Name: name, Route: route, Revision: s.revision})
 }
 
 // Delete removes a route from the store.
@@ -27,22 +42,42 @@ func (s *Store) Delete(namespace, name string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.routes, namespace+"/"+name)
+	s.publish()
+	s.notify(Event{Type: EventDelete, Namespace: namespace, Name: name, Revision: s.revision})
 }
 
-// Snapshot returns a copy of all routes for safe iteration.
-func (s *Store) Snapshot() []*CompiledRoute {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	result := make([]*CompiledRoute, 0, len(s.routes))
-	for _, r := range s.routes {
-		result = append(result, r)
+// publish rebuilds the read view from the route map, ordered by key so that
+// iteration order is stable between updates, and counts the change. Callers
+// must hold s.mu.
+func (s *Store) publish() {
+	keys := make([]string, 0, len(s.routes))
+	for key := range s.routes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	view := make([]*CompiledRoute, len(keys))
+	for i, key := range keys {
+		view[i] = s.routes[key]
 	}
-	return result
+	s.view.Store(&view)
+	s.revision++
+}
+
+// Get returns the route stored under namespace and name, or nil.
+func (s *Store) Get(namespace, name string) *CompiledRoute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.routes[namespace+"/"+name]
+}
+
+// Snapshot returns the current immutable view of all routes. The slice and the
+// routes it points to are shared between readers and must not be modified.
+func (s *Store) Snapshot() []*CompiledRoute {
+	return *s.view.Load()
 }
 
 // Count returns the number of routes in the store.
 func (s *Store) Count() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.routes)
+	return len(*s.view.Load())
 }
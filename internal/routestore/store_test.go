@@ -0,0 +1,101 @@
+package routestore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestStoreSnapshot(t *testing.T) {
+	s := New()
+	if got := s.Snapshot(); len(got) != 0 {
+		t.Fatalf("empty store snapshot has %d routes", len(got))
+	}
+
+	s.Set("default", "b", &CompiledRoute{Name: "b"})
+	s.Set("default", "a", &CompiledRoute{Name: "a"})
+	before := s.Snapshot()
+
+	s.Set("default", "a", &CompiledRoute{Name: "a", Generation: 2})
+	s.Delete("default", "b")
+
+	if len(before) != 2 || before[0].Name != "a" || before[1].Name != "b" || before[0].Generation != 0 {
+		t.Errorf("earlier snapshot changed after updates: %+v", before)
+	}
+	after := s.Snapshot()
+	if len(after) != 1 || after[0].Generation != 2 {
+		t.Errorf("snapshot after updates = %+v, want route a at generation 2", after)
+	}
+	if s.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", s.Count())
+	}
+	if got := s.Get("default", "a"); got == nil || got.Generation != 2 {
+		t.Errorf("Get(a) = %+v, want route a at generation 2", got)
+	}
+	if got := s.Get("default", "b"); got != nil {
+		t.Errorf("Get(b) = %+v, want nil after delete", got)
+	}
+}
+
+func TestStoreSnapshotAllocations(t *testing.T) {
+	s := New()
+	s.Set("default", "a", &CompiledRoute{Name: "a"})
+
+	if allocs := testing.AllocsPerRun(100, func() { s.Snapshot() }); allocs != 0 {
+		t.Errorf("Snapshot() allocs per run = %v, want 0", allocs)
+	}
+}
+
+// TestStoreConcurrentAccess is meaningful under `go test -race`.
+func TestStoreConcurrentAccess(t *testing.T) {
+	s := New()
+	var wg sync.WaitGroup
+
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				name := fmt.Sprintf("route-%d-%d", w, i%10)
+				s.Set("default", name, &CompiledRoute{Name: name, Generation: int64(i)})
+				if i%3 == 0 {
+					s.Delete("default", name)
+				}
+			}
+		}()
+	}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 500 {
+				for _, route := range s.Snapshot() {
+					if route == nil {
+						t.Error("snapshot contains nil route")
+						return
+					}
+					_ = route.Name
+				}
+				_ = s.Count()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(s.Snapshot()); got != s.Count() {
+		t.Errorf("len(Snapshot()) = %d, Count() = %d", got, s.Count())
+	}
+}
+
+func BenchmarkStoreSnapshot(b *testing.B) {
+	s := New()
+	for i := range 50 {
+		s.Set("default", fmt.Sprintf("route-%d", i), &CompiledRoute{})
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Snapshot()
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package routestore
+
+import "sync"
+
+// maxPendingEvents bounds the events queued for a subscriber that does not
+// keep up. Beyond it, the backlog is replaced by a single EventResync.
+const maxPendingEvents = 1024
+
+// EventType is the kind of a route change.
+type EventType string
+
+const (
+	// EventSet reports a route added or replaced by Set.
+	EventSet EventType = "set"
+	// EventDelete reports a route removed by Delete.
+	EventDelete EventType = "delete"
+	// EventResync asks the subscriber to re-read every route from Snapshot:
+	// it is the first event of a subscription, and replaces the events a
+	// subscriber fell too far behind to receive.
+	EventResync EventType = "resync"
+)
+
+// Event is a change of the store.
+type Event struct {
+	Type      EventType
+	Namespace string         // of the changed route; empty for EventResync
+	Name      string         // of the changed route; empty for EventResync
+	Route     *CompiledRoute // stored by EventSet; nil otherwise. Must not be modified.
+	Revision  uint64         // of the store once the change was made
+}
+
+// subscription delivers the events of one subscriber from its own goroutine,
+// so a slow subscriber never holds up writers or other subscribers.
+type subscription struct {
+	ch      chan<- Event
+	wake    chan struct{} // signals new pending events
+	done    chan struct{} // closed by the cancel func
+	stopped chan struct{} // closed when the goroutine returns
+
+	mu      sync.Mutex
+	pending []Event
+}
+
+// Subscribe sends the store's changes to ch until the returned cancel func is
+// called. The contract:
+//
+//   - The first event is an EventResync with the current revision; read
+//     Snapshot for the routes as of that revision or later.
+//   - Every Set and Delete after that is sent as one event, in the order the
+//     writes were made and with increasing revisions, once Snapshot and Get
+//     reflect it.
+//   - Writers never wait for subscribers. A subscriber more than 1024 events
+//     behind has its backlog replaced by one EventResync; the events that
+//     follow it may already be reflected in Snapshot and applying them again
+//     is harmless.
+//   - No event is sent once cancel returns, so the caller may then close ch.
+//     ch is never closed by the store.
+func (s *Store) Subscribe(ch chan<- Event) (cancel func()) {
+	sub := &subscription{
+		ch:      ch,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[*subscription]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	sub.enqueue(Event{Type: EventResync, Revision: s.revision})
+	s.mu.Unlock()
+	go sub.deliver()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, sub)
+			s.mu.Unlock()
+			close(sub.done)
+			<-sub.stopped
+		})
+	}
+}
+
+// Revision returns the number of changes made to the store.
+func (s *Store) Revision() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revision
+}
+
+// notify queues ev for every subscriber. Callers must hold s.mu, so events
+// are queued in revision order.
+func (s *Store) notify(ev Event) {
+	for sub := range s.subs {
+		sub.enqueue(ev)
+	}
+}
+
+// enqueue adds ev to the pending events, collapsing an overlong backlog into
+// an EventResync, and wakes the goroutine.
+func (sub *subscription) enqueue(ev Event) {
+	sub.mu.Lock()
+	if len(sub.pending) >= maxPendingEvents {
+		sub.pending = append(sub.pending[:0], Event{Type: EventResync, Revision: ev.Revision})
+	} else {
+		sub.pending = append(sub.pending, ev)
+	}
+	sub.mu.Unlock()
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends the pending events to the subscriber until it cancels.
+func (sub *subscription) deliver() {
+	defer close(sub.stopped)
+	for {
+		select {
+		case <-sub.wake:
+		case <-sub.done:
+			return
+		}
+		for {
+			sub.mu.Lock()
+			if len(sub.pending) == 0 {
+				sub.pending = nil
+				sub.mu.Unlock()
+				break
+			}
+			ev := sub.pending[0]
+			sub.pending = sub.pending[1:]
+			sub.mu.Unlock()
+			select {
+			case sub.ch <- ev:
+			case <-sub.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package routestore
+
+import (
+	"testing"
+	"time"
+)
+
+// next returns the next event on ch, failing the test if none arrives.
+func next(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func TestStoreSubscribe(t *testing.T) {
+	s := New()
+	s.Set("default", "a", &CompiledRoute{Name: "a"})
+
+	ch := make(chan Event)
+	cancel := s.Subscribe(ch)
+	defer cancel()
+
+	if ev := next(t, ch); ev.Type != EventResync || ev.Revision != 1 {
+		t.Errorf("first event = %+v, want a resync at revision 1", ev)
+	}
+
+	// Writers do not wait for the subscriber, which reads nothing yet.
+	b := &CompiledRoute{Name: "b"}
+	s.Set("default", "b", b)
+	s.Delete("default", "a")
+	if s.Revision() != 3 {
+		t.Errorf("Revision() = %d, want 3", s.Revision())
+	}
+
+	want := []Event{
+		{Type: EventSet, Namespace: "default", Name: "b", Route: b, Revision: 2},
+		{Type: EventDelete, Namespace: "default", Name: "a", Revision: 3},
+	}
+	for _, w := range want {
+		if ev := next(t, ch); ev != w {
+			t.Errorf("event = %+v, want %+v", ev, w)
+		}
+	}
+}
+
+func TestStoreSubscribeBacklog(t *testing.T) {
+	s := New()
+	ch := make(chan Event)
+	cancel := s.Subscribe(ch)
+	defer cancel()
+
+	// Once the delivery goroutine holds the resync, blocked on ch, every
+	// following change queues up behind it.
+	for idle := false; !idle; time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		for sub := range s.subs {
+			sub.mu.Lock()
+			idle = len(sub.pending) == 0
+			sub.mu.Unlock()
+		}
+		s.mu.Unlock()
+	}
+	for range maxPendingEvents + 10 {
+		s.Set("default", "a", &CompiledRoute{Name: "a"})
+	}
+	s.Delete("default", "a")
+
+	next(t, ch) // the first resync
+	ev := next(t, ch)
+	if ev.Type != EventResync {
+		t.Fatalf("event after the overflow = %+v, want a resync", ev)
+	}
+	// Events after the collapsed backlog still arrive, in order, ending with
+	// the last change.
+	last := ev
+	for last.Revision < s.Revision() {
+		ev = next(t, ch)
+		if ev.Revision <= last.Revision {
+			t.Fatalf("revision %d after %d", ev.Revision, last.Revision)
+		}
+		last = ev
+	}
+	if last.Type != EventDelete {
+		t.Errorf("last event = %+v, want the delete", last)
+	}
+}
+
+func TestStoreSubscribeCancel(t *testing.T) {
+	s := New()
+	ch := make(chan Event, 1)
+	cancel := s.Subscribe(ch)
+	next(t, ch)
+
+	s.Set("default", "a", &CompiledRoute{Name: "a"})
+	s.Set("default", "b", &CompiledRoute{Name: "b"})
+	// The goroutine may be blocked sending the second event; cancel stops it
+	// and no event follows, so ch can be closed.
+	cancel()
+	cancel()
+	for range len(ch) {
+		<-ch
+	}
+	close(ch)
+	s.Set("default", "c", &CompiledRoute{Name: "c"})
+}
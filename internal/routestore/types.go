@@ -1,27 +1,207 @@
 package routestore
 
-import "regexp"
+import (
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/jsonschema"
+)
 
 // CompiledRoute represents a fully compiled route from an X402Route CRD.
 type CompiledRoute struct {
-	Name           string
-	Namespace      string
-	Hosts          []string // hostnames from the associated Ingress rules
-	Wallet         string
-	Network        string
-	FacilitatorURL string
-	DefaultPrice   string
-	Rules          []CompiledRule
-	Backends       map[string]string // path -> backend URL
+	Name               string
+	Namespace          string
+	Generation         int64    // metadata.generation of the X402Route
+	Hosts              []string // hostnames from the associated Ingress rules
+	TLSHosts           []string // hostnames the Ingress terminates TLS for (spec.tls[].hosts)
+	Wallet             string
+	Network            string
+	Asset              string // token contract override; empty means the network's USDC
+	FacilitatorURL     string
+	FailoverURLs       []string                 // facilitators tried in order when FacilitatorURL fails; nil for none
+	FacilitatorHealthy bool                     // try facilitators that failed recently last
+	FacilitatorType    string                   // "coinbase", "x402.org" or "custom"; empty detects it from FacilitatorURL
+	FacilitatorAuth    *CompiledFacilitatorAuth // credential header of facilitator calls; nil sends none
+	DefaultPrice       string
+	Rules              []CompiledRule
+	Backends           []CompiledBackend
+	Unmatched          string               // "404" or "passthrough" for requests matching no rule
+	OnFacilitatorError string               // "failClosed", "failOpen" or "staticOK"
+	Callbacks          bool                 // settlement callbacks enabled
+	CallbackHosts      []string             // allowed callback hosts; empty allows any
+	CompilerVersion    int32                // version of the controller compile rules that produced the route
+	Mirror             *CompiledMirror      // shadow traffic for paid requests; nil when disabled
+	MaxConcurrent      int32                // paid requests in flight to the backend per replica; 0 is unlimited
+	QueueWait          time.Duration        // how long a request over MaxConcurrent waits for a slot
+	WaitingRoom        *CompiledWaitingRoom // queue tokens for requests over MaxConcurrent; nil answers them 503
+	Sandbox            bool                 // served on a test network; Network is already the test network
+	MinimumCharge      *big.Rat             // smallest charged amount in tokens; nil when unset
+	PriceIncrement     *big.Rat             // charged amounts are rounded up to a multiple of it; nil when unset
+	VerifyTimeout      time.Duration        // bounds the facilitator /verify call; 0 uses the gateway default
+	SettleTimeout      time.Duration        // bounds the facilitator /settle call; 0 uses the gateway default
+	PaymentBudget      time.Duration        // bounds the /verify call of a request; 0 for none
+	SettleAbandoned    bool                 // settle requests whose client disconnected before settlement
+	BindResource       bool                 // payments must echo the resource hash of their requirements
+	BindClient         string               // "certificate" or "session" binds offers to the client's TLS connection; empty binds none
+	PaidCaching        *CompiledCaching     // caching headers set on paid responses; nil keeps the backend's
+	Exemptions         *CompiledExemptions  // requests forwarded without payment; nil exempts none
+	Paused             bool                 // paused by x402.io/paused; paid paths are forwarded without payment
+	Reputation         *CompiledReputation  // payer score thresholds; nil treats every payer alike
+}
+
+// CompiledCaching holds the caching headers that replace the backend's on
+// paid responses.
+type CompiledCaching struct {
+	CacheControl     string
+	SurrogateControl string // also sent as CDN-Cache-Control; empty removes both
+}
+
+// CompiledReputation holds the payer scores at which a route graylists or
+// denies payers. 0 disables a threshold.
+type CompiledReputation struct {
+	GraylistScore float64
+	DenyScore     float64
+}
+
+// CompiledWaitingRoom holds the queue token settings of a route whose
+// requests over MaxConcurrent are answered 429.
+type CompiledWaitingRoom struct {
+	MaxRetryAfter time.Duration // cap of the retry time given to queued clients
+	TokenTTL      time.Duration // how long after its retry time a token is honored
+	PriorityWait  time.Duration // how long a token holder waits for a slot
+}
+
+// CompiledExemptions holds the methods exempted on every path and the paths
+// exempted for any method. A path ending in "/*" is a prefix.
+type CompiledExemptions struct {
+	Methods []string
+	Paths   []string
+}
+
+// CompiledFacilitatorAuth names the Vault secret value sent to the
+// facilitator in Header, after Prefix. The value itself is read by the
+// gateway on use.
+type CompiledFacilitatorAuth struct {
+	Header    string
+	Prefix    string
+	VaultPath string
+	VaultKey  string
+}
+
+// CompiledMirror copies a sample of settled paid requests to a second backend.
+type CompiledMirror struct {
+	URL     string
+	Percent int32
+}
+
+// CompiledBackend is an original Ingress backend and the path it was routed on.
+type CompiledBackend struct {
+	Path      string
+	PathType  string // Ingress pathType: "Exact", "Prefix" or "ImplementationSpecific"
+	URL       string
+	Service   string                   // backend Service name
+	Port      int32                    // backend Service port
+	Endpoints []string                 // ready endpoint base URLs; empty means use URL
+	Protocol  string                   // "http1" (default), "h2c", "h2" or "https"
+	Socket    string                   // Unix domain socket of a sidecar backend; dialed instead of the URL host
+	TLS       *CompiledBackendTLS      // verification of TLS backends; nil skips it
+	External  bool                     // upstream outside the cluster; dialed only at public addresses
+	Auth      *CompiledFacilitatorAuth // credential added to requests to an external backend; nil for none
+}
+
+// CompiledBackendTLS configures how the gateway verifies a TLS backend.
+type CompiledBackendTLS struct {
+	CA                 []byte // PEM CA bundle; empty uses the system roots
+	ServerName         string // SNI and verified name
+	InsecureSkipVerify bool
 }
 
 // CompiledRule is a single route rule with optional conditions.
 type CompiledRule struct {
-	Path       string
-	Price      string // effective price (from rule or default)
-	Free       bool
-	Mode       string // "all-pay" or "conditional"
-	Conditions []CompiledCondition
+	Path        string
+	Host        string // virtual host the rule is limited to; empty serves every host
+	Price       string // effective price (from rule or default)
+	Free        bool
+	Mode        string // "all-pay" or "conditional"
+	Conditions  []CompiledCondition
+	Offers      []CompiledOffer // alternative prices; empty means Price only
+	Modifiers   []CompiledPriceModifier
+	Experiments []CompiledExperiment       // price variants by client bucket; empty means Price for all
+	GraphQL     *CompiledGraphQL           // per-operation prices; nil when not a GraphQL rule
+	Metering    *CompiledMetering          // charge by response, up to Price; nil for fixed prices
+	Async       *CompiledAsync             // serve paid requests as background jobs; nil when synchronous
+	Idempotency *CompiledIdempotency       // replay paid POSTs by Idempotency-Key; nil when disabled
+	Settle      string                     // "sync", "async" or "afterResponse"
+	NotModified *CompiledNotModified       // charge of conditional requests answered 304; nil charges them in full
+	Validation  *CompiledRequestValidation // checks before payment is demanded; nil checks nothing
+	Backend     *CompiledBackend           // external backend replacing the route's backends; nil uses them
+}
+
+// CompiledIdempotency configures Idempotency-Key replays for a rule.
+type CompiledIdempotency struct {
+	Window           time.Duration
+	MaxResponseBytes int64
+}
+
+// CompiledNotModified is the charge of a conditional request the backend
+// answers 304 Not Modified.
+type CompiledNotModified struct {
+	Price *big.Rat // in tokens; zero does not charge
+}
+
+// CompiledRequestValidation is what a request must look like to be paid for.
+type CompiledRequestValidation struct {
+	RequiredHeaders []string           // canonical header names
+	ContentTypes    []string           // media types; "type/*" matches every subtype
+	Schema          *jsonschema.Schema // the JSON body must match; nil reads no body
+	MaxBodyBytes    int64
+}
+
+// CompiledAsync configures background jobs for a rule.
+type CompiledAsync struct {
+	Timeout        time.Duration
+	ResultTTL      time.Duration
+	MaxResultBytes int64
+}
+
+// CompiledMetering computes the charge of a request from the backend response.
+type CompiledMetering struct {
+	UnitHeader       string
+	UnitPrice        *big.Rat
+	MaxResponseBytes int64
+}
+
+// CompiledGraphQL holds the per-operation prices of a GraphQL rule.
+type CompiledGraphQL struct {
+	Operations   map[string]string // operation name -> price
+	MaxBodyBytes int64
+}
+
+// CompiledPriceModifier is a pre-compiled query-parameter price adjustment.
+type CompiledPriceModifier struct {
+	Param      string
+	Pattern    *regexp.Regexp
+	Multiplier *big.Rat // scales every advertised price; nil when Price is set
+	Price      string   // replaces the rule price
+}
+
+// ExperimentControl is the variant of clients outside every experiment of a
+// rule, who pay the rule price.
+const ExperimentControl = "control"
+
+// CompiledExperiment is a price variant served to a share of a rule's clients.
+type CompiledExperiment struct {
+	Name    string
+	Price   string
+	Percent int32
+}
+
+// CompiledOffer is one of several prices advertised for a rule.
+type CompiledOffer struct {
+	Name    string
+	Price   string
+	Headers map[string]string // set on the proxied request when a payment matches
 }
 
 // CompiledCondition is a pre-compiled condition for conditional payment evaluation.
@@ -0,0 +1,205 @@
+package routesync
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// Reconnection backoff of a data plane whose stream ended.
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Client keeps a data plane's route store in sync with a control plane. The
+// routes last applied keep being served while the control plane is
+// unreachable. It implements manager.Runnable.
+type Client struct {
+	// Upstream is the address of the control plane, such as
+	// x402-k8s-operator.x402-system:8403.
+	Upstream string
+	// Node identifies this data plane to the control plane.
+	Node string
+	// Store receives the routes.
+	Store *routestore.Store
+	// TokenFile holds the bearer token sent to the control plane, re-read
+	// for every stream. Empty sends none.
+	TokenFile string
+	// TLS connects with TLS, verifying the control plane against the CA
+	// bundle in CAFile, or the system roots when it is empty.
+	TLS    bool
+	CAFile string
+
+	version atomic.Pointer[string] // of the routes applied
+	applied map[string][]byte      // encoding of the routes applied, by namespace/name
+}
+
+// Start streams routes from the control plane until ctx is canceled,
+// reconnecting with backoff.
+func (c *Client) Start(ctx context.Context) error {
+	creds := insecure.NewCredentials()
+	if c.TLS {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if c.CAFile != "" {
+			pem, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return fmt.Errorf("route sync CA: %w", err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("route sync CA %s holds no PEM certificate", c.CAFile)
+			}
+		}
+		creds = credentials.NewTLS(config)
+	}
+	conn, err := grpc.NewClient(c.Upstream,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+	if err != nil {
+		return fmt.Errorf("route sync upstream %q: %w", c.Upstream, err)
+	}
+	defer conn.Close()
+
+	delay := minReconnectDelay
+	for {
+		received, err := c.stream(ctx, conn)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if received {
+			delay = minReconnectDelay
+		}
+		slog.Warn("route sync stream ended, reconnecting", "upstream", c.Upstream, "error", err, "retryIn", delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every data
+// plane replica syncs its own routes.
+func (c *Client) NeedLeaderElection() bool {
+	return false
+}
+
+// Version returns the version of the routes applied, or "" before the first.
+func (c *Client) Version() string {
+	if v := c.version.Load(); v != nil {
+		return *v
+	}
+	return ""
+}
+
+// ReadyCheck is a healthz.Checker that passes once routes were applied, so a
+// data plane does not serve before it knows its routes.
+func (c *Client) ReadyCheck(_ *http.Request) error {
+	if c.Version() == "" {
+		return errors.New("no routes received from the control plane")
+	}
+	return nil
+}
+
+// stream runs one stream and reports whether it received any response.
+func (c *Client) stream(ctx context.Context, conn *grpc.ClientConn) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if c.TokenFile != "" {
+		token, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return false, fmt.Errorf("route sync token: %w", err)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], StreamMethod)
+	if err != nil {
+		return false, err
+	}
+	if err := stream.SendMsg(&DiscoveryRequest{Node: c.Node, VersionInfo: c.Version()}); err != nil {
+		return false, err
+	}
+	received := false
+	for {
+		var resp DiscoveryResponse
+		if err := stream.RecvMsg(&resp); err != nil {
+			return received, err
+		}
+		received = true
+		ack := DiscoveryRequest{Node: c.Node, ResponseNonce: resp.Nonce}
+		if err := c.apply(&resp); err != nil {
+			slog.Error("rejected routes from the control plane", "version", resp.VersionInfo, "error", err)
+			metrics.RouteSyncUpdatesTotal.WithLabelValues("rejected").Inc()
+			ack.ErrorDetail = err.Error()
+		} else {
+			slog.Info("applied routes from the control plane", "version", resp.VersionInfo, "routes", len(resp.Routes))
+			metrics.RouteSyncUpdatesTotal.WithLabelValues("applied").Inc()
+		}
+		ack.VersionInfo = c.Version()
+		if err := stream.SendMsg(&ack); err != nil {
+			return received, err
+		}
+	}
+}
+
+// apply replaces the routes of the store with those of resp, or changes
+// nothing when any of them is invalid. Routes whose encoding did not change
+// are not set again.
+func (c *Client) apply(resp *DiscoveryResponse) error {
+	type decoded struct {
+		route *routestore.CompiledRoute
+		data  []byte
+	}
+	routes := make(map[string]decoded, len(resp.Routes))
+	for i, data := range resp.Routes {
+		var route routestore.CompiledRoute
+		if err := json.Unmarshal(data, &route); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+		if route.Namespace == "" || route.Name == "" {
+			return fmt.Errorf("route %d has no namespace or name", i)
+		}
+		key := route.Namespace + "/" + route.Name
+		if _, ok := routes[key]; ok {
+			return fmt.Errorf("route %s is listed twice", key)
+		}
+		routes[key] = decoded{&route, data}
+	}
+
+	applied := make(map[string][]byte, len(routes))
+	for key, d := range routes {
+		applied[key] = d.data
+		if !bytes.Equal(c.applied[key], d.data) {
+			c.Store.Set(d.route.Namespace, d.route.Name, d.route)
+		}
+	}
+	for _, route := range c.Store.Snapshot() {
+		if _, ok := routes[route.Namespace+"/"+route.Name]; !ok {
+			c.Store.Delete(route.Namespace, route.Name)
+		}
+	}
+	c.applied = applied
+	version := resp.VersionInfo
+	c.version.Store(&version)
+	return nil
+}
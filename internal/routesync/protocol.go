@@ -0,0 +1,70 @@
+// Package routesync pushes the compiled routes of the operator, the control
+// plane, to remote gateways, the data planes, over a gRPC stream, so gateways
+// running as sidecars or in other clusters serve the same routes without
+// watching the Kubernetes API.
+//
+// The protocol follows the state-of-the-world variant of xDS. A data plane
+// opens the StreamRoutes stream and sends a DiscoveryRequest naming itself
+// and the version it already has, if any. The control plane answers with a
+// DiscoveryResponse holding every route whenever the routes change, each
+// with a version and a nonce. The data plane acknowledges each response with
+// a DiscoveryRequest carrying the nonce: with the new version when it
+// applied the routes (ACK), or with the version it kept and an error detail
+// when it rejected them (NACK). Messages are encoded as JSON.
+package routesync
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc"
+)
+
+// ServiceName is the gRPC service of the control plane.
+const ServiceName = "x402.routesync.v1alpha1.RouteDiscoveryService"
+
+// StreamMethod is the full name of the route stream.
+const StreamMethod = "/" + ServiceName + "/StreamRoutes"
+
+// DiscoveryRequest is sent by a data plane: once to subscribe, then to ACK
+// or NACK every response.
+type DiscoveryRequest struct {
+	Node          string `json:"node"`                    // the data plane, such as its pod name
+	VersionInfo   string `json:"versionInfo,omitempty"`   // of the routes the data plane serves; empty for none
+	ResponseNonce string `json:"responseNonce,omitempty"` // of the response acknowledged; empty when subscribing
+	ErrorDetail   string `json:"errorDetail,omitempty"`   // why the response was rejected; empty for an ACK
+}
+
+// DiscoveryResponse holds every route of the control plane. Routes are the
+// JSON encoding of routestore.CompiledRoute, decoded one by one so a route
+// the data plane cannot decode is NACKed rather than breaking the stream.
+type DiscoveryResponse struct {
+	VersionInfo string            `json:"versionInfo"`
+	Nonce       string            `json:"nonce"`
+	Routes      []json.RawMessage `json:"routes"`
+}
+
+// jsonCodec encodes the messages of the stream as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return "json" }
+
+// routeDiscoveryServer is the handler type of the service.
+type routeDiscoveryServer interface {
+	streamRoutes(stream grpc.ServerStream) error
+}
+
+// serviceDesc describes the service for grpc.Server.RegisterService.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*routeDiscoveryServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "StreamRoutes",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			return srv.(routeDiscoveryServer).streamRoutes(stream)
+		},
+	}},
+}
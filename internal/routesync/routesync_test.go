@@ -0,0 +1,166 @@
+package routesync
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/jsonschema"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// freeAddr returns a loopback address nothing listens on.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// run starts r until the test ends.
+func run(t *testing.T, r interface{ Start(context.Context) error }) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	})
+}
+
+// eventually fails the test unless cond holds within a second, the
+// convergence the protocol promises.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not happen within a second", what)
+		}
+	}
+}
+
+func testRoute(generation int64) *routestore.CompiledRoute {
+	schema, _ := jsonschema.Compile([]byte(`{"type":"object","required":["sku"]}`))
+	return &routestore.CompiledRoute{
+		Name: "api", Namespace: "default", Generation: generation, Wallet: "0xTestWallet", Network: "base-sepolia",
+		MinimumCharge: big.NewRat(1, 1000),
+		VerifyTimeout: 3 * time.Second,
+		Rules: []routestore.CompiledRule{{
+			Path: "/api/*", Price: "0.01", Mode: "conditional", Settle: "sync",
+			Conditions: []routestore.CompiledCondition{{Header: "User-Agent", Pattern: regexp.MustCompile("(?i)bot"), Action: "pay"}},
+			Validation: &routestore.CompiledRequestValidation{Schema: schema, MaxBodyBytes: 1024},
+		}},
+		Backends: []routestore.CompiledBackend{{Path: "/", PathType: "Prefix", URL: "http://api.default.svc:80"}},
+	}
+}
+
+func TestRouteSync(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("secret\n"), 0o600)
+	control := routestore.New()
+	control.Set("default", "api", testRoute(1))
+	addr := freeAddr(t)
+	run(t, NewServer(addr, control, tokenFile))
+
+	data := routestore.New()
+	client := &Client{Upstream: addr, Node: "gateway-0", Store: data, TokenFile: tokenFile}
+	acked := func() float64 { return testutil.ToFloat64(metrics.RouteSyncUpdatesTotal.WithLabelValues("acked")) }
+	before := acked()
+	if client.ReadyCheck(nil) == nil {
+		t.Error("ReadyCheck() passed before any routes were received")
+	}
+	run(t, client)
+
+	eventually(t, "the first sync", func() bool { return data.Get("default", "api") != nil })
+	got := data.Get("default", "api")
+	rule := got.Rules[0]
+	if got.MinimumCharge.Cmp(big.NewRat(1, 1000)) != 0 || got.VerifyTimeout != 3*time.Second ||
+		!rule.Conditions[0].Pattern.MatchString("GoogleBot") || rule.Validation.Schema.Validate(map[string]any{}) == nil {
+		t.Errorf("synced route = %+v, want the control plane's route", got)
+	}
+	if client.ReadyCheck(nil) != nil || client.Version() == "" {
+		t.Errorf("ReadyCheck() = %v after the first sync", client.ReadyCheck(nil))
+	}
+	eventually(t, "the ACK", func() bool { return acked()-before >= 1 })
+
+	// Changes converge.
+	control.Set("default", "api", testRoute(2))
+	control.Set("default", "web", &routestore.CompiledRoute{Name: "web", Namespace: "default"})
+	eventually(t, "the update", func() bool {
+		r := data.Get("default", "api")
+		return r.Generation == 2 && data.Get("default", "web") != nil
+	})
+	control.Delete("default", "web")
+	eventually(t, "the deletion", func() bool { return data.Count() == 1 })
+
+	// An unchanged route is not set again.
+	revision := data.Revision()
+	control.Set("default", "other", &routestore.CompiledRoute{Name: "other", Namespace: "other"})
+	eventually(t, "the addition", func() bool { return data.Count() == 2 })
+	if got := data.Revision() - revision; got != 1 {
+		t.Errorf("data plane store changed %d times for one added route, want 1", got)
+	}
+}
+
+func TestRouteSyncUnauthorized(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("secret"), 0o600)
+	addr := freeAddr(t)
+	run(t, NewServer(addr, routestore.New(), tokenFile))
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := &Client{Upstream: addr, Node: "gateway-0", Store: routestore.New()}
+	var received bool
+	eventually(t, "the rejection", func() bool {
+		received, err = client.stream(context.Background(), conn)
+		return status.Code(err) != codes.Unavailable
+	})
+	if received || status.Code(err) != codes.Unauthenticated {
+		t.Errorf("stream() = %v, %v, want Unauthenticated", received, err)
+	}
+}
+
+func TestClientApplyRejects(t *testing.T) {
+	store := routestore.New()
+	client := &Client{Store: store}
+	valid, _ := json.Marshal(testRoute(1))
+	if err := client.apply(&DiscoveryResponse{VersionInfo: "v1", Routes: []json.RawMessage{valid}}); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	tests := map[string][]json.RawMessage{
+		"invalid pattern": {json.RawMessage(`{"Name":"a","Namespace":"default","Rules":[{"Conditions":[{"Pattern":"("}]}]}`)},
+		"no name":         {json.RawMessage(`{"Namespace":"default"}`)},
+		"listed twice":    {valid, valid},
+	}
+	for name, routes := range tests {
+		if err := client.apply(&DiscoveryResponse{VersionInfo: "v2", Routes: routes}); err == nil {
+			t.Errorf("%s: apply() succeeded", name)
+		}
+	}
+	// Rejected versions change nothing.
+	if client.Version() != "v1" || store.Count() != 1 || store.Get("default", "api") == nil {
+		t.Errorf("version %q with %d routes after rejections, want v1 with the api route", client.Version(), store.Count())
+	}
+}
@@ -0,0 +1,229 @@
+package routesync
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/razvanmacovei/x402-k8s-operator/internal/metrics"
+	"github.com/razvanmacovei/x402-k8s-operator/internal/routestore"
+)
+
+// Server streams the routes of a route store to remote gateways. It
+// implements manager.Runnable.
+type Server struct {
+	addr      string
+	store     *routestore.Store
+	tokenFile string
+	getCert   func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	// instance prefixes the versions served, so a data plane that kept the
+	// version of a previous control plane process is sent the routes again.
+	instance string
+
+	mu       sync.Mutex
+	response *DiscoveryResponse // the routes of the last version served, shared by every stream
+}
+
+// NewServer returns a server listening on addr that streams the routes of
+// store. Data planes must send the bearer token in tokenFile, re-read for
+// every stream; an empty tokenFile accepts every data plane.
+func NewServer(addr string, store *routestore.Store, tokenFile string) *Server {
+	instance := make([]byte, 4)
+	rand.Read(instance)
+	return &Server{addr: addr, store: store, tokenFile: tokenFile, instance: hex.EncodeToString(instance)}
+}
+
+// EnableTLS makes the server serve TLS with the certificate returned by
+// getCert. Call before Start.
+func (s *Server) EnableTLS(getCert func(*tls.ClientHelloInfo) (*tls.Certificate, error)) {
+	s.getCert = getCert
+}
+
+// Start serves the route stream until ctx is canceled.
+func (s *Server) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("route sync server failed: %w", err)
+	}
+	opts := []grpc.ServerOption{grpc.ForceServerCodec(jsonCodec{})}
+	if s.getCert != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{GetCertificate: s.getCert, MinVersion: tls.VersionTLS12})))
+	}
+	srv := grpc.NewServer(opts...)
+	srv.RegisterService(&serviceDesc, s)
+	slog.Info("starting route sync server", "addr", s.addr)
+
+	go func() {
+		<-ctx.Done()
+		// Streams never end on their own, so stop them after a grace period.
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			srv.Stop()
+		}
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("route sync server failed: %w", err)
+	}
+	return nil
+}
+
+// streamRoutes serves one data plane: it sends every route each time the
+// store changes, and reads the data plane's ACKs and NACKs.
+func (s *Server) streamRoutes(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	var subscribe DiscoveryRequest
+	if err := stream.RecvMsg(&subscribe); err != nil {
+		return err
+	}
+	node := subscribe.Node
+	slog.Info("data plane connected", "node", node, "version", subscribe.VersionInfo)
+	metrics.RouteSyncDataPlanes.Inc()
+	defer metrics.RouteSyncDataPlanes.Dec()
+
+	// Subscribe before the first response, so no change is missed.
+	events := make(chan routestore.Event, 64)
+	cancel := s.store.Subscribe(events)
+	defer cancel()
+
+	var mu sync.Mutex
+	pushed := map[string]time.Time{} // send time of the unacknowledged nonces
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			var req DiscoveryRequest
+			if err := stream.RecvMsg(&req); err != nil {
+				recvErr <- err
+				return
+			}
+			mu.Lock()
+			sent, ok := pushed[req.ResponseNonce]
+			delete(pushed, req.ResponseNonce)
+			mu.Unlock()
+			if !ok {
+				continue
+			}
+			if req.ErrorDetail != "" {
+				slog.Warn("data plane rejected routes", "node", node, "kept", req.VersionInfo, "error", req.ErrorDetail)
+				metrics.RouteSyncUpdatesTotal.WithLabelValues("nacked").Inc()
+				continue
+			}
+			slog.Debug("data plane applied routes", "node", node, "version", req.VersionInfo, "latency", time.Since(sent))
+			metrics.RouteSyncUpdatesTotal.WithLabelValues("acked").Inc()
+		}
+	}()
+
+	version := subscribe.VersionInfo
+	nonce := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+				slog.Info("data plane disconnected", "node", node)
+				return nil
+			}
+			return err
+		case <-events:
+		}
+		// Changes made in a burst are sent as one version.
+	drain:
+		for {
+			select {
+			case <-events:
+			default:
+				break drain
+			}
+		}
+		resp, err := s.current()
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if resp.VersionInfo == version {
+			continue
+		}
+		nonce++
+		push := *resp
+		push.Nonce = strconv.Itoa(nonce)
+		mu.Lock()
+		pushed[push.Nonce] = time.Now()
+		mu.Unlock()
+		if err := stream.SendMsg(&push); err != nil {
+			return err
+		}
+		version = push.VersionInfo
+	}
+}
+
+// current returns the routes of the store's current version, encoding them
+// once for every stream.
+func (s *Server) current() (*DiscoveryResponse, error) {
+	version := s.instance + "-" + strconv.FormatUint(s.store.Revision(), 10)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.response != nil && s.response.VersionInfo == version {
+		return s.response, nil
+	}
+	// The snapshot may already hold later changes; their events bring a
+	// newer version, sent again.
+	snapshot := s.store.Snapshot()
+	resp := &DiscoveryResponse{VersionInfo: version, Routes: make([]json.RawMessage, len(snapshot))}
+	for i, route := range snapshot {
+		data, err := json.Marshal(route)
+		if err != nil {
+			return nil, fmt.Errorf("encode route %s/%s: %w", route.Namespace, route.Name, err)
+		}
+		resp.Routes[i] = data
+	}
+	s.response = resp
+	return resp, nil
+}
+
+// authorize checks the bearer token of a stream against the token file.
+func (s *Server) authorize(ctx context.Context) error {
+	if s.tokenFile == "" {
+		return nil
+	}
+	token, err := os.ReadFile(s.tokenFile)
+	if err != nil {
+		slog.Error("failed to read route sync token", "file", s.tokenFile, "error", err)
+		return errors.New("route sync token unavailable")
+	}
+	want := strings.TrimSpace(string(token))
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(auth, "Bearer ")
+		if ok && want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+			return nil
+		}
+	}
+	return errors.New("missing or invalid bearer token")
+}
@@ -0,0 +1,125 @@
+// Package scaffold generates a starter X402Route manifest from an existing
+// Ingress. Every path of the Ingress is listed as a free rule, so applying the
+// manifest as generated changes no prices; the user then prices the paths to
+// charge for.
+package scaffold
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/template"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// Defaults of a generated route.
+const (
+	DefaultWallet  = "0x0000000000000000000000000000000000000000"
+	DefaultNetwork = "base-sepolia"
+	DefaultPrice   = "0.001"
+)
+
+// Options customizes the generated route.
+type Options struct {
+	Name    string // X402Route name; defaults to "<ingress>-x402"
+	Wallet  string // defaults to DefaultWallet, a placeholder
+	Network string // defaults to DefaultNetwork
+}
+
+var manifest = template.Must(template.New("route").Parse(`# Generated by x402ctl init from Ingress {{.Namespace}}/{{.Ingress}}.
+# Every path starts free. Set a price on the paths to charge for, or
+# remove "free: true" to charge payment.defaultPrice.
+apiVersion: x402.io/v1alpha1
+kind: X402Route
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+spec:
+  ingressRef:
+    name: {{.Ingress}}
+  payment:
+    wallet: {{printf "%q" .Wallet}}{{if .PlaceholderWallet}} # replace with the wallet receiving payments{{end}}
+    network: {{.Network}}
+    defaultPrice: {{printf "%q" .Price}}
+  routes:
+{{- range .Paths}}
+    - path: {{printf "%q" .Path}}{{if .Hosts}} # {{.Hosts}}{{end}}
+      free: true
+{{- end}}
+`))
+
+// path is a rule of the generated route and the hosts serving it.
+type path struct {
+	Path  string
+	Hosts string
+}
+
+// Route writes the X402Route manifest for ing to w.
+func Route(w io.Writer, ing *networkingv1.Ingress, opts Options) error {
+	if opts.Name == "" {
+		opts.Name = ing.Name + "-x402"
+	}
+	if opts.Wallet == "" {
+		opts.Wallet = DefaultWallet
+	}
+	if opts.Network == "" {
+		opts.Network = DefaultNetwork
+	}
+	paths := rulePaths(ing)
+	if len(paths) == 0 {
+		return fmt.Errorf("ingress %s/%s has no paths", ing.Namespace, ing.Name)
+	}
+	return manifest.Execute(w, map[string]any{
+		"Name":              opts.Name,
+		"Namespace":         ing.Namespace,
+		"Ingress":           ing.Name,
+		"Wallet":            opts.Wallet,
+		"PlaceholderWallet": opts.Wallet == DefaultWallet,
+		"Network":           opts.Network,
+		"Price":             DefaultPrice,
+		"Paths":             paths,
+	})
+}
+
+// rulePaths returns the rule path of every distinct Ingress path, in the order
+// they appear, with the hosts serving it. A default backend is "/**".
+func rulePaths(ing *networkingv1.Ingress) []path {
+	var paths []path
+	hosts := map[string][]string{}
+	add := func(rulePath, host string) {
+		if _, ok := hosts[rulePath]; !ok {
+			paths = append(paths, path{Path: rulePath})
+			hosts[rulePath] = nil
+		}
+		if host != "" && !slices.Contains(hosts[rulePath], host) {
+			hosts[rulePath] = append(hosts[rulePath], host)
+		}
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			add(rulePath(p), rule.Host)
+		}
+	}
+	if ing.Spec.DefaultBackend != nil {
+		add("/**", "")
+	}
+	for i := range paths {
+		paths[i].Hosts = strings.Join(hosts[paths[i].Path], ", ")
+	}
+	return paths
+}
+
+// rulePath converts an Ingress path to a route rule path: Exact paths match
+// as they are, and Prefix and ImplementationSpecific paths cover their subtree.
+func rulePath(p networkingv1.HTTPIngressPath) string {
+	if p.PathType != nil && *p.PathType == networkingv1.PathTypeExact {
+		return p.Path
+	}
+	prefix := strings.TrimRight(p.Path, "/")
+	return prefix + "/**"
+}
@@ -0,0 +1,77 @@
+package scaffold
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+)
+
+func ingressPath(path string, pathType networkingv1.PathType) networkingv1.HTTPIngressPath {
+	return networkingv1.HTTPIngressPath{Path: path, PathType: &pathType}
+}
+
+func TestRoute(t *testing.T) {
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-ing", Namespace: "my-ns"},
+		Spec: networkingv1.IngressSpec{
+			// Served by the "/" path already.
+			DefaultBackend: &networkingv1.IngressBackend{},
+			Rules: []networkingv1.IngressRule{
+				{Host: "api.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						ingressPath("/api/", networkingv1.PathTypePrefix),
+						ingressPath("/health", networkingv1.PathTypeExact),
+					},
+				}}},
+				{Host: "www.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						ingressPath("/api", networkingv1.PathTypeImplementationSpecific),
+						ingressPath("/", networkingv1.PathTypePrefix),
+					},
+				}}},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := Route(&out, ing, Options{Network: "base"}); err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	var route x402v1alpha1.X402Route
+	if err := yaml.UnmarshalStrict(out.Bytes(), &route); err != nil {
+		t.Fatalf("generated manifest is not an X402Route: %v\n%s", err, out.String())
+	}
+
+	if route.Name != "my-ing-x402" || route.Namespace != "my-ns" || route.Spec.IngressRef.Name != "my-ing" {
+		t.Errorf("metadata = %s/%s for ingress %q", route.Namespace, route.Name, route.Spec.IngressRef.Name)
+	}
+	if route.Spec.Payment.Network != "base" || route.Spec.Payment.Wallet != DefaultWallet {
+		t.Errorf("payment = %+v", route.Spec.Payment)
+	}
+	var got []string
+	for _, rule := range route.Spec.Routes {
+		if !rule.Free {
+			t.Errorf("rule %s is not free", rule.Path)
+		}
+		got = append(got, rule.Path)
+	}
+	if want := "/api/** /health /**"; strings.Join(got, " ") != want {
+		t.Errorf("rule paths = %q, want %q", strings.Join(got, " "), want)
+	}
+	if !strings.Contains(out.String(), `- path: "/api/**" # api.example.com, www.example.com`) {
+		t.Errorf("manifest does not list the hosts of /api/**:\n%s", out.String())
+	}
+}
+
+func TestRouteWithoutPaths(t *testing.T) {
+	ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"}}
+	if err := Route(&bytes.Buffer{}, ing, Options{}); err == nil {
+		t.Error("Route() for an Ingress without paths succeeded, want error")
+	}
+}
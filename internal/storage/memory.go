@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired keys are removed from a Memory store.
+const sweepInterval = time.Minute
+
+// Memory is an in-memory store, private to one gateway replica.
+type Memory struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // zero when the key never expires
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{now: time.Now, entries: map[string]memoryEntry{}}
+}
+
+// Get implements Interface.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lookup(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+// Set implements Interface.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	e := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.entries[key] = e
+	return nil
+}
+
+// Add implements Interface.
+func (m *Memory) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+	e := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.entries[key] = e
+	return true, nil
+}
+
+// Delete implements Interface.
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// Incr implements Interface.
+func (m *Memory) Incr(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	e, _ := m.lookup(key)
+	var n int64
+	if e.value != nil {
+		var err error
+		if n, err = strconv.ParseInt(string(e.value), 10, 64); err != nil {
+			return 0, errNotCounter(key)
+		}
+	}
+	n += delta
+	e.value = strconv.AppendInt(nil, n, 10)
+	if e.expires.IsZero() && ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.entries[key] = e
+	return n, nil
+}
+
+// TTL implements Interface.
+func (m *Memory) TTL(_ context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lookup(key)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if e.expires.IsZero() {
+		return 0, nil
+	}
+	return e.expires.Sub(m.now()), nil
+}
+
+// Scan implements Interface.
+func (m *Memory) Scan(_ context.Context, prefix string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	found := map[string][]byte{}
+	for key, e := range m.entries {
+		if strings.HasPrefix(key, prefix) && !e.expired(now) {
+			found[key] = append([]byte(nil), e.value...)
+		}
+	}
+	return found, nil
+}
+
+// lookup returns the entry of key unless it has expired. The caller holds
+// m.mu.
+func (m *Memory) lookup(key string) (memoryEntry, bool) {
+	e, ok := m.entries[key]
+	if !ok || e.expired(m.now()) {
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+// sweep removes the expired keys, at most once per sweepInterval. The caller
+// holds m.mu.
+func (m *Memory) sweep() {
+	now := m.now()
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, e := range m.entries {
+		if e.expired(now) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisTimeout bounds a Redis command whose context has no deadline.
+	redisTimeout = 5 * time.Second
+	// redisMaxIdle is the number of idle connections kept for reuse.
+	redisMaxIdle = 16
+	// redisBatch is the number of keys asked for per SCAN and MGET.
+	redisBatch = 500
+)
+
+// redisIncrScript increments a counter and gives it an expiry unless it has
+// one, in one step.
+const redisIncrScript = `local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n`
+
+// Redis is a store on a Redis server, shared by every gateway replica that
+// uses it. It is a minimal RESP client keeping a small pool of connections.
+type Redis struct {
+	addr         string
+	tls          *tls.Config // nil for plain TCP
+	user         string
+	password     string
+	passwordFile string
+	db           int
+
+	idle chan *redisConn
+}
+
+// redisConn is a connection to the server.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// NewRedis returns a store on the Redis server at u, a redis:// or rediss://
+// URL with an optional user, password and database number. A password in
+// passwordFile takes precedence over one in the URL. Connections are opened
+// when first needed.
+func NewRedis(u *url.URL, passwordFile string) (*Redis, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("storage URL %q has no host", u.Redacted())
+	}
+	r := &Redis{
+		addr:         u.Host,
+		passwordFile: passwordFile,
+		idle:         make(chan *redisConn, redisMaxIdle),
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Scheme == "rediss" {
+		r.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	}
+	if u.User != nil {
+		r.user = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("storage URL %q: invalid database %q", u.Redacted(), db)
+		}
+		r.db = n
+	}
+	return r, nil
+}
+
+// Get implements Interface.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+// Set implements Interface.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+// Add implements Interface with SET NX, which replies nil when the key
+// exists.
+func (r *Redis) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	args := []string{"SET", key, string(value), "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	reply, err := r.do(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// Delete implements Interface.
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", key)
+	return err
+}
+
+// Incr implements Interface.
+func (r *Redis) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	var ms int64
+	if ttl > 0 {
+		ms = max(ttl.Milliseconds(), 1)
+	}
+	reply, err := r.do(ctx, "EVAL", redisIncrScript, "1", key, strconv.FormatInt(delta, 10), strconv.FormatInt(ms, 10))
+	if err != nil {
+		var replyErr redisError
+		if errors.As(err, &replyErr) && strings.Contains(string(replyErr), "not an integer") {
+			return 0, errNotCounter(key)
+		}
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected reply %T to INCRBY", reply)
+	}
+	return n, nil
+}
+
+// TTL implements Interface.
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	reply, err := r.do(ctx, "PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+	ms, ok := reply.(int64)
+	switch {
+	case !ok:
+		return 0, fmt.Errorf("redis: unexpected reply %T to PTTL", reply)
+	case ms == -2:
+		return 0, ErrNotFound
+	case ms < 0:
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Scan implements Interface. Keys are listed with SCAN, which may miss keys
+// written during the scan, and read in batches with MGET.
+func (r *Redis) Scan(ctx context.Context, prefix string) (map[string][]byte, error) {
+	pattern := redisGlobEscaper.Replace(prefix) + "*"
+	seen := map[string]bool{}
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := r.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(redisBatch))
+		if err != nil {
+			return nil, err
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("redis: unexpected reply to SCAN")
+		}
+		next, _ := page[0].([]byte)
+		batch, _ := page[1].([]any)
+		for _, k := range batch {
+			if key, ok := k.([]byte); ok && !seen[string(key)] {
+				seen[string(key)] = true
+				keys = append(keys, string(key))
+			}
+		}
+		if cursor = string(next); cursor == "0" || cursor == "" {
+			break
+		}
+	}
+
+	found := make(map[string][]byte, len(keys))
+	for start := 0; start < len(keys); start += redisBatch {
+		batch := keys[start:min(start+redisBatch, len(keys))]
+		reply, err := r.do(ctx, append([]string{"MGET"}, batch...)...)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := reply.([]any)
+		if !ok || len(values) != len(batch) {
+			return nil, fmt.Errorf("redis: unexpected reply to MGET")
+		}
+		for i, v := range values {
+			// Keys that expired since the SCAN come back as nil.
+			if value, ok := v.([]byte); ok {
+				found[batch[i]] = value
+			}
+		}
+	}
+	return found, nil
+}
+
+// redisGlobEscaper escapes the glob characters of a SCAN MATCH pattern, such
+// as the "*" of rule paths.
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// Close closes the idle connections.
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.idle:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends one command and returns its reply: a string for a status, []byte
+// for a bulk string, int64, nil or []any. An error reply is a redisError.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	c, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	c.SetDeadline(deadline)
+	reply, err := c.command(args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state.
+		c.Close()
+		return nil, fmt.Errorf("redis %s: %w", args[0], err)
+	}
+	select {
+	case r.idle <- c:
+	default:
+		c.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection or opens a new one.
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if r.tls != nil {
+		tc := tls.Client(nc, r.tls)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		nc = tc
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	if err := r.setup(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// setup authenticates a new connection and selects the database.
+func (r *Redis) setup(c *redisConn) error {
+	password := r.password
+	if r.passwordFile != "" {
+		b, err := os.ReadFile(r.passwordFile)
+		if err != nil {
+			return fmt.Errorf("redis password: %w", err)
+		}
+		password = strings.TrimSpace(string(b))
+	}
+	if password != "" {
+		args := []string{"AUTH", password}
+		if r.user != "" {
+			args = []string{"AUTH", r.user, password}
+		}
+		if _, err := c.command(args...); err != nil {
+			return fmt.Errorf("redis AUTH: %w", err)
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.command("SELECT", strconv.Itoa(r.db)); err != nil {
+			return fmt.Errorf("redis SELECT: %w", err)
+		}
+	}
+	return nil
+}
+
+// command writes args as a RESP array of bulk strings and reads the reply.
+func (c *redisConn) command(args ...string) (any, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// readReply reads one RESP2 reply.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	kind, rest := line[0], line[1:]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length %q", rest)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", rest)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				// An error reply inside an array, such as from EXEC, is kept.
+				var replyErr redisError
+				if !errors.As(err, &replyErr) {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", kind)
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the commands of the Redis store from a Memory store.
+type fakeRedis struct {
+	addr     string
+	password string
+	mem      *Memory
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{addr: ln.Addr().String(), password: password, mem: NewMemory()}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := f.password == ""
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range reply.([]any) {
+			args = append(args, string(a.([]byte)))
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		f.mu.Unlock()
+		if args[0] == "AUTH" {
+			if authed = args[len(args)-1] == f.password; !authed {
+				fmt.Fprint(c, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			fmt.Fprint(c, "+OK\r\n")
+			continue
+		}
+		if !authed {
+			fmt.Fprint(c, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		fmt.Fprint(c, f.exec(args))
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	ctx := context.Background()
+	bulk := func(b []byte) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(b), b) }
+	switch args[0] {
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, err := f.mem.Get(ctx, args[1])
+		if err != nil {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "SET":
+		var ttl time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch args[i] {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				ms, _ := strconv.Atoi(args[i])
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if nx {
+			if ok, _ := f.mem.Add(ctx, args[1], []byte(args[2]), ttl); !ok {
+				return "$-1\r\n"
+			}
+			return "+OK\r\n"
+		}
+		f.mem.Set(ctx, args[1], []byte(args[2]), ttl)
+		return "+OK\r\n"
+	case "DEL":
+		f.mem.Delete(ctx, args[1])
+		return ":1\r\n"
+	case "EVAL":
+		if args[1] != redisIncrScript {
+			return "-ERR unknown script\r\n"
+		}
+		delta, _ := strconv.ParseInt(args[4], 10, 64)
+		ms, _ := strconv.Atoi(args[5])
+		n, err := f.mem.Incr(ctx, args[3], delta, time.Duration(ms)*time.Millisecond)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "PTTL":
+		ttl, err := f.mem.TTL(ctx, args[1])
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return ":-2\r\n"
+		case ttl == 0:
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", ttl.Milliseconds())
+	case "SCAN":
+		// One page of every key; the glob is reduced to its escaped prefix.
+		prefix := strings.NewReplacer(`\\`, `\`, `\*`, `*`, `\?`, `?`, `\[`, `[`, `\]`, `]`).Replace(strings.TrimSuffix(args[3], "*"))
+		found, _ := f.mem.Scan(ctx, prefix)
+		out := fmt.Sprintf("*2\r\n%s*%d\r\n", bulk([]byte("0")), len(found))
+		for key := range found {
+			out += bulk([]byte(key))
+		}
+		return out
+	case "MGET":
+		out := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, key := range args[1:] {
+			if v, err := f.mem.Get(ctx, key); err == nil {
+				out += bulk(v)
+			} else {
+				out += "$-1\r\n"
+			}
+		}
+		return out
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestRedis(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	now := time.Unix(1700000000, 0)
+	var mu sync.Mutex
+	f.mem.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(passwordFile, []byte("secret\n"), 0o600)
+	r, err := NewRedis(&url.URL{Scheme: "redis", Host: f.addr, Path: "/1"}, passwordFile)
+	if err != nil {
+		t.Fatalf("NewRedis() error = %v", err)
+	}
+	defer r.Close()
+
+	testStore(t, r, func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	})
+
+	// Connections are authenticated once and then reused.
+	f.mu.Lock()
+	commands := strings.Join(f.commands, " ")
+	f.mu.Unlock()
+	if !strings.HasPrefix(commands, "AUTH SELECT GET") || strings.Count(commands, "AUTH") != 1 {
+		t.Errorf("commands = %s, want one AUTH and SELECT before the first GET", commands)
+	}
+}
+
+func TestRedisWrongPassword(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	r, err := NewRedis(&url.URL{Scheme: "redis", Host: f.addr, User: url.UserPassword("default", "wrong")}, "")
+	if err != nil {
+		t.Fatalf("NewRedis() error = %v", err)
+	}
+	if _, err := r.Get(context.Background(), "key"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Get() error = %v, want WRONGPASS", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Package storage is the key-value store behind the stateful gateway
+// features. The in-memory store keeps state per gateway replica; the Redis
+// store shares it across replicas, so counters and lookups see the traffic of
+// every replica.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ErrNotFound is returned for keys that do not exist or have expired.
+var ErrNotFound = errors.New("key not found")
+
+// Interface is a key-value store. Keys are strings namespaced by the feature
+// that owns them, such as "analytics:"; values are opaque bytes, except those
+// of counters, which hold a decimal integer.
+type Interface interface {
+	// Get returns the value of key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key, expiring after ttl; 0 keeps it until it is
+	// overwritten.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add stores value under key like Set, unless key already exists. It
+	// reports whether the value was stored, so only one of several callers
+	// adding the same key wins.
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes key; a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// Incr adds delta to the counter under key, creating it at 0, and returns
+	// the new value. A key without an expiry is given ttl, so a counter
+	// expires ttl after it was first incremented; 0 never expires it.
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// TTL returns the time left before key expires, 0 when it never does, or
+	// ErrNotFound.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Scan returns the keys starting with prefix and their values.
+	Scan(ctx context.Context, prefix string) (map[string][]byte, error)
+}
+
+// Open returns the store at rawURL: memory:// (or "") for an in-memory
+// store, redis://[user@]host:port[/db] or rediss:// for Redis over TLS. The
+// Redis password is read from passwordFile, if set, whenever a connection is
+// opened.
+func Open(rawURL, passwordFile string) (Interface, error) {
+	if rawURL == "" {
+		return NewMemory(), nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage URL: %w", err)
+	}
+	switch u.Scheme {
+	case "memory":
+		return NewMemory(), nil
+	case "redis", "rediss":
+		return NewRedis(u, passwordFile)
+	}
+	return nil, fmt.Errorf("unsupported storage URL scheme %q, want memory, redis or rediss", u.Scheme)
+}
+
+// errNotCounter is returned by Incr for a key holding something else than a
+// counter.
+func errNotCounter(key string) error {
+	return fmt.Errorf("value of %q is not a counter", key)
+}
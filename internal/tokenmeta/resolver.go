@@ -0,0 +1,279 @@
+// Package tokenmeta resolves ERC-20 token metadata (decimals, name and EIP-712
+// version) for assets outside the gateway's built-in registry by calling the
+// token contract through a JSON-RPC endpoint. Results are cached in memory and
+// persisted in a ConfigMap, so restarts do not repeat the chain calls.
+package tokenmeta
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ERC-20 function selectors.
+const (
+	selectorDecimals = "0x313ce567" // decimals()
+	selectorName     = "0x06fdde03" // name()
+	selectorVersion  = "0x54fd4d50" // version(), EIP-2612 tokens only
+)
+
+// defaultVersion is the EIP-712 domain version assumed when a token has no
+// version() function.
+const defaultVersion = "1"
+
+// Metadata describes a token.
+type Metadata struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Decimals int    `json:"decimals"`
+}
+
+// Resolver looks up token metadata. Client and Reader are optional; without
+// them metadata is only cached in memory.
+type Resolver struct {
+	RPCURLs       map[string]string // key: CAIP-2 chain ID, e.g. "eip155:8453"
+	Client        client.Client     // writes the persistent cache
+	Reader        client.Reader     // reads the persistent cache
+	Namespace     string
+	ConfigMapName string
+
+	httpClient *http.Client
+	mu         sync.RWMutex
+	cache      map[string]Metadata // key: cacheKey(chainID, asset)
+}
+
+// NewResolver returns a resolver for the given chain RPC endpoints.
+func NewResolver(rpcURLs map[string]string) *Resolver {
+	return &Resolver{
+		RPCURLs:    rpcURLs,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		cache:      make(map[string]Metadata),
+	}
+}
+
+// ParseRPCURLs parses a comma-separated list of chainID=url pairs.
+func ParseRPCURLs(s string) (map[string]string, error) {
+	urls := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		chainID, url, ok := strings.Cut(pair, "=")
+		if !ok || chainID == "" || url == "" {
+			return nil, fmt.Errorf("invalid chain RPC entry %q, want chainID=url", pair)
+		}
+		urls[chainID] = url
+	}
+	return urls, nil
+}
+
+// Lookup returns the metadata of asset on chainID.
+func (r *Resolver) Lookup(ctx context.Context, chainID, asset string) (Metadata, error) {
+	key := cacheKey(chainID, asset)
+	r.mu.RLock()
+	meta, ok := r.cache[key]
+	r.mu.RUnlock()
+	if ok {
+		return meta, nil
+	}
+
+	if meta, ok := r.loadPersisted(ctx, key); ok {
+		r.remember(key, meta)
+		return meta, nil
+	}
+
+	meta, err := r.resolve(ctx, chainID, asset)
+	if err != nil {
+		return Metadata{}, err
+	}
+	r.remember(key, meta)
+	// A failed write only costs other replicas a chain call.
+	_ = r.persist(ctx, key, meta)
+	return meta, nil
+}
+
+func (r *Resolver) remember(key string, meta Metadata) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache[key] = meta
+}
+
+// cacheKey builds a ConfigMap-safe key; ConfigMap keys may not contain ':'.
+func cacheKey(chainID, asset string) string {
+	return strings.ReplaceAll(chainID, ":", "-") + "." + strings.ToLower(asset)
+}
+
+func (r *Resolver) resolve(ctx context.Context, chainID, asset string) (Metadata, error) {
+	if !strings.HasPrefix(chainID, "eip155:") {
+		return Metadata{}, fmt.Errorf("token metadata lookup is only supported on eip155 chains, not %s", chainID)
+	}
+	rpcURL, ok := r.RPCURLs[chainID]
+	if !ok {
+		return Metadata{}, fmt.Errorf("asset %s on %s is not in the registry and no RPC endpoint is configured for the chain", asset, chainID)
+	}
+
+	out, err := r.call(ctx, rpcURL, asset, selectorDecimals)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("call decimals() on %s: %w", asset, err)
+	}
+	decimals, err := decodeUint8(out)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("decode decimals() of %s: %w", asset, err)
+	}
+
+	out, err = r.call(ctx, rpcURL, asset, selectorName)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("call name() on %s: %w", asset, err)
+	}
+	name, err := decodeString(out)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("decode name() of %s: %w", asset, err)
+	}
+
+	version := defaultVersion
+	if out, err := r.call(ctx, rpcURL, asset, selectorVersion); err == nil {
+		if v, err := decodeString(out); err == nil && v != "" {
+			version = v
+		}
+	}
+	return Metadata{Name: name, Version: version, Decimals: decimals}, nil
+}
+
+// call performs an eth_call of a zero-argument function and returns the raw
+// return data.
+func (r *Resolver) call(ctx context.Context, rpcURL, to, selector string) ([]byte, error) {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params":  []any{map[string]string{"to": to, "data": selector}, "latest"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("RPC returned status %d", resp.StatusCode)
+	}
+
+	var rpcResp struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("decode RPC response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return nil, errors.New(rpcResp.Error.Message)
+	}
+	return hex.DecodeString(strings.TrimPrefix(rpcResp.Result, "0x"))
+}
+
+// decodeUint8 decodes an ABI-encoded uint8.
+func decodeUint8(data []byte) (int, error) {
+	if len(data) != 32 {
+		return 0, fmt.Errorf("want 32 bytes, got %d", len(data))
+	}
+	v := new(big.Int).SetBytes(data)
+	if !v.IsUint64() || v.Uint64() > 255 {
+		return 0, fmt.Errorf("value %s out of range", v)
+	}
+	return int(v.Uint64()), nil
+}
+
+// decodeString decodes an ABI-encoded string. Some early tokens return
+// bytes32 instead, which is decoded as a NUL-padded string.
+func decodeString(data []byte) (string, error) {
+	if len(data) == 32 {
+		return string(bytes.TrimRight(data, "\x00")), nil
+	}
+	if len(data) < 64 {
+		return "", fmt.Errorf("want at least 64 bytes, got %d", len(data))
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(data)) {
+		return "", errors.New("string offset out of range")
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(data[start-32 : start])
+	if !length.IsUint64() || start+length.Uint64() > uint64(len(data)) {
+		return "", errors.New("string length out of range")
+	}
+	return string(data[start : start+length.Uint64()]), nil
+}
+
+func (r *Resolver) loadPersisted(ctx context.Context, key string) (Metadata, bool) {
+	if r.Reader == nil {
+		return Metadata{}, false
+	}
+	var cm corev1.ConfigMap
+	if err := r.Reader.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.ConfigMapName}, &cm); err != nil {
+		return Metadata{}, false
+	}
+	raw, ok := cm.Data[key]
+	if !ok {
+		return Metadata{}, false
+	}
+	var meta Metadata
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+		return Metadata{}, false
+	}
+	return meta, true
+}
+
+// persist records metadata in the cache ConfigMap, creating it on first use.
+func (r *Resolver) persist(ctx context.Context, key string, meta Metadata) error {
+	if r.Client == nil || r.Reader == nil {
+		return nil
+	}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	var cm corev1.ConfigMap
+	err = r.Reader.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.ConfigMapName}, &cm)
+	if apierrors.IsNotFound(err) {
+		cm = corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.Namespace, Name: r.ConfigMapName},
+			Data:       map[string]string{key: string(raw)},
+		}
+		return r.Client.Create(ctx, &cm)
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[key] = string(raw)
+	// A conflicting write from another replica is fine; it will be retried on
+	// that asset's next cache miss.
+	return r.Client.Update(ctx, &cm)
+}
@@ -0,0 +1,165 @@
+package tokenmeta
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// abiString ABI-encodes a string return value.
+func abiString(s string) string {
+	data := make([]byte, 64+((len(s)+31)/32)*32)
+	data[31] = 32
+	data[63] = byte(len(s))
+	copy(data[64:], s)
+	return "0x" + hex.EncodeToString(data)
+}
+
+func newRPC(t *testing.T, calls *atomic.Int32, hasVersion bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var call struct {
+			Data string `json:"data"`
+		}
+		json.Unmarshal(req.Params[0], &call)
+
+		switch {
+		case call.Data == selectorDecimals:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%064x"}`, 18)
+		case call.Data == selectorName:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":%q}`, abiString("Dai Stablecoin"))
+		case call.Data == selectorVersion && hasVersion:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":%q}`, abiString("2"))
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"execution reverted"}}`)
+		}
+	}))
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		hasVersion bool
+		want       Metadata
+	}{
+		{name: "with version", hasVersion: true, want: Metadata{Name: "Dai Stablecoin", Version: "2", Decimals: 18}},
+		{name: "without version", want: Metadata{Name: "Dai Stablecoin", Version: "1", Decimals: 18}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			rpc := newRPC(t, &calls, tt.hasVersion)
+			defer rpc.Close()
+
+			r := NewResolver(map[string]string{"eip155:8453": rpc.URL})
+			got, err := r.Lookup(context.Background(), "eip155:8453", "0xDAI")
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Lookup() = %+v, want %+v", got, tt.want)
+			}
+
+			// The second lookup is served from memory.
+			before := calls.Load()
+			r.Lookup(context.Background(), "eip155:8453", "0xdai")
+			if calls.Load() != before {
+				t.Error("second Lookup() called the RPC endpoint")
+			}
+		})
+	}
+}
+
+func TestLookupPersisted(t *testing.T) {
+	var calls atomic.Int32
+	rpc := newRPC(t, &calls, true)
+	defer rpc.Close()
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	newResolver := func() *Resolver {
+		r := NewResolver(map[string]string{"eip155:8453": rpc.URL})
+		r.Client, r.Reader = c, c
+		r.Namespace, r.ConfigMapName = "x402-system", "x402-token-metadata"
+		return r
+	}
+	want, err := newResolver().Lookup(context.Background(), "eip155:8453", "0xDAI")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+
+	// A fresh resolver, as after a restart, reads the ConfigMap instead of the chain.
+	before := calls.Load()
+	got, err := newResolver().Lookup(context.Background(), "eip155:8453", "0xDAI")
+	if err != nil || got != want {
+		t.Fatalf("Lookup() after restart = %+v, %v, want %+v", got, err, want)
+	}
+	if calls.Load() != before {
+		t.Error("Lookup() after restart called the RPC endpoint")
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	r := NewResolver(map[string]string{})
+	for _, chainID := range []string{"eip155:8453", "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp"} {
+		if _, err := r.Lookup(context.Background(), chainID, "0xDAI"); err == nil {
+			t.Errorf("Lookup(%s) without RPC endpoint succeeded, want error", chainID)
+		}
+	}
+}
+
+func TestDecodeString(t *testing.T) {
+	bytes32, _ := hex.DecodeString("4d4b520000000000000000000000000000000000000000000000000000000000")
+	abi, _ := hex.DecodeString(strings.TrimPrefix(abiString("USD Coin"), "0x"))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "abi string", data: abi, want: "USD Coin"},
+		{name: "bytes32", data: bytes32, want: "MKR"},
+		{name: "truncated", data: abi[:40], wantErr: true},
+		{name: "length out of range", data: abi[:64], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeString(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRPCURLs(t *testing.T) {
+	got, err := ParseRPCURLs("eip155:8453=https://mainnet.base.org, eip155:84532=https://sepolia.base.org")
+	if err != nil {
+		t.Fatalf("ParseRPCURLs() error = %v", err)
+	}
+	if len(got) != 2 || got["eip155:84532"] != "https://sepolia.base.org" {
+		t.Errorf("ParseRPCURLs() = %v", got)
+	}
+	if _, err := ParseRPCURLs("eip155:8453"); err == nil {
+		t.Error("ParseRPCURLs() without url succeeded, want error")
+	}
+}
@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Secrets reads values of key/value secrets. Each secret is cached for the
+// refresh interval, so values changed in Vault are picked up without calling
+// Vault on every use, and a secret that cannot be re-read keeps its last
+// value.
+type Secrets struct {
+	client  *Client
+	refresh time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	data    map[string]any
+	fetched time.Time
+}
+
+// NewSecrets returns a reader of the secrets client can access, caching them
+// for refresh.
+func NewSecrets(client *Client, refresh time.Duration) *Secrets {
+	return &Secrets{client: client, refresh: refresh, cache: make(map[string]cachedSecret)}
+}
+
+// Refresh returns how long values are cached.
+func (s *Secrets) Refresh() time.Duration {
+	return s.refresh
+}
+
+// Value returns the value of key in the secret at path, from a KV engine of
+// version 1 or 2 (paths of version 2 include "data/", e.g.
+// "secret/data/x402").
+func (s *Secrets) Value(ctx context.Context, path, key string) (string, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[path]
+	s.mu.Unlock()
+
+	if !ok || time.Since(cached.fetched) >= s.refresh {
+		data, err := s.read(ctx, path)
+		switch {
+		case err == nil:
+			cached = cachedSecret{data: data, fetched: time.Now()}
+			s.mu.Lock()
+			s.cache[path] = cached
+			s.mu.Unlock()
+		case !ok:
+			return "", err
+		}
+	}
+
+	value, ok := cached.data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string value %q", path, key)
+	}
+	return value, nil
+}
+
+func (s *Secrets) read(ctx context.Context, path string) (map[string]any, error) {
+	var data map[string]any
+	if err := s.client.Read(ctx, path, &data); err != nil {
+		return nil, err
+	}
+	// KV version 2 nests the values next to their metadata.
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			return inner, nil
+		}
+	}
+	return data, nil
+}
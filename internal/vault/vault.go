@@ -0,0 +1,230 @@
+// Package vault is a minimal HashiCorp Vault HTTP client, used for transit
+// signing keys and secret values. It is configured like the Vault CLI, from
+// VAULT_ADDR, VAULT_NAMESPACE and VAULT_TOKEN. Instead of a static token,
+// VAULT_TOKEN_FILE names a token a Vault Agent keeps renewed in a file, and
+// VAULT_KUBERNETES_ROLE logs in with the pod's service account token through
+// the Kubernetes auth method, renewing the Vault token as its lease runs out.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountTokenFile is the pod's service account token, used to log in
+// with the Kubernetes auth method; replaced in tests.
+var serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Client calls the Vault HTTP API.
+type Client struct {
+	Addr      string
+	Namespace string
+	// TokenFile holds the Vault token. It is re-read on each request, so a
+	// token renewed by Vault Agent is picked up. Empty uses Token.
+	TokenFile string
+	Token     string
+	// KubernetesRole logs in with the Kubernetes auth method mounted at
+	// KubernetesMount (default "kubernetes") when no token is set.
+	KubernetesRole  string
+	KubernetesMount string
+
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	login loginToken
+}
+
+// loginToken is a token obtained by logging in.
+type loginToken struct {
+	token     string
+	renewable bool
+	renewAt   time.Time // half of the lease
+	expires   time.Time
+}
+
+// NewClient returns a client for the Vault server at addr.
+func NewClient(addr string) (*Client, error) {
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Vault address %q", addr)
+	}
+	return &Client{Addr: strings.TrimSuffix(addr, "/"), httpClient: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// FromEnv returns a client configured by the VAULT_* environment variables.
+func FromEnv() (*Client, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+	c, err := NewClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.Namespace = os.Getenv("VAULT_NAMESPACE")
+	c.Token = os.Getenv("VAULT_TOKEN")
+	c.TokenFile = os.Getenv("VAULT_TOKEN_FILE")
+	c.KubernetesRole = os.Getenv("VAULT_KUBERNETES_ROLE")
+	c.KubernetesMount = os.Getenv("VAULT_KUBERNETES_MOUNT")
+	return c, nil
+}
+
+// Read decodes the data of the secret or object at path into out.
+func (c *Client) Read(ctx context.Context, path string, out any) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Write sends in to path and decodes the data of the answer into out, which
+// may be nil.
+func (c *Client) Write(ctx context.Context, path string, in, out any) error {
+	return c.do(ctx, http.MethodPost, path, in, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	raw, err := c.send(ctx, method, path, token, in)
+	if err != nil || out == nil {
+		return err
+	}
+	var answer struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return fmt.Errorf("vault %s %s: decode answer: %w", method, path, err)
+	}
+	return json.Unmarshal(answer.Data, out)
+}
+
+// send calls the API with token, which may be empty, and returns the answer.
+func (c *Client) send(ctx context.Context, method, path, token string, in any) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Addr+"/v1/"+strings.TrimPrefix(path, "/"), body)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		var answer struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(raw, &answer)
+		return nil, fmt.Errorf("vault %s %s: status %d: %s", method, path, resp.StatusCode, strings.Join(answer.Errors, "; "))
+	}
+	return raw, nil
+}
+
+func (c *Client) token(ctx context.Context) (string, error) {
+	switch {
+	case c.TokenFile != "":
+		token, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read Vault token: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	case c.Token != "":
+		return c.Token, nil
+	case c.KubernetesRole != "":
+		return c.loginToken(ctx)
+	}
+	return "", errors.New("no Vault token configured")
+}
+
+// loginToken returns the token of the Kubernetes auth login. Past half of its
+// lease the token is renewed, or a new one obtained when it cannot be; if
+// Vault is unreachable the current token is used until it expires.
+func (c *Client) loginToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	current := c.login
+	if current.token != "" && now.Before(current.renewAt) {
+		return current.token, nil
+	}
+	if current.token != "" && current.renewable && now.Before(current.expires) {
+		if renewed, err := c.authenticate(ctx, "auth/token/renew-self", current.token, nil); err == nil {
+			c.login = renewed
+			return renewed.token, nil
+		}
+	}
+
+	jwt, err := os.ReadFile(serviceAccountTokenFile)
+	if err == nil {
+		mount := c.KubernetesMount
+		if mount == "" {
+			mount = "kubernetes"
+		}
+		var login loginToken
+		login, err = c.authenticate(ctx, "auth/"+mount+"/login", "", map[string]string{"role": c.KubernetesRole, "jwt": strings.TrimSpace(string(jwt))})
+		if err == nil {
+			c.login = login
+			return login.token, nil
+		}
+	}
+	if current.token != "" && now.Before(current.expires) {
+		return current.token, nil
+	}
+	return "", fmt.Errorf("log in to Vault as role %s: %w", c.KubernetesRole, err)
+}
+
+// authenticate calls an endpoint answering with an auth envelope, such as a
+// login or renewal.
+func (c *Client) authenticate(ctx context.Context, path, token string, in any) (loginToken, error) {
+	raw, err := c.send(ctx, http.MethodPost, path, token, in)
+	if err != nil {
+		return loginToken{}, err
+	}
+	var answer struct {
+		Auth *struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+			Renewable     bool   `json:"renewable"`
+		} `json:"auth"`
+	}
+	if err := json.Unmarshal(raw, &answer); err != nil || answer.Auth == nil || answer.Auth.ClientToken == "" {
+		return loginToken{}, fmt.Errorf("vault %s: answer has no token", path)
+	}
+	now := time.Now()
+	lease := time.Duration(answer.Auth.LeaseDuration) * time.Second
+	login := loginToken{token: answer.Auth.ClientToken, renewable: answer.Auth.Renewable, renewAt: now.Add(lease / 2), expires: now.Add(lease)}
+	if lease == 0 {
+		// Tokens without a lease, such as root tokens, never expire.
+		login.renewAt = now.Add(100 * 365 * 24 * time.Hour)
+		login.expires = login.renewAt
+	}
+	return login, nil
+}
@@ -0,0 +1,163 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKubernetesLogin(t *testing.T) {
+	jwt := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(jwt, []byte("sa-jwt\n"), 0o600)
+	prev := serviceAccountTokenFile
+	serviceAccountTokenFile = jwt
+	t.Cleanup(func() { serviceAccountTokenFile = prev })
+
+	var logins, renewals atomic.Int32
+	var renewFails atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/k8s/login":
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			if in["role"] != "x402" || in["jwt"] != "sa-jwt" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			logins.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": "login-token", "lease_duration": 2, "renewable": true}})
+		case "/v1/auth/token/renew-self":
+			if renewFails.Load() || r.Header.Get("X-Vault-Token") != "login-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			renewals.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": "login-token", "lease_duration": 2, "renewable": true}})
+		case "/v1/secret/app":
+			if r.Header.Get("X-Vault-Token") != "login-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"key": "value"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.KubernetesRole, c.KubernetesMount = "x402", "k8s"
+	read := func() {
+		t.Helper()
+		var data map[string]string
+		if err := c.Read(context.Background(), "secret/app", &data); err != nil || data["key"] != "value" {
+			t.Fatalf("Read() = %v, %v", data, err)
+		}
+	}
+
+	read()
+	read()
+	if logins.Load() != 1 || renewals.Load() != 0 {
+		t.Fatalf("logins, renewals = %d, %d, want 1, 0 within the first half of the lease", logins.Load(), renewals.Load())
+	}
+
+	// Past half of the lease the token is renewed.
+	time.Sleep(1100 * time.Millisecond)
+	read()
+	if logins.Load() != 1 || renewals.Load() != 1 {
+		t.Fatalf("logins, renewals = %d, %d, want 1, 1 after half of the lease", logins.Load(), renewals.Load())
+	}
+
+	// A token that cannot be renewed is replaced by a new login.
+	renewFails.Store(true)
+	time.Sleep(1100 * time.Millisecond)
+	read()
+	if logins.Load() != 2 {
+		t.Fatalf("logins = %d, want a new login when renewal fails", logins.Load())
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	var reads atomic.Int32
+	var down atomic.Bool
+	value := "v1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"Vault is sealed"}})
+			return
+		}
+		reads.Add(1)
+		switch r.URL.Path {
+		case "/v1/kv1/app":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"key": value, "number": 1}})
+		case "/v1/secret/data/app":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+				"data":     map[string]any{"key": value},
+				"metadata": map[string]any{"version": 3},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+		}
+	}))
+	defer srv.Close()
+	c, _ := NewClient(srv.URL)
+	c.Token = "root"
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		path    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "kv version 1", path: "kv1/app", key: "key", want: "v1"},
+		{name: "kv version 2", path: "secret/data/app", key: "key", want: "v1"},
+		{name: "not a string", path: "kv1/app", key: "number", wantErr: true},
+		{name: "missing key", path: "kv1/app", key: "other", wantErr: true},
+		{name: "missing secret", path: "kv1/other", key: "key", wantErr: true},
+	}
+	s := NewSecrets(c, time.Hour)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Value(ctx, tt.path, tt.key)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Value() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// Values are cached for the refresh interval, then re-read.
+	s = NewSecrets(c, 50*time.Millisecond)
+	s.Value(ctx, "kv1/app", "key")
+	before := reads.Load()
+	value = "v2"
+	if got, _ := s.Value(ctx, "kv1/app", "key"); got != "v1" || reads.Load() != before {
+		t.Errorf("cached Value() = %q after %d reads, want v1 without a read", got, reads.Load()-before)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got, _ := s.Value(ctx, "kv1/app", "key"); got != "v2" {
+		t.Errorf("refreshed Value() = %q, want v2", got)
+	}
+
+	// An unreachable Vault keeps serving the last value.
+	down.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if got, err := s.Value(ctx, "kv1/app", "key"); err != nil || got != "v2" {
+		t.Errorf("Value() with Vault down = %q, %v, want the last value", got, err)
+	}
+	if _, err := s.Value(ctx, "secret/data/app", "key"); err == nil {
+		t.Error("Value() of a never read secret with Vault down succeeded")
+	}
+}
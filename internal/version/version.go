@@ -0,0 +1,29 @@
+// Package version holds the build information of the operator binary, set at
+// build time with -ldflags:
+//
+//	-X github.com/razvanmacovei/x402-k8s-operator/internal/version.Version=v1.2.3
+//	-X github.com/razvanmacovei/x402-k8s-operator/internal/version.Commit=abc1234
+//	-X github.com/razvanmacovei/x402-k8s-operator/internal/version.BuildDate=2026-01-02T15:04:05Z
+package version
+
+import "runtime"
+
+// Build information. Unset values are reported as "dev" or "unknown".
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info is the build information of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	return Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+}
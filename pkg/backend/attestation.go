@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+// HeaderVerified is the request header in which a trusted upstream tier,
+// such as an edge gateway, attests that it verified the request's payment.
+// A gateway that trusts the tier settles the payment without verifying it
+// again with the facilitator.
+const HeaderVerified = "X-402-Verified"
+
+// attestationType is the typ header of attestations, which keeps them apart
+// from context tokens signed with the same keys.
+const attestationType = "x402-verified+jwt"
+
+// MaxAttestationTTL bounds the lifetime of an attestation: it only has to
+// outlive the hop from the upstream tier to the gateway.
+const MaxAttestationTTL = time.Minute
+
+// attestationSkew is the clock skew tolerated between the tiers.
+const attestationSkew = 5 * time.Second
+
+var (
+	// ErrAudience is returned for attestations made for another gateway.
+	ErrAudience = errors.New("x402 attestation for another audience")
+	// ErrAttestationLifetime is returned for attestations issued in the
+	// future or valid for longer than MaxAttestationTTL.
+	ErrAttestationLifetime = errors.New("x402 attestation lifetime out of bounds")
+)
+
+// Attestation is the claim of an upstream tier that a payment was verified.
+// It is bound to one Payment-Signature header, to the requirements it was
+// verified against and to the facilitator that verified it, so it cannot
+// vouch for another payment, price or asset.
+type Attestation struct {
+	Issuer   string `json:"iss"` // the upstream tier, for logs
+	Audience string `json:"aud"` // the gateway the attestation is for
+	Payer    string `json:"sub,omitempty"`
+	Payment  string `json:"pay"` // PaymentDigest of the Payment-Signature header
+	Scheme   string `json:"scheme"`
+	Network  string `json:"network"`
+	Asset    string `json:"asset"`
+	Amount   string `json:"amount"` // in atomic units, as in the payment requirements
+	PayTo    string `json:"payTo"`
+	// Facilitator is the URL of the facilitator that verified the payment,
+	// which the gateway asks to settle it.
+	Facilitator string `json:"facilitator"`
+	IssuedAt    int64  `json:"iat"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+// PaymentDigest returns the digest of a Payment-Signature header that binds
+// an attestation to it.
+func PaymentDigest(paymentHeader string) string {
+	sum := sha256.Sum256([]byte(paymentHeader))
+	return b64.EncodeToString(sum[:])
+}
+
+// SignAttestation mints an attestation with the key set's active key.
+func SignAttestation(ctx context.Context, keys *KeySet, a Attestation) (string, error) {
+	return signToken(ctx, keys, attestationType, a)
+}
+
+// VerifyAttestation checks the attestation's signature against any key in
+// the set, that it is meant for audience and that now falls within its
+// lifetime, which may not exceed MaxAttestationTTL, and returns its claims.
+// Callers must still match the claims against the request's payment.
+func VerifyAttestation(keys *KeySet, token, audience string, now time.Time) (*Attestation, error) {
+	var a Attestation
+	header, err := verifyToken(keys, token, &a)
+	if err != nil {
+		return nil, err
+	}
+	if header.Typ != attestationType || a.Payment == "" {
+		return nil, ErrInvalidToken
+	}
+	if audience == "" || a.Audience != audience {
+		return nil, ErrAudience
+	}
+	if a.IssuedAt > now.Add(attestationSkew).Unix() || a.ExpiresAt-a.IssuedAt > int64(MaxAttestationTTL/time.Second) {
+		return nil, ErrAttestationLifetime
+	}
+	if now.Unix() >= a.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &a, nil
+}
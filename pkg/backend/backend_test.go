@@ -0,0 +1,275 @@
+package backend
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/signer"
+)
+
+func testClaims(now time.Time) Claims {
+	return Claims{
+		Issuer:    Issuer,
+		Payer:     "0xPayer",
+		Amount:    "0.001",
+		Network:   "base-sepolia",
+		Route:     "default/my-api",
+		Path:      "/api/data",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	}
+}
+
+func mustKeySet(t *testing.T, active string, keys map[string][]byte) *KeySet {
+	t.Helper()
+	ks, err := NewKeySet(active, keys)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	return ks
+}
+
+func ed25519PEM(t *testing.T) []byte {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestSignVerify(t *testing.T) {
+	now := time.Now()
+	keys := mustKeySet(t, "k1", map[string][]byte{"k1": []byte("secret-1")})
+	token, err := Sign(keys, testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    *KeySet
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", keys: keys, token: token, now: now},
+		{name: "expired", keys: keys, token: token, now: now.Add(2 * time.Minute), wantErr: ErrExpiredToken},
+		{name: "tampered", keys: keys, token: token[:len(token)-2] + "xx", now: now, wantErr: ErrInvalidToken},
+		{name: "wrong key", keys: mustKeySet(t, "k1", map[string][]byte{"k1": []byte("other")}), token: token, now: now, wantErr: ErrInvalidToken},
+		{name: "unknown key", keys: mustKeySet(t, "k2", map[string][]byte{"k2": []byte("secret-1")}), token: token, now: now, wantErr: ErrUnknownKey},
+		{name: "malformed", keys: keys, token: "not-a-token", now: now, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Verify(tt.keys, tt.token, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.Payer != "0xPayer" {
+				t.Errorf("Verify() payer = %q, want 0xPayer", claims.Payer)
+			}
+		})
+	}
+}
+
+func TestLoadKeyDirRotation(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("k1", "secret-1")
+	write(ActiveKeyFile, "k1\n")
+
+	keys, err := LoadKeyDir(dir, 0)
+	if err != nil {
+		t.Fatalf("LoadKeyDir() error = %v", err)
+	}
+	oldToken, _ := Sign(keys, testClaims(time.Now()))
+
+	// Rotate: add k2 and make it active; tokens signed with k1 stay valid.
+	write("k2", "secret-2")
+	write(ActiveKeyFile, "k2")
+	if kid, _, err := keys.Active(); err != nil || kid != "k2" {
+		t.Fatalf("Active() = %q, %v, want k2", kid, err)
+	}
+	if _, err := Verify(keys, oldToken, time.Now()); err != nil {
+		t.Errorf("Verify() of token signed before rotation error = %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	now := time.Now()
+	keys := mustKeySet(t, "ed1", map[string][]byte{"ed1": ed25519PEM(t), "hmac": []byte("secret-1")})
+	token, err := Sign(keys, testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	set := keys.JWKS()
+	if len(set.Keys) != 1 || set.Keys[0].KeyID != "ed1" || set.Keys[0].Alg != "EdDSA" {
+		t.Fatalf("JWKS() = %+v, want only the Ed25519 key", set.Keys)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keys.JWKS())
+	}))
+	defer srv.Close()
+	remote, err := LoadJWKS(srv.URL, time.Minute)
+	if err != nil {
+		t.Fatalf("LoadJWKS() error = %v", err)
+	}
+	if claims, err := Verify(remote, token, now); err != nil || claims.Payer != "0xPayer" {
+		t.Errorf("Verify() with JWKS = %v, %v, want verified claims", claims, err)
+	}
+	if _, _, err := remote.Active(); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Active() on verify-only set error = %v, want ErrUnknownKey", err)
+	}
+
+	// An HS256 token must not verify against an Ed25519 key, even when its
+	// secret is the public key an attacker can read from the JWKS.
+	forged := mustKeySet(t, "ed1", map[string][]byte{"ed1": keys.keys["ed1"].verifier.Public().(ed25519.PublicKey)})
+	forgedToken, _ := Sign(forged, testClaims(now))
+	if _, err := Verify(remote, forgedToken, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() of HS256 token against Ed25519 key error = %v, want ErrInvalidToken", err)
+	}
+}
+
+// remoteSigner hides everything but the Signer methods, like a KMS key.
+type remoteSigner struct{ signer.Signer }
+
+func TestSignerKeySet(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	keys, err := NewSignerKeySet("kms1", map[string]signer.Signer{"kms1": remoteSigner{signer.NewEd25519(private)}})
+	if err != nil {
+		t.Fatalf("NewSignerKeySet() error = %v", err)
+	}
+	now := time.Now()
+	token, err := SignContext(context.Background(), keys, testClaims(now))
+	if err != nil {
+		t.Fatalf("SignContext() error = %v", err)
+	}
+	if claims, err := Verify(keys, token, now); err != nil || claims.Payer != "0xPayer" {
+		t.Errorf("Verify() = %v, %v, want verified claims", claims, err)
+	}
+	if set := keys.JWKS(); len(set.Keys) != 1 || set.Keys[0].KeyID != "kms1" {
+		t.Errorf("JWKS() = %+v, want the kms1 public key", set)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := mustKeySet(t, "k1", map[string][]byte{"k1": []byte("secret-1")})
+	token, _ := Sign(keys, testClaims(time.Now()))
+	h := Middleware(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("FromContext() found no claims")
+			return
+		}
+		w.Write([]byte(claims.Payer))
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{name: "valid", path: "/api/data", token: token, wantStatus: http.StatusOK},
+		{name: "missing", path: "/api/data", wantStatus: http.StatusUnauthorized},
+		{name: "other path", path: "/api/other", token: token, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set(HeaderContext, tt.token)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAttestation(t *testing.T) {
+	now := time.Now()
+	keys := mustKeySet(t, "k1", map[string][]byte{"k1": []byte("secret-1")})
+	attestation := func(change func(a *Attestation)) string {
+		a := Attestation{
+			Issuer:    "edge",
+			Audience:  "inner",
+			Payer:     "0xPayer",
+			Payment:   PaymentDigest("payment"),
+			Network:   "eip155:84532",
+			Amount:    "1000",
+			PayTo:     "0xWallet",
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(30 * time.Second).Unix(),
+		}
+		if change != nil {
+			change(&a)
+		}
+		token, err := SignAttestation(context.Background(), keys, a)
+		if err != nil {
+			t.Fatalf("SignAttestation() error = %v", err)
+		}
+		return token
+	}
+	contextToken, _ := Sign(keys, testClaims(now))
+
+	tests := []struct {
+		name     string
+		token    string
+		audience string
+		now      time.Time
+		wantErr  error
+	}{
+		{name: "valid", token: attestation(nil), audience: "inner", now: now},
+		{name: "other audience", token: attestation(nil), audience: "other", now: now, wantErr: ErrAudience},
+		{name: "no audience configured", token: attestation(func(a *Attestation) { a.Audience = "" }), now: now, wantErr: ErrAudience},
+		{name: "expired", token: attestation(nil), audience: "inner", now: now.Add(time.Minute), wantErr: ErrExpiredToken},
+		{name: "issued in the future", token: attestation(func(a *Attestation) { a.IssuedAt = now.Add(time.Minute).Unix() }), audience: "inner", now: now, wantErr: ErrAttestationLifetime},
+		{name: "lives too long", token: attestation(func(a *Attestation) { a.ExpiresAt = now.Add(time.Hour).Unix() }), audience: "inner", now: now, wantErr: ErrAttestationLifetime},
+		{name: "unbound", token: attestation(func(a *Attestation) { a.Payment = "" }), audience: "inner", now: now, wantErr: ErrInvalidToken},
+		{name: "context token", token: contextToken, audience: "inner", now: now, wantErr: ErrInvalidToken},
+		{name: "tampered", token: attestation(nil) + "x", audience: "inner", now: now, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := VerifyAttestation(keys, tt.token, tt.audience, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAttestation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (a.Payer != "0xPayer" || a.Payment != PaymentDigest("payment")) {
+				t.Errorf("VerifyAttestation() = %+v, want the attested payment", a)
+			}
+		})
+	}
+
+	// Attestations never pass for context tokens.
+	if _, err := Verify(keys, attestation(func(a *Attestation) { a.Issuer = Issuer }), now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() of an attestation error = %v, want %v", err, ErrInvalidToken)
+	}
+}
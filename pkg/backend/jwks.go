@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/signer"
+)
+
+// JWKSPath is where the gateway serves the public keys of its key set.
+const JWKSPath = "/.well-known/x402/jwks.json"
+
+// JWKS is a JSON Web Key Set (RFC 7517).
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is an Ed25519 public key in JWK form (RFC 8037).
+type JWK struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	KeyID   string `json:"kid"`
+	X       string `json:"x"`
+	Alg     string `json:"alg"`
+	Use     string `json:"use"`
+}
+
+// JWKS returns the public keys of the set's Ed25519 keys. HMAC secrets are
+// never published.
+func (ks *KeySet) JWKS() JWKS {
+	ks.maybeReload()
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for id, key := range ks.keys {
+		public, ok := key.verifier.Public().(ed25519.PublicKey)
+		if !ok {
+			continue
+		}
+		set.Keys = append(set.Keys, JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			KeyID:   id,
+			X:       b64.EncodeToString(public),
+			Alg:     "EdDSA",
+			Use:     "sig",
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// jwksClient fetches remote key sets.
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// LoadJWKS returns a verify-only key set fetched from a JWKS URL, such as the
+// gateway's JWKSPath. The set is re-fetched at most once per refresh interval,
+// so keys added during a rotation are picked up automatically.
+func LoadJWKS(url string, refresh time.Duration) (*KeySet, error) {
+	return newReloadingKeySet(func() (string, map[string]signingKey, error) {
+		return fetchJWKS(url)
+	}, refresh)
+}
+
+func fetchJWKS(url string) (string, map[string]signingKey, error) {
+	resp, err := jwksClient.Get(url)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return "", nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+	keys := make(map[string]signingKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" {
+			continue
+		}
+		public, err := b64.DecodeString(jwk.X)
+		if err != nil || len(public) != ed25519.PublicKeySize {
+			return "", nil, fmt.Errorf("JWKS key %s: invalid Ed25519 public key", jwk.KeyID)
+		}
+		keys[jwk.KeyID] = signingKey{verifier: signer.NewEd25519Verifier(public)}
+	}
+	return "", keys, nil
+}
@@ -0,0 +1,229 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/signer"
+)
+
+// ActiveKeyFile names the entry of a key directory (or Secret) that holds the
+// ID of the key used for signing. Every other entry is a key, named by its ID:
+// either a raw HMAC secret (HS256) or a PEM-encoded PKCS#8 Ed25519 private key
+// (EdDSA). Ed25519 public keys are published as a JWKS, so backends can verify
+// tokens without holding the signing secret.
+const ActiveKeyFile = "active"
+
+// signingKey pairs a signer with its verifier. Keys loaded from a JWKS carry
+// only the verifier and cannot sign.
+type signingKey struct {
+	signer   signer.Signer // nil for verify-only keys
+	verifier signer.Verifier
+}
+
+func (k signingKey) alg() string {
+	return k.verifier.Algorithm()
+}
+
+// parseKey reads a raw HMAC secret or a PEM-encoded Ed25519 private key.
+func parseKey(data []byte) (signingKey, error) {
+	s, err := signer.ParsePrivateKey(data)
+	if err != nil {
+		return signingKey{}, err
+	}
+	return newSigningKey(s)
+}
+
+func newSigningKey(s signer.Signer) (signingKey, error) {
+	v, err := signer.NewVerifier(s)
+	if err != nil {
+		return signingKey{}, err
+	}
+	return signingKey{signer: s, verifier: v}, nil
+}
+
+// KeySet holds the keys used to sign and verify context tokens. Tokens are
+// signed with the active key and accepted with any key in the set, so a key
+// can be rotated by adding it, switching the active ID, and removing the old
+// key once tokens signed with it have expired.
+type KeySet struct {
+	mu      sync.RWMutex
+	active  string
+	keys    map[string]signingKey
+	load    func() (string, map[string]signingKey, error) // nil for static sets
+	refresh time.Duration
+	loaded  time.Time
+}
+
+// NewKeySet returns a static key set. Each value is a raw HMAC secret or a
+// PEM-encoded Ed25519 private key.
+func NewKeySet(active string, keys map[string][]byte) (*KeySet, error) {
+	parsed := make(map[string]signingKey, len(keys))
+	for id, data := range keys {
+		key, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		parsed[id] = key
+	}
+	return &KeySet{active: active, keys: parsed}, nil
+}
+
+// NewSignerKeySet returns a static key set of signers, such as keys held in
+// a KMS. Signatures are verified locally with their public keys.
+func NewSignerKeySet(active string, signers map[string]signer.Signer) (*KeySet, error) {
+	keys := make(map[string]signingKey, len(signers))
+	for id, s := range signers {
+		key, err := newSigningKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return &KeySet{active: active, keys: keys}, nil
+}
+
+// LoadKeyDir returns a key set read from a directory, such as a mounted
+// Secret. The directory is re-read at most once per refresh interval, so
+// rotated Secret contents are picked up without a restart.
+func LoadKeyDir(dir string, refresh time.Duration) (*KeySet, error) {
+	return newReloadingKeySet(func() (string, map[string]signingKey, error) {
+		return readKeyDir(dir)
+	}, refresh)
+}
+
+// LoadKeyURI returns a key set signing with the key at uri, such as
+// "vault-transit://transit/x402-context", opened by the signer registered for
+// its scheme (see signer.Register). Keys with versions (signer.Ring) sign with
+// their active version and verify with any; the versions are re-listed at
+// most once per refresh interval, so a key rotated in the KMS is picked up.
+func LoadKeyURI(ctx context.Context, uri string, refresh time.Duration) (*KeySet, error) {
+	s, err := signer.Open(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	ring, ok := s.(signer.Ring)
+	if !ok {
+		return NewSignerKeySet(path.Base(uri), map[string]signer.Signer{path.Base(uri): s})
+	}
+	return newReloadingKeySet(func() (string, map[string]signingKey, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		active, versions, err := ring.Versions(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("list versions of %s: %w", uri, err)
+		}
+		keys := make(map[string]signingKey, len(versions))
+		for id, v := range versions {
+			if keys[id], err = newSigningKey(v); err != nil {
+				return "", nil, fmt.Errorf("key %s: %w", id, err)
+			}
+		}
+		return active, keys, nil
+	}, refresh)
+}
+
+func newReloadingKeySet(load func() (string, map[string]signingKey, error), refresh time.Duration) (*KeySet, error) {
+	ks := &KeySet{load: load, refresh: refresh}
+	if err := ks.reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Active returns the ID and algorithm of the signing key.
+func (ks *KeySet) Active() (string, string, error) {
+	key, err := ks.activeKey()
+	if err != nil {
+		return "", "", err
+	}
+	return ks.activeID(), key.alg(), nil
+}
+
+func (ks *KeySet) activeID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+func (ks *KeySet) activeKey() (signingKey, error) {
+	ks.maybeReload()
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[ks.active]
+	if !ok || key.signer == nil {
+		return signingKey{}, fmt.Errorf("active key %q: %w", ks.active, ErrUnknownKey)
+	}
+	return key, nil
+}
+
+func (ks *KeySet) key(id string) (signingKey, bool) {
+	ks.maybeReload()
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[id]
+	return key, ok
+}
+
+func (ks *KeySet) maybeReload() {
+	if ks.load == nil {
+		return
+	}
+	ks.mu.RLock()
+	stale := time.Since(ks.loaded) >= ks.refresh
+	ks.mu.RUnlock()
+	if stale {
+		// Keep serving the previous keys if the source is mid-update or unreachable.
+		_ = ks.reload()
+	}
+}
+
+func (ks *KeySet) reload() error {
+	active, keys, err := ks.load()
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.active, ks.keys, ks.loaded = active, keys, time.Now()
+	return nil
+}
+
+func readKeyDir(dir string) (string, map[string]signingKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("read key directory: %w", err)
+	}
+
+	var active string
+	keys := make(map[string]signingKey)
+	for _, e := range entries {
+		// Skip kubelet's ..data and timestamped directories of Secret volumes.
+		if strings.HasPrefix(e.Name(), ".") || e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return "", nil, fmt.Errorf("read key %s: %w", e.Name(), err)
+		}
+		if e.Name() == ActiveKeyFile {
+			active = strings.TrimSpace(string(data))
+			continue
+		}
+		key, err := parseKey(data)
+		if err != nil {
+			return "", nil, fmt.Errorf("key %s: %w", e.Name(), err)
+		}
+		keys[e.Name()] = key
+	}
+	if _, ok := keys[active]; !ok {
+		return "", nil, fmt.Errorf("key directory %s: active key %q not found", dir, active)
+	}
+	return active, keys, nil
+}
@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type claimsKey struct{}
+
+// Middleware rejects requests without a valid X-402-Context header with 401
+// and makes the verified claims available through FromContext. Tokens are
+// bound to the request path, so a token cannot be replayed against another
+// endpoint. The gateway only signs paid requests; wrap the handlers of paid
+// paths, not free ones.
+func Middleware(keys *KeySet, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(HeaderContext)
+		if token == "" {
+			http.Error(w, "missing x402 payment context", http.StatusUnauthorized)
+			return
+		}
+		claims, err := Verify(keys, token, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if claims.Path != r.URL.Path {
+			http.Error(w, "x402 payment context was issued for another path", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	})
+}
+
+// FromContext returns the payment claims verified by Middleware.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
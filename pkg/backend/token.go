@@ -0,0 +1,155 @@
+// Package backend lets services behind the x402 gateway trust the payment
+// context the gateway forwards. The gateway signs an X-402-Context header (a
+// JWT using HS256 or EdDSA) for every paid request it proxies; Middleware
+// verifies it against a shared key set mounted from a Kubernetes Secret, or
+// against the public keys the gateway publishes at JWKSPath.
+package backend
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HeaderContext is the request header carrying the signed payment context.
+const HeaderContext = "X-402-Context"
+
+// Issuer is the iss claim of tokens minted by the gateway.
+const Issuer = "x402-gateway"
+
+var (
+	// ErrInvalidToken is returned for malformed tokens and bad signatures.
+	ErrInvalidToken = errors.New("invalid x402 context token")
+	// ErrExpiredToken is returned for tokens past their expiry.
+	ErrExpiredToken = errors.New("expired x402 context token")
+	// ErrUnknownKey is returned when the token's key ID is not in the key set.
+	ErrUnknownKey = errors.New("unknown x402 context signing key")
+)
+
+// Settlement states of a payment context.
+const (
+	// SettlementSettled means Amount was charged before the request was
+	// forwarded.
+	SettlementSettled = "settled"
+	// SettlementAuthorized means the payment settles after the request is
+	// forwarded, so Amount is the most it may charge and may never be paid.
+	SettlementAuthorized = "authorized"
+)
+
+// Claims is the payment context asserted by the gateway.
+type Claims struct {
+	Issuer      string `json:"iss"`
+	Payer       string `json:"sub,omitempty"`
+	Amount      string `json:"amount"` // in atomic units of Asset, as in the payment requirements
+	Asset       string `json:"asset"`
+	Settlement  string `json:"settlement"` // SettlementSettled or SettlementAuthorized
+	Network     string `json:"network"`
+	Transaction string `json:"tx,omitempty"`
+	Route       string `json:"route"` // "namespace/name" of the X402Route
+	Path        string `json:"path"`
+	IssuedAt    int64  `json:"iat"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+// tokenType is the typ header of context tokens.
+const tokenType = "JWT"
+
+type tokenHeader struct {
+	Alg   string `json:"alg"`
+	KeyID string `json:"kid"`
+	Typ   string `json:"typ"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// Sign mints a token for claims with the key set's active key.
+func Sign(keys *KeySet, claims Claims) (string, error) {
+	return SignContext(context.Background(), keys, claims)
+}
+
+// SignContext is Sign with a context bounding signers that call out, such as
+// keys held in a KMS.
+func SignContext(ctx context.Context, keys *KeySet, claims Claims) (string, error) {
+	return signToken(ctx, keys, tokenType, claims)
+}
+
+// signToken mints a token of type typ for claims with the key set's active
+// key.
+func signToken(ctx context.Context, keys *KeySet, typ string, claims any) (string, error) {
+	key, err := keys.activeKey()
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(tokenHeader{Alg: key.alg(), KeyID: keys.activeID(), Typ: typ})
+	if err != nil {
+		return "", fmt.Errorf("marshal token header: %w", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("marshal token claims: %w", err)
+	}
+	signingInput := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	sig, err := key.signer.Sign(ctx, []byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("sign token: %w", err)
+	}
+	return signingInput + "." + b64.EncodeToString(sig), nil
+}
+
+// Verify checks the token's signature against any key in the set and its
+// expiry against now, and returns its claims.
+func Verify(keys *KeySet, token string, now time.Time) (*Claims, error) {
+	var claims Claims
+	header, err := verifyToken(keys, token, &claims)
+	// Attestations are signed with keys that may also sign context tokens,
+	// so they must never pass for one.
+	if err != nil || header.Typ == attestationType || claims.Issuer != Issuer {
+		return nil, cmp.Or(err, ErrInvalidToken)
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+// verifyToken checks the token's signature against any key in the set,
+// decodes its claims into claims and returns its header.
+func verifyToken(keys *KeySet, token string, claims any) (*tokenHeader, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, ok := keys.key(header.KeyID)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// The algorithm is fixed by the key, never chosen by the token.
+	if header.Alg != key.alg() {
+		return nil, ErrInvalidToken
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || !key.verifier.Verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &header, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := b64.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
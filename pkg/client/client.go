@@ -0,0 +1,110 @@
+// Package client implements the client side of the x402 payment protocol for
+// Go HTTP clients. Transport detects 402 Payment Required responses, asks a
+// Signer for a payment payload and retries the request with it attached.
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// HeaderPaymentRequired carries the Base64-encoded payment requirements of a 402 response.
+	HeaderPaymentRequired = "PAYMENT-REQUIRED"
+	// HeaderPaymentSignature carries the Base64-encoded payment payload of a paid request.
+	HeaderPaymentSignature = "Payment-Signature"
+	// HeaderPaymentResponse carries the Base64-encoded settlement of a paid response.
+	HeaderPaymentResponse = "PAYMENT-RESPONSE"
+)
+
+// ErrNoPaymentRequired is returned when a response carries no PAYMENT-REQUIRED header.
+var ErrNoPaymentRequired = errors.New("response has no PAYMENT-REQUIRED header")
+
+// PaymentRequired is the decoded PAYMENT-REQUIRED header of a 402 response.
+type PaymentRequired struct {
+	X402Version int       `json:"x402Version"`
+	Resource    *Resource `json:"resource"`
+	Accepts     []Accept  `json:"accepts"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Resource describes the resource being paid for.
+type Resource struct {
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Accept is a single accepted payment method.
+type Accept struct {
+	Scheme            string `json:"scheme"`
+	Network           string `json:"network"`
+	Amount            string `json:"amount"` // atomic units of Asset
+	PayTo             string `json:"payTo"`
+	MaxTimeoutSeconds int    `json:"maxTimeoutSeconds"`
+	Asset             string `json:"asset"`
+	Extra             *Extra `json:"extra,omitempty"`
+}
+
+// Extra carries asset metadata needed to sign a payment.
+type Extra struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// ResourceHash binds the offer to the requested resource. Gateways that
+	// set it reject payments whose accepted requirements do not echo it.
+	ResourceHash string `json:"resourceHash,omitempty"`
+	// Challenge and Nonce bind the offer to the client's TLS certificate or
+	// session. A Signer must sign the authorization with Nonce instead of a
+	// random one and keep Challenge in the accepted requirements.
+	Challenge string `json:"challenge,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+}
+
+// Settlement is the decoded PAYMENT-RESPONSE header of a paid response.
+type Settlement struct {
+	Success     bool   `json:"success"`
+	ErrorReason string `json:"errorReason,omitempty"`
+	Payer       string `json:"payer,omitempty"`
+	Transaction string `json:"transaction,omitempty"`
+	Network     string `json:"network,omitempty"`
+}
+
+// ParsePaymentRequired decodes the PAYMENT-REQUIRED header of a response.
+func ParsePaymentRequired(resp *http.Response) (*PaymentRequired, error) {
+	var required PaymentRequired
+	if err := decodeHeader(resp.Header.Get(HeaderPaymentRequired), &required); err != nil {
+		return nil, err
+	}
+	return &required, nil
+}
+
+// ParseSettlement decodes the PAYMENT-RESPONSE header of a response. It
+// returns nil without error when the header is absent.
+func ParseSettlement(resp *http.Response) (*Settlement, error) {
+	raw := resp.Header.Get(HeaderPaymentResponse)
+	if raw == "" {
+		return nil, nil
+	}
+	var settlement Settlement
+	if err := decodeHeader(raw, &settlement); err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+func decodeHeader(raw string, v any) error {
+	if raw == "" {
+		return ErrNoPaymentRequired
+	}
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return fmt.Errorf("base64 decode header: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unmarshal header: %w", err)
+	}
+	return nil
+}
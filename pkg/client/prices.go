@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// PricesPath is the gateway endpoint that lists the prices of a host.
+const PricesPath = "/x402/prices"
+
+// PriceTable is the price list the gateway publishes for a host.
+type PriceTable struct {
+	Host string `json:"host"`
+	// BaseURL is the public origin of the paths, such as
+	// https://api.example.com, when the gateway knows it.
+	BaseURL string  `json:"baseURL,omitempty"`
+	Prices  []Price `json:"prices"`
+}
+
+// Price is what a gated path charges. For metered paths Price is the most a
+// request pays.
+type Price struct {
+	Path        string            `json:"path"`
+	Price       string            `json:"price"`
+	Asset       string            `json:"asset,omitempty"`
+	Network     string            `json:"network"`
+	Sandbox     bool              `json:"sandbox,omitempty"`
+	Metered     bool              `json:"metered,omitempty"`
+	Conditional bool              `json:"conditional,omitempty"`
+	Offers      []Offer           `json:"offers,omitempty"`
+	Operations  map[string]string `json:"operations,omitempty"`
+	VariesBy    []string          `json:"variesBy,omitempty"`
+}
+
+// Offer is one of several prices advertised for a path.
+type Offer struct {
+	Name  string `json:"name"`
+	Price string `json:"price"`
+}
+
+// FetchPrices returns the price table the gateway serving rawURL publishes
+// for its host. A nil c uses http.DefaultClient.
+func FetchPrices(ctx context.Context, c *http.Client, rawURL string) (*PriceTable, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	u.Path, u.RawQuery, u.Fragment = PricesPath, "format=json", ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch prices: status %d", resp.StatusCode)
+	}
+	var table PriceTable
+	if err := json.NewDecoder(resp.Body).Decode(&table); err != nil {
+		return nil, fmt.Errorf("decode prices: %w", err)
+	}
+	return &table, nil
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchPrices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PricesPath || r.URL.Query().Get("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"host":"api.example.com","prices":[{"path":"/api/*","price":"0.01","network":"base","offers":[{"name":"pro","price":"0.05"}]}]}`)
+	}))
+	defer srv.Close()
+
+	table, err := FetchPrices(context.Background(), nil, srv.URL+"/api/hello?q=1")
+	if err != nil {
+		t.Fatalf("FetchPrices() error = %v", err)
+	}
+	if table.Host != "api.example.com" || len(table.Prices) != 1 {
+		t.Fatalf("table = %+v", table)
+	}
+	if p := table.Prices[0]; p.Path != "/api/*" || p.Price != "0.01" || p.Network != "base" || len(p.Offers) != 1 || p.Offers[0].Name != "pro" {
+		t.Errorf("price = %+v", p)
+	}
+
+	old := httptest.NewServer(http.NotFoundHandler())
+	defer old.Close()
+	if _, err := FetchPrices(context.Background(), old.Client(), old.URL); err == nil {
+		t.Error("FetchPrices() from a gateway without a price table: error = nil")
+	}
+	srv.Close()
+	if _, err := FetchPrices(context.Background(), nil, srv.URL); err == nil {
+		t.Error("FetchPrices() on a closed server: error = nil")
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Signer produces a payment payload for a 402 response. The payload is the
+// JSON document sent Base64-encoded in the Payment-Signature header; it
+// usually signs a transfer for one of required.Accepts.
+type Signer interface {
+	Sign(ctx context.Context, required *PaymentRequired) ([]byte, error)
+}
+
+// SignerFunc adapts a function to the Signer interface.
+type SignerFunc func(ctx context.Context, required *PaymentRequired) ([]byte, error)
+
+// Sign calls f(ctx, required).
+func (f SignerFunc) Sign(ctx context.Context, required *PaymentRequired) ([]byte, error) {
+	return f(ctx, required)
+}
+
+// Transport is an http.RoundTripper that pays for 402 responses. When a
+// response asks for payment, the request is retried once with a payment
+// payload from Signer. Requests that already carry a Payment-Signature header,
+// and requests whose body cannot be replayed (no GetBody), are not retried.
+type Transport struct {
+	// Base is the underlying transport. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Signer signs payments. Required.
+	Signer Signer
+}
+
+// NewClient returns an http.Client that pays for 402 responses with signer.
+func NewClient(signer Signer) *http.Client {
+	return &http.Client{Transport: &Transport{Signer: signer}}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base().RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusPaymentRequired {
+		return resp, err
+	}
+	if req.Header.Get(HeaderPaymentSignature) != "" {
+		return resp, nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	required, err := ParsePaymentRequired(resp)
+	if err != nil {
+		// Not an x402 response; hand it back untouched.
+		return resp, nil
+	}
+	payload, err := t.Signer.Sign(req.Context(), required)
+	if err != nil {
+		closeBody(resp)
+		return nil, fmt.Errorf("sign x402 payment: %w", err)
+	}
+	closeBody(resp)
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("rewind request body: %w", err)
+		}
+		retry.Body = body
+	}
+	retry.Header.Set(HeaderPaymentSignature, base64.StdEncoding.EncodeToString(payload))
+	return t.base().RoundTrip(retry)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// closeBody drains and closes a response body so the connection can be reused.
+func closeBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
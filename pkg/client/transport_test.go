@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newPaywall returns a server that answers 402 until the request carries a
+// Payment-Signature header, then echoes the request body.
+func newPaywall(t *testing.T) *httptest.Server {
+	t.Helper()
+	required, _ := json.Marshal(PaymentRequired{
+		X402Version: 2,
+		Resource:    &Resource{URL: "http://example.com/api/data"},
+		Accepts:     []Accept{{Scheme: "exact", Network: "eip155:84532", Amount: "1000", PayTo: "0xTestWallet"}},
+	})
+	settlement, _ := json.Marshal(Settlement{Success: true, Transaction: "0xabc"})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderPaymentSignature) == "" {
+			w.Header().Set(HeaderPaymentRequired, base64.StdEncoding.EncodeToString(required))
+			w.WriteHeader(http.StatusPaymentRequired)
+			return
+		}
+		w.Header().Set(HeaderPaymentResponse, base64.StdEncoding.EncodeToString(settlement))
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTransportPays(t *testing.T) {
+	srv := newPaywall(t)
+	var signed *PaymentRequired
+	c := NewClient(SignerFunc(func(_ context.Context, required *PaymentRequired) ([]byte, error) {
+		signed = required
+		return []byte(`{"payload":"signed"}`), nil
+	}))
+
+	resp, err := c.Post(srv.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("response = %d %q, want 200 \"hello\"", resp.StatusCode, body)
+	}
+	if signed == nil || len(signed.Accepts) != 1 || signed.Accepts[0].Amount != "1000" {
+		t.Errorf("signer got requirements %+v", signed)
+	}
+	settlement, err := ParseSettlement(resp)
+	if err != nil || settlement == nil || settlement.Transaction != "0xabc" {
+		t.Errorf("ParseSettlement() = %+v, %v", settlement, err)
+	}
+}
+
+func TestTransportDoesNotRetry(t *testing.T) {
+	srv := newPaywall(t)
+	calls := 0
+	signer := SignerFunc(func(context.Context, *PaymentRequired) ([]byte, error) {
+		calls++
+		return []byte(`{}`), nil
+	})
+
+	tests := []struct {
+		name  string
+		build func() *http.Request
+	}{
+		{name: "already paid", build: func() *http.Request {
+			req, _ := http.NewRequest("GET", srv.URL, nil)
+			req.Header.Set(HeaderPaymentSignature, "invalid")
+			return req
+		}},
+		{name: "body cannot be replayed", build: func() *http.Request {
+			req, _ := http.NewRequest("POST", srv.URL, io.NopCloser(strings.NewReader("x")))
+			return req
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			resp, err := (&Transport{Signer: signer}).RoundTrip(tt.build())
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			resp.Body.Close()
+			if calls != 0 {
+				t.Errorf("signer called %d times, want 0", calls)
+			}
+		})
+	}
+}
+
+func TestTransportSignerError(t *testing.T) {
+	srv := newPaywall(t)
+	signErr := errors.New("insufficient funds")
+	c := NewClient(SignerFunc(func(context.Context, *PaymentRequired) ([]byte, error) {
+		return nil, signErr
+	}))
+
+	if _, err := c.Get(srv.URL); !errors.Is(err, signErr) {
+		t.Errorf("Get() error = %v, want %v", err, signErr)
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/pkg/generated/clientset/versioned/typed/x402/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeX402PricePlans implements X402PricePlanInterface
+type fakeX402PricePlans struct {
+	*gentype.FakeClientWithList[*v1alpha1.X402PricePlan, *v1alpha1.X402PricePlanList]
+	Fake *FakeX402V1alpha1
+}
+
+func newFakeX402PricePlans(fake *FakeX402V1alpha1, namespace string) x402v1alpha1.X402PricePlanInterface {
+	return &fakeX402PricePlans{
+		gentype.NewFakeClientWithList[*v1alpha1.X402PricePlan, *v1alpha1.X402PricePlanList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("x402priceplans"),
+			v1alpha1.SchemeGroupVersion.WithKind("X402PricePlan"),
+			func() *v1alpha1.X402PricePlan { return &v1alpha1.X402PricePlan{} },
+			func() *v1alpha1.X402PricePlanList { return &v1alpha1.X402PricePlanList{} },
+			func(dst, src *v1alpha1.X402PricePlanList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.X402PricePlanList) []*v1alpha1.X402PricePlan {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.X402PricePlanList, items []*v1alpha1.X402PricePlan) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/pkg/generated/clientset/versioned/typed/x402/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeX402Routes implements X402RouteInterface
+type fakeX402Routes struct {
+	*gentype.FakeClientWithList[*v1alpha1.X402Route, *v1alpha1.X402RouteList]
+	Fake *FakeX402V1alpha1
+}
+
+func newFakeX402Routes(fake *FakeX402V1alpha1, namespace string) x402v1alpha1.X402RouteInterface {
+	return &fakeX402Routes{
+		gentype.NewFakeClientWithList[*v1alpha1.X402Route, *v1alpha1.X402RouteList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("x402routes"),
+			v1alpha1.SchemeGroupVersion.WithKind("X402Route"),
+			func() *v1alpha1.X402Route { return &v1alpha1.X402Route{} },
+			func() *v1alpha1.X402RouteList { return &v1alpha1.X402RouteList{} },
+			func(dst, src *v1alpha1.X402RouteList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.X402RouteList) []*v1alpha1.X402Route { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1alpha1.X402RouteList, items []*v1alpha1.X402Route) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	scheme "github.com/razvanmacovei/x402-k8s-operator/pkg/generated/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// X402RoutesGetter has a method to return a X402RouteInterface.
+// A group's client should implement this interface.
+type X402RoutesGetter interface {
+	X402Routes(namespace string) X402RouteInterface
+}
+
+// X402RouteInterface has methods to work with X402Route resources.
+type X402RouteInterface interface {
+	Create(ctx context.Context, x402Route *x402v1alpha1.X402Route, opts metav1.CreateOptions) (*x402v1alpha1.X402Route, error)
+	Update(ctx context.Context, x402Route *x402v1alpha1.X402Route, opts metav1.UpdateOptions) (*x402v1alpha1.X402Route, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, x402Route *x402v1alpha1.X402Route, opts metav1.UpdateOptions) (*x402v1alpha1.X402Route, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*x402v1alpha1.X402Route, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*x402v1alpha1.X402RouteList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *x402v1alpha1.X402Route, err error)
+	X402RouteExpansion
+}
+
+// x402Routes implements X402RouteInterface
+type x402Routes struct {
+	*gentype.ClientWithList[*x402v1alpha1.X402Route, *x402v1alpha1.X402RouteList]
+}
+
+// newX402Routes returns a X402Routes
+func newX402Routes(c *X402V1alpha1Client, namespace string) *x402Routes {
+	return &x402Routes{
+		gentype.NewClientWithList[*x402v1alpha1.X402Route, *x402v1alpha1.X402RouteList](
+			"x402routes",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *x402v1alpha1.X402Route { return &x402v1alpha1.X402Route{} },
+			func() *x402v1alpha1.X402RouteList { return &x402v1alpha1.X402RouteList{} },
+		),
+	}
+}
@@ -0,0 +1,5 @@
+// Package generated holds the typed clientset, listers and informers for the
+// x402.io/v1alpha1 API, for controllers and tools that work with X402Route
+// objects without a dynamic client. The subpackages are generated by
+// k8s.io/code-generator with make generate-client and must not be edited.
+package generated
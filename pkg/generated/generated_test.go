@@ -0,0 +1,74 @@
+package generated_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/generated/clientset/versioned/fake"
+	"github.com/razvanmacovei/x402-k8s-operator/pkg/generated/informers/externalversions"
+)
+
+func TestClientsetInformerLister(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	existing := &x402v1alpha1.X402Route{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "existing"}}
+	cs := fake.NewSimpleClientset(existing)
+	factory := externalversions.NewSharedInformerFactory(cs, 0)
+	informer := factory.X402().V1alpha1().X402Routes()
+	lister := informer.Lister()
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	for typ, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			t.Fatalf("informer for %v did not sync", typ)
+		}
+	}
+
+	created := &x402v1alpha1.X402Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "my-api"},
+		Spec:       x402v1alpha1.X402RouteSpec{Routes: []x402v1alpha1.RouteRule{{Path: "/api/*"}}},
+	}
+	if _, err := cs.X402V1alpha1().X402Routes("shop").Create(ctx, created, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), func() bool {
+		_, err := lister.X402Routes("shop").Get("my-api")
+		return err == nil
+	}) {
+		t.Fatal("lister never saw the created route")
+	}
+
+	got, err := lister.X402Routes("shop").Get("my-api")
+	if err != nil || len(got.Spec.Routes) != 1 || got.Spec.Routes[0].Path != "/api/*" {
+		t.Fatalf("Get() = %+v, %v, want the created route", got, err)
+	}
+	all, err := lister.List(labels.Everything())
+	if err != nil || len(all) != 2 {
+		t.Errorf("List() = %d routes, %v, want 2", len(all), err)
+	}
+	if _, err := factory.ForResource(x402v1alpha1.SchemeGroupVersion.WithResource("x402routes")); err != nil {
+		t.Errorf("ForResource() error = %v", err)
+	}
+
+	plan := &x402v1alpha1.X402PricePlan{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "standard"},
+		Spec:       x402v1alpha1.X402PricePlanSpec{DefaultPrice: "0.001", Routes: []x402v1alpha1.RouteRule{{Path: "/health", Free: true}}},
+	}
+	if _, err := cs.X402V1alpha1().X402PricePlans("shop").Create(ctx, plan, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() plan error = %v", err)
+	}
+	gotPlan, err := cs.X402V1alpha1().X402PricePlans("shop").Get(ctx, "standard", metav1.GetOptions{})
+	if err != nil || gotPlan.Spec.DefaultPrice != "0.001" {
+		t.Errorf("Get() plan = %+v, %v, want the created plan", gotPlan, err)
+	}
+	if _, err := factory.ForResource(x402v1alpha1.SchemeGroupVersion.WithResource("x402priceplans")); err != nil {
+		t.Errorf("ForResource() plans error = %v", err)
+	}
+}
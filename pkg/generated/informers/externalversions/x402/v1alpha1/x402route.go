@@ -0,0 +1,86 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+	time "time"
+
+	apix402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	versioned "github.com/razvanmacovei/x402-k8s-operator/pkg/generated/clientset/versioned"
+	internalinterfaces "github.com/razvanmacovei/x402-k8s-operator/pkg/generated/informers/externalversions/internalinterfaces"
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/pkg/generated/listers/x402/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// X402RouteInformer provides access to a shared informer and lister for
+// X402Routes.
+type X402RouteInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() x402v1alpha1.X402RouteLister
+}
+
+type x402RouteInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewX402RouteInformer constructs a new informer for X402Route type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewX402RouteInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredX402RouteInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredX402RouteInformer constructs a new informer for X402Route type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredX402RouteInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		cache.ToListWatcherWithWatchListSemantics(&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.X402V1alpha1().X402Routes(namespace).List(context.Background(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.X402V1alpha1().X402Routes(namespace).Watch(context.Background(), options)
+			},
+			ListWithContextFunc: func(ctx context.Context, options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.X402V1alpha1().X402Routes(namespace).List(ctx, options)
+			},
+			WatchFuncWithContext: func(ctx context.Context, options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.X402V1alpha1().X402Routes(namespace).Watch(ctx, options)
+			},
+		}, client),
+		&apix402v1alpha1.X402Route{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *x402RouteInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredX402RouteInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *x402RouteInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&apix402v1alpha1.X402Route{}, f.defaultInformer)
+}
+
+func (f *x402RouteInformer) Lister() x402v1alpha1.X402RouteLister {
+	return x402v1alpha1.NewX402RouteLister(f.Informer().GetIndexer())
+}
@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	x402v1alpha1 "github.com/razvanmacovei/x402-k8s-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// X402RouteLister helps list X402Routes.
+// All objects returned here must be treated as read-only.
+type X402RouteLister interface {
+	// List lists all X402Routes in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*x402v1alpha1.X402Route, err error)
+	// X402Routes returns an object that can list and get X402Routes.
+	X402Routes(namespace string) X402RouteNamespaceLister
+	X402RouteListerExpansion
+}
+
+// x402RouteLister implements the X402RouteLister interface.
+type x402RouteLister struct {
+	listers.ResourceIndexer[*x402v1alpha1.X402Route]
+}
+
+// NewX402RouteLister returns a new X402RouteLister.
+func NewX402RouteLister(indexer cache.Indexer) X402RouteLister {
+	return &x402RouteLister{listers.New[*x402v1alpha1.X402Route](indexer, x402v1alpha1.Resource("x402route"))}
+}
+
+// X402Routes returns an object that can list and get X402Routes.
+func (s *x402RouteLister) X402Routes(namespace string) X402RouteNamespaceLister {
+	return x402RouteNamespaceLister{listers.NewNamespaced[*x402v1alpha1.X402Route](s.ResourceIndexer, namespace)}
+}
+
+// X402RouteNamespaceLister helps list and get X402Routes.
+// All objects returned here must be treated as read-only.
+type X402RouteNamespaceLister interface {
+	// List lists all X402Routes in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*x402v1alpha1.X402Route, err error)
+	// Get retrieves the X402Route from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*x402v1alpha1.X402Route, error)
+	X402RouteNamespaceListerExpansion
+}
+
+// x402RouteNamespaceLister implements the X402RouteNamespaceLister
+// interface.
+type x402RouteNamespaceLister struct {
+	listers.ResourceIndexer[*x402v1alpha1.X402Route]
+}
@@ -0,0 +1,206 @@
+// Package signer isolates the signing and verification done by the operator
+// behind interfaces, so keys held in a KMS or HSM, or implementations
+// restricted to FIPS-approved algorithms, can be plugged in without touching
+// the code that mints or checks signatures.
+//
+// The built-in implementations are HMAC-SHA256 and Ed25519, both pure Go and
+// FIPS 140-3 approved. Built with GOFIPS140 set, they run in the Go
+// Cryptographic Module; FIPS reports whether it is active.
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/fips140"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// JWS algorithms of the built-in keys.
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// ErrUnsupportedKey is returned for keys no built-in verifier handles.
+var ErrUnsupportedKey = errors.New("unsupported signing key")
+
+// Key describes a signing key.
+type Key interface {
+	// Algorithm is the JWS algorithm of the signatures, e.g. "EdDSA".
+	Algorithm() string
+	// Public returns the key that verifies signatures, or nil for symmetric
+	// keys, which must never be published.
+	Public() crypto.PublicKey
+}
+
+// Signer signs messages. Implementations backed by a KMS or HSM never expose
+// the private key and may call out to a remote service, bounded by ctx.
+type Signer interface {
+	Key
+	Sign(ctx context.Context, message []byte) ([]byte, error)
+}
+
+// Verifier checks signatures made by a Signer.
+type Verifier interface {
+	Key
+	Verify(message, signature []byte) bool
+}
+
+// Ring is a key with several versions, such as a KMS key rotated in place.
+// Signatures are made with the active version and checked with any version,
+// so tokens signed before a rotation stay valid.
+type Ring interface {
+	// Versions lists the usable versions by key ID and names the active one.
+	Versions(ctx context.Context) (active string, versions map[string]Signer, err error)
+}
+
+// HMAC is an HS256 key. It both signs and verifies.
+type HMAC struct {
+	secret []byte
+}
+
+// NewHMAC returns an HS256 key.
+func NewHMAC(secret []byte) *HMAC {
+	return &HMAC{secret: secret}
+}
+
+func (k *HMAC) Algorithm() string        { return AlgHS256 }
+func (k *HMAC) Public() crypto.PublicKey { return nil }
+
+func (k *HMAC) Sign(_ context.Context, message []byte) ([]byte, error) {
+	return k.mac(message), nil
+}
+
+func (k *HMAC) Verify(message, signature []byte) bool {
+	return hmac.Equal(signature, k.mac(message))
+}
+
+func (k *HMAC) mac(message []byte) []byte {
+	h := hmac.New(sha256.New, k.secret)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// Ed25519 is an EdDSA key. Keys built from a public key only verify.
+type Ed25519 struct {
+	private ed25519.PrivateKey // nil for verify-only keys
+	public  ed25519.PublicKey
+}
+
+// NewEd25519 returns an EdDSA key that signs and verifies.
+func NewEd25519(private ed25519.PrivateKey) *Ed25519 {
+	return &Ed25519{private: private, public: private.Public().(ed25519.PublicKey)}
+}
+
+// NewEd25519Verifier returns an EdDSA key that only verifies.
+func NewEd25519Verifier(public ed25519.PublicKey) *Ed25519 {
+	return &Ed25519{public: public}
+}
+
+func (k *Ed25519) Algorithm() string        { return AlgEdDSA }
+func (k *Ed25519) Public() crypto.PublicKey { return k.public }
+
+func (k *Ed25519) Sign(_ context.Context, message []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, fmt.Errorf("ed25519 key has no private key: %w", ErrUnsupportedKey)
+	}
+	return ed25519.Sign(k.private, message), nil
+}
+
+func (k *Ed25519) Verify(message, signature []byte) bool {
+	return ed25519.Verify(k.public, message, signature)
+}
+
+// ParsePrivateKey reads a PEM-encoded PKCS#8 Ed25519 private key, or treats
+// data without PEM block as a raw HMAC secret.
+func ParsePrivateKey(data []byte) (Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return NewHMAC(data), nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#8 private key: %w", err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key type %T: %w", parsed, ErrUnsupportedKey)
+	}
+	return NewEd25519(private), nil
+}
+
+// NewVerifier returns the verifier of s: s itself when it verifies, or a
+// local verifier for its public key, so signatures of remote keys are
+// checked without calling out.
+func NewVerifier(s Signer) (Verifier, error) {
+	if v, ok := s.(Verifier); ok {
+		return v, nil
+	}
+	switch public := s.Public().(type) {
+	case ed25519.PublicKey:
+		return NewEd25519Verifier(public), nil
+	}
+	return nil, fmt.Errorf("%s key with public key %T: %w", s.Algorithm(), s.Public(), ErrUnsupportedKey)
+}
+
+// Opener opens a signer held outside the process, such as a KMS key, from a
+// URI whose scheme it was registered for.
+type Opener func(ctx context.Context, uri string) (Signer, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[string]Opener{}
+)
+
+// Register makes open handle key URIs of scheme, e.g. "awskms". It panics if
+// the scheme is already registered, and is meant to be called from init.
+func Register(scheme string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	if _, dup := openers[scheme]; dup {
+		panic("signer: scheme " + scheme + " registered twice")
+	}
+	openers[scheme] = open
+}
+
+// Schemes returns the registered key URI schemes, sorted.
+func Schemes() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+	schemes := make([]string, 0, len(openers))
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open returns the signer of a key URI with the opener of its scheme.
+func Open(ctx context.Context, uri string) (Signer, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("invalid key URI %q", uri)
+	}
+	openersMu.RLock()
+	open, ok := openers[u.Scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("key URI %q: no signer registered for scheme %q", uri, u.Scheme)
+	}
+	return open(ctx, uri)
+}
+
+// FIPS reports whether the process runs in FIPS 140-3 mode, as set by
+// GOFIPS140 at build time or GODEBUG=fips140 at run time.
+func FIPS() bool {
+	return fips140.Enabled()
+}
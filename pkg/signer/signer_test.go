@@ -0,0 +1,102 @@
+package signer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+// remoteSigner hides everything but the Signer methods, like a KMS key.
+type remoteSigner struct{ Signer }
+
+func TestSignAndVerify(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	parsed, err := ParsePrivateKey(pemKey)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		signer     Signer
+		wantAlg    string
+		wantPublic bool
+	}{
+		{name: "hmac", signer: NewHMAC([]byte("secret")), wantAlg: AlgHS256},
+		{name: "ed25519", signer: NewEd25519(private), wantAlg: AlgEdDSA, wantPublic: true},
+		{name: "parsed PEM", signer: parsed, wantAlg: AlgEdDSA, wantPublic: true},
+		{name: "remote ed25519", signer: remoteSigner{NewEd25519(private)}, wantAlg: AlgEdDSA, wantPublic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signer.Algorithm(); got != tt.wantAlg {
+				t.Errorf("Algorithm() = %q, want %q", got, tt.wantAlg)
+			}
+			if got := tt.signer.Public() != nil; got != tt.wantPublic {
+				t.Errorf("Public() set = %v, want %v", got, tt.wantPublic)
+			}
+			sig, err := tt.signer.Sign(context.Background(), []byte("message"))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			v, err := NewVerifier(tt.signer)
+			if err != nil {
+				t.Fatalf("NewVerifier() error = %v", err)
+			}
+			if !v.Verify([]byte("message"), sig) {
+				t.Error("Verify() rejected a valid signature")
+			}
+			if v.Verify([]byte("tampered"), sig) {
+				t.Error("Verify() accepted a signature of another message")
+			}
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	if s, err := ParsePrivateKey([]byte("raw secret")); err != nil || s.Algorithm() != AlgHS256 {
+		t.Errorf("ParsePrivateKey(raw) = %v, %v, want an HS256 key", s, err)
+	}
+	if _, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")})); err == nil {
+		t.Error("ParsePrivateKey(bad PEM) succeeded")
+	}
+	if _, err := NewEd25519Verifier(make(ed25519.PublicKey, ed25519.PublicKeySize)).Sign(context.Background(), nil); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("Sign() with a verify-only key error = %v, want ErrUnsupportedKey", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	key := NewHMAC([]byte("secret"))
+	Register("testkms", func(_ context.Context, uri string) (Signer, error) {
+		if uri != "testkms://keys/k1" {
+			return nil, errors.New("unknown key")
+		}
+		return key, nil
+	})
+
+	tests := []struct {
+		uri     string
+		want    Signer
+		wantErr bool
+	}{
+		{uri: "testkms://keys/k1", want: key},
+		{uri: "testkms://keys/k2", wantErr: true},
+		{uri: "otherkms://keys/k1", wantErr: true},
+		{uri: "no-scheme", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Open(context.Background(), tt.uri)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Open(%q) = %v, %v, want %v, error %v", tt.uri, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if schemes := Schemes(); len(schemes) != 1 || schemes[0] != "testkms" {
+		t.Errorf("Schemes() = %v, want [testkms]", schemes)
+	}
+}